@@ -10,17 +10,25 @@ import (
 
 	"github.com/tazhate/familybot/config"
 	"github.com/tazhate/familybot/internal/bot"
-	"github.com/tazhate/familybot/internal/clients/caldav"
 	"github.com/tazhate/familybot/internal/clients/debtmanager"
 	"github.com/tazhate/familybot/internal/clients/todoist"
 	"github.com/tazhate/familybot/internal/scheduler"
 	"github.com/tazhate/familybot/internal/service"
+	"github.com/tazhate/familybot/internal/speech"
 	"github.com/tazhate/familybot/internal/storage"
 )
 
 func main() {
 	log.SetFlags(log.LstdFlags | log.Lshortfile)
 
+	// familybot migrate status|up|down
+	if len(os.Args) > 1 && os.Args[1] == "migrate" {
+		if err := runMigrate(os.Args[2:], os.Stdout); err != nil {
+			log.Fatalf("migrate: %v", err)
+		}
+		return
+	}
+
 	// Загрузка конфига
 	cfg, err := config.Load()
 	if err != nil {
@@ -28,7 +36,7 @@ func main() {
 	}
 
 	// Инициализация storage
-	store, err := storage.New(cfg.DatabasePath)
+	store, err := storage.New(cfg.DatabaseURL, cfg.DatabasePath)
 	if err != nil {
 		log.Fatalf("Failed to init storage: %v", err)
 	}
@@ -37,11 +45,25 @@ func main() {
 	// Инициализация сервисов
 	taskSvc := service.NewTaskService(store)
 	reminderSvc := service.NewReminderService(store, cfg.Timezone)
+	if err := reminderSvc.RecalculateNextRuns(); err != nil {
+		log.Printf("Failed to recalculate reminders: %v", err)
+	}
 	personSvc := service.NewPersonService(store)
 	personSvc.SetReminderService(reminderSvc) // для автосоздания напоминаний о ДР
 	scheduleSvc := service.NewScheduleService(store)
-	autoSvc := service.NewAutoService(store)
-	checklistSvc := service.NewChecklistService(store)
+	autoSvc := service.NewAutoService(store, cfg.Timezone)
+	checklistSvc := service.NewChecklistService(store, cfg.Timezone)
+	attachmentSvc := service.NewAttachmentService(store)
+	if cfg.AttachmentsDir != "" {
+		attachmentSvc.SetBlobDir(cfg.AttachmentsDir)
+	}
+	searchSvc := service.NewSearchService(store)
+	feedSvc := service.NewFeedService(store, cfg.Timezone)
+	settingsSvc := service.NewSettingsService(store, cfg.Timezone, cfg.MorningTime, cfg.EveningTime)
+	householdSvc := service.NewHouseholdService(store)
+	if err := householdSvc.Bootstrap(cfg.OwnerTelegramID, cfg.PartnerTelegramID); err != nil {
+		log.Printf("Failed to bootstrap household: %v", err)
+	}
 
 	// Инициализация клиента Debt Manager (опционально)
 	var debtClient *debtmanager.Client
@@ -50,22 +72,17 @@ func main() {
 		log.Printf("Debt Manager client configured: %s", cfg.DebtManagerURL)
 	}
 
-	// Инициализация CalDAV клиента и CalendarService (опционально)
-	var calendarSvc *service.CalendarService
+	// CalendarService: календари подключают через /calendars; CALDAV_* из конфигурации
+	// становятся аккаунтом владельца
+	calendarSvc := service.NewCalendarService(store, cfg.Timezone)
 	if cfg.CalDAVUsername != "" && cfg.CalDAVPassword != "" {
-		caldavClient := caldav.NewClient(cfg.CalDAVURL, cfg.CalDAVUsername, cfg.CalDAVPassword)
-		if cfg.CalDAVCalendarID != "" {
-			caldavClient.SetCalendarID(cfg.CalDAVCalendarID)
-		}
-
-		// Get owner user ID
 		ownerUser, err := store.GetUserByTelegramID(cfg.OwnerTelegramID)
 		if err == nil && ownerUser != nil {
-			calendarSvc = service.NewCalendarService(store, caldavClient, ownerUser.ID, cfg.Timezone)
-			if cfg.CalDAVCalendarID != "" {
-				calendarSvc.SetCalendarPath(cfg.CalDAVCalendarID)
+			if err := calendarSvc.LinkFromConfig(ownerUser.ID, cfg.CalDAVURL, cfg.CalDAVUsername, cfg.CalDAVPassword, cfg.CalDAVCalendarID); err != nil {
+				log.Printf("Failed to link CalDAV account from config: %v", err)
+			} else {
+				log.Printf("CalDAV account from config linked: %s", cfg.CalDAVURL)
 			}
-			log.Printf("Apple Calendar (CalDAV) configured: %s", cfg.CalDAVURL)
 		} else {
 			log.Printf("Warning: CalDAV configured but owner user not found in DB")
 		}
@@ -104,18 +121,25 @@ func main() {
 	}
 
 	// Инициализация бота
-	tgBot, err := bot.New(cfg, store, taskSvc, reminderSvc, personSvc, scheduleSvc, autoSvc, checklistSvc, calendarSvc, todoistSvc, debtClient)
+	tgBot, err := bot.New(cfg, store, taskSvc, reminderSvc, personSvc, scheduleSvc, autoSvc, checklistSvc, searchSvc, calendarSvc, todoistSvc, householdSvc, settingsSvc, attachmentSvc, feedSvc, debtClient)
 	if err != nil {
 		log.Fatalf("Failed to init bot: %v", err)
 	}
 
-	// Настройка webhook
-	if err := tgBot.SetupWebhook(); err != nil {
-		log.Fatalf("Failed to setup webhook: %v", err)
+	if cfg.WhisperBin != "" {
+		tgBot.SetTranscriber(speech.NewWhisperCPP(cfg.WhisperBin, cfg.WhisperModel, cfg.WhisperLanguage, cfg.FFmpegBin))
+		log.Printf("Voice notes enabled (whisper: %s, model: %s)", cfg.WhisperBin, cfg.WhisperModel)
+	}
+
+	// Настройка webhook (в режиме polling бот сам забирает апдейты, см. Bot.Start)
+	if cfg.TelegramMode == config.TelegramModeWebhook {
+		if err := tgBot.SetupWebhook(); err != nil {
+			log.Fatalf("Failed to setup webhook: %v", err)
+		}
 	}
 
 	// Инициализация scheduler
-	sched := scheduler.New(cfg, store, taskSvc, reminderSvc, personSvc, scheduleSvc, checklistSvc, calendarSvc, todoistSvc, debtClient)
+	sched := scheduler.New(cfg, store, taskSvc, reminderSvc, personSvc, scheduleSvc, checklistSvc, calendarSvc, todoistSvc, settingsSvc, autoSvc, debtClient)
 	sched.SetSender(tgBot)
 
 	// Контекст для graceful shutdown
@@ -0,0 +1,97 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+
+	"github.com/tazhate/familybot/config"
+	"github.com/tazhate/familybot/internal/storage"
+)
+
+const migrateUsage = `usage: familybot migrate <command>
+
+commands:
+  status             show applied and pending migrations
+  up [version]       apply migrations up to version (default: latest)
+  down [version]     roll back to version (default: one step back)`
+
+// runMigrate обрабатывает `familybot migrate status|up|down`.
+func runMigrate(args []string, out io.Writer) error {
+	if len(args) == 0 {
+		return errors.New(migrateUsage)
+	}
+
+	var target int
+	if len(args) > 1 {
+		v, err := strconv.Atoi(args[1])
+		if err != nil || v < 0 {
+			return fmt.Errorf("invalid version %q", args[1])
+		}
+		target = v
+	}
+
+	store, err := storage.Open(config.DatabaseURL(), config.DatabasePath())
+	if err != nil {
+		return err
+	}
+	defer store.Close()
+
+	switch args[0] {
+	case "status":
+		return printMigrationStatus(store, out)
+	case "up":
+		if err := store.MigrateUp(target); err != nil {
+			return err
+		}
+	case "down":
+		if len(args) < 2 {
+			current, err := store.SchemaVersion()
+			if err != nil {
+				return err
+			}
+			if current == 0 {
+				fmt.Fprintln(out, "Nothing to roll back")
+				return nil
+			}
+			target = current - 1
+		}
+		if err := store.MigrateDown(target); err != nil {
+			return err
+		}
+	default:
+		return errors.New(migrateUsage)
+	}
+
+	version, err := store.SchemaVersion()
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(out, "Schema version: %d\n", version)
+	return nil
+}
+
+func printMigrationStatus(store *storage.Storage, out io.Writer) error {
+	statuses, err := store.MigrationStatus()
+	if err != nil {
+		return err
+	}
+	version, err := store.SchemaVersion()
+	if err != nil {
+		return err
+	}
+
+	fmt.Fprintf(out, "Schema version: %d (binary supports %d)\n\n", version, storage.LatestSchemaVersion())
+	for _, st := range statuses {
+		state := "pending"
+		if st.AppliedAt != nil {
+			state = "applied " + st.AppliedAt.Format("2006-01-02 15:04:05")
+		}
+		if st.Version > storage.LatestSchemaVersion() {
+			state += " (unknown to this binary)"
+		}
+		fmt.Fprintf(out, "%4d  %-30s %s\n", st.Version, st.Name, state)
+	}
+	return nil
+}
@@ -0,0 +1,53 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/tazhate/familybot/internal/storage"
+)
+
+func TestRunMigrate(t *testing.T) {
+	t.Setenv("DATABASE_URL", "")
+	t.Setenv("DATABASE_PATH", filepath.Join(t.TempDir(), "familybot.db"))
+	latest := storage.LatestSchemaVersion()
+
+	migrate := func(args ...string) string {
+		t.Helper()
+		var out bytes.Buffer
+		if err := runMigrate(args, &out); err != nil {
+			t.Fatalf("migrate %s: %v", strings.Join(args, " "), err)
+		}
+		return out.String()
+	}
+	expect := func(out, want string) {
+		t.Helper()
+		if !strings.Contains(out, want) {
+			t.Errorf("output %q does not contain %q", out, want)
+		}
+	}
+
+	expect(migrate("status"), fmt.Sprintf("Schema version: 0 (binary supports %d)", latest))
+	expect(migrate("up"), fmt.Sprintf("Schema version: %d\n", latest))
+	status := migrate("status")
+	if strings.Contains(status, "pending") {
+		t.Errorf("pending migrations after up:\n%s", status)
+	}
+
+	// Без версии down откатывает один шаг
+	expect(migrate("down"), fmt.Sprintf("Schema version: %d\n", latest-1))
+	expect(migrate("status"), "pending")
+	expect(migrate("down", "0"), "Schema version: 0\n")
+	expect(migrate("down"), "Nothing to roll back")
+	expect(migrate("up", "2"), "Schema version: 2\n")
+	expect(migrate("up"), fmt.Sprintf("Schema version: %d\n", latest))
+
+	for _, args := range [][]string{nil, {"sideways"}, {"down", "-1"}, {"up", "latest"}} {
+		if err := runMigrate(args, &bytes.Buffer{}); err == nil {
+			t.Errorf("migrate %q accepted", args)
+		}
+	}
+}
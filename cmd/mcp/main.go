@@ -14,6 +14,7 @@ import (
 	"net/http"
 	"net/url"
 	"os"
+	"strconv"
 	"strings"
 	"sync"
 	"time"
@@ -822,13 +823,13 @@ func (s *MCPServer) handleToolsListWithRole(req JSONRPCRequest, role UserRole) J
 		},
 		{
 			Name:        "familybot_create_task",
-			Description: "Создать новую задачу. Приоритеты: urgent (срочно), week (на неделю), someday (когда-нибудь).",
+			Description: "Создать новую задачу. Приоритеты: urgent (срочно), week (на неделю), someday (когда-нибудь). Если due_date не указан, дата и время берутся из названия («Позвонить маме завтра в 15:00»).",
 			InputSchema: InputSchema{
 				Type: "object",
 				Properties: map[string]Property{
 					"title":    {Type: "string", Description: "Название задачи"},
 					"priority": {Type: "string", Description: "Приоритет: urgent, week, someday", Enum: []string{"urgent", "week", "someday"}},
-					"due_date": {Type: "string", Description: "Дата дедлайна в формате YYYY-MM-DD (опционально)"},
+					"due_date": {Type: "string", Description: "Дедлайн: YYYY-MM-DD или текстом — «завтра 15:00», «в пятницу», «через 3 дня», «next monday» (опционально)"},
 				},
 				Required: []string{"title"},
 			},
@@ -857,15 +858,18 @@ func (s *MCPServer) handleToolsListWithRole(req JSONRPCRequest, role UserRole) J
 		},
 		{
 			Name:        "familybot_update_task",
-			Description: "Обновить задачу: название, приоритет, дедлайн, тип повторения. Типы повторения: daily (ежедневно), weekdays (Пн-Пт), weekly (раз в неделю), monthly (раз в месяц), '' (без повторения).",
+			Description: "Обновить задачу: название, приоритет, дедлайн, тип или правило (RRULE) повторения, родительскую задачу и блокировки. Типы повторения: daily (ежедневно), weekdays (Пн-Пт), weekly (раз в неделю), monthly (раз в месяц), '' (без повторения).",
 			InputSchema: InputSchema{
 				Type: "object",
 				Properties: map[string]Property{
 					"task_id":     {Type: "string", Description: "ID задачи (число)"},
 					"title":       {Type: "string", Description: "Новое название (опционально)"},
 					"priority":    {Type: "string", Description: "Новый приоритет: urgent, week, someday (опционально)", Enum: []string{"urgent", "week", "someday"}},
-					"due_date":    {Type: "string", Description: "Новый дедлайн YYYY-MM-DD (опционально)"},
+					"due_date":    {Type: "string", Description: "Новый дедлайн: YYYY-MM-DD или текстом — «завтра 15:00», «в пятницу» (опционально)"},
 					"repeat_type": {Type: "string", Description: "Тип повторения: daily, weekdays, weekly, monthly, '' (опционально)", Enum: []string{"", "daily", "weekdays", "weekly", "monthly", "monthly_nth"}},
+					"rrule":       {Type: "string", Description: "Правило повторения RFC 5545 (FREQ=MONTHLY;BYDAY=-1FR) или по-русски («каждые 2 недели в пт»); none — без повторения (опционально)"},
+					"parent_id":   {Type: "string", Description: "ID родительской задачи, сделать подзадачей; 0 — вынести на верхний уровень (опционально)"},
+					"blocked_by":  {Type: "string", Description: "ID задач через запятую, которые надо сделать раньше (заменяет список); none — снять все блокировки (опционально)"},
 				},
 				Required: []string{"task_id"},
 			},
@@ -1146,6 +1150,39 @@ func (s *MCPServer) handleToolsCallWithRole(req JSONRPCRequest, role UserRole) J
 		if repeatType, ok := params.Arguments["repeat_type"]; ok && repeatType != "" {
 			body["repeat_type"] = repeatType
 		}
+		if rrule, ok := params.Arguments["rrule"]; ok && rrule != "" {
+			if rrule == "none" {
+				body["rrule"] = ""
+			} else {
+				body["rrule"] = rrule
+			}
+		}
+		if parentID, ok := params.Arguments["parent_id"]; ok && parentID != "" {
+			id, err := strconv.ParseInt(strings.TrimSpace(fmt.Sprintf("%v", parentID)), 10, 64)
+			if err != nil {
+				result, isError = "Неверный parent_id: "+fmt.Sprintf("%v", parentID), true
+				break
+			}
+			body["parent_id"] = id
+		}
+		if blockedBy, ok := params.Arguments["blocked_by"]; ok && blockedBy != "" {
+			ids := []int64{}
+			raw := strings.TrimSpace(fmt.Sprintf("%v", blockedBy))
+			if raw != "none" {
+				for _, part := range strings.Split(raw, ",") {
+					id, err := strconv.ParseInt(strings.TrimSpace(strings.TrimPrefix(strings.TrimSpace(part), "#")), 10, 64)
+					if err != nil {
+						result, isError = "Неверный blocked_by: "+raw, true
+						break
+					}
+					ids = append(ids, id)
+				}
+				if isError {
+					break
+				}
+			}
+			body["blocked_by"] = ids
+		}
 		result, isError = s.apiPut(apiPrefix+"/task/"+taskID, body)
 	case "familybot_list_people":
 		result, isError = s.apiGet("/api/people") // Same for both
@@ -7,16 +7,25 @@ import (
 	"time"
 )
 
+// Способы получения апдейтов от Telegram
+const (
+	TelegramModeWebhook = "webhook" // Telegram шлёт апдейты на WEBHOOK_URL (нужен публичный HTTPS)
+	TelegramModePolling = "polling" // бот сам забирает апдейты через getUpdates
+)
+
 type Config struct {
 	TelegramToken     string
 	OwnerTelegramID   int64
 	PartnerTelegramID int64
 	GroupChatID       int64 // Group chat for shared messages (e.g., daily quotes)
 	DatabasePath      string
+	DatabaseURL       string // PostgreSQL DSN; if set, used instead of DatabasePath
 	Timezone          *time.Location
 	MorningTime       string
 	EveningTime       string
+	MaxLateness       time.Duration // Более поздние напоминания (бот был выключен) приходят дайджестом «пропущено»
 	WebhookURL        string
+	TelegramMode      string // webhook (по умолчанию) или polling
 	ServerPort        string
 	APIUsername       string
 	APIPassword       string
@@ -33,6 +42,13 @@ type Config struct {
 	TodoistProjectID        string
 	TodoistSectionID        string // Owner's section
 	TodoistPartnerSectionID string // Partner's section
+	// Voice notes: local whisper.cpp (optional)
+	WhisperBin      string // путь к whisper-cli; пусто — голосовые не распознаются
+	WhisperModel    string // ggml-модель, например models/ggml-small.bin
+	WhisperLanguage string
+	FFmpegBin       string // конвертирует voice (ogg/opus) в wav 16 kHz для whisper
+	// Attachments: копии файлов на диске для бэкапа (optional)
+	AttachmentsDir string
 }
 
 func Load() (*Config, error) {
@@ -56,10 +72,8 @@ func Load() (*Config, error) {
 		groupChatID, _ = strconv.ParseInt(g, 10, 64)
 	}
 
-	dbPath := os.Getenv("DATABASE_PATH")
-	if dbPath == "" {
-		dbPath = "./data/familybot.db"
-	}
+	dbPath := DatabasePath()
+	dbURL := DatabaseURL()
 
 	tzName := os.Getenv("TIMEZONE")
 	if tzName == "" {
@@ -80,11 +94,28 @@ func Load() (*Config, error) {
 		eveningTime = "21:00"
 	}
 
+	maxLateness := 30 * time.Minute
+	if v := os.Getenv("SCHEDULER_MAX_LATENESS"); v != "" {
+		maxLateness, err = time.ParseDuration(v)
+		if err != nil || maxLateness < 0 {
+			return nil, fmt.Errorf("invalid SCHEDULER_MAX_LATENESS (e.g. 30m): %q", v)
+		}
+	}
+
 	webhookURL := os.Getenv("WEBHOOK_URL")
 	if webhookURL == "" {
 		webhookURL = "https://family.tazhate.com"
 	}
 
+	telegramMode := os.Getenv("TELEGRAM_MODE")
+	switch telegramMode {
+	case "":
+		telegramMode = TelegramModeWebhook
+	case TelegramModeWebhook, TelegramModePolling:
+	default:
+		return nil, fmt.Errorf("invalid TELEGRAM_MODE (webhook or polling): %q", telegramMode)
+	}
+
 	serverPort := os.Getenv("SERVER_PORT")
 	if serverPort == "" {
 		serverPort = "8080"
@@ -112,16 +143,37 @@ func Load() (*Config, error) {
 	todoistSectionID := os.Getenv("TODOIST_SECTION_ID")
 	todoistPartnerSectionID := os.Getenv("TODOIST_PARTNER_SECTION_ID")
 
+	// Voice notes via whisper.cpp (optional)
+	whisperBin := os.Getenv("WHISPER_BIN")
+	whisperModel := os.Getenv("WHISPER_MODEL")
+	if whisperBin != "" && whisperModel == "" {
+		return nil, fmt.Errorf("WHISPER_MODEL is required when WHISPER_BIN is set")
+	}
+	whisperLanguage := os.Getenv("WHISPER_LANGUAGE")
+	if whisperLanguage == "" {
+		whisperLanguage = "ru"
+	}
+	ffmpegBin := os.Getenv("FFMPEG_BIN")
+	if ffmpegBin == "" {
+		ffmpegBin = "ffmpeg"
+	}
+
+	// Attachments backup (optional): файлы остаются в Telegram, здесь — их копии
+	attachmentsDir := os.Getenv("ATTACHMENTS_DIR")
+
 	return &Config{
 		TelegramToken:     token,
 		OwnerTelegramID:   ownerID,
 		PartnerTelegramID: partnerID,
 		GroupChatID:       groupChatID,
 		DatabasePath:      dbPath,
+		DatabaseURL:       dbURL,
 		Timezone:          tz,
 		MorningTime:       morningTime,
 		EveningTime:       eveningTime,
+		MaxLateness:       maxLateness,
 		WebhookURL:        webhookURL,
+		TelegramMode:      telegramMode,
 		ServerPort:        serverPort,
 		APIUsername:       apiUsername,
 		APIPassword:       apiPassword,
@@ -135,9 +187,29 @@ func Load() (*Config, error) {
 		TodoistProjectID:        todoistProjectID,
 		TodoistSectionID:        todoistSectionID,
 		TodoistPartnerSectionID: todoistPartnerSectionID,
+		WhisperBin:              whisperBin,
+		WhisperModel:            whisperModel,
+		WhisperLanguage:         whisperLanguage,
+		FFmpegBin:               ffmpegBin,
+		AttachmentsDir:          attachmentsDir,
 	}, nil
 }
 
+// DatabasePath returns DATABASE_PATH or the default path.
+// Used separately by `familybot migrate`, which doesn't need the bot token.
+func DatabasePath() string {
+	if p := os.Getenv("DATABASE_PATH"); p != "" {
+		return p
+	}
+	return "./data/familybot.db"
+}
+
+// DatabaseURL returns the PostgreSQL connection string from DATABASE_URL.
+// Empty means the SQLite file at DatabasePath is used.
+func DatabaseURL() string {
+	return os.Getenv("DATABASE_URL")
+}
+
 func (c *Config) IsAllowedUser(telegramID int64) bool {
 	return telegramID == c.OwnerTelegramID || telegramID == c.PartnerTelegramID
 }
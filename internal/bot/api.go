@@ -2,6 +2,7 @@ package bot
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
 	"net/http"
 	"strconv"
@@ -9,6 +10,8 @@ import (
 	"time"
 
 	"github.com/tazhate/familybot/internal/domain"
+	"github.com/tazhate/familybot/internal/nlp/dates"
+	"github.com/tazhate/familybot/internal/service"
 )
 
 // API Response types
@@ -29,6 +32,10 @@ type TaskResponse struct {
 	IsShared   bool    `json:"is_shared"`
 	IsRepeat   bool    `json:"is_repeat"`
 	CreatedAt  string  `json:"created_at"`
+	RRule      string  `json:"rrule,omitempty"`
+	ParentID   *int64  `json:"parent_id,omitempty"`
+	BlockedBy  []int64 `json:"blocked_by,omitempty"`
+	Subtasks   []int64 `json:"subtasks,omitempty"`
 }
 
 type PersonResponse struct {
@@ -81,11 +88,68 @@ type ChecklistItemResponse struct {
 type ChecklistResponse struct {
 	ID        int64                   `json:"id"`
 	Title     string                  `json:"title"`
-	Items     []ChecklistItemResponse `json:"items"`
+	Items     []ChecklistItemResponse `json:"items"` // отметки текущего прогона
+	RunID     *int64                  `json:"run_id,omitempty"`
 	PersonID  *int64                  `json:"person_id,omitempty"`
 	CreatedAt string                  `json:"created_at"`
 }
 
+type ChecklistRunItemResponse struct {
+	Text      string  `json:"text"`
+	Checked   bool    `json:"checked"`
+	CheckedBy *int64  `json:"checked_by,omitempty"`
+	CheckedAt *string `json:"checked_at,omitempty"`
+}
+
+type ChecklistRunResponse struct {
+	ID            int64                      `json:"id"`
+	ChecklistID   int64                      `json:"checklist_id"`
+	UserID        int64                      `json:"user_id"`
+	WeeklyEventID *int64                     `json:"weekly_event_id,omitempty"`
+	Items         []ChecklistRunItemResponse `json:"items"`
+	IsShared      bool                       `json:"is_shared"`
+	StartedAt     string                     `json:"started_at"`
+	CompletedAt   *string                    `json:"completed_at,omitempty"`
+}
+
+type AttachmentResponse struct {
+	ID        int64  `json:"id"`
+	OwnerType string `json:"owner_type"`
+	OwnerID   int64  `json:"owner_id"`
+	Kind      string `json:"kind"`
+	FileID    string `json:"file_id"`
+	FileName  string `json:"file_name,omitempty"`
+	MimeType  string `json:"mime_type,omitempty"`
+	FileSize  int64  `json:"file_size,omitempty"`
+	Caption   string `json:"caption,omitempty"`
+	LocalCopy bool   `json:"local_copy"`
+	CreatedAt string `json:"created_at"`
+}
+
+type SearchResultResponse struct {
+	Kind  string  `json:"kind"`
+	ID    int64   `json:"id"`
+	Title string  `json:"title"`
+	Body  string  `json:"body,omitempty"`
+	Rank  float64 `json:"rank"`
+}
+
+type NotificationResponse struct {
+	ID            int64   `json:"id"`
+	Key           string  `json:"key"`
+	ChatID        int64   `json:"chat_id"`
+	UserID        *int64  `json:"user_id,omitempty"`
+	TaskID        *int64  `json:"task_id,omitempty"`
+	Text          string  `json:"text"`
+	Urgent        bool    `json:"urgent"`
+	Status        string  `json:"status"`
+	Attempts      int     `json:"attempts"`
+	LastError     string  `json:"last_error,omitempty"`
+	NextAttemptAt string  `json:"next_attempt_at"`
+	CreatedAt     string  `json:"created_at"`
+	SentAt        *string `json:"sent_at,omitempty"`
+}
+
 // SetupAPI registers API routes with Basic Auth
 func (b *Bot) SetupAPI() {
 	if b.cfg.APIUsername == "" || b.cfg.APIPassword == "" {
@@ -138,6 +202,12 @@ func (b *Bot) SetupAPI() {
 	http.HandleFunc("/api/todoist/cleanup-wrong-tasks", b.basicAuth(b.apiTodoistCleanupWrongTasks))
 
 	// Debug/Admin endpoints
+	// Search
+	http.HandleFunc("/api/search", b.basicAuth(b.apiSearch))
+
+	// Notifications outbox and delivery log
+	http.HandleFunc("/api/notifications", b.basicAuth(b.apiNotifications))
+
 	http.HandleFunc("/api/users", b.basicAuth(b.apiUsers))
 	http.HandleFunc("/api/debug/tasks", b.basicAuth(b.apiDebugTasks))
 }
@@ -195,6 +265,7 @@ func (b *Bot) apiTasks(w http.ResponseWriter, r *http.Request) {
 			Priority string `json:"priority"`
 			DueDate  string `json:"due_date"`
 			PersonID *int64 `json:"person_id"`
+			ParentID *int64 `json:"parent_id"`
 		}
 		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
 			b.jsonError(w, "Invalid JSON", http.StatusBadRequest)
@@ -206,19 +277,37 @@ func (b *Bot) apiTasks(w http.ResponseWriter, r *http.Request) {
 			return
 		}
 
+		if req.ParentID != nil {
+			task, err := b.taskService.CreateSubtask(*req.ParentID, userID, chatID, req.Title)
+			if err != nil {
+				b.jsonError(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			personNames, _ := b.personService.GetNamesMap(userID)
+			b.jsonResponse(w, b.taskToResponse(task, personNames))
+			return
+		}
+
 		priority := domain.Priority(req.Priority)
 		if priority == "" {
 			priority = domain.PrioritySomeday
 		}
 
+		// Без due_date дату ищем в названии: «Позвонить маме завтра в 15:00»
 		var dueDate *time.Time
 		if req.DueDate != "" {
-			t, err := time.Parse("2006-01-02", req.DueDate)
+			t, err := parseDueDate(req.DueDate)
 			if err != nil {
-				b.jsonError(w, "Invalid date format (use YYYY-MM-DD)", http.StatusBadRequest)
+				b.jsonError(w, "Invalid date format (use YYYY-MM-DD or text like \"завтра 15:00\")", http.StatusBadRequest)
+				return
+			}
+			dueDate = t
+		} else {
+			req.Title, dueDate = b.taskService.ParseDate(req.Title)
+			if req.Title == "" {
+				b.jsonError(w, "Title is required", http.StatusBadRequest)
 				return
 			}
-			dueDate = &t
 		}
 
 		task, err := b.taskService.CreateFull(userID, chatID, req.Title, priority, req.PersonID, dueDate)
@@ -269,7 +358,7 @@ func (b *Bot) apiTasksShared(w http.ResponseWriter, r *http.Request) {
 
 	userID := b.ownerInternalID()
 
-	tasks, err := b.taskService.ListShared(false)
+	tasks, err := b.taskService.ListShared(userID, false)
 	if err != nil {
 		b.jsonError(w, err.Error(), http.StatusInternalServerError)
 		return
@@ -351,6 +440,27 @@ func (b *Bot) apiTask(w http.ResponseWriter, r *http.Request) {
 			}
 			b.jsonResponse(w, map[string]bool{"shared": false})
 			return
+
+		case "attachments":
+			if r.Method != http.MethodGet {
+				b.jsonError(w, "Method not allowed", http.StatusMethodNotAllowed)
+				return
+			}
+			if err := b.attachmentService.CheckOwner(domain.AttachmentTask, taskID, userID, chatID); err != nil {
+				b.jsonError(w, "Task not found", http.StatusNotFound)
+				return
+			}
+			attachments, err := b.attachmentService.List(domain.AttachmentTask, taskID)
+			if err != nil {
+				b.jsonError(w, err.Error(), http.StatusInternalServerError)
+				return
+			}
+			result := make([]AttachmentResponse, len(attachments))
+			for i, a := range attachments {
+				result[i] = attachmentToResponse(a)
+			}
+			b.jsonResponse(w, result)
+			return
 		}
 	}
 
@@ -366,14 +476,17 @@ func (b *Bot) apiTask(w http.ResponseWriter, r *http.Request) {
 			return
 		}
 		personNames, _ := b.personService.GetNamesMap(userID)
-		b.jsonResponse(w, b.taskToResponse(task, personNames))
+		b.jsonResponse(w, b.taskWithRelations(task, personNames))
 
 	case http.MethodPut:
 		var req struct {
-			Title      *string `json:"title"`
-			Priority   *string `json:"priority"`
-			DueDate    *string `json:"due_date"`
-			RepeatType *string `json:"repeat_type"`
+			Title      *string  `json:"title"`
+			Priority   *string  `json:"priority"`
+			DueDate    *string  `json:"due_date"`
+			RepeatType *string  `json:"repeat_type"`
+			RRule      *string  `json:"rrule"` // RRULE или фраза по-русски, "" — без повторения
+			ParentID   *int64   `json:"parent_id"`  // 0 — вынести на верхний уровень
+			BlockedBy  *[]int64 `json:"blocked_by"` // заменяет весь список блокировок
 		}
 		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
 			b.jsonError(w, "Invalid JSON", http.StatusBadRequest)
@@ -396,21 +509,28 @@ func (b *Bot) apiTask(w http.ResponseWriter, r *http.Request) {
 
 		if req.RepeatType != nil {
 			rt := domain.RepeatType(*req.RepeatType)
-			if err := b.storage.UpdateTaskRepeatType(taskID, rt); err != nil {
+			if err := b.storage.UpdateTaskRecurrence(taskID, rt, "", nil); err != nil {
 				b.jsonError(w, err.Error(), http.StatusInternalServerError)
 				return
 			}
 		}
 
+		if req.RRule != nil {
+			if err := b.taskService.SetRecurrence(taskID, userID, chatID, *req.RRule); err != nil {
+				b.jsonError(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+		}
+
 		if req.DueDate != nil {
 			var dueDate *time.Time
 			if *req.DueDate != "" {
-				t, err := time.Parse("2006-01-02", *req.DueDate)
+				t, err := parseDueDate(*req.DueDate)
 				if err != nil {
 					b.jsonError(w, "Invalid date format", http.StatusBadRequest)
 					return
 				}
-				dueDate = &t
+				dueDate = t
 			}
 			if err := b.taskService.UpdateDueDate(taskID, userID, chatID, dueDate); err != nil {
 				b.jsonError(w, err.Error(), http.StatusInternalServerError)
@@ -418,9 +538,27 @@ func (b *Bot) apiTask(w http.ResponseWriter, r *http.Request) {
 			}
 		}
 
+		if req.ParentID != nil {
+			parentID := req.ParentID
+			if *parentID == 0 {
+				parentID = nil
+			}
+			if err := b.taskService.SetParent(taskID, userID, chatID, parentID); err != nil {
+				b.jsonError(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+		}
+
+		if req.BlockedBy != nil {
+			if err := b.taskService.SetBlockers(taskID, userID, chatID, *req.BlockedBy); err != nil {
+				b.jsonError(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+		}
+
 		task, _ := b.taskService.Get(taskID)
 		personNames, _ := b.personService.GetNamesMap(userID)
-		b.jsonResponse(w, b.taskToResponse(task, personNames))
+		b.jsonResponse(w, b.taskWithRelations(task, personNames))
 
 	case http.MethodDelete:
 		if err := b.taskService.Delete(taskID, userID, chatID); err != nil {
@@ -471,6 +609,7 @@ func (b *Bot) ensurePartnerUser() (*domain.User, error) {
 	if err := b.storage.CreateUser(newUser); err != nil {
 		return nil, fmt.Errorf("create partner user: %w", err)
 	}
+	b.bootstrapHousehold()
 
 	return newUser, nil
 }
@@ -497,7 +636,7 @@ func (b *Bot) apiPartnerTasks(w http.ResponseWriter, r *http.Request) {
 		}
 
 		// Get shared tasks
-		sharedTasks, err := b.taskService.ListShared(false)
+		sharedTasks, err := b.taskService.ListShared(partnerUser.ID, false)
 		if err != nil {
 			b.jsonError(w, err.Error(), http.StatusInternalServerError)
 			return
@@ -558,14 +697,21 @@ func (b *Bot) apiPartnerTasks(w http.ResponseWriter, r *http.Request) {
 			priority = domain.PrioritySomeday
 		}
 
+		// Без due_date дату ищем в названии: «Позвонить маме завтра в 15:00»
 		var dueDate *time.Time
 		if req.DueDate != "" {
-			t, err := time.Parse("2006-01-02", req.DueDate)
+			t, err := parseDueDate(req.DueDate)
 			if err != nil {
-				b.jsonError(w, "Invalid date format (use YYYY-MM-DD)", http.StatusBadRequest)
+				b.jsonError(w, "Invalid date format (use YYYY-MM-DD or text like \"завтра 15:00\")", http.StatusBadRequest)
+				return
+			}
+			dueDate = t
+		} else {
+			req.Title, dueDate = b.taskService.ParseDate(req.Title)
+			if req.Title == "" {
+				b.jsonError(w, "Title is required", http.StatusBadRequest)
 				return
 			}
-			dueDate = &t
 		}
 
 		task, err := b.taskService.CreateFull(userID, chatID, req.Title, priority, req.PersonID, dueDate)
@@ -623,7 +769,7 @@ func (b *Bot) apiPartnerTasksToday(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Get shared urgent tasks too
-	sharedTasks, err := b.taskService.ListShared(false)
+	sharedTasks, err := b.taskService.ListShared(partnerUser.ID, false)
 	if err != nil {
 		b.jsonError(w, err.Error(), http.StatusInternalServerError)
 		return
@@ -652,6 +798,20 @@ func (b *Bot) apiPartnerTasksToday(w http.ResponseWriter, r *http.Request) {
 	b.jsonResponse(w, b.tasksToResponse(tasks, personNames))
 }
 
+// parseDueDate accepts YYYY-MM-DD, RFC 3339 or text like "завтра 15:00", "next friday"
+func parseDueDate(s string) (*time.Time, error) {
+	if t, err := time.Parse("2006-01-02", s); err == nil {
+		return &t, nil
+	}
+	if t, err := time.Parse(time.RFC3339, s); err == nil {
+		return &t, nil
+	}
+	if r := dates.Parse(s, time.Now()); r != nil {
+		return &r.Time, nil
+	}
+	return nil, fmt.Errorf("invalid date: %s", s)
+}
+
 // isToday checks if date is today
 func isToday(t time.Time) bool {
 	now := time.Now()
@@ -727,14 +887,16 @@ func (b *Bot) apiPartnerTask(w http.ResponseWriter, r *http.Request) {
 	switch r.Method {
 	case http.MethodGet:
 		personNames, _ := b.personService.GetNamesMap(b.cfg.OwnerTelegramID)
-		b.jsonResponse(w, b.taskToResponse(task, personNames))
+		b.jsonResponse(w, b.taskWithRelations(task, personNames))
 
 	case http.MethodPut:
 		// Can update own tasks and shared tasks
 		var req struct {
-			Title    *string `json:"title"`
-			Priority *string `json:"priority"`
-			DueDate  *string `json:"due_date"`
+			Title     *string  `json:"title"`
+			Priority  *string  `json:"priority"`
+			DueDate   *string  `json:"due_date"`
+			ParentID  *int64   `json:"parent_id"`  // 0 — вынести на верхний уровень
+			BlockedBy *[]int64 `json:"blocked_by"` // заменяет весь список блокировок
 		}
 		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
 			b.jsonError(w, "Invalid JSON", http.StatusBadRequest)
@@ -767,12 +929,12 @@ func (b *Bot) apiPartnerTask(w http.ResponseWriter, r *http.Request) {
 		if req.DueDate != nil {
 			var dueDate *time.Time
 			if *req.DueDate != "" {
-				t, err := time.Parse("2006-01-02", *req.DueDate)
+				t, err := parseDueDate(*req.DueDate)
 				if err != nil {
 					b.jsonError(w, "Invalid date format", http.StatusBadRequest)
 					return
 				}
-				dueDate = &t
+				dueDate = t
 			}
 			if err := b.taskService.UpdateDueDate(taskID, userID, chatID, dueDate); err != nil {
 				if isSharedTask && !isOwnTask {
@@ -784,9 +946,33 @@ func (b *Bot) apiPartnerTask(w http.ResponseWriter, r *http.Request) {
 			}
 		}
 
+		// Связи меняем в контексте владельца задачи, если она общая
+		relUserID, relChatID := userID, chatID
+		if isSharedTask && !isOwnTask {
+			relUserID, relChatID = task.UserID, task.ChatID
+		}
+
+		if req.ParentID != nil {
+			parentID := req.ParentID
+			if *parentID == 0 {
+				parentID = nil
+			}
+			if err := b.taskService.SetParent(taskID, relUserID, relChatID, parentID); err != nil {
+				b.jsonError(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+		}
+
+		if req.BlockedBy != nil {
+			if err := b.taskService.SetBlockers(taskID, relUserID, relChatID, *req.BlockedBy); err != nil {
+				b.jsonError(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+		}
+
 		updatedTask, _ := b.taskService.Get(taskID)
 		personNames, _ := b.personService.GetNamesMap(b.cfg.OwnerTelegramID)
-		b.jsonResponse(w, b.taskToResponse(updatedTask, personNames))
+		b.jsonResponse(w, b.taskWithRelations(updatedTask, personNames))
 
 	case http.MethodDelete:
 		// Can only delete OWN tasks, not owner's shared tasks
@@ -889,6 +1075,94 @@ func (b *Bot) apiBirthdays(w http.ResponseWriter, r *http.Request) {
 	b.jsonResponse(w, b.personsToResponse(persons))
 }
 
+// GET /api/search?q=query - full-text search across tasks, people, checklists and events
+func (b *Bot) apiSearch(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		b.jsonError(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	query := strings.TrimSpace(r.URL.Query().Get("q"))
+	if query == "" {
+		b.jsonError(w, "Query parameter q is required", http.StatusBadRequest)
+		return
+	}
+
+	results, err := b.searchService.Search(b.ownerInternalID(), query)
+	if err != nil {
+		b.jsonError(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	resp := make([]SearchResultResponse, 0, len(results))
+	for _, res := range results {
+		resp = append(resp, SearchResultResponse{
+			Kind:  string(res.Kind),
+			ID:    res.RefID,
+			Title: res.Title,
+			Body:  res.Body,
+			Rank:  res.Rank,
+		})
+	}
+	b.jsonResponse(w, resp)
+}
+
+// GET /api/notifications?status=pending|sent|failed&limit=100 - outbox and delivery log, newest first
+func (b *Bot) apiNotifications(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		b.jsonError(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	status := domain.NotificationStatus(r.URL.Query().Get("status"))
+	switch status {
+	case "", domain.NotificationPending, domain.NotificationSent, domain.NotificationFailed:
+	default:
+		b.jsonError(w, "status must be pending, sent or failed", http.StatusBadRequest)
+		return
+	}
+
+	limit := 100
+	if v := r.URL.Query().Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n <= 0 || n > 1000 {
+			b.jsonError(w, "limit must be between 1 and 1000", http.StatusBadRequest)
+			return
+		}
+		limit = n
+	}
+
+	notifications, err := b.storage.ListNotifications(status, limit)
+	if err != nil {
+		b.jsonError(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	resp := make([]NotificationResponse, 0, len(notifications))
+	for _, n := range notifications {
+		item := NotificationResponse{
+			ID:            n.ID,
+			Key:           n.Key,
+			ChatID:        n.ChatID,
+			UserID:        n.UserID,
+			TaskID:        n.TaskID,
+			Text:          n.Text,
+			Urgent:        n.Urgent,
+			Status:        string(n.Status),
+			Attempts:      n.Attempts,
+			LastError:     n.LastError,
+			NextAttemptAt: n.NextAttemptAt.Format(time.RFC3339),
+			CreatedAt:     n.CreatedAt.Format(time.RFC3339),
+		}
+		if n.SentAt != nil {
+			sentAt := n.SentAt.Format(time.RFC3339)
+			item.SentAt = &sentAt
+		}
+		resp = append(resp, item)
+	}
+	b.jsonResponse(w, resp)
+}
+
 // GET /api/reminders - list reminders
 func (b *Bot) apiReminders(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodGet {
@@ -1376,6 +1650,19 @@ func (b *Bot) apiCalendarEventDelete(w http.ResponseWriter, r *http.Request) {
 	}
 
 	if err := b.calendarService.DeleteEvent(eventID, user.ID); err != nil {
+		// Событие изменили в календаре — владелец выбирает версию в Telegram
+		var conflictErr *service.ConflictError
+		if errors.As(err, &conflictErr) {
+			b.notifyCalendarConflicts([]*domain.CalendarConflict{conflictErr.Conflict})
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusConflict)
+			json.NewEncoder(w).Encode(APIResponse{
+				Success: false,
+				Error:   err.Error(),
+				Data:    map[string]interface{}{"conflict_id": conflictErr.Conflict.ID},
+			})
+			return
+		}
 		b.jsonError(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
@@ -1386,7 +1673,7 @@ func (b *Bot) apiCalendarEventDelete(w http.ResponseWriter, r *http.Request) {
 	})
 }
 
-// GET /api/calendar/list - list available Apple calendars
+// GET /api/calendar/list - list the owner's linked calendars
 func (b *Bot) apiCalendarList(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodGet {
 		b.jsonError(w, "Method not allowed", http.StatusMethodNotAllowed)
@@ -1398,31 +1685,45 @@ func (b *Bot) apiCalendarList(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	calendars, err := b.calendarService.DiscoverCalendars()
+	user, _ := b.storage.GetUserByTelegramID(b.cfg.OwnerTelegramID)
+	if user == nil {
+		b.jsonError(w, "User not found", http.StatusNotFound)
+		return
+	}
+
+	calendars, err := b.calendarService.Calendars(user.ID)
 	if err != nil {
 		b.jsonError(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
 
 	type CalendarItem struct {
-		ID   string `json:"id"`
-		Name string `json:"name"`
-		URL  string `json:"url"`
+		ID        int64  `json:"id"`
+		AccountID int64  `json:"account_id"`
+		Name      string `json:"name"`
+		Path      string `json:"path"`
+		Color     string `json:"color"`
+		Mode      string `json:"mode"`
+		IsShared  bool   `json:"is_shared"`
 	}
 
-	var result []CalendarItem
+	result := make([]CalendarItem, 0, len(calendars))
 	for _, c := range calendars {
 		result = append(result, CalendarItem{
-			ID:   c.ID,
-			Name: c.DisplayName,
-			URL:  c.URL,
+			ID:        c.ID,
+			AccountID: c.AccountID,
+			Name:      c.Name,
+			Path:      c.Path,
+			Color:     string(c.Color),
+			Mode:      string(c.Mode),
+			IsShared:  c.IsShared,
 		})
 	}
 
 	b.jsonResponse(w, result)
 }
 
-// POST /api/calendar/sync - sync with Apple Calendar
+// POST /api/calendar/sync - sync the linked calendars
 func (b *Bot) apiCalendarSync(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodPost {
 		b.jsonError(w, "Method not allowed", http.StatusMethodNotAllowed)
@@ -1434,13 +1735,15 @@ func (b *Bot) apiCalendarSync(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Sync calendar events FROM Apple
-	result, err := b.calendarService.SyncFromApple()
+	// Sync calendar events FROM the calendars
+	result, err := b.calendarService.SyncAll()
 	if err != nil {
 		b.jsonError(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
 
+	b.notifyCalendarConflicts(result.Conflicts)
+
 	// Sync weekly schedule events TO Apple
 	scheduleSynced := 0
 	if b.scheduleService != nil {
@@ -1465,9 +1768,10 @@ func (b *Bot) apiCalendarSync(w http.ResponseWriter, r *http.Request) {
 
 	b.jsonResponse(w, map[string]interface{}{
 		"from_apple": map[string]interface{}{
-			"added":   result.Added,
-			"updated": result.Updated,
-			"deleted": result.Deleted,
+			"added":     result.Added,
+			"updated":   result.Updated,
+			"deleted":   result.Deleted,
+			"conflicts": len(result.Conflicts),
 		},
 		"to_apple": map[string]interface{}{
 			"schedule_synced": scheduleSynced,
@@ -1530,6 +1834,8 @@ func (b *Bot) taskToResponse(t *domain.Task, personNames map[int64]string) TaskR
 		IsShared:  t.IsShared,
 		IsRepeat:  t.IsRepeating(),
 		CreatedAt: t.CreatedAt.Format(time.RFC3339),
+		RRule:     t.RecurrenceRule(),
+		ParentID:  t.ParentID,
 	}
 	if t.DueDate != nil {
 		d := t.DueDate.Format("2006-01-02")
@@ -1543,6 +1849,22 @@ func (b *Bot) taskToResponse(t *domain.Task, personNames map[int64]string) TaskR
 	return tr
 }
 
+// Helper: task response with subtasks and blockers (for single task endpoints)
+func (b *Bot) taskWithRelations(t *domain.Task, personNames map[int64]string) TaskResponse {
+	tr := b.taskToResponse(t, personNames)
+	if subtasks, _ := b.taskService.Subtasks(t.ID); len(subtasks) > 0 {
+		for _, st := range subtasks {
+			tr.Subtasks = append(tr.Subtasks, st.ID)
+		}
+	}
+	if blockers, _ := b.taskService.Blockers(t.ID); len(blockers) > 0 {
+		for _, bt := range blockers {
+			tr.BlockedBy = append(tr.BlockedBy, bt.ID)
+		}
+	}
+	return tr
+}
+
 // Helper: convert persons to API response
 func (b *Bot) personsToResponse(persons []*domain.Person) []PersonResponse {
 	result := make([]PersonResponse, 0, len(persons))
@@ -1556,7 +1878,7 @@ func (b *Bot) personsToResponse(persons []*domain.Person) []PersonResponse {
 			d := p.Birthday.Format("2006-01-02")
 			pr.Birthday = &d
 			if p.Birthday.Year() > 1 {
-				age := p.Age()
+				age := p.Age(time.Now())
 				pr.Age = &age
 			}
 		}
@@ -1921,8 +2243,9 @@ func (b *Bot) apiChecklists(w http.ResponseWriter, r *http.Request) {
 }
 
 // GET /api/checklist/:id - get checklist
+// GET /api/checklist/:id/runs - latest runs
 // DELETE /api/checklist/:id - delete checklist
-// PUT /api/checklist/:id/check/:index - check item
+// PUT /api/checklist/:id/check/:index - check item in the current run
 func (b *Bot) apiChecklist(w http.ResponseWriter, r *http.Request) {
 	user, _ := b.storage.GetUserByTelegramID(b.cfg.OwnerTelegramID)
 	var userID int64
@@ -1957,6 +2280,21 @@ func (b *Bot) apiChecklist(w http.ResponseWriter, r *http.Request) {
 			b.jsonError(w, "Checklist not found", http.StatusNotFound)
 			return
 		}
+
+		if len(parts) >= 2 && parts[1] == "runs" {
+			runs, err := b.checklistService.History(checklistID, userID)
+			if err != nil {
+				b.jsonError(w, err.Error(), http.StatusInternalServerError)
+				return
+			}
+			result := make([]ChecklistRunResponse, len(runs))
+			for i, run := range runs {
+				result[i] = checklistRunToResponse(run)
+			}
+			b.jsonResponse(w, result)
+			return
+		}
+
 		b.jsonResponse(w, b.checklistToResponse(checklist))
 
 	case http.MethodDelete:
@@ -1987,14 +2325,23 @@ func (b *Bot) apiChecklist(w http.ResponseWriter, r *http.Request) {
 				return
 			}
 
-			if !checklist.CheckItem(index) {
+			run, err := b.checklistService.CurrentRun(checklistID, userID)
+			if err != nil {
+				b.jsonError(w, err.Error(), http.StatusInternalServerError)
+				return
+			}
+			if index < 0 || index >= len(run.Items) {
 				b.jsonError(w, "Invalid item index", http.StatusBadRequest)
 				return
 			}
 
-			if err := b.storage.UpdateChecklistItems(checklistID, checklist.Items); err != nil {
-				b.jsonError(w, err.Error(), http.StatusInternalServerError)
-				return
+			if !run.Items[index].Checked {
+				run, err = b.checklistService.ToggleRunItem(run.ID, userID, index)
+				if err != nil {
+					b.jsonError(w, err.Error(), http.StatusInternalServerError)
+					return
+				}
+				b.refreshChecklistRun(run, 0, 0)
 			}
 
 			b.jsonResponse(w, b.checklistToResponse(checklist))
@@ -2091,21 +2438,68 @@ func (b *Bot) apiReminder(w http.ResponseWriter, r *http.Request) {
 }
 
 func (b *Bot) checklistToResponse(c *domain.Checklist) ChecklistResponse {
-	items := make([]ChecklistItemResponse, len(c.Items))
-	for i, item := range c.Items {
-		items[i] = ChecklistItemResponse{
-			Text:    item.Text,
-			Checked: item.Checked,
-		}
-	}
+	run, _ := b.checklistService.OpenRun(c.ID)
 
-	return ChecklistResponse{
+	resp := ChecklistResponse{
 		ID:        c.ID,
 		Title:     c.Title,
-		Items:     items,
+		Items:     make([]ChecklistItemResponse, len(c.Items)),
 		PersonID:  c.PersonID,
 		CreatedAt: c.CreatedAt.Format(time.RFC3339),
 	}
+	if run != nil {
+		resp.RunID = &run.ID
+		resp.Items = make([]ChecklistItemResponse, len(run.Items))
+		for i, item := range run.Items {
+			resp.Items[i] = ChecklistItemResponse{Text: item.Text, Checked: item.Checked}
+		}
+		return resp
+	}
+
+	for i, item := range c.Items {
+		resp.Items[i] = ChecklistItemResponse{Text: item.Text}
+	}
+	return resp
+}
+
+func checklistRunToResponse(r *domain.ChecklistRun) ChecklistRunResponse {
+	resp := ChecklistRunResponse{
+		ID:            r.ID,
+		ChecklistID:   r.ChecklistID,
+		UserID:        r.UserID,
+		WeeklyEventID: r.WeeklyEventID,
+		Items:         make([]ChecklistRunItemResponse, len(r.Items)),
+		IsShared:      r.IsShared,
+		StartedAt:     r.StartedAt.Format(time.RFC3339),
+	}
+	for i, item := range r.Items {
+		resp.Items[i] = ChecklistRunItemResponse{Text: item.Text, Checked: item.Checked, CheckedBy: item.CheckedBy}
+		if item.CheckedAt != nil {
+			at := item.CheckedAt.Format(time.RFC3339)
+			resp.Items[i].CheckedAt = &at
+		}
+	}
+	if r.CompletedAt != nil {
+		at := r.CompletedAt.Format(time.RFC3339)
+		resp.CompletedAt = &at
+	}
+	return resp
+}
+
+func attachmentToResponse(a *domain.Attachment) AttachmentResponse {
+	return AttachmentResponse{
+		ID:        a.ID,
+		OwnerType: string(a.OwnerType),
+		OwnerID:   a.OwnerID,
+		Kind:      string(a.Kind),
+		FileID:    a.FileID,
+		FileName:  a.FileName,
+		MimeType:  a.MimeType,
+		FileSize:  a.FileSize,
+		Caption:   a.Caption,
+		LocalCopy: a.LocalPath != "",
+		CreatedAt: a.CreatedAt.Format(time.RFC3339),
+	}
 }
 
 func (b *Bot) checklistsToResponse(checklists []*domain.Checklist) []ChecklistResponse {
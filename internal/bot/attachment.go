@@ -0,0 +1,220 @@
+package bot
+
+import (
+	"context"
+	"fmt"
+	"html"
+	"io"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"github.com/tazhate/familybot/internal/domain"
+)
+
+// Вложения: фото или документ, отправленный ответом на карточку задачи,
+// человека или машины, прикрепляется к ней. Карточку узнаём по кнопке
+// «📎 Прикрепить» (att_add:<owner_type>:<owner_id>) в сообщении, на которое ответили.
+
+const (
+	// getFile в Bot API отдаёт файлы до 20 МБ; бо́льшие хранятся только в Telegram
+	attachmentMaxDownload = 20 << 20
+	attachmentTimeout     = time.Minute
+)
+
+// attachButton is the button that marks a card as a target for attachments
+func attachButton(ownerType domain.AttachmentOwner, ownerID int64) tgbotapi.InlineKeyboardButton {
+	return tgbotapi.NewInlineKeyboardButtonData("📎 Прикрепить", fmt.Sprintf("att_add:%s:%d", ownerType, ownerID))
+}
+
+// attachmentRows returns a button per attachment to send it again
+func attachmentRows(attachments []*domain.Attachment) [][]tgbotapi.InlineKeyboardButton {
+	var rows [][]tgbotapi.InlineKeyboardButton
+	for _, a := range attachments {
+		rows = append(rows, tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData(truncate(a.Emoji()+" "+a.DisplayName(), 40), fmt.Sprintf("att_send:%d", a.ID)),
+		))
+	}
+	return rows
+}
+
+// attachments returns the attachments of the card and their text block
+func (b *Bot) attachments(ownerType domain.AttachmentOwner, ownerID int64) ([]*domain.Attachment, string) {
+	list, err := b.attachmentService.List(ownerType, ownerID)
+	if err != nil {
+		log.Printf("attachments %s %d: %v", ownerType, ownerID, err)
+		return nil, ""
+	}
+	return list, b.attachmentService.FormatList(list)
+}
+
+// attachmentTarget finds the card the message replies to
+func attachmentTarget(reply *tgbotapi.Message) (domain.AttachmentOwner, int64, bool) {
+	if reply == nil || reply.ReplyMarkup == nil {
+		return "", 0, false
+	}
+	for _, row := range reply.ReplyMarkup.InlineKeyboard {
+		for _, btn := range row {
+			if btn.CallbackData == nil {
+				continue
+			}
+			parts := strings.Split(*btn.CallbackData, ":")
+			if len(parts) != 3 || parts[0] != "att_add" {
+				continue
+			}
+			id, err := strconv.ParseInt(parts[2], 10, 64)
+			if err != nil {
+				continue
+			}
+			return domain.AttachmentOwner(parts[1]), id, true
+		}
+	}
+	return "", 0, false
+}
+
+// attachmentFromMessage takes the photo (largest size) or document from the message
+func attachmentFromMessage(msg *tgbotapi.Message) *domain.Attachment {
+	a := &domain.Attachment{Caption: strings.TrimSpace(msg.Caption)}
+	switch {
+	case len(msg.Photo) > 0:
+		photo := msg.Photo[len(msg.Photo)-1]
+		a.Kind = domain.AttachmentPhoto
+		a.FileID = photo.FileID
+		a.FileUniqueID = photo.FileUniqueID
+		a.FileSize = int64(photo.FileSize)
+		a.MimeType = "image/jpeg"
+	case msg.Document != nil:
+		a.Kind = domain.AttachmentDocument
+		a.FileID = msg.Document.FileID
+		a.FileUniqueID = msg.Document.FileUniqueID
+		a.FileName = msg.Document.FileName
+		a.MimeType = msg.Document.MimeType
+		a.FileSize = int64(msg.Document.FileSize)
+	default:
+		return nil
+	}
+	return a
+}
+
+func (b *Bot) handleAttachment(msg *tgbotapi.Message, user *domain.User) {
+	chatID := msg.Chat.ID
+	if user == nil || !b.householdRole(user).CanWrite() {
+		return
+	}
+
+	ownerType, ownerID, ok := attachmentTarget(msg.ReplyToMessage)
+	if !ok {
+		b.SendMessage(chatID, "📎 Чтобы прикрепить файл, ответь им на карточку задачи, человека или машины — на сообщение с кнопкой «📎 Прикрепить»")
+		return
+	}
+	if err := b.attachmentService.CheckOwner(ownerType, ownerID, user.ID, chatID); err != nil {
+		b.SendMessage(chatID, "❌ "+err.Error())
+		return
+	}
+
+	a := attachmentFromMessage(msg)
+	a.UserID = user.ID
+	a.OwnerType = ownerType
+	a.OwnerID = ownerID
+
+	// Копия на диск: если скачать не вышло, файл всё равно остаётся в Telegram
+	var content io.Reader
+	if b.attachmentService.MirrorEnabled() && a.FileSize <= attachmentMaxDownload {
+		ctx, cancel := context.WithTimeout(context.Background(), attachmentTimeout)
+		defer cancel()
+		body, err := b.downloadFile(ctx, a.FileID)
+		if err != nil {
+			log.Printf("handleAttachment: download %s: %v", a.FileUniqueID, err)
+		} else {
+			defer body.Close()
+			content = body
+		}
+	}
+
+	if err := b.attachmentService.Attach(a, content); err != nil {
+		log.Printf("handleAttachment: error: %v", err)
+		b.SendMessage(chatID, "❌ Ошибка: "+err.Error())
+		return
+	}
+	log.Printf("handleAttachment: attachment %d added to %s %d by user %d", a.ID, ownerType, ownerID, user.ID)
+
+	text, viewData := b.attachmentOwnerLabel(ownerType, ownerID)
+	kb := tgbotapi.NewInlineKeyboardMarkup(tgbotapi.NewInlineKeyboardRow(
+		tgbotapi.NewInlineKeyboardButtonData("👁 Открыть", viewData),
+	))
+	b.SendMessageWithKeyboard(chatID, fmt.Sprintf("📎 %s прикреплено к %s", html.EscapeString(a.DisplayName()), text), kb)
+}
+
+// attachmentOwnerLabel returns the name of the card for messages and the callback to open it
+func (b *Bot) attachmentOwnerLabel(ownerType domain.AttachmentOwner, ownerID int64) (string, string) {
+	switch ownerType {
+	case domain.AttachmentTask:
+		label := fmt.Sprintf("задаче <b>#%d</b>", ownerID)
+		if task, _ := b.storage.GetTask(ownerID); task != nil {
+			label += " " + html.EscapeString(task.Title)
+		}
+		return label, fmt.Sprintf("view:%d", ownerID)
+	case domain.AttachmentPerson:
+		label := "человеку"
+		if p, _ := b.storage.GetPerson(ownerID); p != nil {
+			label += " <b>" + html.EscapeString(p.Name) + "</b>"
+		}
+		return label, fmt.Sprintf("person:%d", ownerID)
+	default:
+		label := "машине"
+		if a, _ := b.storage.GetAuto(ownerID); a != nil {
+			label += " <b>" + html.EscapeString(a.Name) + "</b>"
+		}
+		return label, fmt.Sprintf("auto:%d", ownerID)
+	}
+}
+
+// sendAttachment sends the file again; if Telegram no longer has it, the local copy is used
+func (b *Bot) sendAttachment(chatID int64, a *domain.Attachment) error {
+	send := func(file tgbotapi.RequestFileData) error {
+		var msg tgbotapi.Chattable
+		if a.Kind == domain.AttachmentPhoto {
+			photo := tgbotapi.NewPhoto(chatID, file)
+			photo.Caption = a.Caption
+			msg = photo
+		} else {
+			doc := tgbotapi.NewDocument(chatID, file)
+			doc.Caption = a.Caption
+			msg = doc
+		}
+		_, err := b.api.Send(msg)
+		return err
+	}
+
+	err := send(tgbotapi.FileID(a.FileID))
+	if err != nil && a.LocalPath != "" {
+		log.Printf("sendAttachment %d: file_id failed (%v), sending local copy", a.ID, err)
+		err = send(tgbotapi.FilePath(a.LocalPath))
+	}
+	return err
+}
+
+// downloadFile opens a file stored in Telegram
+func (b *Bot) downloadFile(ctx context.Context, fileID string) (io.ReadCloser, error) {
+	url, err := b.api.GetFileDirectURL(fileID)
+	if err != nil {
+		return nil, fmt.Errorf("get file: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := b.api.Client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("download: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		resp.Body.Close()
+		return nil, fmt.Errorf("download: status %d", resp.StatusCode)
+	}
+	return resp.Body, nil
+}
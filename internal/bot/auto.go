@@ -0,0 +1,245 @@
+package bot
+
+import (
+	"fmt"
+	"html"
+	"log"
+	"strconv"
+	"strings"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"github.com/tazhate/familybot/internal/domain"
+	"github.com/tazhate/familybot/internal/service"
+)
+
+// Журнал машины: /auto — карточка с историей, /odo — пробег, /service — сервисная
+// книжка, /rule — регламент ТО, /autodoc — документы со сроком.
+// Машину указывают по ID или названию; если машина одна, её можно не называть.
+
+// autoCardKeyboard links a reply to the card of the auto
+func autoCardKeyboard(autoID int64) tgbotapi.InlineKeyboardMarkup {
+	return tgbotapi.NewInlineKeyboardMarkup(
+		tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData("🚗 Карточка", fmt.Sprintf("auto:%d", autoID)),
+			tgbotapi.NewInlineKeyboardButtonData("🚗 Все машины", "menu:autos"),
+		),
+	)
+}
+
+func (b *Bot) cmdAuto(chatID int64, user *domain.User, args string) {
+	if user == nil {
+		b.SendMessage(chatID, "Сначала /start")
+		return
+	}
+	if args == "" {
+		b.cmdAutos(chatID, user)
+		return
+	}
+
+	auto, _, err := b.autoService.ResolveAuto(user.ID, args)
+	if err != nil {
+		b.SendMessage(chatID, "❌ "+err.Error())
+		return
+	}
+
+	attachments, attachmentsText := b.attachments(domain.AttachmentAuto, auto.ID)
+	b.SendMessageWithKeyboard(chatID, b.autoService.FormatAuto(auto)+attachmentsText, autoKeyboard(auto, attachments))
+}
+
+func (b *Bot) cmdOdo(chatID int64, user *domain.User, args string) {
+	if user == nil {
+		b.SendMessage(chatID, "Сначала /start")
+		return
+	}
+	if args == "" {
+		b.SendMessage(chatID, `<b>Записать пробег:</b>
+
+/odo [машина] км
+
+<b>Примеры:</b>
+/odo Ford 85000
+/odo 2 120 500`)
+		return
+	}
+
+	auto, rest, err := b.autoService.ResolveAuto(user.ID, args)
+	if err != nil {
+		b.SendMessage(chatID, "❌ "+err.Error())
+		return
+	}
+	km, err := parseKm(rest)
+	if err != nil {
+		b.SendMessage(chatID, "❌ "+err.Error())
+		return
+	}
+	if err := b.autoService.RecordMileage(auto.ID, user.ID, km); err != nil {
+		b.SendMessage(chatID, "❌ "+err.Error())
+		return
+	}
+	log.Printf("cmdOdo: auto %d mileage %d", auto.ID, km)
+
+	text := fmt.Sprintf("✅ 🚗 %s: пробег <b>%s км</b>", html.EscapeString(auto.Name), formatMoney(float64(km)))
+	b.SendMessageWithKeyboard(chatID, text, autoCardKeyboard(auto.ID))
+}
+
+func (b *Bot) cmdService(chatID int64, user *domain.User, args string) {
+	if user == nil {
+		b.SendMessage(chatID, "Сначала /start")
+		return
+	}
+	if args == "" {
+		b.SendMessage(chatID, `<b>Записать обслуживание:</b>
+
+/service [машина] что сделали [пробег км] [сумма ₽] [; заметка]
+
+<b>Примеры:</b>
+/service Ford замена масла 85000км 4500₽ ; Castrol 5W-30
+/service Lexus шиномонтаж 3200₽`)
+		return
+	}
+
+	auto, rest, err := b.autoService.ResolveAuto(user.ID, args)
+	if err != nil {
+		b.SendMessage(chatID, "❌ "+err.Error())
+		return
+	}
+	record, rule, err := b.autoService.AddService(auto.ID, user.ID, rest)
+	if err != nil {
+		b.SendMessage(chatID, "❌ "+err.Error())
+		return
+	}
+	log.Printf("cmdService: auto %d service record %d", auto.ID, record.ID)
+
+	text := fmt.Sprintf("✅ 🚗 %s: %s", html.EscapeString(auto.Name), html.EscapeString(record.Title))
+	if record.Km > 0 {
+		text += fmt.Sprintf(" · %s км", formatMoney(float64(record.Km)))
+	}
+	if record.Cost > 0 {
+		text += fmt.Sprintf(" · %s ₽", formatMoney(record.Cost))
+	}
+	if rule != nil {
+		text += fmt.Sprintf("\n\n🔧 Регламент «%s» — %s", html.EscapeString(rule.Title), b.autoService.FormatRule(rule))
+	}
+	b.SendMessageWithKeyboard(chatID, text, autoCardKeyboard(auto.ID))
+}
+
+func (b *Bot) cmdRule(chatID int64, user *domain.User, args string) {
+	if user == nil {
+		b.SendMessage(chatID, "Сначала /start")
+		return
+	}
+	if args == "" {
+		b.SendMessage(chatID, `<b>Регламент ТО:</b>
+
+/rule [машина] работа каждые N км или M мес
+/rule del ID — удалить
+
+<b>Примеры:</b>
+/rule Ford масло каждые 10000 км или 12 мес
+/rule Lexus тормозная жидкость каждые 2 года
+
+Запись /service с названием работы («замена масла») начинает отсчёт заново.`)
+		return
+	}
+
+	if idStr, ok := strings.CutPrefix(args, "del "); ok {
+		id, err := strconv.ParseInt(strings.TrimSpace(idStr), 10, 64)
+		if err != nil {
+			b.SendMessage(chatID, "Неверный ID")
+			return
+		}
+		if err := b.autoService.DeleteRule(id, user.ID); err != nil {
+			b.SendMessage(chatID, "❌ "+err.Error())
+			return
+		}
+		b.SendMessage(chatID, fmt.Sprintf("🗑 Регламент #%d удалён", id))
+		return
+	}
+
+	auto, rest, err := b.autoService.ResolveAuto(user.ID, args)
+	if err != nil {
+		b.SendMessage(chatID, "❌ "+err.Error())
+		return
+	}
+	rule, err := b.autoService.AddRule(auto.ID, rest)
+	if err != nil {
+		b.SendMessage(chatID, "❌ "+err.Error())
+		return
+	}
+	log.Printf("cmdRule: auto %d rule %d", auto.ID, rule.ID)
+
+	text := fmt.Sprintf("✅ 🚗 %s: регламент <b>#%d</b> %s — %s",
+		html.EscapeString(auto.Name), rule.ID, html.EscapeString(rule.Title), b.autoService.FormatRule(rule))
+	b.SendMessageWithKeyboard(chatID, text, autoCardKeyboard(auto.ID))
+}
+
+func (b *Bot) cmdAutoDoc(chatID int64, user *domain.User, args string) {
+	if user == nil {
+		b.SendMessage(chatID, "Сначала /start")
+		return
+	}
+	if args == "" {
+		docs, err := b.autoService.ListDocuments(user.ID)
+		if err != nil {
+			log.Printf("cmdAutoDoc: error: %v", err)
+			b.SendMessage(chatID, "❌ Ошибка: "+err.Error())
+			return
+		}
+		text := "<b>📄 Документы</b>\n\n" + b.autoService.FormatDocuments(docs) + `
+
+/autodoc [машина] вид ДД.ММ.ГГГГ — добавить или продлить
+  <i>вид: техосмотр, осаго, каско, права — или своё название</i>
+/autodoc del ID — удалить
+
+<b>Примеры:</b>
+/autodoc Ford осаго 15.06.2027
+/autodoc права 01.02.2030`
+		b.SendMessage(chatID, text)
+		return
+	}
+
+	if idStr, ok := strings.CutPrefix(args, "del "); ok {
+		id, err := strconv.ParseInt(strings.TrimSpace(idStr), 10, 64)
+		if err != nil {
+			b.SendMessage(chatID, "Неверный ID")
+			return
+		}
+		if err := b.autoService.DeleteDocument(id, user.ID); err != nil {
+			b.SendMessage(chatID, "❌ "+err.Error())
+			return
+		}
+		b.SendMessage(chatID, fmt.Sprintf("🗑 Документ #%d удалён", id))
+		return
+	}
+
+	autoID, kind, title, expiresAt, err := b.autoService.ParseDocumentArgs(user.ID, args)
+	if err != nil {
+		b.SendMessage(chatID, "❌ "+err.Error())
+		return
+	}
+	doc, renewed, err := b.autoService.SetDocument(user.ID, autoID, kind, title, expiresAt)
+	if err != nil {
+		log.Printf("cmdAutoDoc: error: %v", err)
+		b.SendMessage(chatID, "❌ Ошибка: "+err.Error())
+		return
+	}
+
+	action := "добавлен"
+	if renewed {
+		action = "продлён"
+	}
+	text := fmt.Sprintf("✅ %s <b>#%d</b> %s %s: %s", doc.Emoji(), doc.ID, html.EscapeString(doc.Title), action, b.autoService.FormatExpiry(doc.ExpiresAt))
+	if doc.AutoID == nil {
+		b.SendMessage(chatID, text)
+		return
+	}
+	b.SendMessageWithKeyboard(chatID, text, autoCardKeyboard(*doc.AutoID))
+}
+
+// parseKm parses the mileage argument of /odo
+func parseKm(s string) (int, error) {
+	if strings.TrimSpace(s) == "" {
+		return 0, fmt.Errorf("укажи пробег: /odo Ford 85000")
+	}
+	return service.ParseKm(s)
+}
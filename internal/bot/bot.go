@@ -10,31 +10,39 @@ import (
 	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
 	"github.com/tazhate/familybot/config"
 	"github.com/tazhate/familybot/internal/clients/debtmanager"
+	"github.com/tazhate/familybot/internal/clock"
 	"github.com/tazhate/familybot/internal/service"
+	"github.com/tazhate/familybot/internal/speech"
 	"github.com/tazhate/familybot/internal/storage"
 )
 
 type Bot struct {
-	api              *tgbotapi.BotAPI
-	cfg              *config.Config
-	storage          *storage.Storage
-	taskService      *service.TaskService
-	reminderService  *service.ReminderService
-	personService    *service.PersonService
-	scheduleService  *service.ScheduleService
-	autoService      *service.AutoService
-	checklistService *service.ChecklistService
-	calendarService  *service.CalendarService
-	todoistService   *service.TodoistService
-	debtClient       *debtmanager.Client
-	server           *http.Server
-
-	// Pending task text by chatID (for priority selection)
-	pendingTasks   map[int64]string
-	pendingTasksMu sync.RWMutex
+	api               *tgbotapi.BotAPI
+	cfg               *config.Config
+	storage           storage.Store
+	taskService       *service.TaskService
+	reminderService   *service.ReminderService
+	personService     *service.PersonService
+	scheduleService   *service.ScheduleService
+	autoService       *service.AutoService
+	checklistService  *service.ChecklistService
+	searchService     *service.SearchService
+	calendarService   *service.CalendarService
+	todoistService    *service.TodoistService
+	householdService  *service.HouseholdService
+	settingsService   *service.SettingsService
+	attachmentService *service.AttachmentService
+	feedService       *service.FeedService
+	debtClient        *debtmanager.Client
+	transcriber       speech.Transcriber // nil — голосовые не распознаются
+	clock             clock.Clock
+	server            *http.Server
+	receiving         sync.WaitGroup // Start, пока принимает апдейты
+	handlers          sync.WaitGroup // апдейты в обработке, их дожидается Stop
+	wizards           map[string]*wizard
 }
 
-func New(cfg *config.Config, storage *storage.Storage, taskSvc *service.TaskService, reminderSvc *service.ReminderService, personSvc *service.PersonService, scheduleSvc *service.ScheduleService, autoSvc *service.AutoService, checklistSvc *service.ChecklistService, calendarSvc *service.CalendarService, todoistSvc *service.TodoistService, debtClient *debtmanager.Client) (*Bot, error) {
+func New(cfg *config.Config, storage storage.Store, taskSvc *service.TaskService, reminderSvc *service.ReminderService, personSvc *service.PersonService, scheduleSvc *service.ScheduleService, autoSvc *service.AutoService, checklistSvc *service.ChecklistService, searchSvc *service.SearchService, calendarSvc *service.CalendarService, todoistSvc *service.TodoistService, householdSvc *service.HouseholdService, settingsSvc *service.SettingsService, attachmentSvc *service.AttachmentService, feedSvc *service.FeedService, debtClient *debtmanager.Client) (*Bot, error) {
 	api, err := tgbotapi.NewBotAPI(cfg.TelegramToken)
 	if err != nil {
 		return nil, fmt.Errorf("create bot api: %w", err)
@@ -43,20 +51,26 @@ func New(cfg *config.Config, storage *storage.Storage, taskSvc *service.TaskServ
 	log.Printf("Authorized as @%s", api.Self.UserName)
 
 	bot := &Bot{
-		api:              api,
-		cfg:              cfg,
-		storage:          storage,
-		taskService:      taskSvc,
-		reminderService:  reminderSvc,
-		personService:    personSvc,
-		scheduleService:  scheduleSvc,
-		autoService:      autoSvc,
-		checklistService: checklistSvc,
-		calendarService:  calendarSvc,
-		todoistService:   todoistSvc,
-		debtClient:       debtClient,
-		pendingTasks:     make(map[int64]string),
+		api:               api,
+		cfg:               cfg,
+		storage:           storage,
+		taskService:       taskSvc,
+		reminderService:   reminderSvc,
+		personService:     personSvc,
+		scheduleService:   scheduleSvc,
+		autoService:       autoSvc,
+		checklistService:  checklistSvc,
+		searchService:     searchSvc,
+		calendarService:   calendarSvc,
+		todoistService:    todoistSvc,
+		householdService:  householdSvc,
+		settingsService:   settingsSvc,
+		attachmentService: attachmentSvc,
+		feedService:       feedSvc,
+		debtClient:        debtClient,
+		clock:             clock.Real(),
 	}
+	bot.wizards = bot.newWizards()
 
 	// Set bot commands (menu button)
 	bot.setCommands()
@@ -64,6 +78,11 @@ func New(cfg *config.Config, storage *storage.Storage, taskSvc *service.TaskServ
 	return bot, nil
 }
 
+// SetClock replaces the system clock, e.g. to expire dialogs in tests
+func (b *Bot) SetClock(c clock.Clock) {
+	b.clock = c
+}
+
 func (b *Bot) setCommands() {
 	commands := []tgbotapi.BotCommand{
 		{Command: "menu", Description: "📱 Главное меню"},
@@ -72,6 +91,7 @@ func (b *Bot) setCommands() {
 		{Command: "today", Description: "📅 Задачи на сегодня"},
 		{Command: "calendar", Description: "📆 Календарь"},
 		{Command: "week", Description: "🗓 Недельное расписание"},
+		{Command: "settings", Description: "⚙️ Настройки"},
 		{Command: "help", Description: "❓ Справка по командам"},
 	}
 
@@ -107,8 +127,24 @@ func (b *Bot) SetupWebhook() error {
 	return nil
 }
 
+// Start receives updates — via webhook or long polling, see TELEGRAM_MODE —
+// and serves the health check and REST API in both modes
 func (b *Bot) Start(ctx context.Context) error {
-	updates := b.api.ListenForWebhook("/bot")
+	b.receiving.Add(1)
+	defer b.receiving.Done()
+
+	var updates tgbotapi.UpdatesChannel
+	handled := func(updateID int) {}
+	polling := b.cfg.TelegramMode == config.TelegramModePolling
+	if polling {
+		// getUpdates не работает, пока у бота установлен webhook
+		if _, err := b.api.Request(tgbotapi.DeleteWebhookConfig{}); err != nil {
+			return fmt.Errorf("delete webhook: %w", err)
+		}
+		updates, handled = b.pollUpdates(ctx)
+	} else {
+		updates = b.api.ListenForWebhook("/bot")
+	}
 
 	// Health check endpoint
 	http.HandleFunc("/health", func(w http.ResponseWriter, r *http.Request) {
@@ -119,33 +155,72 @@ func (b *Bot) Start(ctx context.Context) error {
 	// Setup REST API with Basic Auth
 	b.SetupAPI()
 
+	// Подписка на календарь: .ics и CalDAV по токену
+	b.SetupFeed()
+
 	b.server = &http.Server{
 		Addr:    ":" + b.cfg.ServerPort,
 		Handler: nil, // use DefaultServeMux
 	}
 
 	go func() {
-		log.Printf("Starting webhook server on :%s", b.cfg.ServerPort)
+		log.Printf("Starting HTTP server on :%s (telegram mode: %s)", b.cfg.ServerPort, b.cfg.TelegramMode)
 		if err := b.server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
 			log.Printf("HTTP server error: %v", err)
 		}
 	}()
 
+	handle := func(update tgbotapi.Update) {
+		b.handlers.Add(1)
+		go func() {
+			defer b.handlers.Done()
+			b.handleUpdate(update)
+			handled(update.UpdateID)
+		}()
+	}
 	for {
 		select {
 		case <-ctx.Done():
+			if polling {
+				// Полученные, но ещё не разобранные апдейты обрабатываем — их offset уже не вернуть
+				for update := range updates {
+					handle(update)
+				}
+			}
 			return nil
-		case update := <-updates:
-			go b.handleUpdate(update)
+		case update, ok := <-updates:
+			if !ok {
+				return nil
+			}
+			handle(update)
 		}
 	}
 }
 
+// Stop shuts down the HTTP server and waits for updates that are still being handled,
+// in polling mode including those received but not yet handled when ctx was cancelled
 func (b *Bot) Stop(ctx context.Context) error {
+	var err error
 	if b.server != nil {
-		return b.server.Shutdown(ctx)
+		err = b.server.Shutdown(ctx)
 	}
-	return nil
+
+	done := make(chan struct{})
+	go func() {
+		// Start дообрабатывает полученные апдейты и только потом перестаёт добавлять новые
+		b.receiving.Wait()
+		b.handlers.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-ctx.Done():
+		log.Printf("Stop: some updates are still being handled")
+		if err == nil {
+			err = ctx.Err()
+		}
+	}
+	return err
 }
 
 func (b *Bot) SendMessage(chatID int64, text string) error {
@@ -184,24 +259,11 @@ func (b *Bot) SendMessageWithSnooze(chatID int64, text string, taskID int64) err
 	return b.SendMessageWithKeyboard(chatID, text, kb)
 }
 
-func (b *Bot) API() *tgbotapi.BotAPI {
-	return b.api
-}
-
-// SetPendingTask stores task text for priority selection
-func (b *Bot) SetPendingTask(chatID int64, text string) {
-	b.pendingTasksMu.Lock()
-	defer b.pendingTasksMu.Unlock()
-	b.pendingTasks[chatID] = text
+// SendCalendarConflict sends a calendar conflict with buttons to choose the version
+func (b *Bot) SendCalendarConflict(chatID int64, text string, conflictID int64) error {
+	return b.SendMessageWithKeyboard(chatID, text, calendarConflictKeyboard(conflictID))
 }
 
-// GetPendingTask retrieves and removes pending task text
-func (b *Bot) GetPendingTask(chatID int64) (string, bool) {
-	b.pendingTasksMu.Lock()
-	defer b.pendingTasksMu.Unlock()
-	text, ok := b.pendingTasks[chatID]
-	if ok {
-		delete(b.pendingTasks, chatID)
-	}
-	return text, ok
+func (b *Bot) API() *tgbotapi.BotAPI {
+	return b.api
 }
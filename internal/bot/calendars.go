@@ -0,0 +1,303 @@
+package bot
+
+import (
+	"fmt"
+	"html"
+	"log"
+	"strconv"
+	"strings"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"github.com/tazhate/familybot/internal/clients/caldav"
+	"github.com/tazhate/familybot/internal/domain"
+	"github.com/tazhate/familybot/internal/service"
+)
+
+// Календари: /calendars — CalDAV-аккаунты пользователя (iCloud, Nextcloud, Fastmail,
+// Radicale) и подключённые из них календари с цветом, режимом и флагом «общий».
+
+const calendarsHelp = `/calendars add icloud ЛОГИН ПАРОЛЬ — подключить аккаунт
+  <i>fastmail, или адрес сервера для Nextcloud, Radicale и других CalDAV</i>
+/calendars find АККАУНТ — календари аккаунта
+/calendars color ID цвет — 🔵 синий, 🟢 зелёный, 🔴 красный…
+/calendars mode ID read|two-way — только чтение или двусторонняя
+/calendars share ID да|нет — видно всей семье
+/calendars del ID — отключить календарь
+/calendars conflicts — события, изменённые и здесь, и в календаре
+/calendars logout АККАУНТ — удалить аккаунт`
+
+// cmdCalendars shows and manages the user's calendar accounts and calendars
+func (b *Bot) cmdCalendars(msg *tgbotapi.Message, user *domain.User, args string) {
+	chatID := msg.Chat.ID
+	if user == nil {
+		b.SendMessage(chatID, "Сначала /start")
+		return
+	}
+	if b.calendarService == nil {
+		b.SendMessage(chatID, "📆 Календарь не настроен")
+		return
+	}
+
+	sub, rest, _ := strings.Cut(args, " ")
+	rest = strings.TrimSpace(rest)
+
+	switch strings.ToLower(sub) {
+	case "":
+		b.showCalendars(chatID, user)
+	case "add", "добавить":
+		// Пароль не должен оставаться в переписке
+		if _, err := b.api.Request(tgbotapi.NewDeleteMessage(chatID, msg.MessageID)); err != nil {
+			log.Printf("cmdCalendars: delete message with password: %v", err)
+		}
+		provider, url, username, password, err := service.ParseAccountArgs(rest)
+		if err != nil {
+			b.SendMessage(chatID, "❌ "+err.Error()+"\n\nФормат: /calendars add icloud ЛОГИН ПАРОЛЬ")
+			return
+		}
+		b.SendMessage(chatID, "🔍 Вход в "+provider.Name()+"...")
+		account, found, err := b.calendarService.AddAccount(user.ID, provider, url, username, password)
+		if err != nil {
+			log.Printf("cmdCalendars: add account: %v", err)
+			b.SendMessage(chatID, "❌ "+err.Error())
+			return
+		}
+		log.Printf("cmdCalendars: user %d linked account %d", user.ID, account.ID)
+		b.offerCalendars(chatID, account.ID, found)
+	case "find", "найти":
+		accountID, err := strconv.ParseInt(rest, 10, 64)
+		if err != nil {
+			b.SendMessage(chatID, "Формат: /calendars find АККАУНТ")
+			return
+		}
+		found, err := b.calendarService.DiscoverCalendars(user.ID, accountID)
+		if err != nil {
+			b.SendMessage(chatID, "❌ "+err.Error())
+			return
+		}
+		b.offerCalendars(chatID, accountID, found)
+	case "link", "подключить":
+		fields := strings.Fields(rest)
+		if len(fields) != 2 {
+			b.SendMessage(chatID, "Формат: /calendars link АККАУНТ НОМЕР")
+			return
+		}
+		accountID, err1 := strconv.ParseInt(fields[0], 10, 64)
+		n, err2 := strconv.Atoi(fields[1])
+		if err1 != nil || err2 != nil {
+			b.SendMessage(chatID, "Формат: /calendars link АККАУНТ НОМЕР")
+			return
+		}
+		b.linkDiscoveredCalendar(chatID, user, accountID, n-1)
+	case "color", "цвет":
+		id, value, ok := parseCalendarArg(rest)
+		color, valid := domain.ParseCalendarColor(value)
+		if !ok || !valid {
+			b.SendMessage(chatID, "Формат: /calendars color ID цвет\nЦвета: красный, оранжевый, жёлтый, зелёный, синий, фиолетовый, коричневый, чёрный, белый")
+			return
+		}
+		cal, err := b.calendarService.SetColor(user.ID, id, color)
+		b.calendarChanged(chatID, cal, err)
+	case "mode", "режим":
+		id, value, ok := parseCalendarArg(rest)
+		mode, valid := domain.ParseCalendarSyncMode(value)
+		if !ok || !valid {
+			b.SendMessage(chatID, "Формат: /calendars mode ID read|two-way")
+			return
+		}
+		cal, err := b.calendarService.SetMode(user.ID, id, mode)
+		b.calendarChanged(chatID, cal, err)
+	case "share", "общий":
+		id, value, ok := parseCalendarArg(rest)
+		shared, valid := parseOnOff(value)
+		if !ok || !valid {
+			b.SendMessage(chatID, "Формат: /calendars share ID да|нет")
+			return
+		}
+		cal, err := b.calendarService.SetShared(user.ID, id, shared)
+		b.calendarChanged(chatID, cal, err)
+	case "del", "удалить":
+		id, err := strconv.ParseInt(rest, 10, 64)
+		if err != nil {
+			b.SendMessage(chatID, "Формат: /calendars del ID")
+			return
+		}
+		cal, err := b.calendarService.UnlinkCalendar(user.ID, id)
+		if err != nil {
+			b.SendMessage(chatID, "❌ "+err.Error())
+			return
+		}
+		b.SendMessage(chatID, fmt.Sprintf("🗑 Календарь «%s» отключён, его события убраны", html.EscapeString(cal.Name)))
+	case "conflicts", "конфликты":
+		b.showCalendarConflicts(chatID, user)
+	case "logout", "выйти":
+		id, err := strconv.ParseInt(rest, 10, 64)
+		if err != nil {
+			b.SendMessage(chatID, "Формат: /calendars logout АККАУНТ")
+			return
+		}
+		if err := b.calendarService.DeleteAccount(user.ID, id); err != nil {
+			b.SendMessage(chatID, "❌ "+err.Error())
+			return
+		}
+		b.SendMessage(chatID, fmt.Sprintf("🗑 Аккаунт #%d удалён вместе с его календарями", id))
+	default:
+		b.SendMessage(chatID, "<b>Календари:</b>\n\n"+calendarsHelp)
+	}
+}
+
+func (b *Bot) showCalendars(chatID int64, user *domain.User) {
+	accounts, err := b.calendarService.Accounts(user.ID)
+	if err != nil {
+		log.Printf("showCalendars: error: %v", err)
+		b.SendMessage(chatID, "❌ Ошибка: "+err.Error())
+		return
+	}
+	calendars, err := b.calendarService.Calendars(user.ID)
+	if err != nil {
+		log.Printf("showCalendars: error: %v", err)
+		b.SendMessage(chatID, "❌ Ошибка: "+err.Error())
+		return
+	}
+
+	text := "📆 <b>Календари</b>\n\n" + b.calendarService.FormatCalendars(accounts, calendars) + "\n"
+	if conflicts, err := b.calendarService.Conflicts(user.ID); err == nil && len(conflicts) > 0 {
+		text += fmt.Sprintf("⚠️ Конфликтов с календарями: %d — /calendars conflicts\n\n", len(conflicts))
+	}
+	text += calendarsHelp
+	b.SendMessage(chatID, text)
+}
+
+// showCalendarConflicts sends each open conflict with buttons to choose the version
+func (b *Bot) showCalendarConflicts(chatID int64, user *domain.User) {
+	conflicts, err := b.calendarService.Conflicts(user.ID)
+	if err != nil {
+		log.Printf("showCalendarConflicts: error: %v", err)
+		b.SendMessage(chatID, "❌ Ошибка: "+err.Error())
+		return
+	}
+	if len(conflicts) == 0 {
+		b.SendMessage(chatID, "✅ Конфликтов с календарями нет")
+		return
+	}
+	for _, c := range conflicts {
+		b.SendCalendarConflict(chatID, b.calendarService.FormatConflict(c), c.ID)
+	}
+}
+
+// notifyCalendarConflicts asks the owners of the conflicting events which version to keep
+func (b *Bot) notifyCalendarConflicts(conflicts []*domain.CalendarConflict) {
+	for _, c := range conflicts {
+		owner, err := b.storage.GetUser(c.UserID)
+		if err != nil || owner == nil {
+			log.Printf("notifyCalendarConflicts: owner of conflict %d not found: %v", c.ID, err)
+			continue
+		}
+		if err := b.SendCalendarConflict(owner.TelegramID, b.calendarService.FormatConflict(c), c.ID); err != nil {
+			log.Printf("notifyCalendarConflicts: send error: %v", err)
+		}
+	}
+}
+
+// resolveCalendarConflict applies the owner's choice and replaces the conflict message with the result
+func (b *Bot) resolveCalendarConflict(chatID int64, msgID int, user *domain.User, conflictID int64, keepLocal bool) {
+	event, err := b.calendarService.ResolveConflict(user.ID, conflictID, keepLocal)
+	var text string
+	switch {
+	case err != nil:
+		log.Printf("resolveCalendarConflict: conflict %d: %v", conflictID, err)
+		text = "❌ " + html.EscapeString(err.Error())
+	case event == nil:
+		text = "🗑 Событие удалено"
+	case keepLocal:
+		text = fmt.Sprintf("✋ Оставлена твоя версия «%s»", html.EscapeString(event.Title))
+	default:
+		text = fmt.Sprintf("📆 Взята версия из календаря «%s»", html.EscapeString(event.Title))
+	}
+	edit := tgbotapi.NewEditMessageText(chatID, msgID, text)
+	edit.ParseMode = "HTML"
+	b.api.Send(edit)
+}
+
+// offerCalendars lists the calendars found in the account with buttons to link them
+func (b *Bot) offerCalendars(chatID int64, accountID int64, found []caldav.Calendar) {
+	if len(found) == 0 {
+		b.SendMessage(chatID, "Календари в аккаунте не найдены")
+		return
+	}
+
+	var sb strings.Builder
+	sb.WriteString(fmt.Sprintf("📆 <b>Календари аккаунта #%d:</b>\n\n", accountID))
+	var rows [][]tgbotapi.InlineKeyboardButton
+	for i, cal := range found {
+		sb.WriteString(fmt.Sprintf("%d. %s\n", i+1, html.EscapeString(cal.DisplayName)))
+		rows = append(rows, tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData("➕ "+cal.DisplayName, fmt.Sprintf("cal_link:%d:%d", accountID, i)),
+		))
+	}
+	sb.WriteString(fmt.Sprintf("\nПодключить: кнопкой или /calendars link %d НОМЕР", accountID))
+	b.SendMessageWithKeyboard(chatID, sb.String(), tgbotapi.NewInlineKeyboardMarkup(rows...))
+}
+
+// linkDiscoveredCalendar links the calendar found in the account by its position in the list
+func (b *Bot) linkDiscoveredCalendar(chatID int64, user *domain.User, accountID int64, index int) {
+	found, err := b.calendarService.DiscoverCalendars(user.ID, accountID)
+	if err != nil {
+		b.SendMessage(chatID, "❌ "+err.Error())
+		return
+	}
+	if index < 0 || index >= len(found) {
+		b.SendMessage(chatID, "❌ Нет календаря с таким номером")
+		return
+	}
+
+	cal, err := b.calendarService.LinkCalendar(user.ID, accountID, found[index].ID, found[index].DisplayName)
+	if err != nil {
+		b.SendMessage(chatID, "❌ "+err.Error())
+		return
+	}
+	log.Printf("linkDiscoveredCalendar: user %d linked calendar %d", user.ID, cal.ID)
+
+	// Первая синхронизация сразу, чтобы события появились в /calendar
+	text := fmt.Sprintf("✅ Календарь %s <code>#%d</code> подключён · %s %s",
+		html.EscapeString(cal.Label()), cal.ID, cal.Mode.Emoji(), cal.Mode.Name())
+	if result, err := b.calendarService.SyncCalendar(cal); err != nil {
+		log.Printf("linkDiscoveredCalendar: sync error: %v", err)
+		text += "\n\n⚠️ Синхронизация не удалась: " + html.EscapeString(err.Error())
+	} else {
+		text += fmt.Sprintf("\n\n📅 Загружено событий: %d", result.Added)
+	}
+	b.SendMessage(chatID, text)
+}
+
+func (b *Bot) calendarChanged(chatID int64, cal *domain.Calendar, err error) {
+	if err != nil {
+		b.SendMessage(chatID, "❌ "+err.Error())
+		return
+	}
+	text := fmt.Sprintf("✅ %s <code>#%d</code> · %s %s", html.EscapeString(cal.Label()), cal.ID, cal.Mode.Emoji(), cal.Mode.Name())
+	if cal.IsShared {
+		text += " · 👨‍👩‍👧 общий"
+	}
+	b.SendMessage(chatID, text)
+}
+
+// parseCalendarArg parses "ID value" of the /calendars subcommands
+func parseCalendarArg(s string) (int64, string, bool) {
+	idStr, value, _ := strings.Cut(strings.TrimSpace(s), " ")
+	id, err := strconv.ParseInt(idStr, 10, 64)
+	if err != nil || strings.TrimSpace(value) == "" {
+		return 0, "", false
+	}
+	return id, strings.TrimSpace(value), true
+}
+
+// parseOnOff parses "да"/"нет", "on"/"off"
+func parseOnOff(s string) (bool, bool) {
+	switch strings.ToLower(s) {
+	case "да", "вкл", "on", "yes", "1":
+		return true, true
+	case "нет", "выкл", "off", "no", "0":
+		return false, true
+	}
+	return false, false
+}
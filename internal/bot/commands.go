@@ -10,6 +10,7 @@ import (
 	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
 	"github.com/tazhate/familybot/internal/clients/debtmanager"
 	"github.com/tazhate/familybot/internal/domain"
+	"github.com/tazhate/familybot/internal/nlp/dates"
 )
 
 func (b *Bot) handleCommand(msg *tgbotapi.Message, user *domain.User) {
@@ -19,8 +20,17 @@ func (b *Bot) handleCommand(msg *tgbotapi.Message, user *domain.User) {
 
 	log.Printf("handleCommand: cmd=%q, args=%q, text=%q", cmd, args, msg.Text)
 
+	if ok, reason := b.commandAllowed(user, cmd); !ok {
+		b.SendMessage(chatID, reason)
+		return
+	}
+
 	switch cmd {
 	case "start":
+		if args != "" {
+			b.cmdJoin(msg)
+			return
+		}
 		b.cmdStart(msg)
 	case "help":
 		b.cmdHelp(chatID)
@@ -30,6 +40,12 @@ func (b *Bot) handleCommand(msg *tgbotapi.Message, user *domain.User) {
 		b.cmdList(chatID, user, args)
 	case "done":
 		b.cmdDone(chatID, user, args)
+	case "subtask":
+		b.cmdSubtask(chatID, user, args)
+	case "block":
+		b.cmdBlock(chatID, user, args)
+	case "unblock":
+		b.cmdUnblock(chatID, user, args)
 	case "del":
 		b.cmdDel(chatID, user, args)
 	case "today":
@@ -84,6 +100,16 @@ func (b *Bot) handleCommand(msg *tgbotapi.Message, user *domain.User) {
 		b.cmdMaintenance(chatID, user, args)
 	case "seedautos":
 		b.cmdSeedAutos(chatID, user)
+	case "auto":
+		b.cmdAuto(chatID, user, args)
+	case "odo":
+		b.cmdOdo(chatID, user, args)
+	case "service":
+		b.cmdService(chatID, user, args)
+	case "rule":
+		b.cmdRule(chatID, user, args)
+	case "autodoc":
+		b.cmdAutoDoc(chatID, user, args)
 	case "addrepeat":
 		b.cmdAddRepeat(chatID, user, args)
 	case "seedallnodes":
@@ -102,6 +128,8 @@ func (b *Bot) handleCommand(msg *tgbotapi.Message, user *domain.User) {
 		b.cmdHistory(chatID, user)
 	case "stats":
 		b.cmdStats(chatID, user)
+	case "find":
+		b.cmdFind(chatID, user, args)
 	case "linkperson":
 		b.cmdLinkPerson(chatID, user, args)
 	case "shareweekly":
@@ -126,10 +154,14 @@ func (b *Bot) handleCommand(msg *tgbotapi.Message, user *domain.User) {
 		b.cmdCalendarWeek(chatID, user)
 	case "addevent":
 		b.cmdAddEvent(chatID, user, args)
+	case "cancel":
+		b.cmdCancel(chatID, user)
 	case "syncapple":
-		b.cmdSyncApple(chatID, user)
+		b.cmdSyncApple(chatID, user, args)
 	case "calendars":
-		b.cmdCalendars(chatID, user)
+		b.cmdCalendars(msg, user, args)
+	case "feed":
+		b.cmdFeed(msg, user, args)
 	// Todoist commands
 	case "synctodoist":
 		b.cmdSyncTodoist(chatID, user)
@@ -139,6 +171,15 @@ func (b *Bot) handleCommand(msg *tgbotapi.Message, user *domain.User) {
 		b.cmdChatID(chatID, msg)
 	case "quote":
 		b.cmdQuote(chatID)
+	// Household commands
+	case "family":
+		b.cmdFamily(chatID, user, args)
+	case "invite":
+		b.cmdInvite(chatID, user, args)
+	case "join":
+		b.cmdJoin(msg)
+	case "settings":
+		b.cmdSettings(chatID, user, args)
 	default:
 		b.SendMessage(chatID, "Неизвестная команда. /help для списка команд")
 	}
@@ -178,6 +219,7 @@ func (b *Bot) cmdStart(msg *tgbotapi.Message) {
 		return
 	}
 	log.Printf("cmdStart: registered user: %s (%d)", newUser.Name, newUser.TelegramID)
+	b.bootstrapHousehold()
 
 	text := fmt.Sprintf("👋 Привет, %s!\n\nЯ помогу управлять задачами и напоминаниями.", name)
 	kb := mainMenuKeyboard()
@@ -189,41 +231,71 @@ func (b *Bot) cmdHelp(chatID int64) {
 
 <b>Задачи</b>
 /add текст — добавить задачу
-  <i>даты: завтра, 20 января, 04.02, в пятницу</i>
+  <i>даты: завтра в 15:30, 20 января, 04.02, в пятницу, через 3 дня</i>
 /list — список задач
 /done ID — выполнить задачу
+/subtask ID текст — подзадача
+/block ID BLOCKER — задача ждёт другую
+/unblock ID BLOCKER — снять блокировку
 /del ID — удалить задачу
 /today — задачи на сегодня
-/remind ID 1д,1ч — напоминание до дедлайна
+/remind ID 1д,1ч — напоминание до дедлайна (или «завтра в 10:00»)
 /assign ID кому — назначить задачу
 /shared — общие семейные задачи
 /share ID — сделать задачу общей
+/find текст — поиск по задачам, людям, чек-листам и событиям
 
 <b>Расписание</b>
 /week — недельное расписание
-/addweekly Пн 17:30 Событие
+/addweekly Пн 17:30 Событие (без аргументов — по шагам)
 /addfloating Сб,Вс 10:00 Лука
 /floating — плавающие события
 
 <b>Люди</b>
 /people — список людей
-/addperson Имя роль ДД.ММ.ГГГГ
+/addperson Имя роль ДД.ММ.ГГГГ (без аргументов — по шагам)
 /birthdays — ближайшие ДР
 
+<b>Машины</b>
+/autos — все машины
+/auto Название — карточка: пробег, документы, регламент, история
+/odo Название 85000 — записать пробег
+/service Название замена масла 85000км 4500₽ — сервисная книжка
+/rule Название масло каждые 10000 км или 12 мес — регламент ТО
+/autodoc Название осаго ДД.ММ.ГГГГ — документы со сроком (/autodoc — список)
+
+<b>Календарь</b>
+/calendar — события на сегодня и завтра из всех календарей
+/calweek — события на неделю
+/addevent Врач в пятницу 10:00 — добавить событие
+/calendars — CalDAV-аккаунты и календари: подключить, цвет, режим, общий
+/syncapple — синхронизировать сейчас (/syncapple full — заново)
+/feed — ссылка на подписку на календарь семьи (.ics и CalDAV)
+
 <b>Чек-листы</b>
-/checklist Название — показать чек-лист
+/checklist Название — отмечать пункты (текущий прогон)
+/checklist history Название — история прогонов
 /checklists — все чек-листы
 /addchecklist — создать чек-лист
 
 <b>Напоминания</b>
 /reminders — список напоминаний
 
+<b>Семья</b>
+/family — участники и роли
+/invite [роль] — пригласить в семью
+/join КОД — вступить по приглашению
+
 <b>Статистика</b>
 /history — выполненные задачи
 /stats — статистика за неделю/месяц
 
+<b>Настройки</b>
+/settings — часовой пояс, время брифингов, тихие часы
+
 <b>Навигация</b>
 /menu — главное меню
+/cancel — прервать пошаговый ввод
 /help — эта справка
 
 💡 <i>Просто отправь текст — добавлю как задачу</i>`
@@ -264,33 +336,23 @@ func (b *Bot) cmdAdd(chatID int64, user *domain.User, args string) {
 	}
 
 	if args == "" {
-		b.SendMessage(chatID, "Напиши текст задачи:")
+		b.startWizard(chatID, user, flowAddTask, nil)
 		return
 	}
 
-	// Парсим @упоминания и извлекаем чистый текст
-	cleanText, mentions := b.taskService.ParseMentions(args)
+	args, priority := parsePriorityTags(args)
 
-	// Резолвим @mention через гибридный поиск (People -> Users)
-	var personID *int64
-	var assignedTo *int64
-	var personName string
-	for _, mention := range mentions {
-		resolved, err := b.taskService.ResolveMention(user.ID, mention)
-		if err == nil && resolved != nil {
-			personID = resolved.PersonID
-			assignedTo = resolved.UserID
-			personName = resolved.Name
-			break // Берём первое найденное упоминание
-		}
+	// Если приоритет не указан — мастер спросит его
+	if priority == "" {
+		b.startWizard(chatID, user, flowAddTask, map[string]string{"title": args})
+		return
 	}
 
-	args = cleanText
-
-	// Парсим дату из текста (завтра, в понедельник, через неделю)
-	args, dueDate := b.taskService.ParseDate(args)
+	b.addTask(chatID, user, args, priority)
+}
 
-	// Парсим приоритет из тегов
+// parsePriorityTags extracts a priority tag (!срочно, !неделя, !потом, !1..!3) from the text
+func parsePriorityTags(args string) (string, domain.Priority) {
 	priority := domain.Priority("")
 	if strings.Contains(args, "!срочно") || strings.Contains(args, "!urgent") || strings.Contains(args, "!1") {
 		priority = domain.PriorityUrgent
@@ -308,31 +370,58 @@ func (b *Bot) cmdAdd(chatID int64, user *domain.User, args string) {
 		args = strings.ReplaceAll(args, "!someday", "")
 		args = strings.ReplaceAll(args, "!3", "")
 	}
+	return args, priority
+}
 
-	args = strings.TrimSpace(args)
+// addTask creates a task from text with @mentions and a date ("завтра", "в понедельник")
+func (b *Bot) addTask(chatID int64, user *domain.User, raw string, priority domain.Priority) {
+	task, personName, err := b.createTask(chatID, user, raw, priority)
+	if err != nil {
+		log.Printf("addTask: error creating task: %v", err)
+		b.SendMessage(chatID, "❌ Ошибка: "+err.Error())
+		return
+	}
 
-	// Если приоритет не указан — показываем выбор
-	if priority == "" {
-		b.SetPendingTask(chatID, args)
-		kb := priorityKeyboard()
-		hint := "Выбери приоритет:\n\n<b>" + args + "</b>"
-		if personName != "" {
-			hint += fmt.Sprintf("\n\n👤 Для: %s", personName)
-		}
-		if dueDate != nil {
-			hint += fmt.Sprintf("\n📅 %s", dueDate.Format("02.01.2006"))
+	text := fmt.Sprintf("✅ Задача добавлена\n\n%s <b>#%d</b> %s", task.PriorityEmoji(), task.ID, task.Title)
+	if personName != "" {
+		text += fmt.Sprintf("\n👤 @%s", personName)
+	}
+	if task.DueDate != nil {
+		text += fmt.Sprintf("\n📅 %s", formatDueDate(*task.DueDate))
+	}
+	kb := taskKeyboard(task.ID)
+	b.SendMessageWithKeyboard(chatID, text, kb)
+}
+
+// createTask creates a task in the chat's list: resolves the first @mention, parses the date
+// and syncs the task to Apple Calendar. Returns the name of the mentioned person if any.
+func (b *Bot) createTask(chatID int64, user *domain.User, raw string, priority domain.Priority) (*domain.Task, string, error) {
+	// Парсим @упоминания и извлекаем чистый текст
+	cleanText, mentions := b.taskService.ParseMentions(raw)
+
+	// Резолвим @mention через гибридный поиск (People -> Users)
+	var personID *int64
+	var assignedTo *int64
+	var personName string
+	for _, mention := range mentions {
+		resolved, err := b.taskService.ResolveMention(user.ID, mention)
+		if err == nil && resolved != nil {
+			personID = resolved.PersonID
+			assignedTo = resolved.UserID
+			personName = resolved.Name
+			break // Берём первое найденное упоминание
 		}
-		b.SendMessageWithKeyboard(chatID, hint, kb)
-		return
 	}
 
-	task, err := b.taskService.CreateFull(user.ID, chatID, args, priority, personID, dueDate)
+	// Парсим дату из текста (завтра, в понедельник, через неделю)
+	title, dueDate := b.taskService.ParseDate(cleanText)
+	title = strings.TrimSpace(title)
+
+	task, err := b.taskService.CreateFull(user.ID, chatID, title, priority, personID, dueDate)
 	if err != nil {
-		log.Printf("cmdAdd: error creating task: %v", err)
-		b.SendMessage(chatID, "❌ Ошибка: "+err.Error())
-		return
+		return nil, "", err
 	}
-	log.Printf("cmdAdd: created task %d for user %d", task.ID, user.ID)
+	log.Printf("createTask: created task %d for user %d", task.ID, user.ID)
 
 	// Если есть связь с Telegram — назначаем пользователю
 	if assignedTo != nil {
@@ -347,15 +436,7 @@ func (b *Bot) cmdAdd(chatID int64, user *domain.User, args string) {
 		}
 	}
 
-	text := fmt.Sprintf("✅ Задача добавлена\n\n%s <b>#%d</b> %s", task.PriorityEmoji(), task.ID, task.Title)
-	if personName != "" {
-		text += fmt.Sprintf("\n👤 @%s", personName)
-	}
-	if task.DueDate != nil {
-		text += fmt.Sprintf("\n📅 %s", task.DueDate.Format("02.01.2006"))
-	}
-	kb := taskKeyboard(task.ID)
-	b.SendMessageWithKeyboard(chatID, text, kb)
+	return task, personName, nil
 }
 
 func (b *Bot) cmdList(chatID int64, user *domain.User, args string) {
@@ -460,6 +541,164 @@ func (b *Bot) cmdDone(chatID int64, user *domain.User, args string) {
 		),
 	)
 	b.SendMessageWithKeyboard(chatID, text, kb)
+	b.refreshSharedIfShared(user, taskID)
+	b.offerParentDone(chatID, taskID)
+}
+
+// offerParentDone предлагает закрыть родительскую задачу, если выполнена её последняя подзадача
+func (b *Bot) offerParentDone(chatID int64, taskID int64) {
+	parent, err := b.taskService.CompletableParent(taskID)
+	if err != nil {
+		log.Printf("offerParentDone: error: %v", err)
+		return
+	}
+	if parent == nil {
+		return
+	}
+
+	text := fmt.Sprintf("🎉 Все подзадачи <b>#%d</b> %s выполнены.\nЗакрыть и её?", parent.ID, parent.Title)
+	kb := tgbotapi.NewInlineKeyboardMarkup(
+		tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData(fmt.Sprintf("✅ Закрыть #%d", parent.ID), fmt.Sprintf("done:%d", parent.ID)),
+			tgbotapi.NewInlineKeyboardButtonData("📋 К списку", "menu:list"),
+		),
+	)
+	b.SendMessageWithKeyboard(chatID, text, kb)
+}
+
+// formatTaskRelations — родитель, подзадачи и блокировки для карточки задачи
+func (b *Bot) formatTaskRelations(task *domain.Task) string {
+	var sb strings.Builder
+	if task.ParentID != nil {
+		if parent, _ := b.storage.GetTask(*task.ParentID); parent != nil {
+			sb.WriteString(fmt.Sprintf("\n⬆️ Часть задачи: #%d %s", parent.ID, parent.Title))
+		}
+	}
+	if subtasks, _ := b.taskService.Subtasks(task.ID); len(subtasks) > 0 {
+		done := 0
+		for _, t := range subtasks {
+			if t.IsDone() {
+				done++
+			}
+		}
+		sb.WriteString(fmt.Sprintf("\n\n<b>Подзадачи</b> (%d/%d):", done, len(subtasks)))
+		for _, t := range subtasks {
+			status := "⬜"
+			if t.IsDone() {
+				status = "✅"
+			}
+			sb.WriteString(fmt.Sprintf("\n%s #%d %s", status, t.ID, t.Title))
+		}
+	}
+	if blockers, _ := b.taskService.Blockers(task.ID); len(blockers) > 0 {
+		sb.WriteString("\n\n<b>Ждёт:</b>")
+		for _, t := range blockers {
+			status := "⛔"
+			if t.IsDone() {
+				status = "✅"
+			}
+			sb.WriteString(fmt.Sprintf("\n%s #%d %s", status, t.ID, t.Title))
+		}
+	}
+	return sb.String()
+}
+
+func (b *Bot) cmdSubtask(chatID int64, user *domain.User, args string) {
+	if user == nil {
+		b.SendMessage(chatID, "Сначала /start")
+		return
+	}
+
+	parts := strings.SplitN(strings.TrimSpace(args), " ", 2)
+	if len(parts) < 2 || strings.TrimSpace(parts[1]) == "" {
+		text := `<b>Подзадача:</b>
+
+/subtask ID текст — добавить подзадачу к задаче ID
+
+<b>Пример:</b>
+/subtask 12 Купить краску`
+		b.SendMessage(chatID, text)
+		return
+	}
+
+	parentID, err := strconv.ParseInt(parts[0], 10, 64)
+	if err != nil {
+		b.SendMessage(chatID, "Неверный ID задачи")
+		return
+	}
+
+	task, err := b.taskService.CreateSubtask(parentID, user.ID, chatID, parts[1])
+	if err != nil {
+		log.Printf("cmdSubtask: error: %v", err)
+		b.SendMessage(chatID, "❌ Ошибка: "+err.Error())
+		return
+	}
+	log.Printf("cmdSubtask: created task %d under %d", task.ID, parentID)
+
+	text := fmt.Sprintf("✅ Подзадача добавлена\n\n%s <b>#%d</b> %s\n⬆️ Часть задачи #%d", task.PriorityEmoji(), task.ID, task.Title, parentID)
+	kb := taskKeyboard(task.ID)
+	b.SendMessageWithKeyboard(chatID, text, kb)
+}
+
+func (b *Bot) cmdBlock(chatID int64, user *domain.User, args string) {
+	b.changeBlocker(chatID, user, args, true)
+}
+
+func (b *Bot) cmdUnblock(chatID int64, user *domain.User, args string) {
+	b.changeBlocker(chatID, user, args, false)
+}
+
+// changeBlocker обрабатывает /block и /unblock: «ID blockerID»
+func (b *Bot) changeBlocker(chatID int64, user *domain.User, args string, block bool) {
+	if user == nil {
+		b.SendMessage(chatID, "Сначала /start")
+		return
+	}
+
+	fields := strings.Fields(args)
+	if len(fields) != 2 {
+		text := `<b>Блокировки задач:</b>
+
+/block ID BLOCKER — задача ID ждёт задачу BLOCKER
+/unblock ID BLOCKER — снять блокировку
+
+Заблокированные задачи не показываются в /today.
+
+<b>Пример:</b>
+/block 15 12`
+		b.SendMessage(chatID, text)
+		return
+	}
+
+	taskID, err1 := strconv.ParseInt(fields[0], 10, 64)
+	blockerID, err2 := strconv.ParseInt(fields[1], 10, 64)
+	if err1 != nil || err2 != nil {
+		b.SendMessage(chatID, "Неверный ID задачи")
+		return
+	}
+
+	var text string
+	var err error
+	if block {
+		err = b.taskService.Block(taskID, blockerID, user.ID, chatID)
+		text = fmt.Sprintf("⛔ Задача <b>#%d</b> ждёт <b>#%d</b>", taskID, blockerID)
+	} else {
+		err = b.taskService.Unblock(taskID, blockerID, user.ID, chatID)
+		text = fmt.Sprintf("✅ Задача <b>#%d</b> больше не ждёт <b>#%d</b>", taskID, blockerID)
+	}
+	if err != nil {
+		log.Printf("changeBlocker: error: %v", err)
+		b.SendMessage(chatID, "❌ Ошибка: "+err.Error())
+		return
+	}
+	log.Printf("changeBlocker: task %d blocker %d block=%v", taskID, blockerID, block)
+
+	kb := tgbotapi.NewInlineKeyboardMarkup(
+		tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData("📋 К списку", "menu:list"),
+		),
+	)
+	b.SendMessageWithKeyboard(chatID, text, kb)
 }
 
 func (b *Bot) cmdDel(chatID int64, user *domain.User, args string) {
@@ -588,17 +827,7 @@ func (b *Bot) cmdAddPerson(chatID int64, user *domain.User, args string) {
 	}
 
 	if args == "" {
-		text := `<b>Добавить человека:</b>
-
-/addperson Имя роль ДД.ММ.ГГГГ
-
-<b>Примеры:</b>
-/addperson Тим ребёнок 12.06.2017
-/addperson Ира семья 17.12
-/addperson Федя контакт
-
-<b>Роли:</b> ребёнок, семья, контакт`
-		b.SendMessage(chatID, text)
+		b.startWizard(chatID, user, flowAddPerson, nil)
 		return
 	}
 
@@ -616,6 +845,10 @@ func (b *Bot) cmdAddPerson(chatID int64, user *domain.User, args string) {
 	}
 	log.Printf("cmdAddPerson: created person %s (ID: %d)", person.Name, person.ID)
 
+	b.personAdded(chatID, person)
+}
+
+func (b *Bot) personAdded(chatID int64, person *domain.Person) {
 	text := fmt.Sprintf("✅ Добавлен: %s <b>%s</b>", person.RoleEmoji(), person.Name)
 	if person.HasBirthday() {
 		text += fmt.Sprintf("\n🎂 %s", person.Birthday.Format("02.01.2006"))
@@ -691,19 +924,7 @@ func (b *Bot) cmdAddWeekly(chatID int64, user *domain.User, args string) {
 	}
 
 	if args == "" {
-		text := `<b>Добавить регулярное событие:</b>
-
-/addweekly День Время Название
-/addweekly День Время !N Название
-
-<b>Примеры:</b>
-/addweekly Пн 17:30 Федя спорт
-/addweekly Ср 16:00-20:00 Тим плавание
-/addweekly Сб 10:00 !15 Шахматы
-
-<b>!N</b> — напомнить за N минут
-<b>Дни:</b> Пн, Вт, Ср, Чт, Пт, Сб, Вс`
-		b.SendMessage(chatID, text)
+		b.startWizard(chatID, user, flowAddWeekly, nil)
 		return
 	}
 
@@ -721,6 +942,10 @@ func (b *Bot) cmdAddWeekly(chatID int64, user *domain.User, args string) {
 	}
 	log.Printf("cmdAddWeekly: created event %d", event.ID)
 
+	b.weeklyEventAdded(chatID, event)
+}
+
+func (b *Bot) weeklyEventAdded(chatID int64, event *domain.WeeklyEvent) {
 	// Sync to Apple Calendar
 	if b.calendarService != nil {
 		_ = b.calendarService.SyncWeeklyEventToCalendar(event.ID, int(event.DayOfWeek), event.TimeStart, event.TimeEnd, event.Title, event.IsFloating, nil)
@@ -1005,7 +1230,7 @@ func (b *Bot) cmdFloating(chatID int64, user *domain.User) {
 		}
 
 		status := "❓ не выбран"
-		if e.IsConfirmedThisWeek() && e.ConfirmedDay != nil {
+		if e.IsConfirmedThisWeek(time.Now()) && e.ConfirmedDay != nil {
 			status = "✅ " + domain.WeekdayNameShort(domain.Weekday(*e.ConfirmedDay))
 		}
 
@@ -1032,11 +1257,35 @@ func truncate(s string, maxLen int) string {
 	return string(runes[:maxLen-1]) + "…"
 }
 
+// cmdFind searches tasks, people, checklists, schedule and calendar events
+func (b *Bot) cmdFind(chatID int64, user *domain.User, args string) {
+	if user == nil {
+		b.SendMessage(chatID, "Сначала /start")
+		return
+	}
+
+	if args == "" {
+		b.SendMessage(chatID, "Что ищем? /find молоко")
+		return
+	}
+
+	results, err := b.searchService.Search(user.ID, args)
+	if err != nil {
+		log.Printf("cmdFind: error: %v", err)
+		b.SendMessage(chatID, "❌ Ошибка поиска: "+err.Error())
+		return
+	}
+
+	text := b.searchService.FormatResults(args, results)
+	kb := searchResultsKeyboard(results)
+	b.SendMessageWithKeyboard(chatID, text, kb)
+}
+
 // cmdSeedWeek seeds the default weekly schedule
 func (b *Bot) cmdSeedWeek(chatID int64, user *domain.User) {
-	// Check if user is owner
-	if user.TelegramID != b.cfg.OwnerTelegramID {
-		b.SendMessage(chatID, "❌ Только владелец может заполнить расписание")
+	// Только взрослые участники семьи
+	if ok, err := b.householdService.IsAdult(user.ID); err != nil || !ok {
+		b.SendMessage(chatID, "❌ Только взрослые участники семьи могут заполнить расписание")
 		return
 	}
 
@@ -1100,9 +1349,9 @@ func (b *Bot) cmdSeedWeek(chatID int64, user *domain.User) {
 
 // cmdSeedPeople seeds the default people with birthdays
 func (b *Bot) cmdSeedPeople(chatID int64, user *domain.User) {
-	// Check if user is owner
-	if user.TelegramID != b.cfg.OwnerTelegramID {
-		b.SendMessage(chatID, "❌ Только владелец может добавить людей")
+	// Только взрослые участники семьи
+	if ok, err := b.householdService.IsAdult(user.ID); err != nil || !ok {
+		b.SendMessage(chatID, "❌ Только взрослые участники семьи могут добавить людей")
 		return
 	}
 
@@ -1267,29 +1516,8 @@ func (b *Bot) cmdShared(chatID int64, user *domain.User) {
 		return
 	}
 
-	tasks, err := b.taskService.ListShared(false)
-	if err != nil {
-		log.Printf("cmdShared: error: %v", err)
-		b.SendMessage(chatID, "❌ Ошибка: "+err.Error())
-		return
-	}
-
-	// Получаем имена людей для отображения
-	personNames, _ := b.personService.GetNamesMap(user.ID)
-
-	text := "<b>👨‍👩‍👧 Общие задачи</b>\n\n"
-	if len(tasks) == 0 {
-		text += "Нет общих задач.\n\n💡 Сделай задачу общей: /share ID"
-	} else {
-		text += b.taskService.FormatTaskListWithPersons(tasks, personNames)
-	}
-
-	kb := tgbotapi.NewInlineKeyboardMarkup(
-		tgbotapi.NewInlineKeyboardRow(
-			tgbotapi.NewInlineKeyboardButtonData("📋 Мои задачи", "menu:list"),
-		),
-	)
-	b.SendMessageWithKeyboard(chatID, text, kb)
+	text, kb := b.sharedTasksView(user.ID)
+	b.sendLive(chatID, user.ID, text, kb, domain.LiveSharedTasks, b.householdID(user))
 }
 
 func (b *Bot) cmdShare(chatID int64, user *domain.User, args string) {
@@ -1322,6 +1550,7 @@ func (b *Bot) cmdShare(chatID int64, user *domain.User, args string) {
 		return
 	}
 	log.Printf("cmdShare: task %d shared", taskID)
+	b.refreshSharedTasks(user, 0, 0)
 
 	text := fmt.Sprintf("✅ Задача <b>#%d</b> теперь общая", taskID)
 	kb := tgbotapi.NewInlineKeyboardMarkup(
@@ -1356,6 +1585,7 @@ func (b *Bot) cmdUnshare(chatID int64, user *domain.User, args string) {
 		return
 	}
 	log.Printf("cmdUnshare: task %d unshared", taskID)
+	b.refreshSharedTasks(user, 0, 0)
 
 	text := fmt.Sprintf("✅ Задача <b>#%d</b> больше не общая", taskID)
 	kb := tgbotapi.NewInlineKeyboardMarkup(
@@ -1384,9 +1614,13 @@ func (b *Bot) cmdRemind(chatID int64, user *domain.User, args string) {
 • час, 1ч — за час
 • 30м — за 30 минут
 
+<b>Или точное время:</b>
+• завтра в 10:00, через 2 часа, в пятницу 9 утра
+
 <b>Примеры:</b>
 /remind 5 1д,1ч — за день и за час
-/remind 5 неделя,день,час`
+/remind 5 неделя,день,час
+/remind 5 завтра в 10:00`
 		b.SendMessage(chatID, text)
 		return
 	}
@@ -1410,15 +1644,27 @@ func (b *Bot) cmdRemind(chatID int64, user *domain.User, args string) {
 		return
 	}
 
+	// Parse intervals
+	intervalsStr := strings.Join(parts[1:], ",")
+	intervals := strings.Split(intervalsStr, ",")
+
+	hasIntervals := false
+	for _, intStr := range intervals {
+		if _, ok := domain.ParseRemindInterval(intStr); ok {
+			hasIntervals = true
+			break
+		}
+	}
+	if !hasIntervals {
+		b.remindAt(chatID, user, task, strings.Join(parts[1:], " "))
+		return
+	}
+
 	if task.DueDate == nil {
 		b.SendMessage(chatID, "❌ У задачи нет даты. Добавь дату: /add текст завтра")
 		return
 	}
 
-	// Parse intervals
-	intervalsStr := strings.Join(parts[1:], ",")
-	intervals := strings.Split(intervalsStr, ",")
-
 	var added []string
 	for _, intStr := range intervals {
 		minutes, ok := domain.ParseRemindInterval(intStr)
@@ -1454,6 +1700,54 @@ func (b *Bot) cmdRemind(chatID int64, user *domain.User, args string) {
 	b.SendMessageWithKeyboard(chatID, text, kb)
 }
 
+// remindAt adds a reminder at an exact time ("завтра в 10:00", "через 2 часа")
+func (b *Bot) remindAt(chatID int64, user *domain.User, task *domain.Task, text string) {
+	now := time.Now()
+	when := dates.Parse(text, now)
+	if when == nil {
+		b.SendMessage(chatID, "❌ Не удалось распознать интервалы или время. Примеры: 1д,1ч или завтра в 10:00")
+		return
+	}
+	at := when.Time
+	if !at.After(now) {
+		b.SendMessage(chatID, "❌ Это время уже прошло")
+		return
+	}
+
+	// Без дедлайна или дедлайн на весь этот день — время напоминания становится сроком
+	if task.DueDate == nil || (task.DueDate.Hour() == 0 && task.DueDate.Minute() == 0 &&
+		task.DueDate.Format("2006-01-02") == at.Format("2006-01-02")) {
+		if err := b.taskService.UpdateDueDate(task.ID, user.ID, chatID, &at); err != nil {
+			b.SendMessage(chatID, "❌ Ошибка: "+err.Error())
+			return
+		}
+		task.DueDate = &at
+	}
+	if at.After(*task.DueDate) {
+		b.SendMessage(chatID, fmt.Sprintf("❌ Напоминание позже дедлайна (%s)", formatDueDate(*task.DueDate)))
+		return
+	}
+
+	tr := &domain.TaskReminder{
+		TaskID:       task.ID,
+		RemindBefore: int(task.DueDate.Sub(at).Minutes()),
+	}
+	if err := b.storage.CreateTaskReminder(tr); err != nil {
+		b.SendMessage(chatID, "❌ Ошибка: "+err.Error())
+		return
+	}
+
+	text = fmt.Sprintf("✅ Напомню о <b>#%d</b> %s\n\n⏰ %s\n📅 Дедлайн: %s",
+		task.ID, task.Title, at.Format("02.01.2006 15:04"), formatDueDate(*task.DueDate))
+
+	kb := tgbotapi.NewInlineKeyboardMarkup(
+		tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData("📋 К задачам", "menu:list"),
+		),
+	)
+	b.SendMessageWithKeyboard(chatID, text, kb)
+}
+
 func (b *Bot) cmdEdit(chatID int64, user *domain.User, args string) {
 	if user == nil {
 		b.SendMessage(chatID, "Сначала /start")
@@ -1466,7 +1760,7 @@ func (b *Bot) cmdEdit(chatID int64, user *domain.User, args string) {
 /edit ID — показать задачу и опции
 /edit ID текст Новый текст
 /edit ID приоритет срочно|неделя|потом
-/edit ID дата завтра|20.01|20 января
+/edit ID дата завтра 15:00|в пятницу|20.01|- (убрать)
 
 <b>Примеры:</b>
 /edit 5
@@ -1494,7 +1788,7 @@ func (b *Bot) cmdEdit(chatID int64, user *domain.User, args string) {
 	if len(parts) == 1 {
 		dueStr := "не установлена"
 		if task.DueDate != nil {
-			dueStr = task.DueDate.Format("02.01.2006")
+			dueStr = formatDueDate(*task.DueDate)
 		}
 		text := fmt.Sprintf("<b>✏️ Редактирование #%d</b>\n\n%s <b>%s</b>\n📅 Дата: %s\n🎯 Приоритет: %s",
 			task.ID, task.PriorityEmoji(), task.Title, dueStr, priorityName(task.Priority))
@@ -1542,12 +1836,14 @@ func (b *Bot) cmdEdit(chatID int64, user *domain.User, args string) {
 		b.SendMessage(chatID, fmt.Sprintf("✅ Приоритет задачи #%d: %s", taskID, priorityName(priority)))
 
 	case "дата", "date", "due":
+		// «завтра в 10:00», «в пятницу», «25.01»; «-» или «нет» убирают дату
 		_, dueDate := b.taskService.ParseDate(value)
 		if dueDate == nil {
-			// Try parsing as DD.MM.YYYY directly
-			t, err := time.Parse("02.01.2006", value)
-			if err == nil {
-				dueDate = &t
+			switch strings.ToLower(value) {
+			case "-", "нет", "none":
+			default:
+				b.SendMessage(chatID, "❌ Не удалось распознать дату. Примеры: завтра, в пятницу 15:00, 25.01, через 3 дня. Убрать дату: -")
+				return
 			}
 		}
 		if err := b.taskService.UpdateDueDate(taskID, user.ID, chatID, dueDate); err != nil {
@@ -1570,7 +1866,7 @@ func (b *Bot) cmdEdit(chatID int64, user *domain.User, args string) {
 
 		dateStr := "убрана"
 		if dueDate != nil {
-			dateStr = dueDate.Format("02.01.2006")
+			dateStr = formatDueDate(*dueDate)
 		}
 		b.SendMessage(chatID, fmt.Sprintf("✅ Дата задачи #%d: %s", taskID, dateStr))
 
@@ -1592,6 +1888,14 @@ func priorityName(p domain.Priority) string {
 	}
 }
 
+// formatDueDate shows the time only when the deadline has one
+func formatDueDate(t time.Time) string {
+	if t.Hour() == 0 && t.Minute() == 0 {
+		return t.Format("02.01.2006")
+	}
+	return t.Format("02.01.2006 15:04")
+}
+
 func (b *Bot) cmdEditReminder(chatID int64, user *domain.User, args string) {
 	if user == nil {
 		b.SendMessage(chatID, "Сначала /start")
@@ -1706,12 +2010,7 @@ func (b *Bot) cmdAutos(chatID int64, user *domain.User) {
 		text += "\n/seedautos — добавить дефолтные"
 	}
 
-	kb := tgbotapi.NewInlineKeyboardMarkup(
-		tgbotapi.NewInlineKeyboardRow(
-			tgbotapi.NewInlineKeyboardButtonData("➕ Добавить", "add_auto"),
-			tgbotapi.NewInlineKeyboardButtonData("🏠 Меню", "menu:main"),
-		),
-	)
+	kb := autosKeyboard(autos, tgbotapi.NewInlineKeyboardButtonData("🏠 Меню", "menu:main"))
 	b.SendMessageWithKeyboard(chatID, text, kb)
 }
 
@@ -1917,11 +2216,19 @@ func (b *Bot) cmdAddRepeat(chatID int64, user *domain.User, args string) {
 • monthly ДЕНЬ — N-е число каждого месяца
 • monthly_nth N День — N-я неделя месяца
 
+<b>Или любое правило</b> (по-русски или RRULE):
+/addrepeat ПРАВИЛО ЧЧ:ММ Название
+
 <b>Примеры:</b>
 /addrepeat daily 09:15 Утренний статус
 /addrepeat weekdays 09:00 Дейли-статус
 /addrepeat monthly 4 11:00 Отчёт Apostol
-/addrepeat monthly_nth 2 Пт 09:00 Дежурство`
+/addrepeat monthly_nth 2 Пт 09:00 Дежурство
+/addrepeat каждые 2 недели в пт 19:00 Полить цветы
+/addrepeat последнюю пятницу месяца 10:00 Отчёт
+/addrepeat каждые 3 месяца 15 числа 12:00 Счётчики
+/addrepeat каждый год 1 сентября 08:00 Линейка
+/addrepeat FREQ=MONTHLY;BYDAY=-1FR 10:00 Отчёт`
 		b.SendMessage(chatID, text)
 		return
 	}
@@ -1934,6 +2241,14 @@ func (b *Bot) cmdAddRepeat(chatID int64, user *domain.User, args string) {
 
 	repeatTypeStr := strings.ToLower(parts[0])
 
+	// Всё, что не шорткат, — правило повторения: «каждые 2 недели в пт 19:00 Название»
+	switch repeatTypeStr {
+	case "daily", "weekdays", "weekly", "monthly", "monthly_nth":
+	default:
+		b.addRepeatRule(chatID, user, args)
+		return
+	}
+
 	var repeatType domain.RepeatType
 	var timeStr, title string
 	var weekNum int
@@ -2077,6 +2392,39 @@ func (b *Bot) cmdAddRepeat(chatID int64, user *domain.User, args string) {
 	b.SendMessage(chatID, text)
 }
 
+// addRepeatRule creates a task repeating by a free-form rule
+// Usage: /addrepeat ПРАВИЛО ЧЧ:ММ Название (время отделяет правило от названия)
+func (b *Bot) addRepeatRule(chatID int64, user *domain.User, args string) {
+	fields := strings.Fields(args)
+	timeIdx := -1
+	for i, f := range fields {
+		if _, err := time.Parse("15:04", f); err == nil {
+			timeIdx = i
+			break
+		}
+	}
+	if timeIdx < 1 || timeIdx == len(fields)-1 {
+		b.SendMessage(chatID, "Формат: /addrepeat ПРАВИЛО ЧЧ:ММ Название\nПример: /addrepeat каждые 2 недели в пт 19:00 Полить цветы")
+		return
+	}
+
+	ruleText := strings.Join(fields[:timeIdx], " ")
+	timeStr := fields[timeIdx]
+	title := strings.Join(fields[timeIdx+1:], " ")
+
+	task, err := b.taskService.CreateWithRule(user.ID, chatID, title, domain.PriorityUrgent, nil, ruleText, timeStr)
+	if err != nil {
+		log.Printf("addRepeatRule: error: %v", err)
+		b.SendMessage(chatID, "❌ "+err.Error())
+		return
+	}
+	log.Printf("addRepeatRule: created repeating task %d with rule %s", task.ID, task.RRule)
+
+	text := fmt.Sprintf("✅ Создана повторяющаяся задача\n\n🔁 <b>#%d</b> %s\n⏰ %s (%s)\n📅 Первый раз: %s\n<code>%s</code>",
+		task.ID, task.Title, timeStr, domain.DescribeRRule(task.RRule), task.DueDate.Format("02.01.2006"), task.RRule)
+	b.SendMessage(chatID, text)
+}
+
 // cmdSeedAllnodes creates Allnodes status tasks
 func (b *Bot) cmdSeedAllnodes(chatID int64, user *domain.User) {
 	if user == nil {
@@ -2119,7 +2467,8 @@ func (b *Bot) cmdSeedAllnodes(chatID int64, user *domain.User) {
 	b.SendMessage(chatID, text)
 }
 
-// cmdChecklist shows a checklist by name
+// cmdChecklist shows the current run of a checklist by name (starts one if there is none);
+// /checklist history Название — completion rates of the last runs
 func (b *Bot) cmdChecklist(chatID int64, user *domain.User, args string) {
 	if user == nil {
 		b.SendMessage(chatID, "Сначала /start")
@@ -2130,16 +2479,27 @@ func (b *Bot) cmdChecklist(chatID int64, user *domain.User, args string) {
 		text := `<b>Показать чек-лист:</b>
 
 /checklist Название
+/checklist history Название — история прогонов
 
 <b>Примеры:</b>
 /checklist Тим
 /checklist Перед поездкой
+/checklist history Тим
 
 <b>Список чек-листов:</b> /checklists`
 		b.SendMessage(chatID, text)
 		return
 	}
 
+	history := false
+	if sub, rest, ok := strings.Cut(args, " "); ok {
+		switch strings.ToLower(sub) {
+		case "history", "история":
+			history = true
+			args = strings.TrimSpace(rest)
+		}
+	}
+
 	c, err := b.checklistService.GetByTitle(user.ID, args)
 	if err != nil {
 		log.Printf("cmdChecklist: error: %v", err)
@@ -2151,9 +2511,27 @@ func (b *Bot) cmdChecklist(chatID int64, user *domain.User, args string) {
 		return
 	}
 
-	text := b.checklistService.FormatChecklist(c)
-	kb := checklistKeyboard(c)
-	b.SendMessageWithKeyboard(chatID, text, kb)
+	if history {
+		text, err := b.checklistHistoryText(user.ID, c.ID)
+		if err != nil {
+			log.Printf("cmdChecklist: history: %v", err)
+			b.SendMessage(chatID, "❌ Ошибка: "+err.Error())
+			return
+		}
+		b.SendMessageWithKeyboard(chatID, text, checklistHistoryKeyboard(c.ID))
+		return
+	}
+
+	run, err := b.checklistService.CurrentRun(c.ID, user.ID)
+	if err != nil {
+		log.Printf("cmdChecklist: error: %v", err)
+		b.SendMessage(chatID, "❌ Ошибка: "+err.Error())
+		return
+	}
+
+	text := b.checklistService.FormatRun(run)
+	kb := checklistRunKeyboard(run, user.ID)
+	b.sendLive(chatID, user.ID, text, kb, domain.LiveChecklistRun, run.ID)
 }
 
 // cmdChecklists shows all checklists
@@ -2177,7 +2555,7 @@ func (b *Bot) cmdChecklists(chatID int64, user *domain.User) {
 		text += b.checklistService.FormatChecklistList(checklists)
 	}
 
-	kb := checklistsListKeyboard(checklists)
+	kb := checklistsListKeyboard(checklists, b.openChecklistRuns(checklists))
 	b.SendMessageWithKeyboard(chatID, text, kb)
 }
 
@@ -2189,47 +2567,29 @@ func (b *Bot) cmdAddChecklist(chatID int64, user *domain.User, args string) {
 	}
 
 	if args == "" {
-		text := `<b>Создать чек-лист:</b>
-
-/addchecklist Название
-пункт 1
-пункт 2
-пункт 3
-
-<b>Пример:</b>
-/addchecklist Тим
-Выспался ли он?
-Поел ли нормально?
-Какое настроение?
-Что говорит психолог?`
-		b.SendMessage(chatID, text)
+		b.startWizard(chatID, user, flowAddChecklist, nil)
 		return
 	}
 
 	// Parse: first line is title, rest are items
-	lines := strings.Split(args, "\n")
-	title := strings.TrimSpace(lines[0])
-
-	var items []string
-	for _, line := range lines[1:] {
-		line = strings.TrimSpace(line)
-		if line != "" {
-			items = append(items, line)
-		}
-	}
-
+	title, rest, _ := strings.Cut(args, "\n")
+	items := checklistItems(rest)
 	if len(items) == 0 {
 		b.SendMessage(chatID, "Добавь пункты (каждый на новой строке)")
 		return
 	}
 
+	b.addChecklist(chatID, user, strings.TrimSpace(title), items)
+}
+
+func (b *Bot) addChecklist(chatID int64, user *domain.User, title string, items []string) {
 	c, err := b.checklistService.Create(user.ID, title, items)
 	if err != nil {
-		log.Printf("cmdAddChecklist: error: %v", err)
+		log.Printf("addChecklist: error: %v", err)
 		b.SendMessage(chatID, "❌ Ошибка: "+err.Error())
 		return
 	}
-	log.Printf("cmdAddChecklist: created checklist %d", c.ID)
+	log.Printf("addChecklist: created checklist %d", c.ID)
 
 	text := fmt.Sprintf("✅ Чек-лист создан: <b>%s</b>\n\n%s", c.Title, b.checklistService.FormatChecklist(c))
 	kb := checklistKeyboard(c)
@@ -2816,8 +3176,8 @@ func (b *Bot) cmdPaid(chatID int64, user *domain.User, args string) {
 
 // cmdCalendar shows today's and tomorrow's events
 func (b *Bot) cmdCalendar(chatID int64, user *domain.User) {
-	if b.calendarService == nil || !b.calendarService.IsConfigured() {
-		b.SendMessage(chatID, "📆 Календарь не настроен\n\nДля настройки нужно указать Apple ID и app-specific password в конфигурации.")
+	if b.calendarService == nil {
+		b.SendMessage(chatID, "📆 Календарь не настроен")
 		return
 	}
 
@@ -2833,7 +3193,7 @@ func (b *Bot) cmdCalendar(chatID int64, user *domain.User) {
 	}
 
 	if len(events) == 0 {
-		b.SendMessage(chatID, "📆 На сегодня и завтра событий нет\n\n/calweek — на неделю\n/addevent — добавить")
+		b.SendMessage(chatID, "📆 На сегодня и завтра событий нет\n\n/calweek — на неделю\n/addevent — добавить\n/calendars — подключить календари")
 		return
 	}
 
@@ -2845,7 +3205,7 @@ func (b *Bot) cmdCalendar(chatID int64, user *domain.User) {
 
 // cmdCalendarWeek shows this week's events
 func (b *Bot) cmdCalendarWeek(chatID int64, user *domain.User) {
-	if b.calendarService == nil || !b.calendarService.IsConfigured() {
+	if b.calendarService == nil {
 		b.SendMessage(chatID, "📆 Календарь не настроен")
 		return
 	}
@@ -2879,85 +3239,77 @@ func (b *Bot) cmdAddEvent(chatID int64, user *domain.User, args string) {
 	}
 
 	if args == "" {
-		b.SendMessage(chatID, "Формат: /addevent <название> <дата> [время]\n\nПримеры:\n/addevent Встреча 25.01\n/addevent Созвон 25.01 14:00\n/addevent День рождения завтра")
+		b.startWizard(chatID, user, flowAddEvent, nil)
 		return
 	}
 
-	// Parse date from args
-	cleanText, dueDate := b.taskService.ParseDate(args)
-	if dueDate == nil {
-		b.SendMessage(chatID, "Не удалось распознать дату. Примеры:\n/addevent Встреча 25.01\n/addevent Созвон завтра 14:00")
+	// Дата, время и длительность: «завтра 14:00», «в пятницу с 10 до 12», «25.01 в 9 утра на 2 часа»
+	title, when := dates.Extract(args, time.Now())
+	if when == nil {
+		b.SendMessage(chatID, "Не удалось распознать дату. Примеры:\n/addevent Встреча 25.01\n/addevent Созвон завтра 14:00\n/addevent Врач в пятницу с 10 до 11")
 		return
 	}
 
-	title := strings.TrimSpace(cleanText)
 	if title == "" {
 		b.SendMessage(chatID, "Укажите название события")
 		return
 	}
 
-	// Parse time if present (look for HH:MM pattern)
-	allDay := true
-	timeRe := strings.NewReplacer(".", ":")
-	for _, word := range strings.Fields(args) {
-		if len(word) == 5 && word[2] == ':' {
-			if h, err := strconv.Atoi(word[:2]); err == nil && h >= 0 && h <= 23 {
-				if m, err := strconv.Atoi(word[3:]); err == nil && m >= 0 && m <= 59 {
-					*dueDate = time.Date(dueDate.Year(), dueDate.Month(), dueDate.Day(), h, m, 0, 0, dueDate.Location())
-					allDay = false
-				title = strings.Replace(title, word, "", 1)
-				title = strings.TrimSpace(title)
-				}
-			}
-		}
-		// Also try HH.MM format
-		normalized := timeRe.Replace(word)
-		if normalized != word && len(normalized) == 5 && normalized[2] == ':' {
-			if h, err := strconv.Atoi(normalized[:2]); err == nil && h >= 0 && h <= 23 {
-				if m, err := strconv.Atoi(normalized[3:]); err == nil && m >= 0 && m <= 59 {
-					*dueDate = time.Date(dueDate.Year(), dueDate.Month(), dueDate.Day(), h, m, 0, 0, dueDate.Location())
-					allDay = false
-				title = strings.Replace(title, word, "", 1)
-				title = strings.TrimSpace(title)
-				}
-			}
-		}
-	}
+	b.addCalendarEvent(chatID, user, title, when)
+}
 
-	// Calculate end time (1 hour after start for non-all-day events)
+// addCalendarEvent creates the event from the parsed date and time
+func (b *Bot) addCalendarEvent(chatID int64, user *domain.User, title string, when *dates.Result) {
+	// Без времени — событие на весь день; без длительности — на час
+	allDay := !when.HasTime
 	var endTime time.Time
 	if !allDay {
-		endTime = dueDate.Add(time.Hour)
+		endTime = when.Time.Add(time.Hour)
+		if when.Duration > 0 {
+			endTime = when.Until()
+		}
 	}
 
-	event, err := b.calendarService.CreateEvent(user.ID, title, *dueDate, endTime, "", allDay)
+	event, err := b.calendarService.CreateEvent(user.ID, title, when.Time, endTime, "", allDay)
 	if err != nil {
-		log.Printf("cmdAddEvent: error: %v", err)
+		log.Printf("addCalendarEvent: error: %v", err)
 		b.SendMessage(chatID, "❌ Ошибка создания события: "+err.Error())
 		return
 	}
-	log.Printf("cmdAddEvent: created event %s", event.ID)
+	log.Printf("addCalendarEvent: created event %d", event.ID)
 
 	text := fmt.Sprintf("✅ Событие создано:\n\n📆 %s\n%s", event.Title, event.FormatDateTime())
 	if event.CalDAVUID != "" {
-		text += "\n\n☁️ Синхронизировано с Apple Calendar"
+		text += "\n\n☁️ Записано в календарь"
 	}
 
 	b.SendMessage(chatID, text)
 }
 
-// cmdSyncApple triggers manual sync with Apple Calendar
-func (b *Bot) cmdSyncApple(chatID int64, user *domain.User) {
-	if b.calendarService == nil || !b.calendarService.IsConfigured() {
-		b.SendMessage(chatID, "📆 Apple Calendar не настроен\n\nДля настройки укажите CALDAV_USERNAME и CALDAV_PASSWORD")
+// cmdSyncApple triggers manual sync of the user's linked calendars
+func (b *Bot) cmdSyncApple(chatID int64, user *domain.User, args string) {
+	if b.calendarService == nil {
+		b.SendMessage(chatID, "📆 Календарь не настроен")
 		return
 	}
+	calendars, err := b.calendarService.Calendars(user.ID)
+	if err != nil || len(calendars) == 0 {
+		b.SendMessage(chatID, "📆 Календари не подключены\n\n/calendars — подключить iCloud, Nextcloud, Fastmail или Radicale")
+		return
+	}
+
+	// /syncapple full — забыть sync-token и скачать календари целиком
+	if args == "full" {
+		if err := b.calendarService.ResetSync(user.ID); err != nil {
+			log.Printf("cmdSyncApple: reset error: %v", err)
+		}
+	}
 
-	b.SendMessage(chatID, "🔄 Синхронизация с Apple Calendar...")
+	b.SendMessage(chatID, "🔄 Синхронизация календарей...")
 	log.Printf("cmdSyncApple: starting sync for user %d", user.ID)
 
-	// Sync calendar events FROM Apple
-	result, err := b.calendarService.SyncFromApple()
+	// Sync calendar events FROM the calendars
+	result, err := b.calendarService.SyncUser(user.ID)
 	if err != nil {
 		log.Printf("cmdSyncApple: error: %v", err)
 		b.SendMessage(chatID, "❌ Ошибка синхронизации событий: "+err.Error())
@@ -2965,7 +3317,7 @@ func (b *Bot) cmdSyncApple(chatID int64, user *domain.User) {
 	}
 	log.Printf("cmdSyncApple: synced events: added=%d, updated=%d, deleted=%d", result.Added, result.Updated, result.Deleted)
 
-	// Sync weekly schedule events TO Apple
+	// Sync weekly schedule events TO the two-way calendar
 	scheduleSynced := 0
 	if b.scheduleService != nil {
 		events, err := b.scheduleService.List(user.ID, true)
@@ -2986,13 +3338,16 @@ func (b *Bot) cmdSyncApple(chatID int64, user *domain.User) {
 
 	var sb strings.Builder
 	sb.WriteString("✅ Синхронизация завершена!\n\n")
-	sb.WriteString("<b>📅 Из Apple:</b>\n")
+	sb.WriteString(fmt.Sprintf("<b>📅 Из календарей (%d):</b>\n", len(calendars)))
 	sb.WriteString(fmt.Sprintf("  ➕ Добавлено: %d\n", result.Added))
 	sb.WriteString(fmt.Sprintf("  🔄 Обновлено: %d\n", result.Updated))
 	sb.WriteString(fmt.Sprintf("  🗑 Удалено: %d\n", result.Deleted))
+	if len(result.Conflicts) > 0 {
+		sb.WriteString(fmt.Sprintf("  ⚠️ Конфликтов: %d — /calendars conflicts\n", len(result.Conflicts)))
+	}
 
 	if scheduleSynced > 0 {
-		sb.WriteString(fmt.Sprintf("\n<b>🗓 В Apple (расписание):</b>\n"))
+		sb.WriteString(fmt.Sprintf("\n<b>🗓 В календарь (расписание):</b>\n"))
 		sb.WriteString(fmt.Sprintf("  📤 Синхронизировано: %d\n", scheduleSynced))
 	}
 
@@ -3001,40 +3356,7 @@ func (b *Bot) cmdSyncApple(chatID int64, user *domain.User) {
 	}
 
 	b.SendMessage(chatID, sb.String())
-}
-
-// cmdCalendars shows available calendars (for setup)
-func (b *Bot) cmdCalendars(chatID int64, user *domain.User) {
-	if b.calendarService == nil || !b.calendarService.IsConfigured() {
-		b.SendMessage(chatID, "📆 Apple Calendar не настроен")
-		return
-	}
-
-	b.SendMessage(chatID, "🔍 Поиск календарей...")
-
-	calendars, err := b.calendarService.DiscoverCalendars()
-	if err != nil {
-		log.Printf("cmdCalendars: error: %v", err)
-		b.SendMessage(chatID, "❌ Ошибка: "+err.Error())
-		return
-	}
-
-	if len(calendars) == 0 {
-		b.SendMessage(chatID, "Календари не найдены")
-		return
-	}
-
-	var sb strings.Builder
-	sb.WriteString("📆 <b>Доступные календари:</b>\n\n")
-
-	for i, cal := range calendars {
-		sb.WriteString(fmt.Sprintf("%d. <b>%s</b>\n", i+1, cal.DisplayName))
-		sb.WriteString(fmt.Sprintf("   ID: <code>%s</code>\n\n", cal.ID))
-	}
-
-	sb.WriteString("Укажите CALDAV_CALENDAR_ID в конфигурации для синхронизации с конкретным календарём")
-
-	b.SendMessage(chatID, sb.String())
+	b.notifyCalendarConflicts(result.Conflicts)
 }
 
 // ================== Todoist Commands ================== 
@@ -3105,7 +3427,7 @@ func (b *Bot) cmdChatID(chatID int64, msg *tgbotapi.Message) {
 
 // cmdQuote sends a daily relationship quote immediately
 func (b *Bot) cmdQuote(chatID int64) {
-	quote := domain.GetDailyQuote()
+	quote := domain.GetDailyQuote(time.Now())
 	var message string
 	if quote.Author != "" {
 		message = fmt.Sprintf("💕 <b>Цитата дня о любви</b>\n\n<i>\"%s\"</i>\n\n— %s", quote.Text, quote.Author)
@@ -0,0 +1,290 @@
+package bot
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"html"
+	"log"
+	"net/http"
+	"path"
+	"strings"
+
+	"github.com/emersion/go-ical"
+	"github.com/emersion/go-webdav"
+	"github.com/emersion/go-webdav/caldav"
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"github.com/tazhate/familybot/internal/domain"
+	"github.com/tazhate/familybot/internal/service"
+)
+
+// Подписка на календарь семьи без пароля iCloud: /ical/<token>.ics для любого
+// приложения календаря и CalDAV только для чтения (/caldav/, пароль — тот же токен),
+// чтобы телефон добавил календарь как аккаунт. Ссылку выдаёт /feed.
+
+const (
+	feedCalDAVPrefix  = "/caldav"
+	feedPrincipalPath = feedCalDAVPrefix + "/me/"
+	feedHomeSetPath   = feedPrincipalPath + "calendars/"
+	feedCalendarPath  = feedHomeSetPath + "familybot/"
+	feedCalDAVRealm   = `Basic realm="FamilyBot CalDAV"`
+)
+
+// SetupFeed registers the ICS feed and the read-only CalDAV server; the token in
+// the URL or in the password is the access
+func (b *Bot) SetupFeed() {
+	if b.feedService == nil {
+		return
+	}
+	http.HandleFunc("/ical/", b.serveICalFeed)
+	http.Handle(feedCalDAVPrefix+"/", b.feedCalDAV())
+	http.HandleFunc("/.well-known/caldav", func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, feedCalDAVPrefix+"/", http.StatusMovedPermanently)
+	})
+}
+
+// feedCalDAV returns the read-only CalDAV server behind the token check
+func (b *Bot) feedCalDAV() http.Handler {
+	return b.feedAuth(&caldav.Handler{Backend: &feedBackend{feeds: b.feedService}, Prefix: feedCalDAVPrefix})
+}
+
+// GET /ical/{token}.ics - the user's feed as one calendar file
+func (b *Bot) serveICalFeed(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	token, ok := strings.CutSuffix(strings.TrimPrefix(r.URL.Path, "/ical/"), ".ics")
+	if !ok || strings.Contains(token, "/") {
+		http.NotFound(w, r)
+		return
+	}
+	userID, err := b.feedService.UserByToken(token)
+	if errors.Is(err, service.ErrFeedDisabled) {
+		// Не подсказываем, существовала ли такая ссылка
+		http.NotFound(w, r)
+		return
+	}
+	if err != nil {
+		log.Printf("ical feed: %v", err)
+		http.Error(w, "Internal error", http.StatusInternalServerError)
+		return
+	}
+
+	cal, err := b.feedService.FeedCalendar(userID)
+	if err != nil {
+		log.Printf("ical feed: user %d: %v", userID, err)
+		http.Error(w, "Internal error", http.StatusInternalServerError)
+		return
+	}
+	var buf bytes.Buffer
+	if err := ical.NewEncoder(&buf).Encode(cal); err != nil {
+		log.Printf("ical feed: user %d: encode: %v", userID, err)
+		http.Error(w, "Internal error", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/calendar; charset=utf-8")
+	w.Header().Set("Content-Disposition", `inline; filename="familybot.ics"`)
+	w.Header().Set("Cache-Control", "private, max-age=300")
+	if r.Method == http.MethodGet {
+		w.Write(buf.Bytes())
+	}
+}
+
+type feedUserKey struct{}
+
+// feedAuth checks Basic Auth for CalDAV: any login, the feed token as the password
+func (b *Bot) feedAuth(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, token, ok := r.BasicAuth()
+		if !ok {
+			w.Header().Set("WWW-Authenticate", feedCalDAVRealm)
+			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+		userID, err := b.feedService.UserByToken(token)
+		if errors.Is(err, service.ErrFeedDisabled) {
+			w.Header().Set("WWW-Authenticate", feedCalDAVRealm)
+			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+		if err != nil {
+			log.Printf("caldav feed: %v", err)
+			http.Error(w, "Internal error", http.StatusInternalServerError)
+			return
+		}
+		next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), feedUserKey{}, userID)))
+	})
+}
+
+// feedBackend implements caldav.Backend over the user's feed: one calendar, read-only
+type feedBackend struct {
+	feeds *service.FeedService
+}
+
+var _ caldav.Backend = (*feedBackend)(nil)
+
+var errFeedReadOnly = webdav.NewHTTPError(http.StatusForbidden, errors.New("familybot: calendar is read-only"))
+
+func (fb *feedBackend) CurrentUserPrincipal(ctx context.Context) (string, error) {
+	return feedPrincipalPath, nil
+}
+
+func (fb *feedBackend) CalendarHomeSetPath(ctx context.Context) (string, error) {
+	return feedHomeSetPath, nil
+}
+
+func (fb *feedBackend) CreateCalendar(ctx context.Context, calendar *caldav.Calendar) error {
+	return errFeedReadOnly
+}
+
+func (fb *feedBackend) ListCalendars(ctx context.Context) ([]caldav.Calendar, error) {
+	return []caldav.Calendar{feedCalendar()}, nil
+}
+
+func (fb *feedBackend) GetCalendar(ctx context.Context, p string) (*caldav.Calendar, error) {
+	if path.Clean(p) != path.Clean(feedCalendarPath) {
+		return nil, webdav.NewHTTPError(http.StatusNotFound, fmt.Errorf("familybot: no calendar %s", p))
+	}
+	cal := feedCalendar()
+	return &cal, nil
+}
+
+func feedCalendar() caldav.Calendar {
+	return caldav.Calendar{
+		Path:                  feedCalendarPath,
+		Name:                  "FamilyBot",
+		Description:           "Расписание, задачи, дни рождения и сроки по машинам",
+		SupportedComponentSet: []string{ical.CompEvent},
+	}
+}
+
+func (fb *feedBackend) GetCalendarObject(ctx context.Context, p string, req *caldav.CalendarCompRequest) (*caldav.CalendarObject, error) {
+	objects, err := fb.objects(ctx)
+	if err != nil {
+		return nil, err
+	}
+	for i := range objects {
+		if objects[i].Path == p {
+			return &objects[i], nil
+		}
+	}
+	return nil, webdav.NewHTTPError(http.StatusNotFound, fmt.Errorf("familybot: no object %s", p))
+}
+
+func (fb *feedBackend) ListCalendarObjects(ctx context.Context, p string, req *caldav.CalendarCompRequest) ([]caldav.CalendarObject, error) {
+	if path.Clean(p) != path.Clean(feedCalendarPath) {
+		return nil, webdav.NewHTTPError(http.StatusNotFound, fmt.Errorf("familybot: no calendar %s", p))
+	}
+	return fb.objects(ctx)
+}
+
+func (fb *feedBackend) QueryCalendarObjects(ctx context.Context, p string, query *caldav.CalendarQuery) ([]caldav.CalendarObject, error) {
+	objects, err := fb.ListCalendarObjects(ctx, p, &query.CompRequest)
+	if err != nil {
+		return nil, err
+	}
+	return caldav.Filter(query, objects)
+}
+
+func (fb *feedBackend) PutCalendarObject(ctx context.Context, p string, cal *ical.Calendar, opts *caldav.PutCalendarObjectOptions) (*caldav.CalendarObject, error) {
+	return nil, errFeedReadOnly
+}
+
+func (fb *feedBackend) DeleteCalendarObject(ctx context.Context, p string) error {
+	return errFeedReadOnly
+}
+
+// objects returns every feed event as its own calendar object; the ETag is the hash of
+// the content, so clients download only what changed
+func (fb *feedBackend) objects(ctx context.Context) ([]caldav.CalendarObject, error) {
+	userID, ok := ctx.Value(feedUserKey{}).(int64)
+	if !ok {
+		return nil, webdav.NewHTTPError(http.StatusUnauthorized, errors.New("familybot: no feed user"))
+	}
+	events, err := fb.feeds.Events(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	objects := make([]caldav.CalendarObject, 0, len(events))
+	for _, e := range events {
+		cal := fb.feeds.Calendar([]*ical.Event{e})
+		var buf bytes.Buffer
+		if err := ical.NewEncoder(&buf).Encode(cal); err != nil {
+			return nil, fmt.Errorf("encode %s: %w", service.FeedObjectName(e), err)
+		}
+		sum := sha256.Sum256(buf.Bytes())
+		objects = append(objects, caldav.CalendarObject{
+			Path:          feedCalendarPath + service.FeedObjectName(e),
+			ContentLength: int64(buf.Len()),
+			ETag:          hex.EncodeToString(sum[:16]),
+			Data:          cal,
+		})
+	}
+	return objects, nil
+}
+
+// cmdFeed shows the link to subscribe to the family calendar:
+// /feed, /feed new — заменить ссылку, /feed off — выключить
+func (b *Bot) cmdFeed(msg *tgbotapi.Message, user *domain.User, args string) {
+	chatID := msg.Chat.ID
+	if user == nil {
+		b.SendMessage(chatID, "Сначала /start")
+		return
+	}
+	if b.feedService == nil {
+		b.SendMessage(chatID, "📆 Подписка на календарь не настроена")
+		return
+	}
+	// Ссылка — это доступ к календарю, в общий чат её не показываем
+	if !msg.Chat.IsPrivate() {
+		b.SendMessage(chatID, "🔒 Ссылку на календарь пришлю только в личном чате с ботом")
+		return
+	}
+
+	var feed *domain.CalendarFeed
+	var err error
+	switch strings.ToLower(args) {
+	case "":
+		feed, err = b.feedService.Feed(user.ID)
+	case "new", "новая", "заменить":
+		feed, err = b.feedService.Rotate(user.ID)
+	case "off", "выкл", "выключить":
+		if err := b.feedService.Disable(user.ID); err != nil {
+			log.Printf("cmdFeed: disable: %v", err)
+			b.SendMessage(chatID, "❌ Не удалось выключить подписку")
+			return
+		}
+		b.SendMessage(chatID, "📆 Подписка выключена, прежняя ссылка больше не работает\n\n/feed — новая ссылка")
+		return
+	default:
+		b.SendMessage(chatID, "Формат: /feed, /feed new — новая ссылка, /feed off — выключить")
+		return
+	}
+	if err != nil {
+		log.Printf("cmdFeed: %v", err)
+		b.SendMessage(chatID, "❌ Не удалось получить ссылку")
+		return
+	}
+
+	base := strings.TrimSuffix(b.cfg.WebhookURL, "/")
+	icsURL := base + "/ical/" + feed.Token + ".ics"
+	webcalURL := "webcal://" + strings.TrimPrefix(strings.TrimPrefix(icsURL, "https://"), "http://")
+
+	var sb strings.Builder
+	sb.WriteString("📆 <b>Подписка на календарь семьи</b>\n")
+	sb.WriteString("Расписание, подтверждённые плавающие события, задачи со сроком, дни рождения и сроки по машинам\n\n")
+	sb.WriteString(fmt.Sprintf("<b>Ссылка для подписки:</b>\n<code>%s</code>\n", html.EscapeString(icsURL)))
+	sb.WriteString(fmt.Sprintf("<i>Apple Календарь, Google Календарь («Добавить по URL»), Outlook; на iPhone можно открыть %s</i>\n\n", html.EscapeString(webcalURL)))
+	sb.WriteString("<b>CalDAV-аккаунт</b> (только чтение):\n")
+	sb.WriteString(fmt.Sprintf("Сервер: <code>%s</code>\n", html.EscapeString(base+feedCalDAVPrefix+"/")))
+	sb.WriteString("Логин: любой\n")
+	sb.WriteString(fmt.Sprintf("Пароль: <code>%s</code>\n\n", feed.Token))
+	sb.WriteString("🔒 Кто знает ссылку, видит календарь.\n/feed new — заменить ссылку, /feed off — выключить")
+	b.SendMessage(chatID, sb.String())
+}
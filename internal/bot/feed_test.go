@@ -0,0 +1,176 @@
+package bot
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/tazhate/familybot/internal/clients/caldav"
+	"github.com/tazhate/familybot/internal/domain"
+	"github.com/tazhate/familybot/internal/service"
+)
+
+// feedServer serves the ICS feed and the CalDAV server of the test bot. The feed is in
+// Europe/Moscow: in production the zone comes from the IANA database, and the CalDAV
+// filter does not know the TZID of a fixed zone.
+func (tb *testBot) feedServer() *httptest.Server {
+	tb.t.Helper()
+	moscow, err := time.LoadLocation("Europe/Moscow")
+	if err != nil {
+		tb.t.Fatal(err)
+	}
+	tb.bot.feedService = service.NewFeedService(tb.store, moscow)
+	tb.bot.feedService.SetClock(tb.clock)
+	mux := http.NewServeMux()
+	mux.HandleFunc("/ical/", tb.bot.serveICalFeed)
+	mux.Handle(feedCalDAVPrefix+"/", tb.bot.feedCalDAV())
+	server := httptest.NewServer(mux)
+	tb.t.Cleanup(server.Close)
+	return server
+}
+
+// feedTask creates the user's task due on the day
+func (tb *testBot) feedTask(user *domain.User, title string, due time.Time) {
+	tb.t.Helper()
+	task := &domain.Task{UserID: user.ID, ChatID: user.TelegramID, Title: title, Priority: domain.PriorityWeek, DueDate: &due}
+	if err := tb.store.CreateTask(task); err != nil {
+		tb.t.Fatal(err)
+	}
+}
+
+func TestFeedICS(t *testing.T) {
+	tb := newTestBot(t)
+	owner, partner := tb.family()
+	tb.feedTask(owner, "Оплатить садик", time.Date(2030, time.June, 10, 15, 0, 0, 0, testLocation))
+	tb.feedTask(partner, "Сюрприз", time.Date(2030, time.June, 12, 0, 0, 0, 0, testLocation))
+	server := tb.feedServer()
+
+	// Ссылку выдаёт /feed
+	tb.send(100, "/feed")
+	feed, err := tb.bot.feedService.Feed(owner.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := lastText(tb.texts()); !strings.Contains(got, "/ical/"+feed.Token+".ics") {
+		t.Errorf("/feed answered %q", got)
+	}
+
+	get := func(method, path string) (int, string) {
+		t.Helper()
+		req, err := http.NewRequest(method, server.URL+path, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer resp.Body.Close()
+		body, _ := io.ReadAll(resp.Body)
+		if resp.StatusCode == http.StatusOK && !strings.HasPrefix(resp.Header.Get("Content-Type"), "text/calendar") {
+			t.Errorf("%s %s: Content-Type %q", method, path, resp.Header.Get("Content-Type"))
+		}
+		return resp.StatusCode, string(body)
+	}
+
+	status, body := get(http.MethodGet, "/ical/"+feed.Token+".ics")
+	if status != http.StatusOK {
+		t.Fatalf("feed: %d %s", status, body)
+	}
+	for _, want := range []string{"X-WR-CALNAME:FamilyBot", "BEGIN:VTIMEZONE", "SUMMARY:📋 Оплатить садик", "DTSTART;TZID=Europe/Moscow:20300610T150000"} {
+		if !strings.Contains(body, want) {
+			t.Errorf("feed has no %q:\n%s", want, body)
+		}
+	}
+	if strings.Contains(body, "Сюрприз") {
+		t.Error("partner's private task in the feed")
+	}
+	if status, body := get(http.MethodHead, "/ical/"+feed.Token+".ics"); status != http.StatusOK || body != "" {
+		t.Errorf("HEAD: %d %q", status, body)
+	}
+
+	// Неизвестная, заменённая и выключенная ссылки не отличаются друг от друга
+	rotated, err := tb.bot.feedService.Rotate(owner.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, path := range []string{"/ical/" + feed.Token + ".ics", "/ical/nope.ics", "/ical/" + rotated.Token, "/ical/x/" + rotated.Token + ".ics"} {
+		if status, _ := get(http.MethodGet, path); status != http.StatusNotFound {
+			t.Errorf("GET %s: %d", path, status)
+		}
+	}
+	if status, _ := get(http.MethodPost, "/ical/"+rotated.Token+".ics"); status != http.StatusMethodNotAllowed {
+		t.Errorf("POST: %d", status)
+	}
+	tb.send(100, "/feed off")
+	if status, _ := get(http.MethodGet, "/ical/"+rotated.Token+".ics"); status != http.StatusNotFound {
+		t.Errorf("disabled feed: %d", status)
+	}
+}
+
+func TestFeedCalDAV(t *testing.T) {
+	tb := newTestBot(t)
+	owner, _ := tb.family()
+	due := time.Date(2030, time.June, 10, 15, 0, 0, 0, testLocation)
+	tb.feedTask(owner, "Оплатить садик", due)
+	server := tb.feedServer()
+	feed, err := tb.bot.feedService.Feed(owner.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Без пароля и с чужим паролем — одинаковый отказ
+	for _, password := range []string{"", "wrong"} {
+		req, err := http.NewRequest("PROPFIND", server.URL+feedCalDAVPrefix+"/", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if password != "" {
+			req.SetBasicAuth("phone", password)
+		}
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusUnauthorized || resp.Header.Get("WWW-Authenticate") != feedCalDAVRealm {
+			t.Errorf("password %q: %d, %q", password, resp.StatusCode, resp.Header.Get("WWW-Authenticate"))
+		}
+	}
+
+	// Логин любой, пароль — токен подписки
+	client := caldav.NewClient(server.URL+feedCalDAVPrefix+"/", "phone", feed.Token)
+	calendars, err := client.DiscoverCalendars()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(calendars) != 1 || calendars[0].ID != feedCalendarPath || calendars[0].DisplayName != "FamilyBot" {
+		t.Fatalf("calendars %+v", calendars)
+	}
+	events, err := client.GetEvents(feedCalendarPath, due.AddDate(0, 0, -1), due.AddDate(0, 0, 1))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(events) != 1 || events[0].Summary != "📋 Оплатить садик" || !events[0].StartTime.Equal(due) {
+		t.Errorf("events %+v", events)
+	}
+
+	// Только чтение
+	event := &caldav.Event{UID: "phone-event", Summary: "С телефона", StartTime: due, EndTime: due.Add(time.Hour)}
+	if err := client.CreateEvent(feedCalendarPath, event); err == nil {
+		t.Error("event created in the read-only calendar")
+	}
+	if err := client.DeleteEvent(feedCalendarPath, events[0].UID); err == nil {
+		t.Error("event deleted from the read-only calendar")
+	}
+
+	if _, err := tb.bot.feedService.Rotate(owner.ID); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := client.DiscoverCalendars(); err == nil {
+		t.Error("old token still opens CalDAV")
+	}
+}
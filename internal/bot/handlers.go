@@ -2,6 +2,7 @@ package bot
 
 import (
 	"fmt"
+	"html"
 	"log"
 	"strconv"
 	"strings"
@@ -16,6 +17,10 @@ func (b *Bot) handleUpdate(update tgbotapi.Update) {
 		b.handleMessage(update.Message)
 	} else if update.CallbackQuery != nil {
 		b.handleCallback(update.CallbackQuery)
+	} else if update.InlineQuery != nil {
+		b.handleInlineQuery(update.InlineQuery)
+	} else if update.ChosenInlineResult != nil {
+		b.handleChosenInlineResult(update.ChosenInlineResult)
 	}
 }
 
@@ -23,7 +28,12 @@ func (b *Bot) handleMessage(msg *tgbotapi.Message) {
 	userID := msg.From.ID
 	chatID := msg.Chat.ID
 
-	if !b.cfg.IsAllowedUser(userID) {
+	if !b.isAllowed(userID) {
+		// Вход по приглашению: /join КОД или ссылка t.me/bot?start=КОД
+		if msg.IsCommand() && (msg.Command() == "join" || msg.Command() == "start") && msg.CommandArguments() != "" {
+			b.cmdJoin(msg)
+			return
+		}
 		log.Printf("handleMessage: unauthorized access attempt from user %d", userID)
 		b.SendMessage(chatID, "⛔ Доступ запрещён")
 		return
@@ -40,11 +50,35 @@ func (b *Bot) handleMessage(msg *tgbotapi.Message) {
 		user = b.autoRegisterUser(msg.From)
 	}
 
+	if msg.Voice != nil {
+		b.handleVoice(chatID, user, msg.Voice)
+		return
+	}
+	if len(msg.Photo) > 0 || msg.Document != nil {
+		b.handleAttachment(msg, user)
+		return
+	}
+
 	text := strings.TrimSpace(msg.Text)
 	if text == "" {
 		return
 	}
 
+	// Активный мастер: текст — ответ на его вопрос, команда или кнопка меню его прерывает
+	conv, expired := b.conversation(chatID, user)
+	if expired && !msg.IsCommand() {
+		return
+	}
+	if conv != nil {
+		if !msg.IsCommand() && !menuButtons[text] {
+			b.wizardInput(chatID, user, conv, text)
+			return
+		}
+		if msg.Command() != "cancel" {
+			b.endConversation(chatID, user)
+		}
+	}
+
 	if msg.IsCommand() {
 		b.handleCommand(msg, user)
 		return
@@ -59,6 +93,10 @@ func (b *Bot) handleMessage(msg *tgbotapi.Message) {
 		b.cmdToday(chatID, user)
 		return
 	case "➕ Добавить":
+		if ok, reason := b.commandAllowed(user, "add"); !ok {
+			b.SendMessage(chatID, reason)
+			return
+		}
 		b.cmdAdd(chatID, user, "")
 		return
 	case "🗓 Расписание":
@@ -72,12 +110,10 @@ func (b *Bot) handleMessage(msg *tgbotapi.Message) {
 		return
 	}
 
-	// Добавление задачи текстом — показываем выбор приоритета
-	if user != nil {
+	// Добавление задачи текстом — мастер сразу спрашивает приоритет
+	if user != nil && b.householdRole(user).CanWrite() {
 		log.Printf("handleMessage: text task prompt for user %d: %q", user.ID, text)
-		b.SetPendingTask(chatID, text)
-		kb := priorityKeyboard()
-		b.SendMessageWithKeyboard(chatID, "Выбери приоритет для задачи:\n\n<b>"+text+"</b>", kb)
+		b.startWizard(chatID, user, flowAddTask, map[string]string{"title": text})
 	}
 }
 
@@ -105,6 +141,7 @@ func (b *Bot) autoRegisterUser(from *tgbotapi.User) *domain.User {
 	}
 
 	log.Printf("Auto-registered user: %s (ID: %d)", name, from.ID)
+	b.bootstrapHousehold()
 	return newUser
 }
 
@@ -113,7 +150,7 @@ func (b *Bot) handleCallback(callback *tgbotapi.CallbackQuery) {
 	chatID := callback.Message.Chat.ID
 	msgID := callback.Message.MessageID
 
-	if !b.cfg.IsAllowedUser(userID) {
+	if !b.isAllowed(userID) {
 		b.api.Request(tgbotapi.NewCallback(callback.ID, "⛔ Доступ запрещён"))
 		return
 	}
@@ -132,52 +169,20 @@ func (b *Bot) handleCallback(callback *tgbotapi.CallbackQuery) {
 
 	parts := strings.Split(data, ":")
 
-	switch parts[0] {
-	case "setpri":
-		// setpri:priority (title from pendingTasks)
-		if len(parts) < 2 {
-			return
-		}
-		priority := domain.Priority(parts[1])
-		title, ok := b.GetPendingTask(chatID)
-		if !ok || title == "" {
-			b.api.Request(tgbotapi.NewCallback(callback.ID, "❌ Текст задачи не найден, попробуй снова"))
-			return
-		}
-
-		// Парсим @упоминания
-		cleanText, mentions := b.taskService.ParseMentions(title)
-		var personID *int64
-		for _, mention := range mentions {
-			person, _ := b.personService.GetByName(user.ID, mention)
-			if person != nil {
-				personID = &person.ID
-				break
-			}
-		}
-
-		// Парсим дату из текста
-		cleanText, dueDate := b.taskService.ParseDate(cleanText)
+	if !b.callbackAllowed(user, parts[0]) {
+		b.api.Request(tgbotapi.NewCallback(callback.ID, "⛔ Только просмотр"))
+		return
+	}
 
-		task, err := b.taskService.CreateFull(user.ID, chatID, cleanText, priority, personID, dueDate)
-		if err != nil {
-			log.Printf("callback setpri: error creating task: %v", err)
-			b.api.Request(tgbotapi.NewCallback(callback.ID, "❌ "+err.Error()))
-			return
-		}
-		log.Printf("callback setpri: created task %d", task.ID)
+	// Экран, который нарисует колбэк, снова отметит сообщение, если оно живое
+	b.untrackMessage(chatID, msgID)
 
-		b.api.Request(tgbotapi.NewCallback(callback.ID, "✅ Задача создана!"))
+	switch parts[0] {
+	case "settings":
+		b.handleSettingsCallback(callback, user, parts[1:])
 
-		text := fmt.Sprintf("✅ Задача добавлена\n\n%s <b>#%d</b> %s", task.PriorityEmoji(), task.ID, task.Title)
-		if task.DueDate != nil {
-			text += fmt.Sprintf("\n📅 %s", task.DueDate.Format("02.01.2006"))
-		}
-		kb := taskKeyboard(task.ID)
-		edit := tgbotapi.NewEditMessageText(chatID, msgID, text)
-		edit.ParseMode = "HTML"
-		edit.ReplyMarkup = &kb
-		b.api.Send(edit)
+	case "wiz":
+		b.handleWizardCallback(callback, user, parts[1:])
 
 	case "done":
 		if len(parts) < 2 {
@@ -197,6 +202,8 @@ func (b *Bot) handleCallback(callback *tgbotapi.CallbackQuery) {
 		}
 		b.api.Request(tgbotapi.NewCallback(callback.ID, "✅ Выполнено!"))
 		b.refreshTaskList(chatID, msgID, user.ID)
+		b.refreshSharedIfShared(user, taskID)
+		b.offerParentDone(chatID, taskID)
 
 	case "done_today":
 		if len(parts) < 2 {
@@ -216,6 +223,8 @@ func (b *Bot) handleCallback(callback *tgbotapi.CallbackQuery) {
 		}
 		b.api.Request(tgbotapi.NewCallback(callback.ID, "✅ Выполнено!"))
 		b.showToday(chatID, msgID, user.ID)
+		b.refreshSharedIfShared(user, taskID)
+		b.offerParentDone(chatID, taskID)
 
 	case "del":
 		if len(parts) < 2 {
@@ -269,6 +278,7 @@ func (b *Bot) handleCallback(callback *tgbotapi.CallbackQuery) {
 		log.Printf("callback share: task %d shared", taskID)
 
 		b.api.Request(tgbotapi.NewCallback(callback.ID, "👨‍👩‍👧 Задача стала общей!"))
+		b.refreshSharedTasks(user, 0, 0)
 
 		task, _ := b.storage.GetTask(taskID)
 		if task != nil {
@@ -336,7 +346,10 @@ func (b *Bot) handleCallback(callback *tgbotapi.CallbackQuery) {
 			return
 		}
 		taskID := atoi(parts[1])
-		task, _ := b.storage.GetTask(taskID)
+		task, err := b.taskService.GetVisible(taskID, user.ID, chatID)
+		if err != nil {
+			log.Printf("callback view: error: %v", err)
+		}
 		if task == nil {
 			b.api.Request(tgbotapi.NewCallback(callback.ID, "Задача не найдена"))
 			return
@@ -350,8 +363,11 @@ func (b *Bot) handleCallback(callback *tgbotapi.CallbackQuery) {
 		}
 		text := fmt.Sprintf("%s <b>#%d</b>\n\n%s\n\nСтатус: %s\nПриоритет: %s",
 			task.PriorityEmoji(), task.ID, task.Title, status, task.Priority)
+		text += b.formatTaskRelations(task)
+		attachments, attachmentsText := b.attachments(domain.AttachmentTask, task.ID)
+		text += attachmentsText
 
-		kb := viewTaskKeyboard(task)
+		kb := viewTaskKeyboard(task, attachments)
 		edit := tgbotapi.NewEditMessageText(chatID, msgID, text)
 		edit.ParseMode = "HTML"
 		edit.ReplyMarkup = &kb
@@ -498,21 +514,11 @@ func (b *Bot) handleCallback(callback *tgbotapi.CallbackQuery) {
 
 	case "add":
 		b.api.Request(tgbotapi.NewCallback(callback.ID, ""))
-		b.SendMessage(chatID, "Напиши текст задачи:")
+		b.startWizard(chatID, user, flowAddTask, nil)
 
 	case "add_weekly":
 		b.api.Request(tgbotapi.NewCallback(callback.ID, ""))
-		text := `<b>Добавить регулярное событие:</b>
-
-/addweekly День Время Название
-
-<b>Примеры:</b>
-/addweekly Пн 17:30 Федя спорт
-/addweekly Ср 16:00-20:00 Тим плавание
-/addweekly Сб 10:00 Шахматы
-
-<b>Дни:</b> Пн, Вт, Ср, Чт, Пт, Сб, Вс`
-		b.SendMessage(chatID, text)
+		b.startWizard(chatID, user, flowAddWeekly, nil)
 
 	case "add_floating":
 		b.api.Request(tgbotapi.NewCallback(callback.ID, ""))
@@ -564,7 +570,7 @@ func (b *Bot) handleCallback(callback *tgbotapi.CallbackQuery) {
 		}
 
 		status := "❓ не выбран на эту неделю"
-		if event.IsConfirmedThisWeek() && event.ConfirmedDay != nil {
+		if event.IsConfirmedThisWeek(time.Now()) && event.ConfirmedDay != nil {
 			status = "✅ выбран " + domain.WeekdayName(domain.Weekday(*event.ConfirmedDay))
 		}
 
@@ -590,22 +596,17 @@ func (b *Bot) handleCallback(callback *tgbotapi.CallbackQuery) {
 
 	case "add_person":
 		b.api.Request(tgbotapi.NewCallback(callback.ID, ""))
-		text := `<b>Добавить человека:</b>
-
-/addperson Имя роль ДД.ММ.ГГГГ
-
-<b>Примеры:</b>
-/addperson Тим ребёнок 12.06.2017
-/addperson Ира семья 17.12
-/addperson Федя контакт`
-		b.SendMessage(chatID, text)
+		b.startWizard(chatID, user, flowAddPerson, nil)
 
 	case "del_person":
 		if len(parts) < 2 {
 			return
 		}
 		personID := atoi(parts[1])
-		person, _ := b.personService.Get(personID)
+		person, err := b.personService.GetOwned(personID, user.ID)
+		if err != nil {
+			log.Printf("callback del_person: error: %v", err)
+		}
 		if person == nil {
 			b.api.Request(tgbotapi.NewCallback(callback.ID, "Не найден"))
 			return
@@ -644,7 +645,10 @@ func (b *Bot) handleCallback(callback *tgbotapi.CallbackQuery) {
 			return
 		}
 		personID := atoi(parts[1])
-		person, _ := b.personService.Get(personID)
+		person, err := b.personService.GetOwned(personID, user.ID)
+		if err != nil {
+			log.Printf("callback person: error: %v", err)
+		}
 		if person == nil {
 			b.api.Request(tgbotapi.NewCallback(callback.ID, "Не найден"))
 			return
@@ -656,9 +660,9 @@ func (b *Bot) handleCallback(callback *tgbotapi.CallbackQuery) {
 		if person.HasBirthday() {
 			text += fmt.Sprintf("\n🎂 %s", person.Birthday.Format("02.01.2006"))
 			if person.Birthday.Year() > 1 {
-				text += fmt.Sprintf(" (%d лет)", person.Age())
+				text += fmt.Sprintf(" (%d лет)", person.Age(time.Now()))
 			}
-			days := person.DaysUntilBirthday()
+			days := person.DaysUntilBirthday(time.Now())
 			if days == 0 {
 				text += "\n<b>СЕГОДНЯ ДЕНЬ РОЖДЕНИЯ!</b>"
 			} else {
@@ -668,51 +672,132 @@ func (b *Bot) handleCallback(callback *tgbotapi.CallbackQuery) {
 		if person.Notes != "" {
 			text += fmt.Sprintf("\n\n📝 %s", person.Notes)
 		}
+		attachments, attachmentsText := b.attachments(domain.AttachmentPerson, person.ID)
+		text += attachmentsText
 
-		kb := tgbotapi.NewInlineKeyboardMarkup(
+		rows := attachmentRows(attachments)
+		rows = append(rows,
+			tgbotapi.NewInlineKeyboardRow(attachButton(domain.AttachmentPerson, personID)),
 			tgbotapi.NewInlineKeyboardRow(
 				tgbotapi.NewInlineKeyboardButtonData("🗑 Удалить", fmt.Sprintf("del_person:%d", personID)),
 				tgbotapi.NewInlineKeyboardButtonData("◀️ Назад", "menu:people"),
 			),
 		)
+		kb := tgbotapi.NewInlineKeyboardMarkup(rows...)
 		edit := tgbotapi.NewEditMessageText(chatID, msgID, text)
 		edit.ParseMode = "HTML"
 		edit.ReplyMarkup = &kb
 		b.api.Send(edit)
 
-	case "cl_check":
-		// cl_check:checklistID:itemIndex
-		if len(parts) < 3 {
+	case "auto":
+		// auto:autoID — карточка машины
+		if len(parts) < 2 {
 			return
 		}
-		checklistID := atoi(parts[1])
-		itemIndex := int(atoi(parts[2]))
+		b.api.Request(tgbotapi.NewCallback(callback.ID, ""))
+		b.showAuto(chatID, msgID, user.ID, atoi(parts[1]))
+
+	case "att_add":
+		// att_add:ownerType:ownerID — файл прикрепляется ответом на карточку
+		b.api.Request(tgbotapi.CallbackConfig{
+			CallbackQueryID: callback.ID,
+			Text:            "Ответь на эту карточку фото или документом — он прикрепится к ней",
+			ShowAlert:       true,
+		})
+
+	case "att_send":
+		// att_send:attachmentID — прислать вложение ещё раз
+		if len(parts) < 2 {
+			return
+		}
+		a, _ := b.attachmentService.Get(atoi(parts[1]))
+		if a == nil || b.attachmentService.CheckOwner(a.OwnerType, a.OwnerID, user.ID, chatID) != nil {
+			b.api.Request(tgbotapi.NewCallback(callback.ID, "Вложение не найдено"))
+			return
+		}
+		if err := b.sendAttachment(chatID, a); err != nil {
+			log.Printf("callback att_send: attachment %d: %v", a.ID, err)
+			b.api.Request(tgbotapi.NewCallback(callback.ID, "❌ Не удалось отправить файл"))
+			return
+		}
+		b.api.Request(tgbotapi.NewCallback(callback.ID, ""))
+
+	case "cl_start":
+		// cl_start:checklistID — новый прогон чек-листа
+		if len(parts) < 2 {
+			return
+		}
+		run, err := b.checklistService.StartRun(atoi(parts[1]), user.ID)
+		if err != nil {
+			log.Printf("callback cl_start: error: %v", err)
+			b.api.Request(tgbotapi.NewCallback(callback.ID, "❌ "+err.Error()))
+			return
+		}
+		log.Printf("callback cl_start: checklist %d run %d started", run.ChecklistID, run.ID)
+
+		b.api.Request(tgbotapi.NewCallback(callback.ID, "▶️ Начато"))
+		b.showChecklistRun(chatID, msgID, user.ID, run)
+
+	case "cl_history":
+		// cl_history:checklistID
+		if len(parts) < 2 {
+			return
+		}
+		b.api.Request(tgbotapi.NewCallback(callback.ID, ""))
+		b.showChecklistHistory(chatID, msgID, user.ID, atoi(parts[1]))
 
-		if err := b.checklistService.CheckItem(checklistID, user.ID, itemIndex); err != nil {
-			log.Printf("callback cl_check: error: %v", err)
+	case "clr_check":
+		// clr_check:runID:itemIndex
+		if len(parts) < 3 {
+			return
+		}
+		run, err := b.checklistService.ToggleRunItem(atoi(parts[1]), user.ID, int(atoi(parts[2])))
+		if err != nil {
+			log.Printf("callback clr_check: error: %v", err)
 			b.api.Request(tgbotapi.NewCallback(callback.ID, "❌ "+err.Error()))
 			return
 		}
 
 		b.api.Request(tgbotapi.NewCallback(callback.ID, "✅"))
-		b.showChecklist(chatID, msgID, checklistID)
+		b.showChecklistRun(chatID, msgID, user.ID, run)
+		b.refreshChecklistRun(run, chatID, msgID)
 
-	case "cl_reset":
-		// cl_reset:checklistID
+	case "clr_view":
+		// clr_view:runID
 		if len(parts) < 2 {
 			return
 		}
-		checklistID := atoi(parts[1])
+		run, err := b.checklistService.GetVisibleRun(atoi(parts[1]), user.ID)
+		if err != nil {
+			log.Printf("callback clr_view: error: %v", err)
+		}
+		if run == nil {
+			b.api.Request(tgbotapi.NewCallback(callback.ID, "Не найден"))
+			return
+		}
+		b.api.Request(tgbotapi.NewCallback(callback.ID, ""))
+		b.showChecklistRun(chatID, msgID, user.ID, run)
 
-		if err := b.checklistService.Reset(checklistID, user.ID); err != nil {
-			log.Printf("callback cl_reset: error: %v", err)
+	case "clr_share":
+		// clr_share:runID — отправить прогон остальным членам семьи
+		if len(parts) < 2 {
+			return
+		}
+		run, err := b.checklistService.ShareRun(atoi(parts[1]), user.ID)
+		if err != nil {
+			log.Printf("callback clr_share: error: %v", err)
 			b.api.Request(tgbotapi.NewCallback(callback.ID, "❌ "+err.Error()))
 			return
 		}
-		log.Printf("callback cl_reset: checklist %d reset", checklistID)
 
-		b.api.Request(tgbotapi.NewCallback(callback.ID, "🔄 Сброшено"))
-		b.showChecklist(chatID, msgID, checklistID)
+		names := b.shareChecklistRun(user, run)
+		if len(names) == 0 {
+			b.api.Request(tgbotapi.NewCallback(callback.ID, "Некому отправить"))
+		} else {
+			b.api.Request(tgbotapi.NewCallback(callback.ID, "👥 Отправлено: "+strings.Join(names, ", ")))
+		}
+		b.showChecklistRun(chatID, msgID, user.ID, run)
+		b.refreshChecklistRun(run, chatID, msgID)
 
 	case "cl_del":
 		// cl_del:checklistID - show confirm
@@ -764,23 +849,96 @@ func (b *Bot) handleCallback(callback *tgbotapi.CallbackQuery) {
 		}
 		checklistID := atoi(parts[1])
 		b.api.Request(tgbotapi.NewCallback(callback.ID, ""))
-		b.showChecklist(chatID, msgID, checklistID)
+		b.showChecklist(chatID, msgID, user.ID, checklistID)
+
+	case "weekly":
+		// weekly:eventID — карточка события недельного расписания (из /find)
+		if len(parts) < 2 {
+			return
+		}
+		event, err := b.scheduleService.GetVisible(atoi(parts[1]), user.ID)
+		if err != nil {
+			log.Printf("callback weekly: error: %v", err)
+		}
+		if event == nil {
+			b.api.Request(tgbotapi.NewCallback(callback.ID, "Событие не найдено"))
+			return
+		}
+
+		b.api.Request(tgbotapi.NewCallback(callback.ID, ""))
+
+		text := fmt.Sprintf("🗓 <b>%s</b>\n\n%s %s", event.Title, event.DayName(), event.TimeRange())
+		if event.IsFloating {
+			text += "\n🔄 Плавающее событие"
+		}
+		if event.IsShared {
+			text += "\n👨‍👩‍👧 Общее"
+		}
+
+		kb := tgbotapi.NewInlineKeyboardMarkup(
+			tgbotapi.NewInlineKeyboardRow(
+				tgbotapi.NewInlineKeyboardButtonData("🗓 Расписание", "menu:week"),
+				tgbotapi.NewInlineKeyboardButtonData("🏠 Меню", "menu:main"),
+			),
+		)
+		edit := tgbotapi.NewEditMessageText(chatID, msgID, text)
+		edit.ParseMode = "HTML"
+		edit.ReplyMarkup = &kb
+		b.api.Send(edit)
+
+	case "cal_event":
+		// cal_event:eventID — карточка события календаря (из /find)
+		if len(parts) < 2 || b.calendarService == nil {
+			return
+		}
+		event, err := b.calendarService.GetVisibleEvent(atoi(parts[1]), user.ID)
+		if err != nil {
+			log.Printf("callback cal_event: error: %v", err)
+		}
+		if event == nil {
+			b.api.Request(tgbotapi.NewCallback(callback.ID, "Событие не найдено"))
+			return
+		}
+
+		b.api.Request(tgbotapi.NewCallback(callback.ID, ""))
+
+		text := fmt.Sprintf("📆 <b>%s</b>\n\n%s", event.Title, event.FormatDateTime())
+		if event.Location != "" {
+			text += fmt.Sprintf("\n📍 %s", event.Location)
+		}
+		if event.Description != "" {
+			text += fmt.Sprintf("\n\n📝 %s", event.Description)
+		}
+
+		kb := tgbotapi.NewInlineKeyboardMarkup(
+			tgbotapi.NewInlineKeyboardRow(
+				tgbotapi.NewInlineKeyboardButtonData("🏠 Меню", "menu:main"),
+			),
+		)
+		edit := tgbotapi.NewEditMessageText(chatID, msgID, text)
+		edit.ParseMode = "HTML"
+		edit.ReplyMarkup = &kb
+		b.api.Send(edit)
+
+	case "cal_link":
+		// cal_link:accountID:index — подключить календарь из списка найденных в аккаунте
+		if len(parts) < 3 {
+			return
+		}
+		b.api.Request(tgbotapi.NewCallback(callback.ID, "🔄 Подключаю..."))
+		b.linkDiscoveredCalendar(chatID, user, atoi(parts[1]), int(atoi(parts[2])))
+
+	case "cal_conflict":
+		// cal_conflict:ID:mine|theirs — какую версию события оставить
+		if len(parts) < 3 || b.calendarService == nil {
+			return
+		}
+		b.api.Request(tgbotapi.NewCallback(callback.ID, ""))
+		b.resolveCalendarConflict(chatID, msgID, user, atoi(parts[1]), parts[2] == "mine")
 
 	case "add_checklist":
 		b.api.Request(tgbotapi.NewCallback(callback.ID, ""))
-		text := `<b>Создать чек-лист:</b>
-
-/addchecklist Название
-пункт 1
-пункт 2
-пункт 3
-
-<b>Пример:</b>
-/addchecklist Тим
-Выспался ли он?
-Поел ли нормально?
-Какое настроение?`
-		b.SendMessage(chatID, text)
+		b.startWizard(chatID, user, flowAddChecklist, nil)
 
 	default:
 		b.api.Request(tgbotapi.NewCallback(callback.ID, ""))
@@ -933,7 +1091,22 @@ func (b *Bot) showMainMenu(chatID int64, msgID int) {
 }
 
 func (b *Bot) showShared(chatID int64, msgID int, userID int64) {
-	tasks, _ := b.taskService.ListShared(false)
+	text, kb := b.sharedTasksView(userID)
+
+	edit := tgbotapi.NewEditMessageText(chatID, msgID, text)
+	edit.ParseMode = "HTML"
+	edit.ReplyMarkup = &kb
+	b.api.Send(edit)
+
+	user, _ := b.storage.GetUserByID(userID)
+	if user != nil {
+		b.trackMessage(chatID, msgID, userID, domain.LiveSharedTasks, b.householdID(user))
+	}
+}
+
+// sharedTasksView renders the shared tasks of the user's family
+func (b *Bot) sharedTasksView(userID int64) (string, tgbotapi.InlineKeyboardMarkup) {
+	tasks, _ := b.taskService.ListShared(userID, false)
 
 	// Получаем имена людей для отображения
 	personNames, _ := b.personService.GetNamesMap(userID)
@@ -950,30 +1123,41 @@ func (b *Bot) showShared(chatID int64, msgID int, userID int64) {
 			tgbotapi.NewInlineKeyboardButtonData("📋 Мои задачи", "menu:list"),
 		),
 	)
+	return text, kb
+}
 
-	edit := tgbotapi.NewEditMessageText(chatID, msgID, text)
-	edit.ParseMode = "HTML"
-	edit.ReplyMarkup = &kb
-	b.api.Send(edit)
+// refreshSharedIfShared refreshes shared task lists after a shared task has changed
+func (b *Bot) refreshSharedIfShared(user *domain.User, taskID int64) {
+	task, _ := b.storage.GetTask(taskID)
+	if task != nil && task.IsShared {
+		b.refreshSharedTasks(user, 0, 0)
+	}
 }
 
+// showPartnerTasks shows the tasks of the other adults of the user's household
 func (b *Bot) showPartnerTasks(chatID int64, msgID int, userID int64) {
-	partnerChatID := b.cfg.PartnerTelegramID
-	if partnerChatID == 0 {
-		edit := tgbotapi.NewEditMessageText(chatID, msgID, "Партнёр не настроен")
-		b.api.Send(edit)
-		return
+	adults, err := b.householdService.Adults(userID)
+	if err != nil {
+		log.Printf("showPartnerTasks: error: %v", err)
 	}
 
-	tasks, _ := b.taskService.ListByChat(partnerChatID, false)
-
 	personNames, _ := b.personService.GetNamesMap(userID)
 
-	text := "<b>👩 Задачи Иры</b>\n\n"
-	if len(tasks) == 0 {
-		text += "Нет активных задач 🎉"
-	} else {
-		text += b.taskService.FormatTaskListWithPersons(tasks, personNames)
+	text := ""
+	for _, adult := range adults {
+		if adult.ID == userID {
+			continue
+		}
+		tasks, _ := b.taskService.ListByChat(adult.TelegramID, false)
+		text += fmt.Sprintf("<b>👤 Задачи: %s</b>\n\n", html.EscapeString(adult.Name))
+		if len(tasks) == 0 {
+			text += "Нет активных задач 🎉\n\n"
+		} else {
+			text += b.taskService.FormatTaskListWithPersons(tasks, personNames) + "\n"
+		}
+	}
+	if text == "" {
+		text = "В семье нет других взрослых\n\n/family — участники семьи"
 	}
 
 	kb := tgbotapi.NewInlineKeyboardMarkup(
@@ -1005,7 +1189,7 @@ func (b *Bot) showFloating(chatID int64, msgID int, userID int64) {
 			}
 
 			status := "❓ не выбран"
-			if e.IsConfirmedThisWeek() && e.ConfirmedDay != nil {
+			if e.IsConfirmedThisWeek(time.Now()) && e.ConfirmedDay != nil {
 				status = "✅ " + domain.WeekdayNameShort(domain.Weekday(*e.ConfirmedDay))
 			}
 
@@ -1029,13 +1213,26 @@ func (b *Bot) showAutos(chatID int64, msgID int, userID int64) {
 	text := "<b>🚗 Мои машины</b>\n\n"
 	text += b.autoService.FormatAutoList(autos)
 
-	kb := tgbotapi.NewInlineKeyboardMarkup(
-		tgbotapi.NewInlineKeyboardRow(
-			tgbotapi.NewInlineKeyboardButtonData("➕ Добавить", "add_auto"),
-			tgbotapi.NewInlineKeyboardButtonData("📋 Задачи", "menu:list"),
-		),
-	)
+	kb := autosKeyboard(autos, tgbotapi.NewInlineKeyboardButtonData("📋 Задачи", "menu:list"))
+
+	edit := tgbotapi.NewEditMessageText(chatID, msgID, text)
+	edit.ParseMode = "HTML"
+	edit.ReplyMarkup = &kb
+	b.api.Send(edit)
+}
+
+// showAuto shows the auto card with its attachments
+func (b *Bot) showAuto(chatID int64, msgID int, userID, autoID int64) {
+	auto, _ := b.autoService.Get(autoID)
+	if auto == nil || auto.UserID != userID {
+		b.showAutos(chatID, msgID, userID)
+		return
+	}
 
+	attachments, attachmentsText := b.attachments(domain.AttachmentAuto, auto.ID)
+	text := b.autoService.FormatAuto(auto) + attachmentsText
+
+	kb := autoKeyboard(auto, attachments)
 	edit := tgbotapi.NewEditMessageText(chatID, msgID, text)
 	edit.ParseMode = "HTML"
 	edit.ReplyMarkup = &kb
@@ -1052,7 +1249,7 @@ func (b *Bot) showChecklists(chatID int64, msgID int, userID int64) {
 		text += b.checklistService.FormatChecklistList(checklists)
 	}
 
-	kb := checklistsListKeyboard(checklists)
+	kb := checklistsListKeyboard(checklists, b.openChecklistRuns(checklists))
 
 	edit := tgbotapi.NewEditMessageText(chatID, msgID, text)
 	edit.ParseMode = "HTML"
@@ -1060,12 +1257,38 @@ func (b *Bot) showChecklists(chatID int64, msgID int, userID int64) {
 	b.api.Send(edit)
 }
 
-func (b *Bot) showChecklist(chatID int64, msgID int, checklistID int64) {
+// openChecklistRuns returns unfinished runs of the checklists by checklist ID
+func (b *Bot) openChecklistRuns(checklists []*domain.Checklist) map[int64]*domain.ChecklistRun {
+	runs := make(map[int64]*domain.ChecklistRun)
+	for _, c := range checklists {
+		r, err := b.checklistService.OpenRun(c.ID)
+		if err != nil {
+			log.Printf("openChecklistRuns: checklist %d: %v", c.ID, err)
+			continue
+		}
+		if r != nil {
+			runs[c.ID] = r
+		}
+	}
+	return runs
+}
+
+// showChecklist shows the unfinished run of the checklist or the template with a start button
+func (b *Bot) showChecklist(chatID int64, msgID int, userID, checklistID int64) {
 	c, _ := b.checklistService.Get(checklistID)
 	if c == nil {
 		return
 	}
 
+	run, err := b.checklistService.OpenRun(checklistID)
+	if err != nil {
+		log.Printf("showChecklist: checklist %d: %v", checklistID, err)
+	}
+	if run != nil {
+		b.showChecklistRun(chatID, msgID, userID, run)
+		return
+	}
+
 	text := b.checklistService.FormatChecklist(c)
 	kb := checklistKeyboard(c)
 
@@ -1075,6 +1298,67 @@ func (b *Bot) showChecklist(chatID int64, msgID int, checklistID int64) {
 	b.api.Send(edit)
 }
 
+func (b *Bot) showChecklistRun(chatID int64, msgID int, userID int64, run *domain.ChecklistRun) {
+	text := b.checklistService.FormatRun(run)
+	kb := checklistRunKeyboard(run, userID)
+
+	edit := tgbotapi.NewEditMessageText(chatID, msgID, text)
+	edit.ParseMode = "HTML"
+	edit.ReplyMarkup = &kb
+	b.api.Send(edit)
+	b.trackMessage(chatID, msgID, userID, domain.LiveChecklistRun, run.ID)
+}
+
+func (b *Bot) showChecklistHistory(chatID int64, msgID int, userID, checklistID int64) {
+	text, err := b.checklistHistoryText(userID, checklistID)
+	if err != nil {
+		text = "❌ " + err.Error()
+	}
+	kb := checklistHistoryKeyboard(checklistID)
+
+	edit := tgbotapi.NewEditMessageText(chatID, msgID, text)
+	edit.ParseMode = "HTML"
+	edit.ReplyMarkup = &kb
+	b.api.Send(edit)
+}
+
+func (b *Bot) checklistHistoryText(userID, checklistID int64) (string, error) {
+	c, err := b.checklistService.Get(checklistID)
+	if err != nil {
+		return "", err
+	}
+	if c == nil {
+		return "", fmt.Errorf("чек-лист не найден")
+	}
+	runs, err := b.checklistService.History(checklistID, userID)
+	if err != nil {
+		return "", err
+	}
+	return b.checklistService.FormatHistory(c, runs), nil
+}
+
+// shareChecklistRun sends the run to the other members of the user's family
+// and returns their names; they can check items of the same run
+func (b *Bot) shareChecklistRun(user *domain.User, run *domain.ChecklistRun) []string {
+	mates, err := b.householdService.Mates(user.ID)
+	if err != nil {
+		log.Printf("shareChecklistRun: error: %v", err)
+	}
+
+	text := fmt.Sprintf("👥 <b>%s</b> делится чек-листом\n\n%s", user.Name, b.checklistService.FormatRun(run))
+	var names []string
+	for _, mate := range mates {
+		if mate.ID == user.ID {
+			continue
+		}
+		if err := b.sendLive(mate.TelegramID, mate.ID, text, checklistRunKeyboard(run, mate.ID), domain.LiveChecklistRun, run.ID); err != nil {
+			continue
+		}
+		names = append(names, mate.Name)
+	}
+	return names
+}
+
 func (b *Bot) showHistory(chatID int64, msgID int, userID int64) {
 	tasks, _ := b.storage.ListCompletedTasks(userID, 20)
 
@@ -0,0 +1,273 @@
+package bot
+
+import (
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"github.com/tazhate/familybot/internal/domain"
+)
+
+// Команды, доступные наблюдателю (viewer): только просмотр
+var readOnlyCommands = map[string]bool{
+	"start": true, "help": true, "menu": true, "list": true, "today": true,
+	"reminders": true, "people": true, "birthdays": true, "week": true,
+	"floating": true, "shared": true, "autos": true, "auto": true, "checklists": true,
+	"history": true, "stats": true, "find": true, "calendar": true,
+	"calweek": true, "chatid": true, "quote": true, "family": true, "join": true,
+	"settings": true, "cancel": true, "feed": true,
+}
+
+// Колбэки навигации, доступные наблюдателю
+var readOnlyCallbacks = map[string]bool{
+	"view": true, "page": true, "menu": true, "back": true, "refresh": true,
+	"person": true, "floating": true, "weekly": true, "cl_view": true, "cal_event": true,
+	"settings": true, "cl_history": true, "clr_view": true, "auto": true, "att_send": true,
+}
+
+// Финансовые команды: не для детей и наблюдателей
+var financeCommands = map[string]bool{
+	"debts": true, "debt": true, "payday": true, "paid": true,
+}
+
+// isAllowed returns true for users from the config and members of any household
+func (b *Bot) isAllowed(telegramID int64) bool {
+	if b.cfg.IsAllowedUser(telegramID) {
+		return true
+	}
+	user, err := b.storage.GetUserByTelegramID(telegramID)
+	if err != nil || user == nil {
+		return false
+	}
+	m, err := b.householdService.Membership(user.ID)
+	return err == nil && m != nil
+}
+
+// householdRole returns the user's role; users from the config without a household are adults
+func (b *Bot) householdRole(user *domain.User) domain.HouseholdRole {
+	if user == nil {
+		return domain.HouseholdViewer
+	}
+	m, err := b.householdService.Membership(user.ID)
+	if err != nil || m == nil {
+		return domain.HouseholdAdult
+	}
+	return m.Role
+}
+
+// commandAllowed checks the command against the user's household role
+func (b *Bot) commandAllowed(user *domain.User, cmd string) (bool, string) {
+	role := b.householdRole(user)
+	if financeCommands[cmd] && !role.CanSeeFinance() {
+		return false, "⛔ Финансы доступны только взрослым"
+	}
+	if !role.CanWrite() && !readOnlyCommands[cmd] {
+		return false, "⛔ У наблюдателя доступ только на просмотр"
+	}
+	return true, ""
+}
+
+// callbackAllowed checks the callback action against the user's household role
+func (b *Bot) callbackAllowed(user *domain.User, action string) bool {
+	return b.householdRole(user).CanWrite() || readOnlyCallbacks[action]
+}
+
+// bootstrapHousehold puts OWNER and PARTNER into the family after they register
+func (b *Bot) bootstrapHousehold() {
+	if err := b.householdService.Bootstrap(b.cfg.OwnerTelegramID, b.cfg.PartnerTelegramID); err != nil {
+		log.Printf("bootstrapHousehold: error: %v", err)
+	}
+}
+
+// cmdFamily shows the household and manages members:
+// /family, /family роль ИМЯ РОЛЬ, /family удалить ИМЯ, /family имя НАЗВАНИЕ
+func (b *Bot) cmdFamily(chatID int64, user *domain.User, args string) {
+	if user == nil {
+		b.SendMessage(chatID, "Сначала /start")
+		return
+	}
+
+	sub, rest, _ := strings.Cut(args, " ")
+	rest = strings.TrimSpace(rest)
+
+	switch strings.ToLower(sub) {
+	case "":
+		b.showFamily(chatID, user)
+	case "роль", "role":
+		fields := strings.Fields(rest)
+		if len(fields) < 2 {
+			b.SendMessage(chatID, "Формат: /family роль ИМЯ РОЛЬ\nРоли: админ, взрослый, ребёнок, наблюдатель")
+			return
+		}
+		role, ok := domain.ParseHouseholdRole(fields[len(fields)-1])
+		if !ok {
+			b.SendMessage(chatID, "❌ Неизвестная роль. Роли: админ, взрослый, ребёнок, наблюдатель")
+			return
+		}
+		target := b.findFamilyMember(chatID, user, strings.Join(fields[:len(fields)-1], " "))
+		if target == nil {
+			return
+		}
+		if err := b.householdService.SetRole(user.ID, target.UserID, role); err != nil {
+			b.SendMessage(chatID, "❌ Ошибка: "+err.Error())
+			return
+		}
+		b.SendMessage(chatID, fmt.Sprintf("✅ %s теперь %s %s", target.User.Name, role.Emoji(), role.Name()))
+	case "удалить", "remove":
+		target := b.findFamilyMember(chatID, user, rest)
+		if target == nil {
+			return
+		}
+		if err := b.householdService.Remove(user.ID, target.UserID); err != nil {
+			b.SendMessage(chatID, "❌ Ошибка: "+err.Error())
+			return
+		}
+		b.SendMessage(chatID, fmt.Sprintf("✅ %s больше не в семье", target.User.Name))
+	case "имя", "name":
+		if err := b.householdService.Rename(user.ID, rest); err != nil {
+			b.SendMessage(chatID, "❌ Ошибка: "+err.Error())
+			return
+		}
+		b.SendMessage(chatID, "✅ Семья переименована: <b>"+rest+"</b>")
+	default:
+		b.SendMessage(chatID, "Формат: /family [роль ИМЯ РОЛЬ | удалить ИМЯ | имя НАЗВАНИЕ]")
+	}
+}
+
+func (b *Bot) showFamily(chatID int64, user *domain.User) {
+	h, members, err := b.householdService.Get(user.ID)
+	if err != nil {
+		b.SendMessage(chatID, "❌ Ошибка: "+err.Error())
+		return
+	}
+
+	var sb strings.Builder
+	sb.WriteString(fmt.Sprintf("👨‍👩‍👧 <b>%s</b>\n\n", h.Name))
+	for _, m := range members {
+		sb.WriteString(fmt.Sprintf("%s %s — %s <code>#%d</code>\n", m.Role.Emoji(), m.User.Name, m.Role.Name(), m.UserID))
+	}
+	if b.householdRole(user).CanManage() {
+		sb.WriteString("\n/invite [роль] — пригласить\n/family роль ИМЯ РОЛЬ\n/family удалить ИМЯ\n/family имя НАЗВАНИЕ")
+	}
+	b.SendMessage(chatID, sb.String())
+}
+
+// findFamilyMember finds a member of the user's household by "#ID", ID or name
+func (b *Bot) findFamilyMember(chatID int64, user *domain.User, query string) *domain.HouseholdMember {
+	query = strings.TrimSpace(query)
+	if query == "" {
+		b.SendMessage(chatID, "Укажи имя участника или #ID из /family")
+		return nil
+	}
+	_, members, err := b.householdService.Get(user.ID)
+	if err != nil {
+		b.SendMessage(chatID, "❌ Ошибка: "+err.Error())
+		return nil
+	}
+
+	id, _ := strconv.ParseInt(strings.TrimPrefix(query, "#"), 10, 64)
+	var found []*domain.HouseholdMember
+	for _, m := range members {
+		first, _, _ := strings.Cut(m.User.Name, " ")
+		if m.UserID == id || strings.EqualFold(m.User.Name, query) || strings.EqualFold(first, query) {
+			found = append(found, m)
+		}
+	}
+	switch len(found) {
+	case 0:
+		b.SendMessage(chatID, "❌ Участник не найден: "+query)
+		return nil
+	case 1:
+		return found[0]
+	default:
+		b.SendMessage(chatID, "❓ Несколько участников с таким именем — укажи #ID из /family")
+		return nil
+	}
+}
+
+// cmdInvite creates an invite link: /invite [взрослый|ребёнок|наблюдатель|админ]
+func (b *Bot) cmdInvite(chatID int64, user *domain.User, args string) {
+	if user == nil {
+		b.SendMessage(chatID, "Сначала /start")
+		return
+	}
+
+	role := domain.HouseholdAdult
+	if args != "" {
+		var ok bool
+		if role, ok = domain.ParseHouseholdRole(args); !ok {
+			b.SendMessage(chatID, "❌ Неизвестная роль. Роли: админ, взрослый, ребёнок, наблюдатель")
+			return
+		}
+	}
+
+	inv, err := b.householdService.CreateInvite(user.ID, role)
+	if err != nil {
+		b.SendMessage(chatID, "❌ Ошибка: "+err.Error())
+		return
+	}
+
+	text := fmt.Sprintf("✉️ <b>Приглашение в семью</b> (%s %s)\n\n"+
+		"Ссылка: https://t.me/%s?start=%s\n"+
+		"Или команда боту: <code>/join %s</code>\n\n"+
+		"<i>Одноразовое, действует до %s</i>",
+		role.Emoji(), role.Name(), b.api.Self.UserName, inv.Code, inv.Code, inv.ExpiresAt.Format("02.01.2006"))
+	b.SendMessage(chatID, text)
+}
+
+// cmdJoin joins the household by invite code: /join CODE or the deep link /start CODE.
+// Available to users that are not in the allowed list yet.
+func (b *Bot) cmdJoin(msg *tgbotapi.Message) {
+	chatID := msg.Chat.ID
+	code := strings.TrimSpace(msg.CommandArguments())
+	if code == "" {
+		b.SendMessage(chatID, "Формат: /join КОД")
+		return
+	}
+
+	user, err := b.storage.GetUserByTelegramID(msg.From.ID)
+	if err != nil {
+		b.SendMessage(chatID, "❌ Ошибка: "+err.Error())
+		return
+	}
+	if user == nil {
+		// Незнакомца регистрируем только с действующим приглашением
+		if _, err := b.householdService.CheckInvite(code); err != nil {
+			b.SendMessage(chatID, "❌ "+err.Error())
+			return
+		}
+		name := msg.From.FirstName
+		if msg.From.LastName != "" {
+			name += " " + msg.From.LastName
+		}
+		user = &domain.User{
+			TelegramID: msg.From.ID,
+			Name:       name,
+			Role:       domain.RoleMember,
+		}
+		if err := b.storage.CreateUser(user); err != nil {
+			log.Printf("cmdJoin: error creating user: %v", err)
+			b.SendMessage(chatID, "❌ Ошибка регистрации: "+err.Error())
+			return
+		}
+	}
+
+	h, role, err := b.householdService.Join(user, code)
+	if err != nil {
+		b.SendMessage(chatID, "❌ "+err.Error())
+		return
+	}
+	log.Printf("cmdJoin: %s (%d) joined household %d as %s", user.Name, user.TelegramID, h.ID, role)
+
+	text := fmt.Sprintf("👋 Добро пожаловать в «%s», %s!\n\nТвоя роль: %s %s", h.Name, user.Name, role.Emoji(), role.Name())
+	b.SendMessageWithKeyboard(chatID, text, mainMenuKeyboard())
+
+	mates, _ := b.householdService.Mates(user.ID)
+	for _, u := range mates {
+		if u.ID != user.ID {
+			b.SendMessage(u.TelegramID, fmt.Sprintf("👨‍👩‍👧 %s теперь в семье (%s)", user.Name, role.Name()))
+		}
+	}
+}
@@ -0,0 +1,173 @@
+package bot
+
+import (
+	"fmt"
+	"html"
+	"log"
+	"strconv"
+	"strings"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"github.com/tazhate/familybot/internal/domain"
+)
+
+// Инлайн-режим из любого чата:
+//   @familybot купить молоко завтра — превью задачи; выбор создаёт её в списке отправителя
+//   @familybot ?молоко — поиск по задачам; выбор вставляет карточку задачи в чат
+// Создание по выбору приходит апдейтом chosen_inline_result: у @BotFather нужно
+// включить /setinline и /setinlinefeedback.
+
+const (
+	inlineSearchPrefix = "?"
+	inlineResultAdd    = "add"
+	inlineMaxResults   = 20
+	// cache_time = 0 библиотека не отправляет, и Telegram кэшировал бы ответ 5 минут
+	inlineCacheTime = 1
+)
+
+func (b *Bot) handleInlineQuery(q *tgbotapi.InlineQuery) {
+	results := []interface{}{}
+
+	if b.isAllowed(q.From.ID) {
+		user, err := b.storage.GetUserByTelegramID(q.From.ID)
+		if err != nil {
+			log.Printf("handleInlineQuery: error getting user: %v", err)
+		}
+		query := strings.TrimSpace(q.Query)
+		switch {
+		case user == nil || query == "":
+		case strings.HasPrefix(query, inlineSearchPrefix):
+			results = b.inlineSearchResults(user, strings.TrimSpace(strings.TrimPrefix(query, inlineSearchPrefix)))
+		default:
+			results = b.inlineAddResults(query)
+		}
+	}
+
+	answer := tgbotapi.InlineConfig{
+		InlineQueryID: q.ID,
+		Results:       results,
+		CacheTime:     inlineCacheTime,
+		IsPersonal:    true,
+	}
+	if _, err := b.api.Request(answer); err != nil {
+		log.Printf("handleInlineQuery: error answering: %v", err)
+	}
+}
+
+// inlineAddResults returns the preview of the task that will be created from the query
+func (b *Bot) inlineAddResults(query string) []interface{} {
+	raw, priority := parsePriorityTags(query)
+	cleanText, mentions := b.taskService.ParseMentions(raw)
+	title, dueDate := b.taskService.ParseDate(cleanText)
+	title = strings.TrimSpace(title)
+	if title == "" {
+		return []interface{}{}
+	}
+
+	preview := &domain.Task{Title: title, Priority: priority, DueDate: dueDate}
+	if preview.Priority == "" {
+		preview.Priority = domain.PrioritySomeday
+	}
+
+	var details []string
+	if dueDate != nil {
+		details = append(details, "📅 "+formatDueDate(*dueDate))
+	}
+	if len(mentions) > 0 {
+		details = append(details, "👤 @"+mentions[0])
+	}
+
+	text := fmt.Sprintf("📝 Новая задача\n\n%s <b>%s</b>", preview.PriorityEmoji(), html.EscapeString(title))
+	for _, d := range details {
+		text += "\n" + html.EscapeString(d)
+	}
+
+	article := tgbotapi.NewInlineQueryResultArticleHTML(inlineResultAdd, "➕ "+title, text)
+	article.Description = "Добавить в мои задачи"
+	if len(details) > 0 {
+		article.Description += " · " + strings.Join(details, " · ")
+	}
+	return []interface{}{article}
+}
+
+// inlineSearchResults returns cards of the tasks found by the query
+func (b *Bot) inlineSearchResults(user *domain.User, query string) []interface{} {
+	results := []interface{}{}
+	if query == "" || b.searchService == nil {
+		return results
+	}
+
+	found, err := b.searchService.Search(user.ID, query)
+	if err != nil {
+		log.Printf("inlineSearchResults: error: %v", err)
+		return results
+	}
+
+	personNames, _ := b.personService.GetNamesMap(user.ID)
+	for _, r := range found {
+		if r.Kind != domain.SearchKindTask {
+			continue
+		}
+		task, _ := b.storage.GetTask(r.RefID)
+		if task == nil {
+			continue
+		}
+
+		article := tgbotapi.NewInlineQueryResultArticleHTML("task:"+strconv.FormatInt(task.ID, 10), task.PriorityEmoji()+" "+task.Title, taskCard(task, personNames))
+		article.Description = fmt.Sprintf("#%d", task.ID)
+		if task.IsDone() {
+			article.Description += " · ✅ выполнена"
+		}
+		if task.DueDate != nil {
+			article.Description += " · 📅 " + formatDueDate(*task.DueDate)
+		}
+		results = append(results, article)
+		if len(results) == inlineMaxResults {
+			break
+		}
+	}
+	return results
+}
+
+// handleChosenInlineResult creates the task when the user picks the "add" preview
+func (b *Bot) handleChosenInlineResult(r *tgbotapi.ChosenInlineResult) {
+	if r.ResultID != inlineResultAdd || !b.isAllowed(r.From.ID) {
+		return
+	}
+
+	user, err := b.storage.GetUserByTelegramID(r.From.ID)
+	if err != nil || user == nil {
+		log.Printf("handleChosenInlineResult: user %d not found: %v", r.From.ID, err)
+		return
+	}
+	if !b.householdRole(user).CanWrite() {
+		return
+	}
+
+	// Задача попадает в личный список отправителя: чат с ботом совпадает с его Telegram ID
+	raw, priority := parsePriorityTags(r.Query)
+	if _, _, err := b.createTask(user.TelegramID, user, raw, priority); err != nil {
+		log.Printf("handleChosenInlineResult: error creating task: %v", err)
+	}
+}
+
+// taskCard formats a task for sharing in another chat
+func taskCard(task *domain.Task, personNames map[int64]string) string {
+	status := "⬜"
+	if task.IsDone() {
+		status = "✅"
+	}
+	text := fmt.Sprintf("%s %s <b>#%d</b> %s", status, task.PriorityEmoji(), task.ID, html.EscapeString(task.Title))
+	if task.DueDate != nil {
+		text += "\n📅 " + formatDueDate(*task.DueDate)
+	}
+	if task.PersonID != nil {
+		if name, ok := personNames[*task.PersonID]; ok {
+			text += "\n👤 " + html.EscapeString(name)
+		}
+	}
+	if task.Description != "" {
+		text += "\n\n" + html.EscapeString(task.Description)
+	}
+	return text
+}
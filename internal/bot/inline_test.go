@@ -0,0 +1,138 @@
+package bot
+
+import (
+	"encoding/json"
+	"slices"
+	"strconv"
+	"strings"
+	"testing"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"github.com/tazhate/familybot/internal/domain"
+)
+
+type inlineArticle struct {
+	ID          string `json:"id"`
+	Title       string `json:"title"`
+	Description string `json:"description"`
+	Content     struct {
+		Text string `json:"message_text"`
+	} `json:"input_message_content"`
+}
+
+// inline sends an inline query from the user and returns the articles of the answer
+func (tb *testBot) inline(from int64, query string) []inlineArticle {
+	tb.t.Helper()
+	tb.bot.handleUpdate(tgbotapi.Update{InlineQuery: &tgbotapi.InlineQuery{
+		ID:    "q",
+		From:  &tgbotapi.User{ID: from},
+		Query: query,
+	}})
+	var answers []telegramCall
+	for _, c := range tb.tg.take() {
+		if c.method == "answerInlineQuery" {
+			answers = append(answers, c)
+		}
+	}
+	if len(answers) != 1 {
+		tb.t.Fatalf("%d answers to %q", len(answers), query)
+	}
+	var articles []inlineArticle
+	if err := json.Unmarshal([]byte(answers[0].params.Get("results")), &articles); err != nil {
+		tb.t.Fatal(err)
+	}
+	return articles
+}
+
+func (tb *testBot) choose(from int64, resultID, query string) {
+	tb.t.Helper()
+	tb.bot.handleUpdate(tgbotapi.Update{ChosenInlineResult: &tgbotapi.ChosenInlineResult{
+		ResultID: resultID,
+		From:     &tgbotapi.User{ID: from},
+		Query:    query,
+	}})
+}
+
+func TestInlineAdd(t *testing.T) {
+	tb := newTestBot(t)
+	owner, _ := tb.family()
+
+	articles := tb.inline(100, "купить торт завтра !срочно @Ира")
+	if len(articles) != 1 {
+		t.Fatalf("articles %+v", articles)
+	}
+	a := articles[0]
+	if a.ID != inlineResultAdd || a.Title != "➕ купить торт" || a.Description != "Добавить в мои задачи · 📅 05.06.2030 · 👤 @Ира" {
+		t.Errorf("preview %+v", a)
+	}
+	if !strings.Contains(a.Content.Text, "🔴 <b>купить торт</b>") {
+		t.Errorf("preview message %q", a.Content.Text)
+	}
+	// Превью ничего не создаёт
+	if got := tb.titles(100); len(got) != 0 {
+		t.Errorf("tasks %q after the preview", got)
+	}
+
+	tb.choose(100, inlineResultAdd, "купить торт завтра !срочно")
+	tasks, err := tb.bot.taskService.List(owner.ID, false)
+	if err != nil || len(tasks) != 1 {
+		t.Fatalf("tasks %+v, %v", tasks, err)
+	}
+	task := tasks[0]
+	if task.Title != "купить торт" || task.Priority != domain.PriorityUrgent || task.ChatID != owner.TelegramID || task.DueDate == nil || formatDueDate(*task.DueDate) != "05.06.2030" {
+		t.Errorf("created %+v", task)
+	}
+
+	// Чужие ничего не видят и не создают; «task:ID» из поиска не создаёт задачу
+	if articles := tb.inline(999, "купить торт"); len(articles) != 0 {
+		t.Errorf("stranger got %+v", articles)
+	}
+	tb.choose(999, inlineResultAdd, "купить торт")
+	tb.choose(100, "task:"+strconv.FormatInt(task.ID, 10), "?торт")
+	if got := tb.titles(100); len(got) != 1 {
+		t.Errorf("tasks %q", got)
+	}
+	if articles := tb.inline(100, "  !срочно "); len(articles) != 0 {
+		t.Errorf("preview of an empty title: %+v", articles)
+	}
+}
+
+func TestInlineSearch(t *testing.T) {
+	tb := newTestBot(t)
+	owner, partner := tb.family()
+	create := func(u *domain.User, title string, shared bool) *domain.Task {
+		task := &domain.Task{UserID: u.ID, ChatID: u.TelegramID, Title: title, Priority: domain.PriorityWeek, IsShared: shared}
+		if err := tb.store.CreateTask(task); err != nil {
+			t.Fatal(err)
+		}
+		return task
+	}
+	own := create(owner, "Купить молоко", false)
+	shared := create(partner, "Молоко для блинов", true)
+	create(partner, "Молоко себе", false)
+	create(owner, "Хлеб", false)
+
+	var ids []string
+	for _, a := range tb.inline(100, "?молоко") {
+		ids = append(ids, a.ID)
+		if !strings.Contains(a.Content.Text, "<b>#") || !strings.HasPrefix(a.Description, "#") {
+			t.Errorf("card %+v", a)
+		}
+	}
+	slices.Sort(ids)
+	want := []string{"task:" + strconv.FormatInt(own.ID, 10), "task:" + strconv.FormatInt(shared.ID, 10)}
+	if !slices.Equal(ids, want) {
+		t.Errorf("found %q, want %q", ids, want)
+	}
+
+	if err := tb.bot.taskService.MarkDone(own.ID, owner.ID, owner.TelegramID); err != nil {
+		t.Fatal(err)
+	}
+	// Выполненные задачи в поиск не попадают
+	if articles := tb.inline(100, "? купить"); len(articles) != 0 {
+		t.Errorf("done task found: %+v", articles)
+	}
+	if articles := tb.inline(100, "?"); len(articles) != 0 {
+		t.Errorf("empty search found %+v", articles)
+	}
+}
@@ -2,11 +2,18 @@ package bot
 
 import (
 	"fmt"
+	"time"
 
 	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
 	"github.com/tazhate/familybot/internal/domain"
 )
 
+// Кнопки постоянного меню; нажатие прерывает мастер
+var menuButtons = map[string]bool{
+	"📋 Задачи": true, "📅 Сегодня": true, "➕ Добавить": true,
+	"🗓 Расписание": true, "📆 Календарь": true, "📱 Меню": true,
+}
+
 // Persistent reply keyboard (always visible at bottom)
 func persistentMenuKeyboard() tgbotapi.ReplyKeyboardMarkup {
 	return tgbotapi.NewReplyKeyboard(
@@ -55,15 +62,36 @@ func peopleKeyboard(persons []*domain.Person) tgbotapi.InlineKeyboardMarkup {
 	return tgbotapi.NewInlineKeyboardMarkup(rows...)
 }
 
-// Priority selection keyboard (text stored in bot.pendingTasks)
-func priorityKeyboard() tgbotapi.InlineKeyboardMarkup {
-	return tgbotapi.NewInlineKeyboardMarkup(
-		tgbotapi.NewInlineKeyboardRow(
-			tgbotapi.NewInlineKeyboardButtonData("🔴 Срочно", "setpri:urgent"),
-			tgbotapi.NewInlineKeyboardButtonData("🟡 На неделе", "setpri:week"),
-			tgbotapi.NewInlineKeyboardButtonData("🟢 Когда-нибудь", "setpri:someday"),
-		),
-	)
+// Wizard step keyboard: quick answers, then back/skip/cancel
+func (b *Bot) wizardKeyboard(c *domain.Conversation, step *wizardStep) tgbotapi.InlineKeyboardMarkup {
+	var rows [][]tgbotapi.InlineKeyboardButton
+	var row []tgbotapi.InlineKeyboardButton
+	for _, opt := range step.options {
+		row = append(row, tgbotapi.NewInlineKeyboardButtonData(opt[0], "wiz:set:"+opt[1]))
+		if len(row) == 4 {
+			rows = append(rows, row)
+			row = nil
+		}
+	}
+	if len(row) > 0 {
+		rows = append(rows, row)
+	}
+
+	var nav []tgbotapi.InlineKeyboardButton
+	if len(c.History) > 0 {
+		back := "⬅️ Назад"
+		if step.backLabel != "" {
+			back = step.backLabel
+		}
+		nav = append(nav, tgbotapi.NewInlineKeyboardButtonData(back, "wiz:back"))
+	}
+	if step.optional {
+		nav = append(nav, tgbotapi.NewInlineKeyboardButtonData("⏭ Пропустить", "wiz:skip"))
+	}
+	nav = append(nav, tgbotapi.NewInlineKeyboardButtonData("✖️ Отмена", "wiz:cancel"))
+	rows = append(rows, nav)
+
+	return tgbotapi.NewInlineKeyboardMarkup(rows...)
 }
 
 // Task action keyboard (for single task)
@@ -77,6 +105,7 @@ func taskKeyboard(taskID int64) tgbotapi.InlineKeyboardMarkup {
 			tgbotapi.NewInlineKeyboardButtonData("👨‍👩‍👧 Сделать общей", fmt.Sprintf("share:%d", taskID)),
 			tgbotapi.NewInlineKeyboardButtonData("📋 К списку", "menu:list"),
 		),
+		tgbotapi.NewInlineKeyboardRow(attachButton(domain.AttachmentTask, taskID)),
 	)
 }
 
@@ -129,7 +158,7 @@ func taskListKeyboard(tasks []*domain.Task, page int) *tgbotapi.InlineKeyboardMa
 	// Action row
 	rows = append(rows, tgbotapi.NewInlineKeyboardRow(
 		tgbotapi.NewInlineKeyboardButtonData("➕ Добавить", "add"),
-		tgbotapi.NewInlineKeyboardButtonData("👩 Ира", "menu:partner"),
+		tgbotapi.NewInlineKeyboardButtonData("👫 Взрослые", "menu:partner"),
 		tgbotapi.NewInlineKeyboardButtonData("🔄", "refresh:list"),
 	))
 
@@ -138,7 +167,7 @@ func taskListKeyboard(tasks []*domain.Task, page int) *tgbotapi.InlineKeyboardMa
 }
 
 // View task keyboard
-func viewTaskKeyboard(task *domain.Task) tgbotapi.InlineKeyboardMarkup {
+func viewTaskKeyboard(task *domain.Task, attachments []*domain.Attachment) tgbotapi.InlineKeyboardMarkup {
 	var rows [][]tgbotapi.InlineKeyboardButton
 
 	if !task.IsDone() {
@@ -155,13 +184,40 @@ func viewTaskKeyboard(task *domain.Task) tgbotapi.InlineKeyboardMarkup {
 		))
 	}
 
+	rows = append(rows, attachmentRows(attachments)...)
 	rows = append(rows, tgbotapi.NewInlineKeyboardRow(
+		attachButton(domain.AttachmentTask, task.ID),
 		tgbotapi.NewInlineKeyboardButtonData("◀️ Назад к списку", "back:list"),
 	))
 
 	return tgbotapi.NewInlineKeyboardMarkup(rows...)
 }
 
+// Autos list keyboard: a button per auto to open its card
+func autosKeyboard(autos []*domain.Auto, back tgbotapi.InlineKeyboardButton) tgbotapi.InlineKeyboardMarkup {
+	var rows [][]tgbotapi.InlineKeyboardButton
+	for _, a := range autos {
+		rows = append(rows, tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData("🚗 "+a.Name, fmt.Sprintf("auto:%d", a.ID)),
+		))
+	}
+	rows = append(rows, tgbotapi.NewInlineKeyboardRow(
+		tgbotapi.NewInlineKeyboardButtonData("➕ Добавить", "add_auto"),
+		back,
+	))
+	return tgbotapi.NewInlineKeyboardMarkup(rows...)
+}
+
+// Auto card keyboard
+func autoKeyboard(auto *domain.Auto, attachments []*domain.Attachment) tgbotapi.InlineKeyboardMarkup {
+	rows := attachmentRows(attachments)
+	rows = append(rows, tgbotapi.NewInlineKeyboardRow(
+		attachButton(domain.AttachmentAuto, auto.ID),
+		tgbotapi.NewInlineKeyboardButtonData("◀️ Назад", "menu:autos"),
+	))
+	return tgbotapi.NewInlineKeyboardMarkup(rows...)
+}
+
 // Confirm delete keyboard
 func confirmDeleteKeyboard(taskID int64) tgbotapi.InlineKeyboardMarkup {
 	return tgbotapi.NewInlineKeyboardMarkup(
@@ -178,7 +234,7 @@ func mainMenuKeyboard() tgbotapi.InlineKeyboardMarkup {
 		tgbotapi.NewInlineKeyboardRow(
 			tgbotapi.NewInlineKeyboardButtonData("📋 Задачи", "menu:list"),
 			tgbotapi.NewInlineKeyboardButtonData("📅 Сегодня", "menu:today"),
-			tgbotapi.NewInlineKeyboardButtonData("👩 Ира", "menu:partner"),
+			tgbotapi.NewInlineKeyboardButtonData("👫 Взрослые", "menu:partner"),
 		),
 		tgbotapi.NewInlineKeyboardRow(
 			tgbotapi.NewInlineKeyboardButtonData("🗓 Расписание", "menu:week"),
@@ -235,7 +291,7 @@ func floatingListKeyboard(events []*domain.WeeklyEvent) tgbotapi.InlineKeyboardM
 
 	// Show each event with day selection buttons
 	for _, e := range events {
-		if !e.IsConfirmedThisWeek() {
+		if !e.IsConfirmedThisWeek(time.Now()) {
 			days := e.GetFloatingDays()
 			var dayButtons []tgbotapi.InlineKeyboardButton
 
@@ -295,11 +351,26 @@ func todayKeyboard(tasks []*domain.Task) *tgbotapi.InlineKeyboardMarkup {
 	return &keyboard
 }
 
-// Checklist keyboard - shows items as checkable buttons
+// Checklist template keyboard - start a run, history, delete
 func checklistKeyboard(c *domain.Checklist) tgbotapi.InlineKeyboardMarkup {
+	return tgbotapi.NewInlineKeyboardMarkup(
+		tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData("▶️ Начать", fmt.Sprintf("cl_start:%d", c.ID)),
+			tgbotapi.NewInlineKeyboardButtonData("📊 История", fmt.Sprintf("cl_history:%d", c.ID)),
+		),
+		tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData("🗑 Удалить", fmt.Sprintf("cl_del:%d", c.ID)),
+			tgbotapi.NewInlineKeyboardButtonData("📋 Все чек-листы", "menu:checklists"),
+		),
+	)
+}
+
+// Checklist run keyboard - shows items as checkable buttons.
+// Члену семьи, с которым поделились прогоном, видны только пункты и обновление.
+func checklistRunKeyboard(r *domain.ChecklistRun, viewerID int64) tgbotapi.InlineKeyboardMarkup {
 	var rows [][]tgbotapi.InlineKeyboardButton
 
-	for i, item := range c.Items {
+	for i, item := range r.Items {
 		status := "⬜"
 		if item.Checked {
 			status = "✅"
@@ -307,16 +378,30 @@ func checklistKeyboard(c *domain.Checklist) tgbotapi.InlineKeyboardMarkup {
 		row := tgbotapi.NewInlineKeyboardRow(
 			tgbotapi.NewInlineKeyboardButtonData(
 				fmt.Sprintf("%s %s", status, truncate(item.Text, 30)),
-				fmt.Sprintf("cl_check:%d:%d", c.ID, i),
+				fmt.Sprintf("clr_check:%d:%d", r.ID, i),
 			),
 		)
 		rows = append(rows, row)
 	}
 
-	// Action row
+	if viewerID != r.UserID {
+		rows = append(rows, tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData("🔄 Обновить", fmt.Sprintf("clr_view:%d", r.ID)),
+		))
+		return tgbotapi.NewInlineKeyboardMarkup(rows...)
+	}
+
+	// Action rows
+	var actions []tgbotapi.InlineKeyboardButton
+	if !r.IsShared {
+		actions = append(actions, tgbotapi.NewInlineKeyboardButtonData("👥 Поделиться", fmt.Sprintf("clr_share:%d", r.ID)))
+	}
+	actions = append(actions, tgbotapi.NewInlineKeyboardButtonData("🔄", fmt.Sprintf("clr_view:%d", r.ID)))
+	rows = append(rows, actions)
+
 	rows = append(rows, tgbotapi.NewInlineKeyboardRow(
-		tgbotapi.NewInlineKeyboardButtonData("🔄 Сбросить", fmt.Sprintf("cl_reset:%d", c.ID)),
-		tgbotapi.NewInlineKeyboardButtonData("🗑 Удалить", fmt.Sprintf("cl_del:%d", c.ID)),
+		tgbotapi.NewInlineKeyboardButtonData("▶️ Начать заново", fmt.Sprintf("cl_start:%d", r.ChecklistID)),
+		tgbotapi.NewInlineKeyboardButtonData("📊 История", fmt.Sprintf("cl_history:%d", r.ChecklistID)),
 	))
 
 	rows = append(rows, tgbotapi.NewInlineKeyboardRow(
@@ -326,6 +411,16 @@ func checklistKeyboard(c *domain.Checklist) tgbotapi.InlineKeyboardMarkup {
 	return tgbotapi.NewInlineKeyboardMarkup(rows...)
 }
 
+// Checklist history keyboard
+func checklistHistoryKeyboard(checklistID int64) tgbotapi.InlineKeyboardMarkup {
+	return tgbotapi.NewInlineKeyboardMarkup(
+		tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData("◀️ К чек-листу", fmt.Sprintf("cl_view:%d", checklistID)),
+			tgbotapi.NewInlineKeyboardButtonData("📋 Все чек-листы", "menu:checklists"),
+		),
+	)
+}
+
 // Edit task keyboard
 func editTaskKeyboard(taskID int64) tgbotapi.InlineKeyboardMarkup {
 	return tgbotapi.NewInlineKeyboardMarkup(
@@ -349,16 +444,17 @@ func editTaskKeyboard(taskID int64) tgbotapi.InlineKeyboardMarkup {
 	)
 }
 
-// Checklists list keyboard
-func checklistsListKeyboard(checklists []*domain.Checklist) tgbotapi.InlineKeyboardMarkup {
+// Checklists list keyboard; openRuns — unfinished runs by checklist ID
+func checklistsListKeyboard(checklists []*domain.Checklist, openRuns map[int64]*domain.ChecklistRun) tgbotapi.InlineKeyboardMarkup {
 	var rows [][]tgbotapi.InlineKeyboardButton
 
 	for _, c := range checklists {
+		label := fmt.Sprintf("📋 %s", c.Title)
+		if r := openRuns[c.ID]; r != nil {
+			label = fmt.Sprintf("▶️ %s (%d/%d)", c.Title, r.CheckedCount(), len(r.Items))
+		}
 		row := tgbotapi.NewInlineKeyboardRow(
-			tgbotapi.NewInlineKeyboardButtonData(
-				fmt.Sprintf("📋 %s (%d/%d)", c.Title, c.CheckedCount(), len(c.Items)),
-				fmt.Sprintf("cl_view:%d", c.ID),
-			),
+			tgbotapi.NewInlineKeyboardButtonData(label, fmt.Sprintf("cl_view:%d", c.ID)),
 		)
 		rows = append(rows, row)
 	}
@@ -370,3 +466,103 @@ func checklistsListKeyboard(checklists []*domain.Checklist) tgbotapi.InlineKeybo
 
 	return tgbotapi.NewInlineKeyboardMarkup(rows...)
 }
+
+// Search results keyboard: one button per hit, opens the matching card
+func searchResultsKeyboard(results []*domain.SearchResult) tgbotapi.InlineKeyboardMarkup {
+	var rows [][]tgbotapi.InlineKeyboardButton
+
+	for _, r := range results {
+		var data string
+		switch r.Kind {
+		case domain.SearchKindTask:
+			data = fmt.Sprintf("view:%d", r.RefID)
+		case domain.SearchKindPerson:
+			data = fmt.Sprintf("person:%d", r.RefID)
+		case domain.SearchKindChecklist:
+			data = fmt.Sprintf("cl_view:%d", r.RefID)
+		case domain.SearchKindWeeklyEvent:
+			data = fmt.Sprintf("weekly:%d", r.RefID)
+		case domain.SearchKindCalendarEvent:
+			data = fmt.Sprintf("cal_event:%d", r.RefID)
+		default:
+			continue
+		}
+		rows = append(rows, tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData(fmt.Sprintf("%s %s", r.KindEmoji(), truncate(r.Title, 40)), data),
+		))
+	}
+
+	rows = append(rows, tgbotapi.NewInlineKeyboardRow(
+		tgbotapi.NewInlineKeyboardButtonData("🏠 Меню", "menu:main"),
+	))
+
+	return tgbotapi.NewInlineKeyboardMarkup(rows...)
+}
+
+// Settings keyboard (/settings)
+func settingsKeyboard() tgbotapi.InlineKeyboardMarkup {
+	return tgbotapi.NewInlineKeyboardMarkup(
+		tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData("🌍 Часовой пояс", "settings:tz"),
+			tgbotapi.NewInlineKeyboardButtonData("🔕 Тихие часы", "settings:quiet"),
+		),
+		tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData("☀️ Утро", "settings:morning"),
+			tgbotapi.NewInlineKeyboardButtonData("🌙 Вечер", "settings:evening"),
+		),
+		tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData("📰 Разделы брифинга", "settings:sections"),
+		),
+		tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData("🏠 Меню", "menu:main"),
+		),
+	)
+}
+
+// settingsOptionsKeyboard shows preset values for a setting: settings:<field>:<value>
+func settingsOptionsKeyboard(field string, options [][2]string) tgbotapi.InlineKeyboardMarkup {
+	var rows [][]tgbotapi.InlineKeyboardButton
+	var row []tgbotapi.InlineKeyboardButton
+	for _, opt := range options {
+		row = append(row, tgbotapi.NewInlineKeyboardButtonData(opt[0], "settings:"+field+":"+opt[1]))
+		if len(row) == 3 {
+			rows = append(rows, row)
+			row = nil
+		}
+	}
+	if len(row) > 0 {
+		rows = append(rows, row)
+	}
+	rows = append(rows, tgbotapi.NewInlineKeyboardRow(
+		tgbotapi.NewInlineKeyboardButtonData("⬅️ Назад", "settings:main"),
+	))
+	return tgbotapi.NewInlineKeyboardMarkup(rows...)
+}
+
+// settingsSectionsKeyboard toggles briefing sections
+func settingsSectionsKeyboard(st *domain.UserSettings) tgbotapi.InlineKeyboardMarkup {
+	var rows [][]tgbotapi.InlineKeyboardButton
+	for _, section := range domain.BriefingSections {
+		mark := "⬜"
+		if st.HasSection(section) {
+			mark = "✅"
+		}
+		rows = append(rows, tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData(mark+" "+section.Name(), "settings:sec:"+string(section)),
+		))
+	}
+	rows = append(rows, tgbotapi.NewInlineKeyboardRow(
+		tgbotapi.NewInlineKeyboardButtonData("⬅️ Назад", "settings:main"),
+	))
+	return tgbotapi.NewInlineKeyboardMarkup(rows...)
+}
+
+// calendarConflictKeyboard chooses the version of an event changed both in the bot and in the calendar
+func calendarConflictKeyboard(conflictID int64) tgbotapi.InlineKeyboardMarkup {
+	return tgbotapi.NewInlineKeyboardMarkup(
+		tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData("✋ Оставить моё", fmt.Sprintf("cal_conflict:%d:mine", conflictID)),
+			tgbotapi.NewInlineKeyboardButtonData("📆 Взять из календаря", fmt.Sprintf("cal_conflict:%d:theirs", conflictID)),
+		),
+	)
+}
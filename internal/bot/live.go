@@ -0,0 +1,110 @@
+package bot
+
+import (
+	"log"
+	"strings"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"github.com/tazhate/familybot/internal/domain"
+)
+
+// Живые сообщения: бот помнит, в каких сообщениях показан прогон чек-листа
+// или список общих задач, и редактирует их все, когда состояние меняется.
+// Любой колбэк на сообщении сначала снимает отметку — экран, который он
+// нарисует, снова отметит сообщение, если оно живое.
+
+// trackMessage remembers that the message shows a live view to the user
+func (b *Bot) trackMessage(chatID int64, msgID int, userID int64, kind domain.LiveMessageKind, refID int64) {
+	err := b.storage.TrackMessage(&domain.LiveMessage{
+		ChatID:    chatID,
+		MessageID: msgID,
+		UserID:    userID,
+		Kind:      kind,
+		RefID:     refID,
+	})
+	if err != nil {
+		log.Printf("trackMessage %d/%d: %v", chatID, msgID, err)
+	}
+}
+
+func (b *Bot) untrackMessage(chatID int64, msgID int) {
+	if err := b.storage.UntrackMessage(chatID, msgID); err != nil {
+		log.Printf("untrackMessage %d/%d: %v", chatID, msgID, err)
+	}
+}
+
+// sendLive sends a message with a live view and tracks it
+func (b *Bot) sendLive(chatID, userID int64, text string, kb tgbotapi.InlineKeyboardMarkup, kind domain.LiveMessageKind, refID int64) error {
+	msg := tgbotapi.NewMessage(chatID, text)
+	msg.ParseMode = "HTML"
+	msg.ReplyMarkup = kb
+	sent, err := b.api.Send(msg)
+	if err != nil {
+		log.Printf("sendLive error (chat %d): %v", chatID, err)
+		return err
+	}
+	b.trackMessage(chatID, sent.MessageID, userID, kind, refID)
+	return nil
+}
+
+// editLive edits a tracked message; a message deleted in the chat is forgotten
+func (b *Bot) editLive(m *domain.LiveMessage, text string, kb tgbotapi.InlineKeyboardMarkup) {
+	edit := tgbotapi.NewEditMessageText(m.ChatID, m.MessageID, text)
+	edit.ParseMode = "HTML"
+	edit.ReplyMarkup = &kb
+	if _, err := b.api.Send(edit); err != nil {
+		switch {
+		case strings.Contains(err.Error(), "message is not modified"):
+		case strings.Contains(err.Error(), "message to edit not found"):
+			b.untrackMessage(m.ChatID, m.MessageID)
+		default:
+			log.Printf("editLive %d/%d: %v", m.ChatID, m.MessageID, err)
+		}
+		return
+	}
+	b.trackMessage(m.ChatID, m.MessageID, m.UserID, m.Kind, m.RefID)
+}
+
+// refreshChecklistRun edits every message showing the run, except the one the change came from
+func (b *Bot) refreshChecklistRun(run *domain.ChecklistRun, exceptChatID int64, exceptMsgID int) {
+	messages, err := b.storage.ListLiveMessages(domain.LiveChecklistRun, run.ID)
+	if err != nil {
+		log.Printf("refreshChecklistRun %d: %v", run.ID, err)
+		return
+	}
+
+	text := b.checklistService.FormatRun(run)
+	for _, m := range messages {
+		if m.ChatID == exceptChatID && m.MessageID == exceptMsgID {
+			continue
+		}
+		b.editLive(m, text, checklistRunKeyboard(run, m.UserID))
+	}
+}
+
+// refreshSharedTasks edits every message with the shared tasks of the user's family,
+// except the one the change came from
+func (b *Bot) refreshSharedTasks(user *domain.User, exceptChatID int64, exceptMsgID int) {
+	messages, err := b.storage.ListLiveMessages(domain.LiveSharedTasks, b.householdID(user))
+	if err != nil {
+		log.Printf("refreshSharedTasks: %v", err)
+		return
+	}
+
+	for _, m := range messages {
+		if m.ChatID == exceptChatID && m.MessageID == exceptMsgID {
+			continue
+		}
+		text, kb := b.sharedTasksView(m.UserID)
+		b.editLive(m, text, kb)
+	}
+}
+
+// householdID returns the ID of the user's family or 0 if the user has none
+func (b *Bot) householdID(user *domain.User) int64 {
+	m, err := b.householdService.Membership(user.ID)
+	if err != nil || m == nil {
+		return 0
+	}
+	return m.HouseholdID
+}
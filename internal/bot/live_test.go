@@ -0,0 +1,129 @@
+package bot
+
+import (
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/tazhate/familybot/internal/domain"
+)
+
+// edits returns the texts the message was edited to, in order
+func edits(calls []telegramCall, chatID int64, msgID int) []string {
+	var texts []string
+	for _, c := range calls {
+		if c.method == "editMessageText" && c.params.Get("chat_id") == strconv.FormatInt(chatID, 10) && c.params.Get("message_id") == strconv.Itoa(msgID) {
+			texts = append(texts, c.params.Get("text"))
+		}
+	}
+	return texts
+}
+
+// sentTo returns the texts sent to the chat, in order
+func sentTo(calls []telegramCall, chatID int64) []string {
+	var texts []string
+	for _, c := range calls {
+		if c.method == "sendMessage" && c.params.Get("chat_id") == strconv.FormatInt(chatID, 10) {
+			texts = append(texts, c.params.Get("text"))
+		}
+	}
+	return texts
+}
+
+// family registers OWNER and PARTNER, the bootstrap puts them into one household
+func (tb *testBot) family() (owner, partner *domain.User) {
+	tb.t.Helper()
+	tb.send(100, "/menu")
+	tb.send(200, "/menu")
+	tb.tg.take()
+	return tb.user(100), tb.user(200)
+}
+
+func (tb *testBot) liveMessages(kind domain.LiveMessageKind, refID int64) []string {
+	tb.t.Helper()
+	messages, err := tb.store.ListLiveMessages(kind, refID)
+	if err != nil {
+		tb.t.Fatal(err)
+	}
+	var ids []string
+	for _, m := range messages {
+		ids = append(ids, strconv.FormatInt(m.ChatID, 10)+"/"+strconv.Itoa(m.MessageID))
+	}
+	return ids
+}
+
+func TestLiveChecklistRun(t *testing.T) {
+	tb := newTestBot(t)
+	owner, _ := tb.family()
+	c, err := tb.bot.checklistService.Create(owner.ID, "В поход", []string{"Палатка", "Спальник"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	run, err := tb.bot.checklistService.StartRun(c.ID, owner.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Владелец открывает прогон в сообщении 50 и делится им
+	tb.press(100, 50, "cl_view:"+strconv.FormatInt(c.ID, 10))
+	tb.press(100, 50, "clr_share:"+strconv.FormatInt(run.ID, 10))
+	if got := sentTo(tb.tg.take(), 200); len(got) != 1 || !strings.Contains(got[0], "User100</b> делится чек-листом") {
+		t.Fatalf("partner got %q", got)
+	}
+	partnerMsgID := tb.tg.message // последнее отправленное сообщение
+
+	// Отметка партнёра видна в сообщении владельца, с подписью
+	tb.press(200, partnerMsgID, "clr_check:"+strconv.FormatInt(run.ID, 10)+":0")
+	got := edits(tb.tg.take(), 100, 50)
+	if len(got) != 1 || !strings.Contains(got[0], "✅ 1. Палатка <i>— User200, 12:00</i>") {
+		t.Errorf("owner's message edited to %q", got)
+	}
+
+	// Удалённое в чате сообщение забывается
+	tb.tg.deleted[50] = true
+	tb.press(200, partnerMsgID, "clr_check:"+strconv.FormatInt(run.ID, 10)+":1")
+	if got, want := tb.liveMessages(domain.LiveChecklistRun, run.ID), []string{"200/" + strconv.Itoa(partnerMsgID)}; strings.Join(got, " ") != strings.Join(want, " ") {
+		t.Errorf("live messages %q, want %q", got, want)
+	}
+	tb.tg.take()
+	tb.press(200, partnerMsgID, "clr_check:"+strconv.FormatInt(run.ID, 10)+":1")
+	if got := edits(tb.tg.take(), 100, 50); len(got) != 0 {
+		t.Errorf("deleted message edited again: %q", got)
+	}
+}
+
+func TestLiveSharedTasks(t *testing.T) {
+	tb := newTestBot(t)
+	owner, partner := tb.family()
+	task, err := tb.bot.taskService.Create(owner.ID, owner.TelegramID, "Купить подарок", domain.PriorityWeek)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tb.press(100, 60, "menu:shared")
+	tb.press(200, 61, "menu:shared")
+	tb.tg.take()
+
+	tb.send(100, "/share "+strconv.FormatInt(task.ID, 10))
+	calls := tb.tg.take()
+	for _, m := range []struct {
+		chatID int64
+		msgID  int
+	}{{owner.TelegramID, 60}, {partner.TelegramID, 61}} {
+		if got := edits(calls, m.chatID, m.msgID); len(got) != 1 || !strings.Contains(got[0], "Купить подарок") {
+			t.Errorf("shared list %d/%d edited to %q", m.chatID, m.msgID, got)
+		}
+	}
+
+	// Любой колбэк на сообщении снимает отметку, пока экран не отметит его снова
+	tb.press(200, 61, "menu:list")
+	tb.tg.take()
+	tb.send(100, "/unshare "+strconv.FormatInt(task.ID, 10))
+	calls = tb.tg.take()
+	if got := edits(calls, partner.TelegramID, 61); len(got) != 0 {
+		t.Errorf("message that left the shared list edited to %q", got)
+	}
+	if got := edits(calls, owner.TelegramID, 60); len(got) != 1 || strings.Contains(got[0], "Купить подарок") {
+		t.Errorf("owner's shared list edited to %q", got)
+	}
+}
@@ -0,0 +1,143 @@
+package bot
+
+import (
+	"context"
+	"log"
+	"strconv"
+	"sync"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+const (
+	updateOffsetKey = "telegram_update_offset"
+	pollTimeout     = 30 // секунд, long polling
+	pollRetryDelay  = 3 * time.Second
+)
+
+// pollUpdates fetches updates with getUpdates until ctx is cancelled, then closes the channel;
+// updates already in it are still to be handled. handled must be called when an update has
+// been handled: the offset in the DB moves only past handled updates, so an update that was
+// received but not handled before a restart isn't skipped.
+func (b *Bot) pollUpdates(ctx context.Context) (updates tgbotapi.UpdatesChannel, handled func(updateID int)) {
+	ch := make(chan tgbotapi.Update, 100)
+	offsets := &updateOffsets{bot: b, pending: make(map[int]bool)}
+	offsets.next = b.loadUpdateOffset()
+	offsets.saved = offsets.next
+
+	go func() {
+		defer close(ch)
+
+		offset := offsets.next
+		log.Printf("Polling Telegram updates (offset %d)", offset)
+
+		for ctx.Err() == nil {
+			batch, err := b.getUpdates(ctx, offset)
+			if ctx.Err() != nil {
+				return
+			}
+			if err != nil {
+				log.Printf("getUpdates error: %v", err)
+				select {
+				case <-ctx.Done():
+					return
+				case <-time.After(pollRetryDelay):
+				}
+				continue
+			}
+
+			for _, update := range batch {
+				if update.UpdateID < offset {
+					continue
+				}
+				offset = update.UpdateID + 1
+				offsets.received(update.UpdateID)
+				select {
+				case <-ctx.Done():
+					return
+				case ch <- update:
+				}
+			}
+		}
+	}()
+
+	return ch, offsets.handled
+}
+
+// getUpdates is GetUpdates that returns as soon as ctx is cancelled instead of waiting
+// out the long poll. The abandoned request confirms nothing: Telegram drops updates only
+// when getUpdates is called with a higher offset.
+func (b *Bot) getUpdates(ctx context.Context, offset int) ([]tgbotapi.Update, error) {
+	type result struct {
+		updates []tgbotapi.Update
+		err     error
+	}
+	done := make(chan result, 1)
+	go func() {
+		updates, err := b.api.GetUpdates(tgbotapi.UpdateConfig{Offset: offset, Timeout: pollTimeout})
+		done <- result{updates, err}
+	}()
+
+	select {
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	case r := <-done:
+		return r.updates, r.err
+	}
+}
+
+// updateOffsets tracks the updates being handled. The stored offset is the first update
+// that is not handled yet, or the one after the last received if all are handled.
+type updateOffsets struct {
+	bot *Bot
+
+	mu      sync.Mutex
+	next    int          // после последнего полученного апдейта
+	saved   int          // сохранён в БД
+	pending map[int]bool // получены, но ещё обрабатываются
+}
+
+func (o *updateOffsets) received(updateID int) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	o.pending[updateID] = true
+	o.next = updateID + 1
+}
+
+func (o *updateOffsets) handled(updateID int) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	delete(o.pending, updateID)
+
+	offset := o.next
+	for id := range o.pending {
+		offset = min(offset, id)
+	}
+	if offset > o.saved {
+		o.bot.saveUpdateOffset(offset)
+		o.saved = offset
+	}
+}
+
+func (b *Bot) loadUpdateOffset() int {
+	value, err := b.storage.GetBotState(updateOffsetKey)
+	if err != nil {
+		log.Printf("Error loading update offset: %v", err)
+	}
+	if value == "" {
+		return 0
+	}
+	offset, err := strconv.Atoi(value)
+	if err != nil {
+		log.Printf("Invalid stored update offset %q: %v", value, err)
+		return 0
+	}
+	return offset
+}
+
+func (b *Bot) saveUpdateOffset(offset int) {
+	if err := b.storage.SetBotState(updateOffsetKey, strconv.Itoa(offset)); err != nil {
+		log.Printf("Error saving update offset: %v", err)
+	}
+}
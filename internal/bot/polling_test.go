@@ -0,0 +1,33 @@
+package bot
+
+import (
+	"testing"
+
+	"github.com/tazhate/familybot/internal/storage/storagetest"
+)
+
+// TestUpdateOffsetsWaitForHandling: апдейты обрабатываются параллельно и заканчиваются
+// в любом порядке, а offset в БД не уходит дальше первого необработанного
+func TestUpdateOffsetsWaitForHandling(t *testing.T) {
+	b := &Bot{storage: storagetest.SQLite(t)}
+	b.saveUpdateOffset(10)
+	offsets := &updateOffsets{bot: b, pending: make(map[int]bool), next: b.loadUpdateOffset(), saved: 10}
+
+	for _, id := range []int{10, 11, 12} {
+		offsets.received(id)
+	}
+	steps := []struct {
+		handled int
+		want    int
+	}{
+		{11, 10}, // 10 ещё обрабатывается
+		{10, 12},
+		{12, 13},
+	}
+	for _, step := range steps {
+		offsets.handled(step.handled)
+		if got := b.loadUpdateOffset(); got != step.want {
+			t.Errorf("after update %d is handled the stored offset is %d, want %d", step.handled, got, step.want)
+		}
+	}
+}
@@ -0,0 +1,137 @@
+package bot
+
+import (
+	"errors"
+	"strings"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"github.com/tazhate/familybot/internal/domain"
+)
+
+var errUnknownSetting = errors.New("неизвестная настройка: пояс, утро, вечер или тихие")
+
+// Часовые пояса для быстрого выбора; любой другой — /settings пояс Asia/Tokyo
+var timezoneOptions = [][2]string{
+	{"Калининград", "Europe/Kaliningrad"}, {"Москва", "Europe/Moscow"}, {"Самара", "Europe/Samara"},
+	{"Екатеринбург", "Asia/Yekaterinburg"}, {"Новосибирск", "Asia/Novosibirsk"}, {"Владивосток", "Asia/Vladivostok"},
+	{"Лондон", "Europe/London"}, {"Берлин", "Europe/Berlin"}, {"Стамбул", "Europe/Istanbul"},
+	{"Дубай", "Asia/Dubai"}, {"Бангкок", "Asia/Bangkok"}, {"Нью-Йорк", "America/New_York"},
+}
+
+var morningOptions = [][2]string{
+	{"06:00", "0600"}, {"06:30", "0630"}, {"07:00", "0700"},
+	{"07:30", "0730"}, {"08:00", "0800"}, {"08:30", "0830"},
+	{"09:00", "0900"}, {"09:30", "0930"}, {"10:00", "1000"},
+	{"выкл", "off"},
+}
+
+var eveningOptions = [][2]string{
+	{"19:00", "1900"}, {"20:00", "2000"}, {"20:30", "2030"},
+	{"21:00", "2100"}, {"21:30", "2130"}, {"22:00", "2200"},
+	{"выкл", "off"},
+}
+
+var quietOptions = [][2]string{
+	{"22–07", "2200-0700"}, {"23–07", "2300-0700"}, {"23–08", "2300-0800"},
+	{"00–08", "0000-0800"}, {"00–09", "0000-0900"}, {"выкл", "off"},
+}
+
+// cmdSettings shows personal settings or changes one of them:
+// /settings пояс Europe/Berlin, /settings утро 07:30, /settings вечер выкл, /settings тихие 23:00-08:00
+func (b *Bot) cmdSettings(chatID int64, user *domain.User, args string) {
+	if user == nil {
+		b.SendMessage(chatID, "Сначала /start")
+		return
+	}
+
+	if args == "" {
+		st := b.settingsService.Get(user.ID)
+		b.SendMessageWithKeyboard(chatID, b.settingsService.Format(st), settingsKeyboard())
+		return
+	}
+
+	field, value, _ := strings.Cut(args, " ")
+	st, err := b.applySetting(user.ID, strings.ToLower(field), strings.TrimSpace(value))
+	if err != nil {
+		b.SendMessage(chatID, "❌ Ошибка: "+err.Error())
+		return
+	}
+	b.SendMessageWithKeyboard(chatID, "✅ Сохранено\n\n"+b.settingsService.Format(st), settingsKeyboard())
+}
+
+func (b *Bot) applySetting(userID int64, field, value string) (*domain.UserSettings, error) {
+	switch field {
+	case "tz", "пояс", "timezone":
+		return b.settingsService.SetTimezone(userID, value)
+	case "morning", "утро", "брифинг":
+		return b.settingsService.SetMorningTime(userID, value)
+	case "evening", "вечер", "чекин":
+		return b.settingsService.SetEveningTime(userID, value)
+	case "quiet", "тихие", "тишина":
+		return b.settingsService.SetQuietHours(userID, value)
+	default:
+		return nil, errUnknownSetting
+	}
+}
+
+// handleSettingsCallback handles settings:<field>[:<value>] buttons
+func (b *Bot) handleSettingsCallback(callback *tgbotapi.CallbackQuery, user *domain.User, args []string) {
+	chatID := callback.Message.Chat.ID
+	msgID := callback.Message.MessageID
+
+	field := "main"
+	if len(args) > 0 {
+		field = args[0]
+	}
+
+	// Выбор значения: показываем варианты
+	if len(args) < 2 {
+		b.api.Request(tgbotapi.NewCallback(callback.ID, ""))
+		switch field {
+		case "tz":
+			b.editSettings(chatID, msgID, "🌍 <b>Часовой пояс</b>\n\nДругой пояс: <code>/settings пояс Asia/Tokyo</code> или <code>UTC+5</code>",
+				settingsOptionsKeyboard("tz", timezoneOptions))
+		case "morning":
+			b.editSettings(chatID, msgID, "☀️ <b>Утренний брифинг</b>\n\nЛюбое время: <code>/settings утро 07:15</code>",
+				settingsOptionsKeyboard("morning", morningOptions))
+		case "evening":
+			b.editSettings(chatID, msgID, "🌙 <b>Вечерний чекин</b>\n\nЛюбое время: <code>/settings вечер 22:15</code>",
+				settingsOptionsKeyboard("evening", eveningOptions))
+		case "quiet":
+			b.editSettings(chatID, msgID, "🔕 <b>Тихие часы</b>\n\nНесрочные уведомления копятся и приходят после окончания.\nСвои часы: <code>/settings тихие 22:30-07:30</code>",
+				settingsOptionsKeyboard("quiet", quietOptions))
+		case "sections":
+			st := b.settingsService.Get(user.ID)
+			b.editSettings(chatID, msgID, "📰 <b>Разделы утреннего брифинга</b>", settingsSectionsKeyboard(st))
+		default:
+			st := b.settingsService.Get(user.ID)
+			b.editSettings(chatID, msgID, b.settingsService.Format(st), settingsKeyboard())
+		}
+		return
+	}
+
+	if field == "sec" {
+		st, err := b.settingsService.ToggleSection(user.ID, domain.BriefingSection(args[1]))
+		if err != nil {
+			b.api.Request(tgbotapi.NewCallback(callback.ID, "❌ "+err.Error()))
+			return
+		}
+		b.api.Request(tgbotapi.NewCallback(callback.ID, "✅ Сохранено"))
+		b.editSettings(chatID, msgID, "📰 <b>Разделы утреннего брифинга</b>", settingsSectionsKeyboard(st))
+		return
+	}
+
+	st, err := b.applySetting(user.ID, field, args[1])
+	if err != nil {
+		b.api.Request(tgbotapi.NewCallback(callback.ID, "❌ "+err.Error()))
+		return
+	}
+	b.api.Request(tgbotapi.NewCallback(callback.ID, "✅ Сохранено"))
+	b.editSettings(chatID, msgID, b.settingsService.Format(st), settingsKeyboard())
+}
+
+func (b *Bot) editSettings(chatID int64, msgID int, text string, kb tgbotapi.InlineKeyboardMarkup) {
+	edit := tgbotapi.NewEditMessageTextAndMarkup(chatID, msgID, text, kb)
+	edit.ParseMode = "HTML"
+	b.api.Send(edit)
+}
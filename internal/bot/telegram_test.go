@@ -0,0 +1,229 @@
+package bot
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"github.com/tazhate/familybot/config"
+	"github.com/tazhate/familybot/internal/clock"
+	"github.com/tazhate/familybot/internal/domain"
+	"github.com/tazhate/familybot/internal/service"
+	"github.com/tazhate/familybot/internal/storage"
+	"github.com/tazhate/familybot/internal/storage/storagetest"
+)
+
+var testLocation = time.FixedZone("MSK", 3*60*60)
+
+// telegramCall — запрос бота к Bot API: метод и его параметры
+type telegramCall struct {
+	method string
+	params url.Values
+}
+
+// fakeTelegram answers Bot API requests like Telegram does and records them.
+// Files put into files are served from the file endpoint.
+type fakeTelegram struct {
+	mu      sync.Mutex
+	calls   []telegramCall
+	files   map[string]string // file_id → содержимое
+	deleted map[int]bool      // сообщения, удалённые в чате: их нельзя отредактировать
+	message int
+}
+
+func newFakeTelegram(t *testing.T) (*fakeTelegram, *tgbotapi.BotAPI) {
+	t.Helper()
+	tg := &fakeTelegram{files: make(map[string]string), deleted: make(map[int]bool)}
+	srv := httptest.NewServer(tg)
+	t.Cleanup(srv.Close)
+
+	// Все запросы, включая скачивание файлов с api.telegram.org, уходят на тестовый сервер
+	target, _ := url.Parse(srv.URL)
+	client := &http.Client{Transport: roundTripFunc(func(r *http.Request) (*http.Response, error) {
+		r.URL.Scheme, r.URL.Host = target.Scheme, target.Host
+		return http.DefaultTransport.RoundTrip(r)
+	})}
+	api, err := tgbotapi.NewBotAPIWithClient("token", tgbotapi.APIEndpoint, client)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return tg, api
+}
+
+type roundTripFunc func(*http.Request) (*http.Response, error)
+
+func (f roundTripFunc) RoundTrip(r *http.Request) (*http.Response, error) { return f(r) }
+
+func (tg *fakeTelegram) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if path, ok := strings.CutPrefix(r.URL.Path, "/file/bottoken/"); ok {
+		tg.mu.Lock()
+		content, found := tg.files[path]
+		tg.mu.Unlock()
+		if !found {
+			http.NotFound(w, r)
+			return
+		}
+		w.Write([]byte(content))
+		return
+	}
+
+	method := r.URL.Path[strings.LastIndex(r.URL.Path, "/")+1:]
+	var params url.Values
+	if strings.HasPrefix(r.Header.Get("Content-Type"), "multipart/") {
+		if err := r.ParseMultipartForm(1 << 20); err == nil {
+			params = r.MultipartForm.Value
+		}
+	} else if err := r.ParseForm(); err == nil {
+		params = r.PostForm
+	}
+
+	tg.mu.Lock()
+	defer tg.mu.Unlock()
+	if method != "getMe" {
+		tg.calls = append(tg.calls, telegramCall{method: method, params: params})
+	}
+
+	var result any = true
+	switch method {
+	case "getMe":
+		result = tgbotapi.User{ID: 1, IsBot: true, UserName: "familybot"}
+	case "sendMessage", "sendPhoto", "sendDocument":
+		tg.message++
+		chatID, _ := strconv.ParseInt(params.Get("chat_id"), 10, 64)
+		result = tgbotapi.Message{MessageID: tg.message, Chat: &tgbotapi.Chat{ID: chatID}, Text: params.Get("text")}
+	case "editMessageText":
+		chatID, _ := strconv.ParseInt(params.Get("chat_id"), 10, 64)
+		messageID, _ := strconv.Atoi(params.Get("message_id"))
+		if tg.deleted[messageID] {
+			json.NewEncoder(w).Encode(map[string]any{"ok": false, "error_code": 400, "description": "Bad Request: message to edit not found"})
+			return
+		}
+		result = tgbotapi.Message{MessageID: messageID, Chat: &tgbotapi.Chat{ID: chatID}, Text: params.Get("text")}
+	case "getFile":
+		fileID := params.Get("file_id")
+		if _, ok := tg.files[fileID]; !ok {
+			json.NewEncoder(w).Encode(map[string]any{"ok": false, "error_code": 400, "description": "Bad Request: invalid file_id"})
+			return
+		}
+		result = tgbotapi.File{FileID: fileID, FilePath: fileID}
+	}
+	json.NewEncoder(w).Encode(map[string]any{"ok": true, "result": result})
+}
+
+// take returns the calls made since the previous take
+func (tg *fakeTelegram) take() []telegramCall {
+	tg.mu.Lock()
+	defer tg.mu.Unlock()
+	calls := tg.calls
+	tg.calls = nil
+	return calls
+}
+
+// testBot — бот на SQLite с фальшивым Telegram; OWNER — 100, PARTNER — 200
+type testBot struct {
+	t     *testing.T
+	bot   *Bot
+	tg    *fakeTelegram
+	clock *clock.Fake
+	store *storage.Storage
+}
+
+func newTestBot(t *testing.T) *testBot {
+	t.Helper()
+	tg, api := newFakeTelegram(t)
+	store := storagetest.SQLite(t)
+	clk := clock.NewFake(time.Date(2030, time.June, 4, 12, 0, 0, 0, testLocation))
+	cfg := &config.Config{
+		OwnerTelegramID:   100,
+		PartnerTelegramID: 200,
+		Timezone:          testLocation,
+		MorningTime:       "09:00",
+		EveningTime:       "21:00",
+	}
+
+	reminders := service.NewReminderService(store, testLocation)
+	persons := service.NewPersonService(store)
+	persons.SetReminderService(reminders)
+	b := &Bot{
+		api:               api,
+		cfg:               cfg,
+		storage:           store,
+		taskService:       service.NewTaskService(store),
+		reminderService:   reminders,
+		personService:     persons,
+		scheduleService:   service.NewScheduleService(store),
+		autoService:       service.NewAutoService(store, testLocation),
+		checklistService:  service.NewChecklistService(store, testLocation),
+		searchService:     service.NewSearchService(store),
+		calendarService:   service.NewCalendarService(store, testLocation),
+		householdService:  service.NewHouseholdService(store),
+		settingsService:   service.NewSettingsService(store, testLocation, cfg.MorningTime, cfg.EveningTime),
+		attachmentService: service.NewAttachmentService(store),
+		feedService:       service.NewFeedService(store, testLocation),
+		clock:             clk,
+	}
+	for _, svc := range []interface{ SetClock(clock.Clock) }{
+		b.taskService, b.reminderService, b.personService, b.scheduleService, b.autoService,
+		b.checklistService, b.calendarService, b.householdService, b.settingsService,
+		b.attachmentService, b.feedService,
+	} {
+		svc.SetClock(clk)
+	}
+	b.wizards = b.newWizards()
+	return &testBot{t: t, bot: b, tg: tg, clock: clk, store: store}
+}
+
+// send delivers a private message from the user; text starting with / is a command
+func (tb *testBot) send(from int64, text string) {
+	tb.t.Helper()
+	msg := &tgbotapi.Message{
+		From: &tgbotapi.User{ID: from, FirstName: "User" + strconv.FormatInt(from, 10)},
+		Chat: &tgbotapi.Chat{ID: from, Type: "private"},
+		Text: text,
+		Date: int(tb.clock.Now().Unix()),
+	}
+	if strings.HasPrefix(text, "/") {
+		command, _, _ := strings.Cut(text, " ")
+		msg.Entities = []tgbotapi.MessageEntity{{Type: "bot_command", Offset: 0, Length: len(command)}}
+	}
+	tb.bot.handleUpdate(tgbotapi.Update{Message: msg})
+}
+
+// press presses an inline button with the callback data under message messageID
+func (tb *testBot) press(from int64, messageID int, data string) {
+	tb.t.Helper()
+	tb.bot.handleUpdate(tgbotapi.Update{CallbackQuery: &tgbotapi.CallbackQuery{
+		ID:      "cb",
+		From:    &tgbotapi.User{ID: from, FirstName: "User" + strconv.FormatInt(from, 10)},
+		Message: &tgbotapi.Message{MessageID: messageID, Chat: &tgbotapi.Chat{ID: from, Type: "private"}},
+		Data:    data,
+	}})
+}
+
+// texts returns the texts sent or edited since the previous call, in order
+func (tb *testBot) texts() []string {
+	var texts []string
+	for _, c := range tb.tg.take() {
+		if c.method == "sendMessage" || c.method == "editMessageText" {
+			texts = append(texts, c.params.Get("text"))
+		}
+	}
+	return texts
+}
+
+// user returns the registered user with the Telegram ID
+func (tb *testBot) user(telegramID int64) *domain.User {
+	tb.t.Helper()
+	u, err := tb.store.GetUserByTelegramID(telegramID)
+	if err != nil || u == nil {
+		tb.t.Fatalf("user %d: %v, %v", telegramID, u, err)
+	}
+	return u
+}
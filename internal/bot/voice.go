@@ -0,0 +1,102 @@
+package bot
+
+import (
+	"context"
+	"fmt"
+	"html"
+	"io"
+	"log"
+	"os"
+	"strings"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"github.com/tazhate/familybot/internal/domain"
+	"github.com/tazhate/familybot/internal/speech"
+)
+
+// Голосовые: заметка скачивается во временный файл, распознаётся локально
+// и дальше идёт как текст — в мастер добавления задачи или ответом на его шаг.
+
+const (
+	voiceMaxDuration = 5 * time.Minute
+	voiceTimeout     = 2 * time.Minute
+)
+
+// SetTranscriber enables voice notes
+func (b *Bot) SetTranscriber(t speech.Transcriber) {
+	b.transcriber = t
+}
+
+func (b *Bot) handleVoice(chatID int64, user *domain.User, voice *tgbotapi.Voice) {
+	if user == nil || !b.householdRole(user).CanWrite() {
+		return
+	}
+	if b.transcriber == nil {
+		b.SendMessage(chatID, "🎙 Голосовые не настроены — напиши задачу текстом")
+		return
+	}
+	if time.Duration(voice.Duration)*time.Second > voiceMaxDuration {
+		b.SendMessage(chatID, fmt.Sprintf("🎙 Слишком длинное голосовое, максимум %d мин", int(voiceMaxDuration.Minutes())))
+		return
+	}
+
+	b.api.Request(tgbotapi.NewChatAction(chatID, tgbotapi.ChatTyping))
+
+	ctx, cancel := context.WithTimeout(context.Background(), voiceTimeout)
+	defer cancel()
+
+	text, err := b.transcribeVoice(ctx, voice.FileID)
+	if err != nil {
+		log.Printf("handleVoice: error transcribing for user %d: %v", user.ID, err)
+		b.SendMessage(chatID, "❌ Не удалось распознать голосовое")
+		return
+	}
+	if text == "" {
+		b.SendMessage(chatID, "🎙 В голосовом не слышно слов — попробуй ещё раз или напиши текстом")
+		return
+	}
+	// Сам текст в лог не пишем: в голосовых бывает личное
+	log.Printf("handleVoice: transcribed %d chars for user %d", len([]rune(text)), user.ID)
+
+	// Активный мастер: расшифровка — ответ на его вопрос (например, исправленный текст задачи)
+	conv, expired := b.conversation(chatID, user)
+	if expired {
+		return
+	}
+	if conv != nil {
+		b.SendMessage(chatID, "🎙 "+html.EscapeString(text))
+		b.wizardInput(chatID, user, conv, text)
+		return
+	}
+
+	b.startWizard(chatID, user, flowAddTask, map[string]string{"title": text, "transcript": text})
+}
+
+// transcribeVoice downloads the voice note from Telegram and returns the recognized text
+func (b *Bot) transcribeVoice(ctx context.Context, fileID string) (string, error) {
+	body, err := b.downloadFile(ctx, fileID)
+	if err != nil {
+		return "", err
+	}
+	defer body.Close()
+
+	f, err := os.CreateTemp("", "familybot-voice-*.oga")
+	if err != nil {
+		return "", err
+	}
+	defer os.Remove(f.Name())
+	if _, err := io.Copy(f, body); err != nil {
+		f.Close()
+		return "", fmt.Errorf("download: %w", err)
+	}
+	if err := f.Close(); err != nil {
+		return "", err
+	}
+
+	text, err := b.transcriber.Transcribe(ctx, f.Name())
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(text), nil
+}
@@ -0,0 +1,96 @@
+package bot
+
+import (
+	"errors"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"testing"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"github.com/tazhate/familybot/internal/domain"
+	"github.com/tazhate/familybot/internal/speech/speechtest"
+)
+
+// voice sends a voice note of the given length in seconds; its file is put on the fake Telegram
+func (tb *testBot) voice(from int64, fileID string, seconds int) {
+	tb.t.Helper()
+	tb.tg.mu.Lock()
+	tb.tg.files[fileID] = "OggS"
+	tb.tg.mu.Unlock()
+	tb.bot.handleUpdate(tgbotapi.Update{Message: &tgbotapi.Message{
+		From:  &tgbotapi.User{ID: from, FirstName: "User" + strconv.FormatInt(from, 10)},
+		Chat:  &tgbotapi.Chat{ID: from, Type: "private"},
+		Voice: &tgbotapi.Voice{FileID: fileID, Duration: seconds},
+	}})
+}
+
+func TestVoiceAddsTask(t *testing.T) {
+	tb := newTestBot(t)
+	transcriber := speechtest.NewTranscriber("Купить малако")
+	tb.bot.SetTranscriber(transcriber)
+
+	tb.voice(100, "voice-1", 5)
+	if got := lastText(tb.texts()); !strings.HasPrefix(got, "🎙 Распознано:\n\n<b>Купить малако</b>") || !strings.HasSuffix(got, "Выбери приоритет или исправь текст.") {
+		t.Fatalf("asked %q after the voice note", got)
+	}
+
+	// Распознанное можно исправить: «Назад» показывает его, новое голосовое — ответ на шаг
+	tb.press(100, 1, "wiz:back")
+	if got := lastText(tb.texts()); !strings.Contains(got, "<code>Купить малако</code>") {
+		t.Errorf("title step %q does not show the transcript", got)
+	}
+	transcriber.SetText("Купить молоко")
+	tb.voice(100, "voice-2", 3)
+	if got := tb.texts(); len(got) != 2 || got[0] != "🎙 Купить молоко" || !strings.HasPrefix(got[1], "Выбери приоритет:\n\n<b>Купить молоко</b>") {
+		t.Errorf("answered %q to the corrected voice note", got)
+	}
+	tb.press(100, 2, "wiz:set:"+string(domain.PriorityWeek))
+	if got := tb.titles(100); !slices.Equal(got, []string{"Купить молоко week"}) {
+		t.Errorf("tasks %q", got)
+	}
+
+	files := transcriber.Files()
+	if len(files) != 2 {
+		t.Fatalf("transcribed %q", files)
+	}
+	for _, f := range files {
+		if _, err := os.Stat(f); !os.IsNotExist(err) {
+			t.Errorf("temporary file %s left: %v", f, err)
+		}
+	}
+}
+
+func TestVoiceErrors(t *testing.T) {
+	tb := newTestBot(t)
+	tb.voice(100, "voice-1", 5)
+	if got := tb.texts(); !slices.Equal(got, []string{"🎙 Голосовые не настроены — напиши задачу текстом"}) {
+		t.Errorf("without a transcriber answered %q", got)
+	}
+
+	transcriber := speechtest.NewTranscriber("Купить молоко")
+	tb.bot.SetTranscriber(transcriber)
+	tests := []struct {
+		name    string
+		seconds int
+		text    string
+		err     error
+		want    string
+	}{
+		{"too long", 6 * 60, "Купить молоко", nil, "🎙 Слишком длинное голосовое, максимум 5 мин"},
+		{"silence", 5, "", nil, "🎙 В голосовом не слышно слов — попробуй ещё раз или напиши текстом"},
+		{"failure", 5, "", errors.New("whisper: exit status 1"), "❌ Не удалось распознать голосовое"},
+	}
+	for _, tt := range tests {
+		transcriber.SetText(tt.text)
+		transcriber.SetError(tt.err)
+		tb.voice(100, "voice-"+tt.name, tt.seconds)
+		if got := tb.texts(); !slices.Equal(got, []string{tt.want}) {
+			t.Errorf("%s: answered %q, want %q", tt.name, got, tt.want)
+		}
+	}
+	if got := tb.titles(100); len(got) != 0 {
+		t.Errorf("tasks %q", got)
+	}
+}
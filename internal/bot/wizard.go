@@ -0,0 +1,490 @@
+package bot
+
+import (
+	"errors"
+	"fmt"
+	"html"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"github.com/tazhate/familybot/internal/domain"
+	"github.com/tazhate/familybot/internal/nlp/dates"
+	"github.com/tazhate/familybot/internal/service"
+)
+
+// conversationTimeout — сколько мастер ждёт ответа, потом диалог сбрасывается
+const conversationTimeout = 30 * time.Minute
+
+// Мастера (пошаговые диалоги)
+const (
+	flowAddTask      = "add_task"
+	flowAddWeekly    = "add_weekly"
+	flowAddPerson    = "add_person"
+	flowAddEvent     = "add_event"
+	flowAddChecklist = "add_checklist"
+)
+
+// wizardStep — шаг мастера: вопрос, проверка ответа и следующий шаг
+type wizardStep struct {
+	prompt    func(c *domain.Conversation) string
+	options   [][2]string // кнопки быстрого ответа: подпись, значение
+	optional  bool        // можно пропустить
+	backLabel string      // подпись кнопки «Назад», если шаг назад значит что-то конкретное
+	// parse проверяет ответ и возвращает значение, которое сохраняется в Data[шаг]
+	parse func(c *domain.Conversation, input string) (string, error)
+	// next возвращает следующий шаг; "" — ответы собраны, вызывается finish
+	next func(c *domain.Conversation) string
+}
+
+type wizard struct {
+	title  string
+	first  string
+	steps  map[string]*wizardStep
+	finish func(chatID int64, user *domain.User, c *domain.Conversation)
+}
+
+func ask(s string) func(c *domain.Conversation) string {
+	return func(*domain.Conversation) string { return s }
+}
+
+func then(step string) func(c *domain.Conversation) string {
+	return func(*domain.Conversation) string { return step }
+}
+
+func required(what string) func(c *domain.Conversation, input string) (string, error) {
+	return func(_ *domain.Conversation, input string) (string, error) {
+		if input == "" {
+			return "", fmt.Errorf("%s не может быть пустым", what)
+		}
+		return input, nil
+	}
+}
+
+func (b *Bot) newWizards() map[string]*wizard {
+	return map[string]*wizard{
+		flowAddTask: {
+			title: "Новая задача",
+			first: "title",
+			steps: map[string]*wizardStep{
+				"title": {
+					prompt: taskTitlePrompt,
+					parse:  required("текст задачи"),
+					next:   then("priority"),
+				},
+				"priority": {
+					prompt:    b.taskPriorityPrompt,
+					backLabel: "✏️ Изменить текст",
+					options: [][2]string{
+						{"🔴 Срочно", string(domain.PriorityUrgent)},
+						{"🟡 На неделе", string(domain.PriorityWeek)},
+						{"🟢 Когда-нибудь", string(domain.PrioritySomeday)},
+					},
+					parse: func(_ *domain.Conversation, input string) (string, error) {
+						switch p := domain.Priority(input); p {
+						case domain.PriorityUrgent, domain.PriorityWeek, domain.PrioritySomeday:
+							return input, nil
+						}
+						return "", errors.New("выбери приоритет кнопкой")
+					},
+					next: then(""),
+				},
+			},
+			finish: func(chatID int64, user *domain.User, c *domain.Conversation) {
+				b.addTask(chatID, user, c.Data["title"], domain.Priority(c.Data["priority"]))
+			},
+		},
+
+		flowAddWeekly: {
+			title: "Регулярное событие",
+			first: "day",
+			steps: map[string]*wizardStep{
+				"day": {
+					prompt: ask("🗓 В какой день недели?\n\n<i>Одной командой: /addweekly Пн 17:30 !15 Название</i>"),
+					options: [][2]string{
+						{"Пн", "пн"}, {"Вт", "вт"}, {"Ср", "ср"}, {"Чт", "чт"},
+						{"Пт", "пт"}, {"Сб", "сб"}, {"Вс", "вс"},
+					},
+					parse: func(_ *domain.Conversation, input string) (string, error) {
+						day, ok := domain.ParseWeekday(strings.ToLower(input))
+						if !ok {
+							return "", errors.New("неверный день недели (Пн, Вт, Ср, Чт, Пт, Сб, Вс)")
+						}
+						return strconv.Itoa(int(day)), nil
+					},
+					next: then("time"),
+				},
+				"time": {
+					prompt: ask("🕐 Во сколько? Например <b>17:30</b> или <b>16:00-20:00</b>"),
+					parse: func(_ *domain.Conversation, input string) (string, error) {
+						if _, _, err := service.ParseTimeRange(input); err != nil {
+							return "", err
+						}
+						return input, nil
+					},
+					next: then("title"),
+				},
+				"title": {
+					prompt: ask("✏️ Как называется событие? Например: Тим плавание"),
+					parse:  required("название"),
+					next:   then("reminder"),
+				},
+				"reminder": {
+					prompt:  ask("🔔 За сколько минут напомнить?"),
+					options: [][2]string{{"Не напоминать", "0"}, {"15 мин", "15"}, {"30 мин", "30"}, {"1 час", "60"}},
+					parse: func(_ *domain.Conversation, input string) (string, error) {
+						fields := strings.Fields(input)
+						if len(fields) == 0 {
+							return "", errors.New("укажи число минут, например 15")
+						}
+						minutes, err := strconv.Atoi(fields[0])
+						if err != nil || minutes < 0 {
+							return "", errors.New("укажи число минут, например 15")
+						}
+						return strconv.Itoa(minutes), nil
+					},
+					next: then(""),
+				},
+			},
+			finish: b.finishAddWeekly,
+		},
+
+		flowAddPerson: {
+			title: "Новый человек",
+			first: "name",
+			steps: map[string]*wizardStep{
+				"name": {
+					prompt: ask("👤 Как зовут?"),
+					parse:  required("имя"),
+					next:   then("role"),
+				},
+				"role": {
+					prompt: ask("Кто это?"),
+					options: [][2]string{
+						{"👶 Ребёнок", string(domain.RoleChild)},
+						{"👨‍👩‍👧 Семья", string(domain.RoleFamily)},
+						{"👤 Контакт", string(domain.RoleContact)},
+					},
+					parse: func(_ *domain.Conversation, input string) (string, error) {
+						role, ok := domain.ParsePersonRole(input)
+						if !ok {
+							return "", errors.New("роли: ребёнок, семья, контакт")
+						}
+						return string(role), nil
+					},
+					next: then("birthday"),
+				},
+				"birthday": {
+					prompt:   ask("🎂 День рождения? <b>12.06.2017</b> или <b>17.12</b>, если год неизвестен"),
+					optional: true,
+					parse: func(_ *domain.Conversation, input string) (string, error) {
+						if _, err := b.personService.ParseBirthday(input); err != nil {
+							return "", err
+						}
+						return input, nil
+					},
+					next: then(""),
+				},
+			},
+			finish: b.finishAddPerson,
+		},
+
+		flowAddEvent: {
+			title: "Событие в календаре",
+			first: "title",
+			steps: map[string]*wizardStep{
+				"title": {
+					prompt: ask("📆 Как называется событие?"),
+					parse:  required("название"),
+					next:   then("when"),
+				},
+				"when": {
+					prompt: ask("🕐 Когда? Например: <b>завтра 14:00</b>, <b>в пятницу с 10 до 11</b>, <b>25.01</b>"),
+					parse: func(_ *domain.Conversation, input string) (string, error) {
+						if dates.Parse(input, b.clock.Now()) == nil {
+							return "", errors.New("не удалось распознать дату")
+						}
+						return input, nil
+					},
+					next: then(""),
+				},
+			},
+			finish: func(chatID int64, user *domain.User, c *domain.Conversation) {
+				b.addCalendarEvent(chatID, user, c.Data["title"], dates.Parse(c.Data["when"], b.clock.Now()))
+			},
+		},
+
+		flowAddChecklist: {
+			title: "Новый чек-лист",
+			first: "title",
+			steps: map[string]*wizardStep{
+				"title": {
+					prompt: ask("📝 Как назвать чек-лист?"),
+					parse:  required("название"),
+					next:   then("items"),
+				},
+				// Пункты можно присылать по одному или списком, пока не нажата «Готово»
+				"items": {
+					prompt: func(c *domain.Conversation) string {
+						items := checklistItems(c.Data["items"])
+						if len(items) == 0 {
+							return "Пришли пункты — по одному или списком, каждый с новой строки"
+						}
+						return fmt.Sprintf("Пунктов: %d. Пришли ещё или нажми «Готово»", len(items))
+					},
+					options: [][2]string{{"✅ Готово", "done"}},
+					parse: func(c *domain.Conversation, input string) (string, error) {
+						if input == "done" {
+							if len(checklistItems(c.Data["items"])) == 0 {
+								return "", errors.New("добавь хотя бы один пункт")
+							}
+							c.Data["items_done"] = "1"
+							return c.Data["items"], nil
+						}
+						return strings.TrimSpace(c.Data["items"] + "\n" + input), nil
+					},
+					next: func(c *domain.Conversation) string {
+						if c.Data["items_done"] != "" {
+							return ""
+						}
+						return "items"
+					},
+				},
+			},
+			finish: func(chatID int64, user *domain.User, c *domain.Conversation) {
+				b.addChecklist(chatID, user, c.Data["title"], checklistItems(c.Data["items"]))
+			},
+		},
+	}
+}
+
+// startWizard starts the flow; steps already answered in data are skipped
+func (b *Bot) startWizard(chatID int64, user *domain.User, flow string, data map[string]string) {
+	w := b.wizards[flow]
+	if w == nil || user == nil {
+		return
+	}
+
+	c := &domain.Conversation{
+		ChatID: chatID,
+		UserID: user.ID,
+		Flow:   flow,
+		Step:   w.first,
+		Data:   make(map[string]string),
+	}
+	for k, v := range data {
+		c.Data[k] = v
+	}
+	for c.Step != "" {
+		if _, ok := c.Data[c.Step]; !ok {
+			break
+		}
+		c.History = append(c.History, c.Step)
+		c.Step = w.steps[c.Step].next(c)
+	}
+	b.advanceWizard(chatID, user, w, c)
+}
+
+// wizardInput handles a text answer to the current step
+func (b *Bot) wizardInput(chatID int64, user *domain.User, c *domain.Conversation, input string) {
+	w := b.wizards[c.Flow]
+	step := w.steps[c.Step]
+	if step == nil {
+		b.endConversation(chatID, user)
+		return
+	}
+
+	value, err := step.parse(c, strings.TrimSpace(input))
+	if err != nil {
+		b.SendMessageWithKeyboard(chatID, "❌ "+err.Error()+"\n\n"+step.prompt(c), b.wizardKeyboard(c, step))
+		return
+	}
+	b.wizardAnswer(chatID, user, w, c, value)
+}
+
+func (b *Bot) wizardAnswer(chatID int64, user *domain.User, w *wizard, c *domain.Conversation, value string) {
+	c.Data[c.Step] = value
+	next := w.steps[c.Step].next(c)
+	if next != c.Step {
+		c.History = append(c.History, c.Step)
+	}
+	c.Step = next
+	b.advanceWizard(chatID, user, w, c)
+}
+
+// advanceWizard asks the current step or finishes the flow when there are no steps left
+func (b *Bot) advanceWizard(chatID int64, user *domain.User, w *wizard, c *domain.Conversation) {
+	if c.Step == "" {
+		b.endConversation(chatID, user)
+		w.finish(chatID, user, c)
+		return
+	}
+
+	c.ExpiresAt = b.clock.Now().Add(conversationTimeout)
+	if err := b.storage.SaveConversation(c); err != nil {
+		log.Printf("advanceWizard: error saving conversation: %v", err)
+		b.SendMessage(chatID, "❌ Ошибка: "+err.Error())
+		return
+	}
+
+	step := w.steps[c.Step]
+	b.SendMessageWithKeyboard(chatID, step.prompt(c), b.wizardKeyboard(c, step))
+}
+
+// conversation returns the active dialog of the user in the chat.
+// An expired one is removed; expired reports that the user has been told about it.
+func (b *Bot) conversation(chatID int64, user *domain.User) (c *domain.Conversation, expired bool) {
+	if user == nil {
+		return nil, false
+	}
+	c, err := b.storage.GetConversation(chatID, user.ID)
+	if err != nil {
+		log.Printf("conversation: error: %v", err)
+		return nil, false
+	}
+	if c == nil || b.wizards[c.Flow] == nil {
+		return nil, false
+	}
+	if c.IsExpired(b.clock.Now()) {
+		b.endConversation(chatID, user)
+		b.SendMessage(chatID, fmt.Sprintf("⌛ Время на ответ вышло, «%s» отменено. Начни заново.", b.wizards[c.Flow].title))
+		return nil, true
+	}
+	return c, false
+}
+
+func (b *Bot) endConversation(chatID int64, user *domain.User) {
+	if err := b.storage.DeleteConversation(chatID, user.ID); err != nil {
+		log.Printf("endConversation: error: %v", err)
+	}
+}
+
+// cmdCancel cancels the active dialog
+func (b *Bot) cmdCancel(chatID int64, user *domain.User) {
+	c, expired := b.conversation(chatID, user)
+	if expired {
+		return
+	}
+	if c == nil {
+		b.SendMessage(chatID, "Нечего отменять")
+		return
+	}
+	b.endConversation(chatID, user)
+	b.SendMessage(chatID, fmt.Sprintf("✖️ «%s» отменено", b.wizards[c.Flow].title))
+}
+
+// handleWizardCallback handles wiz:set:<value>, wiz:skip, wiz:back and wiz:cancel buttons
+func (b *Bot) handleWizardCallback(callback *tgbotapi.CallbackQuery, user *domain.User, args []string) {
+	chatID := callback.Message.Chat.ID
+
+	c, _ := b.conversation(chatID, user)
+	if c == nil || len(args) == 0 {
+		b.api.Request(tgbotapi.NewCallback(callback.ID, "⌛ Диалог устарел, начни заново"))
+		return
+	}
+	w := b.wizards[c.Flow]
+	step := w.steps[c.Step]
+
+	// Кнопки под старым вопросом больше не нужны
+	b.api.Request(tgbotapi.NewEditMessageReplyMarkup(chatID, callback.Message.MessageID,
+		tgbotapi.InlineKeyboardMarkup{InlineKeyboard: [][]tgbotapi.InlineKeyboardButton{}}))
+
+	switch args[0] {
+	case "set":
+		b.api.Request(tgbotapi.NewCallback(callback.ID, ""))
+		b.wizardInput(chatID, user, c, strings.Join(args[1:], ":"))
+
+	case "skip":
+		if step == nil || !step.optional {
+			b.api.Request(tgbotapi.NewCallback(callback.ID, "Этот шаг обязательный"))
+			return
+		}
+		b.api.Request(tgbotapi.NewCallback(callback.ID, ""))
+		b.wizardAnswer(chatID, user, w, c, "")
+
+	case "back":
+		if len(c.History) == 0 {
+			b.api.Request(tgbotapi.NewCallback(callback.ID, "Это первый шаг"))
+			return
+		}
+		b.api.Request(tgbotapi.NewCallback(callback.ID, ""))
+		delete(c.Data, c.Step)
+		c.Step = c.History[len(c.History)-1]
+		c.History = c.History[:len(c.History)-1]
+		delete(c.Data, c.Step)
+		b.advanceWizard(chatID, user, w, c)
+
+	case "cancel":
+		b.api.Request(tgbotapi.NewCallback(callback.ID, "Отменено"))
+		b.endConversation(chatID, user)
+		b.SendMessage(chatID, fmt.Sprintf("✖️ «%s» отменено", w.title))
+	}
+}
+
+// taskTitlePrompt asks for the task text; after a voice note it shows the transcript to copy and fix
+func taskTitlePrompt(c *domain.Conversation) string {
+	if transcript := c.Data["transcript"]; transcript != "" {
+		return "✏️ Пришли исправленный текст задачи.\n\n🎙 Распознано (нажми, чтобы скопировать):\n<code>" + html.EscapeString(transcript) + "</code>"
+	}
+	return "✏️ Напиши текст задачи:\n\n<i>Можно с датой и @упоминанием: «купить торт завтра @Ира»</i>"
+}
+
+func (b *Bot) taskPriorityPrompt(c *domain.Conversation) string {
+	title := c.Data["title"]
+	voice := title == c.Data["transcript"]
+	hint := "Выбери приоритет:\n\n<b>" + title + "</b>"
+	if voice {
+		hint = "🎙 Распознано:\n\n<b>" + html.EscapeString(title) + "</b>"
+	}
+	if _, dueDate := b.taskService.ParseDate(title); dueDate != nil {
+		hint += fmt.Sprintf("\n📅 %s", formatDueDate(*dueDate))
+	}
+	if voice {
+		hint += "\n\nВыбери приоритет или исправь текст."
+	}
+	return hint
+}
+
+func (b *Bot) finishAddWeekly(chatID int64, user *domain.User, c *domain.Conversation) {
+	day, _ := strconv.Atoi(c.Data["day"])
+	timeStart, timeEnd, _ := service.ParseTimeRange(c.Data["time"])
+	reminderBefore, _ := strconv.Atoi(c.Data["reminder"])
+
+	event, err := b.scheduleService.Create(user.ID, domain.Weekday(day), timeStart, timeEnd, c.Data["title"], reminderBefore)
+	if err != nil {
+		log.Printf("finishAddWeekly: error: %v", err)
+		b.SendMessage(chatID, "❌ Ошибка: "+err.Error())
+		return
+	}
+	log.Printf("finishAddWeekly: created event %d", event.ID)
+	b.weeklyEventAdded(chatID, event)
+}
+
+func (b *Bot) finishAddPerson(chatID int64, user *domain.User, c *domain.Conversation) {
+	var birthday *time.Time
+	if c.Data["birthday"] != "" {
+		birthday, _ = b.personService.ParseBirthday(c.Data["birthday"])
+	}
+
+	person, err := b.personService.Create(user.ID, c.Data["name"], domain.PersonRole(c.Data["role"]), birthday, "")
+	if err != nil {
+		log.Printf("finishAddPerson: error: %v", err)
+		b.SendMessage(chatID, "❌ Ошибка: "+err.Error())
+		return
+	}
+	log.Printf("finishAddPerson: created person %s (ID: %d)", person.Name, person.ID)
+	b.personAdded(chatID, person)
+}
+
+// checklistItems splits checklist items, one per line
+func checklistItems(s string) []string {
+	var items []string
+	for _, line := range strings.Split(s, "\n") {
+		if line = strings.TrimSpace(line); line != "" {
+			items = append(items, line)
+		}
+	}
+	return items
+}
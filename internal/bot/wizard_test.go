@@ -0,0 +1,134 @@
+package bot
+
+import (
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/tazhate/familybot/internal/domain"
+)
+
+// titles returns the titles of the user's open tasks
+func (tb *testBot) titles(telegramID int64) []string {
+	tb.t.Helper()
+	tasks, err := tb.bot.taskService.List(tb.user(telegramID).ID, false)
+	if err != nil {
+		tb.t.Fatal(err)
+	}
+	var titles []string
+	for _, task := range tasks {
+		titles = append(titles, task.Title+" "+string(task.Priority))
+	}
+	return titles
+}
+
+// step returns the current step of the user's dialog, "" without one
+func (tb *testBot) step(telegramID int64) string {
+	tb.t.Helper()
+	c, err := tb.store.GetConversation(telegramID, tb.user(telegramID).ID)
+	if err != nil {
+		tb.t.Fatal(err)
+	}
+	if c == nil {
+		return ""
+	}
+	return c.Step
+}
+
+func lastText(texts []string) string {
+	if len(texts) == 0 {
+		return ""
+	}
+	return texts[len(texts)-1]
+}
+
+func TestWizardSteps(t *testing.T) {
+	tb := newTestBot(t)
+
+	tb.send(100, "Купить молоко")
+	if got := lastText(tb.texts()); !strings.Contains(got, "Выбери приоритет") || !strings.Contains(got, "Купить молоко") {
+		t.Fatalf("asked %q, want the priority", got)
+	}
+	if step := tb.step(100); step != "priority" {
+		t.Fatalf("step %q, want priority", step)
+	}
+
+	// Неверный ответ — вопрос повторяется, шаг тот же
+	tb.send(100, "очень срочно")
+	if got := lastText(tb.texts()); !strings.HasPrefix(got, "❌ выбери приоритет кнопкой") {
+		t.Errorf("answered %q to a wrong priority", got)
+	}
+
+	// «Изменить текст» возвращает к заголовку и забывает старый
+	tb.press(100, 1, "wiz:back")
+	if got := lastText(tb.texts()); !strings.HasPrefix(got, "✏️ Напиши текст задачи") {
+		t.Errorf("asked %q after back, want the title", got)
+	}
+	tb.press(100, 2, "wiz:back")
+	if step := tb.step(100); step != "title" {
+		t.Errorf("back on the first step moved to %q", step)
+	}
+
+	tb.send(100, "Купить хлеб")
+	tb.press(100, 3, "wiz:set:"+string(domain.PriorityUrgent))
+	if got := lastText(tb.texts()); !strings.HasPrefix(got, "✅ Задача добавлена") {
+		t.Errorf("finished with %q", got)
+	}
+	if got := tb.titles(100); !slices.Equal(got, []string{"Купить хлеб urgent"}) {
+		t.Errorf("tasks %q", got)
+	}
+	if step := tb.step(100); step != "" {
+		t.Errorf("dialog left at %q after finishing", step)
+	}
+}
+
+func TestWizardCancel(t *testing.T) {
+	tb := newTestBot(t)
+
+	tb.send(100, "Позвонить маме")
+	tb.send(100, "/cancel")
+	if got := lastText(tb.texts()); got != "✖️ «Новая задача» отменено" {
+		t.Errorf("/cancel answered %q", got)
+	}
+	tb.send(100, "/cancel")
+	if got := lastText(tb.texts()); got != "Нечего отменять" {
+		t.Errorf("second /cancel answered %q", got)
+	}
+
+	tb.send(100, "Позвонить папе")
+	tb.press(100, 1, "wiz:cancel")
+	if got := lastText(tb.texts()); got != "✖️ «Новая задача» отменено" {
+		t.Errorf("cancel button answered %q", got)
+	}
+	if step := tb.step(100); step != "" {
+		t.Errorf("dialog left at %q after cancel", step)
+	}
+	if got := tb.titles(100); len(got) != 0 {
+		t.Errorf("tasks %q after cancel", got)
+	}
+}
+
+func TestWizardTimeout(t *testing.T) {
+	tb := newTestBot(t)
+
+	// Каждый ответ продлевает диалог
+	tb.send(100, "Полить цветы")
+	tb.clock.Advance(conversationTimeout - time.Minute)
+	tb.press(100, 1, "wiz:back")
+	tb.clock.Advance(conversationTimeout - time.Minute)
+	tb.send(100, "Полить кактус")
+	tb.texts()
+
+	tb.clock.Advance(conversationTimeout + time.Minute)
+	tb.send(100, string(domain.PriorityWeek))
+	if got := tb.texts(); !slices.Equal(got, []string{"⌛ Время на ответ вышло, «Новая задача» отменено. Начни заново."}) {
+		t.Errorf("answered %q after the timeout", got)
+	}
+	if got := tb.titles(100); len(got) != 0 {
+		t.Errorf("tasks %q after the timeout", got)
+	}
+	if step := tb.step(100); step != "" {
+		t.Errorf("expired dialog left at %q", step)
+	}
+}
@@ -0,0 +1,431 @@
+// Package caldavtest — CalDAV-сервер в памяти на go-webdav для проверки синхронизации
+// без iCloud: один календарь, sync-collection (RFC 6578) и ctag включаются отдельно,
+// сервер считает, сколько объектов у него скачали. PUT и DELETE учитывают If-Match.
+package caldavtest
+
+import (
+	"bytes"
+	"context"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/emersion/go-ical"
+	"github.com/emersion/go-webdav"
+	"github.com/emersion/go-webdav/caldav"
+)
+
+const (
+	principalPath = "/family/"
+	homeSetPath   = "/family/calendars/"
+	calendarPath  = "/family/calendars/home/"
+	tokenPrefix   = "familybot-sync-"
+)
+
+type object struct {
+	data    *ical.Calendar
+	etag    string
+	modTime time.Time
+	version int // версия календаря, в которой объект изменился
+}
+
+// Server is an in-memory CalDAV server with a single calendar
+type Server struct {
+	*httptest.Server
+
+	mu             sync.Mutex
+	objects        map[string]*object
+	deleted        map[string]int // путь → версия, в которой объект удалён
+	version        int
+	syncCollection bool
+	ctag           bool
+	fetched        int    // сколько объектов отдано с calendar-data
+	username       string // "" — вход без пароля
+	password       string
+}
+
+// NewServer starts a server; sync-collection and ctag are enabled
+func NewServer() *Server {
+	s := &Server{
+		objects:        make(map[string]*object),
+		deleted:        make(map[string]int),
+		syncCollection: true,
+		ctag:           true,
+	}
+	handler := &caldav.Handler{Backend: &backend{s: s}}
+	s.Server = httptest.NewServer(s.wrap(handler))
+	return s
+}
+
+// SetCredentials makes the server answer 401 to requests without this login and password
+func (s *Server) SetCredentials(username, password string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.username, s.password = username, password
+}
+
+// CalendarPath returns the path of the calendar to sync
+func (s *Server) CalendarPath() string {
+	return calendarPath
+}
+
+// SetSyncCollection turns RFC 6578 sync-collection support on or off
+func (s *Server) SetSyncCollection(enabled bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.syncCollection = enabled
+}
+
+// SetCTag turns the calendarserver.org getctag property on or off
+func (s *Server) SetCTag(enabled bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.ctag = enabled
+}
+
+// PutEvent creates or replaces an event and returns the path of its object
+func (s *Server) PutEvent(uid, summary string, start, end time.Time) string {
+	cal := ical.NewCalendar()
+	cal.Props.SetText(ical.PropVersion, "2.0")
+	cal.Props.SetText(ical.PropProductID, "-//FamilyBot//caldavtest//EN")
+	event := ical.NewEvent()
+	event.Props.SetText(ical.PropUID, uid)
+	event.Props.SetText(ical.PropSummary, summary)
+	event.Props.SetDateTime(ical.PropDateTimeStamp, time.Now().UTC())
+	event.Props.SetDateTime(ical.PropDateTimeStart, start.UTC())
+	event.Props.SetDateTime(ical.PropDateTimeEnd, end.UTC())
+	event.Props.SetDateTime(ical.PropLastModified, time.Now().UTC())
+	cal.Children = append(cal.Children, event.Component)
+
+	p := calendarPath + uid + ".ics"
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.put(p, cal)
+	return p
+}
+
+// PutObject creates or replaces an object from iCalendar text, e.g. a recurring event
+// with its overridden occurrences, and returns its path
+func (s *Server) PutObject(name, ics string) (string, error) {
+	cal, err := ical.NewDecoder(strings.NewReader(ics)).Decode()
+	if err != nil {
+		return "", fmt.Errorf("caldavtest: decode %s: %w", name, err)
+	}
+
+	p := calendarPath + name + ".ics"
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.put(p, cal)
+	return p, nil
+}
+
+// DeleteEvent removes the event object by UID
+func (s *Server) DeleteEvent(uid string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.remove(calendarPath + uid + ".ics")
+}
+
+// EventSummary returns the SUMMARY of the event object by UID; false if there is no such object
+func (s *Server) EventSummary(uid string) (string, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	obj, ok := s.objects[calendarPath+uid+".ics"]
+	if !ok {
+		return "", false
+	}
+	for _, comp := range obj.data.Children {
+		if comp.Name == ical.CompEvent {
+			summary, _ := comp.Props.Text(ical.PropSummary)
+			return summary, true
+		}
+	}
+	return "", true
+}
+
+// Fetched returns how many objects were downloaded with their data and resets the counter
+func (s *Server) Fetched() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	n := s.fetched
+	s.fetched = 0
+	return n
+}
+
+func (s *Server) put(p string, cal *ical.Calendar) *object {
+	s.version++
+	obj := &object{
+		data:    cal,
+		etag:    fmt.Sprintf("etag-%d", s.version),
+		modTime: time.Now().UTC(),
+		version: s.version,
+	}
+	s.objects[p] = obj
+	delete(s.deleted, p)
+	return obj
+}
+
+func (s *Server) remove(p string) bool {
+	if _, ok := s.objects[p]; !ok {
+		return false
+	}
+	s.version++
+	delete(s.objects, p)
+	s.deleted[p] = s.version
+	return true
+}
+
+func (s *Server) token() string {
+	return tokenPrefix + strconv.Itoa(s.version)
+}
+
+// wrap answers what go-webdav's handler doesn't support: the sync-collection
+// report and the getctag property
+func (s *Server) wrap(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		s.mu.Lock()
+		username, password := s.username, s.password
+		s.mu.Unlock()
+		if u, p, _ := r.BasicAuth(); username != "" && (u != username || p != password) {
+			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+
+		// caldav.Handler не передаёт If-Match в DeleteCalendarObject
+		if r.Method == http.MethodDelete && !s.matches(r.URL.Path, webdav.ConditionalMatch(r.Header.Get("If-Match"))) {
+			http.Error(w, "If-Match condition failed", http.StatusPreconditionFailed)
+			return
+		}
+		if r.Method != "REPORT" && r.Method != "PROPFIND" {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		body, err := io.ReadAll(r.Body)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		r.Body = io.NopCloser(bytes.NewReader(body))
+
+		s.mu.Lock()
+		syncCollection, ctag := s.syncCollection, s.ctag
+		s.mu.Unlock()
+
+		switch {
+		case r.Method == "REPORT" && bytes.Contains(body, []byte("sync-collection")):
+			if !syncCollection {
+				http.Error(w, "sync-collection is not supported", http.StatusForbidden)
+				return
+			}
+			s.serveSyncCollection(w, body)
+		case r.Method == "PROPFIND" && ctag && bytes.Contains(body, []byte("getctag")) && path.Clean(r.URL.Path) == path.Clean(calendarPath):
+			s.serveCTag(w)
+		default:
+			next.ServeHTTP(w, r)
+		}
+	})
+}
+
+// matches checks the If-Match condition against the object at the path
+func (s *Server) matches(p string, ifMatch webdav.ConditionalMatch) bool {
+	if !ifMatch.IsSet() {
+		return true
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	obj, ok := s.objects[p]
+	if !ok {
+		return false
+	}
+	if ifMatch.IsWildcard() {
+		return true
+	}
+	match, err := ifMatch.MatchETag(obj.etag)
+	return err == nil && match
+}
+
+func (s *Server) serveSyncCollection(w http.ResponseWriter, body []byte) {
+	var req struct {
+		SyncToken string `xml:"DAV: sync-token"`
+	}
+	if err := xml.Unmarshal(body, &req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	since := 0
+	if req.SyncToken != "" {
+		v, err := strconv.Atoi(strings.TrimPrefix(req.SyncToken, tokenPrefix))
+		if err != nil || !strings.HasPrefix(req.SyncToken, tokenPrefix) || v > s.version {
+			w.Header().Set("Content-Type", "application/xml; charset=utf-8")
+			w.WriteHeader(http.StatusForbidden)
+			io.WriteString(w, `<?xml version="1.0" encoding="utf-8"?><d:error xmlns:d="DAV:"><d:valid-sync-token/></d:error>`)
+			return
+		}
+		since = v
+	}
+
+	var sb strings.Builder
+	sb.WriteString(`<?xml version="1.0" encoding="utf-8"?><d:multistatus xmlns:d="DAV:">`)
+	for _, p := range sortedKeys(s.objects) {
+		obj := s.objects[p]
+		if obj.version <= since {
+			continue
+		}
+		fmt.Fprintf(&sb, `<d:response><d:href>%s</d:href><d:propstat><d:prop><d:getetag>%q</d:getetag></d:prop>`+
+			`<d:status>HTTP/1.1 200 OK</d:status></d:propstat></d:response>`, p, obj.etag)
+	}
+	// При первой синхронизации удалённые объекты не нужны
+	if since > 0 {
+		for _, p := range sortedKeys(s.deleted) {
+			if s.deleted[p] <= since {
+				continue
+			}
+			fmt.Fprintf(&sb, `<d:response><d:href>%s</d:href><d:status>HTTP/1.1 404 Not Found</d:status></d:response>`, p)
+		}
+	}
+	fmt.Fprintf(&sb, `<d:sync-token>%s</d:sync-token></d:multistatus>`, s.token())
+
+	w.Header().Set("Content-Type", "application/xml; charset=utf-8")
+	w.WriteHeader(http.StatusMultiStatus)
+	io.WriteString(w, sb.String())
+}
+
+func (s *Server) serveCTag(w http.ResponseWriter) {
+	s.mu.Lock()
+	ctag := strconv.Itoa(s.version)
+	s.mu.Unlock()
+
+	w.Header().Set("Content-Type", "application/xml; charset=utf-8")
+	w.WriteHeader(http.StatusMultiStatus)
+	fmt.Fprintf(w, `<?xml version="1.0" encoding="utf-8"?>`+
+		`<d:multistatus xmlns:d="DAV:" xmlns:cs="http://calendarserver.org/ns/"><d:response><d:href>%s</d:href>`+
+		`<d:propstat><d:prop><cs:getctag>%s</cs:getctag></d:prop><d:status>HTTP/1.1 200 OK</d:status></d:propstat>`+
+		`</d:response></d:multistatus>`, calendarPath, ctag)
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// backend implements caldav.Backend over the server's objects
+type backend struct {
+	s *Server
+}
+
+var _ caldav.Backend = (*backend)(nil)
+
+func (b *backend) CurrentUserPrincipal(ctx context.Context) (string, error) {
+	return principalPath, nil
+}
+
+func (b *backend) CalendarHomeSetPath(ctx context.Context) (string, error) {
+	return homeSetPath, nil
+}
+
+func (b *backend) CreateCalendar(ctx context.Context, calendar *caldav.Calendar) error {
+	return webdav.NewHTTPError(http.StatusForbidden, fmt.Errorf("caldavtest: only one calendar"))
+}
+
+func (b *backend) ListCalendars(ctx context.Context) ([]caldav.Calendar, error) {
+	return []caldav.Calendar{b.calendar()}, nil
+}
+
+func (b *backend) GetCalendar(ctx context.Context, p string) (*caldav.Calendar, error) {
+	if path.Clean(p) != path.Clean(calendarPath) {
+		return nil, webdav.NewHTTPError(http.StatusNotFound, fmt.Errorf("caldavtest: no calendar %s", p))
+	}
+	cal := b.calendar()
+	return &cal, nil
+}
+
+func (b *backend) calendar() caldav.Calendar {
+	return caldav.Calendar{Path: calendarPath, Name: "Home", SupportedComponentSet: []string{ical.CompEvent}}
+}
+
+func (b *backend) GetCalendarObject(ctx context.Context, p string, req *caldav.CalendarCompRequest) (*caldav.CalendarObject, error) {
+	b.s.mu.Lock()
+	defer b.s.mu.Unlock()
+
+	obj, ok := b.s.objects[p]
+	if !ok {
+		return nil, webdav.NewHTTPError(http.StatusNotFound, fmt.Errorf("caldavtest: no object %s", p))
+	}
+	b.s.fetched++
+	co := toCalendarObject(p, obj)
+	return &co, nil
+}
+
+func (b *backend) ListCalendarObjects(ctx context.Context, p string, req *caldav.CalendarCompRequest) ([]caldav.CalendarObject, error) {
+	b.s.mu.Lock()
+	defer b.s.mu.Unlock()
+
+	var list []caldav.CalendarObject
+	for _, key := range sortedKeys(b.s.objects) {
+		list = append(list, toCalendarObject(key, b.s.objects[key]))
+	}
+	return list, nil
+}
+
+func (b *backend) QueryCalendarObjects(ctx context.Context, p string, query *caldav.CalendarQuery) ([]caldav.CalendarObject, error) {
+	all, err := b.ListCalendarObjects(ctx, p, &query.CompRequest)
+	if err != nil {
+		return nil, err
+	}
+	b.s.mu.Lock()
+	defer b.s.mu.Unlock()
+	b.s.fetched += len(all)
+	return caldav.Filter(query, all)
+}
+
+func (b *backend) PutCalendarObject(ctx context.Context, p string, cal *ical.Calendar, opts *caldav.PutCalendarObjectOptions) (*caldav.CalendarObject, error) {
+	if opts != nil && !b.s.matches(p, opts.IfMatch) {
+		return nil, webdav.NewHTTPError(http.StatusPreconditionFailed, fmt.Errorf("caldavtest: If-Match condition failed"))
+	}
+	b.s.mu.Lock()
+	defer b.s.mu.Unlock()
+
+	obj := b.s.put(p, cal)
+	co := toCalendarObject(p, obj)
+	return &co, nil
+}
+
+func (b *backend) DeleteCalendarObject(ctx context.Context, p string) error {
+	b.s.mu.Lock()
+	defer b.s.mu.Unlock()
+
+	if !b.s.remove(p) {
+		return webdav.NewHTTPError(http.StatusNotFound, fmt.Errorf("caldavtest: no object %s", p))
+	}
+	return nil
+}
+
+func toCalendarObject(p string, obj *object) caldav.CalendarObject {
+	// go-webdav клиент требует getcontentlength при чтении каталога
+	var buf bytes.Buffer
+	ical.NewEncoder(&buf).Encode(obj.data)
+	return caldav.CalendarObject{
+		Path:          p,
+		ModTime:       obj.modTime,
+		ContentLength: int64(buf.Len()),
+		ETag:          obj.etag,
+		Data:          obj.data,
+	}
+}
@@ -5,6 +5,7 @@ import (
 	"context"
 	"fmt"
 	"net/http"
+	"strconv"
 	"strings"
 	"time"
 
@@ -22,8 +23,10 @@ type Client struct {
 	baseURL    string
 	username   string
 	password   string
-	calendarID string // Optional: specific calendar to use
+	calendarID string         // Optional: specific calendar to use
+	location   *time.Location // Zone of floating times and all-day dates
 	client     *caldav.Client
+	httpClient *http.Client
 }
 
 // NewClient creates a new CalDAV client
@@ -35,6 +38,7 @@ func NewClient(baseURL, username, password string) *Client {
 		baseURL:  baseURL,
 		username: username,
 		password: password,
+		location: time.UTC,
 	}
 }
 
@@ -48,6 +52,11 @@ func (c *Client) SetCalendarID(id string) {
 	c.calendarID = id
 }
 
+// SetLocation sets the zone of floating times and all-day dates, usually the bot's timezone
+func (c *Client) SetLocation(loc *time.Location) {
+	c.location = loc
+}
+
 // connect establishes connection to CalDAV server
 func (c *Client) connect() (*caldav.Client, error) {
 	if c.client != nil {
@@ -68,6 +77,7 @@ func (c *Client) connect() (*caldav.Client, error) {
 	}
 
 	c.client = client
+	c.httpClient = httpClient
 	return client, nil
 }
 
@@ -156,11 +166,11 @@ func (c *Client) GetEvents(calendarPath string, from, to time.Time) ([]Event, er
 
 	var events []Event
 	for _, obj := range objects {
-		event, err := parseCalendarObject(&obj)
+		expanded, err := expandCalendarObject(&obj, c.location, from, to)
 		if err != nil {
 			continue // Skip invalid events
 		}
-		events = append(events, event)
+		events = append(events, expanded...)
 	}
 
 	return events, nil
@@ -189,18 +199,24 @@ func (c *Client) CreateEvent(calendarPath string, event *Event) error {
 	// Create iCalendar data
 	cal := eventToICS(event)
 
-	// Create path for new event
-	eventPath := calendarPath
-	if !strings.HasSuffix(eventPath, "/") {
-		eventPath += "/"
+	// Create path for new event; existing objects keep their path, it may differ from the UID
+	eventPath := event.Path
+	if eventPath == "" {
+		eventPath = calendarPath
+		if !strings.HasSuffix(eventPath, "/") {
+			eventPath += "/"
+		}
+		eventPath += event.UID + ".ics"
 	}
-	eventPath += event.UID + ".ics"
 
-	_, err = client.PutCalendarObject(context.Background(), eventPath, cal)
+	obj, err := client.PutCalendarObject(context.Background(), eventPath, cal)
 	if err != nil {
 		return fmt.Errorf("create event: %w", err)
 	}
 
+	// ETag есть не у всех серверов; без него объект перечитается при следующей синхронизации
+	event.Path = obj.Path
+	event.ETag = obj.ETag
 	return nil
 }
 
@@ -235,66 +251,84 @@ func (c *Client) DeleteEvent(calendarPath, eventUID string) error {
 	return nil
 }
 
-// parseCalendarObject parses a CalDAV object into an Event
-func parseCalendarObject(obj *caldav.CalendarObject) (Event, error) {
-	event := Event{}
+// parseCalendarObject parses a CalDAV object into an Event: the series itself for a recurring one
+func parseCalendarObject(obj *caldav.CalendarObject, loc *time.Location) (Event, error) {
+	event := Event{Path: obj.Path, ETag: obj.ETag}
 
 	if obj.Data == nil {
 		return event, fmt.Errorf("no data in calendar object")
 	}
 
-	cal := obj.Data
+	master, overrides := splitEvents(obj.Data)
+	if master == nil && len(overrides) > 0 {
+		master = overrides[0]
+	}
+	if master == nil {
+		return event, nil
+	}
+	return parseEvent(obj, master, newTimezones(obj.Data, loc)), nil
+}
 
-	// Find VEVENT component
-	for _, comp := range cal.Children {
-		if comp.Name != ical.CompEvent {
-			continue
-		}
+// parseEvent parses one VEVENT of the object
+func parseEvent(obj *caldav.CalendarObject, comp *ical.Component, zones *timezones) Event {
+	event := Event{Path: obj.Path, ETag: obj.ETag}
 
-		// Get UID
-		if prop := comp.Props.Get(ical.PropUID); prop != nil {
-			event.UID = prop.Value
-		}
+	// Get UID
+	if prop := comp.Props.Get(ical.PropUID); prop != nil {
+		event.UID = prop.Value
+	}
 
-		// Get Summary (title)
-		if prop := comp.Props.Get(ical.PropSummary); prop != nil {
-			event.Summary = prop.Value
-		}
+	// Get Summary (title)
+	if prop := comp.Props.Get(ical.PropSummary); prop != nil {
+		event.Summary = prop.Value
+	}
 
-		// Get Description
-		if prop := comp.Props.Get(ical.PropDescription); prop != nil {
-			event.Description = prop.Value
-		}
+	// Get Description
+	if prop := comp.Props.Get(ical.PropDescription); prop != nil {
+		event.Description = prop.Value
+	}
 
-		// Get Location
-		if prop := comp.Props.Get(ical.PropLocation); prop != nil {
-			event.Location = prop.Value
-		}
+	// Get Location
+	if prop := comp.Props.Get(ical.PropLocation); prop != nil {
+		event.Location = prop.Value
+	}
 
-		// Get start time
-		if prop := comp.Props.Get(ical.PropDateTimeStart); prop != nil {
-			t, err := prop.DateTime(time.UTC)
-			if err == nil {
-				event.StartTime = t
-			}
-			// Check if all-day event
-			if valueType := prop.Params.Get(ical.ParamValue); valueType == string(ical.ValueDate) {
-				event.AllDay = true
-			}
+	// Get start time: TZID by the IANA database or VTIMEZONE, dates and floating times by loc
+	if prop := comp.Props.Get(ical.PropDateTimeStart); prop != nil {
+		if _, _, isDate, err := zones.parse(prop); err == nil {
+			event.StartTime, _ = zones.dateTime(prop)
+			event.AllDay = isDate
 		}
+	}
 
-		// Get end time
-		if prop := comp.Props.Get(ical.PropDateTimeEnd); prop != nil {
-			t, err := prop.DateTime(time.UTC)
-			if err == nil {
-				event.EndTime = t
-			}
+	// Get end time, or the start plus DURATION
+	if prop := comp.Props.Get(ical.PropDateTimeEnd); prop != nil {
+		if t, err := zones.dateTime(prop); err == nil {
+			event.EndTime = t
+		}
+	} else if prop := comp.Props.Get(ical.PropDuration); prop != nil && !event.StartTime.IsZero() {
+		if d, err := prop.Duration(); err == nil {
+			event.EndTime = event.StartTime.Add(d)
 		}
+	}
 
-		break // Only process first VEVENT
+	// Get recurrence rule
+	if prop := comp.Props.Get(ical.PropRecurrenceRule); prop != nil {
+		event.RRule = prop.Value
 	}
 
-	return event, nil
+	if prop := comp.Props.Get(ical.PropSequence); prop != nil {
+		if n, err := prop.Int(); err == nil {
+			event.Sequence = n
+		}
+	}
+	if prop := comp.Props.Get(ical.PropLastModified); prop != nil {
+		if t, err := prop.DateTime(time.UTC); err == nil {
+			event.LastModified = t
+		}
+	}
+
+	return event
 }
 
 // eventToICS converts an Event to iCalendar format
@@ -329,12 +363,22 @@ func eventToICS(event *Event) *ical.Calendar {
 	}
 
 	// Add recurrence rule if present
+	// RRULE — не TEXT: SetText экранировал бы ; и , в правиле
 	if event.RRule != "" {
-		vevent.Props.SetText(ical.PropRecurrenceRule, event.RRule)
+		rrule := ical.NewProp(ical.PropRecurrenceRule)
+		rrule.Value = event.RRule
+		vevent.Props.Set(rrule)
+	}
+
+	if event.Sequence > 0 {
+		seq := ical.NewProp(ical.PropSequence)
+		seq.Value = strconv.Itoa(event.Sequence)
+		vevent.Props.Set(seq)
 	}
 
 	// Add creation timestamp
 	vevent.Props.SetDateTime(ical.PropDateTimeStamp, time.Now().UTC())
+	vevent.Props.SetDateTime(ical.PropLastModified, time.Now().UTC())
 
 	cal.Children = append(cal.Children, vevent.Component)
 	return cal
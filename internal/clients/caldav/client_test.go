@@ -0,0 +1,50 @@
+package caldav_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/tazhate/familybot/internal/clients/caldav"
+	"github.com/tazhate/familybot/internal/clients/caldav/caldavtest"
+)
+
+func newClient(t *testing.T) (*caldav.Client, *caldavtest.Server) {
+	t.Helper()
+	server := caldavtest.NewServer()
+	t.Cleanup(server.Close)
+	client := caldav.NewClient(server.URL, "family", "secret")
+	client.SetLocation(time.UTC)
+	return client, server
+}
+
+// TestCreateEventKeepsRRule: RRULE is a RECUR value, not TEXT, so ; and , must not be escaped
+func TestCreateEventKeepsRRule(t *testing.T) {
+	client, server := newClient(t)
+	start := time.Date(2030, time.March, 4, 9, 0, 0, 0, time.UTC) // понедельник
+	event := &caldav.Event{
+		UID:       "english",
+		Summary:   "Английский",
+		StartTime: start,
+		EndTime:   start.Add(time.Hour),
+		RRule:     "FREQ=WEEKLY;BYDAY=MO,WE;COUNT=4",
+	}
+	if err := client.CreateEvent(server.CalendarPath(), event); err != nil {
+		t.Fatal(err)
+	}
+
+	stored, err := client.GetEvent(event.Path)
+	if err != nil || stored == nil {
+		t.Fatalf("get event: %v, %v", stored, err)
+	}
+	if stored.RRule != event.RRule {
+		t.Errorf("stored RRULE %q, want %q", stored.RRule, event.RRule)
+	}
+
+	occurrences, err := client.GetEvents(server.CalendarPath(), start.AddDate(0, 0, -1), start.AddDate(0, 1, 0))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(occurrences) != 4 {
+		t.Errorf("%d occurrences, want 4", len(occurrences))
+	}
+}
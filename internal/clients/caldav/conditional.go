@@ -0,0 +1,133 @@
+package caldav
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/emersion/go-ical"
+	"github.com/emersion/go-webdav/caldav"
+)
+
+// Условная запись (If-Match): бот меняет объект, только если на сервере он той же
+// версии, что была при последней синхронизации. Иначе правку из календаря не затереть.
+
+// ErrPreconditionFailed is returned by conditional writes when the object changed on the server
+var ErrPreconditionFailed = errors.New("calendar object changed on the server")
+
+// GetEvent fetches the calendar object by its path; nil if it doesn't exist
+func (c *Client) GetEvent(objectPath string) (*Event, error) {
+	resp, err := c.do(http.MethodGet, objectPath, nil, http.Header{"Accept": {ical.MIMEType}})
+	if err != nil {
+		return nil, fmt.Errorf("get event: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusNotFound {
+		return nil, nil
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("get event: HTTP %d", resp.StatusCode)
+	}
+
+	cal, err := ical.NewDecoder(resp.Body).Decode()
+	if err != nil {
+		return nil, fmt.Errorf("decode event: %w", err)
+	}
+	event, err := parseCalendarObject(&caldav.CalendarObject{Path: objectPath, ETag: responseETag(resp), Data: cal}, c.location)
+	if err != nil {
+		return nil, err
+	}
+	return &event, nil
+}
+
+// PutEventIfMatch writes the event to its object (event.Path) only if the object on the
+// server still has the etag; an empty etag writes unconditionally. Sets event.ETag to the
+// new version, "" if the server didn't return it.
+func (c *Client) PutEventIfMatch(event *Event, etag string) error {
+	if event.Path == "" {
+		return fmt.Errorf("event path not specified")
+	}
+
+	var buf bytes.Buffer
+	if err := ical.NewEncoder(&buf).Encode(eventToICS(event)); err != nil {
+		return fmt.Errorf("encode event: %w", err)
+	}
+	header := http.Header{"Content-Type": {ical.MIMEType}}
+	if etag != "" {
+		header.Set("If-Match", strconv.Quote(etag))
+	}
+
+	resp, err := c.do(http.MethodPut, event.Path, &buf, header)
+	if err != nil {
+		return fmt.Errorf("put event: %w", err)
+	}
+	resp.Body.Close()
+	switch {
+	case resp.StatusCode == http.StatusPreconditionFailed:
+		return ErrPreconditionFailed
+	case resp.StatusCode/100 != 2:
+		return fmt.Errorf("put event: HTTP %d", resp.StatusCode)
+	}
+	event.ETag = responseETag(resp)
+	return nil
+}
+
+// DeleteObjectIfMatch deletes the object only if it still has the etag; an empty etag
+// deletes unconditionally. An object that is already gone is not an error.
+func (c *Client) DeleteObjectIfMatch(objectPath, etag string) error {
+	var header http.Header
+	if etag != "" {
+		header = http.Header{"If-Match": {strconv.Quote(etag)}}
+	}
+
+	resp, err := c.do(http.MethodDelete, objectPath, nil, header)
+	if err != nil {
+		return fmt.Errorf("delete event: %w", err)
+	}
+	resp.Body.Close()
+	switch {
+	case resp.StatusCode == http.StatusPreconditionFailed:
+		return ErrPreconditionFailed
+	case resp.StatusCode == http.StatusNotFound:
+		return nil
+	case resp.StatusCode/100 != 2:
+		return fmt.Errorf("delete event: HTTP %d", resp.StatusCode)
+	}
+	return nil
+}
+
+// do sends a request to the path on the server with the account's credentials
+func (c *Client) do(method, p string, body io.Reader, header http.Header) (*http.Response, error) {
+	if _, err := c.connect(); err != nil {
+		return nil, err
+	}
+
+	base, err := url.Parse(c.baseURL)
+	if err != nil {
+		return nil, fmt.Errorf("parse base URL: %w", err)
+	}
+	target := base.ResolveReference(&url.URL{Path: p})
+
+	req, err := http.NewRequest(method, target.String(), body)
+	if err != nil {
+		return nil, err
+	}
+	for k, v := range header {
+		req.Header[k] = v
+	}
+	return c.httpClient.Do(req)
+}
+
+// responseETag returns the unquoted ETag of the response, "" if there is none
+func responseETag(resp *http.Response) string {
+	etag := strings.TrimPrefix(resp.Header.Get("ETag"), "W/")
+	if unquoted, err := strconv.Unquote(etag); err == nil {
+		return unquoted
+	}
+	return etag
+}
@@ -13,6 +13,8 @@ type Calendar struct {
 // Event represents a calendar event
 type Event struct {
 	UID         string // Unique ID in CalDAV
+	Path        string // Path of the calendar object on the server
+	ETag        string // Version of the calendar object, changes on every edit
 	Summary     string // Title
 	Description string
 	Location    string
@@ -21,9 +23,29 @@ type Event struct {
 	AllDay      bool
 	Reminders   []Reminder
 	RRule       string // Recurrence rule (e.g., "FREQ=WEEKLY;BYDAY=MO")
+
+	// RecurrenceID identifies an occurrence of a recurring event: its original start,
+	// "20060102T150405Z" or "20060102" for all-day events; "" for a single event
+	RecurrenceID string
+
+	Sequence     int       // SEQUENCE: revision number, grows on significant changes
+	LastModified time.Time // LAST-MODIFIED, zero if the server didn't set it
 }
 
 // Reminder represents an event reminder
 type Reminder struct {
 	MinutesBefore int
 }
+
+// ObjectRef is a calendar object path with its current ETag
+type ObjectRef struct {
+	Path string
+	ETag string
+}
+
+// Changes is the result of a sync-collection report (RFC 6578)
+type Changes struct {
+	SyncToken string      // Token for the next sync
+	Updated   []ObjectRef // New or changed objects
+	Deleted   []string    // Paths of removed objects
+}
@@ -0,0 +1,257 @@
+package caldav
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/emersion/go-ical"
+	"github.com/emersion/go-webdav/caldav"
+	"github.com/teambition/rrule-go"
+)
+
+// Повторяющиеся события: объект календаря хранит серию (RRULE, RDATE, EXDATE) и её
+// изменённые экземпляры — VEVENT с тем же UID и RECURRENCE-ID. Серия разворачивается
+// в отдельные события в окне синхронизации. У каждого экземпляра постоянный
+// RecurrenceID — исходное время начала, по нему экземпляр находится при следующей синхронизации.
+
+const (
+	// maxOccurrences limits the occurrences of one series in the window
+	maxOccurrences = 1000
+	// maxIterations limits the rule steps, e.g. FREQ=MINUTELY started years ago
+	maxIterations = 100 * maxOccurrences
+)
+
+// expandCalendarObject parses a CalDAV object into events: a single event as is, a recurring
+// one as its occurrences that overlap from–to. Floating times are in loc.
+func expandCalendarObject(obj *caldav.CalendarObject, loc *time.Location, from, to time.Time) ([]Event, error) {
+	if obj.Data == nil {
+		return nil, fmt.Errorf("no data in calendar object")
+	}
+	zones := newTimezones(obj.Data, loc)
+	master, overrides := splitEvents(obj.Data)
+
+	if master == nil {
+		// Приглашение на отдельные экземпляры чужой серии
+		var events []Event
+		for _, comp := range overrides {
+			if event, key, ok := parseOverride(obj, comp, zones); ok {
+				event.RecurrenceID = key
+				events = append(events, event)
+			}
+		}
+		return events, nil
+	}
+	event := parseEvent(obj, master, zones)
+	if !isRecurring(master) {
+		return []Event{event}, nil
+	}
+	events, err := expandSeries(obj, master, overrides, event, zones, from, to)
+	if err != nil {
+		// Правило не разобрать — серия остаётся одним событием
+		return []Event{event}, nil
+	}
+	return events, nil
+}
+
+// expandSeries generates the occurrences of the series between from and to; overridden
+// occurrences take the fields of their VEVENT, cancelled ones are skipped
+func expandSeries(obj *caldav.CalendarObject, master *ical.Component, overrides []*ical.Component, base Event, zones *timezones, from, to time.Time) ([]Event, error) {
+	start, zn, allDay, err := zones.parse(master.Props.Get(ical.PropDateTimeStart))
+	if err != nil {
+		return nil, fmt.Errorf("parse DTSTART: %w", err)
+	}
+	// Длительность по часам на стене: встреча 10:00–11:00 остаётся такой и после перевода стрелок
+	var length time.Duration
+	if !base.EndTime.IsZero() {
+		length = zn.wall(base.EndTime).Sub(start)
+	}
+
+	set, err := recurrenceSet(master, start, zn, allDay, zones)
+	if err != nil {
+		return nil, err
+	}
+
+	byKey := make(map[string]*ical.Component)
+	for _, comp := range overrides {
+		if key, ok := recurrenceKey(comp.Props.Get(ical.PropRecurrenceID), zones); ok {
+			byKey[key] = comp
+		}
+	}
+
+	var events []Event
+	used := make(map[string]bool)
+	fromWall, toWall := zn.wall(from).Add(-length), zn.wall(to)
+	next := set.Iterator()
+	for i := 0; i < maxIterations && len(events) < maxOccurrences; i++ {
+		wall, ok := next()
+		if !ok || !wall.Before(toWall) {
+			break
+		}
+		// Экземпляр, закончившийся ровно в from, не попадает в окно; без длительности — попадает
+		if wall.Before(fromWall) || length > 0 && wall.Equal(fromWall) {
+			continue
+		}
+
+		instant := zn.instant(wall)
+		key := occurrenceKey(wall, instant, allDay)
+		if comp := byKey[key]; comp != nil {
+			used[key] = true
+			if override, _, ok := parseOverride(obj, comp, zones); ok {
+				override.RecurrenceID = key
+				events = append(events, inherit(override, base))
+			}
+			continue
+		}
+
+		event := base
+		event.RecurrenceID = key
+		event.StartTime = instant
+		if !base.EndTime.IsZero() {
+			event.EndTime = zn.instant(wall.Add(length))
+		}
+		events = append(events, event)
+	}
+
+	// Экземпляры, перенесённые в окно из-за его границ
+	for key, comp := range byKey {
+		if used[key] {
+			continue
+		}
+		override, _, ok := parseOverride(obj, comp, zones)
+		if !ok || !override.StartTime.Before(to) || override.StartTime.Before(from) {
+			continue
+		}
+		override.RecurrenceID = key
+		events = append(events, inherit(override, base))
+	}
+	return events, nil
+}
+
+// recurrenceSet builds the set of the series' start times on the wall clock of DTSTART
+func recurrenceSet(master *ical.Component, start time.Time, zn zone, allDay bool, zones *timezones) (*rrule.Set, error) {
+	set := &rrule.Set{}
+	set.DTStart(start)
+	// DTSTART — всегда первый экземпляр, даже если не подходит под правило
+	set.RDate(start)
+
+	if prop := master.Props.Get(ical.PropRecurrenceRule); prop != nil {
+		opt, err := rrule.StrToROption(prop.Value)
+		if err != nil {
+			return nil, fmt.Errorf("parse RRULE: %w", err)
+		}
+		opt.Dtstart = start
+		if !opt.Until.IsZero() {
+			opt.Until = ruleUntil(prop.Value, opt.Until, zn, allDay)
+		}
+		rule, err := rrule.NewRRule(*opt)
+		if err != nil {
+			return nil, fmt.Errorf("parse RRULE: %w", err)
+		}
+		set.RRule(rule)
+	}
+
+	dates := func(name string) []time.Time {
+		var times []time.Time
+		for _, prop := range master.Props.Values(name) {
+			for _, v := range strings.Split(prop.Value, ",") {
+				if wall, vzn, _, err := zones.parseValue(&prop, v); err == nil {
+					times = append(times, zn.wall(vzn.instant(wall)))
+				}
+			}
+		}
+		return times
+	}
+	for _, t := range dates(ical.PropRecurrenceDates) {
+		set.RDate(t)
+	}
+	for _, t := range dates(ical.PropExceptionDates) {
+		set.ExDate(t)
+	}
+	return set, nil
+}
+
+// ruleUntil moves UNTIL to the wall clock of DTSTART: in UTC it is an instant, and a date
+// for a timed series means the whole day
+func ruleUntil(rule string, until time.Time, zn zone, allDay bool) time.Time {
+	for _, part := range strings.Split(rule, ";") {
+		name, value, _ := strings.Cut(part, "=")
+		if !strings.EqualFold(name, "UNTIL") {
+			continue
+		}
+		switch {
+		case strings.HasSuffix(value, "Z"):
+			return zn.wall(until)
+		case len(value) == len(dateFormat) && !allDay:
+			return until.Add(24*time.Hour - time.Second)
+		}
+	}
+	return until
+}
+
+// splitEvents returns the VEVENT of the series (or the single event) and its overridden occurrences
+func splitEvents(cal *ical.Calendar) (master *ical.Component, overrides []*ical.Component) {
+	for _, comp := range cal.Children {
+		if comp.Name != ical.CompEvent {
+			continue
+		}
+		if comp.Props.Get(ical.PropRecurrenceID) != nil {
+			overrides = append(overrides, comp)
+		} else if master == nil {
+			master = comp
+		}
+	}
+	return master, overrides
+}
+
+func isRecurring(comp *ical.Component) bool {
+	return comp.Props.Get(ical.PropRecurrenceRule) != nil || comp.Props.Get(ical.PropRecurrenceDates) != nil
+}
+
+// parseOverride parses an overridden occurrence; false if it is cancelled or has no RECURRENCE-ID
+func parseOverride(obj *caldav.CalendarObject, comp *ical.Component, zones *timezones) (Event, string, bool) {
+	if status, _ := comp.Props.Text(ical.PropStatus); strings.EqualFold(status, string(ical.EventCancelled)) {
+		return Event{}, "", false
+	}
+	key, ok := recurrenceKey(comp.Props.Get(ical.PropRecurrenceID), zones)
+	if !ok {
+		return Event{}, "", false
+	}
+	return parseEvent(obj, comp, zones), key, true
+}
+
+// recurrenceKey returns the RecurrenceID of the occurrence the RECURRENCE-ID points to
+func recurrenceKey(prop *ical.Prop, zones *timezones) (string, bool) {
+	if prop == nil {
+		return "", false
+	}
+	wall, zn, isDate, err := zones.parse(prop)
+	if err != nil {
+		return "", false
+	}
+	return occurrenceKey(wall, zn.instant(wall), isDate), true
+}
+
+// occurrenceKey formats the original start of the occurrence: a date for an all-day
+// series, the instant in UTC otherwise
+func occurrenceKey(wall, instant time.Time, allDay bool) string {
+	if allDay {
+		return wall.Format(dateFormat)
+	}
+	return instant.UTC().Format(dateTimeUTCFormat)
+}
+
+// inherit fills the fields an overridden occurrence left out from its series
+func inherit(event, series Event) Event {
+	if event.Summary == "" {
+		event.Summary = series.Summary
+	}
+	if event.Description == "" {
+		event.Description = series.Description
+	}
+	if event.Location == "" {
+		event.Location = series.Location
+	}
+	event.RRule = series.RRule
+	return event
+}
@@ -0,0 +1,161 @@
+package caldav_test
+
+import (
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/tazhate/familybot/internal/clients/caldav"
+	"github.com/tazhate/familybot/internal/clients/caldav/caldavtest"
+)
+
+// putSeries stores an object of the given VEVENT lines: a series and its overridden occurrences
+func putSeries(t *testing.T, server *caldavtest.Server, uid string, lines ...string) {
+	t.Helper()
+	ics := append([]string{"BEGIN:VCALENDAR", "VERSION:2.0", "PRODID:-//FamilyBot//test//EN"}, lines...)
+	ics = append(ics, "END:VCALENDAR", "")
+	if _, err := server.PutObject(uid, strings.Join(ics, "\r\n")); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// occurrences returns the events between from and to as "RecurrenceID start–end summary", in UTC
+func occurrences(t *testing.T, client *caldav.Client, server *caldavtest.Server, from, to time.Time) []string {
+	t.Helper()
+	events, err := client.GetEvents(server.CalendarPath(), from, to)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, e := range events {
+		end := "-"
+		if !e.EndTime.IsZero() {
+			end = e.EndTime.UTC().Format("02.01 15:04")
+		}
+		got = append(got, e.RecurrenceID+" "+e.StartTime.UTC().Format("02.01 15:04")+"–"+end+" "+e.Summary)
+	}
+	slices.Sort(got)
+	return got
+}
+
+// TestRecurrenceKeepsWallClock: a weekly 10:00 in Berlin stays 10:00 after the clocks go
+// forward on 31 March, and UNTIL in UTC includes the last occurrence
+func TestRecurrenceKeepsWallClock(t *testing.T) {
+	client, server := newClient(t)
+	putSeries(t, server, "yoga",
+		"BEGIN:VEVENT",
+		"UID:yoga",
+		"DTSTAMP:20300301T000000Z",
+		"DTSTART;TZID=Europe/Berlin:20300320T100000",
+		"DTEND;TZID=Europe/Berlin:20300320T110000",
+		"RRULE:FREQ=WEEKLY;UNTIL=20300403T080000Z",
+		"SUMMARY:Йога",
+		"END:VEVENT",
+	)
+	got := occurrences(t, client, server, time.Date(2030, time.March, 1, 0, 0, 0, 0, time.UTC), time.Date(2030, time.May, 1, 0, 0, 0, 0, time.UTC))
+	want := []string{
+		"20300320T090000Z 20.03 09:00–20.03 10:00 Йога",
+		"20300327T090000Z 27.03 09:00–27.03 10:00 Йога",
+		"20300403T080000Z 03.04 08:00–03.04 09:00 Йога",
+	}
+	if !slices.Equal(got, want) {
+		t.Errorf("occurrences %q, want %q", got, want)
+	}
+}
+
+// TestRecurrenceExceptions: EXDATE removes an occurrence, RECURRENCE-ID moves one and
+// cancels another
+func TestRecurrenceExceptions(t *testing.T) {
+	client, server := newClient(t)
+	putSeries(t, server, "standup",
+		"BEGIN:VEVENT",
+		"UID:standup",
+		"DTSTAMP:20300301T000000Z",
+		"DTSTART:20300304T090000Z",
+		"DTEND:20300304T091500Z",
+		"RRULE:FREQ=DAILY;COUNT=5",
+		"EXDATE:20300305T090000Z",
+		"SUMMARY:Созвон",
+		"LOCATION:Zoom",
+		"END:VEVENT",
+		"BEGIN:VEVENT",
+		"UID:standup",
+		"DTSTAMP:20300301T000000Z",
+		"RECURRENCE-ID:20300306T090000Z",
+		"DTSTART:20300306T150000Z",
+		"DTEND:20300306T151500Z",
+		"END:VEVENT",
+		"BEGIN:VEVENT",
+		"UID:standup",
+		"DTSTAMP:20300301T000000Z",
+		"RECURRENCE-ID:20300307T090000Z",
+		"DTSTART:20300307T090000Z",
+		"DTEND:20300307T091500Z",
+		"STATUS:CANCELLED",
+		"END:VEVENT",
+	)
+	from, to := time.Date(2030, time.March, 1, 0, 0, 0, 0, time.UTC), time.Date(2030, time.April, 1, 0, 0, 0, 0, time.UTC)
+	got := occurrences(t, client, server, from, to)
+	want := []string{
+		"20300304T090000Z 04.03 09:00–04.03 09:15 Созвон",
+		"20300306T090000Z 06.03 15:00–06.03 15:15 Созвон", // перенесённый берёт название серии
+		"20300308T090000Z 08.03 09:00–08.03 09:15 Созвон",
+	}
+	if !slices.Equal(got, want) {
+		t.Errorf("occurrences %q, want %q", got, want)
+	}
+
+	// Перенесённый за границу окна экземпляр попадает туда, куда перенесён
+	got = occurrences(t, client, server, time.Date(2030, time.March, 6, 12, 0, 0, 0, time.UTC), time.Date(2030, time.March, 7, 0, 0, 0, 0, time.UTC))
+	if !slices.Equal(got, want[1:2]) {
+		t.Errorf("occurrences on 6 March afternoon %q, want %q", got, want[1:2])
+	}
+}
+
+// TestRecurrenceAllDayUntil: UNTIL as a date includes the last day of an all-day series
+func TestRecurrenceAllDayUntil(t *testing.T) {
+	client, server := newClient(t)
+	putSeries(t, server, "trip",
+		"BEGIN:VEVENT",
+		"UID:trip",
+		"DTSTAMP:20300301T000000Z",
+		"DTSTART;VALUE=DATE:20300304",
+		"DTEND;VALUE=DATE:20300305",
+		"RRULE:FREQ=DAILY;UNTIL=20300306",
+		"SUMMARY:Дача",
+		"END:VEVENT",
+	)
+	got := occurrences(t, client, server, time.Date(2030, time.March, 1, 0, 0, 0, 0, time.UTC), time.Date(2030, time.April, 1, 0, 0, 0, 0, time.UTC))
+	want := []string{
+		"20300304 04.03 00:00–05.03 00:00 Дача",
+		"20300305 05.03 00:00–06.03 00:00 Дача",
+		"20300306 06.03 00:00–07.03 00:00 Дача",
+	}
+	if !slices.Equal(got, want) {
+		t.Errorf("occurrences %q, want %q", got, want)
+	}
+}
+
+// TestRecurrenceWithoutEnd: an occurrence without DTEND that starts exactly at the window
+// start is in the window, one at its end is not
+func TestRecurrenceWithoutEnd(t *testing.T) {
+	client, server := newClient(t)
+	putSeries(t, server, "pills",
+		"BEGIN:VEVENT",
+		"UID:pills",
+		"DTSTAMP:20300301T000000Z",
+		"DTSTART:20300304T090000Z",
+		"RRULE:FREQ=DAILY;COUNT=5",
+		"SUMMARY:Таблетки",
+		"END:VEVENT",
+	)
+	got := occurrences(t, client, server, time.Date(2030, time.March, 5, 9, 0, 0, 0, time.UTC), time.Date(2030, time.March, 7, 9, 0, 0, 0, time.UTC))
+	want := []string{
+		"20300305T090000Z 05.03 09:00–- Таблетки",
+		"20300306T090000Z 06.03 09:00–- Таблетки",
+	}
+	if !slices.Equal(got, want) {
+		t.Errorf("occurrences %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,182 @@
+package caldav
+
+import (
+	"context"
+	"encoding/xml"
+	"fmt"
+	"net/http"
+	"net/url"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/emersion/go-webdav/caldav"
+)
+
+// Инкрементальная синхронизация: sync-collection (RFC 6578), а для серверов без
+// него — ctag коллекции (расширение calendarserver.org) и etag каждого объекта.
+
+// multiGetBatch — сколько объектов запрашивать одним calendar-multiget
+const multiGetBatch = 50
+
+// SyncCollection returns the objects changed since the token; an empty token lists all objects.
+// Fails if the server doesn't support sync-collection or no longer accepts the token.
+func (c *Client) SyncCollection(calendarPath, token string) (*Changes, error) {
+	client, err := c.connect()
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := client.SyncCollection(context.Background(), calendarPath, &caldav.SyncQuery{SyncToken: token})
+	if err != nil {
+		return nil, fmt.Errorf("sync collection: %w", err)
+	}
+	if resp.SyncToken == "" {
+		return nil, fmt.Errorf("sync collection: server returned no sync-token")
+	}
+
+	changes := &Changes{SyncToken: resp.SyncToken, Deleted: resp.Deleted}
+	for _, obj := range resp.Updated {
+		if isCollection(calendarPath, obj.Path) {
+			continue
+		}
+		changes.Updated = append(changes.Updated, ObjectRef{Path: obj.Path, ETag: obj.ETag})
+	}
+	return changes, nil
+}
+
+// ListObjects returns the paths and ETags of all objects in the calendar
+func (c *Client) ListObjects(calendarPath string) ([]ObjectRef, error) {
+	client, err := c.connect()
+	if err != nil {
+		return nil, err
+	}
+
+	files, err := client.ReadDir(context.Background(), calendarPath, false)
+	if err != nil {
+		return nil, fmt.Errorf("list calendar objects: %w", err)
+	}
+
+	var refs []ObjectRef
+	for _, f := range files {
+		if f.IsDir || isCollection(calendarPath, f.Path) {
+			continue
+		}
+		refs = append(refs, ObjectRef{Path: f.Path, ETag: f.ETag})
+	}
+	return refs, nil
+}
+
+// GetEventsByPath fetches the calendar objects by their paths. Recurring events are expanded
+// into their occurrences between from and to; objects without a VEVENT (tasks, journals) are skipped.
+func (c *Client) GetEventsByPath(calendarPath string, paths []string, from, to time.Time) ([]Event, error) {
+	client, err := c.connect()
+	if err != nil {
+		return nil, err
+	}
+
+	var events []Event
+	for start := 0; start < len(paths); start += multiGetBatch {
+		end := min(start+multiGetBatch, len(paths))
+		objects, err := client.MultiGetCalendar(context.Background(), calendarPath, &caldav.CalendarMultiGet{Paths: paths[start:end]})
+		if err != nil {
+			return nil, fmt.Errorf("multiget calendar: %w", err)
+		}
+		for _, obj := range objects {
+			expanded, err := expandCalendarObject(&obj, c.location, from, to)
+			if err != nil {
+				continue
+			}
+			for _, event := range expanded {
+				if event.UID != "" {
+					events = append(events, event)
+				}
+			}
+		}
+	}
+	return events, nil
+}
+
+// QueryObjects returns the objects with events between from and to, recurring ones
+// included if any of their occurrences falls there
+func (c *Client) QueryObjects(calendarPath string, from, to time.Time) ([]ObjectRef, error) {
+	client, err := c.connect()
+	if err != nil {
+		return nil, err
+	}
+
+	query := &caldav.CalendarQuery{
+		CompFilter: caldav.CompFilter{
+			Name:  "VCALENDAR",
+			Comps: []caldav.CompFilter{{Name: "VEVENT", Start: from, End: to}},
+		},
+	}
+	objects, err := client.QueryCalendar(context.Background(), calendarPath, query)
+	if err != nil {
+		return nil, fmt.Errorf("query calendar: %w", err)
+	}
+
+	refs := make([]ObjectRef, 0, len(objects))
+	for _, obj := range objects {
+		refs = append(refs, ObjectRef{Path: obj.Path, ETag: obj.ETag})
+	}
+	return refs, nil
+}
+
+// GetCTag returns the ctag of the calendar, "" if the server doesn't provide it
+func (c *Client) GetCTag(calendarPath string) (string, error) {
+	if _, err := c.connect(); err != nil {
+		return "", err
+	}
+
+	base, err := url.Parse(c.baseURL)
+	if err != nil {
+		return "", fmt.Errorf("parse base URL: %w", err)
+	}
+	target := base.ResolveReference(&url.URL{Path: calendarPath})
+
+	body := `<?xml version="1.0" encoding="utf-8"?>` +
+		`<d:propfind xmlns:d="DAV:" xmlns:cs="http://calendarserver.org/ns/"><d:prop><cs:getctag/></d:prop></d:propfind>`
+	req, err := http.NewRequest("PROPFIND", target.String(), strings.NewReader(body))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", "application/xml; charset=utf-8")
+	req.Header.Set("Depth", "0")
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("propfind ctag: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusMultiStatus {
+		return "", fmt.Errorf("propfind ctag: HTTP %d", resp.StatusCode)
+	}
+
+	var ms struct {
+		Responses []struct {
+			Propstats []struct {
+				Status string `xml:"DAV: status"`
+				Prop   struct {
+					CTag string `xml:"http://calendarserver.org/ns/ getctag"`
+				} `xml:"DAV: prop"`
+			} `xml:"DAV: propstat"`
+		} `xml:"DAV: response"`
+	}
+	if err := xml.NewDecoder(resp.Body).Decode(&ms); err != nil {
+		return "", fmt.Errorf("decode ctag: %w", err)
+	}
+	for _, r := range ms.Responses {
+		for _, ps := range r.Propstats {
+			if strings.Contains(ps.Status, " 200 ") && ps.Prop.CTag != "" {
+				return ps.Prop.CTag, nil
+			}
+		}
+	}
+	return "", nil
+}
+
+// isCollection reports whether p is the calendar collection itself
+func isCollection(calendarPath, p string) bool {
+	return path.Clean(p) == path.Clean(calendarPath)
+}
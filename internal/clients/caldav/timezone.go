@@ -0,0 +1,353 @@
+package caldav
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/emersion/go-ical"
+	"github.com/teambition/rrule-go"
+)
+
+// Часовые пояса объекта календаря. TZID бывает именем из базы IANA ("Europe/Moscow"),
+// именем с префиксом ("/mozilla.org/20050126_1/Europe/Berlin") или произвольным
+// ("Russian Standard Time" у Outlook) — тогда правила перехода берутся из блока VTIMEZONE.
+// Время без пояса («плавающее») и даты событий на весь день — по часовому поясу бота.
+
+const (
+	dateFormat        = "20060102"
+	dateTimeFormat    = "20060102T150405"
+	dateTimeUTCFormat = "20060102T150405Z"
+)
+
+// zone converts between wall clock times and instants. Wall clock times are kept as times
+// in UTC with the local clock reading: recurrence rules are expanded on them, so "every
+// Monday at 10:00" stays at 10:00 across DST changes.
+type zone interface {
+	instant(wall time.Time) time.Time
+	wall(t time.Time) time.Time
+}
+
+// locationZone is a zone from the IANA database
+type locationZone struct {
+	loc *time.Location
+}
+
+func (z locationZone) instant(wall time.Time) time.Time {
+	return time.Date(wall.Year(), wall.Month(), wall.Day(), wall.Hour(), wall.Minute(), wall.Second(), 0, z.loc)
+}
+
+func (z locationZone) wall(t time.Time) time.Time {
+	t = t.In(z.loc)
+	return time.Date(t.Year(), t.Month(), t.Day(), t.Hour(), t.Minute(), t.Second(), 0, time.UTC)
+}
+
+// vtimezone is a zone defined by a VTIMEZONE block: its offsets and the rules of switching between them
+type vtimezone struct {
+	observances []observance
+}
+
+// observance is a STANDARD or DAYLIGHT part of a VTIMEZONE
+type observance struct {
+	onset      time.Time // DTSTART: wall clock time of the first switch, in the previous offset
+	rule       *rrule.RRule
+	rdates     []time.Time
+	offsetFrom time.Duration
+	offsetTo   time.Duration
+}
+
+func (z *vtimezone) instant(wall time.Time) time.Time {
+	// Смещение зависит от момента, а момент — от смещения: второе приближение точно,
+	// кроме часа перевода стрелок
+	t := wall.Add(-z.offset(wall))
+	return wall.Add(-z.offset(t))
+}
+
+func (z *vtimezone) wall(t time.Time) time.Time {
+	return t.UTC().Add(z.offset(t))
+}
+
+// offset returns the UTC offset in effect at the instant t
+func (z *vtimezone) offset(t time.Time) time.Duration {
+	var last time.Time
+	var offset time.Duration
+	found := false
+	for i := range z.observances {
+		o := &z.observances[i]
+		if switched, ok := o.lastSwitch(t); ok && (!found || switched.After(last)) {
+			last, offset, found = switched, o.offsetTo, true
+		}
+	}
+	if found {
+		return offset
+	}
+
+	// До первого перехода действует смещение, с которого перешли в самый ранний раз
+	first := &z.observances[0]
+	for i := range z.observances {
+		if z.observances[i].onset.Before(first.onset) {
+			first = &z.observances[i]
+		}
+	}
+	return first.offsetFrom
+}
+
+// lastSwitch returns the instant of the last switch to the observance at or before t
+func (o *observance) lastSwitch(t time.Time) (time.Time, bool) {
+	wall := t.UTC().Add(o.offsetFrom)
+	var last time.Time
+	if !o.onset.After(wall) {
+		last = o.onset
+	}
+	if o.rule != nil {
+		if r := o.rule.Before(wall, true); r.After(last) {
+			last = r
+		}
+	}
+	for _, d := range o.rdates {
+		if !d.After(wall) && d.After(last) {
+			last = d
+		}
+	}
+	if last.IsZero() {
+		return time.Time{}, false
+	}
+	return last.Add(-o.offsetFrom), true
+}
+
+// timezones resolves the TZID parameters of one calendar object
+type timezones struct {
+	floating zone
+	blocks   map[string]*ical.Component // VTIMEZONE by TZID
+	zones    map[string]zone
+}
+
+// newTimezones collects the VTIMEZONE blocks of the object; floating times are in loc
+func newTimezones(cal *ical.Calendar, loc *time.Location) *timezones {
+	if loc == nil {
+		loc = time.UTC
+	}
+	z := &timezones{
+		floating: locationZone{loc},
+		blocks:   make(map[string]*ical.Component),
+		zones:    make(map[string]zone),
+	}
+	for _, comp := range cal.Children {
+		if comp.Name != ical.CompTimezone {
+			continue
+		}
+		if tzid, err := comp.Props.Text(ical.PropTimezoneID); err == nil && tzid != "" {
+			z.blocks[tzid] = comp
+		}
+	}
+	return z
+}
+
+// zone returns the zone of the TZID
+func (z *timezones) zone(tzid string) zone {
+	if zn, ok := z.zones[tzid]; ok {
+		return zn
+	}
+	zn := z.resolve(tzid)
+	z.zones[tzid] = zn
+	return zn
+}
+
+func (z *timezones) resolve(tzid string) zone {
+	if loc := loadLocation(tzid); loc != nil {
+		return locationZone{loc}
+	}
+	if block := z.blocks[tzid]; block != nil {
+		if vtz, err := parseVTimezone(block); err == nil {
+			return vtz
+		}
+	}
+	return z.floating
+}
+
+// loadLocation finds the TZID in the IANA database, also as the tail of a prefixed name
+func loadLocation(tzid string) *time.Location {
+	tzid = strings.Trim(tzid, `"`)
+	if tzid == "" || tzid == "Local" {
+		return nil
+	}
+	if loc, err := time.LoadLocation(tzid); err == nil {
+		return loc
+	}
+	parts := strings.Split(strings.Trim(tzid, "/"), "/")
+	for n := min(3, len(parts)-1); n >= 2; n-- {
+		if loc, err := time.LoadLocation(strings.Join(parts[len(parts)-n:], "/")); err == nil {
+			return loc
+		}
+	}
+	return nil
+}
+
+// parse reads a DATE or DATE-TIME value: the wall clock time, its zone and whether it is a date
+func (z *timezones) parse(prop *ical.Prop) (wall time.Time, zn zone, isDate bool, err error) {
+	return z.parseValue(prop, prop.Value)
+}
+
+// parseValue reads one value of the property; RDATE and EXDATE may hold several
+func (z *timezones) parseValue(prop *ical.Prop, value string) (time.Time, zone, bool, error) {
+	value, _, _ = strings.Cut(value, "/") // PERIOD: начало периода
+	switch {
+	case prop.Params.Get(ical.ParamValue) == string(ical.ValueDate) || len(value) == len(dateFormat):
+		t, err := time.Parse(dateFormat, value)
+		return t, z.floating, true, err
+	case strings.HasSuffix(value, "Z"):
+		t, err := time.Parse(dateTimeUTCFormat, value)
+		return t, locationZone{time.UTC}, false, err
+	}
+	t, err := time.Parse(dateTimeFormat, value)
+	if tzid := prop.Params.Get(ical.PropTimezoneID); tzid != "" {
+		return t, z.zone(tzid), false, err
+	}
+	return t, z.floating, false, err
+}
+
+// dateTime returns the instant of a DATE or DATE-TIME property
+func (z *timezones) dateTime(prop *ical.Prop) (time.Time, error) {
+	wall, zn, _, err := z.parse(prop)
+	if err != nil {
+		return time.Time{}, err
+	}
+	return zn.instant(wall), nil
+}
+
+// parseVTimezone reads the STANDARD and DAYLIGHT parts of a VTIMEZONE block
+func parseVTimezone(block *ical.Component) (*vtimezone, error) {
+	vtz := &vtimezone{}
+	for _, comp := range block.Children {
+		if comp.Name != ical.CompTimezoneStandard && comp.Name != ical.CompTimezoneDaylight {
+			continue
+		}
+		var o observance
+		var err error
+		if o.offsetFrom, err = parseUTCOffset(comp.Props.Get(ical.PropTimezoneOffsetFrom)); err != nil {
+			return nil, err
+		}
+		if o.offsetTo, err = parseUTCOffset(comp.Props.Get(ical.PropTimezoneOffsetTo)); err != nil {
+			return nil, err
+		}
+		prop := comp.Props.Get(ical.PropDateTimeStart)
+		if prop == nil {
+			return nil, fmt.Errorf("observance without DTSTART")
+		}
+		if o.onset, err = time.Parse(dateTimeFormat, prop.Value); err != nil {
+			return nil, fmt.Errorf("observance DTSTART: %w", err)
+		}
+		if prop := comp.Props.Get(ical.PropRecurrenceRule); prop != nil {
+			opt, err := rrule.StrToROption(prop.Value)
+			if err != nil {
+				return nil, fmt.Errorf("observance RRULE: %w", err)
+			}
+			opt.Dtstart = o.onset
+			if o.rule, err = rrule.NewRRule(*opt); err != nil {
+				return nil, fmt.Errorf("observance RRULE: %w", err)
+			}
+		}
+		for _, prop := range comp.Props.Values(ical.PropRecurrenceDates) {
+			for _, v := range strings.Split(prop.Value, ",") {
+				if t, err := time.Parse(dateTimeFormat, v); err == nil {
+					o.rdates = append(o.rdates, t)
+				}
+			}
+		}
+		vtz.observances = append(vtz.observances, o)
+	}
+	if len(vtz.observances) == 0 {
+		return nil, fmt.Errorf("VTIMEZONE without STANDARD or DAYLIGHT")
+	}
+	return vtz, nil
+}
+
+// parseUTCOffset parses "+0300", "-0500" or "+053000"
+func parseUTCOffset(prop *ical.Prop) (time.Duration, error) {
+	if prop == nil {
+		return 0, fmt.Errorf("observance without UTC offset")
+	}
+	v := prop.Value
+	if (len(v) != 5 && len(v) != 7) || (v[0] != '+' && v[0] != '-') {
+		return 0, fmt.Errorf("invalid UTC offset %q", v)
+	}
+	var parts [3]int
+	for i := 0; 1+2*i < len(v); i++ {
+		n, err := strconv.Atoi(v[1+2*i : 3+2*i])
+		if err != nil {
+			return 0, fmt.Errorf("invalid UTC offset %q", v)
+		}
+		parts[i] = n
+	}
+	d := time.Duration(parts[0])*time.Hour + time.Duration(parts[1])*time.Minute + time.Duration(parts[2])*time.Second
+	if v[0] == '-' {
+		d = -d
+	}
+	return d, nil
+}
+
+// VTimezone describes loc as a VTIMEZONE block for objects with TZID=loc: the offset at
+// from and every switch between from and to. Clients that know the IANA name use their
+// own rules, the rest need the block to place the events.
+func VTimezone(loc *time.Location, from, to time.Time) *ical.Component {
+	tz := ical.NewComponent(ical.CompTimezone)
+	tz.Props.SetText(ical.PropTimezoneID, loc.String())
+
+	from = from.In(loc)
+	_, offset := from.Zone()
+	tz.Children = append(tz.Children, newObservance(from.IsDST(), from, offset, offset))
+
+	// Переходы ищутся по дням, точное время — делением пополам
+	prev := from
+	for t := from.Add(24 * time.Hour); t.Before(to); t = t.Add(24 * time.Hour) {
+		if _, off := t.In(loc).Zone(); off != offset {
+			lo, hi := prev, t
+			for hi.Sub(lo) > time.Second {
+				mid := lo.Add(hi.Sub(lo) / 2)
+				if _, o := mid.In(loc).Zone(); o == offset {
+					lo = mid
+				} else {
+					hi = mid
+				}
+			}
+			at := hi.In(loc)
+			tz.Children = append(tz.Children, newObservance(at.IsDST(), at, offset, off))
+			offset = off
+		}
+		prev = t
+	}
+	return tz
+}
+
+// newObservance returns a STANDARD or DAYLIGHT part switching to offsetTo at the instant at
+func newObservance(daylight bool, at time.Time, offsetFrom, offsetTo int) *ical.Component {
+	name := ical.CompTimezoneStandard
+	if daylight {
+		name = ical.CompTimezoneDaylight
+	}
+	o := ical.NewComponent(name)
+	start := ical.NewProp(ical.PropDateTimeStart)
+	start.Value = at.UTC().Add(time.Duration(offsetFrom) * time.Second).Format(dateTimeFormat)
+	o.Props.Set(start)
+	o.Props.Set(utcOffsetProp(ical.PropTimezoneOffsetFrom, offsetFrom))
+	o.Props.Set(utcOffsetProp(ical.PropTimezoneOffsetTo, offsetTo))
+	if abbr, _ := at.Zone(); abbr != "" {
+		o.Props.SetText(ical.PropTimezoneName, abbr)
+	}
+	return o
+}
+
+// utcOffsetProp formats the offset in seconds as "+0300"
+func utcOffsetProp(name string, offset int) *ical.Prop {
+	sign := '+'
+	if offset < 0 {
+		sign, offset = '-', -offset
+	}
+	prop := ical.NewProp(name)
+	prop.Value = fmt.Sprintf("%c%02d%02d", sign, offset/3600, offset%3600/60)
+	if offset%60 != 0 {
+		prop.Value += fmt.Sprintf("%02d", offset%60)
+	}
+	return prop
+}
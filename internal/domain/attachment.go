@@ -0,0 +1,60 @@
+package domain
+
+import "time"
+
+// AttachmentOwner — к чему прикреплён файл
+type AttachmentOwner string
+
+const (
+	AttachmentTask   AttachmentOwner = "task"
+	AttachmentPerson AttachmentOwner = "person"
+	AttachmentAuto   AttachmentOwner = "auto"
+)
+
+// AttachmentKind — как файл пришёл в Telegram и как его отправлять обратно
+type AttachmentKind string
+
+const (
+	AttachmentPhoto    AttachmentKind = "photo"
+	AttachmentDocument AttachmentKind = "document"
+)
+
+// Attachment — фото или документ (полис, чек, рецепт), прикреплённый к задаче, человеку или машине.
+// Сам файл хранится в Telegram (FileID); LocalPath — копия в локальной папке, если она включена.
+type Attachment struct {
+	ID           int64
+	UserID       int64 // кто прикрепил
+	OwnerType    AttachmentOwner
+	OwnerID      int64
+	Kind         AttachmentKind
+	FileID       string
+	FileUniqueID string
+	FileName     string
+	MimeType     string
+	FileSize     int64
+	Caption      string
+	LocalPath    string
+	CreatedAt    time.Time
+}
+
+// Emoji returns the icon of the attachment kind
+func (a *Attachment) Emoji() string {
+	if a.Kind == AttachmentPhoto {
+		return "🖼"
+	}
+	return "📄"
+}
+
+// DisplayName returns the file name, caption or a generic name for the list
+func (a *Attachment) DisplayName() string {
+	switch {
+	case a.FileName != "":
+		return a.FileName
+	case a.Caption != "":
+		return a.Caption
+	case a.Kind == AttachmentPhoto:
+		return "Фото от " + a.CreatedAt.Format("02.01.2006")
+	default:
+		return "Файл от " + a.CreatedAt.Format("02.01.2006")
+	}
+}
@@ -0,0 +1,187 @@
+package domain
+
+import (
+	"strings"
+	"time"
+)
+
+// AutoAlertDays — за сколько дней до срока предупреждать (страховка, ТО, документы, регламент)
+var AutoAlertDays = []int{30, 7, 1}
+
+// AutoAlertKm — за сколько км до регламентной работы предупреждать
+const AutoAlertKm = 1000
+
+// OdometerReading — показание одометра
+type OdometerReading struct {
+	ID         int64
+	AutoID     int64
+	UserID     int64
+	Km         int
+	RecordedAt time.Time
+}
+
+// ServiceRecord — запись сервисной книжки: что сделали, на каком пробеге и за сколько
+type ServiceRecord struct {
+	ID          int64
+	AutoID      int64
+	UserID      int64
+	RuleID      *int64 // регламентная работа, которую закрыла запись
+	Title       string
+	Km          int     // 0 — пробег не указан
+	Cost        float64 // ₽, 0 — не указана
+	Notes       string
+	PerformedAt time.Time
+}
+
+// MaintenanceRule — регламент: «масло каждые 10 000 км или 12 мес», что наступит раньше.
+// Отсчёт идёт от последнего выполнения (LastKm, LastDoneAt).
+type MaintenanceRule struct {
+	ID             int64
+	AutoID         int64
+	Title          string
+	IntervalKm     int // 0 — без ограничения по пробегу
+	IntervalMonths int // 0 — без ограничения по времени
+	LastKm         int
+	LastDoneAt     *time.Time
+	CreatedAt      time.Time
+}
+
+// NextKm returns the mileage of the next service or 0 if the rule has no km interval
+func (r *MaintenanceRule) NextKm() int {
+	if r.IntervalKm <= 0 {
+		return 0
+	}
+	return r.LastKm + r.IntervalKm
+}
+
+// NextDate returns the date of the next service or nil if the rule has no month interval.
+// The day doesn't overflow: 31 January plus a month is the last day of February.
+func (r *MaintenanceRule) NextDate() *time.Time {
+	if r.IntervalMonths <= 0 || r.LastDoneAt == nil {
+		return nil
+	}
+	done := *r.LastDoneAt
+	first := time.Date(done.Year(), done.Month()+time.Month(r.IntervalMonths), 1, done.Hour(), done.Minute(), done.Second(), done.Nanosecond(), done.Location())
+	last := first.AddDate(0, 1, -1).Day()
+	next := first.AddDate(0, 0, min(done.Day(), last)-1)
+	return &next
+}
+
+// Matches reports whether a service record title refers to the rule: every word of the rule
+// appears in the title up to its ending («масло» — «замена масла», «тормозная жидкость» —
+// «замена тормозной жидкости»)
+func (r *MaintenanceRule) Matches(title string) bool {
+	ruleWords := strings.Fields(strings.ToLower(r.Title))
+	titleWords := strings.Fields(strings.ToLower(title))
+	if len(ruleWords) == 0 {
+		return false
+	}
+	for _, rw := range ruleWords {
+		stem := wordStem(rw)
+		found := false
+		for _, tw := range titleWords {
+			if strings.HasPrefix(tw, stem) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	return true
+}
+
+// wordStem drops a Russian ending: up to two last letters, keeping at least four
+func wordStem(word string) string {
+	runes := []rune(word)
+	n := len(runes) - 2
+	if n < 4 {
+		n = min(4, len(runes))
+	}
+	return string(runes[:n])
+}
+
+// AutoDocumentKind — вид документа со сроком действия
+type AutoDocumentKind string
+
+const (
+	AutoDocInspection AutoDocumentKind = "inspection" // техосмотр (диагностическая карта)
+	AutoDocOSAGO      AutoDocumentKind = "osago"
+	AutoDocKASKO      AutoDocumentKind = "kasko"
+	AutoDocLicense    AutoDocumentKind = "license" // водительское удостоверение, без машины
+	AutoDocOther      AutoDocumentKind = "other"
+)
+
+// ParseAutoDocumentKind recognizes the kind by a word from the command («осаго», «техосмотр», «права»)
+func ParseAutoDocumentKind(s string) (AutoDocumentKind, bool) {
+	switch strings.ToLower(s) {
+	case "техосмотр", "то-карта", "диагностика", "inspection":
+		return AutoDocInspection, true
+	case "осаго", "osago":
+		return AutoDocOSAGO, true
+	case "каско", "kasko":
+		return AutoDocKASKO, true
+	case "права", "ву", "license", "licence":
+		return AutoDocLicense, true
+	}
+	return "", false
+}
+
+// AutoDocument — документ со сроком: техосмотр, ОСАГО/КАСКО, права и т.п.
+type AutoDocument struct {
+	ID        int64
+	UserID    int64
+	AutoID    *int64 // nil — документ водителя (права)
+	Kind      AutoDocumentKind
+	Title     string
+	ExpiresAt time.Time
+	Notes     string
+	CreatedAt time.Time
+}
+
+// Emoji returns the icon of the document kind
+func (d *AutoDocument) Emoji() string {
+	switch d.Kind {
+	case AutoDocInspection:
+		return "🔍"
+	case AutoDocOSAGO, AutoDocKASKO:
+		return "🛡"
+	case AutoDocLicense:
+		return "🪪"
+	default:
+		return "📄"
+	}
+}
+
+// AutoDocumentTitle returns the default title of a document kind
+func AutoDocumentTitle(kind AutoDocumentKind) string {
+	switch kind {
+	case AutoDocInspection:
+		return "Техосмотр"
+	case AutoDocOSAGO:
+		return "ОСАГО"
+	case AutoDocKASKO:
+		return "КАСКО"
+	case AutoDocLicense:
+		return "Права"
+	default:
+		return "Документ"
+	}
+}
+
+// AutoAlertStage returns the alert threshold reached with daysLeft days to go:
+// one of AutoAlertDays, 0 when the date has passed, -1 when it's too early.
+// Each stage is announced once, so a missed day is caught up by the next check.
+func AutoAlertStage(daysLeft int) int {
+	if daysLeft < 0 {
+		return 0
+	}
+	stage := -1
+	for _, d := range AutoAlertDays {
+		if daysLeft <= d {
+			stage = d
+		}
+	}
+	return stage
+}
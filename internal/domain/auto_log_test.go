@@ -0,0 +1,98 @@
+package domain
+
+import (
+	"testing"
+	"time"
+)
+
+func TestMaintenanceRuleNextKm(t *testing.T) {
+	tests := []struct {
+		lastKm, intervalKm int
+		want               int
+	}{
+		{85000, 10000, 95000},
+		{0, 15000, 15000},
+		{85000, 0, 0}, // только по времени
+	}
+	for _, tt := range tests {
+		r := &MaintenanceRule{LastKm: tt.lastKm, IntervalKm: tt.intervalKm}
+		if got := r.NextKm(); got != tt.want {
+			t.Errorf("NextKm() from %d every %d km = %d, want %d", tt.lastKm, tt.intervalKm, got, tt.want)
+		}
+	}
+}
+
+func TestMaintenanceRuleNextDate(t *testing.T) {
+	msk := time.FixedZone("MSK", 3*60*60)
+	date := func(y int, m time.Month, d int) time.Time { return time.Date(y, m, d, 10, 30, 0, 0, msk) }
+	tests := []struct {
+		done   time.Time
+		months int
+		want   time.Time
+	}{
+		{date(2029, time.March, 10), 12, date(2030, time.March, 10)},
+		{date(2029, time.November, 15), 3, date(2030, time.February, 15)},
+		{date(2030, time.January, 31), 1, date(2030, time.February, 28)},
+		{date(2028, time.February, 29), 12, date(2029, time.February, 28)},
+		{date(2029, time.August, 31), 6, date(2030, time.February, 28)},
+		{date(2029, time.May, 31), 1, date(2029, time.June, 30)},
+	}
+	for _, tt := range tests {
+		done := tt.done
+		r := &MaintenanceRule{IntervalMonths: tt.months, LastDoneAt: &done}
+		if got := r.NextDate(); got == nil || !got.Equal(tt.want) {
+			t.Errorf("NextDate() from %s every %d months = %v, want %s", tt.done.Format("02.01.2006"), tt.months, got, tt.want)
+		}
+	}
+
+	done := date(2030, time.January, 31)
+	if got := (&MaintenanceRule{IntervalKm: 10000, LastDoneAt: &done}).NextDate(); got != nil {
+		t.Errorf("NextDate() without a month interval = %s", got)
+	}
+	if got := (&MaintenanceRule{IntervalMonths: 12}).NextDate(); got != nil {
+		t.Errorf("NextDate() never done = %s", got)
+	}
+}
+
+func TestMaintenanceRuleMatches(t *testing.T) {
+	tests := []struct {
+		rule, title string
+		want        bool
+	}{
+		{"масло", "замена масла", true},
+		{"Масло", "Замена МАСЛА и фильтра", true},
+		{"тормозная жидкость", "замена тормозной жидкости", true},
+		{"масло кпп", "замена масла", false},
+		{"масло", "воздушный фильтр", false},
+		{"ГРМ", "ремень ГРМ", true},
+		{"", "замена масла", false},
+	}
+	for _, tt := range tests {
+		r := &MaintenanceRule{Title: tt.rule}
+		if got := r.Matches(tt.title); got != tt.want {
+			t.Errorf("rule %q matches %q = %v, want %v", tt.rule, tt.title, got, tt.want)
+		}
+	}
+}
+
+func TestAutoAlertStage(t *testing.T) {
+	tests := []struct {
+		daysLeft, want int
+	}{
+		{45, -1},
+		{31, -1},
+		{30, 30},
+		{8, 30},
+		{7, 7},
+		{2, 7},
+		{1, 1},
+		{0, 1},
+		{-1, 0},
+		{-40, 0},
+	}
+	for _, tt := range tests {
+		if got := AutoAlertStage(tt.daysLeft); got != tt.want {
+			t.Errorf("AutoAlertStage(%d) = %d, want %d", tt.daysLeft, got, tt.want)
+		}
+	}
+}
@@ -6,7 +6,13 @@ import "time"
 type CalendarEvent struct {
 	ID          int64
 	UserID      int64
+	CalendarID  *int64     // Linked calendar the event came from, nil for local events
 	CalDAVUID   string     // Unique ID from Apple Calendar
+	CalDAVPath  string     // Path of the calendar object on the CalDAV server
+	RecurrenceID string    // Occurrence of a recurring event: its original start, "" for a single event
+	ETag        string     // ETag of the calendar object at the last sync
+	Sequence    int        // SEQUENCE of the calendar object, grows with every change we write
+	Pending     bool       // Changed in the bot, not yet written to the calendar
 	Title       string     // Summary/Subject
 	Description string     // Description
 	Location    string     // Location
@@ -19,6 +25,11 @@ type CalendarEvent struct {
 	UpdatedAt   time.Time
 }
 
+// IsOccurrence reports whether the event is one occurrence of a recurring calendar event
+func (e *CalendarEvent) IsOccurrence() bool {
+	return e.RecurrenceID != ""
+}
+
 // FormatTime returns formatted time for display
 func (e *CalendarEvent) FormatTime() string {
 	if e.AllDay {
@@ -43,25 +54,24 @@ func (e *CalendarEvent) FormatDateTime() string {
 	return e.StartTime.Format("02.01.2006 15:04")
 }
 
-// IsToday returns true if event is today
-func (e *CalendarEvent) IsToday() bool {
-	now := time.Now()
+// IsToday returns true if event is on the same day as now
+func (e *CalendarEvent) IsToday(now time.Time) bool {
 	return e.StartTime.Year() == now.Year() &&
 		e.StartTime.YearDay() == now.YearDay()
 }
 
-// IsTomorrow returns true if event is tomorrow
-func (e *CalendarEvent) IsTomorrow() bool {
-	tomorrow := time.Now().AddDate(0, 0, 1)
+// IsTomorrow returns true if event is on the day after now
+func (e *CalendarEvent) IsTomorrow(now time.Time) bool {
+	tomorrow := now.AddDate(0, 0, 1)
 	return e.StartTime.Year() == tomorrow.Year() &&
 		e.StartTime.YearDay() == tomorrow.YearDay()
 }
 
-// DaysUntil returns number of days until the event
-func (e *CalendarEvent) DaysUntil() int {
-	now := time.Now().Truncate(24 * time.Hour)
+// DaysUntil returns number of days from now until the event
+func (e *CalendarEvent) DaysUntil(now time.Time) int {
+	today := now.Truncate(24 * time.Hour)
 	eventDate := e.StartTime.Truncate(24 * time.Hour)
-	return int(eventDate.Sub(now).Hours() / 24)
+	return int(eventDate.Sub(today).Hours() / 24)
 }
 
 // LocationEmoji returns location emoji if location is set
@@ -0,0 +1,221 @@
+package domain
+
+import (
+	"strings"
+	"time"
+)
+
+// CalendarProvider — вид CalDAV-сервера: от него зависят адрес по умолчанию и подпись
+type CalendarProvider string
+
+const (
+	ProviderICloud    CalendarProvider = "icloud"
+	ProviderNextcloud CalendarProvider = "nextcloud"
+	ProviderFastmail  CalendarProvider = "fastmail"
+	ProviderRadicale  CalendarProvider = "radicale"
+	ProviderCalDAV    CalendarProvider = "caldav" // любой другой сервер
+)
+
+// CalendarSyncMode — направление синхронизации календаря
+type CalendarSyncMode string
+
+const (
+	CalendarReadOnly CalendarSyncMode = "read"    // события только загружаются в бота
+	CalendarTwoWay   CalendarSyncMode = "two-way" // бот ещё и пишет в календарь
+)
+
+// CalendarColor — цвет календаря; в Telegram показывается цветным кружком
+type CalendarColor string
+
+// CalendarColors — палитра, новые календари получают цвета по кругу
+var CalendarColors = []CalendarColor{"blue", "green", "orange", "purple", "red", "yellow", "brown", "black", "white"}
+
+// CalendarAccount — CalDAV-аккаунт пользователя (iCloud, Nextcloud, Fastmail, Radicale).
+// Пароль хранится как есть, поэтому для iCloud и Fastmail нужен пароль приложения.
+type CalendarAccount struct {
+	ID        int64
+	UserID    int64
+	Provider  CalendarProvider
+	URL       string
+	Username  string
+	Password  string
+	CreatedAt time.Time
+}
+
+// Calendar — подключённый календарь аккаунта. Его события попадают в /calendar
+// владельца, а при IsShared — и всей семьи.
+type Calendar struct {
+	ID        int64
+	AccountID int64
+	UserID    int64
+	Path      string // путь коллекции на CalDAV-сервере
+	Name      string
+	Color     CalendarColor
+	Mode      CalendarSyncMode
+	IsShared  bool   // события календаря видит вся семья
+	SyncToken string // sync-token (RFC 6578) последней синхронизации
+	CTag      string // ctag коллекции, если сервер не умеет sync-collection
+	SyncedAt  *time.Time
+	CreatedAt time.Time
+}
+
+// Writable returns true if the bot may create and change events in the calendar
+func (c *Calendar) Writable() bool {
+	return c.Mode == CalendarTwoWay
+}
+
+// Label returns the colour marker and the name of the calendar
+func (c *Calendar) Label() string {
+	return c.Color.Emoji() + " " + c.Name
+}
+
+// Title returns the provider and login of the account
+func (a *CalendarAccount) Title() string {
+	return a.Provider.Name() + " · " + a.Username
+}
+
+// Name returns the display name of the provider
+func (p CalendarProvider) Name() string {
+	switch p {
+	case ProviderICloud:
+		return "iCloud"
+	case ProviderNextcloud:
+		return "Nextcloud"
+	case ProviderFastmail:
+		return "Fastmail"
+	case ProviderRadicale:
+		return "Radicale"
+	default:
+		return "CalDAV"
+	}
+}
+
+// DefaultURL returns the CalDAV endpoint of the provider, "" if it depends on the server
+func (p CalendarProvider) DefaultURL() string {
+	switch p {
+	case ProviderICloud:
+		return "https://caldav.icloud.com"
+	case ProviderFastmail:
+		return "https://caldav.fastmail.com"
+	default:
+		return ""
+	}
+}
+
+// ParseCalendarProvider parses "icloud", "nextcloud", "fastmail", "radicale"
+func ParseCalendarProvider(s string) (CalendarProvider, bool) {
+	switch strings.ToLower(strings.TrimSpace(s)) {
+	case "icloud", "apple":
+		return ProviderICloud, true
+	case "nextcloud":
+		return ProviderNextcloud, true
+	case "fastmail":
+		return ProviderFastmail, true
+	case "radicale":
+		return ProviderRadicale, true
+	case "caldav":
+		return ProviderCalDAV, true
+	}
+	return "", false
+}
+
+// DetectCalendarProvider guesses the provider by the server URL
+func DetectCalendarProvider(url string) CalendarProvider {
+	u := strings.ToLower(url)
+	switch {
+	case strings.Contains(u, "icloud.com"):
+		return ProviderICloud
+	case strings.Contains(u, "fastmail"):
+		return ProviderFastmail
+	case strings.Contains(u, "nextcloud") || strings.Contains(u, "remote.php/dav"):
+		return ProviderNextcloud
+	case strings.Contains(u, "radicale") || strings.Contains(u, ":5232"):
+		return ProviderRadicale
+	default:
+		return ProviderCalDAV
+	}
+}
+
+// Name returns Russian name for the sync mode
+func (m CalendarSyncMode) Name() string {
+	if m == CalendarTwoWay {
+		return "двусторонняя"
+	}
+	return "только чтение"
+}
+
+// Emoji returns emoji for the sync mode
+func (m CalendarSyncMode) Emoji() string {
+	if m == CalendarTwoWay {
+		return "🔄"
+	}
+	return "👁"
+}
+
+// ParseCalendarSyncMode parses "read", "two-way", "чтение", "запись" etc.
+func ParseCalendarSyncMode(s string) (CalendarSyncMode, bool) {
+	switch strings.ToLower(strings.TrimSpace(s)) {
+	case "read", "ro", "readonly", "read-only", "чтение", "просмотр":
+		return CalendarReadOnly, true
+	case "two-way", "twoway", "2way", "rw", "двусторонняя", "двусторонний", "запись":
+		return CalendarTwoWay, true
+	}
+	return "", false
+}
+
+// Emoji returns the coloured circle for the colour
+func (c CalendarColor) Emoji() string {
+	switch c {
+	case "red":
+		return "🔴"
+	case "orange":
+		return "🟠"
+	case "yellow":
+		return "🟡"
+	case "green":
+		return "🟢"
+	case "blue":
+		return "🔵"
+	case "purple":
+		return "🟣"
+	case "brown":
+		return "🟤"
+	case "black":
+		return "⚫"
+	case "white":
+		return "⚪"
+	default:
+		return "📆"
+	}
+}
+
+// ParseCalendarColor parses "blue", "синий", "🔵" etc.
+func ParseCalendarColor(s string) (CalendarColor, bool) {
+	s = strings.ToLower(strings.TrimSpace(s))
+	for _, c := range CalendarColors {
+		if s == string(c) || s == c.Emoji() {
+			return c, true
+		}
+	}
+	switch s {
+	case "красный":
+		return "red", true
+	case "оранжевый":
+		return "orange", true
+	case "жёлтый", "желтый":
+		return "yellow", true
+	case "зелёный", "зеленый":
+		return "green", true
+	case "синий", "голубой":
+		return "blue", true
+	case "фиолетовый":
+		return "purple", true
+	case "коричневый":
+		return "brown", true
+	case "чёрный", "черный":
+		return "black", true
+	case "белый":
+		return "white", true
+	}
+	return "", false
+}
@@ -0,0 +1,154 @@
+package domain
+
+import "time"
+
+// EventFields — то, что пользователь видит и меняет в событии; сравнивается при синхронизации
+type EventFields struct {
+	Title       string
+	Description string
+	Location    string
+	StartTime   time.Time
+	EndTime     time.Time
+	AllDay      bool
+}
+
+// EventField — поле события для трёхстороннего слияния. Время (начало, конец,
+// «весь день») — одно поле: перенос события меняет их вместе.
+type EventField string
+
+const (
+	EventFieldTitle       EventField = "title"
+	EventFieldDescription EventField = "description"
+	EventFieldLocation    EventField = "location"
+	EventFieldTime        EventField = "time"
+)
+
+// EventFieldsOrder — порядок полей при слиянии и в сообщении о конфликте
+var EventFieldsOrder = []EventField{EventFieldTitle, EventFieldTime, EventFieldLocation, EventFieldDescription}
+
+// CalendarEventSnapshot — событие в том виде, в каком оно было на сервере при последней
+// синхронизации: общий предок правок в боте и в календаре
+type CalendarEventSnapshot struct {
+	EventID  int64
+	Fields   EventFields
+	Sequence int
+}
+
+// CalendarConflict — событие изменили и в боте, и в календаре (или изменили с одной
+// стороны и удалили с другой). Ждёт решения владельца: оставить версию бота или взять
+// версию из календаря. Пока конфликт открыт, синхронизация событие не трогает.
+type CalendarConflict struct {
+	ID             int64
+	EventID        int64
+	UserID         int64
+	Remote         EventFields // версия из календаря
+	RemoteETag     string
+	RemoteSequence int
+	RemoteDeleted  bool         // в календаре событие удалено, в боте изменено
+	LocalDeleted   bool         // в боте событие удалено, в календаре изменено
+	Fields         []EventField // поля, изменённые с обеих сторон по-разному
+	CreatedAt      time.Time
+}
+
+// Fields returns the editable content of the event
+func (e *CalendarEvent) Fields() EventFields {
+	return EventFields{
+		Title:       e.Title,
+		Description: e.Description,
+		Location:    e.Location,
+		StartTime:   e.StartTime,
+		EndTime:     e.EndTime,
+		AllDay:      e.AllDay,
+	}
+}
+
+// SetFields replaces the editable content of the event
+func (e *CalendarEvent) SetFields(f EventFields) {
+	e.Title = f.Title
+	e.Description = f.Description
+	e.Location = f.Location
+	e.StartTime = f.StartTime
+	e.EndTime = f.EndTime
+	e.AllDay = f.AllDay
+}
+
+// Equal reports whether the field has the same value in both versions
+func (f EventField) Equal(a, b EventFields) bool {
+	switch f {
+	case EventFieldTitle:
+		return a.Title == b.Title
+	case EventFieldDescription:
+		return a.Description == b.Description
+	case EventFieldLocation:
+		return a.Location == b.Location
+	case EventFieldTime:
+		return a.StartTime.Equal(b.StartTime) && a.EndTime.Equal(b.EndTime) && a.AllDay == b.AllDay
+	}
+	return true
+}
+
+// Copy sets the field of dst from src
+func (f EventField) Copy(dst *EventFields, src EventFields) {
+	switch f {
+	case EventFieldTitle:
+		dst.Title = src.Title
+	case EventFieldDescription:
+		dst.Description = src.Description
+	case EventFieldLocation:
+		dst.Location = src.Location
+	case EventFieldTime:
+		dst.StartTime = src.StartTime
+		dst.EndTime = src.EndTime
+		dst.AllDay = src.AllDay
+	}
+}
+
+// Name returns Russian name of the field
+func (f EventField) Name() string {
+	switch f {
+	case EventFieldTitle:
+		return "название"
+	case EventFieldDescription:
+		return "описание"
+	case EventFieldLocation:
+		return "место"
+	case EventFieldTime:
+		return "время"
+	}
+	return string(f)
+}
+
+// Equal reports whether both versions of the event are the same
+func (a EventFields) Equal(b EventFields) bool {
+	return len(a.Diff(b)) == 0
+}
+
+// Diff returns the fields that differ between the versions
+func (a EventFields) Diff(b EventFields) []EventField {
+	var fields []EventField
+	for _, f := range EventFieldsOrder {
+		if !f.Equal(a, b) {
+			fields = append(fields, f)
+		}
+	}
+	return fields
+}
+
+// MergeEventFields merges the bot's and the calendar's versions of the event against their
+// common ancestor, field by field: a field changed on one side takes that side's value,
+// a field changed on both sides to the same value is kept. A field changed on both sides
+// differently is a conflict: it keeps the local value and is returned in conflicts.
+func MergeEventFields(base, local, remote EventFields) (merged EventFields, conflicts []EventField) {
+	merged = local
+	for _, f := range EventFieldsOrder {
+		switch {
+		case f.Equal(local, remote):
+		case f.Equal(base, local):
+			f.Copy(&merged, remote)
+		case f.Equal(base, remote):
+		default:
+			conflicts = append(conflicts, f)
+		}
+	}
+	return merged, conflicts
+}
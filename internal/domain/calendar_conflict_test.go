@@ -0,0 +1,106 @@
+package domain
+
+import (
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestMergeEventFields(t *testing.T) {
+	start := time.Date(2030, time.March, 12, 19, 0, 0, 0, time.UTC)
+	base := EventFields{Title: "Ужин", Location: "Дома", StartTime: start, EndTime: start.Add(time.Hour)}
+
+	with := func(change func(*EventFields)) EventFields {
+		f := base
+		change(&f)
+		return f
+	}
+	moved := func(f *EventFields) {
+		f.StartTime = start.Add(time.Hour)
+		f.EndTime = start.Add(2 * time.Hour)
+	}
+
+	tests := []struct {
+		name          string
+		local, remote EventFields
+		want          EventFields
+		conflicts     []EventField
+	}{
+		{
+			name:   "no changes",
+			local:  base,
+			remote: base,
+			want:   base,
+		},
+		{
+			name:   "local-only edit",
+			local:  with(func(f *EventFields) { f.Title = "Ужин с мамой" }),
+			remote: base,
+			want:   with(func(f *EventFields) { f.Title = "Ужин с мамой" }),
+		},
+		{
+			name:   "remote-only edit",
+			local:  base,
+			remote: with(moved),
+			want:   with(moved),
+		},
+		{
+			name:   "different fields auto-merge",
+			local:  with(func(f *EventFields) { f.Title = "Ужин с мамой" }),
+			remote: with(func(f *EventFields) { f.Location = "Ресторан" }),
+			want: with(func(f *EventFields) {
+				f.Title = "Ужин с мамой"
+				f.Location = "Ресторан"
+			}),
+		},
+		{
+			name:   "same change on both sides",
+			local:  with(moved),
+			remote: with(moved),
+			want:   with(moved),
+		},
+		{
+			name:      "same field changed differently",
+			local:     with(func(f *EventFields) { f.Title = "Ужин с мамой" }),
+			remote:    with(func(f *EventFields) { f.Title = "Ужин с друзьями" }),
+			want:      with(func(f *EventFields) { f.Title = "Ужин с мамой" }),
+			conflicts: []EventField{EventFieldTitle},
+		},
+		{
+			name: "conflict keeps one-sided changes merged",
+			local: with(func(f *EventFields) {
+				f.Title = "Ужин с мамой"
+				f.Description = "Купить торт"
+			}),
+			remote: with(func(f *EventFields) {
+				f.Title = "Ужин с друзьями"
+				moved(f)
+			}),
+			want: with(func(f *EventFields) {
+				f.Title = "Ужин с мамой"
+				f.Description = "Купить торт"
+				moved(f)
+			}),
+			conflicts: []EventField{EventFieldTitle},
+		},
+		{
+			// Начало, конец и «весь день» — одно поле: перенос не склеивается из половин
+			name:      "time is a single field",
+			local:     with(func(f *EventFields) { f.EndTime = start.Add(90 * time.Minute) }),
+			remote:    with(func(f *EventFields) { f.StartTime = start.Add(-30 * time.Minute) }),
+			want:      with(func(f *EventFields) { f.EndTime = start.Add(90 * time.Minute) }),
+			conflicts: []EventField{EventFieldTime},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			merged, conflicts := MergeEventFields(base, tt.local, tt.remote)
+			if !merged.Equal(tt.want) {
+				t.Errorf("merged %+v, want %+v", merged, tt.want)
+			}
+			if !slices.Equal(conflicts, tt.conflicts) {
+				t.Errorf("conflicts %v, want %v", conflicts, tt.conflicts)
+			}
+		})
+	}
+}
@@ -0,0 +1,12 @@
+package domain
+
+import "time"
+
+// CalendarFeed — личная ссылка на подписку: расписание, задачи со сроком, дни рождения
+// и сроки по машинам в любом приложении календаря, без пароля iCloud. Токен в ссылке и
+// есть доступ: кто знает ссылку, тот видит календарь, поэтому её можно заменить.
+type CalendarFeed struct {
+	UserID    int64
+	Token     string
+	CreatedAt time.Time
+}
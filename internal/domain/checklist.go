@@ -5,13 +5,13 @@ import (
 	"time"
 )
 
-// ChecklistItem represents a single item in a checklist
+// ChecklistItem represents a single item in a checklist template
 type ChecklistItem struct {
-	Text    string `json:"text"`
-	Checked bool   `json:"checked"`
+	Text string `json:"text"`
 }
 
-// Checklist represents a reusable checklist template
+// Checklist represents a reusable checklist template.
+// Отметки ставятся не в шаблоне, а в прогонах (ChecklistRun).
 type Checklist struct {
 	ID        int64
 	UserID    int64
@@ -36,36 +36,103 @@ func (c *Checklist) ParseItemsJSON(data string) error {
 	return json.Unmarshal([]byte(data), &c.Items)
 }
 
-// ResetChecks resets all items to unchecked
-func (c *Checklist) ResetChecks() {
-	for i := range c.Items {
-		c.Items[i].Checked = false
+// ChecklistRunItem is an item of a run: whether, by whom and when it was checked
+type ChecklistRunItem struct {
+	Text      string     `json:"text"`
+	Checked   bool       `json:"checked"`
+	CheckedBy *int64     `json:"checked_by,omitempty"`
+	CheckedAt *time.Time `json:"checked_at,omitempty"`
+}
+
+// ChecklistRun is one pass through a checklist, e.g. packing Тим for one Friday.
+// Started manually or by the reminder of a linked weekly event.
+type ChecklistRun struct {
+	ID            int64
+	ChecklistID   int64
+	UserID        int64  // кто начал прогон
+	WeeklyEventID *int64 // событие, напоминание о котором начало прогон
+	Title         string
+	Items         []ChecklistRunItem
+	IsShared      bool // отмечать могут все члены семьи
+	StartedAt     time.Time
+	CompletedAt   *time.Time
+}
+
+// NewChecklistRun creates an unchecked run of the checklist's current items
+func NewChecklistRun(c *Checklist, userID int64, startedAt time.Time) *ChecklistRun {
+	items := make([]ChecklistRunItem, len(c.Items))
+	for i, item := range c.Items {
+		items[i] = ChecklistRunItem{Text: item.Text}
+	}
+	return &ChecklistRun{
+		ChecklistID: c.ID,
+		UserID:      userID,
+		Title:       c.Title,
+		Items:       items,
+		StartedAt:   startedAt,
 	}
 }
 
-// CheckItem marks an item as checked by index
-func (c *Checklist) CheckItem(index int) bool {
-	if index < 0 || index >= len(c.Items) {
+// ItemsJSON returns items as JSON string for storage
+func (r *ChecklistRun) ItemsJSON() string {
+	data, _ := json.Marshal(r.Items)
+	return string(data)
+}
+
+// ParseItemsJSON parses items from JSON string
+func (r *ChecklistRun) ParseItemsJSON(data string) error {
+	if data == "" {
+		r.Items = []ChecklistRunItem{}
+		return nil
+	}
+	return json.Unmarshal([]byte(data), &r.Items)
+}
+
+// ToggleItem checks or unchecks an item by index and updates CompletedAt
+func (r *ChecklistRun) ToggleItem(index int, userID int64, at time.Time) bool {
+	if index < 0 || index >= len(r.Items) {
 		return false
 	}
-	c.Items[index].Checked = true
+	item := &r.Items[index]
+	if item.Checked {
+		item.Checked = false
+		item.CheckedBy = nil
+		item.CheckedAt = nil
+	} else {
+		item.Checked = true
+		item.CheckedBy = &userID
+		item.CheckedAt = &at
+	}
+
+	if r.AllChecked() {
+		if r.CompletedAt == nil {
+			r.CompletedAt = &at
+		}
+	} else {
+		r.CompletedAt = nil
+	}
 	return true
 }
 
+// IsCompleted returns true if all items were checked
+func (r *ChecklistRun) IsCompleted() bool {
+	return r.CompletedAt != nil
+}
+
 // AllChecked returns true if all items are checked
-func (c *Checklist) AllChecked() bool {
-	for _, item := range c.Items {
+func (r *ChecklistRun) AllChecked() bool {
+	for _, item := range r.Items {
 		if !item.Checked {
 			return false
 		}
 	}
-	return len(c.Items) > 0
+	return len(r.Items) > 0
 }
 
 // CheckedCount returns number of checked items
-func (c *Checklist) CheckedCount() int {
+func (r *ChecklistRun) CheckedCount() int {
 	count := 0
-	for _, item := range c.Items {
+	for _, item := range r.Items {
 		if item.Checked {
 			count++
 		}
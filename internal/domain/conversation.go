@@ -0,0 +1,20 @@
+package domain
+
+import "time"
+
+// Conversation — состояние пошагового диалога (мастера) для пары чат + пользователь.
+// Хранится в БД, поэтому переживает рестарт бота.
+type Conversation struct {
+	ChatID    int64
+	UserID    int64
+	Flow      string            // мастер: add_task, add_weekly, ...
+	Step      string            // шаг, на который ждём ответ
+	Data      map[string]string // ответы по шагам
+	History   []string          // пройденные шаги, для кнопки «Назад»
+	ExpiresAt time.Time
+	UpdatedAt time.Time
+}
+
+func (c *Conversation) IsExpired(now time.Time) bool {
+	return !c.ExpiresAt.IsZero() && now.After(c.ExpiresAt)
+}
@@ -0,0 +1,112 @@
+package domain
+
+import (
+	"strings"
+	"time"
+)
+
+// HouseholdRole — роль участника семьи
+type HouseholdRole string
+
+const (
+	HouseholdAdmin  HouseholdRole = "admin"  // приглашает и управляет участниками
+	HouseholdAdult  HouseholdRole = "adult"  // полный доступ к задачам и финансам
+	HouseholdChild  HouseholdRole = "child"  // задачи и расписание, без финансов
+	HouseholdViewer HouseholdRole = "viewer" // только просмотр (бабушки, дедушки)
+)
+
+// Household — семья: её участники видят общие задачи и события друг друга
+// и получают брифинги. Пользователь состоит не больше чем в одной семье.
+type Household struct {
+	ID        int64
+	Name      string
+	CreatedAt time.Time
+}
+
+// HouseholdMember — участник семьи
+type HouseholdMember struct {
+	HouseholdID int64
+	UserID      int64
+	Role        HouseholdRole
+	JoinedAt    time.Time
+	User        *User // заполняется при выборке списка участников
+}
+
+// HouseholdInvite — одноразовый код приглашения (/join CODE)
+type HouseholdInvite struct {
+	Code        string
+	HouseholdID int64
+	Role        HouseholdRole
+	CreatedBy   int64
+	ExpiresAt   time.Time
+	UsedBy      *int64
+	UsedAt      *time.Time
+	CreatedAt   time.Time
+}
+
+// IsValid returns true if the invite is unused and not expired
+func (i *HouseholdInvite) IsValid(now time.Time) bool {
+	return i.UsedBy == nil && now.Before(i.ExpiresAt)
+}
+
+// CanWrite returns true if the role may create and change anything
+func (r HouseholdRole) CanWrite() bool {
+	return r != HouseholdViewer
+}
+
+// CanManage returns true if the role may invite and manage members
+func (r HouseholdRole) CanManage() bool {
+	return r == HouseholdAdmin
+}
+
+// CanSeeFinance returns true if the role may see debts and payments
+func (r HouseholdRole) CanSeeFinance() bool {
+	return r == HouseholdAdmin || r == HouseholdAdult
+}
+
+// Emoji returns emoji for the role
+func (r HouseholdRole) Emoji() string {
+	switch r {
+	case HouseholdAdmin:
+		return "👑"
+	case HouseholdAdult:
+		return "🧑"
+	case HouseholdChild:
+		return "🧒"
+	case HouseholdViewer:
+		return "👀"
+	default:
+		return "👤"
+	}
+}
+
+// Name returns Russian name for the role
+func (r HouseholdRole) Name() string {
+	switch r {
+	case HouseholdAdmin:
+		return "админ"
+	case HouseholdAdult:
+		return "взрослый"
+	case HouseholdChild:
+		return "ребёнок"
+	case HouseholdViewer:
+		return "наблюдатель"
+	default:
+		return string(r)
+	}
+}
+
+// ParseHouseholdRole parses "admin", "взрослый", "ребёнок", "наблюдатель" etc.
+func ParseHouseholdRole(s string) (HouseholdRole, bool) {
+	switch strings.ToLower(strings.TrimSpace(s)) {
+	case "admin", "админ", "администратор":
+		return HouseholdAdmin, true
+	case "adult", "взрослый", "взрослая":
+		return HouseholdAdult, true
+	case "child", "ребёнок", "ребенок", "дети":
+		return HouseholdChild, true
+	case "viewer", "наблюдатель", "просмотр", "гость":
+		return HouseholdViewer, true
+	}
+	return "", false
+}
@@ -0,0 +1,22 @@
+package domain
+
+import "time"
+
+// LiveMessageKind — что показывает сообщение, которое бот обновляет при изменениях
+type LiveMessageKind string
+
+const (
+	LiveChecklistRun LiveMessageKind = "checklist_run" // RefID — прогон чек-листа
+	LiveSharedTasks  LiveMessageKind = "shared_tasks"  // RefID не используется
+)
+
+// LiveMessage — сообщение бота с чек-листом или общими задачами.
+// Когда состояние меняется, бот редактирует все такие сообщения, у обоих партнёров.
+type LiveMessage struct {
+	ChatID    int64
+	MessageID int
+	UserID    int64 // кому показано: от этого зависят кнопки
+	Kind      LiveMessageKind
+	RefID     int64
+	UpdatedAt time.Time
+}
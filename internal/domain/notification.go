@@ -0,0 +1,31 @@
+package domain
+
+import "time"
+
+// NotificationStatus — статус доставки уведомления
+type NotificationStatus string
+
+const (
+	NotificationPending NotificationStatus = "pending" // ждёт отправки или повтора
+	NotificationSent    NotificationStatus = "sent"
+	NotificationFailed  NotificationStatus = "failed" // попытки исчерпаны
+)
+
+// Notification — исходящее уведомление планировщика (outbox).
+// Key — ключ идемпотентности: повторная постановка того же уведомления игнорируется.
+type Notification struct {
+	ID            int64
+	Key           string // например "reminder:12:1760601600:123456789"
+	UserID        *int64 // nil для группового чата
+	ChatID        int64
+	Text          string
+	TaskID        *int64 // если задано — сообщение отправляется с кнопками «выполнено/отложить»
+	ConflictID    *int64 // если задано — с кнопками «оставить моё/взять из календаря»
+	Urgent        bool   // отправляется и в тихие часы
+	Status        NotificationStatus
+	Attempts      int
+	LastError     string
+	NextAttemptAt time.Time
+	CreatedAt     time.Time
+	SentAt        *time.Time
+}
@@ -1,6 +1,9 @@
 package domain
 
-import "time"
+import (
+	"strings"
+	"time"
+)
 
 // PersonRole defines the type of person
 type PersonRole string
@@ -29,12 +32,11 @@ func (p *Person) HasTelegram() bool {
 	return p.TelegramID != nil
 }
 
-// Age returns current age if birthday is set
-func (p *Person) Age() int {
+// Age returns the age at now if birthday is set
+func (p *Person) Age(now time.Time) int {
 	if p.Birthday == nil {
 		return 0
 	}
-	now := time.Now()
 	age := now.Year() - p.Birthday.Year()
 	if now.YearDay() < p.Birthday.YearDay() {
 		age--
@@ -47,21 +49,23 @@ func (p *Person) HasBirthday() bool {
 	return p.Birthday != nil
 }
 
-// DaysUntilBirthday returns days until next birthday
-func (p *Person) DaysUntilBirthday() int {
+// DaysUntilBirthday returns calendar days from the day of now until next birthday:
+// 0 on the birthday itself, 1 the day before
+func (p *Person) DaysUntilBirthday(now time.Time) int {
 	if p.Birthday == nil {
 		return -1
 	}
 
-	now := time.Now()
-	thisYear := time.Date(now.Year(), p.Birthday.Month(), p.Birthday.Day(), 0, 0, 0, 0, now.Location())
+	// Считаем по датам, а не по часам: вечером накануне до ДР тоже «завтра»
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
+	thisYear := time.Date(now.Year(), p.Birthday.Month(), p.Birthday.Day(), 0, 0, 0, 0, time.UTC)
 
-	if thisYear.Before(now) {
+	if thisYear.Before(today) {
 		// Birthday already passed this year
 		thisYear = thisYear.AddDate(1, 0, 0)
 	}
 
-	return int(thisYear.Sub(now).Hours() / 24)
+	return int(thisYear.Sub(today).Hours() / 24)
 }
 
 // RoleEmoji returns emoji for the role
@@ -95,3 +99,18 @@ func (p *Person) RoleName() string {
 		return "контакт"
 	}
 }
+
+// ParsePersonRole parses a role name: ребёнок, семья, контакт (or child, family, contact)
+func ParsePersonRole(s string) (PersonRole, bool) {
+	switch strings.ToLower(strings.TrimSpace(s)) {
+	case "ребёнок", "ребенок", "child":
+		return RoleChild, true
+	case "семья", "family":
+		return RoleFamily, true
+	case "контакт", "contact":
+		return RoleContact, true
+	case "партнёр_ребёнок", "partner_child":
+		return RolePartnerChild, true
+	}
+	return "", false
+}
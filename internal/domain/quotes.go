@@ -66,8 +66,7 @@ var DailyRelationshipQuotes = []RelationshipQuote{
 }
 
 // GetDailyQuote returns a quote based on the day of year (deterministic but varies daily)
-func GetDailyQuote() RelationshipQuote {
-	now := time.Now()
+func GetDailyQuote(now time.Time) RelationshipQuote {
 	dayOfYear := now.YearDay()
 	index := dayOfYear % len(DailyRelationshipQuotes)
 	return DailyRelationshipQuotes[index]
@@ -0,0 +1,391 @@
+package domain
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/teambition/rrule-go"
+)
+
+// RRULE (RFC 5545) для повторяющихся задач: разбор, описание и расчёт дат.
+
+var rruleWeekdays = []string{"SU", "MO", "TU", "WE", "TH", "FR", "SA"}
+
+// NormalizeRRule проверяет правило и приводит его к каноничному виду без DTSTART
+func NormalizeRRule(rule string) (string, error) {
+	rule = strings.TrimSpace(rule)
+	rule = strings.TrimPrefix(strings.ToUpper(rule), "RRULE:")
+	if rule == "" {
+		return "", fmt.Errorf("empty rule")
+	}
+	opt, err := rrule.StrToROption(rule)
+	if err != nil {
+		return "", fmt.Errorf("invalid rule %q: %w", rule, err)
+	}
+	if _, err := rrule.NewRRule(*opt); err != nil {
+		return "", fmt.Errorf("invalid rule %q: %w", rule, err)
+	}
+	return opt.RRuleString(), nil
+}
+
+// RuleOccurrenceAfter returns the first occurrence of rule at or after `after`.
+// dtstart anchors the series (interval phase, weekday, time of day).
+func RuleOccurrenceAfter(rule string, dtstart, after time.Time) (*time.Time, error) {
+	opt, err := rrule.StrToROptionInLocation(rule, dtstart.Location())
+	if err != nil {
+		return nil, err
+	}
+	opt.Dtstart = dtstart
+	r, err := rrule.NewRRule(*opt)
+	if err != nil {
+		return nil, err
+	}
+	next := r.After(after, true)
+	if next.IsZero() {
+		return nil, nil // серия закончилась (COUNT/UNTIL)
+	}
+	return &next, nil
+}
+
+// RecurrenceRule returns the task's RRULE. For the legacy repeat types an
+// equivalent rule is derived, so every repeating task can be sent to CalDAV.
+func (t *Task) RecurrenceRule() string {
+	switch t.RepeatType {
+	case RepeatRule:
+		return t.RRule
+	case RepeatDaily:
+		return "FREQ=DAILY"
+	case RepeatWeekdays:
+		return "FREQ=WEEKLY;BYDAY=MO,TU,WE,TH,FR"
+	case RepeatWeekly:
+		return "FREQ=WEEKLY"
+	case RepeatMonthly:
+		return "FREQ=MONTHLY"
+	case RepeatMonthlyNth:
+		if t.DueDate == nil {
+			return "FREQ=MONTHLY"
+		}
+		weekNum := t.RepeatWeekNum
+		if weekNum < 1 || weekNum > 4 {
+			weekNum = 1
+		}
+		return fmt.Sprintf("FREQ=MONTHLY;BYDAY=%d%s", weekNum, rruleWeekdays[t.DueDate.Weekday()])
+	default:
+		return ""
+	}
+}
+
+// OccursOn reports whether a rule-based task has an occurrence on the given day
+func (t *Task) OccursOn(day time.Time) bool {
+	if t.RepeatType != RepeatRule || t.RRule == "" || t.DueDate == nil {
+		return false
+	}
+	dayStart := time.Date(day.Year(), day.Month(), day.Day(), 0, 0, 0, 0, day.Location())
+	next, err := RuleOccurrenceAfter(t.RRule, t.seriesStart(*t.DueDate, day.Location()), dayStart)
+	if err != nil || next == nil {
+		return false
+	}
+	return next.Before(dayStart.AddDate(0, 0, 1))
+}
+
+// nextRuleOccurrence — следующая дата по RRULE после дня from (и после текущего дедлайна)
+func (t *Task) nextRuleOccurrence(from time.Time) *time.Time {
+	anchor := from
+	if t.DueDate != nil {
+		anchor = *t.DueDate
+	}
+	after := from
+	if anchor.After(after) {
+		after = anchor
+	}
+	// Текущее вхождение выполнено — ищем начиная со следующего дня
+	after = time.Date(after.Year(), after.Month(), after.Day()+1, 0, 0, 0, 0, after.Location())
+
+	next, err := RuleOccurrenceAfter(t.RRule, t.seriesStart(anchor, from.Location()), after)
+	if err != nil {
+		return nil
+	}
+	return next
+}
+
+// seriesStart — DTSTART серии: сохранённый при создании правила, а у задач,
+// созданных до rrule_start, — дата якоря и время напоминания
+func (t *Task) seriesStart(anchor time.Time, loc *time.Location) time.Time {
+	if t.RRuleStart != nil {
+		return t.RRuleStart.In(loc)
+	}
+	return RuleStart(anchor, t.RepeatTime)
+}
+
+// RuleStart — DTSTART серии, которая начинается в день day во время repeatTime ("HH:MM")
+func RuleStart(day time.Time, repeatTime string) time.Time {
+	hour, min := 0, 0
+	if n, _ := parseHHMM(repeatTime); n > 0 {
+		hour, min = n/100, n%100
+	}
+	return time.Date(day.Year(), day.Month(), day.Day(), hour, min, 0, 0, day.Location())
+}
+
+// === Русские фразы → RRULE ===
+
+var (
+	reEveryN    = regexp.MustCompile(`^(?:кажд\S*|раз в)\s+(\d+)?\s*(\S+)`)
+	reMonthDay  = regexp.MustCompile(`(\d{1,2})\s*(?:-?го\s+)?(?:числа|число)`)
+	reDayMonth  = regexp.MustCompile(`(\d{1,2})\s+([а-я]+)`)
+	reOrdinalWd = regexp.MustCompile(`(перв\S*|втор\S*|трет\S*|четв[её]рт\S*|пят(?:ый|ую|ая)|последн\S*|\d)(?:-?(?:й|ю|я))?\s+([а-я]+)`)
+)
+
+var monthNamesGenitive = []string{"января", "февраля", "марта", "апреля", "мая", "июня", "июля", "августа", "сентября", "октября", "ноября", "декабря"}
+
+var monthPrefixes = []string{"январ", "феврал", "март", "апрел", "ма", "июн", "июл", "август", "сентябр", "октябр", "ноябр", "декабр"}
+
+// ParseRecurrence понимает готовое правило (FREQ=...) или фразу по-русски:
+// «каждый день», «по будням», «каждые 2 недели в пт», «каждый понедельник и четверг»,
+// «последнюю пятницу месяца», «вторую среду месяца», «каждые 3 месяца 15 числа»,
+// «каждый год 1 сентября».
+func ParseRecurrence(text string) (string, error) {
+	text = strings.TrimSpace(text)
+	upper := strings.ToUpper(text)
+	if strings.HasPrefix(upper, "FREQ=") || strings.HasPrefix(upper, "RRULE:") {
+		return NormalizeRRule(text)
+	}
+
+	s := strings.ReplaceAll(strings.ToLower(text), "ё", "е")
+	s = strings.Join(strings.Fields(strings.NewReplacer(",", " ", ".", " ").Replace(s)), " ")
+
+	var parts []string
+	add := func(p string) { parts = append(parts, p) }
+
+	switch {
+	case s == "ежедневно" || s == "каждый день":
+		return "FREQ=DAILY", nil
+	case s == "по будням" || s == "по рабочим дням" || s == "будни":
+		return "FREQ=WEEKLY;BYDAY=MO,TU,WE,TH,FR", nil
+	case s == "по выходным" || s == "выходные":
+		return "FREQ=WEEKLY;BYDAY=SA,SU", nil
+	}
+
+	// Частота и интервал
+	freq, interval := "", 1
+	switch {
+	case strings.HasPrefix(s, "ежедневно"):
+		freq = "DAILY"
+	case strings.HasPrefix(s, "еженедельно"):
+		freq = "WEEKLY"
+	case strings.HasPrefix(s, "ежемесячно"):
+		freq = "MONTHLY"
+	case strings.HasPrefix(s, "ежегодно"):
+		freq = "YEARLY"
+	}
+	if m := reEveryN.FindStringSubmatch(s); freq == "" && m != nil {
+		switch unit := m[2]; {
+		case strings.HasPrefix(unit, "дн") || strings.HasPrefix(unit, "ден") || strings.HasPrefix(unit, "сутк"):
+			freq = "DAILY"
+		case strings.HasPrefix(unit, "недел"):
+			freq = "WEEKLY"
+		case strings.HasPrefix(unit, "месяц"):
+			freq = "MONTHLY"
+		case strings.HasPrefix(unit, "год") || strings.HasPrefix(unit, "лет"):
+			freq = "YEARLY"
+		case parseRuWeekday(unit) != "":
+			freq = "WEEKLY" // «каждый понедельник»
+		}
+		// Число — интервал, только если за ним единица: «каждые 3 месяца», но не «каждое 15 число»
+		if m[1] != "" && freq != "" {
+			interval, _ = strconv.Atoi(m[1])
+		}
+	}
+
+	// «последнюю пятницу месяца», «2-ю среду»
+	for _, m := range reOrdinalWd.FindAllStringSubmatch(s, -1) {
+		n, wd := parseRuOrdinal(m[1]), parseRuWeekday(m[2])
+		if n == 0 || wd == "" || parseRuWeekday(m[1]) != "" {
+			continue
+		}
+		if freq == "" || freq == "WEEKLY" {
+			freq = "MONTHLY"
+		}
+		add(fmt.Sprintf("BYDAY=%d%s", n, wd))
+		break
+	}
+
+	// Дни недели: «по пн и чт», «в пятницу»
+	if len(parts) == 0 {
+		var days []string
+		for _, w := range strings.Fields(s) {
+			if d := parseRuWeekday(w); d != "" && !contains(days, d) {
+				days = append(days, d)
+			}
+		}
+		if len(days) > 0 {
+			if freq == "" {
+				freq = "WEEKLY"
+			}
+			add("BYDAY=" + strings.Join(days, ","))
+		}
+	}
+
+	// «1 сентября» (ежегодно) или «15 числа» (ежемесячно)
+	dated := false
+	for _, m := range reDayMonth.FindAllStringSubmatch(s, -1) {
+		if month := parseRuMonth(m[2]); month > 0 {
+			if freq == "" {
+				freq = "YEARLY"
+			}
+			add(fmt.Sprintf("BYMONTH=%d", month))
+			add("BYMONTHDAY=" + m[1])
+			dated = true
+			break
+		}
+	}
+	if m := reMonthDay.FindStringSubmatch(s); m != nil && !dated {
+		if freq == "" {
+			freq = "MONTHLY"
+		}
+		add("BYMONTHDAY=" + m[1])
+	}
+
+	if freq == "" {
+		return "", fmt.Errorf("не понял правило повторения: %q", text)
+	}
+	rule := "FREQ=" + freq
+	if interval > 1 {
+		rule += fmt.Sprintf(";INTERVAL=%d", interval)
+	}
+	for _, p := range parts {
+		rule += ";" + p
+	}
+	return NormalizeRRule(rule)
+}
+
+// DescribeRRule — короткое описание правила по-русски для сообщений бота
+func DescribeRRule(rule string) string {
+	opt, err := rrule.StrToROption(rule)
+	if err != nil {
+		return rule
+	}
+
+	interval := opt.Interval
+	if interval < 1 {
+		interval = 1
+	}
+	var sb strings.Builder
+	switch opt.Freq {
+	case rrule.DAILY:
+		sb.WriteString(everyN(interval, "каждый день", "дн."))
+	case rrule.WEEKLY:
+		sb.WriteString(everyN(interval, "каждую неделю", "нед."))
+	case rrule.MONTHLY:
+		sb.WriteString(everyN(interval, "каждый месяц", "мес."))
+	case rrule.YEARLY:
+		sb.WriteString(everyN(interval, "каждый год", "г."))
+	default:
+		return rule
+	}
+
+	if len(opt.Byweekday) > 0 {
+		var days []string
+		for _, wd := range opt.Byweekday {
+			name := WeekdayNameShort(Weekday((wd.Day() + 1) % 7))
+			switch n := wd.N(); {
+			case n == -1:
+				name = "последн. " + name
+			case n > 0:
+				name = fmt.Sprintf("%d-й %s", n, name)
+			}
+			days = append(days, name)
+		}
+		sb.WriteString(", " + strings.Join(days, ", "))
+	}
+	var days []string
+	for _, d := range opt.Bymonthday {
+		days = append(days, strconv.Itoa(d))
+	}
+	var months []string
+	for _, m := range opt.Bymonth {
+		if m >= 1 && m <= 12 {
+			months = append(months, monthNamesGenitive[m-1])
+		}
+	}
+	switch {
+	case len(days) > 0 && len(months) > 0:
+		sb.WriteString(", " + strings.Join(days, ",") + " " + strings.Join(months, ","))
+	case len(days) > 0:
+		sb.WriteString(", " + strings.Join(days, ",") + " числа")
+	case len(months) > 0:
+		sb.WriteString(", " + strings.Join(months, ","))
+	}
+	return sb.String()
+}
+
+func everyN(n int, one, unit string) string {
+	if n == 1 {
+		return one
+	}
+	return fmt.Sprintf("раз в %d %s", n, unit)
+}
+
+// parseRuWeekday принимает любую форму дня недели («пятницу», «по средам») и возвращает код RRULE
+func parseRuWeekday(w string) string {
+	prefixes := []struct{ prefix, code string }{
+		{"пн", "MO"}, {"понедел", "MO"},
+		{"вт", "TU"}, {"вторн", "TU"},
+		{"ср", "WE"}, {"сред", "WE"},
+		{"чт", "TH"}, {"четверг", "TH"},
+		{"пт", "FR"}, {"пятниц", "FR"},
+		{"сб", "SA"}, {"суббот", "SA"},
+		{"вс", "SU"}, {"воскресен", "SU"},
+	}
+	for _, p := range prefixes {
+		if w == p.prefix || (len([]rune(p.prefix)) > 2 && strings.HasPrefix(w, p.prefix)) {
+			return p.code
+		}
+	}
+	return ""
+}
+
+func parseRuOrdinal(w string) int {
+	switch {
+	case strings.HasPrefix(w, "перв"):
+		return 1
+	case strings.HasPrefix(w, "втор"):
+		return 2
+	case strings.HasPrefix(w, "трет"):
+		return 3
+	case strings.HasPrefix(w, "четв"):
+		return 4
+	case strings.HasPrefix(w, "пят"):
+		return 5
+	case strings.HasPrefix(w, "последн"):
+		return -1
+	}
+	if n, err := strconv.Atoi(w); err == nil && n >= 1 && n <= 5 {
+		return n
+	}
+	return 0
+}
+
+func parseRuMonth(w string) int {
+	for i, p := range monthPrefixes {
+		if strings.HasPrefix(w, p) {
+			// «ма» — только «мая»/«май», не «марта»
+			if p == "ма" && w != "мая" && w != "май" {
+				continue
+			}
+			return i + 1
+		}
+	}
+	return 0
+}
+
+func contains(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
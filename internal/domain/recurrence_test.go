@@ -0,0 +1,160 @@
+package domain
+
+import (
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestParseRecurrence(t *testing.T) {
+	tests := []struct {
+		text string
+		want string
+	}{
+		{"каждый день", "FREQ=DAILY"},
+		{"по будням", "FREQ=WEEKLY;BYDAY=MO,TU,WE,TH,FR"},
+		{"по выходным", "FREQ=WEEKLY;BYDAY=SA,SU"},
+		{"каждые 10 дней", "FREQ=DAILY;INTERVAL=10"},
+		{"каждые 2 недели в пт", "FREQ=WEEKLY;INTERVAL=2;BYDAY=FR"},
+		{"каждый понедельник и четверг", "FREQ=WEEKLY;BYDAY=MO,TH"},
+		{"последнюю пятницу месяца", "FREQ=MONTHLY;BYDAY=-1FR"},
+		{"вторую среду месяца", "FREQ=MONTHLY;BYDAY=+2WE"},
+		{"каждые 3 месяца 15 числа", "FREQ=MONTHLY;INTERVAL=3;BYMONTHDAY=15"},
+		{"каждый год 1 сентября", "FREQ=YEARLY;BYMONTH=9;BYMONTHDAY=1"},
+		{"ежемесячно 31 числа", "FREQ=MONTHLY;BYMONTHDAY=31"},
+		// Готовое правило только нормализуется
+		{"RRULE:freq=weekly;byday=mo", "FREQ=WEEKLY;BYDAY=MO"},
+		{"FREQ=DAILY;COUNT=3", "FREQ=DAILY;COUNT=3"},
+	}
+	for _, tt := range tests {
+		got, err := ParseRecurrence(tt.text)
+		if err != nil || got != tt.want {
+			t.Errorf("ParseRecurrence(%q) = %q, %v; want %q", tt.text, got, err, tt.want)
+		}
+	}
+
+	for _, text := range []string{"", "когда-нибудь", "FREQ=SOMETIMES"} {
+		if got, err := ParseRecurrence(text); err == nil {
+			t.Errorf("ParseRecurrence(%q) = %q, want error", text, got)
+		}
+	}
+}
+
+func TestDescribeRRule(t *testing.T) {
+	tests := []struct {
+		rule string
+		want string
+	}{
+		{"FREQ=DAILY", "каждый день"},
+		{"FREQ=DAILY;INTERVAL=10", "раз в 10 дн."},
+		{"FREQ=WEEKLY;BYDAY=MO,TU,WE,TH,FR", "каждую неделю, Пн, Вт, Ср, Чт, Пт"},
+		{"FREQ=WEEKLY;INTERVAL=2;BYDAY=FR", "раз в 2 нед., Пт"},
+		{"FREQ=MONTHLY;BYDAY=-1FR", "каждый месяц, последн. Пт"},
+		{"FREQ=MONTHLY;BYDAY=+2WE", "каждый месяц, 2-й Ср"},
+		{"FREQ=MONTHLY;INTERVAL=3;BYMONTHDAY=15", "раз в 3 мес., 15 числа"},
+		{"FREQ=YEARLY;BYMONTH=9;BYMONTHDAY=1", "каждый год, 1 сентября"},
+		{"FREQ=YEARLY;BYMONTH=12", "каждый год, декабря"},
+		// Непонятное правило показывается как есть
+		{"FREQ=HOURLY", "FREQ=HOURLY"},
+		{"что-то", "что-то"},
+	}
+	for _, tt := range tests {
+		if got := DescribeRRule(tt.rule); got != tt.want {
+			t.Errorf("DescribeRRule(%q) = %q, want %q", tt.rule, got, tt.want)
+		}
+	}
+}
+
+func TestRuleOccurrenceAfter(t *testing.T) {
+	moscow, err := time.LoadLocation("Europe/Moscow")
+	if err != nil {
+		t.Fatal(err)
+	}
+	const layout = "2006-01-02 15:04"
+	// Понедельник, 09:00
+	dtstart := time.Date(2030, time.January, 7, 9, 0, 0, 0, moscow)
+
+	tests := []struct {
+		name  string
+		rule  string
+		after string
+		want  string // "" — серия закончилась
+	}{
+		{"start itself", "FREQ=WEEKLY", "2030-01-07 09:00", "2030-01-07 09:00"},
+		{"next week", "FREQ=WEEKLY", "2030-01-07 09:01", "2030-01-14 09:00"},
+		{"interval keeps phase", "FREQ=WEEKLY;INTERVAL=2", "2030-01-08 00:00", "2030-01-21 09:00"},
+		{"several weekdays", "FREQ=WEEKLY;BYDAY=MO,TH", "2030-01-08 00:00", "2030-01-10 09:00"},
+		{"last friday", "FREQ=MONTHLY;BYDAY=-1FR", "2030-02-01 00:00", "2030-02-22 09:00"},
+		{"last count", "FREQ=WEEKLY;COUNT=3", "2030-01-20 00:00", "2030-01-21 09:00"},
+		{"count exhausted", "FREQ=WEEKLY;COUNT=3", "2030-01-21 09:01", ""},
+		{"until exhausted", "FREQ=DAILY;UNTIL=20300110T060000Z", "2030-01-10 09:01", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			after, err := time.ParseInLocation(layout, tt.after, moscow)
+			if err != nil {
+				t.Fatal(err)
+			}
+			next, err := RuleOccurrenceAfter(tt.rule, dtstart, after)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got := ""
+			if next != nil {
+				got = next.In(moscow).Format(layout)
+			}
+			if got != tt.want {
+				t.Errorf("RuleOccurrenceAfter(%s, %s) = %q, want %q", tt.rule, tt.after, got, tt.want)
+			}
+		})
+	}
+}
+
+// TestNextOccurrenceEndsSeries completes every occurrence the way MarkDone does:
+// the next task copies the rule and the series start, and COUNT/UNTIL end the chain
+func TestNextOccurrenceEndsSeries(t *testing.T) {
+	moscow, err := time.LoadLocation("Europe/Moscow")
+	if err != nil {
+		t.Fatal(err)
+	}
+	start := time.Date(2030, time.January, 7, 9, 0, 0, 0, moscow)
+
+	tests := []struct {
+		rule string
+		want []string
+	}{
+		{"FREQ=WEEKLY;COUNT=3", []string{"2030-01-07", "2030-01-14", "2030-01-21"}},
+		{"FREQ=DAILY;INTERVAL=2;UNTIL=20300113T060000Z", []string{"2030-01-07", "2030-01-09", "2030-01-11", "2030-01-13"}},
+		{"FREQ=MONTHLY;BYDAY=MO;BYSETPOS=1;COUNT=2", []string{"2030-01-07", "2030-02-04"}},
+	}
+	for _, tt := range tests {
+		// Хранилище возвращает время в UTC
+		stored := start.UTC()
+		due := start.UTC()
+		task := &Task{RepeatType: RepeatRule, RepeatTime: "09:00", RRule: tt.rule, RRuleStart: &stored, DueDate: &due}
+
+		var got []string
+		for len(got) <= len(tt.want) {
+			got = append(got, task.DueDate.In(moscow).Format("2006-01-02"))
+			// Выполнено в день дедлайна
+			next := task.NextOccurrence(task.DueDate.In(moscow))
+			if next == nil {
+				break
+			}
+			if next.In(moscow).Hour() != 9 {
+				t.Errorf("%s: occurrence at %s", tt.rule, next.In(moscow))
+			}
+			task = &Task{RepeatType: task.RepeatType, RepeatTime: task.RepeatTime, RRule: task.RRule, RRuleStart: task.RRuleStart, DueDate: next}
+		}
+		if !slices.Equal(got, tt.want) {
+			t.Errorf("%s: occurrences %v, want %v", tt.rule, got, tt.want)
+		}
+	}
+
+	// Старые задачи без rrule_start считают от дедлайна
+	due := time.Date(2030, time.January, 14, 9, 0, 0, 0, moscow)
+	legacy := &Task{RepeatType: RepeatRule, RepeatTime: "09:00", RRule: "FREQ=WEEKLY;BYDAY=MO", DueDate: &due}
+	if next := legacy.NextOccurrence(due); next == nil || !next.Equal(due.AddDate(0, 0, 7)) {
+		t.Errorf("legacy task next %v, want %v", next, due.AddDate(0, 0, 7))
+	}
+}
@@ -10,7 +10,7 @@ const (
 	ReminderMonthly    ReminderType = "monthly"
 	ReminderMonthWeek  ReminderType = "month_week" // 2-я пятница месяца
 	ReminderYearly     ReminderType = "yearly"
-	ReminderFloating   ReminderType = "floating"   // требует подтверждения
+	ReminderFloating   ReminderType = "floating"   // в любой из дней Params.Days, требует подтверждения
 )
 
 type Reminder struct {
@@ -18,7 +18,7 @@ type Reminder struct {
 	UserID    int64
 	Title     string
 	Type      ReminderType
-	Schedule  string // cron expression
+	Schedule  string // RRULE (старые записи — cron expression), только для отображения
 	Params    string // JSON с доп. параметрами
 	IsActive  bool
 	LastSent  *time.Time
@@ -29,8 +29,9 @@ type Reminder struct {
 type ReminderParams struct {
 	Time       string `json:"time,omitempty"`        // "09:00"
 	DayOfWeek  int    `json:"day_of_week,omitempty"` // 0-6 (Sun-Sat)
+	Days       []int  `json:"days,omitempty"`        // 0-6, возможные дни для floating
 	DayOfMonth int    `json:"day_of_month,omitempty"`
-	WeekOfMonth int   `json:"week_of_month,omitempty"` // 1-5
+	WeekOfMonth int   `json:"week_of_month,omitempty"` // 1-5, -1 = последняя
 	Month      int    `json:"month,omitempty"`         // 1-12
 	Day        int    `json:"day,omitempty"`           // 1-31
 }
@@ -0,0 +1,143 @@
+package domain
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/teambition/rrule-go"
+)
+
+// Расписание регулярных напоминаний. Каждый тип переводится в RRULE и
+// считается через rrule-go в часовом поясе пользователя, поэтому время
+// «09:00» остаётся 09:00 и после перехода на летнее/зимнее время.
+
+// ReminderTime разбирает Params.Time ("09:00"), по умолчанию 11:00
+func (p ReminderParams) ReminderTime() (hour, minute int, err error) {
+	timeStr := p.Time
+	if timeStr == "" {
+		timeStr = "11:00"
+	}
+	t, err := time.Parse("15:04", timeStr)
+	if err != nil {
+		return 0, 0, fmt.Errorf("invalid time format: %s", timeStr)
+	}
+	return t.Hour(), t.Minute(), nil
+}
+
+// RRule returns the RFC 5545 rule (without DTSTART and time of day) for the reminder type.
+//
+//   - monthly: DayOfMonth 1-31, -1 — последний день. 29-31 в коротких месяцах
+//     сдвигаются на последний день месяца.
+//   - month_week: WeekOfMonth 1-5 или -1 (последний) + DayOfWeek, напр. 2-я пятница.
+//     Месяцы без 5-го вхождения пропускаются.
+//   - yearly: Month + Day; 29 февраля в невисокосные годы — 28 февраля.
+//   - floating: все возможные дни недели из Days (или DayOfWeek).
+func (p ReminderParams) RRule(t ReminderType) (string, error) {
+	switch t {
+	case ReminderDaily:
+		return "FREQ=DAILY", nil
+
+	case ReminderWeekly:
+		if p.DayOfWeek < 0 || p.DayOfWeek > 6 {
+			return "", fmt.Errorf("invalid day of week: %d", p.DayOfWeek)
+		}
+		return "FREQ=WEEKLY;BYDAY=" + rruleWeekdays[p.DayOfWeek], nil
+
+	case ReminderMonthly:
+		days, err := clampedMonthDays(p.DayOfMonth)
+		if err != nil {
+			return "", err
+		}
+		return "FREQ=MONTHLY;" + days, nil
+
+	case ReminderMonthWeek:
+		if p.DayOfWeek < 0 || p.DayOfWeek > 6 {
+			return "", fmt.Errorf("invalid day of week: %d", p.DayOfWeek)
+		}
+		n := p.WeekOfMonth
+		if n == 0 || n < -1 || n > 5 {
+			return "", fmt.Errorf("invalid week of month: %d", n)
+		}
+		return fmt.Sprintf("FREQ=MONTHLY;BYDAY=%d%s", n, rruleWeekdays[p.DayOfWeek]), nil
+
+	case ReminderYearly:
+		if p.Month < 1 || p.Month > 12 {
+			return "", fmt.Errorf("invalid month: %d", p.Month)
+		}
+		days, err := clampedMonthDays(p.Day)
+		if err != nil {
+			return "", err
+		}
+		return fmt.Sprintf("FREQ=YEARLY;BYMONTH=%d;%s", p.Month, days), nil
+
+	case ReminderFloating:
+		days := p.Days
+		if len(days) == 0 {
+			days = []int{p.DayOfWeek}
+		}
+		var codes []string
+		for _, d := range days {
+			if d < 0 || d > 6 {
+				return "", fmt.Errorf("invalid day of week: %d", d)
+			}
+			codes = append(codes, rruleWeekdays[d])
+		}
+		return "FREQ=WEEKLY;BYDAY=" + strings.Join(codes, ","), nil
+
+	default:
+		return "", fmt.Errorf("unknown reminder type: %s", t)
+	}
+}
+
+// NextReminderRun returns the first run strictly after `after`, in after's location
+func NextReminderRun(t ReminderType, p ReminderParams, after time.Time) (time.Time, error) {
+	rule, err := p.RRule(t)
+	if err != nil {
+		return time.Time{}, err
+	}
+	hour, minute, err := p.ReminderTime()
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	opt, err := rrule.StrToROptionInLocation(rule, after.Location())
+	if err != nil {
+		return time.Time{}, err
+	}
+	// Начинаем серию с начала предыдущего дня: rrule-go не включает DTSTART,
+	// если он не подходит под правило, а время задаём через BYHOUR/BYMINUTE.
+	opt.Dtstart = time.Date(after.Year(), after.Month(), after.Day()-1, 0, 0, 0, 0, after.Location())
+	opt.Byhour = []int{hour}
+	opt.Byminute = []int{minute}
+	opt.Bysecond = []int{0}
+
+	r, err := rrule.NewRRule(*opt)
+	if err != nil {
+		return time.Time{}, err
+	}
+	next := r.After(after, false)
+	if next.IsZero() {
+		return time.Time{}, fmt.Errorf("no next run for rule %s", rule)
+	}
+	return next, nil
+}
+
+// clampedMonthDays — BYMONTHDAY для дня d; для 29-31 берём последний существующий день
+func clampedMonthDays(d int) (string, error) {
+	switch {
+	case d == -1:
+		return "BYMONTHDAY=-1", nil
+	case d >= 1 && d <= 28:
+		return "BYMONTHDAY=" + strconv.Itoa(d), nil
+	case d >= 29 && d <= 31:
+		var days []string
+		for i := 28; i <= d; i++ {
+			days = append(days, strconv.Itoa(i))
+		}
+		return "BYMONTHDAY=" + strings.Join(days, ",") + ";BYSETPOS=-1", nil
+	default:
+		return "", fmt.Errorf("invalid day of month: %d", d)
+	}
+}
@@ -0,0 +1,211 @@
+package domain
+
+import (
+	"testing"
+	"time"
+)
+
+func TestNextReminderRun(t *testing.T) {
+	moscow, err := time.LoadLocation("Europe/Moscow")
+	if err != nil {
+		t.Fatal(err)
+	}
+	const layout = "2006-01-02 15:04"
+
+	tests := []struct {
+		name   string
+		typ    ReminderType
+		params ReminderParams
+		after  string
+		want   string
+	}{
+		{"daily later today", ReminderDaily, ReminderParams{Time: "09:00"}, "2030-01-01 08:59", "2030-01-01 09:00"},
+		{"daily strictly after", ReminderDaily, ReminderParams{Time: "09:00"}, "2030-01-01 09:00", "2030-01-02 09:00"},
+		{"default time", ReminderDaily, ReminderParams{}, "2030-01-01 12:00", "2030-01-02 11:00"},
+		{"weekly", ReminderWeekly, ReminderParams{DayOfWeek: 0}, "2030-01-01 12:00", "2030-01-06 11:00"},
+
+		{"2nd friday", ReminderMonthWeek, ReminderParams{WeekOfMonth: 2, DayOfWeek: 5}, "2030-01-01 00:00", "2030-01-11 11:00"},
+		{"2nd friday next month", ReminderMonthWeek, ReminderParams{WeekOfMonth: 2, DayOfWeek: 5}, "2030-01-11 11:00", "2030-02-08 11:00"},
+		{"5th friday", ReminderMonthWeek, ReminderParams{WeekOfMonth: 5, DayOfWeek: 5}, "2030-01-01 00:00", "2030-03-29 11:00"},
+		// В апреле 2030 только четыре пятницы
+		{"5th friday skips april", ReminderMonthWeek, ReminderParams{WeekOfMonth: 5, DayOfWeek: 5}, "2030-03-29 11:00", "2030-05-31 11:00"},
+		{"last monday", ReminderMonthWeek, ReminderParams{WeekOfMonth: -1, DayOfWeek: 1}, "2030-01-28 11:00", "2030-02-25 11:00"},
+
+		{"monthly 31st in january", ReminderMonthly, ReminderParams{DayOfMonth: 31}, "2030-01-15 12:00", "2030-01-31 11:00"},
+		{"monthly 31st in february", ReminderMonthly, ReminderParams{DayOfMonth: 31}, "2030-01-31 11:00", "2030-02-28 11:00"},
+		{"monthly 31st in april", ReminderMonthly, ReminderParams{DayOfMonth: 31}, "2030-03-31 11:00", "2030-04-30 11:00"},
+		{"monthly 30th in leap february", ReminderMonthly, ReminderParams{DayOfMonth: 30}, "2032-02-01 12:00", "2032-02-29 11:00"},
+		{"monthly last day", ReminderMonthly, ReminderParams{DayOfMonth: -1}, "2030-02-01 12:00", "2030-02-28 11:00"},
+		{"monthly last day in leap february", ReminderMonthly, ReminderParams{DayOfMonth: -1}, "2032-02-01 12:00", "2032-02-29 11:00"},
+		{"monthly 31st in leap february", ReminderMonthly, ReminderParams{DayOfMonth: 31}, "2032-01-31 11:00", "2032-02-29 11:00"},
+		{"monthly 31st after leap february", ReminderMonthly, ReminderParams{DayOfMonth: 31}, "2032-02-29 11:00", "2032-03-31 11:00"},
+		{"monthly 29th in february", ReminderMonthly, ReminderParams{DayOfMonth: 29}, "2030-01-29 11:00", "2030-02-28 11:00"},
+		{"monthly 29th in leap february", ReminderMonthly, ReminderParams{DayOfMonth: 29}, "2032-01-29 11:00", "2032-02-29 11:00"},
+		{"monthly 29th after february", ReminderMonthly, ReminderParams{DayOfMonth: 29}, "2030-02-28 11:00", "2030-03-29 11:00"},
+
+		{"29 feb in non-leap year", ReminderYearly, ReminderParams{Month: 2, Day: 29}, "2030-01-01 12:00", "2030-02-28 11:00"},
+		{"29 feb in leap year", ReminderYearly, ReminderParams{Month: 2, Day: 29}, "2031-03-01 12:00", "2032-02-29 11:00"},
+
+		{"floating next possible day", ReminderFloating, ReminderParams{Days: []int{1, 3}}, "2030-01-01 12:00", "2030-01-02 11:00"},
+		{"floating wraps the week", ReminderFloating, ReminderParams{Days: []int{1, 3}}, "2030-01-02 11:00", "2030-01-07 11:00"},
+		{"floating without days", ReminderFloating, ReminderParams{DayOfWeek: 6}, "2030-01-01 12:00", "2030-01-05 11:00"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			after, err := time.ParseInLocation(layout, tt.after, moscow)
+			if err != nil {
+				t.Fatal(err)
+			}
+			next, err := NextReminderRun(tt.typ, tt.params, after)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := next.Format(layout); got != tt.want || next.Location() != moscow {
+				t.Errorf("NextReminderRun after %s = %s %s, want %s", tt.after, got, next.Location(), tt.want)
+			}
+		})
+	}
+}
+
+// TestNextReminderRunSpringForward: 02:30 не существует в день перехода на летнее время,
+// напоминание приходит в 03:30 CEST, а на следующий день — снова в 02:30
+func TestNextReminderRunSpringForward(t *testing.T) {
+	berlin, err := time.LoadLocation("Europe/Berlin")
+	if err != nil {
+		t.Fatal(err)
+	}
+	params := ReminderParams{Time: "02:30"}
+
+	next, err := NextReminderRun(ReminderDaily, params, time.Date(2030, time.March, 30, 12, 0, 0, 0, berlin))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := next.Format("2006-01-02 15:04 MST"); got != "2030-03-31 03:30 CEST" {
+		t.Errorf("run on spring-forward day at %s, want 2030-03-31 03:30 CEST", got)
+	}
+
+	next, err = NextReminderRun(ReminderDaily, params, next)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := next.Format("2006-01-02 15:04 MST"); got != "2030-04-01 02:30 CEST" {
+		t.Errorf("run after spring-forward day at %s, want 2030-04-01 02:30 CEST", got)
+	}
+}
+
+// TestNextReminderRunFallBack: 02:30 бывает дважды в день перехода на зимнее время,
+// напоминание должно прийти один раз
+func TestNextReminderRunFallBack(t *testing.T) {
+	berlin, err := time.LoadLocation("Europe/Berlin")
+	if err != nil {
+		t.Fatal(err)
+	}
+	params := ReminderParams{Time: "02:30"}
+	const layout = "2006-01-02 15:04 MST"
+
+	tests := []struct {
+		after time.Time
+		want  string
+	}{
+		{time.Date(2030, time.October, 26, 12, 0, 0, 0, berlin), "2030-10-27 02:30 CET"},
+		// Первые 02:30 (летние) напоминание пропускает и приходит во вторые
+		{time.Date(2030, time.October, 27, 0, 15, 0, 0, time.UTC), "2030-10-27 02:30 CET"},
+		{time.Date(2030, time.October, 27, 0, 45, 0, 0, time.UTC), "2030-10-27 02:30 CET"},
+		// После запуска — уже завтра
+		{time.Date(2030, time.October, 27, 1, 30, 0, 0, time.UTC), "2030-10-28 02:30 CET"},
+	}
+	for _, tt := range tests {
+		after := tt.after.In(berlin)
+		next, err := NextReminderRun(ReminderDaily, params, after)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := next.Format(layout); got != tt.want {
+			t.Errorf("run after %s at %s, want %s", after.Format(layout), got, tt.want)
+		}
+	}
+}
+
+// TestNextReminderRunTimezoneChange: после смены часового пояса пользователя следующий
+// запуск считается от последнего в новом поясе и приходит в то же время по новым часам
+func TestNextReminderRunTimezoneChange(t *testing.T) {
+	moscow, err := time.LoadLocation("Europe/Moscow")
+	if err != nil {
+		t.Fatal(err)
+	}
+	params := ReminderParams{Time: "09:00"}
+	last := time.Date(2030, time.January, 1, 9, 0, 0, 0, moscow)
+
+	tests := []struct {
+		zone string
+		want string
+	}{
+		{"Europe/Moscow", "2030-01-02 09:00 +0300"},
+		// На востоке 09:00 уже прошло — завтра
+		{"Asia/Novosibirsk", "2030-01-02 09:00 +0700"},
+		// На западе 09:00 ещё впереди — сегодня
+		{"Europe/London", "2030-01-01 09:00 +0000"},
+	}
+	for _, tt := range tests {
+		loc, err := time.LoadLocation(tt.zone)
+		if err != nil {
+			t.Fatal(err)
+		}
+		next, err := NextReminderRun(ReminderDaily, params, last.In(loc))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := next.Format("2006-01-02 15:04 -0700"); got != tt.want || next.Location() != loc {
+			t.Errorf("%s: next run %s %s, want %s", tt.zone, got, next.Location(), tt.want)
+		}
+	}
+}
+
+func TestNextReminderRunInvalid(t *testing.T) {
+	after := time.Date(2030, time.January, 1, 12, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name   string
+		typ    ReminderType
+		params ReminderParams
+	}{
+		{"day of week", ReminderWeekly, ReminderParams{DayOfWeek: 7}},
+		{"week of month", ReminderMonthWeek, ReminderParams{WeekOfMonth: 6, DayOfWeek: 5}},
+		{"no week of month", ReminderMonthWeek, ReminderParams{DayOfWeek: 5}},
+		{"day of month", ReminderMonthly, ReminderParams{DayOfMonth: 32}},
+		{"month", ReminderYearly, ReminderParams{Month: 13, Day: 1}},
+		{"floating day", ReminderFloating, ReminderParams{Days: []int{1, 9}}},
+		{"time", ReminderDaily, ReminderParams{Time: "25:00"}},
+		{"type", ReminderType("hourly"), ReminderParams{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if next, err := NextReminderRun(tt.typ, tt.params, after); err == nil {
+				t.Errorf("NextReminderRun = %s, want error", next)
+			}
+		})
+	}
+}
+
+func TestClampedMonthDays(t *testing.T) {
+	tests := []struct {
+		day     int
+		want    string
+		wantErr bool
+	}{
+		{-1, "BYMONTHDAY=-1", false},
+		{1, "BYMONTHDAY=1", false},
+		{28, "BYMONTHDAY=28", false},
+		{29, "BYMONTHDAY=28,29;BYSETPOS=-1", false},
+		{30, "BYMONTHDAY=28,29,30;BYSETPOS=-1", false},
+		{31, "BYMONTHDAY=28,29,30,31;BYSETPOS=-1", false},
+		{0, "", true},
+		{-2, "", true},
+		{32, "", true},
+	}
+	for _, tt := range tests {
+		got, err := clampedMonthDays(tt.day)
+		if (err != nil) != tt.wantErr || got != tt.want {
+			t.Errorf("clampedMonthDays(%d) = %q, %v; want %q, error %v", tt.day, got, err, tt.want, tt.wantErr)
+		}
+	}
+}
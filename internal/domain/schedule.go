@@ -40,12 +40,12 @@ type WeeklyEvent struct {
 	CreatedAt      time.Time
 }
 
-// IsConfirmedThisWeek checks if floating event has confirmed day for current week
-func (e *WeeklyEvent) IsConfirmedThisWeek() bool {
+// IsConfirmedThisWeek checks if floating event has confirmed day for the week of now
+func (e *WeeklyEvent) IsConfirmedThisWeek(now time.Time) bool {
 	if !e.IsFloating || e.ConfirmedDay == nil {
 		return false
 	}
-	_, week := time.Now().ISOWeek()
+	_, week := now.ISOWeek()
 	return e.ConfirmedWeek == week
 }
 
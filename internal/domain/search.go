@@ -0,0 +1,55 @@
+package domain
+
+// SearchKind — тип найденного объекта
+type SearchKind string
+
+const (
+	SearchKindTask          SearchKind = "task"
+	SearchKindPerson        SearchKind = "person"
+	SearchKindChecklist     SearchKind = "checklist"
+	SearchKindWeeklyEvent   SearchKind = "weekly"
+	SearchKindCalendarEvent SearchKind = "event"
+)
+
+// SearchResult — одна находка полнотекстового поиска
+type SearchResult struct {
+	Kind  SearchKind
+	RefID int64 // ID объекта в его таблице
+	Title string
+	Body  string  // описание, заметки, пункты чек-листа и т.п.
+	Rank  float64 // чем больше, тем релевантнее
+}
+
+func (r *SearchResult) KindEmoji() string {
+	switch r.Kind {
+	case SearchKindTask:
+		return "📋"
+	case SearchKindPerson:
+		return "👤"
+	case SearchKindChecklist:
+		return "☑️"
+	case SearchKindWeeklyEvent:
+		return "🗓"
+	case SearchKindCalendarEvent:
+		return "📆"
+	default:
+		return "🔎"
+	}
+}
+
+func (r *SearchResult) KindName() string {
+	switch r.Kind {
+	case SearchKindTask:
+		return "Задача"
+	case SearchKindPerson:
+		return "Человек"
+	case SearchKindChecklist:
+		return "Чек-лист"
+	case SearchKindWeeklyEvent:
+		return "Расписание"
+	case SearchKindCalendarEvent:
+		return "Календарь"
+	default:
+		return string(r.Kind)
+	}
+}
@@ -0,0 +1,166 @@
+package domain
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// BriefingSection — раздел утреннего брифинга
+type BriefingSection string
+
+const (
+	SectionBirthdays BriefingSection = "birthdays"
+	SectionCalendar  BriefingSection = "calendar"
+	SectionSchedule  BriefingSection = "schedule"
+	SectionTasks     BriefingSection = "tasks"
+)
+
+// BriefingSections — все разделы в порядке вывода
+var BriefingSections = []BriefingSection{SectionBirthdays, SectionCalendar, SectionSchedule, SectionTasks}
+
+// DefaultBriefingSections — разделы брифинга по умолчанию
+const DefaultBriefingSections = "birthdays,calendar,tasks"
+
+// Name returns Russian name for the section
+func (s BriefingSection) Name() string {
+	switch s {
+	case SectionBirthdays:
+		return "🎂 Дни рождения"
+	case SectionCalendar:
+		return "📆 Календарь"
+	case SectionSchedule:
+		return "🗓 Расписание"
+	case SectionTasks:
+		return "📋 Задачи"
+	default:
+		return string(s)
+	}
+}
+
+// UserSettings — личные настройки пользователя
+type UserSettings struct {
+	UserID           int64
+	Timezone         string // IANA, например Europe/Moscow
+	MorningTime      string // "HH:MM", пусто — утренний брифинг выключен
+	EveningTime      string // "HH:MM", пусто — вечерний чекин выключен
+	QuietStart       string // "HH:MM", пусто — тихих часов нет
+	QuietEnd         string // "HH:MM", может быть раньше QuietStart (через полночь)
+	BriefingSections string // через запятую: birthdays,calendar,tasks
+	UpdatedAt        time.Time
+}
+
+// Location returns the user's timezone or fallback if it is not set or invalid
+func (s *UserSettings) Location(fallback *time.Location) *time.Location {
+	if s.Timezone == "" {
+		return fallback
+	}
+	loc, err := time.LoadLocation(s.Timezone)
+	if err != nil {
+		return fallback
+	}
+	return loc
+}
+
+// HasQuietHours returns true if quiet hours are configured
+func (s *UserSettings) HasQuietHours() bool {
+	return s.QuietStart != "" && s.QuietEnd != "" && s.QuietStart != s.QuietEnd
+}
+
+// InQuietHours reports whether local (already in the user's timezone) falls into quiet hours
+func (s *UserSettings) InQuietHours(local time.Time) bool {
+	if !s.HasQuietHours() {
+		return false
+	}
+	now := local.Format("15:04")
+	if s.QuietStart < s.QuietEnd {
+		return now >= s.QuietStart && now < s.QuietEnd
+	}
+	// Через полночь: 23:00–08:00
+	return now >= s.QuietStart || now < s.QuietEnd
+}
+
+// QuietUntil returns the end of the current quiet hours for local (already in the user's timezone)
+func (s *UserSettings) QuietUntil(local time.Time) (time.Time, bool) {
+	if !s.InQuietHours(local) {
+		return time.Time{}, false
+	}
+	end, err := time.ParseInLocation("15:04", s.QuietEnd, local.Location())
+	if err != nil {
+		return time.Time{}, false
+	}
+	until := time.Date(local.Year(), local.Month(), local.Day(), end.Hour(), end.Minute(), 0, 0, local.Location())
+	if !until.After(local) {
+		until = until.AddDate(0, 0, 1)
+	}
+	return until, true
+}
+
+// QuietHoursLabel returns "23:00–08:00" or "выкл"
+func (s *UserSettings) QuietHoursLabel() string {
+	if !s.HasQuietHours() {
+		return "выкл"
+	}
+	return s.QuietStart + "–" + s.QuietEnd
+}
+
+// GetSections returns briefing sections in display order
+func (s *UserSettings) GetSections() []BriefingSection {
+	var result []BriefingSection
+	for _, section := range BriefingSections {
+		if s.HasSection(section) {
+			result = append(result, section)
+		}
+	}
+	return result
+}
+
+// HasSection returns true if the section is enabled in the briefing
+func (s *UserSettings) HasSection(section BriefingSection) bool {
+	for _, name := range strings.Split(s.BriefingSections, ",") {
+		if BriefingSection(strings.TrimSpace(name)) == section {
+			return true
+		}
+	}
+	return false
+}
+
+// ToggleSection enables or disables the section
+func (s *UserSettings) ToggleSection(section BriefingSection) {
+	var names []string
+	for _, current := range BriefingSections {
+		enabled := s.HasSection(current)
+		if current == section {
+			enabled = !enabled
+		}
+		if enabled {
+			names = append(names, string(current))
+		}
+	}
+	s.BriefingSections = strings.Join(names, ",")
+}
+
+// NormalizeClock parses "7:30", "07.30", "0730", "7" into "07:30"
+func NormalizeClock(s string) (string, bool) {
+	s = strings.TrimSpace(s)
+	s = strings.NewReplacer(".", ":", "-", ":").Replace(s)
+	var h, m int
+	switch {
+	case strings.Contains(s, ":"):
+		if _, err := fmt.Sscanf(s, "%d:%d", &h, &m); err != nil {
+			return "", false
+		}
+	case len(s) == 4:
+		if _, err := fmt.Sscanf(s, "%2d%2d", &h, &m); err != nil {
+			return "", false
+		}
+	default:
+		if _, err := fmt.Sscanf(s, "%d", &h); err != nil {
+			return "", false
+		}
+	}
+	if h < 0 || h > 23 || m < 0 || m > 59 {
+		return "", false
+	}
+	return fmt.Sprintf("%02d:%02d", h, m), true
+}
@@ -0,0 +1,65 @@
+package domain
+
+import (
+	"testing"
+	"time"
+)
+
+func TestQuietHours(t *testing.T) {
+	berlin, err := time.LoadLocation("Europe/Berlin")
+	if err != nil {
+		t.Fatal(err)
+	}
+	day := func(d, h, m int) time.Time { return time.Date(2030, time.June, d, h, m, 0, 0, berlin) }
+	night := &UserSettings{QuietStart: "23:00", QuietEnd: "08:00"}
+	lunch := &UserSettings{QuietStart: "13:00", QuietEnd: "14:30"}
+
+	tests := []struct {
+		st    *UserSettings
+		local time.Time
+		until time.Time // zero — не тихие часы
+	}{
+		{night, day(4, 22, 59), time.Time{}},
+		{night, day(4, 23, 0), day(5, 8, 0)},
+		{night, day(5, 0, 30), day(5, 8, 0)},
+		{night, day(5, 7, 59), day(5, 8, 0)},
+		{night, day(5, 8, 0), time.Time{}},
+		{lunch, day(4, 12, 59), time.Time{}},
+		{lunch, day(4, 13, 0), day(4, 14, 30)},
+		{lunch, day(4, 14, 30), time.Time{}},
+		{&UserSettings{}, day(4, 3, 0), time.Time{}},
+		{&UserSettings{QuietStart: "23:00", QuietEnd: "23:00"}, day(4, 23, 0), time.Time{}},
+	}
+	for _, tt := range tests {
+		until, ok := tt.st.QuietUntil(tt.local)
+		if ok != !tt.until.IsZero() || !until.Equal(tt.until) {
+			t.Errorf("%s at %s: until %s, %v; want %s", tt.st.QuietHoursLabel(), tt.local.Format("02 15:04"), until, ok, tt.until)
+		}
+		if in := tt.st.InQuietHours(tt.local); in != ok {
+			t.Errorf("%s at %s: InQuietHours %v, QuietUntil %v", tt.st.QuietHoursLabel(), tt.local.Format("02 15:04"), in, ok)
+		}
+	}
+}
+
+func TestNormalizeClock(t *testing.T) {
+	tests := []struct {
+		in   string
+		want string
+		ok   bool
+	}{
+		{"7:30", "07:30", true},
+		{"07:30", "07:30", true},
+		{"07.30", "07:30", true},
+		{"0730", "07:30", true},
+		{"7", "07:00", true},
+		{"23:59", "23:59", true},
+		{"24:00", "", false},
+		{"12:60", "", false},
+		{"утром", "", false},
+	}
+	for _, tt := range tests {
+		if got, ok := NormalizeClock(tt.in); got != tt.want || ok != tt.ok {
+			t.Errorf("NormalizeClock(%q) = %q, %v; want %q, %v", tt.in, got, ok, tt.want, tt.ok)
+		}
+	}
+}
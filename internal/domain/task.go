@@ -1,6 +1,7 @@
 package domain
 
 import (
+	"strconv"
 	"strings"
 	"time"
 )
@@ -22,6 +23,7 @@ const (
 	RepeatWeekly     RepeatType = "weekly"      // Раз в неделю
 	RepeatMonthly    RepeatType = "monthly"     // Раз в месяц (тот же день)
 	RepeatMonthlyNth RepeatType = "monthly_nth" // N-й день недели месяца (напр. 2-я пятница)
+	RepeatRule       RepeatType = "rrule"       // Произвольное правило RFC 5545 (Task.RRule)
 )
 
 type Task struct {
@@ -47,9 +49,14 @@ type Task struct {
 	RepeatType    RepeatType // Тип повторения
 	RepeatTime    string     // Время напоминания "HH:MM"
 	RepeatWeekNum int        // Номер недели месяца (1-4) для monthly_nth
+	RRule         string     // Правило RFC 5545 для RepeatRule, напр. "FREQ=MONTHLY;BYDAY=-1FR"
+	RRuleStart    *time.Time // DTSTART серии RRule, общий для всех её вхождений
 
 	// Todoist sync
 	TodoistID string // ID задачи в Todoist (для синхронизации)
+
+	// Дерево задач
+	ParentID *int64 // Родительская задача (для подзадач)
 }
 
 func (t *Task) IsDone() bool {
@@ -86,6 +93,10 @@ func (t *Task) NextOccurrence(from time.Time) *time.Time {
 		return nil
 	}
 
+	if t.RepeatType == RepeatRule {
+		return t.nextRuleOccurrence(from)
+	}
+
 	var next time.Time
 
 	switch t.RepeatType {
@@ -206,14 +217,21 @@ func RemindBeforeLabel(minutes int) string {
 		return "за час"
 	case Remind30Min:
 		return "за 30 мин"
+	case 0:
+		return "в срок"
 	default:
-		if minutes >= 1440 {
-			return "за " + string(rune('0'+minutes/1440)) + " дн."
+		// Произвольные интервалы от /remind с точным временем: «за 2 ч. 15 мин.»
+		if minutes%1440 == 0 {
+			return "за " + strconv.Itoa(minutes/1440) + " дн."
 		}
 		if minutes >= 60 {
-			return "за " + string(rune('0'+minutes/60)) + " ч."
+			label := "за " + strconv.Itoa(minutes/60) + " ч."
+			if minutes%60 != 0 {
+				label += " " + strconv.Itoa(minutes%60) + " мин."
+			}
+			return label
 		}
-		return "за " + string(rune('0'+minutes)) + " мин."
+		return "за " + strconv.Itoa(minutes) + " мин."
 	}
 }
 
@@ -7,6 +7,7 @@ type UserRole string
 const (
 	RoleOwner   UserRole = "owner"
 	RolePartner UserRole = "partner"
+	RoleMember  UserRole = "member" // пришёл по приглашению в семью
 )
 
 type User struct {
@@ -0,0 +1,208 @@
+// Package dates распознаёт даты, время и длительности в свободном тексте
+// на русском и английском: «завтра в 15:30», «через 3 дня», «в следующий
+// вторник», «на выходных», «в конце месяца», «с 15:00 до 17:00»,
+// «next friday at 3pm».
+//
+// Парсер работает по словам, а не регулярками: так порядок разбора
+// детерминирован и не зависит от ASCII-only \b в RE2.
+package dates
+
+import (
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Result — найденная в тексте дата
+type Result struct {
+	// Time — дата в полночь или дата со временем, если HasTime
+	Time time.Time
+	// HasTime — во фразе было время суток («в 15:30», «утром», «через 2 часа»)
+	HasTime bool
+	// Duration — длительность («на 2 часа», «с 15:00 до 17:00»), 0 если не указана
+	Duration time.Duration
+	// Start, End — байтовые границы совпадения в исходном тексте
+	Start, End int
+	// Text — совпавший фрагмент
+	Text string
+
+	spans []span
+}
+
+// Date returns the date part at midnight
+func (r *Result) Date() time.Time {
+	return midnight(r.Time)
+}
+
+// Until returns the end of the range (Time + Duration)
+func (r *Result) Until() time.Time {
+	return r.Time.Add(r.Duration)
+}
+
+type span struct {
+	start, end int
+}
+
+// Parse ищет в тексте дату и/или время относительно now.
+// Возвращает nil, если ничего не найдено (одна длительность датой не считается).
+//
+// Время без даты — ближайшее такое время: сегодня или завтра, если уже прошло.
+// Дата без года в прошлом переносится на следующий год.
+func Parse(text string, now time.Time) *Result {
+	p := &parser{toks: tokenize(text), now: now, today: midnight(now), dateEnd: -1}
+
+	var (
+		date     *time.Time
+		clk      *clock
+		moment   *time.Time
+		duration time.Duration
+		spans    []span
+	)
+	for i := 0; i < len(p.toks); {
+		m, ok := p.match(i)
+		if !ok {
+			i++
+			continue
+		}
+
+		used := false
+		if m.moment != nil && moment == nil && date == nil && clk == nil {
+			moment = m.moment
+			used = true
+		}
+		if m.date != nil && date == nil && moment == nil {
+			date = m.date
+			p.dateEnd = i + m.n
+			used = true
+		}
+		if m.clock != nil && clk == nil && moment == nil {
+			clk = m.clock
+			used = true
+		}
+		if m.duration > 0 && duration == 0 {
+			duration = m.duration
+			used = true
+		}
+		if used {
+			spans = append(spans, span{p.toks[i].start, p.toks[i+m.n-1].end})
+		}
+		i += m.n
+	}
+
+	r := &Result{Duration: duration, spans: spans}
+	switch {
+	case moment != nil:
+		r.Time = *moment
+		r.HasTime = true
+	case date != nil && clk != nil:
+		r.Time = clk.on(*date)
+		r.HasTime = true
+	case date != nil:
+		r.Time = *date
+	case clk != nil:
+		r.Time = clk.on(p.today)
+		if !r.Time.After(now) {
+			r.Time = clk.on(p.today.AddDate(0, 0, 1))
+		}
+		r.HasTime = true
+	default:
+		return nil
+	}
+
+	r.Start, r.End = spans[0].start, spans[0].end
+	for _, s := range spans[1:] {
+		if s.start < r.Start {
+			r.Start = s.start
+		}
+		if s.end > r.End {
+			r.End = s.end
+		}
+	}
+	r.Text = text[r.Start:r.End]
+	return r
+}
+
+// Extract is Parse that also returns the text with the matched words removed
+func Extract(text string, now time.Time) (clean string, r *Result) {
+	r = Parse(text, now)
+	if r == nil {
+		return strings.Join(strings.Fields(text), " "), nil
+	}
+
+	clean = text
+	for i := len(r.spans) - 1; i >= 0; i-- {
+		s := r.spans[i]
+		clean = clean[:s.start] + " " + clean[s.end:]
+	}
+	clean = strings.Join(strings.Fields(clean), " ")
+	clean = strings.Trim(clean, " ,;:-–—")
+	clean = strings.ReplaceAll(clean, " ,", ",")
+	return clean, r
+}
+
+func midnight(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, t.Location())
+}
+
+// clock — время суток без даты
+type clock struct {
+	hour, min int
+}
+
+func (c clock) on(day time.Time) time.Time {
+	return time.Date(day.Year(), day.Month(), day.Day(), c.hour, c.min, 0, 0, day.Location())
+}
+
+// token — слово в нижнем регистре без пунктуации по краям и его байтовые границы
+type token struct {
+	s          string
+	start, end int
+}
+
+const (
+	trimLeft  = "(«\"'"
+	trimRight = ",;:!?.)»\"'"
+)
+
+func tokenize(text string) []token {
+	var toks []token
+	for i := 0; i < len(text); {
+		r, size := utf8.DecodeRuneInString(text[i:])
+		if unicode.IsSpace(r) {
+			i += size
+			continue
+		}
+
+		j := i
+		for j < len(text) {
+			r, size := utf8.DecodeRuneInString(text[j:])
+			if unicode.IsSpace(r) {
+				break
+			}
+			j += size
+		}
+
+		start, end := i, j
+		for start < end {
+			r, size := utf8.DecodeRuneInString(text[start:end])
+			if !strings.ContainsRune(trimLeft, r) {
+				break
+			}
+			start += size
+		}
+		for end > start {
+			r, size := utf8.DecodeLastRuneInString(text[start:end])
+			if !strings.ContainsRune(trimRight, r) {
+				break
+			}
+			end -= size
+		}
+		if start < end {
+			s := strings.ReplaceAll(strings.ToLower(text[start:end]), "ё", "е")
+			toks = append(toks, token{s: s, start: start, end: end})
+		}
+		i = j
+	}
+	return toks
+}
@@ -0,0 +1,192 @@
+package dates
+
+import (
+	"testing"
+	"time"
+)
+
+var moscow = time.FixedZone("MSK", 3*60*60)
+
+// now — среда, 5 июня 2030, 10:00
+var now = time.Date(2030, time.June, 5, 10, 0, 0, 0, moscow)
+
+func TestParse(t *testing.T) {
+	const layout = "2006-01-02 15:04"
+	tests := []struct {
+		text     string
+		want     string // дата и время; без времени — полночь
+		hasTime  bool
+		duration time.Duration
+		match    string // Result.Text
+	}{
+		{"завтра в 15:30", "2030-06-06 15:30", true, 0, "завтра в 15:30"},
+		{"сегодня вечером", "2030-06-05 19:00", true, 0, "сегодня вечером"},
+		{"послезавтра", "2030-06-07 00:00", false, 0, "послезавтра"},
+		{"через 3 дня", "2030-06-08 00:00", false, 0, "через 3 дня"},
+		{"через 2 часа", "2030-06-05 12:00", true, 0, "через 2 часа"},
+		{"через полчаса", "2030-06-05 10:30", true, 0, "через полчаса"},
+		{"в пятницу", "2030-06-07 00:00", false, 0, "в пятницу"},
+		{"в среду", "2030-06-12 00:00", false, 0, "в среду"},
+		{"в эту среду", "2030-06-05 00:00", false, 0, "в эту среду"},
+		{"в следующий вторник", "2030-06-11 00:00", false, 0, "в следующий вторник"},
+		{"на выходных", "2030-06-08 00:00", false, 0, "на выходных"},
+		{"на следующих выходных", "2030-06-15 00:00", false, 0, "на следующих выходных"},
+		{"в конце месяца", "2030-06-30 00:00", false, 0, "в конце месяца"},
+		{"к концу недели", "2030-06-07 00:00", false, 0, "к концу недели"},
+		{"на следующей неделе", "2030-06-10 00:00", false, 0, "на следующей неделе"},
+		{"с 15:00 до 17:00", "2030-06-05 15:00", true, 2 * time.Hour, "с 15:00 до 17:00"},
+		{"в 9 утра", "2030-06-06 09:00", true, 0, "в 9 утра"},
+		{"в 3 часа дня", "2030-06-05 15:00", true, 0, "в 3 часа дня"},
+		{"20 мая", "2031-05-20 00:00", false, 0, "20 мая"},
+		{"04.07", "2030-07-04 00:00", false, 0, "04.07"},
+		{"next friday at 3pm", "2030-06-14 15:00", true, 0, "next friday at 3pm"},
+		{"tomorrow at 9:15", "2030-06-06 09:15", true, 0, "tomorrow at 9:15"},
+		{"in 2 hours", "2030-06-05 12:00", true, 0, "in 2 hours"},
+		{"this weekend", "2030-06-08 00:00", false, 0, "this weekend"},
+		{"end of the month", "2030-06-30 00:00", false, 0, "end of the month"},
+
+		// Голый час сразу после даты
+		{"в понедельник в 9", "2030-06-10 09:00", true, 0, "в понедельник в 9"},
+		{"завтра в 9", "2030-06-06 09:00", true, 0, "завтра в 9"},
+		{"завтра к 10", "2030-06-06 10:00", true, 0, "завтра к 10"},
+		{"в пятницу в 3", "2030-06-07 15:00", true, 0, "в пятницу в 3"},
+		{"20 мая в 18", "2031-05-20 18:00", true, 0, "20 мая в 18"},
+
+		{"через 15 минут", "2030-06-05 10:15", true, 0, "через 15 минут"},
+		{"через полтора часа", "2030-06-05 11:30", true, 0, "через полтора часа"},
+		{"через час", "2030-06-05 11:00", true, 0, "через час"},
+		{"через неделю", "2030-06-12 00:00", false, 0, "через неделю"},
+		{"через 2 недели", "2030-06-19 00:00", false, 0, "через 2 недели"},
+		{"через месяц", "2030-07-05 00:00", false, 0, "через месяц"},
+		{"через 3650 дней", "2040-06-02 00:00", false, 0, "через 3650 дней"},
+		{"в субботу утром", "2030-06-08 09:00", true, 0, "в субботу утром"},
+		{"в следующую пятницу в 19:00", "2030-06-14 19:00", true, 0, "в следующую пятницу в 19:00"},
+		{"послезавтра в 8 утра", "2030-06-07 08:00", true, 0, "послезавтра в 8 утра"},
+		{"в четверг с 10 до 12", "2030-06-06 10:00", true, 2 * time.Hour, "в четверг с 10 до 12"},
+		{"завтра на 2 часа", "2030-06-06 00:00", false, 2 * time.Hour, "завтра на 2 часа"},
+		{"к 18:00", "2030-06-05 18:00", true, 0, "к 18:00"},
+		{"в 10.30", "2030-06-05 10:30", true, 0, "в 10.30"},
+		{"15 июня", "2030-06-15 00:00", false, 0, "15 июня"},
+		{"1 января", "2031-01-01 00:00", false, 0, "1 января"},
+		{"20-го числа", "2030-06-20 00:00", false, 0, "20-го числа"},
+		{"31.12.2030", "2030-12-31 00:00", false, 0, "31.12.2030"},
+		{"в эти выходные", "2030-06-08 00:00", false, 0, "в эти выходные"},
+		{"в конце недели", "2030-06-07 00:00", false, 0, "в конце недели"},
+
+		{"tomorrow morning", "2030-06-06 09:00", true, 0, "tomorrow morning"},
+		{"next monday", "2030-06-10 00:00", false, 0, "next monday"},
+		{"on friday at 5pm", "2030-06-07 17:00", true, 0, "on friday at 5pm"},
+		{"in 30 min", "2030-06-05 10:30", true, 0, "in 30 min"},
+		{"in a week", "2030-06-12 00:00", false, 0, "in a week"},
+		{"on the 20th", "2030-06-20 00:00", false, 0, "on the 20th"},
+		{"at noon", "2030-06-05 12:00", true, 0, "at noon"},
+		{"tonight", "2030-06-05 20:00", true, 0, "tonight"},
+		{"day after tomorrow", "2030-06-07 00:00", false, 0, "day after tomorrow"},
+		{"for an hour tomorrow", "2030-06-06 00:00", false, time.Hour, "for an hour tomorrow"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.text, func(t *testing.T) {
+			r := Parse(tt.text, now)
+			if r == nil {
+				t.Fatalf("Parse(%q) = nil", tt.text)
+			}
+			if got := r.Time.Format(layout); got != tt.want {
+				t.Errorf("time %s, want %s", got, tt.want)
+			}
+			if r.HasTime != tt.hasTime {
+				t.Errorf("HasTime %v, want %v", r.HasTime, tt.hasTime)
+			}
+			if r.Duration != tt.duration {
+				t.Errorf("duration %s, want %s", r.Duration, tt.duration)
+			}
+			if r.Text != tt.match || tt.text[r.Start:r.End] != r.Text {
+				t.Errorf("match %q at [%d:%d], want %q", r.Text, r.Start, r.End, tt.match)
+			}
+		})
+	}
+}
+
+// TestParseMonthEnd: «через месяц» с 31-го — последний день короткого месяца
+func TestParseMonthEnd(t *testing.T) {
+	tests := []struct {
+		text string
+		now  time.Time
+		want string
+	}{
+		{"через месяц", time.Date(2030, time.January, 31, 10, 0, 0, 0, moscow), "2030-02-28"},
+		{"через месяц", time.Date(2032, time.January, 31, 10, 0, 0, 0, moscow), "2032-02-29"},
+		{"in 1 month", time.Date(2030, time.January, 31, 10, 0, 0, 0, moscow), "2030-02-28"},
+		{"через 2 месяца", time.Date(2030, time.January, 31, 10, 0, 0, 0, moscow), "2030-03-31"},
+		{"через 3 месяца", time.Date(2030, time.May, 31, 10, 0, 0, 0, moscow), "2030-08-31"},
+		{"через месяц", time.Date(2030, time.March, 31, 10, 0, 0, 0, moscow), "2030-04-30"},
+		{"через месяц", time.Date(2030, time.December, 31, 10, 0, 0, 0, moscow), "2031-01-31"},
+		{"через год", time.Date(2032, time.February, 29, 10, 0, 0, 0, moscow), "2033-02-28"},
+	}
+	for _, tt := range tests {
+		r := Parse(tt.text, tt.now)
+		if r == nil {
+			t.Errorf("Parse(%q) on %s = nil", tt.text, tt.now.Format("2006-01-02"))
+			continue
+		}
+		if got := r.Time.Format("2006-01-02"); got != tt.want {
+			t.Errorf("Parse(%q) on %s = %s, want %s", tt.text, tt.now.Format("2006-01-02"), got, tt.want)
+		}
+	}
+}
+
+// В этих фразах есть числа и предлоги, но нет даты
+func TestParseNoDate(t *testing.T) {
+	for _, text := range []string{
+		"купить 2 кг молока",
+		"купить 3 яблока",
+		"позвонить маме",
+		"на 2 часа",
+		"в 2 подъезде",
+		"взять 10 рублей на проезд",
+		"в 9",
+		"3 дня",
+		"Morning run",
+		// Дальше ста лет — не дата
+		"через 100000000000 дней",
+		"через 99999999999999999999 минут",
+		"in 5000 years",
+		"",
+	} {
+		if r := Parse(text, now); r != nil {
+			t.Errorf("Parse(%q) = %s (%q), want nil", text, r.Time.Format("2006-01-02 15:04"), r.Text)
+		}
+	}
+}
+
+func TestExtract(t *testing.T) {
+	tests := []struct {
+		text  string
+		clean string
+		match string // "" — даты нет
+	}{
+		{"Позвонить маме завтра в 15:30", "Позвонить маме", "завтра в 15:30"},
+		{"завтра в 15:30 позвонить маме", "позвонить маме", "завтра в 15:30"},
+		{"Встреча с 15:00 до 17:00 в офисе", "Встреча в офисе", "с 15:00 до 17:00"},
+		{"Оплатить интернет в конце месяца", "Оплатить интернет", "в конце месяца"},
+		{"Забрать посылку, в пятницу", "Забрать посылку", "в пятницу"},
+		{"Call mom next friday at 3pm", "Call mom", "next friday at 3pm"},
+		{"Стоматолог в понедельник в 9", "Стоматолог", "в понедельник в 9"},
+		{"купить  2 кг   молока", "купить 2 кг молока", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.text, func(t *testing.T) {
+			clean, r := Extract(tt.text, now)
+			if clean != tt.clean {
+				t.Errorf("clean %q, want %q", clean, tt.clean)
+			}
+			switch {
+			case tt.match == "" && r != nil:
+				t.Errorf("found %q, want nothing", r.Text)
+			case tt.match != "" && r == nil:
+				t.Errorf("found nothing, want %q", tt.match)
+			case r != nil && r.Text != tt.match:
+				t.Errorf("match %q, want %q", r.Text, tt.match)
+			}
+		})
+	}
+}
@@ -0,0 +1,234 @@
+package dates
+
+import (
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Словари. Все формы — в нижнем регистре, «ё» заменена на «е».
+
+var weekdayWords = map[string]time.Weekday{
+	"понедельник": time.Monday, "понедельника": time.Monday,
+	"вторник": time.Tuesday, "вторника": time.Tuesday,
+	"среда": time.Wednesday, "среду": time.Wednesday, "среды": time.Wednesday,
+	"четверг": time.Thursday, "четверга": time.Thursday,
+	"пятница": time.Friday, "пятницу": time.Friday, "пятницы": time.Friday,
+	"суббота": time.Saturday, "субботу": time.Saturday, "субботы": time.Saturday,
+	"воскресенье": time.Sunday, "воскресенья": time.Sunday,
+
+	"monday": time.Monday, "tuesday": time.Tuesday, "wednesday": time.Wednesday,
+	"thursday": time.Thursday, "friday": time.Friday, "saturday": time.Saturday,
+	"sunday": time.Sunday,
+}
+
+var monthWords = map[string]time.Month{
+	"января": time.January, "янв": time.January,
+	"февраля": time.February, "фев": time.February, "февр": time.February,
+	"марта": time.March, "мар": time.March,
+	"апреля": time.April, "апр": time.April,
+	"мая":  time.May,
+	"июня": time.June, "июн": time.June,
+	"июля": time.July, "июл": time.July,
+	"августа": time.August, "авг": time.August,
+	"сентября": time.September, "сен": time.September, "сент": time.September,
+	"октября": time.October, "окт": time.October,
+	"ноября": time.November, "ноя": time.November, "нояб": time.November,
+	"декабря": time.December, "дек": time.December,
+
+	"january": time.January, "jan": time.January,
+	"february": time.February, "feb": time.February,
+	"march": time.March, "mar": time.March,
+	"april": time.April, "apr": time.April,
+	"may":  time.May,
+	"june": time.June, "jun": time.June,
+	"july": time.July, "jul": time.July,
+	"august": time.August, "aug": time.August,
+	"september": time.September, "sep": time.September, "sept": time.September,
+	"october": time.October, "oct": time.October,
+	"november": time.November, "nov": time.November,
+	"december": time.December, "dec": time.December,
+}
+
+var numberWords = map[string]int{
+	"один": 1, "одну": 1, "одна": 1, "одно": 1,
+	"два": 2, "две": 2, "пару": 2, "пара": 2,
+	"три": 3, "четыре": 4, "пять": 5, "шесть": 6,
+	"семь": 7, "восемь": 8, "девять": 9, "десять": 10,
+
+	"a": 1, "an": 1, "one": 1, "two": 2, "three": 3, "four": 4, "five": 5,
+	"six": 6, "seven": 7, "eight": 8, "nine": 9, "ten": 10,
+}
+
+type unit int
+
+const (
+	unitNone unit = iota
+	unitMinute
+	unitHour
+	unitDay
+	unitWeek
+	unitMonth
+	unitYear
+)
+
+var unitWords = map[string]unit{
+	"минута": unitMinute, "минуту": unitMinute, "минуты": unitMinute, "минут": unitMinute, "мин": unitMinute,
+	"час": unitHour, "часа": unitHour, "часов": unitHour, "ч": unitHour,
+	"день": unitDay, "дня": unitDay, "дней": unitDay, "сутки": unitDay, "суток": unitDay, "д": unitDay,
+	"неделя": unitWeek, "неделю": unitWeek, "недели": unitWeek, "недель": unitWeek, "нед": unitWeek,
+	"месяц": unitMonth, "месяца": unitMonth, "месяцев": unitMonth, "мес": unitMonth,
+	"год": unitYear, "года": unitYear, "лет": unitYear,
+
+	"minute": unitMinute, "minutes": unitMinute, "min": unitMinute, "mins": unitMinute, "m": unitMinute,
+	"hour": unitHour, "hours": unitHour, "hr": unitHour, "hrs": unitHour, "h": unitHour,
+	"day": unitDay, "days": unitDay, "d": unitDay,
+	"week": unitWeek, "weeks": unitWeek, "w": unitWeek,
+	"month": unitMonth, "months": unitMonth,
+	"year": unitYear, "years": unitYear,
+}
+
+// duration переводит число единиц в длительность (только для минут, часов, дней, недель)
+func (u unit) duration(n int) time.Duration {
+	switch u {
+	case unitMinute:
+		return time.Duration(n) * time.Minute
+	case unitHour:
+		return time.Duration(n) * time.Hour
+	case unitDay:
+		return time.Duration(n) * 24 * time.Hour
+	case unitWeek:
+		return time.Duration(n) * 7 * 24 * time.Hour
+	}
+	return 0
+}
+
+// maxRelativeYears — «через N …» дальше ста лет не дата, а опечатка: «через 100000000000 дней»
+const maxRelativeYears = 100
+
+// fits reports whether n units are within maxRelativeYears
+func (u unit) fits(n int) bool {
+	switch u {
+	case unitMinute:
+		return n <= maxRelativeYears*366*24*60
+	case unitHour:
+		return n <= maxRelativeYears*366*24
+	case unitDay:
+		return n <= maxRelativeYears*366
+	case unitWeek:
+		return n <= maxRelativeYears*53
+	case unitMonth:
+		return n <= maxRelativeYears*12
+	default:
+		return n <= maxRelativeYears
+	}
+}
+
+// Части суток без точного времени
+var dayPartWords = map[string]clock{
+	"утром": {9, 0}, "morning": {9, 0},
+	"днем": {13, 0}, "afternoon": {14, 0},
+	"вечером": {19, 0}, "evening": {19, 0},
+	"ночью": {23, 0}, "night": {23, 0},
+	"полдень": {12, 0}, "noon": {12, 0},
+	"полночь": {0, 0}, "midnight": {0, 0},
+}
+
+// Уточнения «3 часа дня», «9 утра», «3pm»
+type meridiem int
+
+const (
+	meridiemNone meridiem = iota
+	meridiemAM
+	meridiemPM
+	meridiemNight
+)
+
+var meridiemWords = map[string]meridiem{
+	"утра": meridiemAM, "am": meridiemAM, "a.m": meridiemAM,
+	"дня": meridiemPM, "вечера": meridiemPM, "pm": meridiemPM, "p.m": meridiemPM,
+	"ночи": meridiemNight,
+}
+
+func (m meridiem) apply(h int) int {
+	switch m {
+	case meridiemAM:
+		if h == 12 {
+			return 0
+		}
+	case meridiemPM:
+		if h < 12 {
+			return h + 12
+		}
+	case meridiemNight:
+		if h == 12 {
+			return 0
+		}
+		if h >= 6 && h < 12 {
+			return h + 12
+		}
+	}
+	return h
+}
+
+var hourWords = map[string]bool{"час": true, "часа": true, "часов": true, "ч": true, "o'clock": true}
+
+// Предлоги и служебные слова, которые входят в совпадение: «в пятницу», «на завтра», «by the 20th»
+var fillerWords = map[string]bool{
+	"в": true, "во": true, "на": true, "к": true, "до": true,
+	"by": true, "on": true, "at": true, "in": true, "the": true, "this": true, "of": true,
+}
+
+// Предлоги перед временем: «в 15:30», «к 9 утра», «at 3pm»
+var clockPrepositions = map[string]bool{
+	"в": true, "во": true, "к": true, "около": true, "at": true, "by": true, "around": true, "@": true,
+}
+
+var (
+	nextWords    = map[string]bool{"следующий": true, "следующую": true, "следующее": true, "следующая": true, "следующей": true, "следующем": true, "следующие": true, "следующих": true, "next": true}
+	thisWords    = map[string]bool{"этот": true, "эту": true, "это": true, "эта": true, "эти": true, "этих": true, "ближайший": true, "ближайшую": true, "ближайшие": true, "ближайших": true, "this": true, "coming": true}
+	rangeFrom    = map[string]bool{"с": true, "со": true, "from": true}
+	rangeTo      = map[string]bool{"до": true, "по": true, "to": true, "till": true, "until": true, "-": true, "–": true, "—": true}
+	weekendWords = map[string]bool{"выходные": true, "выходных": true, "weekend": true}
+)
+
+// number разбирает «3», «три», «a»
+func number(s string) (int, bool) {
+	if n, err := strconv.Atoi(s); err == nil && n >= 0 {
+		return n, true
+	}
+	n, ok := numberWords[s]
+	return n, ok
+}
+
+// numberUnit разбирает слитные «2ч», «30мин», «3d»
+func numberUnit(s string) (int, unit, bool) {
+	i := 0
+	for i < len(s) && s[i] >= '0' && s[i] <= '9' {
+		i++
+	}
+	if i == 0 || i == len(s) {
+		return 0, unitNone, false
+	}
+	u, ok := unitWords[s[i:]]
+	if !ok {
+		return 0, unitNone, false
+	}
+	n, _ := strconv.Atoi(s[:i])
+	return n, u, true
+}
+
+// ordinal разбирает «20», «20-го», «20го», «20-е», «20th», «1st»
+func ordinal(s string) (int, bool) {
+	for _, suffix := range []string{"-го", "го", "-е", "-ое", "st", "nd", "rd", "th"} {
+		if strings.HasSuffix(s, suffix) {
+			s = strings.TrimSuffix(s, suffix)
+			break
+		}
+	}
+	n, err := strconv.Atoi(s)
+	if err != nil || n < 1 || n > 31 {
+		return 0, false
+	}
+	return n, true
+}
@@ -0,0 +1,651 @@
+package dates
+
+import (
+	"strconv"
+	"strings"
+	"time"
+)
+
+// part — то, что распознал один сопоставитель, начиная с токена i
+type part struct {
+	n        int // сколько токенов занято
+	date     *time.Time
+	clock    *clock
+	moment   *time.Time // точный момент: «через 2 часа»
+	duration time.Duration
+}
+
+type parser struct {
+	toks  []token
+	now   time.Time
+	today time.Time
+	// dateEnd — индекс токена сразу после найденной даты, -1 пока её нет
+	dateEnd int
+}
+
+type matcher func(p *parser, i int) (part, bool)
+
+// Фразы со своим предлогом: «с 15 до 17», «на 2 часа», «через 3 дня»
+var leadMatchers = []matcher{
+	(*parser).matchRange,
+	(*parser).matchDuration,
+	(*parser).matchRelative,
+}
+
+// Остальное может стоять после предлогов из fillerWords. Порядок важен:
+// «20-го мая» должно достаться matchMonthDate, а не matchDayOfMonth.
+var matchers = []matcher{
+	(*parser).matchPeriod,
+	(*parser).matchWeekend,
+	(*parser).matchWeekday,
+	(*parser).matchNamedDay,
+	(*parser).matchNumericDate,
+	(*parser).matchMonthDate,
+	(*parser).matchDayOfMonth,
+	(*parser).matchClock,
+	(*parser).matchDayPart,
+}
+
+func (p *parser) match(i int) (part, bool) {
+	for _, m := range leadMatchers {
+		if r, ok := m(p, i); ok {
+			return r, true
+		}
+	}
+	// Не больше двух служебных слов подряд: «on the 20th», «в эту пятницу»
+	for j := i; j <= i+2 && j < len(p.toks); j++ {
+		if j > i && !fillerWords[p.toks[j-1].s] {
+			break
+		}
+		for _, m := range matchers {
+			if r, ok := m(p, j); ok {
+				r.n += j - i
+				return r, true
+			}
+		}
+	}
+	return part{}, false
+}
+
+// tok returns the i-th token or "" out of range
+func (p *parser) tok(i int) string {
+	if i < 0 || i >= len(p.toks) {
+		return ""
+	}
+	return p.toks[i].s
+}
+
+func (p *parser) days(n int) *time.Time {
+	d := p.today.AddDate(0, 0, n)
+	return &d
+}
+
+// mondayIndex — номер дня в неделе, начиная с понедельника (0..6)
+func mondayIndex(wd time.Weekday) int {
+	return (int(wd) + 6) % 7
+}
+
+// nextWeek — сколько дней до понедельника следующей недели
+func (p *parser) nextWeek() int {
+	return 7 - mondayIndex(p.today.Weekday())
+}
+
+// «сегодня», «завтра», «послезавтра», «day after tomorrow», «tonight»
+func (p *parser) matchNamedDay(i int) (part, bool) {
+	switch p.tok(i) {
+	case "сегодня", "today":
+		return part{n: 1, date: p.days(0)}, true
+	case "завтра", "tomorrow", "tmrw":
+		return part{n: 1, date: p.days(1)}, true
+	case "послезавтра":
+		return part{n: 1, date: p.days(2)}, true
+	case "tonight":
+		return part{n: 1, date: p.days(0), clock: &clock{20, 0}}, true
+	case "day":
+		if p.tok(i+1) == "after" && p.tok(i+2) == "tomorrow" {
+			return part{n: 3, date: p.days(2)}, true
+		}
+	}
+	return part{}, false
+}
+
+// «через 3 дня», «через неделю», «через полчаса», «in 2 hours», «in a week»
+func (p *parser) matchRelative(i int) (part, bool) {
+	lead := p.tok(i)
+	if lead != "через" && lead != "in" {
+		return part{}, false
+	}
+
+	switch p.tok(i + 1) {
+	case "полчаса":
+		return p.relative(2, 30, unitMinute)
+	case "полтора":
+		if hourWords[p.tok(i+2)] {
+			return p.relative(3, 90, unitMinute)
+		}
+	}
+	if n, u, ok := numberUnit(p.tok(i + 1)); ok {
+		return p.relative(2, n, u)
+	}
+	if n, ok := number(p.tok(i + 1)); ok {
+		if u, ok := unitWords[p.tok(i+2)]; ok {
+			return p.relative(3, n, u)
+		}
+	}
+	// «через неделю», «через час» — без числа, только по-русски
+	if u, ok := unitWords[p.tok(i+1)]; ok && lead == "через" {
+		return p.relative(2, 1, u)
+	}
+	return part{}, false
+}
+
+// relative сдвигает сегодняшний день (минуты и часы — текущий момент) на n единиц.
+// Слишком далёкий сдвиг датой не считается.
+func (p *parser) relative(tokens, n int, u unit) (part, bool) {
+	if !u.fits(n) {
+		return part{}, false
+	}
+	switch u {
+	case unitMinute, unitHour:
+		m := p.now.Add(u.duration(n)).Truncate(time.Minute)
+		return part{n: tokens, moment: &m}, true
+	case unitMonth:
+		d := addMonths(p.today, n)
+		return part{n: tokens, date: &d}, true
+	case unitYear:
+		d := addMonths(p.today, 12*n)
+		return part{n: tokens, date: &d}, true
+	case unitWeek:
+		return part{n: tokens, date: p.days(7 * n)}, true
+	default:
+		return part{n: tokens, date: p.days(n)}, true
+	}
+}
+
+// addMonths — AddDate(0, n, 0) без переполнения дня: 31 января + месяц — последний
+// день февраля, а не 3 марта
+func addMonths(d time.Time, n int) time.Time {
+	first := time.Date(d.Year(), d.Month()+time.Month(n), 1, 0, 0, 0, 0, d.Location())
+	last := first.AddDate(0, 1, -1).Day()
+	return time.Date(first.Year(), first.Month(), min(d.Day(), last), 0, 0, 0, 0, d.Location())
+}
+
+// «на 2 часа», «на полчаса», «на час», «for 30 min», «for an hour»
+func (p *parser) matchDuration(i int) (part, bool) {
+	lead := p.tok(i)
+	if lead != "на" && lead != "for" {
+		return part{}, false
+	}
+
+	switch p.tok(i + 1) {
+	case "полчаса":
+		return part{n: 2, duration: 30 * time.Minute}, true
+	case "полтора":
+		if hourWords[p.tok(i+2)] {
+			return part{n: 3, duration: 90 * time.Minute}, true
+		}
+	}
+	if n, u, ok := numberUnit(p.tok(i + 1)); ok && u.duration(n) > 0 {
+		return part{n: 2, duration: u.duration(n)}, true
+	}
+	if n, ok := number(p.tok(i + 1)); ok {
+		if u, ok := unitWords[p.tok(i+2)]; ok && u.duration(n) > 0 {
+			return part{n: 3, duration: u.duration(n)}, true
+		}
+	}
+	// «на час», «на минуту»; «на день рождения» — не длительность
+	if lead == "на" {
+		if u := unitWords[p.tok(i+1)]; u == unitMinute || u == unitHour {
+			return part{n: 2, duration: u.duration(1)}, true
+		}
+	}
+	return part{}, false
+}
+
+// «с 15:00 до 17:00», «с 9 до 5», «from 3 to 5pm», «15:00-17:00», «15:00 – 17:00»
+func (p *parser) matchRange(i int) (part, bool) {
+	prefixed := rangeFrom[p.tok(i)]
+
+	if !prefixed {
+		if from, to, ok := splitRange(p.tok(i)); ok {
+			return rangePart(1, from, to), true
+		}
+	}
+
+	j := i
+	if prefixed {
+		j++
+	}
+	from, fromMer, n, f, ok := p.clockAt(j)
+	if !ok || (!prefixed && !f.colon && fromMer == meridiemNone) {
+		return part{}, false
+	}
+	j += n
+	if !rangeTo[p.tok(j)] {
+		return part{}, false
+	}
+	j++
+	to, toMer, n, f, ok := p.clockAt(j)
+	if !ok || (!prefixed && !f.colon && toMer == meridiemNone) {
+		return part{}, false
+	}
+	j += n
+
+	// «from 3 to 5pm»: pm относится к обеим границам
+	if fromMer == meridiemNone && toMer == meridiemPM && from.hour < 12 && from.hour+12 <= to.hour {
+		from.hour += 12
+	}
+	return rangePart(j-i, from, to), true
+}
+
+func rangePart(n int, from, to clock) part {
+	d := time.Duration(to.hour-from.hour)*time.Hour + time.Duration(to.min-from.min)*time.Minute
+	if d <= 0 {
+		d += 24 * time.Hour
+	}
+	return part{n: n, clock: &from, duration: d}
+}
+
+// splitRange разбирает слитное «15:00-17:00»
+func splitRange(s string) (clock, clock, bool) {
+	for _, sep := range []string{"-", "–", "—"} {
+		a, b, ok := strings.Cut(s, sep)
+		if !ok {
+			continue
+		}
+		fh, fm, fmer, fcolon, ok1 := parseClockToken(a)
+		th, tm, tmer, tcolon, ok2 := parseClockToken(b)
+		if !ok1 || !ok2 || !fcolon || !tcolon {
+			return clock{}, clock{}, false
+		}
+		return clock{fmer.apply(fh), fm}, clock{tmer.apply(th), tm}, true
+	}
+	return clock{}, clock{}, false
+}
+
+// «в 15:30», «в 3 часа дня», «9 утра», «к 18:00», «at 3pm», «at 7»
+func (p *parser) matchClock(i int) (part, bool) {
+	prev := p.tok(i - 1)
+	prefixed := clockPrepositions[prev]
+
+	// «в 10.30» — с предлогом точка означает время, а не дату
+	if prefixed {
+		if c, ok := dottedClock(p.tok(i)); ok {
+			return part{n: 1, clock: &c}, true
+		}
+	}
+
+	c, mer, n, f, ok := p.clockAt(i)
+	if !ok {
+		return part{}, false
+	}
+	// «8 часов» и «3 дня» без предлога — скорее количество, чем время
+	if f.dayWord && !prefixed && !f.hourWord {
+		return part{}, false
+	}
+	// «в понедельник в 9», «завтра к 10»: сразу после даты голое число с предлогом — час
+	afterDate := prefixed && i-1 == p.dateEnd
+	if f.colon || mer != meridiemNone || (f.hourWord && prefixed) || prev == "at" || prev == "@" || afterDate {
+		return part{n: n, clock: &c}, true
+	}
+	return part{}, false
+}
+
+type clockForm struct {
+	colon    bool // «15:30»
+	hourWord bool // «3 часа»
+	dayWord  bool // «3 дня»: и «15:00», и «три дня»
+}
+
+// clockAt разбирает время с позиции j: «15:30», «3pm», «3 часа дня», «9 утра», «15»
+func (p *parser) clockAt(j int) (clock, meridiem, int, clockForm, bool) {
+	h, m, mer, colon, ok := parseClockToken(p.tok(j))
+	if !ok {
+		return clock{}, meridiemNone, 0, clockForm{}, false
+	}
+	f := clockForm{colon: colon}
+	n := 1
+	if hourWords[p.tok(j+n)] {
+		f.hourWord = true
+		n++
+	}
+	if mer == meridiemNone {
+		if mm, ok := meridiemWords[p.tok(j+n)]; ok {
+			f.dayWord = p.tok(j+n) == "дня"
+			mer = mm
+			n++
+		}
+	}
+
+	h = mer.apply(h)
+	// «в 3 часа», «at 5» без уточнения — это день, а не ночь
+	if !colon && mer == meridiemNone && h >= 1 && h <= 6 {
+		h += 12
+	}
+	return clock{h, m}, mer, n, f, true
+}
+
+// parseClockToken разбирает «15:30», «9:05», «3pm», «3:30pm», «15»
+func parseClockToken(s string) (hour, min int, mer meridiem, colon, ok bool) {
+	for _, suffix := range []string{"a.m", "p.m", "am", "pm"} {
+		if strings.HasSuffix(s, suffix) && len(s) > len(suffix) {
+			mer = meridiemWords[suffix]
+			s = strings.TrimSuffix(s, suffix)
+			break
+		}
+	}
+
+	hs, ms, colon := strings.Cut(s, ":")
+	hour, err := strconv.Atoi(hs)
+	if err != nil || len(hs) > 2 || hour < 0 || hour > 23 {
+		return 0, 0, meridiemNone, false, false
+	}
+	if colon {
+		if len(ms) != 2 {
+			return 0, 0, meridiemNone, false, false
+		}
+		min, err = strconv.Atoi(ms)
+		if err != nil || min < 0 || min > 59 {
+			return 0, 0, meridiemNone, false, false
+		}
+	}
+	if mer != meridiemNone && (hour < 1 || hour > 12) {
+		return 0, 0, meridiemNone, false, false
+	}
+	return hour, min, mer, colon, true
+}
+
+// dottedClock разбирает «10.30»
+func dottedClock(s string) (clock, bool) {
+	hs, ms, ok := strings.Cut(s, ".")
+	if !ok || len(hs) > 2 || len(ms) != 2 {
+		return clock{}, false
+	}
+	h, err1 := strconv.Atoi(hs)
+	m, err2 := strconv.Atoi(ms)
+	if err1 != nil || err2 != nil || h < 0 || h > 23 || m < 0 || m > 59 {
+		return clock{}, false
+	}
+	return clock{h, m}, true
+}
+
+// «утром», «вечером», «в полдень», «tomorrow morning», «in the evening»
+func (p *parser) matchDayPart(i int) (part, bool) {
+	c, ok := dayPartWords[p.tok(i)]
+	if !ok {
+		return part{}, false
+	}
+	// Английские «morning», «night» часто просто часть названия: «Morning run»
+	if isASCII(p.tok(i)) {
+		prev := p.tok(i - 1)
+		_, weekday := weekdayWords[prev]
+		switch {
+		case weekday, prev == "the", prev == "this", prev == "at", prev == "by",
+			prev == "today", prev == "tomorrow":
+		default:
+			return part{}, false
+		}
+	}
+	return part{n: 1, clock: &c}, true
+}
+
+func isASCII(s string) bool {
+	for i := 0; i < len(s); i++ {
+		if s[i] >= 0x80 {
+			return false
+		}
+	}
+	return true
+}
+
+// «в пятницу», «в эту пятницу», «в следующий вторник», «до среды», «next friday».
+// Просто день недели — ближайший после сегодняшнего, «этот» — включая сегодня,
+// «следующий» — на следующей неделе.
+func (p *parser) matchWeekday(i int) (part, bool) {
+	j := i
+	next, this := nextWords[p.tok(j)], thisWords[p.tok(j)]
+	if next || this {
+		j++
+	}
+	wd, ok := weekdayWords[p.tok(j)]
+	if !ok {
+		return part{}, false
+	}
+
+	diff := (int(wd) - int(p.today.Weekday()) + 7) % 7
+	switch {
+	case next:
+		diff = p.nextWeek() + mondayIndex(wd)
+	case this:
+	case diff == 0:
+		diff = 7
+	}
+	return part{n: j - i + 1, date: p.days(diff)}, true
+}
+
+// «на выходных», «в эти выходные», «на следующих выходных», «this weekend».
+// Выходные — суббота; если они уже идут — сегодня.
+func (p *parser) matchWeekend(i int) (part, bool) {
+	j := i
+	next := nextWords[p.tok(j)]
+	if next || thisWords[p.tok(j)] {
+		j++
+	}
+	if !weekendWords[p.tok(j)] {
+		return part{}, false
+	}
+
+	idx := mondayIndex(p.today.Weekday())
+	diff := 5 - idx
+	switch {
+	case next:
+		diff = p.nextWeek() + 5
+	case idx >= 5:
+		diff = 0
+	}
+	return part{n: j - i + 1, date: p.days(diff)}, true
+}
+
+// «в конце месяца», «к концу недели», «end of the year»,
+// «на следующей неделе», «в следующем месяце», «next year»
+func (p *parser) matchPeriod(i int) (part, bool) {
+	switch p.tok(i) {
+	case "конец", "конце", "концу", "end":
+		j := i + 1
+		for j < i+3 && (p.tok(j) == "of" || p.tok(j) == "the" || p.tok(j) == "this") {
+			j++
+		}
+		var d time.Time
+		switch p.tok(j) {
+		case "месяца", "month":
+			d = time.Date(p.today.Year(), p.today.Month()+1, 0, 0, 0, 0, 0, p.today.Location())
+		case "недели", "week":
+			// Рабочая неделя кончается в пятницу; в выходные — воскресенье
+			idx := mondayIndex(p.today.Weekday())
+			if idx <= 4 {
+				d = p.today.AddDate(0, 0, 4-idx)
+			} else {
+				d = p.today.AddDate(0, 0, 6-idx)
+			}
+		case "года", "year":
+			d = time.Date(p.today.Year(), time.December, 31, 0, 0, 0, 0, p.today.Location())
+		default:
+			return part{}, false
+		}
+		return part{n: j - i + 1, date: &d}, true
+	}
+
+	if !nextWords[p.tok(i)] {
+		return part{}, false
+	}
+	var d time.Time
+	switch p.tok(i + 1) {
+	case "неделе", "неделю", "неделя", "week":
+		d = *p.days(p.nextWeek())
+	case "месяце", "месяц", "month":
+		d = time.Date(p.today.Year(), p.today.Month()+1, 1, 0, 0, 0, 0, p.today.Location())
+	case "году", "год", "year":
+		d = time.Date(p.today.Year()+1, time.January, 1, 0, 0, 0, 0, p.today.Location())
+	default:
+		return part{}, false
+	}
+	return part{n: 2, date: &d}, true
+}
+
+// «04.02», «04.02.2027», «4/2/27», «2027-02-04»
+func (p *parser) matchNumericDate(i int) (part, bool) {
+	s := p.tok(i)
+	if len(s) == 10 && s[4] == '-' && s[7] == '-' {
+		d, err := time.ParseInLocation("2006-01-02", s, p.today.Location())
+		if err != nil {
+			return part{}, false
+		}
+		return part{n: 1, date: &d}, true
+	}
+
+	sep := "."
+	if strings.Contains(s, "/") {
+		sep = "/"
+	}
+	fields := strings.Split(s, sep)
+	if len(fields) < 2 || len(fields) > 3 {
+		return part{}, false
+	}
+	var nums [3]int
+	for k, f := range fields {
+		n, err := strconv.Atoi(f)
+		if err != nil || len(f) == 0 || len(f) > 4 {
+			return part{}, false
+		}
+		nums[k] = n
+	}
+	day, month := nums[0], time.Month(nums[1])
+	if len(fields[0]) > 2 || len(fields[1]) > 2 || month < 1 || month > 12 {
+		return part{}, false
+	}
+
+	if len(fields) == 2 {
+		// «1.5 литра» — число, а не дата
+		if len(fields[1]) != 2 {
+			return part{}, false
+		}
+		// «в 10.30» — время, его разберёт matchClock
+		if _, ok := dottedClock(s); ok && sep == "." && clockPrepositions[p.tok(i-1)] {
+			return part{}, false
+		}
+		return p.dayMonth(1, day, month)
+	}
+
+	year := nums[2]
+	switch len(fields[2]) {
+	case 2:
+		year += 2000
+	case 4:
+	default:
+		return part{}, false
+	}
+	d, ok := validDate(year, month, day, p.today.Location())
+	if !ok {
+		return part{}, false
+	}
+	return part{n: 1, date: &d}, true
+}
+
+// «20 января», «20-го мая 2027 года», «1 янв», «January 20th, 2027», «20th of May»
+func (p *parser) matchMonthDate(i int) (part, bool) {
+	var (
+		day   int
+		month time.Month
+		j     int
+		ok    bool
+	)
+	if day, ok = ordinal(p.tok(i)); ok {
+		j = i + 1
+		if p.tok(j) == "of" {
+			j++
+		}
+		if month, ok = monthWords[p.tok(j)]; !ok {
+			return part{}, false
+		}
+		j++
+	} else if month, ok = monthWords[p.tok(i)]; ok {
+		if day, ok = ordinal(p.tok(i + 1)); !ok {
+			return part{}, false
+		}
+		j = i + 2
+	} else {
+		return part{}, false
+	}
+
+	year, n := p.yearAt(j)
+	if n == 0 {
+		return p.dayMonth(j-i, day, month)
+	}
+	d, ok := validDate(year, month, day, p.today.Location())
+	if !ok {
+		return part{}, false
+	}
+	return part{n: j - i + n, date: &d}, true
+}
+
+// yearAt разбирает «2027», «2027 года», «2027 г.»
+func (p *parser) yearAt(j int) (year, n int) {
+	s := p.tok(j)
+	if len(s) != 4 {
+		return 0, 0
+	}
+	year, err := strconv.Atoi(s)
+	if err != nil || year < 1900 || year > 2999 {
+		return 0, 0
+	}
+	switch p.tok(j + 1) {
+	case "г", "года", "год":
+		return year, 2
+	}
+	return year, 1
+}
+
+// dayMonth — дата без года: в этом году, а если уже прошла — в следующем
+func (p *parser) dayMonth(n, day int, month time.Month) (part, bool) {
+	for year := p.today.Year(); year <= p.today.Year()+8; year++ {
+		d, ok := validDate(year, month, day, p.today.Location())
+		if ok && !d.Before(p.today) {
+			return part{n: n, date: &d}, true
+		}
+	}
+	return part{}, false
+}
+
+// «20-го», «20-го числа», «20 числа», «the 20th» — ближайшее такое число месяца
+func (p *parser) matchDayOfMonth(i int) (part, bool) {
+	s := p.tok(i)
+	day, ok := ordinal(s)
+	if !ok {
+		return part{}, false
+	}
+	n := 1
+	if p.tok(i+1) == "числа" {
+		n++
+	} else if _, err := strconv.Atoi(s); err == nil {
+		// Голое число — не дата
+		return part{}, false
+	}
+
+	for k := 0; k < 12; k++ {
+		first := time.Date(p.today.Year(), p.today.Month()+time.Month(k), 1, 0, 0, 0, 0, p.today.Location())
+		d, ok := validDate(first.Year(), first.Month(), day, p.today.Location())
+		if ok && !d.Before(p.today) {
+			return part{n: n, date: &d}, true
+		}
+	}
+	return part{}, false
+}
+
+// validDate отсекает 31 апреля и 29 февраля в невисокосный год
+func validDate(year int, month time.Month, day int, loc *time.Location) (time.Time, bool) {
+	d := time.Date(year, month, day, 0, 0, 0, 0, loc)
+	if d.Day() != day || d.Month() != month {
+		return time.Time{}, false
+	}
+	return d, true
+}
@@ -0,0 +1,126 @@
+package scheduler
+
+import (
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/tazhate/familybot/internal/domain"
+)
+
+// Задачи планировщика с отметкой «обработано до» (таблица job_watermarks)
+const (
+	jobEventReminders = "event_reminders"
+	jobRepeatingTasks = "repeating_tasks"
+	jobTaskReminders  = "task_reminders"
+)
+
+// maxReplayWindow — насколько далеко назад досылаются пропущенные минуты
+const maxReplayWindow = 24 * time.Hour
+
+// maxDigestLines — сколько пропущенных напоминаний перечислять в дайджесте
+const maxDigestLines = 30
+
+// missedDigest collects occurrences that are later than the configured max-lateness;
+// they are sent as one "пропущено" message per user instead of one by one
+type missedDigest struct {
+	name        string // кто собрал дайджест: у разных задач в одну минуту разные ключи
+	now         time.Time
+	maxLateness time.Duration
+	users       []*domain.User
+	lines       map[int64][]string
+}
+
+func newMissedDigest(name string, now time.Time, maxLateness time.Duration) *missedDigest {
+	return &missedDigest{
+		name:        name,
+		now:         now,
+		maxLateness: maxLateness,
+		lines:       make(map[int64][]string),
+	}
+}
+
+// tooLate reports whether an occurrence at the moment at should go to the digest
+func (d *missedDigest) tooLate(at time.Time) bool {
+	return d.now.Sub(at) > d.maxLateness
+}
+
+func (d *missedDigest) add(user *domain.User, line string) {
+	if _, ok := d.lines[user.ID]; !ok {
+		d.users = append(d.users, user)
+	}
+	d.lines[user.ID] = append(d.lines[user.ID], line)
+}
+
+// runCatchUp runs the jobs with a shared digest and sends it
+func (s *Scheduler) runCatchUp(name string, jobs ...func(now time.Time, missed *missedDigest)) {
+	if s.sender == nil || s.storage == nil {
+		return
+	}
+
+	now := s.clock.Now()
+	missed := newMissedDigest(name, now, s.cfg.MaxLateness)
+	for _, job := range jobs {
+		job(now, missed)
+	}
+	s.sendMissedDigest(missed)
+}
+
+// replayMissed catches up all minute-based jobs at startup, so a restart during a deploy
+// doesn't drop reminders; everything later than max-lateness ends up in a single digest
+func (s *Scheduler) replayMissed() {
+	s.runCatchUp("replay", s.catchUpEventReminders, s.catchUpRepeatingTasks, s.catchUpTaskReminders)
+}
+
+// catchUp calls run for every minute after the job's watermark up to now and moves the watermark.
+// A job without a watermark (first start) only processes the current minute.
+func (s *Scheduler) catchUp(job string, now time.Time, run func(minute time.Time)) {
+	current := now.Truncate(time.Minute)
+	from := current
+
+	last, err := s.storage.GetJobWatermark(job)
+	if err != nil {
+		log.Printf("Error getting %s watermark: %v", job, err)
+	}
+	if last != nil {
+		from = last.Truncate(time.Minute).Add(time.Minute)
+		if current.Sub(from) > maxReplayWindow {
+			log.Printf("Scheduler: %s is %s behind, replaying only the last %s", job, current.Sub(from).Round(time.Minute), maxReplayWindow)
+			from = current.Add(-maxReplayWindow)
+		}
+		if current.Sub(from) > time.Minute {
+			log.Printf("Scheduler: %s catching up from %s", job, from.Format(time.RFC3339))
+		}
+	}
+
+	for minute := from; !minute.After(current); minute = minute.Add(time.Minute) {
+		run(minute)
+	}
+
+	if err := s.storage.SetJobWatermark(job, current); err != nil {
+		log.Printf("Error saving %s watermark: %v", job, err)
+	}
+}
+
+// sendMissedDigest sends each user one message with the occurrences that were too late
+func (s *Scheduler) sendMissedDigest(d *missedDigest) {
+	for _, user := range d.users {
+		lines := d.lines[user.ID]
+
+		var sb strings.Builder
+		sb.WriteString("⏭ <b>Пропущено</b>, пока бот был недоступен:\n\n")
+		for i, line := range lines {
+			if i == maxDigestLines {
+				sb.WriteString(fmt.Sprintf("… и ещё %d\n", len(lines)-maxDigestLines))
+				break
+			}
+			sb.WriteString("• " + line + "\n")
+		}
+
+		key := fmt.Sprintf("missed:%s:%d", d.name, d.now.Unix())
+		if err := s.notify(user, key, sb.String(), 0, false); err != nil {
+			log.Printf("Error sending missed digest to %d: %v", user.TelegramID, err)
+		}
+	}
+}
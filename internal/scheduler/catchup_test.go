@@ -0,0 +1,355 @@
+package scheduler
+
+import (
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/tazhate/familybot/config"
+	"github.com/tazhate/familybot/internal/clock"
+	"github.com/tazhate/familybot/internal/domain"
+	"github.com/tazhate/familybot/internal/service"
+	"github.com/tazhate/familybot/internal/storage"
+	"github.com/tazhate/familybot/internal/storage/storagetest"
+)
+
+// nopSender accepts every message: catch-up tests look at the outbox, not at deliveries
+type nopSender struct{}
+
+func (nopSender) SendMessage(chatID int64, text string) error                         { return nil }
+func (nopSender) SendMessageWithSnooze(chatID int64, text string, taskID int64) error { return nil }
+func (nopSender) SendCalendarConflict(chatID int64, text string, conflictID int64) error {
+	return nil
+}
+
+type catchUpFixture struct {
+	t     *testing.T
+	store *storage.Storage
+	clock *clock.Fake
+	sched *Scheduler
+	tasks *service.TaskService
+	user  *domain.User
+}
+
+func newCatchUpFixture(t *testing.T, now time.Time) *catchUpFixture {
+	t.Helper()
+	store := storagetest.SQLite(t)
+	clk := clock.NewFake(now)
+	cfg := &config.Config{
+		OwnerTelegramID: 100,
+		Timezone:        now.Location(),
+		MorningTime:     "09:00",
+		EveningTime:     "21:00",
+		MaxLateness:     30 * time.Minute,
+	}
+
+	user := &domain.User{TelegramID: 100, Name: "Алекс", Role: domain.RoleOwner}
+	if err := store.CreateUser(user); err != nil {
+		t.Fatal(err)
+	}
+
+	tasks := service.NewTaskService(store)
+	tasks.SetClock(clk)
+	settings := service.NewSettingsService(store, cfg.Timezone, cfg.MorningTime, cfg.EveningTime)
+	settings.SetClock(clk)
+	schedule := service.NewScheduleService(store)
+	schedule.SetClock(clk)
+
+	s := New(cfg, store, tasks, nil, nil, schedule, nil, nil, nil, settings, nil, nil)
+	s.SetSender(nopSender{})
+	s.SetClock(clk)
+	return &catchUpFixture{t: t, store: store, clock: clk, sched: s, tasks: tasks, user: user}
+}
+
+func (f *catchUpFixture) setWatermark(job string, at time.Time) {
+	f.t.Helper()
+	if err := f.store.SetJobWatermark(job, at); err != nil {
+		f.t.Fatal(err)
+	}
+}
+
+func (f *catchUpFixture) watermark(job string) time.Time {
+	f.t.Helper()
+	at, err := f.store.GetJobWatermark(job)
+	if err != nil || at == nil {
+		f.t.Fatalf("watermark %s: %v, %v", job, at, err)
+	}
+	return *at
+}
+
+// minutes runs catchUp for the job and returns the minutes it replayed
+func (f *catchUpFixture) minutes(job string) []time.Time {
+	var minutes []time.Time
+	f.sched.catchUp(job, f.clock.Now(), func(minute time.Time) {
+		minutes = append(minutes, minute)
+	})
+	return minutes
+}
+
+// outbox returns the queued notifications by key without the recipient suffix
+func (f *catchUpFixture) outbox() map[string]string {
+	f.t.Helper()
+	notifications, err := f.store.ListNotifications(domain.NotificationPending, 1000)
+	if err != nil {
+		f.t.Fatal(err)
+	}
+	texts := make(map[string]string)
+	for _, n := range notifications {
+		key := strings.TrimSuffix(n.Key, ":100")
+		texts[key] = n.Text
+	}
+	return texts
+}
+
+// digest returns the texts of the «пропущено» digests in the outbox
+func (f *catchUpFixture) digests() []string {
+	var digests []string
+	for key, text := range f.outbox() {
+		if strings.HasPrefix(key, "missed:") {
+			digests = append(digests, text)
+		}
+	}
+	return digests
+}
+
+var testLocation = time.FixedZone("MSK", 3*60*60)
+
+func TestCatchUpReplaysFromWatermark(t *testing.T) {
+	now := time.Date(2030, time.June, 4, 10, 5, 30, 0, testLocation)
+	f := newCatchUpFixture(t, now)
+	f.setWatermark(jobEventReminders, time.Date(2030, time.June, 4, 10, 0, 0, 0, testLocation))
+
+	minutes := f.minutes(jobEventReminders)
+
+	var got []string
+	for _, m := range minutes {
+		got = append(got, m.In(testLocation).Format("15:04:05"))
+	}
+	want := "10:01:00 10:02:00 10:03:00 10:04:00 10:05:00"
+	if strings.Join(got, " ") != want {
+		t.Errorf("replayed minutes %v, want %s", got, want)
+	}
+	if wm := f.watermark(jobEventReminders); !wm.Equal(now.Truncate(time.Minute)) {
+		t.Errorf("watermark %s, want %s", wm, now.Truncate(time.Minute))
+	}
+
+	// Следующий запуск в ту же минуту ничего не повторяет
+	if again := f.minutes(jobEventReminders); len(again) != 0 {
+		t.Errorf("second run in the same minute replayed %v", again)
+	}
+}
+
+func TestCatchUpClampsToReplayWindow(t *testing.T) {
+	now := time.Date(2030, time.June, 4, 10, 0, 0, 0, testLocation)
+	f := newCatchUpFixture(t, now)
+	f.setWatermark(jobRepeatingTasks, now.AddDate(0, 0, -3))
+
+	minutes := f.minutes(jobRepeatingTasks)
+
+	if len(minutes) != int(maxReplayWindow/time.Minute)+1 {
+		t.Fatalf("replayed %d minutes, want %d", len(minutes), int(maxReplayWindow/time.Minute)+1)
+	}
+	if first := minutes[0]; !first.Equal(now.Add(-maxReplayWindow)) {
+		t.Errorf("first replayed minute %s, want %s", first, now.Add(-maxReplayWindow))
+	}
+	if last := minutes[len(minutes)-1]; !last.Equal(now) {
+		t.Errorf("last replayed minute %s, want %s", last, now)
+	}
+}
+
+func TestCatchUpFirstStart(t *testing.T) {
+	now := time.Date(2030, time.June, 4, 10, 7, 45, 0, testLocation)
+	f := newCatchUpFixture(t, now)
+
+	minutes := f.minutes(jobTaskReminders)
+
+	if len(minutes) != 1 || !minutes[0].Equal(now.Truncate(time.Minute)) {
+		t.Errorf("first start replayed %v, want only the current minute", minutes)
+	}
+	if wm := f.watermark(jobTaskReminders); !wm.Equal(now.Truncate(time.Minute)) {
+		t.Errorf("watermark %s, want %s", wm, now.Truncate(time.Minute))
+	}
+}
+
+func TestMissedDigestTooLate(t *testing.T) {
+	now := time.Date(2030, time.June, 4, 10, 0, 0, 0, testLocation)
+	d := newMissedDigest("test", now, 30*time.Minute)
+
+	tests := []struct {
+		at   time.Time
+		want bool
+	}{
+		{now, false},
+		{now.Add(-29 * time.Minute), false},
+		{now.Add(-30 * time.Minute), false}, // ровно max-lateness ещё отправляется как обычно
+		{now.Add(-31 * time.Minute), true},
+		{now.Add(-5 * time.Hour), true},
+		{now.Add(time.Minute), false},
+	}
+	for _, tt := range tests {
+		if got := d.tooLate(tt.at); got != tt.want {
+			t.Errorf("tooLate(%s) = %v, want %v", tt.at.Format("15:04"), got, tt.want)
+		}
+	}
+}
+
+// TestReplayMissedSplitsByLateness restarts the bot after two hours of downtime: occurrences
+// up to max-lateness late are sent as usual, older ones go to a single digest
+func TestReplayMissedSplitsByLateness(t *testing.T) {
+	now := time.Date(2030, time.June, 4, 10, 0, 0, 0, testLocation) // вторник
+	f := newCatchUpFixture(t, now)
+	down := now.Add(-2 * time.Hour)
+	for _, job := range []string{jobEventReminders, jobRepeatingTasks, jobTaskReminders} {
+		f.setWatermark(job, down)
+	}
+
+	// Напоминания о событиях в 09:00 (час назад) и в 09:40 (20 минут назад)
+	schedule := service.NewScheduleService(f.store)
+	if _, err := schedule.Create(f.user.ID, domain.WeekdayTuesday, "09:10", "", "Созвон", 10); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := schedule.Create(f.user.ID, domain.WeekdayTuesday, "09:50", "", "Зарядка", 10); err != nil {
+		t.Fatal(err)
+	}
+	// Повторяющиеся задачи в 08:30 и в 09:45
+	if _, err := f.tasks.CreateRepeating(f.user.ID, 100, "Полить цветы", domain.PriorityWeek, nil, nil, domain.RepeatDaily, "08:30"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := f.tasks.CreateRepeating(f.user.ID, 100, "Покормить кота", domain.PriorityWeek, nil, nil, domain.RepeatDaily, "09:45"); err != nil {
+		t.Fatal(err)
+	}
+
+	f.sched.replayMissed()
+
+	outbox := f.outbox()
+	digests := f.digests()
+	if len(digests) != 1 {
+		t.Fatalf("want one digest, got %d: %v", len(digests), outbox)
+	}
+	for _, line := range []string{"Созвон (09:10)", "08:30 #1 Полить цветы"} {
+		if !strings.Contains(digests[0], line) {
+			t.Errorf("digest lacks %q:\n%s", line, digests[0])
+		}
+	}
+	for _, late := range []string{"Зарядка", "Покормить кота"} {
+		if strings.Contains(digests[0], late) {
+			t.Errorf("digest contains %q, which is within max-lateness", late)
+		}
+	}
+
+	if _, ok := outbox["event:2:2030-06-04"]; !ok {
+		t.Errorf("event reminder within max-lateness not sent: %v", keys(outbox))
+	}
+	if _, ok := outbox["repeat:2:2030-06-04T09:45"]; !ok {
+		t.Errorf("repeating task within max-lateness not sent: %v", keys(outbox))
+	}
+	if len(outbox) != 3 {
+		t.Errorf("want digest and two reminders, got %v", keys(outbox))
+	}
+	for _, job := range []string{jobEventReminders, jobRepeatingTasks, jobTaskReminders} {
+		if wm := f.watermark(job); !wm.Equal(now) {
+			t.Errorf("%s watermark %s, want %s", job, wm, now)
+		}
+	}
+}
+
+// TestMinuteJobsKeepSeparateDigests runs the jobs one by one in the same minute, as cron does
+// after the process was suspended: each job's digest must reach the outbox
+func TestMinuteJobsKeepSeparateDigests(t *testing.T) {
+	now := time.Date(2030, time.June, 4, 10, 0, 0, 0, testLocation)
+	f := newCatchUpFixture(t, now)
+	for _, job := range []string{jobEventReminders, jobRepeatingTasks} {
+		f.setWatermark(job, now.Add(-2*time.Hour))
+	}
+	schedule := service.NewScheduleService(f.store)
+	if _, err := schedule.Create(f.user.ID, domain.WeekdayTuesday, "09:10", "", "Созвон", 10); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := f.tasks.CreateRepeating(f.user.ID, 100, "Полить цветы", domain.PriorityWeek, nil, nil, domain.RepeatDaily, "08:30"); err != nil {
+		t.Fatal(err)
+	}
+
+	f.sched.checkEventReminders()
+	f.sched.checkRepeatingTasks()
+
+	digests := strings.Join(f.digests(), "\n")
+	for _, line := range []string{"Созвон", "Полить цветы"} {
+		if !strings.Contains(digests, line) {
+			t.Errorf("digests lack %q:\n%s", line, digests)
+		}
+	}
+}
+
+// TestTaskRemindersCreatedLate checks that a reminder whose time had already passed when
+// it was created is sent as usual, while one missed during downtime goes to the digest
+func TestTaskRemindersCreatedLate(t *testing.T) {
+	now := time.Date(2030, time.June, 4, 10, 30, 0, 0, testLocation)
+	f := newCatchUpFixture(t, now)
+	// Бот работал до 09:30 и был выключен час
+	f.setWatermark(jobTaskReminders, time.Date(2030, time.June, 4, 9, 30, 0, 0, testLocation))
+
+	remind := func(title string, due time.Time, before int) *domain.TaskReminder {
+		task, err := f.tasks.CreateFull(f.user.ID, 100, title, domain.PriorityWeek, nil, &due)
+		if err != nil {
+			t.Fatal(err)
+		}
+		r := &domain.TaskReminder{TaskID: task.ID, RemindBefore: before}
+		if err := f.store.CreateTaskReminder(r); err != nil {
+			t.Fatal(err)
+		}
+		return r
+	}
+	// 08:00 — до отметки: напоминание добавили, когда его время уже прошло
+	createdLate := remind("Записаться к врачу", time.Date(2030, time.June, 4, 9, 0, 0, 0, testLocation), 60)
+	// 09:45 — пока бот был выключен, опоздание 45 минут
+	missed := remind("Отправить отчёт", time.Date(2030, time.June, 4, 10, 45, 0, 0, testLocation), 60)
+	// 10:15 — опоздание 15 минут, в пределах max-lateness
+	late := remind("Купить хлеб", time.Date(2030, time.June, 4, 11, 15, 0, 0, testLocation), 60)
+	// 11:30 — ещё рано
+	future := remind("Забрать посылку", time.Date(2030, time.June, 4, 12, 30, 0, 0, testLocation), 60)
+
+	f.sched.replayMissed()
+
+	outbox := f.outbox()
+	for _, r := range []*domain.TaskReminder{createdLate, late} {
+		if _, ok := outbox["task_reminder:"+itoa(r.ID)]; !ok {
+			t.Errorf("task reminder %d not sent as usual: %v", r.ID, keys(outbox))
+		}
+	}
+	if _, ok := outbox["task_reminder:"+itoa(missed.ID)]; ok {
+		t.Errorf("task reminder %d missed during downtime sent as usual", missed.ID)
+	}
+	if _, ok := outbox["task_reminder:"+itoa(future.ID)]; ok {
+		t.Errorf("future task reminder %d sent", future.ID)
+	}
+
+	digests := f.digests()
+	if len(digests) != 1 {
+		t.Fatalf("want one digest, got %v", keys(outbox))
+	}
+	if !strings.Contains(digests[0], "Отправить отчёт") || strings.Contains(digests[0], "Записаться к врачу") {
+		t.Errorf("digest must list only the reminder missed during downtime:\n%s", digests[0])
+	}
+
+	// Все, кроме будущего, отмечены отправленными и больше не приходят
+	pending, _, err := f.store.GetPendingTaskReminders(now.Add(time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(pending) != 1 || pending[0].ID != future.ID {
+		t.Errorf("pending reminders %v, want only %d", pending, future.ID)
+	}
+}
+
+func keys(m map[string]string) []string {
+	var keys []string
+	for k := range m {
+		keys = append(keys, k)
+	}
+	return keys
+}
+
+func itoa(id int64) string {
+	return strconv.FormatInt(id, 10)
+}
@@ -0,0 +1,158 @@
+package scheduler
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/tazhate/familybot/internal/clock"
+	"github.com/tazhate/familybot/internal/domain"
+	"github.com/tazhate/familybot/internal/service"
+	"github.com/tazhate/familybot/internal/storage"
+)
+
+const (
+	dispatchInterval  = 15 * time.Second
+	dispatchBatchSize = 50
+
+	// Повторы: 30s, 1m, 2m, 4m ... но не реже раза в час; после maxAttempts — failed
+	retryBaseDelay   = 30 * time.Second
+	retryMaxDelay    = time.Hour
+	maxSendAttempts  = 10
+	sentRetentionAge = 30 * 24 * time.Hour
+)
+
+// Dispatcher sends notifications from the outbox (table notifications).
+// Failed sends are retried with exponential backoff, non-urgent notifications
+// wait for the end of the recipient's quiet hours.
+type Dispatcher struct {
+	storage         storage.Store
+	settingsService *service.SettingsService
+	sender          MessageSender
+	clock           clock.Clock
+	wake            chan struct{}
+}
+
+func NewDispatcher(s storage.Store, settingsSvc *service.SettingsService) *Dispatcher {
+	return &Dispatcher{
+		storage:         s,
+		settingsService: settingsSvc,
+		clock:           clock.Real(),
+		wake:            make(chan struct{}, 1),
+	}
+}
+
+func (d *Dispatcher) SetSender(sender MessageSender) {
+	d.sender = sender
+}
+
+func (d *Dispatcher) SetClock(c clock.Clock) {
+	d.clock = c
+}
+
+// Wake asks the dispatcher to check the outbox right away
+func (d *Dispatcher) Wake() {
+	select {
+	case d.wake <- struct{}{}:
+	default:
+	}
+}
+
+// Run dispatches notifications until ctx is cancelled
+func (d *Dispatcher) Run(ctx context.Context) {
+	ticker := time.NewTicker(dispatchInterval)
+	defer ticker.Stop()
+
+	d.dispatch()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		case <-d.wake:
+		}
+		d.dispatch()
+	}
+}
+
+func (d *Dispatcher) dispatch() {
+	if d.sender == nil {
+		return
+	}
+
+	now := d.clock.Now()
+	notifications, err := d.storage.ListDueNotifications(now, dispatchBatchSize)
+	if err != nil {
+		log.Printf("Error listing due notifications: %v", err)
+		return
+	}
+
+	for _, n := range notifications {
+		if !n.Urgent && n.UserID != nil && d.settingsService != nil {
+			if until, ok := d.settingsService.QuietUntil(*n.UserID, now); ok {
+				if err := d.storage.PostponeNotification(n.ID, until); err != nil {
+					log.Printf("Error postponing notification %d: %v", n.ID, err)
+				}
+				continue
+			}
+		}
+		d.send(n)
+	}
+}
+
+func (d *Dispatcher) send(n *domain.Notification) {
+	var err error
+	if n.TaskID != nil {
+		err = d.sender.SendMessageWithSnooze(n.ChatID, n.Text, *n.TaskID)
+	} else if n.ConflictID != nil {
+		err = d.sender.SendCalendarConflict(n.ChatID, n.Text, *n.ConflictID)
+	} else {
+		err = d.sender.SendMessage(n.ChatID, n.Text)
+	}
+
+	if err == nil {
+		if err := d.storage.MarkNotificationSent(n.ID, d.clock.Now()); err != nil {
+			log.Printf("Error marking notification %d as sent: %v", n.ID, err)
+		}
+		return
+	}
+
+	attempts := n.Attempts + 1
+	if attempts >= maxSendAttempts {
+		log.Printf("Notification %d (%s) to %d failed after %d attempts: %v", n.ID, n.Key, n.ChatID, attempts, err)
+		if err := d.storage.FailNotification(n.ID, attempts, err.Error()); err != nil {
+			log.Printf("Error marking notification %d as failed: %v", n.ID, err)
+		}
+		return
+	}
+
+	delay := retryDelay(attempts)
+	log.Printf("Error sending notification %d (%s) to %d, retry in %s: %v", n.ID, n.Key, n.ChatID, delay, err)
+	if err := d.storage.RetryNotification(n.ID, attempts, d.clock.Now().Add(delay), err.Error()); err != nil {
+		log.Printf("Error scheduling retry for notification %d: %v", n.ID, err)
+	}
+}
+
+// cleanup removes delivered notifications older than sentRetentionAge
+func (d *Dispatcher) cleanup() {
+	deleted, err := d.storage.DeleteSentNotificationsBefore(d.clock.Now().Add(-sentRetentionAge))
+	if err != nil {
+		log.Printf("Error cleaning up notifications: %v", err)
+		return
+	}
+	if deleted > 0 {
+		log.Printf("Notifications cleanup: removed %d", deleted)
+	}
+}
+
+// retryDelay returns the delay before the attempt after `attempts` failed ones
+func retryDelay(attempts int) time.Duration {
+	delay := retryBaseDelay
+	for i := 1; i < attempts && delay < retryMaxDelay; i++ {
+		delay *= 2
+	}
+	if delay > retryMaxDelay {
+		delay = retryMaxDelay
+	}
+	return delay
+}
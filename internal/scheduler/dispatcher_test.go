@@ -0,0 +1,190 @@
+package scheduler
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/tazhate/familybot/config"
+	"github.com/tazhate/familybot/internal/clock"
+	"github.com/tazhate/familybot/internal/domain"
+	"github.com/tazhate/familybot/internal/service"
+	"github.com/tazhate/familybot/internal/storage"
+	"github.com/tazhate/familybot/internal/storage/storagetest"
+)
+
+// countingSender counts delivered messages per chat; with err set every send fails
+type countingSender struct {
+	sent map[int64][]string
+	err  error
+}
+
+func (c *countingSender) SendMessage(chatID int64, text string) error {
+	if c.err != nil {
+		return c.err
+	}
+	c.sent[chatID] = append(c.sent[chatID], text)
+	return nil
+}
+
+func (c *countingSender) SendMessageWithSnooze(chatID int64, text string, taskID int64) error {
+	return c.SendMessage(chatID, text)
+}
+
+func (c *countingSender) SendCalendarConflict(chatID int64, text string, conflictID int64) error {
+	return c.SendMessage(chatID, text)
+}
+
+type dispatchFixture struct {
+	t        *testing.T
+	store    *storage.Storage
+	clock    *clock.Fake
+	sender   *countingSender
+	settings *service.SettingsService
+	sched    *Scheduler
+	user     *domain.User
+}
+
+func newDispatchFixture(t *testing.T) *dispatchFixture {
+	t.Helper()
+	store := storagetest.SQLite(t)
+	clk := clock.NewFake(time.Date(2030, time.June, 4, 12, 0, 0, 0, testLocation))
+	cfg := &config.Config{OwnerTelegramID: 100, Timezone: testLocation, MorningTime: "09:00", EveningTime: "21:00", MaxLateness: 30 * time.Minute}
+
+	user := &domain.User{TelegramID: 100, Name: "Алекс", Role: domain.RoleOwner}
+	if err := store.CreateUser(user); err != nil {
+		t.Fatal(err)
+	}
+	settings := service.NewSettingsService(store, testLocation, cfg.MorningTime, cfg.EveningTime)
+	settings.SetClock(clk)
+
+	sender := &countingSender{sent: make(map[int64][]string)}
+	s := New(cfg, store, nil, nil, nil, nil, nil, nil, nil, settings, nil, nil)
+	s.SetSender(sender)
+	s.SetClock(clk)
+	return &dispatchFixture{t: t, store: store, clock: clk, sender: sender, settings: settings, sched: s, user: user}
+}
+
+func (f *dispatchFixture) notify(key, text string, urgent bool) {
+	f.t.Helper()
+	if err := f.sched.notify(f.user, key, text, 0, urgent); err != nil {
+		f.t.Fatal(err)
+	}
+}
+
+// only returns the single notification in the outbox
+func (f *dispatchFixture) only() *domain.Notification {
+	f.t.Helper()
+	notifications, err := f.store.ListNotifications("", 10)
+	if err != nil || len(notifications) != 1 {
+		f.t.Fatalf("outbox %+v, %v; want one notification", notifications, err)
+	}
+	return notifications[0]
+}
+
+func TestRetryDelay(t *testing.T) {
+	tests := []struct {
+		attempts int
+		want     time.Duration
+	}{
+		{1, 30 * time.Second},
+		{2, time.Minute},
+		{3, 2 * time.Minute},
+		{7, 32 * time.Minute},
+		{8, time.Hour}, // 64 минуты упираются в потолок
+		{50, time.Hour},
+	}
+	for _, tt := range tests {
+		if got := retryDelay(tt.attempts); got != tt.want {
+			t.Errorf("retryDelay(%d) = %s, want %s", tt.attempts, got, tt.want)
+		}
+	}
+}
+
+func TestDispatchDeduplicatesKeys(t *testing.T) {
+	f := newDispatchFixture(t)
+	f.notify("briefing:2030-06-04", "☀️ Доброе утро", false)
+	f.notify("briefing:2030-06-04", "☀️ Доброе утро ещё раз", false)
+	f.sched.dispatcher.dispatch()
+	// Уже отправленное с тем же ключом тоже не повторяется
+	f.notify("briefing:2030-06-04", "☀️ Доброе утро в третий раз", false)
+	f.sched.dispatcher.dispatch()
+
+	if got := f.sender.sent[f.user.TelegramID]; len(got) != 1 || got[0] != "☀️ Доброе утро" {
+		t.Errorf("sent %q, want only the first briefing", got)
+	}
+	if n := f.only(); n.Status != domain.NotificationSent || n.Attempts != 1 {
+		t.Errorf("notification %s after %d attempts", n.Status, n.Attempts)
+	}
+}
+
+func TestDispatchRetriesThenFails(t *testing.T) {
+	f := newDispatchFixture(t)
+	f.sender.err = errors.New("telegram: 502 Bad Gateway")
+	f.notify("reminder:1", "🔔 Выпить витамины", false)
+
+	for attempt := 1; attempt <= maxSendAttempts; attempt++ {
+		f.sched.dispatcher.dispatch()
+		n := f.only()
+		if n.Attempts != attempt || n.LastError != "telegram: 502 Bad Gateway" {
+			t.Fatalf("attempt %d: attempts %d, error %q", attempt, n.Attempts, n.LastError)
+		}
+		if attempt == maxSendAttempts {
+			if n.Status != domain.NotificationFailed {
+				t.Fatalf("status %s after %d attempts, want failed", n.Status, attempt)
+			}
+			break
+		}
+		if want := f.clock.Now().Add(retryDelay(attempt)); n.Status != domain.NotificationPending || !n.NextAttemptAt.Equal(want) {
+			t.Fatalf("attempt %d: %s, next at %s; want pending at %s", attempt, n.Status, n.NextAttemptAt, want)
+		}
+		// До срока повтора ничего не отправляется
+		f.clock.Advance(retryDelay(attempt) - time.Second)
+		f.sched.dispatcher.dispatch()
+		if n := f.only(); n.Attempts != attempt {
+			t.Fatalf("retried %s early", retryDelay(attempt))
+		}
+		f.clock.Advance(time.Second)
+	}
+
+	// Проваленное уведомление больше не отправляется
+	f.sender.err = nil
+	f.clock.Advance(24 * time.Hour)
+	f.sched.dispatcher.dispatch()
+	if got := f.sender.sent[f.user.TelegramID]; len(got) != 0 {
+		t.Errorf("failed notification sent: %q", got)
+	}
+}
+
+func TestDispatchPostponesQuietHours(t *testing.T) {
+	f := newDispatchFixture(t)
+	if _, err := f.settings.SetQuietHours(f.user.ID, "11:00-13:30"); err != nil {
+		t.Fatal(err)
+	}
+	f.notify("quote:2030-06-04", "💕 Цитата дня", false)
+	f.notify("urgent:7", "🔴 Оплатить садик", true)
+	f.sched.dispatcher.dispatch()
+
+	if got := f.sender.sent[f.user.TelegramID]; len(got) != 1 || got[0] != "🔴 Оплатить садик" {
+		t.Fatalf("sent %q during quiet hours, want only the urgent one", got)
+	}
+	pending, err := f.store.ListNotifications(domain.NotificationPending, 10)
+	if err != nil || len(pending) != 1 {
+		t.Fatalf("pending %+v, %v", pending, err)
+	}
+	end := time.Date(2030, time.June, 4, 13, 30, 0, 0, testLocation)
+	if !pending[0].NextAttemptAt.Equal(end) || pending[0].Attempts != 0 {
+		t.Errorf("postponed to %s after %d attempts, want %s without attempts", pending[0].NextAttemptAt, pending[0].Attempts, end)
+	}
+
+	f.clock.Set(end.Add(-time.Minute))
+	f.sched.dispatcher.dispatch()
+	if got := len(f.sender.sent[f.user.TelegramID]); got != 1 {
+		t.Errorf("sent %d messages before quiet hours ended", got)
+	}
+	f.clock.Set(end)
+	f.sched.dispatcher.dispatch()
+	if got := f.sender.sent[f.user.TelegramID]; len(got) != 2 || got[1] != "💕 Цитата дня" {
+		t.Errorf("sent %q after quiet hours", got)
+	}
+}
@@ -0,0 +1,83 @@
+package scheduler
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/tazhate/familybot/config"
+	"github.com/tazhate/familybot/internal/clients/debtmanager"
+	"github.com/tazhate/familybot/internal/clock"
+	"github.com/tazhate/familybot/internal/domain"
+	"github.com/tazhate/familybot/internal/storage/storagetest"
+)
+
+// TestFinanceDigestsGoToAdults checks that debt and payday digests reach the admins
+// and adults of OWNER's household, and nobody else
+func TestFinanceDigestsGoToAdults(t *testing.T) {
+	debts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/debts":
+			w.Write([]byte(`[{"id":1,"name":"Ипотека","current_amount":100000,"monthly_payment":30000,"payment_day":16}]`))
+		case "/incomes":
+			w.Write([]byte(`[{"id":1,"source":"Зарплата","amount":90000,"payment_day":15,"is_recurring":true}]`))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer debts.Close()
+
+	store := storagetest.SQLite(t)
+	now := time.Date(2030, time.March, 15, 10, 0, 0, 0, testLocation)
+	cfg := &config.Config{OwnerTelegramID: 100, Timezone: testLocation, MorningTime: "09:00", EveningTime: "21:00", MaxLateness: 30 * time.Minute}
+
+	home, other := &domain.Household{Name: "Дом"}, &domain.Household{Name: "Соседи"}
+	for _, h := range []*domain.Household{home, other} {
+		if err := store.CreateHousehold(h); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for i, m := range []struct {
+		household *domain.Household
+		role      domain.HouseholdRole
+	}{
+		{home, domain.HouseholdAdmin},
+		{home, domain.HouseholdAdult},
+		{home, domain.HouseholdChild},
+		{home, domain.HouseholdViewer},
+		{other, domain.HouseholdAdmin},
+	} {
+		u := &domain.User{TelegramID: int64(100 * (i + 1)), Name: string(m.role), Role: domain.RoleOwner}
+		if err := store.CreateUser(u); err != nil {
+			t.Fatal(err)
+		}
+		if err := store.AddHouseholdMember(&domain.HouseholdMember{HouseholdID: m.household.ID, UserID: u.ID, Role: m.role}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	s := New(cfg, store, nil, nil, nil, nil, nil, nil, nil, nil, nil, debtmanager.NewClient(debts.URL, "token"))
+	s.SetSender(nopSender{})
+	s.SetClock(clock.NewFake(now))
+	s.checkDebtPaymentsTomorrow()
+	s.checkPayday()
+
+	notifications, err := store.ListNotifications(domain.NotificationPending, 100)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := make(map[string][]int64)
+	for _, n := range notifications {
+		kind, _, _ := strings.Cut(n.Key, ":")
+		got[kind] = append(got[kind], n.ChatID)
+	}
+	for _, kind := range []string{"debts", "payday"} {
+		slices.Sort(got[kind])
+		if !slices.Equal(got[kind], []int64{100, 200}) {
+			t.Errorf("%s digest sent to %v, want [100 200]", kind, got[kind])
+		}
+	}
+}
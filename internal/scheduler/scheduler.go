@@ -2,6 +2,7 @@ package scheduler
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"log"
 	"strings"
@@ -10,20 +11,25 @@ import (
 	"github.com/robfig/cron/v3"
 	"github.com/tazhate/familybot/config"
 	"github.com/tazhate/familybot/internal/clients/debtmanager"
+	"github.com/tazhate/familybot/internal/clock"
 	"github.com/tazhate/familybot/internal/domain"
 	"github.com/tazhate/familybot/internal/service"
 	"github.com/tazhate/familybot/internal/storage"
 )
 
+// liveMessageRetention — сколько помнить сообщения с чек-листами и общими задачами
+const liveMessageRetention = 14 * 24 * time.Hour
+
 type MessageSender interface {
 	SendMessage(chatID int64, text string) error
 	SendMessageWithSnooze(chatID int64, text string, taskID int64) error
+	SendCalendarConflict(chatID int64, text string, conflictID int64) error
 }
 
 type Scheduler struct {
 	cron             *cron.Cron
 	cfg              *config.Config
-	storage          *storage.Storage
+	storage          storage.Store
 	taskService      *service.TaskService
 	reminderService  *service.ReminderService
 	personService    *service.PersonService
@@ -31,11 +37,15 @@ type Scheduler struct {
 	checklistService *service.ChecklistService
 	calendarService  *service.CalendarService
 	todoistService   *service.TodoistService
+	settingsService  *service.SettingsService
+	autoService      *service.AutoService
 	debtClient       *debtmanager.Client
 	sender           MessageSender
+	dispatcher       *Dispatcher
+	clock            clock.Clock
 }
 
-func New(cfg *config.Config, storage *storage.Storage, taskSvc *service.TaskService, reminderSvc *service.ReminderService, personSvc *service.PersonService, scheduleSvc *service.ScheduleService, checklistSvc *service.ChecklistService, calendarSvc *service.CalendarService, todoistSvc *service.TodoistService, debtClient *debtmanager.Client) *Scheduler {
+func New(cfg *config.Config, storage storage.Store, taskSvc *service.TaskService, reminderSvc *service.ReminderService, personSvc *service.PersonService, scheduleSvc *service.ScheduleService, checklistSvc *service.ChecklistService, calendarSvc *service.CalendarService, todoistSvc *service.TodoistService, settingsSvc *service.SettingsService, autoSvc *service.AutoService, debtClient *debtmanager.Client) *Scheduler {
 	location := cfg.Timezone
 
 	c := cron.New(cron.WithLocation(location))
@@ -51,167 +61,362 @@ func New(cfg *config.Config, storage *storage.Storage, taskSvc *service.TaskServ
 		checklistService: checklistSvc,
 		calendarService:  calendarSvc,
 		todoistService:   todoistSvc,
+		settingsService:  settingsSvc,
+		autoService:      autoSvc,
 		debtClient:       debtClient,
+		dispatcher:       NewDispatcher(storage, settingsSvc),
+		clock:            clock.Real(),
 	}
 }
 
 func (s *Scheduler) SetSender(sender MessageSender) {
 	s.sender = sender
+	s.dispatcher.SetSender(sender)
 }
 
-func (s *Scheduler) Start(ctx context.Context) error {
-	// Создание задач из отслеживаемых событий расписания (за 5 мин до брифинга)
-	if _, err := s.cron.AddFunc("55 5 * * *", s.createTrackableEventTasks); err != nil {
-		return fmt.Errorf("add trackable event tasks: %w", err)
-	}
-
-	// Утренний брифинг (парсим "09:00" -> "0 9 * * *")
-	morningSpec := timeToCron(s.cfg.MorningTime)
-	if _, err := s.cron.AddFunc(morningSpec, s.morningBriefing); err != nil {
-		return fmt.Errorf("add morning briefing: %w", err)
-	}
-
-	// Вечерний чекин
-	eveningSpec := timeToCron(s.cfg.EveningTime)
-	if _, err := s.cron.AddFunc(eveningSpec, s.eveningCheckin); err != nil {
-		return fmt.Errorf("add evening checkin: %w", err)
-	}
-
-	// Проверка напоминаний каждую минуту
-	if _, err := s.cron.AddFunc("* * * * *", s.checkReminders); err != nil {
-		return fmt.Errorf("add reminder check: %w", err)
-	}
-
-	// Проверка напоминаний о событиях каждую минуту
-	if _, err := s.cron.AddFunc("* * * * *", s.checkEventReminders); err != nil {
-		return fmt.Errorf("add event reminder check: %w", err)
-	}
+// SetClock replaces the system clock for the scheduler and its dispatcher
+func (s *Scheduler) SetClock(c clock.Clock) {
+	s.clock = c
+	s.dispatcher.SetClock(c)
+}
 
-	// Пятничное напоминание о плавающих событиях (в 10:00 по пятницам)
-	if _, err := s.cron.AddFunc("0 10 * * 5", s.fridayFloatingReminder); err != nil {
-		return fmt.Errorf("add friday floating reminder: %w", err)
-	}
+// job — периодическая задача планировщика; spec — cron-выражение в часовом поясе по умолчанию
+type job struct {
+	name string
+	spec string
+	run  func()
+}
 
-	// Проверка urgent задач каждый час (повторные напоминания)
-	if _, err := s.cron.AddFunc("0 * * * *", s.checkUrgentTasks); err != nil {
-		return fmt.Errorf("add urgent task check: %w", err)
+// jobs returns the scheduler's periodic jobs; used both by cron and by Tick
+func (s *Scheduler) jobs() []job {
+	jobs := []job{
+		// Брифинги, чекины и пятничные напоминания — по личному времени и часовому поясу
+		// каждого участника семьи (/settings), поэтому проверяются каждую минуту
+		{"user schedules check", "* * * * *", s.checkUserSchedules},
+		// Напоминания, события, повторяющиеся задачи и напоминания по задачам — каждую минуту
+		{"reminder check", "* * * * *", s.checkReminders},
+		{"event reminder check", "* * * * *", s.checkEventReminders},
+		{"repeating task check", "* * * * *", s.checkRepeatingTasks},
+		{"task reminder check", "* * * * *", s.checkTaskReminders},
+		// Проверка urgent задач каждый час (повторные напоминания)
+		{"urgent task check", "0 * * * *", s.checkUrgentTasks},
+		// Daily relationship quote at 12:00 (inspired by Imago therapy)
+		{"daily quote", "0 12 * * *", s.sendDailyQuote},
+		{"notifications cleanup", "0 4 * * *", s.dispatcher.cleanup},
+		{"live messages cleanup", "10 4 * * *", s.cleanupLiveMessages},
+	}
+
+	// Машины: страховка, ТО, документы и регламент — утром в 10:00
+	if s.autoService != nil {
+		jobs = append(jobs, job{"auto reminders", "0 10 * * *", s.checkAutoReminders})
+	}
+
+	// Календари CalDAV: авто-синхронизация каждый час, напоминания о событиях каждые 5 минут.
+	// Календари подключают через /calendars, поэтому задачи есть и пока их нет.
+	if s.calendarService != nil {
+		jobs = append(jobs,
+			job{"calendar sync", "0 * * * *", s.syncCalendars},
+			job{"calendar event reminders", "*/5 * * * *", s.checkCalendarEventReminders},
+		)
 	}
 
-	// Проверка повторяющихся задач по времени каждую минуту
-	if _, err := s.cron.AddFunc("* * * * *", s.checkRepeatingTasks); err != nil {
-		return fmt.Errorf("add repeating task check: %w", err)
+	// Todoist: авто-синхронизация каждый час
+	if s.todoistService != nil && s.todoistService.IsConfigured() {
+		jobs = append(jobs, job{"todoist sync", "30 * * * *", s.syncTodoist})
 	}
 
-	// Проверка напоминаний по задачам каждую минуту
-	if _, err := s.cron.AddFunc("* * * * *", s.checkTaskReminders); err != nil {
-		return fmt.Errorf("add task reminder check: %w", err)
+	// Debt Manager: платежи на завтра (вечером в 21:00), зарплата и сводка платежей (утром в 10:00)
+	if s.debtClient != nil && s.debtClient.IsConfigured() {
+		jobs = append(jobs,
+			job{"debt payments tomorrow check", "0 21 * * *", s.checkDebtPaymentsTomorrow},
+			job{"payday check", "0 10 * * *", s.checkPayday},
+		)
 	}
+	return jobs
+}
 
-	// Apple Calendar: авто-синхронизация каждый час
-	if s.calendarService != nil && s.calendarService.IsConfigured() {
-		if _, err := s.cron.AddFunc("0 * * * *", s.syncAppleCalendar); err != nil {
-			return fmt.Errorf("add apple calendar sync: %w", err)
-		}
-		// Проверка напоминаний о календарных событиях каждые 5 минут
-		if _, err := s.cron.AddFunc("*/5 * * * *", s.checkCalendarEventReminders); err != nil {
-			return fmt.Errorf("add calendar event reminders: %w", err)
+func (s *Scheduler) Start(ctx context.Context) error {
+	for _, j := range s.jobs() {
+		if _, err := s.cron.AddFunc(j.spec, j.run); err != nil {
+			return fmt.Errorf("add %s: %w", j.name, err)
 		}
-		log.Println("Apple Calendar sync enabled (hourly)")
 	}
-
-	// Todoist: авто-синхронизация каждый час
+	if s.calendarService != nil {
+		log.Println("Calendar sync enabled (hourly)")
+	}
 	if s.todoistService != nil && s.todoistService.IsConfigured() {
-		if _, err := s.cron.AddFunc("30 * * * *", s.syncTodoist); err != nil {
-			return fmt.Errorf("add todoist sync: %w", err)
-		}
 		log.Println("Todoist sync enabled (hourly)")
 	}
-
-	// Debt Manager: проверка платежей на завтра (вечером в 21:00)
 	if s.debtClient != nil && s.debtClient.IsConfigured() {
-		if _, err := s.cron.AddFunc("0 21 * * *", s.checkDebtPaymentsTomorrow); err != nil {
-			return fmt.Errorf("add debt payments tomorrow check: %w", err)
-		}
-		// Debt Manager: проверка зарплаты и сводка платежей (утром в 10:00)
-		if _, err := s.cron.AddFunc("0 10 * * *", s.checkPayday); err != nil {
-			return fmt.Errorf("add payday check: %w", err)
-		}
 		log.Println("Debt Manager notifications enabled")
 	}
-
-	// Daily relationship quote at 12:00 (inspired by Imago therapy)
-	if _, err := s.cron.AddFunc("0 12 * * *", s.sendDailyQuote); err != nil {
-		return fmt.Errorf("add daily quote: %w", err)
-	}
 	log.Println("Daily relationship quotes enabled (12:00)")
 
+	// Уведомления пишутся в outbox, отправляет их диспетчер (повторы, тихие часы)
+	go s.dispatcher.Run(ctx)
+
+	// Досылаем напоминания, пропущенные пока бот был выключен
+	s.replayMissed()
+
 	s.cron.Start()
-	log.Printf("Scheduler started (TZ: %s, morning: %s, evening: %s)",
+	log.Printf("Scheduler started (default TZ: %s, morning: %s, evening: %s)",
 		s.cfg.Timezone, s.cfg.MorningTime, s.cfg.EveningTime)
 
 	<-ctx.Done()
 	return nil
 }
 
+// Tick synchronously runs the jobs due at the clock's current minute, as cron would,
+// and then dispatches the outbox. Lets a fake clock drive the scheduler through whole days.
+func (s *Scheduler) Tick() {
+	now := s.clock.Now().In(s.cfg.Timezone).Truncate(time.Minute)
+	for _, j := range s.jobs() {
+		schedule, err := cron.ParseStandard(j.spec)
+		if err != nil {
+			log.Printf("Scheduler: bad spec for %s: %v", j.name, err)
+			continue
+		}
+		if schedule.Next(now.Add(-time.Second)).Equal(now) {
+			j.run()
+		}
+	}
+	s.dispatcher.dispatch()
+}
+
 func (s *Scheduler) Stop() {
 	ctx := s.cron.Stop()
 	<-ctx.Done()
 	log.Println("Scheduler stopped")
 }
 
-func (s *Scheduler) morningBriefing() {
-	if s.sender == nil {
-		return
+// familyUsers returns all household members.
+// Until the household is set up falls back to OWNER and PARTNER from the config.
+func (s *Scheduler) familyUsers() []*domain.User {
+	users, err := s.storage.ListHouseholdUsers()
+	if err != nil {
+		log.Printf("Error listing household users: %v", err)
+	}
+	if len(users) > 0 {
+		return users
 	}
 
-	s.sendBriefingTo(s.cfg.OwnerTelegramID)
-	if s.cfg.PartnerTelegramID != 0 {
-		s.sendBriefingTo(s.cfg.PartnerTelegramID)
+	for _, telegramID := range []int64{s.cfg.OwnerTelegramID, s.cfg.PartnerTelegramID} {
+		if telegramID == 0 {
+			continue
+		}
+		if user, err := s.storage.GetUserByTelegramID(telegramID); err == nil && user != nil {
+			users = append(users, user)
+		}
 	}
+	return users
 }
 
-func (s *Scheduler) sendBriefingTo(telegramID int64) {
+// cleanupLiveMessages forgets chat messages that haven't been shown for liveMessageRetention,
+// so changes to a checklist don't edit messages from long ago
+func (s *Scheduler) cleanupLiveMessages() {
+	deleted, err := s.storage.DeleteLiveMessagesBefore(s.clock.Now().Add(-liveMessageRetention))
+	if err != nil {
+		log.Printf("Error cleaning up live messages: %v", err)
+		return
+	}
+	if deleted > 0 {
+		log.Printf("Live messages cleanup: removed %d", deleted)
+	}
+}
+
+// userSettings returns the user's settings and the current time in the user's timezone
+func (s *Scheduler) userSettings(userID int64, now time.Time) (*domain.UserSettings, time.Time) {
+	if s.settingsService == nil {
+		return &domain.UserSettings{
+			UserID:           userID,
+			MorningTime:      s.cfg.MorningTime,
+			EveningTime:      s.cfg.EveningTime,
+			BriefingSections: domain.DefaultBriefingSections,
+		}, now.In(s.cfg.Timezone)
+	}
+	st := s.settingsService.Get(userID)
+	return st, now.In(st.Location(s.cfg.Timezone))
+}
+
+// notify puts a message for the user into the outbox. key identifies the occurrence
+// (e.g. "reminder:12:1760601600"): the same key for the same recipient is sent only once.
+// Non-urgent messages wait for the end of the user's quiet hours. taskID != 0 adds snooze buttons.
+func (s *Scheduler) notify(user *domain.User, key, text string, taskID int64, urgent bool) error {
+	n := &domain.Notification{
+		Key:    fmt.Sprintf("%s:%d", key, user.TelegramID),
+		UserID: &user.ID,
+		ChatID: user.TelegramID,
+		Text:   text,
+		Urgent: urgent,
+	}
+	if taskID != 0 {
+		n.TaskID = &taskID
+	}
+	return s.enqueue(n)
+}
+
+// notifyTelegramID is notify for a recipient known only by Telegram ID
+func (s *Scheduler) notifyTelegramID(telegramID int64, key, text string) error {
 	user, err := s.storage.GetUserByTelegramID(telegramID)
 	if err != nil || user == nil {
-		return
+		return s.enqueue(&domain.Notification{
+			Key:    fmt.Sprintf("%s:%d", key, telegramID),
+			ChatID: telegramID,
+			Text:   text,
+		})
 	}
+	return s.notify(user, key, text, 0, false)
+}
 
-	tasks, err := s.taskService.ListForToday(user.ID)
+// notifyFinance sends a debt-manager digest to the admins and adults of OWNER's household:
+// the debt-manager account belongs to that household. Until the household is set up
+// the digest goes to OWNER alone.
+func (s *Scheduler) notifyFinance(key, text string) error {
+	owner, err := s.storage.GetUserByTelegramID(s.cfg.OwnerTelegramID)
+	if err != nil || owner == nil {
+		return s.notifyTelegramID(s.cfg.OwnerTelegramID, key, text)
+	}
+	m, err := s.storage.GetHouseholdMember(owner.ID)
+	if err != nil || m == nil {
+		return s.notify(owner, key, text, 0, false)
+	}
+	members, err := s.storage.ListHouseholdMembers(m.HouseholdID)
 	if err != nil {
-		log.Printf("Error getting today tasks: %v", err)
+		return err
+	}
+	var errs []error
+	for _, mm := range members {
+		if mm.Role.CanSeeFinance() {
+			errs = append(errs, s.notify(mm.User, key, text, 0, false))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// enqueue writes the notification to the outbox and wakes the dispatcher
+func (s *Scheduler) enqueue(n *domain.Notification) error {
+	created, err := s.storage.EnqueueNotification(n, s.clock.Now())
+	if err != nil {
+		return err
+	}
+	if created {
+		s.dispatcher.Wake()
+	}
+	return nil
+}
+
+// checkUserSchedules runs per-user jobs at the user's local time:
+// trackable tasks and morning briefing, evening check-in, Friday floating reminder
+func (s *Scheduler) checkUserSchedules() {
+	if s.sender == nil {
 		return
 	}
 
+	now := s.clock.Now()
+	for _, user := range s.familyUsers() {
+		st, local := s.userSettings(user.ID, now)
+		clock := local.Format("15:04")
+
+		// Задачи из отслеживаемых событий создаются перед брифингом,
+		// а если брифинг выключен — во время брифинга по умолчанию
+		morning := st.MorningTime
+		if morning == "" {
+			morning = s.cfg.MorningTime
+		}
+		if clock == morning {
+			s.createTrackableTasksForUser(user, local)
+		}
+
+		if clock == st.MorningTime {
+			s.sendBriefingTo(user, st, local)
+		}
+		if clock == st.EveningTime {
+			s.sendCheckinTo(user, local)
+		}
+		// Пятничное напоминание о плавающих событиях (в 10:00 по пятницам)
+		if local.Weekday() == time.Friday && clock == "10:00" {
+			s.sendFloatingReminderTo(user, local)
+		}
+	}
+}
+
+// householdMates returns other members of the user's household
+func (s *Scheduler) householdMates(userID int64) []*domain.User {
+	m, err := s.storage.GetHouseholdMember(userID)
+	if err != nil || m == nil {
+		return nil
+	}
+	members, err := s.storage.ListHouseholdMembers(m.HouseholdID)
+	if err != nil {
+		return nil
+	}
+	var mates []*domain.User
+	for _, mm := range members {
+		if mm.UserID != userID {
+			mates = append(mates, mm.User)
+		}
+	}
+	return mates
+}
+
+// sendBriefingTo sends the morning briefing with the sections chosen in /settings
+func (s *Scheduler) sendBriefingTo(user *domain.User, st *domain.UserSettings, local time.Time) {
 	text := "☀️ <b>Доброе утро!</b>\n\n"
 
 	// Проверяем дни рождения
-	birthdayText := s.checkBirthdays(user.ID)
-	if birthdayText != "" {
-		text += birthdayText + "\n"
+	if st.HasSection(domain.SectionBirthdays) {
+		birthdayText := s.checkBirthdays(user.ID, local)
+		if birthdayText != "" {
+			text += birthdayText + "\n"
+		}
 	}
 
 	// Добавляем события календаря на сегодня
-	if s.calendarService != nil {
+	if s.calendarService != nil && st.HasSection(domain.SectionCalendar) {
 		calendarEvents, err := s.calendarService.ListToday(user.ID)
 		if err == nil && len(calendarEvents) > 0 {
 			text += s.calendarService.FormatTodayBriefing(calendarEvents) + "\n"
 		}
 	}
 
-	if len(tasks) == 0 {
-		text += "На сегодня задач нет. Отличный день!"
-	} else {
-		text += fmt.Sprintf("<b>На сегодня %d задач:</b>\n\n", len(tasks))
-		text += s.taskService.FormatTaskList(tasks)
+	// Недельное расписание на сегодня
+	if s.scheduleService != nil && st.HasSection(domain.SectionSchedule) {
+		events, err := s.scheduleService.ListForDay(user.ID, domain.Weekday(local.Weekday()), true)
+		if err == nil && len(events) > 0 {
+			text += "🗓 <b>Расписание:</b>\n"
+			for _, e := range events {
+				if e.IsFloating {
+					continue
+				}
+				text += fmt.Sprintf("• %s %s\n", e.TimeRange(), e.Title)
+			}
+			text += "\n"
+		}
+	}
+
+	if st.HasSection(domain.SectionTasks) {
+		tasks, err := s.taskService.ListForToday(user.ID)
+		if err != nil {
+			log.Printf("Error getting today tasks: %v", err)
+			return
+		}
+
+		if len(tasks) == 0 {
+			text += "На сегодня задач нет. Отличный день!"
+		} else {
+			text += fmt.Sprintf("<b>На сегодня %d задач:</b>\n\n", len(tasks))
+			text += s.taskService.FormatTaskList(tasks)
+		}
 	}
 
-	if err := s.sender.SendMessage(telegramID, text); err != nil {
-		log.Printf("Error sending morning briefing to %d: %v", telegramID, err)
+	// Брифинг приходит в выбранное время даже в тихие часы
+	if err := s.notify(user, "briefing:"+local.Format("2006-01-02"), text, 0, true); err != nil {
+		log.Printf("Error sending morning briefing to %d: %v", user.TelegramID, err)
 	}
 }
 
-// checkBirthdays returns birthday notifications text
-func (s *Scheduler) checkBirthdays(userID int64) string {
+// checkBirthdays returns birthday notifications text; local is the user's current time
+func (s *Scheduler) checkBirthdays(userID int64, local time.Time) string {
 	if s.personService == nil {
 		return ""
 	}
@@ -231,10 +436,10 @@ func (s *Scheduler) checkBirthdays(userID int64) string {
 	result.WriteString("🎂 <b>Дни рождения:</b>\n")
 
 	for _, p := range persons {
-		days := p.DaysUntilBirthday()
+		days := p.DaysUntilBirthday(local)
 		age := ""
 		if p.Birthday.Year() > 1 {
-			nextAge := p.Age()
+			nextAge := p.Age(local)
 			if days > 0 {
 				nextAge++
 			}
@@ -254,23 +459,7 @@ func (s *Scheduler) checkBirthdays(userID int64) string {
 	return result.String()
 }
 
-func (s *Scheduler) eveningCheckin() {
-	if s.sender == nil {
-		return
-	}
-
-	s.sendCheckinTo(s.cfg.OwnerTelegramID)
-	if s.cfg.PartnerTelegramID != 0 {
-		s.sendCheckinTo(s.cfg.PartnerTelegramID)
-	}
-}
-
-func (s *Scheduler) sendCheckinTo(telegramID int64) {
-	user, err := s.storage.GetUserByTelegramID(telegramID)
-	if err != nil || user == nil {
-		return
-	}
-
+func (s *Scheduler) sendCheckinTo(user *domain.User, local time.Time) {
 	// Получаем все невыполненные задачи
 	tasks, err := s.taskService.List(user.ID, false)
 	if err != nil {
@@ -296,8 +485,8 @@ func (s *Scheduler) sendCheckinTo(telegramID int64) {
 		text += "\n\n/list — посмотреть список"
 	}
 
-	if err := s.sender.SendMessage(telegramID, text); err != nil {
-		log.Printf("Error sending evening checkin to %d: %v", telegramID, err)
+	if err := s.notify(user, "checkin:"+local.Format("2006-01-02"), text, 0, true); err != nil {
+		log.Printf("Error sending evening checkin to %d: %v", user.TelegramID, err)
 	}
 }
 
@@ -318,8 +507,13 @@ func (s *Scheduler) checkReminders() {
 			continue
 		}
 
+		// Ключ — напоминание и момент срабатывания: повторная проверка не продублирует его
+		key := fmt.Sprintf("reminder:%d", r.ID)
+		if r.NextRun != nil {
+			key += fmt.Sprintf(":%d", r.NextRun.Unix())
+		}
 		text := fmt.Sprintf("🔔 <b>Напоминание</b>\n\n%s", r.Title)
-		if err := s.sender.SendMessage(user.TelegramID, text); err != nil {
+		if err := s.notify(user, key, text, 0, false); err != nil {
 			log.Printf("Error sending reminder %d to user %d: %v", r.ID, user.TelegramID, err)
 			continue
 		}
@@ -332,104 +526,115 @@ func (s *Scheduler) checkReminders() {
 }
 
 func (s *Scheduler) checkEventReminders() {
-	if s.sender == nil {
-		return
-	}
+	s.runCatchUp(jobEventReminders, s.catchUpEventReminders)
+}
 
+// catchUpEventReminders sends weekly event reminders for every minute since the job's watermark
+func (s *Scheduler) catchUpEventReminders(now time.Time, missed *missedDigest) {
 	events, err := s.storage.ListEventsWithReminders()
 	if err != nil {
 		log.Printf("Error getting events with reminders: %v", err)
 		return
 	}
 
-	currentTime := time.Now().In(s.cfg.Timezone)
-	currentWeekday := int(currentTime.Weekday())
-	currentTimeStr := currentTime.Format("15:04")
-
+	users := make(map[int64]*domain.User)
 	for _, e := range events {
-		// Determine the effective day for this event
-		eventDay := int(e.DayOfWeek)
+		if _, ok := users[e.UserID]; ok {
+			continue
+		}
+		user, err := s.storage.GetUserByID(e.UserID)
+		if err == nil && user != nil {
+			users[e.UserID] = user
+		}
+	}
 
-		// For floating events, use confirmed day
-		if e.IsFloating {
-			if !e.IsConfirmedThisWeek() || e.ConfirmedDay == nil {
+	s.catchUp(jobEventReminders, now, func(minute time.Time) {
+		for _, e := range events {
+			// Get user
+			user := users[e.UserID]
+			if user == nil {
 				continue
 			}
-			eventDay = *e.ConfirmedDay
-		}
 
-		// Skip if not today
-		if eventDay != currentWeekday {
-			continue
-		}
+			// Event times are in the user's timezone
+			_, currentTime := s.userSettings(user.ID, minute)
+			currentWeekday := int(currentTime.Weekday())
+			currentTimeStr := currentTime.Format("15:04")
 
-		// Calculate reminder time
-		eventTime, err := parseTime(e.TimeStart)
-		if err != nil {
-			continue
-		}
+			// Determine the effective day for this event
+			eventDay := int(e.DayOfWeek)
 
-		reminderTime := eventTime.Add(-time.Duration(e.ReminderBefore) * time.Minute)
-		reminderTimeStr := reminderTime.Format("15:04")
+			// For floating events, use confirmed day
+			if e.IsFloating {
+				if !e.IsConfirmedThisWeek(minute) || e.ConfirmedDay == nil {
+					continue
+				}
+				eventDay = *e.ConfirmedDay
+			}
 
-		// Check if it's time to send reminder (exact minute match)
-		if currentTimeStr != reminderTimeStr {
-			continue
-		}
+			// Skip if not today
+			if eventDay != currentWeekday {
+				continue
+			}
 
-		// Get user
-		user, err := s.storage.GetUserByID(e.UserID)
-		if err != nil || user == nil {
-			continue
-		}
+			// Calculate reminder time
+			eventTime, err := parseTime(e.TimeStart, currentTime)
+			if err != nil {
+				continue
+			}
 
-		// Format the reminder text naturally
-		var text string
-		switch {
-		case e.ReminderBefore >= 60 && e.ReminderBefore%60 == 0:
-			hours := e.ReminderBefore / 60
-			if hours == 1 {
-				text = fmt.Sprintf("⏰ <b>Через 1 час</b> — %s (%s)", e.Title, e.TimeStart)
-			} else {
-				text = fmt.Sprintf("⏰ <b>Через %d ч</b> — %s (%s)", hours, e.Title, e.TimeStart)
+			reminderTime := eventTime.Add(-time.Duration(e.ReminderBefore) * time.Minute)
+			reminderTimeStr := reminderTime.Format("15:04")
+
+			// Check if it's time to send reminder (exact minute match)
+			if currentTimeStr != reminderTimeStr {
+				continue
 			}
-		case e.ReminderBefore > 0:
-			text = fmt.Sprintf("⏰ <b>Через %d мин</b> — %s (%s)", e.ReminderBefore, e.Title, e.TimeStart)
-		default:
-			text = fmt.Sprintf("⏰ <b>Сейчас</b> — %s", e.Title)
-		}
 
-		// Append checklist if linked
-		if e.ChecklistID != nil && s.checklistService != nil {
-			checklist, err := s.checklistService.Get(*e.ChecklistID)
-			if err == nil && checklist != nil {
-				// Reset checklist before showing
-				checklist.ResetChecks()
-				text += "\n\n" + s.checklistService.FormatChecklist(checklist)
+			if missed.tooLate(minute) {
+				missed.add(user, fmt.Sprintf("⏰ %s %s (%s)", currentTime.Format("02.01"), e.Title, e.TimeStart))
+				continue
 			}
-		}
 
-		if err := s.sender.SendMessage(user.TelegramID, text); err != nil {
-			log.Printf("Error sending event reminder for event %d to user %d: %v", e.ID, user.TelegramID, err)
-		}
-	}
-}
+			// Format the reminder text naturally
+			var text string
+			switch {
+			case e.ReminderBefore >= 60 && e.ReminderBefore%60 == 0:
+				hours := e.ReminderBefore / 60
+				if hours == 1 {
+					text = fmt.Sprintf("⏰ <b>Через 1 час</b> — %s (%s)", e.Title, e.TimeStart)
+				} else {
+					text = fmt.Sprintf("⏰ <b>Через %d ч</b> — %s (%s)", hours, e.Title, e.TimeStart)
+				}
+			case e.ReminderBefore > 0:
+				text = fmt.Sprintf("⏰ <b>Через %d мин</b> — %s (%s)", e.ReminderBefore, e.Title, e.TimeStart)
+			default:
+				text = fmt.Sprintf("⏰ <b>Сейчас</b> — %s", e.Title)
+			}
 
-// fridayFloatingReminder sends reminders about floating events on Fridays
-func (s *Scheduler) fridayFloatingReminder() {
-	if s.sender == nil || s.scheduleService == nil {
-		return
-	}
+			// Start a run of the linked checklist (once per occurrence) and append it
+			if e.ChecklistID != nil && s.checklistService != nil {
+				dayStart := time.Date(currentTime.Year(), currentTime.Month(), currentTime.Day(), 0, 0, 0, 0, currentTime.Location())
+				run, err := s.checklistService.StartEventRun(e, dayStart)
+				if err != nil {
+					log.Printf("Error starting checklist run for event %d: %v", e.ID, err)
+				} else if run != nil {
+					text += "\n\n" + s.checklistService.FormatRun(run)
+					text += fmt.Sprintf("\n\nОтмечать: /checklist %s", run.Title)
+				}
+			}
 
-	s.sendFloatingReminderTo(s.cfg.OwnerTelegramID)
-	if s.cfg.PartnerTelegramID != 0 {
-		s.sendFloatingReminderTo(s.cfg.PartnerTelegramID)
-	}
+			key := fmt.Sprintf("event:%d:%s", e.ID, currentTime.Format("2006-01-02"))
+			if err := s.notify(user, key, text, 0, false); err != nil {
+				log.Printf("Error sending event reminder for event %d to user %d: %v", e.ID, user.TelegramID, err)
+			}
+		}
+	})
 }
 
-func (s *Scheduler) sendFloatingReminderTo(telegramID int64) {
-	user, err := s.storage.GetUserByTelegramID(telegramID)
-	if err != nil || user == nil {
+// sendFloatingReminderTo reminds on Fridays to pick days for floating events
+func (s *Scheduler) sendFloatingReminderTo(user *domain.User, local time.Time) {
+	if s.scheduleService == nil {
 		return
 	}
 
@@ -442,7 +647,7 @@ func (s *Scheduler) sendFloatingReminderTo(telegramID int64) {
 	// Filter only unconfirmed events for this week
 	var unconfirmed []*domain.WeeklyEvent
 	for _, e := range events {
-		if !e.IsConfirmedThisWeek() {
+		if !e.IsConfirmedThisWeek(s.clock.Now()) {
 			unconfirmed = append(unconfirmed, e)
 		}
 	}
@@ -465,23 +670,39 @@ func (s *Scheduler) sendFloatingReminderTo(telegramID int64) {
 
 	sb.WriteString("\nВыбери день: /floating")
 
-	if err := s.sender.SendMessage(telegramID, sb.String()); err != nil {
-		log.Printf("Error sending floating reminder to %d: %v", telegramID, err)
+	if err := s.notify(user, "floating:"+local.Format("2006-01-02"), sb.String(), 0, false); err != nil {
+		log.Printf("Error sending floating reminder to %d: %v", user.TelegramID, err)
 	}
 }
 
 // checkRepeatingTasks sends reminders for repeating tasks at specified time
 func (s *Scheduler) checkRepeatingTasks() {
-	if s.sender == nil || s.storage == nil {
-		return
+	s.runCatchUp(jobRepeatingTasks, s.catchUpRepeatingTasks)
+}
+
+// catchUpRepeatingTasks sends repeating task reminders for every minute since the job's watermark
+func (s *Scheduler) catchUpRepeatingTasks(now time.Time, missed *missedDigest) {
+	// repeat_time задан в часовом поясе владельца задачи: проверяем каждый пояс семьи
+	zones := map[string]*time.Location{s.cfg.Timezone.String(): s.cfg.Timezone}
+	for _, user := range s.familyUsers() {
+		_, local := s.userSettings(user.ID, now)
+		zones[local.Location().String()] = local.Location()
 	}
 
-	currentTime := time.Now().In(s.cfg.Timezone)
+	s.catchUp(jobRepeatingTasks, now, func(minute time.Time) {
+		for zone, loc := range zones {
+			s.checkRepeatingTasksAt(zone, minute.In(loc), missed)
+		}
+	})
+}
+
+// checkRepeatingTasksAt sends reminders for repeating tasks of users whose timezone is zone
+func (s *Scheduler) checkRepeatingTasksAt(zone string, currentTime time.Time, missed *missedDigest) {
 	currentTimeStr := currentTime.Format("15:04")
 	currentWeekday := currentTime.Weekday()
 
 	// Get all repeating tasks with this time
-	tasks, err := s.storage.ListRepeatingTasksByTime(currentTimeStr)
+	tasks, err := s.storage.ListRepeatingTasksByTime(currentTimeStr, currentTime)
 	if err != nil {
 		log.Printf("Error getting repeating tasks: %v", err)
 		return
@@ -504,6 +725,10 @@ func (s *Scheduler) checkRepeatingTasks() {
 			if task.DueDate != nil && task.DueDate.Day() != currentTime.Day() {
 				continue
 			}
+		case domain.RepeatRule:
+			if !task.OccursOn(currentTime) {
+				continue
+			}
 		}
 
 		// Get the user
@@ -511,12 +736,21 @@ func (s *Scheduler) checkRepeatingTasks() {
 		if err != nil || user == nil {
 			continue
 		}
+		if _, local := s.userSettings(user.ID, currentTime); local.Location().String() != zone {
+			continue
+		}
+
+		if missed.tooLate(currentTime) {
+			missed.add(user, fmt.Sprintf("🔁 %s #%d %s", currentTime.Format("02.01 15:04"), task.ID, task.Title))
+			continue
+		}
 
 		// Send reminder with snooze buttons
 		text := fmt.Sprintf("🔁 <b>%s</b>\n\n%s #%d %s",
 			currentTimeStr, task.PriorityEmoji(), task.ID, task.Title)
 
-		if err := s.sender.SendMessageWithSnooze(user.TelegramID, text, task.ID); err != nil {
+		key := fmt.Sprintf("repeat:%d:%s", task.ID, currentTime.Format("2006-01-02T15:04"))
+		if err := s.notify(user, key, text, task.ID, task.Priority == domain.PriorityUrgent); err != nil {
 			log.Printf("Error sending repeating task reminder for task %d: %v", task.ID, err)
 		}
 	}
@@ -535,26 +769,9 @@ func (s *Scheduler) checkUrgentTasks() {
 	}
 
 	for _, task := range tasks {
-		// Get the user to send reminder to
-		var telegramID int64
-
-		// First try assigned user
-		if task.AssignedTo != nil {
-			user, err := s.storage.GetUserByID(*task.AssignedTo)
-			if err == nil && user != nil {
-				telegramID = user.TelegramID
-			}
-		}
-
-		// Fall back to creator
-		if telegramID == 0 {
-			user, err := s.storage.GetUserByID(task.UserID)
-			if err == nil && user != nil {
-				telegramID = user.TelegramID
-			}
-		}
-
-		if telegramID == 0 {
+		// Get the user to send reminder to: assignee first, then creator
+		user := s.taskRecipient(task)
+		if user == nil {
 			continue
 		}
 
@@ -563,8 +780,9 @@ func (s *Scheduler) checkUrgentTasks() {
 		text := fmt.Sprintf("🔴 <b>Напоминание #%d</b>\n\nЗадача ждёт:\n<b>#%d</b> %s",
 			reminderNum, task.ID, task.Title)
 
-		if err := s.sender.SendMessageWithSnooze(telegramID, text, task.ID); err != nil {
-			log.Printf("Error sending urgent task reminder for task %d to %d: %v", task.ID, telegramID, err)
+		key := fmt.Sprintf("urgent:%d:%s", task.ID, s.clock.Now().UTC().Format("2006-01-02T15"))
+		if err := s.notify(user, key, text, task.ID, true); err != nil {
+			log.Printf("Error sending urgent task reminder for task %d to %d: %v", task.ID, user.TelegramID, err)
 			continue
 		}
 
@@ -575,8 +793,8 @@ func (s *Scheduler) checkUrgentTasks() {
 	}
 }
 
-// parseTime parses "HH:MM" string to time.Time (today)
-func parseTime(timeStr string) (time.Time, error) {
+// parseTime parses "HH:MM" string to time.Time on the day of now
+func parseTime(timeStr string, now time.Time) (time.Time, error) {
 	parts := strings.Split(timeStr, ":")
 	if len(parts) != 2 {
 		return time.Time{}, fmt.Errorf("invalid time format")
@@ -587,35 +805,39 @@ func parseTime(timeStr string) (time.Time, error) {
 	fmt.Sscanf(parts[0], "%d", &hour)
 	fmt.Sscanf(parts[1], "%d", &min)
 
-	now := time.Now()
 	return time.Date(now.Year(), now.Month(), now.Day(), hour, min, 0, 0, now.Location()), nil
 }
 
-// timeToCron converts "HH:MM" to cron spec "MM HH * * *"
-func timeToCron(timeStr string) string {
-	parts := strings.Split(timeStr, ":")
-	if len(parts) != 2 {
-		return "0 9 * * *" // default 9:00
-	}
-	// Remove leading zeros for cron compatibility
-	hour := strings.TrimLeft(parts[0], "0")
-	if hour == "" {
-		hour = "0"
+// taskRecipient returns the assignee of the task or its creator
+func (s *Scheduler) taskRecipient(task *domain.Task) *domain.User {
+	if task.AssignedTo != nil {
+		user, err := s.storage.GetUserByID(*task.AssignedTo)
+		if err == nil && user != nil {
+			return user
+		}
 	}
-	minute := strings.TrimLeft(parts[1], "0")
-	if minute == "" {
-		minute = "0"
+	user, err := s.storage.GetUserByID(task.UserID)
+	if err != nil {
+		return nil
 	}
-	return fmt.Sprintf("%s %s * * *", minute, hour)
+	return user
 }
 
 // checkTaskReminders sends reminders for tasks based on due_date - remind_before
 func (s *Scheduler) checkTaskReminders() {
-	if s.sender == nil || s.storage == nil {
-		return
+	s.runCatchUp(jobTaskReminders, s.catchUpTaskReminders)
+}
+
+// catchUpTaskReminders sends due task reminders. Pending reminders are kept in task_reminders
+// (sent_at), so the watermark is only used to tell reminders missed while the bot was down
+// from ones that were created after their time had already passed.
+func (s *Scheduler) catchUpTaskReminders(now time.Time, missed *missedDigest) {
+	last, err := s.storage.GetJobWatermark(jobTaskReminders)
+	if err != nil {
+		log.Printf("Error getting %s watermark: %v", jobTaskReminders, err)
 	}
 
-	reminders, tasks, err := s.storage.GetPendingTaskReminders()
+	reminders, tasks, err := s.storage.GetPendingTaskReminders(now)
 	if err != nil {
 		log.Printf("Error getting pending task reminders: %v", err)
 		return
@@ -624,26 +846,19 @@ func (s *Scheduler) checkTaskReminders() {
 	for i, r := range reminders {
 		task := tasks[i]
 
-		// Get the user to send reminder to
-		var telegramID int64
-
-		// First try assigned user
-		if task.AssignedTo != nil {
-			user, err := s.storage.GetUserByID(*task.AssignedTo)
-			if err == nil && user != nil {
-				telegramID = user.TelegramID
-			}
+		// Get the user to send reminder to: assignee first, then creator
+		user := s.taskRecipient(task)
+		if user == nil {
+			continue
 		}
 
-		// Fall back to creator
-		if telegramID == 0 {
-			user, err := s.storage.GetUserByID(task.UserID)
-			if err == nil && user != nil {
-				telegramID = user.TelegramID
+		remindAt := task.DueDate.Add(-time.Duration(r.RemindBefore) * time.Minute)
+		if last != nil && remindAt.After(*last) && missed.tooLate(remindAt) {
+			_, local := s.userSettings(user.ID, *task.DueDate)
+			missed.add(user, fmt.Sprintf("⏰ #%d %s (дедлайн %s)", task.ID, task.Title, local.Format("02.01 15:04")))
+			if err := s.storage.MarkTaskReminderSent(r.ID); err != nil {
+				log.Printf("Error marking task reminder %d as sent: %v", r.ID, err)
 			}
-		}
-
-		if telegramID == 0 {
 			continue
 		}
 
@@ -651,13 +866,15 @@ func (s *Scheduler) checkTaskReminders() {
 		intervalLabel := domain.RemindBeforeLabel(r.RemindBefore)
 		dueStr := ""
 		if task.DueDate != nil {
-			dueStr = task.DueDate.In(s.cfg.Timezone).Format("02.01 15:04")
+			_, local := s.userSettings(user.ID, *task.DueDate)
+			dueStr = local.Format("02.01 15:04")
 		}
 
 		text := fmt.Sprintf("⏰ <b>Напоминание %s</b>\n\n%s <b>#%d</b> %s\n\n📅 Дедлайн: %s",
 			intervalLabel, task.PriorityEmoji(), task.ID, task.Title, dueStr)
 
-		if err := s.sender.SendMessageWithSnooze(telegramID, text, task.ID); err != nil {
+		key := fmt.Sprintf("task_reminder:%d", r.ID)
+		if err := s.notify(user, key, text, task.ID, task.Priority == domain.PriorityUrgent); err != nil {
 			log.Printf("Error sending task reminder %d for task %d: %v", r.ID, task.ID, err)
 			continue
 		}
@@ -667,6 +884,10 @@ func (s *Scheduler) checkTaskReminders() {
 			log.Printf("Error marking task reminder %d as sent: %v", r.ID, err)
 		}
 	}
+
+	if err := s.storage.SetJobWatermark(jobTaskReminders, now); err != nil {
+		log.Printf("Error saving %s watermark: %v", jobTaskReminders, err)
+	}
 }
 
 // formatMoney formats a number with space as thousands separator (Russian style)
@@ -699,7 +920,8 @@ func (s *Scheduler) checkDebtPaymentsTomorrow() {
 		return
 	}
 
-	tomorrow := time.Now().In(s.cfg.Timezone).AddDate(0, 0, 1).Day()
+	tomorrowDate := s.clock.Now().In(s.cfg.Timezone).AddDate(0, 0, 1)
+	tomorrow := tomorrowDate.Day()
 
 	debts, err := s.debtClient.GetDebtsForDay(tomorrow)
 	if err != nil {
@@ -722,8 +944,8 @@ func (s *Scheduler) checkDebtPaymentsTomorrow() {
 
 	sb.WriteString("/debts — все долги")
 
-	// Send only to owner (not partner)
-	if err := s.sender.SendMessage(s.cfg.OwnerTelegramID, sb.String()); err != nil {
+	// Финансы видят только взрослые семьи
+	if err := s.notifyFinance("debts:"+tomorrowDate.Format("2006-01-02"), sb.String()); err != nil {
 		log.Printf("Error sending debt payment reminder: %v", err)
 	}
 }
@@ -734,7 +956,8 @@ func (s *Scheduler) checkPayday() {
 		return
 	}
 
-	today := time.Now().In(s.cfg.Timezone).Day()
+	todayDate := s.clock.Now().In(s.cfg.Timezone)
+	today := todayDate.Day()
 
 	isPayday, incomes, err := s.debtClient.IsPayday(today)
 	if err != nil {
@@ -788,55 +1011,119 @@ func (s *Scheduler) checkPayday() {
 
 	sb.WriteString("\n/debts — подробнее")
 
-	// Send only to owner
-	if err := s.sender.SendMessage(s.cfg.OwnerTelegramID, sb.String()); err != nil {
+	// Финансы видят только взрослые семьи
+	if err := s.notifyFinance("payday:"+todayDate.Format("2006-01-02"), sb.String()); err != nil {
 		log.Printf("Error sending payday summary: %v", err)
 	}
 }
 
-// ============== Apple Calendar ==============
+// ============== Autos ==============
+
+// checkAutoReminders sends reminders about insurance, maintenance, documents and maintenance rules
+func (s *Scheduler) checkAutoReminders() {
+	if s.sender == nil || s.autoService == nil {
+		return
+	}
+
+	alerts, err := s.autoService.Alerts()
+	if err != nil {
+		log.Printf("Error getting auto alerts: %v", err)
+		return
+	}
+
+	users := make(map[int64]*domain.User)
+	for _, a := range alerts {
+		user, ok := users[a.UserID]
+		if !ok {
+			user, err = s.storage.GetUser(a.UserID)
+			if err != nil {
+				log.Printf("Error getting user %d: %v", a.UserID, err)
+			}
+			users[a.UserID] = user
+		}
+		if user == nil {
+			continue
+		}
+		if err := s.notify(user, a.Key, a.Text+"\n\n/auto — карточка машины", 0, false); err != nil {
+			log.Printf("Error sending auto reminder: %v", err)
+		}
+	}
+}
+
+// ============== Calendars (CalDAV) ==============
 
-// syncAppleCalendar syncs events with Apple Calendar (runs hourly)
-func (s *Scheduler) syncAppleCalendar() {
-	if s.calendarService == nil || !s.calendarService.IsConfigured() {
+// syncCalendars syncs events with the linked calendars (runs hourly)
+func (s *Scheduler) syncCalendars() {
+	if s.calendarService == nil {
 		return
 	}
 
-	// Sync FROM Apple (calendar events)
-	result, err := s.calendarService.SyncFromApple()
+	// Sync FROM the calendars (calendar events)
+	result, err := s.calendarService.SyncAll()
 	if err != nil {
-		log.Printf("Apple Calendar sync error: %v", err)
+		log.Printf("Calendar sync error: %v", err)
 		return
 	}
 
 	if result.Added > 0 || result.Updated > 0 || result.Deleted > 0 {
-		log.Printf("Apple Calendar sync from Apple: added=%d, updated=%d, deleted=%d",
+		log.Printf("Calendar sync from calendars: added=%d, updated=%d, deleted=%d",
 			result.Added, result.Updated, result.Deleted)
 	}
+	for _, e := range result.Errors {
+		log.Printf("Calendar sync error: %s", e)
+	}
+	s.notifyCalendarConflicts(result.Conflicts)
 
-	// Sync TO Apple (weekly schedule events)
+	// Sync TO the calendars (weekly schedule events)
 	if s.scheduleService != nil && s.storage != nil {
-		user, _ := s.storage.GetUserByTelegramID(s.cfg.OwnerTelegramID)
-		if user != nil {
-			events, err := s.scheduleService.List(user.ID, true)
-			if err == nil {
-				synced := 0
-				for _, e := range events {
-					var floatingDays []int
-					if e.IsFloating {
-						for _, d := range e.GetFloatingDays() {
-							floatingDays = append(floatingDays, int(d))
-						}
-					}
-					if err := s.calendarService.SyncWeeklyEventToCalendar(e.ID, int(e.DayOfWeek), e.TimeStart, e.TimeEnd, e.Title, e.IsFloating, floatingDays); err == nil {
-						synced++
+		synced := 0
+		// Только свои события: событие уходит в календарь автора, общие придут с ним
+		for _, user := range s.familyUsers() {
+			events, err := s.scheduleService.List(user.ID, false)
+			if err != nil {
+				log.Printf("Error listing weekly events for %s: %v", user.Name, err)
+				continue
+			}
+			for _, e := range events {
+				var floatingDays []int
+				if e.IsFloating {
+					for _, d := range e.GetFloatingDays() {
+						floatingDays = append(floatingDays, int(d))
 					}
 				}
-				if synced > 0 {
-					log.Printf("Apple Calendar sync to Apple: schedule=%d", synced)
+				if err := s.calendarService.SyncWeeklyEventToCalendar(e.ID, int(e.DayOfWeek), e.TimeStart, e.TimeEnd, e.Title, e.IsFloating, floatingDays); err == nil {
+					synced++
 				}
 			}
 		}
+		if synced > 0 {
+			log.Printf("Calendar sync to calendars: schedule=%d", synced)
+		}
+	}
+}
+
+// notifyCalendarConflicts asks the owners of the conflicting events which version to keep
+func (s *Scheduler) notifyCalendarConflicts(conflicts []*domain.CalendarConflict) {
+	if s.storage == nil {
+		return
+	}
+	for _, c := range conflicts {
+		user, err := s.storage.GetUser(c.UserID)
+		if err != nil || user == nil {
+			log.Printf("Calendar conflict %d: owner not found: %v", c.ID, err)
+			continue
+		}
+		conflictID := c.ID
+		n := &domain.Notification{
+			Key:        fmt.Sprintf("calendar-conflict:%d:%d", c.ID, user.TelegramID),
+			UserID:     &user.ID,
+			ChatID:     user.TelegramID,
+			Text:       s.calendarService.FormatConflict(c),
+			ConflictID: &conflictID,
+		}
+		if err := s.enqueue(n); err != nil {
+			log.Printf("Error sending calendar conflict: %v", err)
+		}
 	}
 }
 
@@ -860,7 +1147,7 @@ func (s *Scheduler) checkCalendarEventReminders() {
 		}
 
 		// Calculate minutes until event
-		minutesUntil := int(time.Until(e.StartTime).Minutes())
+		minutesUntil := int(e.StartTime.Sub(s.clock.Now()).Minutes())
 
 		// Only send reminder once at ~30 minutes mark (between 28-32 minutes)
 		if minutesUntil < 28 || minutesUntil > 32 {
@@ -873,8 +1160,9 @@ func (s *Scheduler) checkCalendarEventReminders() {
 			continue
 		}
 
-		// Format time in configured timezone
-		localTime := e.StartTime.In(s.cfg.Timezone).Format("15:04")
+		// Format time in the user's timezone
+		_, start := s.userSettings(user.ID, e.StartTime)
+		localTime := start.Format("15:04")
 
 		// Format reminder text
 		text := fmt.Sprintf("⏰ <b>Через 30 мин</b> — %s (%s)", e.Title, localTime)
@@ -884,14 +1172,17 @@ func (s *Scheduler) checkCalendarEventReminders() {
 		}
 
 		// Send to owner
-		if err := s.sender.SendMessage(user.TelegramID, text); err != nil {
+		key := fmt.Sprintf("calendar:%d:%d", e.ID, e.StartTime.Unix())
+		if err := s.notify(user, key, text, 0, false); err != nil {
 			log.Printf("Error sending calendar reminder for event %d: %v", e.ID, err)
 		}
 
-		// Also send to partner if event is shared
-		if e.IsShared && s.cfg.PartnerTelegramID != 0 && s.cfg.PartnerTelegramID != user.TelegramID {
-			if err := s.sender.SendMessage(s.cfg.PartnerTelegramID, text); err != nil {
-				log.Printf("Error sending calendar reminder to partner for event %d: %v", e.ID, err)
+		// Also send to the rest of the family if event is shared
+		if e.IsShared {
+			for _, mate := range s.householdMates(user.ID) {
+				if err := s.notify(mate, key, text, 0, false); err != nil {
+					log.Printf("Error sending calendar reminder to %d for event %d: %v", mate.TelegramID, e.ID, err)
+				}
 			}
 		}
 	}
@@ -899,33 +1190,20 @@ func (s *Scheduler) checkCalendarEventReminders() {
 
 // ============== Trackable Schedule Events ==============
 
-// createTrackableEventTasks creates tasks from trackable schedule events for today
-func (s *Scheduler) createTrackableEventTasks() {
-	if s.scheduleService == nil || s.taskService == nil || s.storage == nil {
-		return
-	}
-
-	s.createTrackableTasksForUser(s.cfg.OwnerTelegramID)
-	if s.cfg.PartnerTelegramID != 0 {
-		s.createTrackableTasksForUser(s.cfg.PartnerTelegramID)
-	}
-}
-
-func (s *Scheduler) createTrackableTasksForUser(telegramID int64) {
-	user, err := s.storage.GetUserByTelegramID(telegramID)
-	if err != nil || user == nil {
+// createTrackableTasksForUser creates tasks from the user's trackable schedule events for today
+func (s *Scheduler) createTrackableTasksForUser(user *domain.User, todayDate time.Time) {
+	if s.scheduleService == nil || s.taskService == nil {
 		return
 	}
 
 	// Get trackable events for today
-	today := domain.Weekday(time.Now().In(s.cfg.Timezone).Weekday())
+	today := domain.Weekday(todayDate.Weekday())
 	events, err := s.scheduleService.ListForDay(user.ID, today, false) // own events only
 	if err != nil {
 		log.Printf("Error getting schedule events for user %d: %v", user.ID, err)
 		return
 	}
 
-	todayDate := time.Now().In(s.cfg.Timezone)
 	todayStart := time.Date(todayDate.Year(), todayDate.Month(), todayDate.Day(), 0, 0, 0, 0, todayDate.Location())
 
 	for _, e := range events {
@@ -936,7 +1214,7 @@ func (s *Scheduler) createTrackableTasksForUser(telegramID int64) {
 
 		// Skip floating events not confirmed for today
 		if e.IsFloating {
-			if !e.IsConfirmedThisWeek() || e.ConfirmedDay == nil || domain.Weekday(*e.ConfirmedDay) != today {
+			if !e.IsConfirmedThisWeek(s.clock.Now()) || e.ConfirmedDay == nil || domain.Weekday(*e.ConfirmedDay) != today {
 				continue
 			}
 		}
@@ -956,7 +1234,7 @@ func (s *Scheduler) createTrackableTasksForUser(telegramID int64) {
 		dueDate := todayStart
 		// If event has time, use that time for due date
 		if e.TimeStart != "" {
-			if t, err := parseTime(e.TimeStart); err == nil {
+			if t, err := parseTime(e.TimeStart, todayDate); err == nil {
 				dueDate = time.Date(todayDate.Year(), todayDate.Month(), todayDate.Day(), t.Hour(), t.Minute(), 0, 0, todayDate.Location())
 			}
 		}
@@ -1011,7 +1289,8 @@ func (s *Scheduler) sendDailyQuote() {
 		return
 	}
 
-	quote := domain.GetDailyQuote()
+	quote := domain.GetDailyQuote(s.clock.Now())
+	key := "quote:" + s.clock.Now().In(s.cfg.Timezone).Format("2006-01-02")
 
 	var message string
 	if quote.Author != "" {
@@ -1022,19 +1301,20 @@ func (s *Scheduler) sendDailyQuote() {
 
 	// Send to group chat if configured, otherwise send to individuals
 	if s.cfg.GroupChatID != 0 {
-		if err := s.sender.SendMessage(s.cfg.GroupChatID, message); err != nil {
+		n := &domain.Notification{
+			Key:    fmt.Sprintf("%s:%d", key, s.cfg.GroupChatID),
+			ChatID: s.cfg.GroupChatID,
+			Text:   message,
+		}
+		if err := s.enqueue(n); err != nil {
 			log.Printf("Error sending daily quote to group chat: %v", err)
 		}
 		log.Printf("Daily quote sent to group: %s", quote.Text[:50])
 	} else {
 		// Fallback to individual messages
-		if err := s.sender.SendMessage(s.cfg.OwnerTelegramID, message); err != nil {
-			log.Printf("Error sending daily quote to owner: %v", err)
-		}
-
-		if s.cfg.PartnerTelegramID != 0 {
-			if err := s.sender.SendMessage(s.cfg.PartnerTelegramID, message); err != nil {
-				log.Printf("Error sending daily quote to partner: %v", err)
+		for _, user := range s.familyUsers() {
+			if err := s.notify(user, key, message, 0, false); err != nil {
+				log.Printf("Error sending daily quote to %s: %v", user.Name, err)
 			}
 		}
 		log.Printf("Daily quote sent to individuals: %s", quote.Text[:50])
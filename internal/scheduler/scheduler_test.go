@@ -0,0 +1,357 @@
+package scheduler_test
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/tazhate/familybot/config"
+	"github.com/tazhate/familybot/internal/clock"
+	"github.com/tazhate/familybot/internal/domain"
+	"github.com/tazhate/familybot/internal/scheduler"
+	"github.com/tazhate/familybot/internal/scheduler/schedulertest"
+	"github.com/tazhate/familybot/internal/service"
+	"github.com/tazhate/familybot/internal/storage"
+	"github.com/tazhate/familybot/internal/storage/storagetest"
+)
+
+const (
+	ownerTelegramID   = 100
+	partnerTelegramID = 200
+)
+
+var moscow = mustLoadLocation("Europe/Moscow")
+
+func mustLoadLocation(name string) *time.Location {
+	loc, err := time.LoadLocation(name)
+	if err != nil {
+		panic(err)
+	}
+	return loc
+}
+
+// simulation drives the scheduler minute by minute with a fake clock, a real SQLite
+// database and a sender that records messages instead of sending them
+type simulation struct {
+	t      *testing.T
+	store  *storage.Storage
+	clock  *clock.Fake
+	sender *schedulertest.RecordingSender
+	sched  *scheduler.Scheduler
+
+	tasks     *service.TaskService
+	reminders *service.ReminderService
+	persons   *service.PersonService
+	schedule  *service.ScheduleService
+	settings  *service.SettingsService
+
+	owner, partner *domain.User
+	sent           []sentMessage
+}
+
+// sentMessage is a recorded message and the minute the dispatcher sent it at
+type sentMessage struct {
+	At time.Time
+	schedulertest.Message
+}
+
+func newSimulation(t *testing.T, start time.Time) *simulation {
+	t.Helper()
+	store := storagetest.SQLite(t)
+	clk := clock.NewFake(start)
+
+	cfg := &config.Config{
+		OwnerTelegramID:   ownerTelegramID,
+		PartnerTelegramID: partnerTelegramID,
+		Timezone:          moscow,
+		MorningTime:       "09:00",
+		EveningTime:       "21:00",
+		MaxLateness:       30 * time.Minute,
+	}
+
+	sim := &simulation{t: t, store: store, clock: clk, sender: schedulertest.NewRecordingSender()}
+	sim.tasks = service.NewTaskService(store)
+	sim.tasks.SetClock(clk)
+	sim.reminders = service.NewReminderService(store, moscow)
+	sim.reminders.SetClock(clk)
+	sim.persons = service.NewPersonService(store)
+	sim.persons.SetClock(clk)
+	sim.persons.SetReminderService(sim.reminders)
+	sim.schedule = service.NewScheduleService(store)
+	sim.schedule.SetClock(clk)
+	sim.settings = service.NewSettingsService(store, moscow, cfg.MorningTime, cfg.EveningTime)
+	sim.settings.SetClock(clk)
+	checklists := service.NewChecklistService(store, moscow)
+	checklists.SetClock(clk)
+	calendars := service.NewCalendarService(store, moscow)
+	calendars.SetClock(clk)
+	autos := service.NewAutoService(store, moscow)
+	autos.SetClock(clk)
+
+	sim.owner = sim.createUser(ownerTelegramID, "Алекс")
+	sim.partner = sim.createUser(partnerTelegramID, "Саша")
+	if err := service.NewHouseholdService(store).Bootstrap(ownerTelegramID, partnerTelegramID); err != nil {
+		t.Fatalf("bootstrap household: %v", err)
+	}
+
+	sim.sched = scheduler.New(cfg, store, sim.tasks, sim.reminders, sim.persons, sim.schedule, checklists, calendars, nil, sim.settings, autos, nil)
+	sim.sched.SetSender(sim.sender)
+	sim.sched.SetClock(clk)
+	return sim
+}
+
+func (sim *simulation) createUser(telegramID int64, name string) *domain.User {
+	sim.t.Helper()
+	u := &domain.User{TelegramID: telegramID, Name: name, Role: domain.RoleOwner}
+	if err := sim.store.CreateUser(u); err != nil {
+		sim.t.Fatalf("create user %s: %v", name, err)
+	}
+	return u
+}
+
+// run ticks the scheduler every minute from the clock's time up to end (exclusive)
+func (sim *simulation) run(end time.Time) {
+	for now := sim.clock.Now(); now.Before(end); now = now.Add(time.Minute) {
+		sim.clock.Set(now)
+		sim.sched.Tick()
+		for _, m := range sim.sender.Messages()[len(sim.sent):] {
+			sim.sent = append(sim.sent, sentMessage{At: now, Message: m})
+		}
+	}
+	sim.clock.Set(end)
+}
+
+// expectation is a message the simulation must send: at the local time to the chat
+type expectation struct {
+	at       string // "15:04" по Москве
+	chatID   int64
+	contains string
+}
+
+// check matches every sent message to exactly one expectation
+func (sim *simulation) check(want []expectation) {
+	sim.t.Helper()
+	used := make([]bool, len(sim.sent))
+	for _, w := range want {
+		found := false
+		for i, m := range sim.sent {
+			if used[i] || m.ChatID != w.chatID || m.At.In(moscow).Format("15:04") != w.at || !strings.Contains(m.Text, w.contains) {
+				continue
+			}
+			used[i] = true
+			found = true
+			break
+		}
+		if !found {
+			sim.t.Errorf("missing message at %s to %d containing %q", w.at, w.chatID, w.contains)
+		}
+	}
+	for i, m := range sim.sent {
+		if !used[i] {
+			sim.t.Errorf("unexpected message at %s to %d: %q", m.At.In(moscow).Format("15:04"), m.ChatID, firstLine(m.Text))
+		}
+	}
+}
+
+func (sim *simulation) outbox(status domain.NotificationStatus) []*domain.Notification {
+	sim.t.Helper()
+	notifications, err := sim.store.ListNotifications(status, 1000)
+	if err != nil {
+		sim.t.Fatalf("list %s notifications: %v", status, err)
+	}
+	return notifications
+}
+
+func firstLine(text string) string {
+	line, _, _ := strings.Cut(text, "\n")
+	return line
+}
+
+func at(day time.Time, hour, min int) time.Time {
+	return time.Date(day.Year(), day.Month(), day.Day(), hour, min, 0, 0, moscow)
+}
+
+// TestSimulatedDay runs a whole Tuesday and checks every briefing, reminder and event
+// message the family gets, and when the dispatcher delivered it
+func TestSimulatedDay(t *testing.T) {
+	tuesday := time.Date(2030, time.June, 4, 0, 0, 0, 0, moscow)
+	sim := newSimulation(t, tuesday)
+	owner, partner := sim.owner, sim.partner
+
+	// Личные настройки: у второго участника ранний брифинг и тихие часы в обед
+	if _, err := sim.settings.SetMorningTime(partner.ID, "07:30"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := sim.settings.SetQuietHours(partner.ID, "11:00-13:00"); err != nil {
+		t.Fatal(err)
+	}
+
+	// Срочная задача: повторные напоминания не чаще раза в 2 часа, не больше трёх
+	urgent, err := sim.tasks.Create(owner.ID, ownerTelegramID, "Оплатить садик", domain.PriorityUrgent)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := sim.reminders.Create(owner.ID, "Выпить витамины", domain.ReminderDaily, domain.ReminderParams{Time: "08:00"}); err != nil {
+		t.Fatal(err)
+	}
+
+	// День рождения завтра: в брифинге и напоминание «завтра ДР» в 11:00
+	birthday := time.Date(1950, time.June, 5, 0, 0, 0, 0, moscow)
+	if _, err := sim.persons.Create(owner.ID, "Бабушка", domain.RoleFamily, &birthday, ""); err != nil {
+		t.Fatal(err)
+	}
+
+	// Задача со сроком в 15:00 и напоминанием за час
+	due := at(tuesday, 15, 0)
+	parcel, err := sim.tasks.CreateFull(owner.ID, ownerTelegramID, "Забрать посылку", domain.PriorityWeek, nil, &due)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := sim.store.CreateTaskReminder(&domain.TaskReminder{TaskID: parcel.ID, RemindBefore: domain.RemindHour}); err != nil {
+		t.Fatal(err)
+	}
+
+	// Общее событие календаря в 16:00: напоминание за 30 минут обоим
+	if err := sim.store.CreateCalendarEvent(&domain.CalendarEvent{
+		UserID:    owner.ID,
+		CalDAVUID: "dentist@example.com",
+		Title:     "Стоматолог",
+		Location:  "Клиника",
+		StartTime: at(tuesday, 16, 0),
+		EndTime:   at(tuesday, 17, 0),
+		IsShared:  true,
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	// Недельное расписание: вторник сегодня, среда — завтра (напоминание не должно прийти)
+	if _, err := sim.schedule.Create(owner.ID, domain.WeekdayTuesday, "18:00", "19:00", "Тренировка", 30); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := sim.schedule.Create(owner.ID, domain.WeekdayWednesday, "08:00", "", "Бассейн", 60); err != nil {
+		t.Fatal(err)
+	}
+
+	water, err := sim.tasks.CreateRepeating(partner.ID, partnerTelegramID, "Полить цветы", domain.PriorityWeek, nil, nil, domain.RepeatDaily, "20:00")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	sim.run(tuesday.AddDate(0, 0, 1))
+
+	sim.check([]expectation{
+		{"00:00", ownerTelegramID, fmt.Sprintf("Напоминание #1</b>\n\nЗадача ждёт:\n<b>#%d</b> Оплатить садик", urgent.ID)},
+		{"03:00", ownerTelegramID, "Напоминание #2"},
+		{"06:00", ownerTelegramID, "Напоминание #3"},
+		{"07:30", partnerTelegramID, "Доброе утро"},
+		{"08:00", ownerTelegramID, "Выпить витамины"},
+		{"09:00", ownerTelegramID, "Завтра — Бабушка (80 лет)"},
+		{"11:00", ownerTelegramID, "Завтра ДР: Бабушка (80 лет)"},
+		{"12:00", ownerTelegramID, "Цитата дня"},
+		{"13:00", partnerTelegramID, "Цитата дня"}, // после тихих часов
+		{"14:00", ownerTelegramID, fmt.Sprintf("Напоминание за час</b>\n\n🟡 <b>#%d</b> Забрать посылку", parcel.ID)},
+		{"15:30", ownerTelegramID, "Через 30 мин</b> — Стоматолог (16:00)\n📍 Клиника"},
+		{"15:30", partnerTelegramID, "Через 30 мин</b> — Стоматолог (16:00)"},
+		{"17:30", ownerTelegramID, "Через 30 мин</b> — Тренировка (18:00)"},
+		{"20:00", partnerTelegramID, "Полить цветы"},
+		{"21:00", ownerTelegramID, "Вечерний чекин"},
+		{"21:00", partnerTelegramID, "Вечерний чекин"},
+	})
+
+	for _, m := range sim.sent {
+		switch {
+		case strings.Contains(m.Text, "Полить цветы") && m.TaskID != water.ID:
+			t.Errorf("repeating task reminder without snooze buttons: task %d", m.TaskID)
+		case strings.Contains(m.Text, "Задача ждёт") && m.TaskID != urgent.ID:
+			t.Errorf("urgent reminder without snooze buttons: task %d", m.TaskID)
+		}
+	}
+	if pending := sim.outbox(domain.NotificationPending); len(pending) != 0 {
+		t.Errorf("outbox has %d undelivered notifications, first %q", len(pending), pending[0].Key)
+	}
+	if got := len(sim.outbox(domain.NotificationSent)); got != len(sim.sent) {
+		t.Errorf("outbox has %d sent notifications, sender got %d", got, len(sim.sent))
+	}
+
+	// Следующий день: ежедневное напоминание снова приходит, а срочная задача больше не напоминает
+	sim.sender.Reset()
+	sim.sent = nil
+	sim.run(at(tuesday.AddDate(0, 0, 1), 8, 1))
+	sim.check([]expectation{
+		{"07:00", ownerTelegramID, "Через 1 час</b> — Бассейн (08:00)"},
+		{"07:30", partnerTelegramID, "Доброе утро"},
+		{"08:00", ownerTelegramID, "Выпить витамины"},
+	})
+}
+
+// TestTelegramOutage checks that messages the sender failed to deliver are retried
+// with backoff and are not duplicated by the next scheduler runs
+func TestTelegramOutage(t *testing.T) {
+	tuesday := time.Date(2030, time.June, 4, 7, 55, 0, 0, moscow)
+	sim := newSimulation(t, tuesday)
+	if _, err := sim.reminders.Create(sim.owner.ID, "Выпить витамины", domain.ReminderDaily, domain.ReminderParams{Time: "08:00"}); err != nil {
+		t.Fatal(err)
+	}
+
+	sim.sender.SetError(errors.New("telegram: 502 Bad Gateway"))
+	sim.run(at(tuesday, 8, 2))
+	if len(sim.sent) != 0 {
+		t.Fatalf("sent %d messages during the outage", len(sim.sent))
+	}
+	pending := sim.outbox(domain.NotificationPending)
+	if len(pending) != 1 || pending[0].Attempts == 0 {
+		t.Fatalf("want one pending notification with failed attempts, got %+v", pending)
+	}
+
+	sim.sender.SetError(nil)
+	sim.run(at(tuesday, 8, 10))
+	sim.check([]expectation{
+		// 08:00 — ошибка, повтор через 30s; 08:01 — ошибка, повтор через 1m; 08:02 — доставлено
+		{"08:02", ownerTelegramID, "Выпить витамины"},
+	})
+}
+
+// TestPerUserSettings checks that briefings follow each user's timezone and times,
+// and that quiet hours across midnight hold back only non-urgent messages
+func TestPerUserSettings(t *testing.T) {
+	tuesday := time.Date(2030, time.June, 4, 0, 0, 0, 0, moscow)
+	sim := newSimulation(t, tuesday.Add(time.Minute))
+	owner, partner := sim.owner, sim.partner
+
+	// Второй участник в Берлине (летом на час позже Москвы), первый отключил вечерний чекин
+	for _, set := range []func() (*domain.UserSettings, error){
+		func() (*domain.UserSettings, error) { return sim.settings.SetTimezone(partner.ID, "Europe/Berlin") },
+		func() (*domain.UserSettings, error) { return sim.settings.SetEveningTime(partner.ID, "23:00") },
+		func() (*domain.UserSettings, error) { return sim.settings.SetQuietHours(partner.ID, "22:30-07:00") },
+		func() (*domain.UserSettings, error) { return sim.settings.SetEveningTime(owner.ID, "выкл") },
+	} {
+		if _, err := set(); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// Срочная задача напоминает и в тихие часы, обычное напоминание ждёт их конца
+	if _, err := sim.tasks.Create(partner.ID, partnerTelegramID, "Продлить визу", domain.PriorityUrgent); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := sim.reminders.Create(partner.ID, "Выключить утюг", domain.ReminderDaily, domain.ReminderParams{Time: "23:30"}); err != nil {
+		t.Fatal(err)
+	}
+
+	sim.run(at(tuesday.AddDate(0, 0, 1), 8, 1))
+	sim.check([]expectation{
+		{"01:00", partnerTelegramID, "Напоминание #1"}, // 00:00 в Берлине
+		{"04:00", partnerTelegramID, "Напоминание #2"},
+		{"07:00", partnerTelegramID, "Напоминание #3"},
+		{"09:00", ownerTelegramID, "Доброе утро"},
+		{"10:00", partnerTelegramID, "Доброе утро"}, // 09:00 в Берлине
+		{"12:00", ownerTelegramID, "Цитата дня"},
+		{"12:00", partnerTelegramID, "Цитата дня"},
+		// Чекин в выбранное самим пользователем время приходит и в тихие часы
+		{"00:00", partnerTelegramID, "Вечерний чекин"},
+		{"08:00", partnerTelegramID, "Выключить утюг"}, // 22:30 в Берлине — ждёт до 07:00
+	})
+}
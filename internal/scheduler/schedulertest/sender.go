@@ -0,0 +1,85 @@
+// Package schedulertest — вспомогательные типы для симуляций планировщика:
+// отправитель, который вместо Telegram запоминает сообщения.
+package schedulertest
+
+import (
+	"sync"
+
+	"github.com/tazhate/familybot/internal/scheduler"
+)
+
+var _ scheduler.MessageSender = (*RecordingSender)(nil)
+
+// Message is a message "sent" through RecordingSender
+type Message struct {
+	ChatID int64
+	Text   string
+	TaskID int64 // 0 — без кнопок отложить
+
+	ConflictID int64 // конфликт правок календаря, 0 — без кнопок выбора версии
+}
+
+// RecordingSender records messages in memory instead of sending them
+type RecordingSender struct {
+	mu       sync.Mutex
+	messages []Message
+	err      error
+}
+
+func NewRecordingSender() *RecordingSender {
+	return &RecordingSender{}
+}
+
+func (r *RecordingSender) SendMessage(chatID int64, text string) error {
+	return r.record(Message{ChatID: chatID, Text: text})
+}
+
+func (r *RecordingSender) SendMessageWithSnooze(chatID int64, text string, taskID int64) error {
+	return r.record(Message{ChatID: chatID, Text: text, TaskID: taskID})
+}
+
+func (r *RecordingSender) SendCalendarConflict(chatID int64, text string, conflictID int64) error {
+	return r.record(Message{ChatID: chatID, Text: text, ConflictID: conflictID})
+}
+
+func (r *RecordingSender) record(m Message) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.err != nil {
+		return r.err
+	}
+	r.messages = append(r.messages, m)
+	return nil
+}
+
+// SetError makes every send fail with err (nil restores delivery), e.g. to simulate a Telegram outage
+func (r *RecordingSender) SetError(err error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.err = err
+}
+
+// Messages returns a copy of the recorded messages in the order they were sent
+func (r *RecordingSender) Messages() []Message {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]Message(nil), r.messages...)
+}
+
+// MessagesTo returns the recorded messages for one chat
+func (r *RecordingSender) MessagesTo(chatID int64) []Message {
+	var messages []Message
+	for _, m := range r.Messages() {
+		if m.ChatID == chatID {
+			messages = append(messages, m)
+		}
+	}
+	return messages
+}
+
+// Reset forgets the recorded messages
+func (r *RecordingSender) Reset() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.messages = nil
+}
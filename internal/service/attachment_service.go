@@ -0,0 +1,203 @@
+package service
+
+import (
+	"fmt"
+	"html"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/tazhate/familybot/internal/clock"
+	"github.com/tazhate/familybot/internal/domain"
+	"github.com/tazhate/familybot/internal/storage"
+)
+
+// AttachmentService хранит ссылки на файлы в Telegram (file_id) и, если задана
+// папка, их копии на диске: <dir>/<owner_type>/<owner_id>/<file_unique_id><ext>.
+type AttachmentService struct {
+	storage storage.Store
+	blobDir string
+	clock   clock.Clock
+}
+
+func NewAttachmentService(s storage.Store) *AttachmentService {
+	return &AttachmentService{storage: s, clock: clock.Real()}
+}
+
+// SetClock replaces the system clock, e.g. to simulate days in tests
+func (s *AttachmentService) SetClock(c clock.Clock) {
+	s.clock = c
+}
+
+// SetBlobDir enables local copies of attached files
+func (s *AttachmentService) SetBlobDir(dir string) {
+	s.blobDir = dir
+}
+
+// MirrorEnabled reports whether file bytes are copied to the local directory
+func (s *AttachmentService) MirrorEnabled() bool {
+	return s.blobDir != ""
+}
+
+// CheckOwner checks that the task, person or auto exists and the user may attach files to it
+func (s *AttachmentService) CheckOwner(ownerType domain.AttachmentOwner, ownerID, userID, chatID int64) error {
+	switch ownerType {
+	case domain.AttachmentTask:
+		task, err := s.storage.GetTask(ownerID)
+		if err != nil {
+			return fmt.Errorf("get task: %w", err)
+		}
+		if task == nil {
+			return fmt.Errorf("задача #%d не найдена", ownerID)
+		}
+		if task.UserID == userID || task.ChatID == chatID || (task.AssignedTo != nil && *task.AssignedTo == userID) {
+			return nil
+		}
+		// Общая задача открыта только семье автора, как в списках задач
+		if task.IsShared {
+			same, err := sameHousehold(s.storage, task.UserID, userID)
+			if err != nil {
+				return err
+			}
+			if same {
+				return nil
+			}
+		}
+		return fmt.Errorf("нет доступа к задаче #%d", ownerID)
+	case domain.AttachmentPerson:
+		person, err := s.storage.GetPerson(ownerID)
+		if err != nil {
+			return fmt.Errorf("get person: %w", err)
+		}
+		if person == nil || person.UserID != userID {
+			return fmt.Errorf("человек не найден")
+		}
+	case domain.AttachmentAuto:
+		auto, err := s.storage.GetAuto(ownerID)
+		if err != nil {
+			return fmt.Errorf("get auto: %w", err)
+		}
+		if auto == nil || auto.UserID != userID {
+			return fmt.Errorf("машина не найдена")
+		}
+	default:
+		return fmt.Errorf("unknown attachment owner %q", ownerType)
+	}
+	return nil
+}
+
+// Attach saves the attachment; content, if given, is copied to the local directory.
+// A failed copy doesn't lose the attachment: the file stays available in Telegram.
+func (s *AttachmentService) Attach(a *domain.Attachment, content io.Reader) error {
+	if a.FileID == "" {
+		return fmt.Errorf("file_id is required")
+	}
+	a.CreatedAt = s.clock.Now()
+
+	if content != nil && s.blobDir != "" {
+		path, err := s.writeBlob(a, content)
+		if err != nil {
+			log.Printf("AttachmentService: local copy of %s failed: %v", a.FileUniqueID, err)
+		} else {
+			a.LocalPath = path
+		}
+	}
+
+	if err := s.storage.CreateAttachment(a); err != nil {
+		return fmt.Errorf("create attachment: %w", err)
+	}
+	return nil
+}
+
+func (s *AttachmentService) writeBlob(a *domain.Attachment, content io.Reader) (string, error) {
+	dir := filepath.Join(s.blobDir, string(a.OwnerType), strconv.FormatInt(a.OwnerID, 10))
+	if err := os.MkdirAll(dir, 0o750); err != nil {
+		return "", err
+	}
+
+	name := a.FileUniqueID
+	if name == "" {
+		name = strconv.FormatInt(a.CreatedAt.UnixNano(), 10)
+	}
+	ext := filepath.Ext(a.FileName)
+	if ext == "" && a.Kind == domain.AttachmentPhoto {
+		ext = ".jpg"
+	}
+	path := filepath.Join(dir, name+ext)
+
+	f, err := os.Create(path)
+	if err != nil {
+		return "", err
+	}
+	if _, err := io.Copy(f, content); err != nil {
+		f.Close()
+		os.Remove(path)
+		return "", err
+	}
+	if err := f.Close(); err != nil {
+		os.Remove(path)
+		return "", err
+	}
+	return path, nil
+}
+
+func (s *AttachmentService) Get(id int64) (*domain.Attachment, error) {
+	return s.storage.GetAttachment(id)
+}
+
+func (s *AttachmentService) List(ownerType domain.AttachmentOwner, ownerID int64) ([]*domain.Attachment, error) {
+	return s.storage.ListAttachments(ownerType, ownerID)
+}
+
+// Delete removes the attachment and its local copy
+func (s *AttachmentService) Delete(id, userID int64) error {
+	a, err := s.storage.GetAttachment(id)
+	if err != nil {
+		return fmt.Errorf("get attachment: %w", err)
+	}
+	if a == nil {
+		return fmt.Errorf("вложение не найдено")
+	}
+	if a.UserID != userID {
+		return fmt.Errorf("access denied")
+	}
+	if err := s.storage.DeleteAttachment(id); err != nil {
+		return fmt.Errorf("delete attachment: %w", err)
+	}
+	if a.LocalPath != "" {
+		if err := os.Remove(a.LocalPath); err != nil && !os.IsNotExist(err) {
+			log.Printf("AttachmentService: remove %s: %v", a.LocalPath, err)
+		}
+	}
+	return nil
+}
+
+// FormatList formats attachments for a task, person or auto card
+func (s *AttachmentService) FormatList(attachments []*domain.Attachment) string {
+	if len(attachments) == 0 {
+		return ""
+	}
+	var sb strings.Builder
+	sb.WriteString(fmt.Sprintf("\n\n📎 <b>Вложения (%d)</b>", len(attachments)))
+	for _, a := range attachments {
+		sb.WriteString(fmt.Sprintf("\n%s %s", a.Emoji(), html.EscapeString(a.DisplayName())))
+		if a.FileSize > 0 {
+			sb.WriteString(" · " + formatFileSize(a.FileSize))
+		}
+	}
+	return sb.String()
+}
+
+func formatFileSize(n int64) string {
+	switch {
+	case n >= 1<<20:
+		return fmt.Sprintf("%.1f МБ", float64(n)/(1<<20))
+	case n >= 1<<10:
+		return fmt.Sprintf("%d КБ", n/(1<<10))
+	default:
+		return fmt.Sprintf("%d Б", n)
+	}
+}
@@ -0,0 +1,62 @@
+package service_test
+
+import (
+	"testing"
+
+	"github.com/tazhate/familybot/internal/domain"
+	"github.com/tazhate/familybot/internal/service"
+	"github.com/tazhate/familybot/internal/storage/storagetest"
+)
+
+func TestCheckOwnerSharedTask(t *testing.T) {
+	store := storagetest.SQLite(t)
+	users := make(map[string]*domain.User)
+	for i, name := range []string{"owner", "partner", "assignee", "stranger"} {
+		u := &domain.User{TelegramID: int64(100 * (i + 1)), Name: name, Role: domain.RoleOwner}
+		if err := store.CreateUser(u); err != nil {
+			t.Fatal(err)
+		}
+		users[name] = u
+	}
+	home, other := &domain.Household{Name: "Дом"}, &domain.Household{Name: "Соседи"}
+	for _, h := range []*domain.Household{home, other} {
+		if err := store.CreateHousehold(h); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for name, h := range map[string]*domain.Household{"owner": home, "partner": home, "stranger": other} {
+		if err := store.AddHouseholdMember(&domain.HouseholdMember{HouseholdID: h.ID, UserID: users[name].ID, Role: domain.HouseholdAdult}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	owner := users["owner"]
+	shared := &domain.Task{UserID: owner.ID, ChatID: owner.TelegramID, Title: "Общая", Priority: domain.PriorityWeek, IsShared: true, AssignedTo: &users["assignee"].ID}
+	private := &domain.Task{UserID: owner.ID, ChatID: owner.TelegramID, Title: "Личная", Priority: domain.PriorityWeek}
+	for _, task := range []*domain.Task{shared, private} {
+		if err := store.CreateTask(task); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	attachments := service.NewAttachmentService(store)
+	tests := []struct {
+		user    string
+		task    *domain.Task
+		allowed bool
+	}{
+		{"owner", private, true},
+		{"partner", shared, true},
+		{"partner", private, false},
+		{"assignee", shared, true}, // без семьи, но задача назначена на него
+		{"stranger", shared, false},
+		{"stranger", private, false},
+	}
+	for _, tt := range tests {
+		u := users[tt.user]
+		err := attachments.CheckOwner(domain.AttachmentTask, tt.task.ID, u.ID, u.TelegramID)
+		if (err == nil) != tt.allowed {
+			t.Errorf("%s → «%s»: error %v, want allowed %v", tt.user, tt.task.Title, err, tt.allowed)
+		}
+	}
+}
@@ -0,0 +1,696 @@
+package service
+
+import (
+	"fmt"
+	"html"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/tazhate/familybot/internal/domain"
+)
+
+// Журнал машины: пробег, сервисная книжка, регламент ТО и документы со сроком.
+
+// autoHistoryLimit — сколько последних сервисных записей показывать в карточке машины
+const autoHistoryLimit = 10
+
+var (
+	// «85000км», «85 000 км», «85000 km»
+	kmRe = regexp.MustCompile(`(?i)(\d{1,3}(?:[ \x{00a0}]\d{3})+|\d+)\s*(?:км|km)(?:\s|$)`)
+	// «4500₽», «4 500 руб», «4500р»
+	costRe = regexp.MustCompile(`(?i)((?:\d{1,3}(?:[ \x{00a0}]\d{3})+|\d+)(?:[.,]\d+)?)\s*(?:₽|руб(?:лей|ля|ль|\.)?|р\.?)(?:\s|$)`)
+	// «12 мес», «1 год», «2 года»
+	monthsRe = regexp.MustCompile(`(?i)(\d+)\s*(?:мес(?:яц(?:а|ев)?)?\.?|months?)(?:\s|$)`)
+	yearsRe  = regexp.MustCompile(`(?i)(\d+)\s*(?:год(?:а)?|лет|years?)(?:\s|$)`)
+	// «каждые», «раз в», «или», «every», «or» — служебные слова регламента
+	ruleWordsRe = regexp.MustCompile(`(?i)(?:^|\s)(?:каждые|каждый|каждое|каждую|раз в|или|every|or)(?:\s|$)`)
+)
+
+// AutoAlert — напоминание о сроке: ключ определяет случай, одно и то же не отправляется дважды
+type AutoAlert struct {
+	UserID int64
+	Key    string
+	Text   string
+}
+
+// ResolveAuto finds the user's auto by ID or name at the start of args and returns the rest.
+// With a single auto the name may be omitted.
+func (s *AutoService) ResolveAuto(userID int64, args string) (*domain.Auto, string, error) {
+	autos, err := s.storage.ListAutosByUser(userID)
+	if err != nil {
+		return nil, "", fmt.Errorf("list autos: %w", err)
+	}
+	if len(autos) == 0 {
+		return nil, "", fmt.Errorf("нет машин — добавь: /addauto Название год")
+	}
+
+	if auto, rest := matchAuto(autos, strings.Fields(args)); auto != nil {
+		return auto, rest, nil
+	}
+	if len(autos) == 1 {
+		return autos[0], strings.TrimSpace(args), nil
+	}
+	return nil, "", fmt.Errorf("не понял, какая машина — укажи название или ID из /autos")
+}
+
+// matchAuto matches the leading words against the auto ID, full name or part of the name
+func matchAuto(autos []*domain.Auto, words []string) (*domain.Auto, string) {
+	if len(words) == 0 {
+		return nil, ""
+	}
+	if id, err := strconv.ParseInt(strings.TrimPrefix(words[0], "#"), 10, 64); err == nil {
+		for _, a := range autos {
+			if a.ID == id {
+				return a, strings.Join(words[1:], " ")
+			}
+		}
+	}
+
+	for n := len(words); n >= 1; n-- {
+		candidate := strings.ToLower(strings.Join(words[:n], " "))
+		var found []*domain.Auto
+		for _, a := range autos {
+			name := strings.ToLower(a.Name)
+			if name == candidate {
+				return a, strings.Join(words[n:], " ")
+			}
+			if strings.Contains(name, candidate) {
+				found = append(found, a)
+			}
+		}
+		if len(found) == 1 {
+			return found[0], strings.Join(words[n:], " ")
+		}
+	}
+	return nil, ""
+}
+
+// Mileage returns the last recorded mileage of the auto, 0 if unknown
+func (s *AutoService) Mileage(autoID int64) int {
+	r, err := s.storage.GetLatestOdometerReading(autoID)
+	if err != nil || r == nil {
+		return 0
+	}
+	return r.Km
+}
+
+// RecordMileage adds an odometer reading; the mileage can't go down
+func (s *AutoService) RecordMileage(autoID, userID int64, km int) error {
+	if km <= 0 {
+		return fmt.Errorf("пробег должен быть больше нуля")
+	}
+	if current := s.Mileage(autoID); km < current {
+		return fmt.Errorf("пробег меньше последнего записанного (%s км)", formatThousands(float64(current)))
+	}
+	return s.storage.AddOdometerReading(&domain.OdometerReading{
+		AutoID:     autoID,
+		UserID:     userID,
+		Km:         km,
+		RecordedAt: s.clock.Now(),
+	})
+}
+
+// ParseKm parses a mileage like «85000», «85 000» or «85 000 км»
+func ParseKm(s string) (int, error) {
+	s = strings.TrimSpace(strings.TrimSuffix(strings.TrimSuffix(strings.TrimSpace(s), "км"), "km"))
+	km, err := strconv.Atoi(strings.NewReplacer(" ", "", "\u00a0", "").Replace(s))
+	if err != nil {
+		return 0, fmt.Errorf("не понял пробег «%s», пример: 85000", s)
+	}
+	return km, nil
+}
+
+// ParseServiceArgs parses «замена масла 85000км 4500₽ ; Castrol 5W-30»
+func ParseServiceArgs(args string) (title string, km int, cost float64, notes string, err error) {
+	main, notes, _ := strings.Cut(args, ";")
+	notes = strings.TrimSpace(notes)
+
+	if m := kmRe.FindStringSubmatch(main); m != nil {
+		km, _ = ParseKm(m[1])
+		main = kmRe.ReplaceAllString(main, " ")
+	}
+	if m := costRe.FindStringSubmatch(main); m != nil {
+		raw := strings.NewReplacer(" ", "", "\u00a0", "", ",", ".").Replace(m[1])
+		cost, _ = strconv.ParseFloat(raw, 64)
+		main = costRe.ReplaceAllString(main, " ")
+	}
+
+	title = strings.Join(strings.Fields(main), " ")
+	if title == "" {
+		return "", 0, 0, "", fmt.Errorf("укажи, что сделали: /service Ford замена масла 85000км 4500₽")
+	}
+	return title, km, cost, notes, nil
+}
+
+// AddService records a service; a mileage above the last one goes to the odometer log,
+// and a maintenance rule whose title is in the record starts counting from this service
+func (s *AutoService) AddService(autoID, userID int64, args string) (*domain.ServiceRecord, *domain.MaintenanceRule, error) {
+	title, km, cost, notes, err := ParseServiceArgs(args)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	now := s.clock.Now()
+	current := s.Mileage(autoID)
+	if km > current {
+		if err := s.RecordMileage(autoID, userID, km); err != nil {
+			return nil, nil, err
+		}
+		current = km
+	}
+
+	r := &domain.ServiceRecord{
+		AutoID:      autoID,
+		UserID:      userID,
+		Title:       title,
+		Km:          km,
+		Cost:        cost,
+		Notes:       notes,
+		PerformedAt: now,
+	}
+
+	rules, err := s.storage.ListMaintenanceRules(autoID)
+	if err != nil {
+		return nil, nil, fmt.Errorf("list rules: %w", err)
+	}
+	var closed *domain.MaintenanceRule
+	for _, rule := range rules {
+		if rule.Matches(title) {
+			closed = rule
+			r.RuleID = &rule.ID
+			break
+		}
+	}
+
+	if err := s.storage.CreateServiceRecord(r); err != nil {
+		return nil, nil, fmt.Errorf("create service record: %w", err)
+	}
+	if closed != nil {
+		doneKm := km
+		if doneKm == 0 {
+			doneKm = current
+		}
+		if err := s.storage.UpdateMaintenanceRuleDone(closed.ID, doneKm, now); err != nil {
+			return nil, nil, fmt.Errorf("update rule: %w", err)
+		}
+		closed.LastKm = doneKm
+		closed.LastDoneAt = &now
+	}
+	return r, closed, nil
+}
+
+// ParseRuleArgs parses «масло каждые 10 000 км или 12 мес»
+func ParseRuleArgs(args string) (title string, intervalKm, intervalMonths int, err error) {
+	text := args
+	if m := kmRe.FindStringSubmatch(text); m != nil {
+		intervalKm, _ = ParseKm(m[1])
+		text = kmRe.ReplaceAllString(text, " ")
+	}
+	if m := monthsRe.FindStringSubmatch(text); m != nil {
+		intervalMonths, _ = strconv.Atoi(m[1])
+		text = monthsRe.ReplaceAllString(text, " ")
+	} else if m := yearsRe.FindStringSubmatch(text); m != nil {
+		years, _ := strconv.Atoi(m[1])
+		intervalMonths = years * 12
+		text = yearsRe.ReplaceAllString(text, " ")
+	}
+	if intervalKm <= 0 && intervalMonths <= 0 {
+		return "", 0, 0, fmt.Errorf("укажи интервал: /rule Ford масло каждые 10000 км или 12 мес")
+	}
+
+	// «каждые» и «или» могут стоять подряд, поэтому чистим до упора
+	for ruleWordsRe.MatchString(text) {
+		text = ruleWordsRe.ReplaceAllString(text, " ")
+	}
+	title = strings.Join(strings.Fields(text), " ")
+	if title == "" {
+		return "", 0, 0, fmt.Errorf("укажи название работы: /rule Ford масло каждые 10000 км")
+	}
+	return title, intervalKm, intervalMonths, nil
+}
+
+// AddRule adds a maintenance rule. The countdown starts from the latest matching
+// service record, or from now and the current mileage if there is none.
+func (s *AutoService) AddRule(autoID int64, args string) (*domain.MaintenanceRule, error) {
+	title, intervalKm, intervalMonths, err := ParseRuleArgs(args)
+	if err != nil {
+		return nil, err
+	}
+
+	now := s.clock.Now()
+	rule := &domain.MaintenanceRule{
+		AutoID:         autoID,
+		Title:          title,
+		IntervalKm:     intervalKm,
+		IntervalMonths: intervalMonths,
+		LastKm:         s.Mileage(autoID),
+		LastDoneAt:     &now,
+		CreatedAt:      now,
+	}
+
+	records, err := s.storage.ListServiceRecords(autoID, 100)
+	if err != nil {
+		return nil, fmt.Errorf("list service records: %w", err)
+	}
+	for _, r := range records {
+		if rule.Matches(r.Title) {
+			performed := r.PerformedAt
+			rule.LastDoneAt = &performed
+			if r.Km > 0 {
+				rule.LastKm = r.Km
+			}
+			break
+		}
+	}
+
+	if err := s.storage.CreateMaintenanceRule(rule); err != nil {
+		return nil, fmt.Errorf("create rule: %w", err)
+	}
+	return rule, nil
+}
+
+// DeleteRule removes a maintenance rule of the user's auto
+func (s *AutoService) DeleteRule(id, userID int64) error {
+	rule, err := s.storage.GetMaintenanceRule(id)
+	if err != nil {
+		return fmt.Errorf("get rule: %w", err)
+	}
+	if rule == nil {
+		return fmt.Errorf("регламент #%d не найден", id)
+	}
+	if auto, _ := s.storage.GetAuto(rule.AutoID); auto == nil || auto.UserID != userID {
+		return fmt.Errorf("нет доступа")
+	}
+	return s.storage.DeleteMaintenanceRule(id)
+}
+
+// SetDocument adds a document with an expiry date. A document of the same kind for the
+// same auto (or the same driver's licence) is renewed instead: its date is replaced.
+func (s *AutoService) SetDocument(userID int64, autoID *int64, kind domain.AutoDocumentKind, title string, expiresAt time.Time) (doc *domain.AutoDocument, renewed bool, err error) {
+	if title == "" {
+		title = domain.AutoDocumentTitle(kind)
+	}
+
+	if kind != domain.AutoDocOther {
+		docs, err := s.storage.ListAutoDocuments(userID)
+		if err != nil {
+			return nil, false, fmt.Errorf("list documents: %w", err)
+		}
+		for _, d := range docs {
+			if d.Kind == kind && sameAuto(d.AutoID, autoID) {
+				if err := s.storage.UpdateAutoDocumentExpiry(d.ID, expiresAt); err != nil {
+					return nil, false, fmt.Errorf("update document: %w", err)
+				}
+				d.ExpiresAt = expiresAt
+				return d, true, nil
+			}
+		}
+	}
+
+	doc = &domain.AutoDocument{
+		UserID:    userID,
+		AutoID:    autoID,
+		Kind:      kind,
+		Title:     title,
+		ExpiresAt: expiresAt,
+		CreatedAt: s.clock.Now(),
+	}
+	if err := s.storage.CreateAutoDocument(doc); err != nil {
+		return nil, false, fmt.Errorf("create document: %w", err)
+	}
+	return doc, false, nil
+}
+
+func sameAuto(a, b *int64) bool {
+	if a == nil || b == nil {
+		return a == nil && b == nil
+	}
+	return *a == *b
+}
+
+// ParseDocumentArgs parses «[машина] вид|название ДД.ММ.ГГГГ»: «Ford осаго 15.06.2027»,
+// «права 01.02.2030», «Ford пропуск МКАД 01.01.2027». The driver's licence has no auto.
+func (s *AutoService) ParseDocumentArgs(userID int64, args string) (autoID *int64, kind domain.AutoDocumentKind, title string, expiresAt time.Time, err error) {
+	words := strings.Fields(args)
+	if len(words) < 2 {
+		return nil, "", "", time.Time{}, fmt.Errorf("формат: /autodoc [машина] осаго|каско|техосмотр|права|название ДД.ММ.ГГГГ")
+	}
+	expiresAt, err = s.ParseDate(words[len(words)-1])
+	if err != nil {
+		return nil, "", "", time.Time{}, err
+	}
+	words = words[:len(words)-1]
+
+	kind = domain.AutoDocOther
+	var rest []string
+	for _, w := range words {
+		if k, ok := domain.ParseAutoDocumentKind(w); ok && kind == domain.AutoDocOther {
+			kind = k
+			continue
+		}
+		rest = append(rest, w)
+	}
+
+	if kind != domain.AutoDocLicense {
+		autos, err := s.storage.ListAutosByUser(userID)
+		if err != nil {
+			return nil, "", "", time.Time{}, fmt.Errorf("list autos: %w", err)
+		}
+		auto, remaining := matchAuto(autos, rest)
+		switch {
+		case auto != nil:
+			rest = strings.Fields(remaining)
+		case len(autos) == 1:
+			auto = autos[0]
+		case len(autos) > 1:
+			return nil, "", "", time.Time{}, fmt.Errorf("не понял, какая машина — укажи название или ID из /autos")
+		}
+		if auto != nil {
+			autoID = &auto.ID
+		}
+	}
+
+	title = strings.Join(rest, " ")
+	if kind == domain.AutoDocOther && title == "" {
+		return nil, "", "", time.Time{}, fmt.Errorf("укажи вид документа: осаго, каско, техосмотр, права — или его название")
+	}
+	return autoID, kind, title, expiresAt, nil
+}
+
+// ListDocuments returns the user's documents, the nearest expiry first
+func (s *AutoService) ListDocuments(userID int64) ([]*domain.AutoDocument, error) {
+	return s.storage.ListAutoDocuments(userID)
+}
+
+// DeleteDocument removes the user's document
+func (s *AutoService) DeleteDocument(id, userID int64) error {
+	d, err := s.storage.GetAutoDocument(id)
+	if err != nil {
+		return fmt.Errorf("get document: %w", err)
+	}
+	if d == nil || d.UserID != userID {
+		return fmt.Errorf("документ #%d не найден", id)
+	}
+	return s.storage.DeleteAutoDocument(id)
+}
+
+// daysLeft returns the number of calendar days from now until t in the service timezone
+func (s *AutoService) daysLeft(t time.Time) int {
+	now := s.clock.Now().In(s.timezone)
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
+	t = t.In(s.timezone)
+	day := time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+	return int(day.Sub(today).Hours() / 24)
+}
+
+// Alerts returns due reminders: insurance and maintenance dates, documents and rules
+// at 30, 7 and 1 days (and once after the date has passed), rules also 1000 km ahead.
+func (s *AutoService) Alerts() ([]*AutoAlert, error) {
+	maxDays := domain.AutoAlertDays[0]
+	var alerts []*AutoAlert
+	autoCache := make(map[int64]*domain.Auto)
+	getAuto := func(id int64) *domain.Auto {
+		if a, ok := autoCache[id]; ok {
+			return a
+		}
+		a, _ := s.storage.GetAuto(id)
+		autoCache[id] = a
+		return a
+	}
+
+	autos, err := s.storage.ListAutosNeedingReminder(maxDays+1, s.clock.Now())
+	if err != nil {
+		return nil, fmt.Errorf("list autos: %w", err)
+	}
+	for _, a := range autos {
+		autoCache[a.ID] = a
+		if a.InsuranceUntil != nil {
+			if alert := s.dateAlert(a.UserID, fmt.Sprintf("auto:%d:insurance", a.ID), "🛡", a.Name, "Страховка", *a.InsuranceUntil); alert != nil {
+				alerts = append(alerts, alert)
+			}
+		}
+		if a.MaintenanceUntil != nil {
+			if alert := s.dateAlert(a.UserID, fmt.Sprintf("auto:%d:maintenance", a.ID), "🔧", a.Name, "ТО", *a.MaintenanceUntil); alert != nil {
+				alerts = append(alerts, alert)
+			}
+		}
+	}
+
+	docs, err := s.storage.ListAutoDocumentsExpiringBefore(s.clock.Now().AddDate(0, 0, maxDays+1))
+	if err != nil {
+		return nil, fmt.Errorf("list documents: %w", err)
+	}
+	for _, d := range docs {
+		owner := "Документы"
+		if d.AutoID != nil {
+			if a := getAuto(*d.AutoID); a != nil {
+				owner = a.Name
+			}
+		}
+		if alert := s.dateAlert(d.UserID, fmt.Sprintf("autodoc:%d", d.ID), d.Emoji(), owner, d.Title, d.ExpiresAt); alert != nil {
+			alerts = append(alerts, alert)
+		}
+	}
+
+	rules, err := s.storage.ListMaintenanceRules(0)
+	if err != nil {
+		return nil, fmt.Errorf("list rules: %w", err)
+	}
+	for _, r := range rules {
+		a := getAuto(r.AutoID)
+		if a == nil {
+			continue
+		}
+		// Новый цикл регламента — новые ключи напоминаний
+		cycle := fmt.Sprintf("autorule:%d:%d", r.ID, r.LastKm)
+		if r.LastDoneAt != nil {
+			cycle += ":" + r.LastDoneAt.UTC().Format("20060102")
+		}
+		if next := r.NextDate(); next != nil {
+			if alert := s.dateAlert(a.UserID, cycle, "🔧", a.Name, r.Title, *next); alert != nil {
+				alerts = append(alerts, alert)
+			}
+		}
+		if alert := s.kmAlert(a, r, cycle); alert != nil {
+			alerts = append(alerts, alert)
+		}
+	}
+	return alerts, nil
+}
+
+func (s *AutoService) dateAlert(userID int64, key, emoji, owner, what string, date time.Time) *AutoAlert {
+	days := s.daysLeft(date)
+	stage := domain.AutoAlertStage(days)
+	if stage < 0 {
+		return nil
+	}
+
+	var when string
+	switch {
+	case days < 0:
+		when = "🔴 просрочено с " + date.In(s.timezone).Format("02.01.2006")
+	case days == 0:
+		when = "заканчивается <b>сегодня</b>"
+	default:
+		when = fmt.Sprintf("через <b>%d дн.</b> (%s)", days, date.In(s.timezone).Format("02.01.2006"))
+	}
+	return &AutoAlert{
+		UserID: userID,
+		Key:    fmt.Sprintf("%s:%s:d%d", key, date.In(s.timezone).Format("2006-01-02"), stage),
+		Text:   fmt.Sprintf("%s <b>%s</b>: %s — %s", emoji, html.EscapeString(owner), html.EscapeString(what), when),
+	}
+}
+
+func (s *AutoService) kmAlert(a *domain.Auto, r *domain.MaintenanceRule, key string) *AutoAlert {
+	next := r.NextKm()
+	mileage := s.Mileage(a.ID)
+	if next == 0 || mileage == 0 {
+		return nil
+	}
+
+	left := next - mileage
+	var stage int
+	var when string
+	switch {
+	case left <= 0:
+		when = fmt.Sprintf("🔴 пора: пробег %s км, по регламенту — %s км", formatThousands(float64(mileage)), formatThousands(float64(next)))
+	case left <= domain.AutoAlertKm:
+		stage = domain.AutoAlertKm
+		when = fmt.Sprintf("через <b>%s км</b> (на %s км)", formatThousands(float64(left)), formatThousands(float64(next)))
+	default:
+		return nil
+	}
+	return &AutoAlert{
+		UserID: a.UserID,
+		Key:    fmt.Sprintf("%s:km%d", key, stage),
+		Text:   fmt.Sprintf("🔧 <b>%s</b>: %s — %s", html.EscapeString(a.Name), html.EscapeString(r.Title), when),
+	}
+}
+
+// FormatAuto formats the card of one auto: dates, mileage, documents, rules and service history
+func (s *AutoService) FormatAuto(a *domain.Auto) string {
+	var sb strings.Builder
+	sb.WriteString(fmt.Sprintf("🚗 <b>#%d</b> %s", a.ID, html.EscapeString(a.Name)))
+	if a.Year > 0 {
+		sb.WriteString(fmt.Sprintf(" (%d)", a.Year))
+	}
+
+	if r, _ := s.storage.GetLatestOdometerReading(a.ID); r != nil {
+		sb.WriteString(fmt.Sprintf("\n🛣 Пробег: <b>%s км</b> (%s)", formatThousands(float64(r.Km)), r.RecordedAt.In(s.timezone).Format("02.01.2006")))
+	}
+
+	sb.WriteString("\n\n📋 Страховка: ")
+	if a.InsuranceUntil != nil {
+		sb.WriteString(s.FormatExpiry(*a.InsuranceUntil))
+	} else {
+		sb.WriteString(a.InsuranceStatus())
+	}
+	sb.WriteString("\n🔧 ТО: ")
+	if a.MaintenanceUntil != nil {
+		sb.WriteString(s.FormatExpiry(*a.MaintenanceUntil))
+	} else {
+		sb.WriteString(a.MaintenanceStatus())
+	}
+
+	if docs, _ := s.storage.ListAutoDocumentsByAuto(a.ID); len(docs) > 0 {
+		sb.WriteString("\n\n<b>Документы</b>")
+		for _, d := range docs {
+			sb.WriteString(fmt.Sprintf("\n%s %s <i>#%d</i> — %s", d.Emoji(), html.EscapeString(d.Title), d.ID, s.FormatExpiry(d.ExpiresAt)))
+		}
+	}
+
+	if rules, _ := s.storage.ListMaintenanceRules(a.ID); len(rules) > 0 {
+		sb.WriteString("\n\n<b>Регламент</b>")
+		for _, r := range rules {
+			sb.WriteString(fmt.Sprintf("\n🔧 %s <i>#%d</i> — %s", html.EscapeString(r.Title), r.ID, s.FormatRule(r)))
+		}
+	}
+
+	count, total, _ := s.storage.ServiceCostTotal(a.ID)
+	if count > 0 {
+		sb.WriteString(fmt.Sprintf("\n\n<b>Сервисная книжка</b> (%d", count))
+		if total > 0 {
+			sb.WriteString(fmt.Sprintf(", всего %s ₽", formatThousands(total)))
+		}
+		sb.WriteString(")")
+		records, _ := s.storage.ListServiceRecords(a.ID, autoHistoryLimit)
+		for _, r := range records {
+			sb.WriteString("\n• " + r.PerformedAt.In(s.timezone).Format("02.01.2006"))
+			if r.Km > 0 {
+				sb.WriteString(fmt.Sprintf(" · %s км", formatThousands(float64(r.Km))))
+			}
+			sb.WriteString(" · " + html.EscapeString(r.Title))
+			if r.Cost > 0 {
+				sb.WriteString(fmt.Sprintf(" — %s ₽", formatThousands(r.Cost)))
+			}
+			if r.Notes != "" {
+				sb.WriteString("\n   <i>" + html.EscapeString(r.Notes) + "</i>")
+			}
+		}
+	}
+
+	if a.Notes != "" {
+		sb.WriteString("\n\n📝 " + html.EscapeString(a.Notes))
+	}
+	return sb.String()
+}
+
+// FormatDocuments formats the user's documents for /autodoc
+func (s *AutoService) FormatDocuments(docs []*domain.AutoDocument) string {
+	if len(docs) == 0 {
+		return "Нет документов"
+	}
+	names := make(map[int64]string)
+	var sb strings.Builder
+	for _, d := range docs {
+		sb.WriteString(fmt.Sprintf("%s <b>#%d</b> %s", d.Emoji(), d.ID, html.EscapeString(d.Title)))
+		if d.AutoID != nil {
+			name, ok := names[*d.AutoID]
+			if !ok {
+				if a, _ := s.storage.GetAuto(*d.AutoID); a != nil {
+					name = a.Name
+				}
+				names[*d.AutoID] = name
+			}
+			if name != "" {
+				sb.WriteString(" · " + html.EscapeString(name))
+			}
+		}
+		sb.WriteString("\n   " + s.FormatExpiry(d.ExpiresAt) + "\n")
+	}
+	return sb.String()
+}
+
+// FormatExpiry formats a date with its status: 🟢 far, 🟡 within a month, 🟠 within a week, 🔴 expired
+func (s *AutoService) FormatExpiry(date time.Time) string {
+	days := s.daysLeft(date)
+	emoji := "🟢"
+	switch {
+	case days < 0:
+		emoji = "🔴"
+	case days <= 7:
+		emoji = "🟠"
+	case days <= 30:
+		emoji = "🟡"
+	}
+	text := fmt.Sprintf("%s до %s", emoji, date.In(s.timezone).Format("02.01.2006"))
+	if days < 0 {
+		return text + " (просрочено)"
+	}
+	return text + fmt.Sprintf(" (%d дн.)", days)
+}
+
+// FormatRule formats the interval of a rule and what's left until the next service
+func (s *AutoService) FormatRule(r *domain.MaintenanceRule) string {
+	mileage := s.Mileage(r.AutoID)
+	var every []string
+	if r.IntervalKm > 0 {
+		every = append(every, formatThousands(float64(r.IntervalKm))+" км")
+	}
+	if r.IntervalMonths > 0 {
+		every = append(every, fmt.Sprintf("%d мес", r.IntervalMonths))
+	}
+	text := "каждые " + strings.Join(every, " или ")
+
+	var next []string
+	if nextKm := r.NextKm(); nextKm > 0 {
+		if mileage > 0 && nextKm-mileage <= 0 {
+			next = append(next, "🔴 пора по пробегу")
+		} else if mileage > 0 {
+			next = append(next, fmt.Sprintf("через %s км", formatThousands(float64(nextKm-mileage))))
+		} else {
+			next = append(next, fmt.Sprintf("на %s км", formatThousands(float64(nextKm))))
+		}
+	}
+	if nextDate := r.NextDate(); nextDate != nil {
+		next = append(next, s.FormatExpiry(*nextDate))
+	}
+	if len(next) > 0 {
+		text += "; дальше: " + strings.Join(next, ", ")
+	}
+	return text
+}
+
+// formatThousands formats a number with thousands separated by spaces: 85 000
+func formatThousands(n float64) string {
+	str := strconv.FormatInt(int64(n+0.5), 10)
+	if n < 0 {
+		str = strconv.FormatInt(int64(n-0.5), 10)
+	}
+	neg := strings.HasPrefix(str, "-")
+	str = strings.TrimPrefix(str, "-")
+
+	var sb strings.Builder
+	for i, r := range str {
+		if i > 0 && (len(str)-i)%3 == 0 {
+			sb.WriteString(" ")
+		}
+		sb.WriteRune(r)
+	}
+	if neg {
+		return "-" + sb.String()
+	}
+	return sb.String()
+}
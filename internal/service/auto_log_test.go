@@ -0,0 +1,188 @@
+package service_test
+
+import (
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/tazhate/familybot/internal/clock"
+	"github.com/tazhate/familybot/internal/domain"
+	"github.com/tazhate/familybot/internal/service"
+	"github.com/tazhate/familybot/internal/storage/storagetest"
+)
+
+func TestParseRuleArgs(t *testing.T) {
+	tests := []struct {
+		args           string
+		title          string
+		intervalKm     int
+		intervalMonths int
+	}{
+		{"масло каждые 10 000 км или 12 мес", "масло", 10000, 12},
+		{"ремень ГРМ каждые 90000 km", "ремень ГРМ", 90000, 0},
+		{"антифриз раз в 3 года", "антифриз", 0, 36},
+		{"тормозная жидкость каждые 2 года или 40000км", "тормозная жидкость", 40000, 24},
+		{"свечи every 6 months", "свечи", 0, 6},
+	}
+	for _, tt := range tests {
+		title, km, months, err := service.ParseRuleArgs(tt.args)
+		if err != nil || title != tt.title || km != tt.intervalKm || months != tt.intervalMonths {
+			t.Errorf("ParseRuleArgs(%q) = %q, %d km, %d months, %v; want %q, %d km, %d months",
+				tt.args, title, km, months, err, tt.title, tt.intervalKm, tt.intervalMonths)
+		}
+	}
+	for _, args := range []string{"масло", "каждые 10000 км", ""} {
+		if _, _, _, err := service.ParseRuleArgs(args); err == nil {
+			t.Errorf("ParseRuleArgs(%q) accepted", args)
+		}
+	}
+}
+
+func TestParseServiceArgs(t *testing.T) {
+	title, km, cost, notes, err := service.ParseServiceArgs("замена масла 85 000км 4 500,50₽ ; Castrol 5W-30")
+	if err != nil || title != "замена масла" || km != 85000 || cost != 4500.5 || notes != "Castrol 5W-30" {
+		t.Errorf("parsed %q, %d km, %v ₽, %q, %v", title, km, cost, notes, err)
+	}
+	for _, tt := range []struct {
+		in  string
+		km  int
+		err bool
+	}{
+		{"85000", 85000, false},
+		{"85 000 км", 85000, false},
+		{"85 000km", 85000, false},
+		{"много", 0, true},
+	} {
+		km, err := service.ParseKm(tt.in)
+		if km != tt.km || (err != nil) != tt.err {
+			t.Errorf("ParseKm(%q) = %d, %v", tt.in, km, err)
+		}
+	}
+}
+
+// garage — машина с пробегом и сервис на фальшивых часах
+type garage struct {
+	t     *testing.T
+	autos *service.AutoService
+	clock *clock.Fake
+	user  *domain.User
+	auto  *domain.Auto
+}
+
+func newGarage(t *testing.T) *garage {
+	t.Helper()
+	store := storagetest.SQLite(t)
+	user := &domain.User{TelegramID: 100, Name: "Алекс", Role: domain.RoleOwner}
+	if err := store.CreateUser(user); err != nil {
+		t.Fatal(err)
+	}
+	auto := &domain.Auto{UserID: user.ID, Name: "Ford"}
+	if err := store.CreateAuto(auto); err != nil {
+		t.Fatal(err)
+	}
+	clk := clock.NewFake(time.Date(2030, time.January, 31, 12, 0, 0, 0, time.UTC))
+	autos := service.NewAutoService(store, time.UTC)
+	autos.SetClock(clk)
+	return &garage{t: t, autos: autos, clock: clk, user: user, auto: auto}
+}
+
+func (g *garage) drive(km int) {
+	g.t.Helper()
+	if err := g.autos.RecordMileage(g.auto.ID, g.user.ID, km); err != nil {
+		g.t.Fatal(err)
+	}
+}
+
+// alerts returns the texts of the due alerts
+func (g *garage) alerts() []string {
+	g.t.Helper()
+	alerts, err := g.autos.Alerts()
+	if err != nil {
+		g.t.Fatal(err)
+	}
+	var texts []string
+	for _, a := range alerts {
+		texts = append(texts, a.Text)
+	}
+	return texts
+}
+
+func TestMaintenanceByKm(t *testing.T) {
+	g := newGarage(t)
+	g.drive(85000)
+	if err := g.autos.RecordMileage(g.auto.ID, g.user.ID, 84000); err == nil {
+		t.Error("mileage went down")
+	}
+	rule, err := g.autos.AddRule(g.auto.ID, "масло каждые 10 000 км")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rule.LastKm != 85000 || rule.NextKm() != 95000 {
+		t.Fatalf("rule counts from %d km to %d km", rule.LastKm, rule.NextKm())
+	}
+
+	steps := []struct {
+		km   int
+		want []string
+	}{
+		{93999, nil},
+		{94000, []string{"🔧 <b>Ford</b>: масло — через <b>1 000 км</b> (на 95 000 км)"}},
+		{95300, []string{"🔧 <b>Ford</b>: масло — 🔴 пора: пробег 95 300 км, по регламенту — 95 000 км"}},
+	}
+	for _, step := range steps {
+		g.drive(step.km)
+		if got := g.alerts(); !slices.Equal(got, step.want) {
+			t.Errorf("at %d km alerts %q, want %q", step.km, got, step.want)
+		}
+	}
+
+	// Запись без пробега закрывает регламент на текущем пробеге
+	_, closed, err := g.autos.AddService(g.auto.ID, g.user.ID, "замена масла 4500₽")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if closed == nil || closed.ID != rule.ID || closed.LastKm != 95300 || closed.NextKm() != 105300 {
+		t.Fatalf("closed %+v", closed)
+	}
+	if got := g.alerts(); len(got) != 0 {
+		t.Errorf("alerts %q after the service", got)
+	}
+}
+
+func TestMaintenanceByMonths(t *testing.T) {
+	g := newGarage(t)
+	g.drive(85000)
+	// Сервис до регламента: отсчёт идёт от него, а не от создания правила
+	if _, _, err := g.autos.AddService(g.auto.ID, g.user.ID, "замена масла 84 000 км"); err != nil {
+		t.Fatal(err)
+	}
+	g.clock.Advance(24 * time.Hour)
+	rule, err := g.autos.AddRule(g.auto.ID, "масло каждые 10000 км или 1 мес")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rule.LastKm != 84000 {
+		t.Errorf("rule counts from %d km, want the service's 84000", rule.LastKm)
+	}
+	// Сервис 31 января + месяц — 28 февраля
+	if next := rule.NextDate(); next == nil || next.Format("02.01.2006") != "28.02.2030" {
+		t.Fatalf("next date %v", next)
+	}
+
+	steps := []struct {
+		day  time.Time
+		want string
+	}{
+		{time.Date(2030, time.February, 21, 9, 0, 0, 0, time.UTC), "через <b>7 дн.</b> (28.02.2030)"},
+		{time.Date(2030, time.February, 28, 9, 0, 0, 0, time.UTC), "заканчивается <b>сегодня</b>"},
+		{time.Date(2030, time.March, 1, 9, 0, 0, 0, time.UTC), "🔴 просрочено с 28.02.2030"},
+	}
+	for _, step := range steps {
+		g.clock.Set(step.day)
+		got := g.alerts()
+		if len(got) != 1 || !strings.HasSuffix(got[0], "масло — "+step.want) {
+			t.Errorf("on %s alerts %q, want %q", step.day.Format("02.01"), got, step.want)
+		}
+	}
+}
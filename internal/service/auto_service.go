@@ -7,16 +7,24 @@ import (
 	"strings"
 	"time"
 
+	"github.com/tazhate/familybot/internal/clock"
 	"github.com/tazhate/familybot/internal/domain"
 	"github.com/tazhate/familybot/internal/storage"
 )
 
 type AutoService struct {
-	storage *storage.Storage
+	storage  storage.Store
+	timezone *time.Location
+	clock    clock.Clock
 }
 
-func NewAutoService(s *storage.Storage) *AutoService {
-	return &AutoService{storage: s}
+func NewAutoService(s storage.Store, tz *time.Location) *AutoService {
+	return &AutoService{storage: s, timezone: tz, clock: clock.Real()}
+}
+
+// SetClock replaces the system clock, e.g. to simulate days in tests
+func (s *AutoService) SetClock(c clock.Clock) {
+	s.clock = c
 }
 
 func (s *AutoService) Create(userID int64, name string, year int) (*domain.Auto, error) {
@@ -90,7 +98,7 @@ func (s *AutoService) SetMaintenance(id int64, userID int64, until time.Time) er
 
 // ListNeedingReminder returns autos needing reminder within N days
 func (s *AutoService) ListNeedingReminder(days int) ([]*domain.Auto, error) {
-	return s.storage.ListAutosNeedingReminder(days)
+	return s.storage.ListAutosNeedingReminder(days, s.clock.Now())
 }
 
 // ParseAddArgs parses "Название ГГГГ" or just "Название"
@@ -117,15 +125,15 @@ func (s *AutoService) ParseDate(dateStr string) (time.Time, error) {
 	dateStr = strings.TrimSpace(dateStr)
 
 	// Try full date first
-	if t, err := time.Parse("02.01.2006", dateStr); err == nil {
+	if t, err := time.ParseInLocation("02.01.2006", dateStr, s.timezone); err == nil {
 		return t, nil
 	}
 
 	// Try short date (assume current year)
 	if t, err := time.Parse("02.01", dateStr); err == nil {
-		t = time.Date(time.Now().Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.Local)
+		t = time.Date(s.clock.Now().Year(), t.Month(), t.Day(), 0, 0, 0, 0, s.timezone)
 		// If date is in the past, use next year
-		if t.Before(time.Now()) {
+		if t.Before(s.clock.Now()) {
 			t = t.AddDate(1, 0, 0)
 		}
 		return t, nil
@@ -0,0 +1,343 @@
+package service
+
+import (
+	"fmt"
+	"html"
+	"log"
+	"path"
+	"strings"
+
+	"github.com/tazhate/familybot/internal/clients/caldav"
+	"github.com/tazhate/familybot/internal/domain"
+)
+
+// Аккаунты и календари: у каждого пользователя сколько угодно CalDAV-аккаунтов,
+// из них подключаются отдельные календари со своим цветом, направлением синхронизации
+// и флагом «общий для семьи». Бот пишет в первый двусторонний календарь пользователя.
+
+// clientFor returns the CalDAV client of the account, one per account
+func (s *CalendarService) clientFor(accountID int64) (*caldav.Client, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if c, ok := s.clients[accountID]; ok {
+		return c, nil
+	}
+	account, err := s.storage.GetCalendarAccount(accountID)
+	if err != nil {
+		return nil, fmt.Errorf("get calendar account: %w", err)
+	}
+	if account == nil {
+		return nil, fmt.Errorf("calendar account %d not found", accountID)
+	}
+	c := caldav.NewClient(account.URL, account.Username, account.Password)
+	c.SetLocation(s.timezone)
+	s.clients[accountID] = c
+	return c, nil
+}
+
+func (s *CalendarService) forgetClient(accountID int64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.clients, accountID)
+}
+
+// ParseAccountArgs parses "icloud LOGIN PASSWORD" or "https://server/dav LOGIN PASSWORD"
+func ParseAccountArgs(args string) (domain.CalendarProvider, string, string, string, error) {
+	fields := strings.Fields(args)
+	if len(fields) != 3 {
+		return "", "", "", "", fmt.Errorf("нужно три значения: сервис или адрес, логин и пароль")
+	}
+	if provider, ok := domain.ParseCalendarProvider(fields[0]); ok {
+		if provider.DefaultURL() == "" {
+			return "", "", "", "", fmt.Errorf("для %s укажи адрес сервера вместо названия", provider.Name())
+		}
+		return provider, provider.DefaultURL(), fields[1], fields[2], nil
+	}
+	if !strings.HasPrefix(fields[0], "https://") && !strings.HasPrefix(fields[0], "http://") {
+		return "", "", "", "", fmt.Errorf("неизвестный сервис «%s»: icloud, fastmail или адрес сервера", fields[0])
+	}
+	return domain.DetectCalendarProvider(fields[0]), fields[0], fields[1], fields[2], nil
+}
+
+// AddAccount checks the login by discovering the calendars and saves the account.
+// Linking the same login again updates the password.
+func (s *CalendarService) AddAccount(userID int64, provider domain.CalendarProvider, url, username, password string) (*domain.CalendarAccount, []caldav.Calendar, error) {
+	client := caldav.NewClient(url, username, password)
+	client.SetLocation(s.timezone)
+	calendars, err := client.DiscoverCalendars()
+	if err != nil {
+		return nil, nil, fmt.Errorf("не удалось войти в %s: %w", provider.Name(), err)
+	}
+
+	account, err := s.storage.GetCalendarAccountByLogin(userID, url, username)
+	if err != nil {
+		return nil, nil, fmt.Errorf("get calendar account: %w", err)
+	}
+	if account != nil {
+		account.Provider = provider
+		account.Password = password
+		err = s.storage.UpdateCalendarAccount(account)
+	} else {
+		account = &domain.CalendarAccount{UserID: userID, Provider: provider, URL: url, Username: username, Password: password}
+		err = s.storage.CreateCalendarAccount(account)
+	}
+	if err != nil {
+		return nil, nil, fmt.Errorf("save calendar account: %w", err)
+	}
+
+	s.mu.Lock()
+	s.clients[account.ID] = client
+	s.mu.Unlock()
+	return account, calendars, nil
+}
+
+// LinkFromConfig registers the account from CALDAV_* settings for the user and links
+// CALDAV_CALENDAR_ID as a two-way calendar. Events imported before calendars could be
+// linked are attached to it. Doesn't go to the server, so the bot starts offline too.
+func (s *CalendarService) LinkFromConfig(userID int64, url, username, password, calendarPath string) error {
+	account, err := s.storage.GetCalendarAccountByLogin(userID, url, username)
+	if err != nil {
+		return fmt.Errorf("get calendar account: %w", err)
+	}
+	switch {
+	case account == nil:
+		account = &domain.CalendarAccount{UserID: userID, Provider: domain.DetectCalendarProvider(url), URL: url, Username: username, Password: password}
+		if err := s.storage.CreateCalendarAccount(account); err != nil {
+			return fmt.Errorf("create calendar account: %w", err)
+		}
+	case account.Password != password:
+		account.Password = password
+		if err := s.storage.UpdateCalendarAccount(account); err != nil {
+			return fmt.Errorf("update calendar account: %w", err)
+		}
+		s.forgetClient(account.ID)
+	}
+	if calendarPath == "" {
+		return nil
+	}
+
+	existing, err := s.storage.GetCalendarByPath(account.ID, calendarPath)
+	if err != nil {
+		return fmt.Errorf("get calendar: %w", err)
+	}
+	if existing != nil {
+		return nil
+	}
+	cal, err := s.linkCalendar(account, calendarPath, path.Base(strings.TrimSuffix(calendarPath, "/")), domain.CalendarTwoWay)
+	if err != nil {
+		return err
+	}
+	adopted, err := s.storage.AdoptCalendarEvents(cal.ID, userID)
+	if err != nil {
+		return fmt.Errorf("adopt calendar events: %w", err)
+	}
+	if adopted > 0 {
+		log.Printf("calendar: %d synced events attached to calendar %d", adopted, cal.ID)
+	}
+	return nil
+}
+
+// Accounts returns the user's CalDAV accounts
+func (s *CalendarService) Accounts(userID int64) ([]*domain.CalendarAccount, error) {
+	return s.storage.ListCalendarAccounts(userID)
+}
+
+func (s *CalendarService) account(userID, accountID int64) (*domain.CalendarAccount, error) {
+	account, err := s.storage.GetCalendarAccount(accountID)
+	if err != nil {
+		return nil, fmt.Errorf("get calendar account: %w", err)
+	}
+	if account == nil || account.UserID != userID {
+		return nil, fmt.Errorf("аккаунт #%d не найден", accountID)
+	}
+	return account, nil
+}
+
+// DeleteAccount removes the account with its calendars and their events
+func (s *CalendarService) DeleteAccount(userID, accountID int64) error {
+	if _, err := s.account(userID, accountID); err != nil {
+		return err
+	}
+	s.forgetClient(accountID)
+	return s.storage.DeleteCalendarAccount(accountID)
+}
+
+// DiscoverCalendars returns the calendars available in the account
+func (s *CalendarService) DiscoverCalendars(userID, accountID int64) ([]caldav.Calendar, error) {
+	if _, err := s.account(userID, accountID); err != nil {
+		return nil, err
+	}
+	client, err := s.clientFor(accountID)
+	if err != nil {
+		return nil, err
+	}
+	return client.DiscoverCalendars()
+}
+
+// LinkCalendar links the calendar of the account. The first calendar of the user
+// becomes two-way, the others are read-only until switched.
+func (s *CalendarService) LinkCalendar(userID, accountID int64, calendarPath, name string) (*domain.Calendar, error) {
+	account, err := s.account(userID, accountID)
+	if err != nil {
+		return nil, err
+	}
+	existing, err := s.storage.GetCalendarByPath(accountID, calendarPath)
+	if err != nil {
+		return nil, fmt.Errorf("get calendar: %w", err)
+	}
+	if existing != nil {
+		return nil, fmt.Errorf("календарь «%s» уже подключён", existing.Name)
+	}
+
+	mode := domain.CalendarReadOnly
+	if cal, _ := s.writeCalendar(userID); cal == nil {
+		mode = domain.CalendarTwoWay
+	}
+	return s.linkCalendar(account, calendarPath, name, mode)
+}
+
+func (s *CalendarService) linkCalendar(account *domain.CalendarAccount, calendarPath, name string, mode domain.CalendarSyncMode) (*domain.Calendar, error) {
+	// Цвет — наименее занятый среди календарей всей семьи, чтобы общие не сливались
+	linked, err := s.storage.ListAllCalendars()
+	if err != nil {
+		return nil, fmt.Errorf("list calendars: %w", err)
+	}
+	used := make(map[domain.CalendarColor]int)
+	for _, c := range linked {
+		used[c.Color]++
+	}
+	color := domain.CalendarColors[0]
+	for _, c := range domain.CalendarColors {
+		if used[c] < used[color] {
+			color = c
+		}
+	}
+	if name == "" {
+		name = "Календарь"
+	}
+	cal := &domain.Calendar{
+		AccountID: account.ID,
+		UserID:    account.UserID,
+		Path:      calendarPath,
+		Name:      name,
+		Color:     color,
+		Mode:      mode,
+	}
+	if err := s.storage.CreateCalendar(cal); err != nil {
+		return nil, fmt.Errorf("create calendar: %w", err)
+	}
+	return cal, nil
+}
+
+// Calendars returns the user's linked calendars
+func (s *CalendarService) Calendars(userID int64) ([]*domain.Calendar, error) {
+	return s.storage.ListCalendars(userID)
+}
+
+// Calendar returns the user's linked calendar by ID
+func (s *CalendarService) Calendar(userID, calendarID int64) (*domain.Calendar, error) {
+	cal, err := s.storage.GetCalendar(calendarID)
+	if err != nil {
+		return nil, fmt.Errorf("get calendar: %w", err)
+	}
+	if cal == nil || cal.UserID != userID {
+		return nil, fmt.Errorf("календарь #%d не найден", calendarID)
+	}
+	return cal, nil
+}
+
+// SetColor changes the colour of the calendar
+func (s *CalendarService) SetColor(userID, calendarID int64, color domain.CalendarColor) (*domain.Calendar, error) {
+	cal, err := s.Calendar(userID, calendarID)
+	if err != nil {
+		return nil, err
+	}
+	cal.Color = color
+	return cal, s.storage.UpdateCalendar(cal)
+}
+
+// SetMode changes the sync direction of the calendar
+func (s *CalendarService) SetMode(userID, calendarID int64, mode domain.CalendarSyncMode) (*domain.Calendar, error) {
+	cal, err := s.Calendar(userID, calendarID)
+	if err != nil {
+		return nil, err
+	}
+	cal.Mode = mode
+	return cal, s.storage.UpdateCalendar(cal)
+}
+
+// SetShared shares the calendar with the family or makes it private, with its events
+func (s *CalendarService) SetShared(userID, calendarID int64, shared bool) (*domain.Calendar, error) {
+	cal, err := s.Calendar(userID, calendarID)
+	if err != nil {
+		return nil, err
+	}
+	cal.IsShared = shared
+	if err := s.storage.UpdateCalendar(cal); err != nil {
+		return nil, err
+	}
+	return cal, s.storage.SetCalendarEventsShared(cal.ID, shared)
+}
+
+// UnlinkCalendar unlinks the calendar and removes its events from the bot
+func (s *CalendarService) UnlinkCalendar(userID, calendarID int64) (*domain.Calendar, error) {
+	cal, err := s.Calendar(userID, calendarID)
+	if err != nil {
+		return nil, err
+	}
+	return cal, s.storage.DeleteCalendar(cal.ID)
+}
+
+// writeCalendar returns the calendar the bot writes the user's events to:
+// the first two-way one. Nil if the user has none.
+func (s *CalendarService) writeCalendar(userID int64) (*domain.Calendar, *caldav.Client) {
+	calendars, err := s.storage.ListCalendars(userID)
+	if err != nil {
+		log.Printf("calendar: list calendars of user %d: %v", userID, err)
+		return nil, nil
+	}
+	for _, cal := range calendars {
+		if !cal.Writable() {
+			continue
+		}
+		client, err := s.clientFor(cal.AccountID)
+		if err != nil {
+			log.Printf("calendar: %v", err)
+			return nil, nil
+		}
+		return cal, client
+	}
+	return nil, nil
+}
+
+// FormatCalendars formats the user's accounts with their linked calendars
+func (s *CalendarService) FormatCalendars(accounts []*domain.CalendarAccount, calendars []*domain.Calendar) string {
+	if len(accounts) == 0 {
+		return "Аккаунтов пока нет"
+	}
+
+	var sb strings.Builder
+	for i, a := range accounts {
+		if i > 0 {
+			sb.WriteString("\n")
+		}
+		sb.WriteString(fmt.Sprintf("<b>%s</b> <code>#%d</code>\n", html.EscapeString(a.Title()), a.ID))
+		linked := 0
+		for _, c := range calendars {
+			if c.AccountID != a.ID {
+				continue
+			}
+			linked++
+			sb.WriteString(fmt.Sprintf("  %s <code>#%d</code> · %s %s", html.EscapeString(c.Label()), c.ID, c.Mode.Emoji(), c.Mode.Name()))
+			if c.IsShared {
+				sb.WriteString(" · 👨‍👩‍👧 общий")
+			}
+			sb.WriteString("\n")
+		}
+		if linked == 0 {
+			sb.WriteString("  <i>календари не подключены</i>\n")
+		}
+	}
+	return sb.String()
+}
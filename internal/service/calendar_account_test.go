@@ -0,0 +1,155 @@
+package service_test
+
+import (
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/tazhate/familybot/internal/clients/caldav/caldavtest"
+	"github.com/tazhate/familybot/internal/domain"
+	"github.com/tazhate/familybot/internal/service"
+)
+
+func TestParseAccountArgs(t *testing.T) {
+	provider, url, username, password, err := service.ParseAccountArgs("icloud me@icloud.com app-pass")
+	if err != nil || provider != domain.ProviderICloud || url != domain.ProviderICloud.DefaultURL() || username != "me@icloud.com" || password != "app-pass" {
+		t.Errorf("icloud: %s %s %s %s, %v", provider, url, username, password, err)
+	}
+	if provider, url, _, _, err := service.ParseAccountArgs("https://dav.example.com/ me secret"); err != nil || provider != domain.ProviderCalDAV || url != "https://dav.example.com/" {
+		t.Errorf("url: %s %s, %v", provider, url, err)
+	}
+	for _, args := range []string{"icloud me", "nextcloud me secret", "ftp://dav me secret", "gmail me secret"} {
+		if _, _, _, _, err := service.ParseAccountArgs(args); err == nil {
+			t.Errorf("ParseAccountArgs(%q) accepted", args)
+		}
+	}
+}
+
+// TestCalendarAccounts links two accounts of one user: events of both are synced,
+// the bot writes to the first two-way calendar, accounts are private to the user
+func TestCalendarAccounts(t *testing.T) {
+	f := newFamily(t)
+	calendars := service.NewCalendarService(f.store, time.UTC)
+	home, work := caldavtest.NewServer(), caldavtest.NewServer()
+	t.Cleanup(home.Close)
+	t.Cleanup(work.Close)
+	home.SetCredentials("alex", "home-pass")
+	work.SetCredentials("alex@work", "work-pass")
+
+	if _, _, err := calendars.AddAccount(f.owner.ID, domain.ProviderCalDAV, work.URL, "alex@work", "wrong"); err == nil {
+		t.Fatal("account with a wrong password added")
+	}
+	link := func(server *caldavtest.Server, username, password string) (*domain.CalendarAccount, *domain.Calendar) {
+		t.Helper()
+		account, found, err := calendars.AddAccount(f.owner.ID, domain.ProviderCalDAV, server.URL, username, password)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(found) != 1 || found[0].ID != server.CalendarPath() {
+			t.Fatalf("discovered %+v", found)
+		}
+		cal, err := calendars.LinkCalendar(f.owner.ID, account.ID, found[0].ID, found[0].DisplayName)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return account, cal
+	}
+	homeAccount, homeCal := link(home, "alex", "home-pass")
+	workAccount, workCal := link(work, "alex@work", "work-pass")
+	if homeCal.Mode != domain.CalendarTwoWay || workCal.Mode != domain.CalendarReadOnly {
+		t.Errorf("modes %s, %s; want the first calendar two-way", homeCal.Mode, workCal.Mode)
+	}
+	if homeCal.Color == workCal.Color {
+		t.Errorf("both calendars are %s", homeCal.Color)
+	}
+	if _, err := calendars.LinkCalendar(f.owner.ID, homeAccount.ID, home.CalendarPath(), "again"); err == nil {
+		t.Error("calendar linked twice")
+	}
+
+	// Повторный вход тем же логином меняет пароль, а не добавляет аккаунт
+	home.SetCredentials("alex", "new-pass")
+	again, _, err := calendars.AddAccount(f.owner.ID, domain.ProviderCalDAV, home.URL, "alex", "new-pass")
+	if err != nil || again.ID != homeAccount.ID {
+		t.Fatalf("relogin: %+v, %v", again, err)
+	}
+	if accounts, err := calendars.Accounts(f.owner.ID); err != nil || len(accounts) != 2 {
+		t.Fatalf("accounts %+v, %v", accounts, err)
+	}
+
+	start := time.Date(2030, time.March, 11, 9, 0, 0, 0, time.UTC)
+	home.PutEvent("dinner", "Ужин", start, start.Add(time.Hour))
+	work.PutEvent("standup", "Созвон", start, start.Add(15*time.Minute))
+	result, err := calendars.SyncUser(f.owner.ID)
+	if err != nil || len(result.Errors) > 0 {
+		t.Fatalf("sync: %+v, %v", result, err)
+	}
+	for _, tt := range []struct {
+		cal   *domain.Calendar
+		title string
+	}{{homeCal, "Ужин"}, {workCal, "Созвон"}} {
+		events, err := f.store.ListCalendarEventsByCalendar(tt.cal.ID)
+		if err != nil || len(events) != 1 || events[0].Title != tt.title {
+			t.Errorf("calendar %s: %+v, %v; want «%s»", tt.cal.Name, events, err, tt.title)
+		}
+	}
+
+	// Бот пишет в первый двусторонний календарь
+	written := func(title string) (inHome, inWork bool) {
+		t.Helper()
+		event, err := calendars.CreateEvent(f.owner.ID, title, start, start.Add(time.Hour), "", false)
+		if err != nil || event.CalDAVUID == "" {
+			t.Fatalf("create «%s»: %+v, %v", title, event, err)
+		}
+		_, inHome = home.EventSummary(event.CalDAVUID)
+		_, inWork = work.EventSummary(event.CalDAVUID)
+		return inHome, inWork
+	}
+	if inHome, inWork := written("Врач"); !inHome || inWork {
+		t.Errorf("«Врач» written to home %v, work %v", inHome, inWork)
+	}
+	if _, err := calendars.SetMode(f.owner.ID, homeCal.ID, domain.CalendarReadOnly); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := calendars.SetMode(f.owner.ID, workCal.ID, domain.CalendarTwoWay); err != nil {
+		t.Fatal(err)
+	}
+	if inHome, inWork := written("Отчёт"); inHome || !inWork {
+		t.Errorf("«Отчёт» written to home %v, work %v", inHome, inWork)
+	}
+
+	// Чужие аккаунты и календари недоступны, даже в своей семье
+	if _, err := calendars.LinkCalendar(f.partner.ID, homeAccount.ID, home.CalendarPath(), "Дом"); err == nil {
+		t.Error("partner linked the owner's calendar")
+	}
+	if _, err := calendars.SetShared(f.partner.ID, homeCal.ID, true); err == nil {
+		t.Error("partner shared the owner's calendar")
+	}
+	if err := calendars.DeleteAccount(f.stranger.ID, workAccount.ID); err == nil {
+		t.Error("stranger deleted the owner's account")
+	}
+
+	// Удаление аккаунта уносит его календари и события
+	if err := calendars.DeleteAccount(f.owner.ID, workAccount.ID); err != nil {
+		t.Fatal(err)
+	}
+	linked, err := calendars.Calendars(f.owner.ID)
+	if err != nil || len(linked) != 1 || linked[0].ID != homeCal.ID {
+		t.Errorf("calendars after delete %+v, %v", linked, err)
+	}
+	events, err := f.store.ListCalendarEventsByCalendar(workCal.ID)
+	if err != nil || len(events) != 0 {
+		t.Errorf("events of the deleted account %+v, %v", events, err)
+	}
+	var titles []string
+	all, err := calendars.ListRange(f.owner.ID, start.Add(-time.Hour), start.Add(time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, e := range all {
+		titles = append(titles, e.Title)
+	}
+	slices.Sort(titles)
+	if !slices.Equal(titles, []string{"Врач", "Ужин"}) {
+		t.Errorf("events left %q, want [Врач Ужин]", titles)
+	}
+}
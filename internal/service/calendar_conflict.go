@@ -0,0 +1,390 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"html"
+	"log"
+	"strings"
+
+	"github.com/tazhate/familybot/internal/clients/caldav"
+	"github.com/tazhate/familybot/internal/domain"
+)
+
+// Двусторонняя синхронизация: правка в боте помечает событие Pending и пишется в календарь
+// условно (If-Match), чтобы не затереть правку из календаря. Если календарь успел измениться,
+// версии сливаются по полям относительно снимка последней синхронизации; поле, изменённое
+// с обеих сторон по-разному, — конфликт, его решает владелец кнопками в Telegram.
+
+// ConflictError is returned when the change can't be applied until the owner resolves the conflict
+type ConflictError struct {
+	Conflict *domain.CalendarConflict
+}
+
+func (e *ConflictError) Error() string {
+	return "событие изменили и в календаре: выбери, какую версию оставить"
+}
+
+// errConflictOpen — правка события с нерешённым конфликтом
+var errConflictOpen = errors.New("у события нерешённый конфликт с календарём: сначала выбери версию")
+
+// Conflicts returns the user's open conflicts
+func (s *CalendarService) Conflicts(userID int64) ([]*domain.CalendarConflict, error) {
+	return s.storage.ListCalendarConflicts(userID)
+}
+
+// conflictsByEvent returns the user's open conflicts by event ID
+func (s *CalendarService) conflictsByEvent(userID int64) (map[int64]*domain.CalendarConflict, error) {
+	conflicts, err := s.storage.ListCalendarConflicts(userID)
+	if err != nil {
+		return nil, fmt.Errorf("list calendar conflicts: %w", err)
+	}
+	byEvent := make(map[int64]*domain.CalendarConflict, len(conflicts))
+	for _, c := range conflicts {
+		byEvent[c.EventID] = c
+	}
+	return byEvent, nil
+}
+
+func (s *CalendarService) hasConflict(event *domain.CalendarEvent) (bool, error) {
+	conflicts, err := s.conflictsByEvent(event.UserID)
+	if err != nil {
+		return false, err
+	}
+	return conflicts[event.ID] != nil, nil
+}
+
+// saveSnapshot remembers the event as it is on the server now
+func (s *CalendarService) saveSnapshot(event *domain.CalendarEvent) {
+	snap := &domain.CalendarEventSnapshot{EventID: event.ID, Fields: event.Fields(), Sequence: event.Sequence}
+	if err := s.storage.SaveCalendarEventSnapshot(snap); err != nil {
+		log.Printf("calendar: save snapshot of event %d: %v", event.ID, err)
+	}
+}
+
+// objectPath returns the path of the event's object; events synced before v15 know only the UID
+func objectPath(cal *domain.Calendar, event *domain.CalendarEvent) string {
+	if event.CalDAVPath != "" {
+		return event.CalDAVPath
+	}
+	return strings.TrimSuffix(cal.Path, "/") + "/" + event.CalDAVUID + ".ics"
+}
+
+// pushEvent writes the event to its calendar if the object there is still of event.ETag
+// version. On success the event is no longer pending and becomes the new snapshot.
+// Returns caldav.ErrPreconditionFailed if the calendar changed the object meanwhile.
+func (s *CalendarService) pushEvent(cal *domain.Calendar, client *caldav.Client, event *domain.CalendarEvent) error {
+	appleEvent := &caldav.Event{
+		UID:         event.CalDAVUID,
+		Path:        objectPath(cal, event),
+		Summary:     event.Title,
+		Description: event.Description,
+		Location:    event.Location,
+		StartTime:   event.StartTime,
+		EndTime:     event.EndTime,
+		AllDay:      event.AllDay,
+		Sequence:    event.Sequence + 1,
+	}
+	if err := client.PutEventIfMatch(appleEvent, event.ETag); err != nil {
+		return err
+	}
+
+	now := s.clock.Now()
+	event.CalDAVPath = appleEvent.Path
+	event.ETag = appleEvent.ETag
+	event.Sequence = appleEvent.Sequence
+	event.Pending = false
+	event.SyncedAt = &now
+	if err := s.storage.UpdateCalendarEvent(event); err != nil {
+		return fmt.Errorf("update local event: %w", err)
+	}
+	s.saveSnapshot(event)
+	return nil
+}
+
+// pushPending writes the events changed in the bot whose objects the server didn't report
+// as changed. A failed write stays pending until the next sync.
+func (s *CalendarService) pushPending(cal *domain.Calendar, client *caldav.Client, events []*domain.CalendarEvent) {
+	for _, event := range events {
+		err := s.pushEvent(cal, client, event)
+		switch {
+		case errors.Is(err, caldav.ErrPreconditionFailed):
+			// Календарь изменил объект после запроса изменений — сольём в следующий раз
+			log.Printf("calendar sync: %s: event %d changed meanwhile, merging next time", cal.Name, event.ID)
+		case err != nil:
+			log.Printf("calendar sync: %s: push event %d: %v", cal.Name, event.ID, err)
+		}
+	}
+}
+
+// mergeRemote merges the calendar's version into the event changed in the bot: fields changed
+// on one side are combined and the result is written back. Fields changed on both sides
+// differently keep the bot's value and become a conflict. Without a snapshot the newer side
+// wins: by SEQUENCE or by LAST-MODIFIED against the time of the edit in the bot.
+func (s *CalendarService) mergeRemote(cal *domain.Calendar, client *caldav.Client, local *domain.CalendarEvent, ae *caldav.Event) (*domain.CalendarConflict, error) {
+	remote := eventFields(ae)
+	snap, err := s.storage.GetCalendarEventSnapshot(local.ID)
+	if err != nil {
+		return nil, fmt.Errorf("get snapshot: %w", err)
+	}
+
+	var merged domain.EventFields
+	var conflicting []domain.EventField
+	switch {
+	case snap != nil:
+		merged, conflicting = domain.MergeEventFields(snap.Fields, local.Fields(), remote)
+	case ae.Sequence > local.Sequence || ae.LastModified.After(local.UpdatedAt):
+		merged = remote
+	default:
+		merged = local.Fields()
+	}
+
+	local.CalDAVUID = ae.UID
+	local.CalDAVPath = ae.Path
+	local.SetFields(merged)
+	if len(conflicting) > 0 {
+		// Событие остаётся в слитом виде и ждёт решения; etag и снимок — прежние
+		if err := s.storage.UpdateCalendarEvent(local); err != nil {
+			return nil, fmt.Errorf("update local event: %w", err)
+		}
+		conflict := &domain.CalendarConflict{
+			EventID:        local.ID,
+			UserID:         local.UserID,
+			Remote:         remote,
+			RemoteETag:     ae.ETag,
+			RemoteSequence: ae.Sequence,
+			Fields:         conflicting,
+		}
+		if err := s.storage.CreateCalendarConflict(conflict); err != nil {
+			return nil, fmt.Errorf("create conflict: %w", err)
+		}
+		return conflict, nil
+	}
+
+	return nil, s.rebase(cal, client, local, ae.ETag, ae.Sequence, remote)
+}
+
+// rebase makes the calendar's version the common ancestor of the event and writes the bot's
+// changes on top of it, if there are any left
+func (s *CalendarService) rebase(cal *domain.Calendar, client *caldav.Client, event *domain.CalendarEvent, etag string, sequence int, remote domain.EventFields) error {
+	now := s.clock.Now()
+	event.ETag = etag
+	event.Sequence = max(event.Sequence, sequence)
+	event.Pending = !event.Fields().Equal(remote)
+	event.SyncedAt = &now
+	if err := s.storage.UpdateCalendarEvent(event); err != nil {
+		return fmt.Errorf("update local event: %w", err)
+	}
+	snap := &domain.CalendarEventSnapshot{EventID: event.ID, Fields: remote, Sequence: sequence}
+	if err := s.storage.SaveCalendarEventSnapshot(snap); err != nil {
+		return fmt.Errorf("save snapshot: %w", err)
+	}
+	if event.Pending && cal != nil {
+		return s.pushEvent(cal, client, event)
+	}
+	return nil
+}
+
+// refreshConflict handles a new version of an event with an open conflict; ae is nil if the
+// calendar deleted the object. Returns the conflict that replaces the old one, if any.
+func (s *CalendarService) refreshConflict(cal *domain.Calendar, client *caldav.Client, conflict *domain.CalendarConflict, local *domain.CalendarEvent, ae *caldav.Event) (*domain.CalendarConflict, error) {
+	switch {
+	case conflict.LocalDeleted && ae == nil:
+		// Удалено с обеих сторон — решать нечего
+		return nil, s.storage.DeleteCalendarEvent(local.ID)
+	case conflict.LocalDeleted:
+		if conflict.RemoteETag == ae.ETag {
+			return nil, nil
+		}
+		conflict.Remote = eventFields(ae)
+		conflict.RemoteETag = ae.ETag
+		conflict.RemoteSequence = ae.Sequence
+		conflict.Fields = local.Fields().Diff(conflict.Remote)
+		return nil, s.storage.UpdateCalendarConflict(conflict)
+	case ae == nil:
+		if conflict.RemoteDeleted {
+			return nil, nil
+		}
+		conflict.RemoteDeleted = true
+		conflict.RemoteETag = ""
+		return nil, s.storage.UpdateCalendarConflict(conflict)
+	case !conflict.RemoteDeleted && conflict.RemoteETag == ae.ETag:
+		return nil, nil
+	}
+
+	// Календарь снова изменил событие — сливаем заново с его новой версией
+	if err := s.storage.DeleteCalendarConflict(conflict.ID); err != nil {
+		return nil, fmt.Errorf("delete conflict: %w", err)
+	}
+	return s.mergeRemote(cal, client, local, ae)
+}
+
+// remoteDeleteConflict records that the event changed in the bot was deleted in the calendar
+func (s *CalendarService) remoteDeleteConflict(local *domain.CalendarEvent) (*domain.CalendarConflict, error) {
+	conflict := &domain.CalendarConflict{
+		EventID:       local.ID,
+		UserID:        local.UserID,
+		Remote:        local.Fields(),
+		RemoteDeleted: true,
+	}
+	if err := s.storage.CreateCalendarConflict(conflict); err != nil {
+		return nil, fmt.Errorf("create conflict: %w", err)
+	}
+	return conflict, nil
+}
+
+// localDeleteConflict records that the event deleted in the bot was changed in the calendar.
+// Nil if the object is already gone from the calendar too.
+func (s *CalendarService) localDeleteConflict(cal *domain.Calendar, client *caldav.Client, event *domain.CalendarEvent) (*domain.CalendarConflict, error) {
+	ae, err := client.GetEvent(objectPath(cal, event))
+	if err != nil || ae == nil {
+		return nil, err
+	}
+	conflict := &domain.CalendarConflict{
+		EventID:        event.ID,
+		UserID:         event.UserID,
+		Remote:         eventFields(ae),
+		RemoteETag:     ae.ETag,
+		RemoteSequence: ae.Sequence,
+		LocalDeleted:   true,
+	}
+	conflict.Fields = event.Fields().Diff(conflict.Remote)
+	if err := s.storage.CreateCalendarConflict(conflict); err != nil {
+		return nil, fmt.Errorf("create conflict: %w", err)
+	}
+	return conflict, nil
+}
+
+// ResolveConflict applies the owner's choice for the conflicting fields: keepLocal keeps the
+// bot's values, otherwise the calendar's values are taken. Changes made on one side only
+// stay merged. Returns the event, nil if it was deleted.
+func (s *CalendarService) ResolveConflict(userID, conflictID int64, keepLocal bool) (*domain.CalendarEvent, error) {
+	conflict, err := s.storage.GetCalendarConflict(conflictID)
+	if err != nil {
+		return nil, fmt.Errorf("get conflict: %w", err)
+	}
+	if conflict == nil || conflict.UserID != userID {
+		return nil, fmt.Errorf("конфликт уже решён")
+	}
+	event, err := s.storage.GetCalendarEvent(conflict.EventID)
+	if err != nil {
+		return nil, fmt.Errorf("get event: %w", err)
+	}
+	if event == nil {
+		return nil, s.storage.DeleteCalendarConflict(conflict.ID)
+	}
+
+	var cal *domain.Calendar
+	var client *caldav.Client
+	if keepLocal || !conflict.RemoteDeleted {
+		if cal, client, err = s.eventCalendar(event); err != nil {
+			return nil, err
+		}
+	}
+
+	switch {
+	case conflict.LocalDeleted && keepLocal:
+		if cal != nil {
+			err = client.DeleteObjectIfMatch(objectPath(cal, event), conflict.RemoteETag)
+		}
+		if err == nil {
+			return nil, s.storage.DeleteCalendarEvent(event.ID)
+		}
+	case conflict.LocalDeleted:
+		// Событие возвращается в версии календаря
+		event.SetFields(conflict.Remote)
+		err = s.rebase(cal, client, event, conflict.RemoteETag, conflict.RemoteSequence, conflict.Remote)
+	case conflict.RemoteDeleted && keepLocal:
+		// Создаём объект заново — без If-Match
+		event.ETag = ""
+		if cal != nil {
+			err = s.pushEvent(cal, client, event)
+		}
+	case conflict.RemoteDeleted:
+		return nil, s.storage.DeleteCalendarEvent(event.ID)
+	default:
+		fields := event.Fields()
+		if !keepLocal {
+			for _, f := range conflict.Fields {
+				f.Copy(&fields, conflict.Remote)
+			}
+		}
+		event.SetFields(fields)
+		err = s.rebase(cal, client, event, conflict.RemoteETag, conflict.RemoteSequence, conflict.Remote)
+	}
+	if errors.Is(err, caldav.ErrPreconditionFailed) {
+		return nil, fmt.Errorf("событие снова изменили в календаре — после синхронизации выбери ещё раз")
+	}
+	if err != nil {
+		return nil, err
+	}
+	return event, s.storage.DeleteCalendarConflict(conflict.ID)
+}
+
+// FormatConflict describes the conflict for the owner
+func (s *CalendarService) FormatConflict(conflict *domain.CalendarConflict) string {
+	event, err := s.storage.GetCalendarEvent(conflict.EventID)
+	if err != nil || event == nil {
+		return "⚠️ Конфликт с календарём: событие не найдено"
+	}
+
+	var sb strings.Builder
+	sb.WriteString("⚠️ <b>Конфликт с календарём")
+	if event.CalendarID != nil {
+		if cal, _ := s.storage.GetCalendar(*event.CalendarID); cal != nil {
+			sb.WriteString(" " + html.EscapeString(cal.Label()))
+		}
+	}
+	sb.WriteString("</b>\n\n")
+
+	title := html.EscapeString(event.Title)
+	switch {
+	case conflict.RemoteDeleted:
+		sb.WriteString(fmt.Sprintf("«%s» изменили здесь, а в календаре удалили.", title))
+		return sb.String()
+	case conflict.LocalDeleted:
+		sb.WriteString(fmt.Sprintf("«%s» удалили здесь, а в календаре изменили:\n", title))
+	default:
+		sb.WriteString(fmt.Sprintf("«%s» изменили и здесь, и в календаре:\n", title))
+	}
+
+	local := event.Fields()
+	for _, f := range conflict.Fields {
+		sb.WriteString(fmt.Sprintf("• %s: %s · в календаре %s\n", f.Name(),
+			html.EscapeString(formatEventField(f, local)), html.EscapeString(formatEventField(f, conflict.Remote))))
+	}
+	return sb.String()
+}
+
+// formatEventField formats the value of the field for the conflict message
+func formatEventField(f domain.EventField, v domain.EventFields) string {
+	var s string
+	switch f {
+	case domain.EventFieldTitle:
+		s = v.Title
+	case domain.EventFieldDescription:
+		s = v.Description
+	case domain.EventFieldLocation:
+		s = v.Location
+	case domain.EventFieldTime:
+		if v.AllDay {
+			return v.StartTime.Format("02.01") + " весь день"
+		}
+		return v.StartTime.Format("02.01 15:04") + "–" + v.EndTime.Format("15:04")
+	}
+	if s == "" {
+		return "—"
+	}
+	return "«" + s + "»"
+}
+
+func eventFields(ae *caldav.Event) domain.EventFields {
+	return domain.EventFields{
+		Title:       ae.Summary,
+		Description: ae.Description,
+		Location:    ae.Location,
+		StartTime:   ae.StartTime,
+		EndTime:     ae.EndTime,
+		AllDay:      ae.AllDay,
+	}
+}
@@ -0,0 +1,204 @@
+package service_test
+
+import (
+	"slices"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/tazhate/familybot/internal/domain"
+)
+
+var dinner = domain.EventFields{
+	Title:     "Ужин",
+	Location:  "Дома",
+	StartTime: time.Date(2030, time.March, 12, 19, 0, 0, 0, moscow),
+	EndTime:   time.Date(2030, time.March, 12, 20, 0, 0, 0, moscow),
+}
+
+func edited(base domain.EventFields, change func(*domain.EventFields)) domain.EventFields {
+	change(&base)
+	return base
+}
+
+func retitle(title string) func(*domain.EventFields) {
+	return func(f *domain.EventFields) { f.Title = title }
+}
+
+func relocate(location string) func(*domain.EventFields) {
+	return func(f *domain.EventFields) { f.Location = location }
+}
+
+// TestCalendarMerge edits a synced event in the bot and in the calendar and syncs again
+func TestCalendarMerge(t *testing.T) {
+	tests := []struct {
+		name         string
+		local        func(*domain.EventFields) // правка в боте, nil — без правки
+		remote       func(*domain.EventFields) // правка в календаре, nil — без правки
+		remoteDelete bool
+
+		want          domain.EventFields  // событие в боте после синхронизации
+		wantRemote    *domain.EventFields // событие в календаре, nil — удалено
+		conflicts     []domain.EventField
+		remoteDeleted bool
+	}{
+		{
+			name:       "local-only edit",
+			local:      retitle("Ужин с мамой"),
+			want:       edited(dinner, retitle("Ужин с мамой")),
+			wantRemote: ptr(edited(dinner, retitle("Ужин с мамой"))),
+		},
+		{
+			name:       "remote-only edit",
+			remote:     relocate("Ресторан"),
+			want:       edited(dinner, relocate("Ресторан")),
+			wantRemote: ptr(edited(dinner, relocate("Ресторан"))),
+		},
+		{
+			name:       "same field on both sides",
+			local:      retitle("Ужин с мамой"),
+			remote:     retitle("Ужин с друзьями"),
+			want:       edited(dinner, retitle("Ужин с мамой")),
+			wantRemote: ptr(edited(dinner, retitle("Ужин с друзьями"))),
+			conflicts:  []domain.EventField{domain.EventFieldTitle},
+		},
+		{
+			name:       "different fields auto-merge",
+			local:      retitle("Ужин с мамой"),
+			remote:     relocate("Ресторан"),
+			want:       edited(edited(dinner, retitle("Ужин с мамой")), relocate("Ресторан")),
+			wantRemote: ptr(edited(edited(dinner, retitle("Ужин с мамой")), relocate("Ресторан"))),
+		},
+		{
+			name:          "remote delete vs local edit",
+			local:         retitle("Ужин с мамой"),
+			remoteDelete:  true,
+			want:          edited(dinner, retitle("Ужин с мамой")),
+			remoteDeleted: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := newCalendarFixture(t)
+			f.putEvent("dinner", dinner)
+			f.sync()
+
+			if tt.remote != nil {
+				f.putEvent("dinner", edited(dinner, tt.remote))
+			}
+			if tt.remoteDelete {
+				f.server.DeleteEvent("dinner")
+			}
+			if tt.local != nil {
+				event := f.event("dinner")
+				event.SetFields(edited(event.Fields(), tt.local))
+				if err := f.calendars.UpdateEvent(event); err != nil {
+					t.Fatalf("update event: %v", err)
+				}
+			}
+
+			result := f.sync()
+
+			event := f.event("dinner")
+			if event == nil {
+				t.Fatal("event was deleted in the bot")
+			}
+			if got := event.Fields(); !got.Equal(tt.want) {
+				t.Errorf("bot has %+v, want %+v", got, tt.want)
+			}
+			ae := f.remote("dinner")
+			switch {
+			case tt.wantRemote == nil && ae != nil:
+				t.Errorf("calendar still has %q", ae.Summary)
+			case tt.wantRemote != nil && ae == nil:
+				t.Errorf("calendar lost the event")
+			case tt.wantRemote != nil:
+				if got := (domain.EventFields{Title: ae.Summary, Location: ae.Location, Description: ae.Description, StartTime: ae.StartTime, EndTime: ae.EndTime, AllDay: ae.AllDay}); !got.Equal(*tt.wantRemote) {
+					t.Errorf("calendar has %+v, want %+v", got, *tt.wantRemote)
+				}
+			}
+
+			wantConflict := len(tt.conflicts) > 0 || tt.remoteDeleted
+			if !wantConflict {
+				if len(result.Conflicts) > 0 {
+					t.Fatalf("unexpected conflicts %+v", result.Conflicts[0])
+				}
+				if event.Pending {
+					t.Error("merged event is still pending")
+				}
+				return
+			}
+			if len(result.Conflicts) != 1 {
+				t.Fatalf("conflicts %d, want 1", len(result.Conflicts))
+			}
+			conflict := result.Conflicts[0]
+			if conflict.EventID != event.ID || conflict.RemoteDeleted != tt.remoteDeleted || !slices.Equal(conflict.Fields, tt.conflicts) {
+				t.Errorf("conflict on event %d, remote deleted %v, fields %v; want %d, %v, %v",
+					conflict.EventID, conflict.RemoteDeleted, conflict.Fields, event.ID, tt.remoteDeleted, tt.conflicts)
+			}
+			open, err := f.calendars.Conflicts(f.user.ID)
+			if err != nil || len(open) != 1 {
+				t.Errorf("open conflicts %d, %v; want 1", len(open), err)
+			}
+
+			// Пока конфликт открыт, повторная синхронизация его не трогает
+			if again := f.sync(); len(again.Conflicts) != 0 {
+				t.Errorf("second sync raised %d more conflicts", len(again.Conflicts))
+			}
+		})
+	}
+}
+
+// TestCalendarMergeWithoutSnapshot merges an event synced before snapshots were kept:
+// the newer side wins as a whole, by SEQUENCE or by LAST-MODIFIED against the bot's edit
+func TestCalendarMergeWithoutSnapshot(t *testing.T) {
+	local := edited(dinner, retitle("Ужин с мамой"))
+	remote := edited(dinner, relocate("Ресторан"))
+
+	tests := []struct {
+		name         string
+		sequence     int
+		lastModified time.Time
+		want         domain.EventFields
+	}{
+		{"higher SEQUENCE wins over older LAST-MODIFIED", 2, time.Date(2020, time.January, 1, 0, 0, 0, 0, time.UTC), remote},
+		{"newer LAST-MODIFIED wins", 0, time.Date(2099, time.January, 1, 0, 0, 0, 0, time.UTC), remote},
+		{"bot's edit is newer", 0, time.Date(2020, time.January, 1, 0, 0, 0, 0, time.UTC), local},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := newCalendarFixture(t)
+			f.putEvent("dinner", remote, "SEQUENCE:"+strconv.Itoa(tt.sequence), "LAST-MODIFIED:"+tt.lastModified.Format("20060102T150405Z"))
+
+			syncedAt := f.clock.Now().Add(-24 * time.Hour)
+			event := &domain.CalendarEvent{
+				UserID:     f.user.ID,
+				CalendarID: &f.cal.ID,
+				CalDAVUID:  "dinner",
+				CalDAVPath: f.server.CalendarPath() + "dinner.ics",
+				ETag:       "etag-before-snapshots",
+				Pending:    true,
+				SyncedAt:   &syncedAt,
+			}
+			event.SetFields(local)
+			if err := f.store.CreateCalendarEvent(event); err != nil {
+				t.Fatal(err)
+			}
+
+			if result := f.sync(); len(result.Conflicts) != 0 {
+				t.Fatalf("unexpected conflicts %+v", result.Conflicts[0])
+			}
+
+			if got := f.event("dinner").Fields(); !got.Equal(tt.want) {
+				t.Errorf("bot has %+v, want %+v", got, tt.want)
+			}
+			if ae := f.remote("dinner"); ae == nil || ae.Summary != tt.want.Title || ae.Location != tt.want.Location {
+				t.Errorf("calendar has %+v, want %q at %q", ae, tt.want.Title, tt.want.Location)
+			}
+		})
+	}
+}
+
+func ptr[T any](v T) *T {
+	return &v
+}
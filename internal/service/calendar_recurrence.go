@@ -0,0 +1,62 @@
+package service
+
+import (
+	"errors"
+	"log"
+	"time"
+
+	"github.com/tazhate/familybot/internal/clients/caldav"
+	"github.com/tazhate/familybot/internal/domain"
+)
+
+// Повторяющиеся события календаря хранятся экземплярами в окне синхронизации: месяц
+// назад и год вперёд. Окно сдвигается раз в сутки — тогда серии разворачиваются заново.
+
+const (
+	recurrenceDaysPast   = 30
+	recurrenceYearsAhead = 1
+)
+
+// errOccurrence — правка одного экземпляра повторяющегося события: бот пишет в календарь
+// объект целиком и затёр бы всю серию
+var errOccurrence = errors.New("это повторяющееся событие — измени его в календаре")
+
+// recurrenceWindow returns the window of occurrences at the moment; it moves at midnight
+// in the bot's timezone
+func (s *CalendarService) recurrenceWindow(at time.Time) (from, to time.Time) {
+	local := at.In(s.timezone)
+	day := time.Date(local.Year(), local.Month(), local.Day(), 0, 0, 0, 0, s.timezone)
+	return day.AddDate(0, 0, -recurrenceDaysPast), day.AddDate(recurrenceYearsAhead, 0, 0)
+}
+
+// seriesToExpand returns the objects to expand again after the window moved from prevTo
+// to to: the known series and the objects with events in the part of the window that
+// just opened, e.g. a series starting next year
+func (s *CalendarService) seriesToExpand(client *caldav.Client, cal *domain.Calendar, localByPath map[string][]*domain.CalendarEvent, prevTo, to time.Time) []string {
+	var paths []string
+	for path, locals := range localByPath {
+		if locals[0].IsOccurrence() {
+			paths = append(paths, path)
+		}
+	}
+
+	// Без запроса по времени новые серии появятся, когда их изменят в календаре
+	refs, err := client.QueryObjects(cal.Path, prevTo, to)
+	if err != nil {
+		log.Printf("calendar sync: %s: query new occurrences: %v", cal.Name, err)
+	}
+	for _, ref := range refs {
+		if len(localByPath[ref.Path]) == 0 {
+			paths = append(paths, ref.Path)
+		}
+	}
+	return paths
+}
+
+// occurrenceKey identifies the event of a calendar object: UID and the occurrence of the series
+func occurrenceKey(uid, recurrenceID string) string {
+	if recurrenceID == "" {
+		return uid
+	}
+	return uid + "#" + recurrenceID
+}
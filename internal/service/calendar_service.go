@@ -1,56 +1,45 @@
 package service
 
 import (
+	"errors"
 	"fmt"
+	"log"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/tazhate/familybot/internal/clients/caldav"
+	"github.com/tazhate/familybot/internal/clock"
 	"github.com/tazhate/familybot/internal/domain"
 	"github.com/tazhate/familybot/internal/storage"
 )
 
-// CalendarService handles calendar operations and syncing with Apple Calendar
+// CalendarService handles calendar operations and syncing with the linked CalDAV calendars
 type CalendarService struct {
-	storage      *storage.Storage
-	caldavClient *caldav.Client
-	calendarPath string         // Path to the calendar to sync
-	ownerUserID  int64          // Owner user ID for new events
-	timezone     *time.Location // Timezone for event times
+	storage  storage.Store
+	timezone *time.Location // Timezone for event times
+	clock    clock.Clock
+
+	mu      sync.Mutex
+	clients map[int64]*caldav.Client // CalDAV clients by account ID
 }
 
 // NewCalendarService creates a new calendar service
-func NewCalendarService(s *storage.Storage, client *caldav.Client, ownerUserID int64, tz *time.Location) *CalendarService {
+func NewCalendarService(s storage.Store, tz *time.Location) *CalendarService {
 	if tz == nil {
 		tz = time.UTC
 	}
 	return &CalendarService{
-		storage:      s,
-		caldavClient: client,
-		ownerUserID:  ownerUserID,
-		timezone:     tz,
+		storage:  s,
+		timezone: tz,
+		clock:    clock.Real(),
+		clients:  make(map[int64]*caldav.Client),
 	}
 }
 
-// IsConfigured returns true if CalDAV client is configured
-func (s *CalendarService) IsConfigured() bool {
-	return s.caldavClient != nil && s.caldavClient.IsConfigured()
-}
-
-// SetCalendarPath sets the calendar path to use for sync
-func (s *CalendarService) SetCalendarPath(path string) {
-	s.calendarPath = path
-	if s.caldavClient != nil {
-		s.caldavClient.SetCalendarID(path)
-	}
-}
-
-// DiscoverCalendars returns available calendars from Apple
-func (s *CalendarService) DiscoverCalendars() ([]caldav.Calendar, error) {
-	if !s.IsConfigured() {
-		return nil, fmt.Errorf("CalDAV not configured")
-	}
-	return s.caldavClient.DiscoverCalendars()
+// SetClock replaces the system clock, e.g. to simulate days in tests
+func (s *CalendarService) SetClock(c clock.Clock) {
+	s.clock = c
 }
 
 // SyncResult contains sync operation results
@@ -59,105 +48,8 @@ type SyncResult struct {
 	Updated int
 	Deleted int
 	Errors  []string
-}
-
-// SyncFromApple syncs events from Apple Calendar to local storage
-func (s *CalendarService) SyncFromApple() (*SyncResult, error) {
-	if !s.IsConfigured() {
-		return nil, fmt.Errorf("CalDAV not configured")
-	}
-
-	if s.calendarPath == "" {
-		return nil, fmt.Errorf("calendar path not set")
-	}
-
-	result := &SyncResult{}
 
-	// Get events for next 90 days
-	from := time.Now().Truncate(24 * time.Hour)
-	to := from.AddDate(0, 3, 0) // 3 months ahead
-
-	appleEvents, err := s.caldavClient.GetEvents(s.calendarPath, from, to)
-	if err != nil {
-		return nil, fmt.Errorf("get events from Apple: %w", err)
-	}
-
-	// Get existing local events
-	localEvents, err := s.storage.ListAllCalendarEvents()
-	if err != nil {
-		return nil, fmt.Errorf("get local events: %w", err)
-	}
-
-	// Create a map of local events by CalDAV UID
-	localByUID := make(map[string]*domain.CalendarEvent)
-	for _, e := range localEvents {
-		if e.CalDAVUID != "" {
-			localByUID[e.CalDAVUID] = e
-		}
-	}
-
-	// Track which UIDs we've seen from Apple
-	seenUIDs := make(map[string]bool)
-	now := time.Now()
-
-	// Process Apple events
-	for _, ae := range appleEvents {
-		seenUIDs[ae.UID] = true
-
-		local, exists := localByUID[ae.UID]
-		if exists {
-			// Update existing event if changed
-			if s.eventChanged(local, &ae) {
-				local.Title = ae.Summary
-				local.Description = ae.Description
-				local.Location = ae.Location
-				local.StartTime = ae.StartTime
-				local.EndTime = ae.EndTime
-				local.AllDay = ae.AllDay
-				local.SyncedAt = &now
-
-				if err := s.storage.UpdateCalendarEvent(local); err != nil {
-					result.Errors = append(result.Errors, fmt.Sprintf("update %s: %v", ae.UID, err))
-				} else {
-					result.Updated++
-				}
-			}
-		} else {
-			// Create new local event
-			event := &domain.CalendarEvent{
-				UserID:      s.ownerUserID,
-				CalDAVUID:   ae.UID,
-				Title:       ae.Summary,
-				Description: ae.Description,
-				Location:    ae.Location,
-				StartTime:   ae.StartTime,
-				EndTime:     ae.EndTime,
-				AllDay:      ae.AllDay,
-				IsShared:    false, // Don't auto-share calendar events to avoid duplication
-				SyncedAt:    &now,
-			}
-
-			if err := s.storage.CreateCalendarEvent(event); err != nil {
-				result.Errors = append(result.Errors, fmt.Sprintf("create %s: %v", ae.UID, err))
-			} else {
-				result.Added++
-			}
-		}
-	}
-
-	// Delete local events that no longer exist in Apple
-	for uid, local := range localByUID {
-		if !seenUIDs[uid] && local.SyncedAt != nil {
-			// Only delete if it was synced from Apple (has SyncedAt)
-			if err := s.storage.DeleteCalendarEvent(local.ID); err != nil {
-				result.Errors = append(result.Errors, fmt.Sprintf("delete %s: %v", uid, err))
-			} else {
-				result.Deleted++
-			}
-		}
-	}
-
-	return result, nil
+	Conflicts []*domain.CalendarConflict // новые конфликты правок, ждут решения владельцев
 }
 
 // eventChanged checks if Apple event differs from local
@@ -183,7 +75,7 @@ func (s *CalendarService) eventChanged(local *domain.CalendarEvent, apple *calda
 	return false
 }
 
-// CreateEvent creates a new event locally and syncs to Apple
+// CreateEvent creates a new event locally and writes it to the user's two-way calendar
 func (s *CalendarService) CreateEvent(userID int64, title string, startTime time.Time, endTime time.Time, location string, allDay bool) (*domain.CalendarEvent, error) {
 	event := &domain.CalendarEvent{
 		UserID:      userID,
@@ -195,13 +87,18 @@ func (s *CalendarService) CreateEvent(userID int64, title string, startTime time
 		IsShared:    true,
 	}
 
+	cal, client := s.writeCalendar(userID)
+	if cal != nil {
+		event.CalendarID = &cal.ID
+		event.IsShared = cal.IsShared
+	}
+
 	// Create locally first
 	if err := s.storage.CreateCalendarEvent(event); err != nil {
 		return nil, fmt.Errorf("create local event: %w", err)
 	}
 
-	// Sync to Apple if configured
-	if s.IsConfigured() && s.calendarPath != "" {
+	if cal != nil {
 		appleEvent := &caldav.Event{
 			Summary:     title,
 			Description: "",
@@ -211,55 +108,85 @@ func (s *CalendarService) CreateEvent(userID int64, title string, startTime time
 			AllDay:      allDay,
 		}
 
-		if err := s.caldavClient.CreateEvent(s.calendarPath, appleEvent); err != nil {
+		if err := client.CreateEvent(cal.Path, appleEvent); err != nil {
 			// Log error but don't fail - local event is created
-			fmt.Printf("Warning: failed to sync event to Apple: %v\n", err)
+			fmt.Printf("Warning: failed to write event to calendar %d: %v\n", cal.ID, err)
 		} else {
 			// Update local event with CalDAV UID
 			event.CalDAVUID = appleEvent.UID
-			now := time.Now()
+			event.CalDAVPath = appleEvent.Path
+			event.ETag = appleEvent.ETag
+			now := s.clock.Now()
 			event.SyncedAt = &now
-			_ = s.storage.UpdateCalendarEvent(event)
+			if err := s.storage.UpdateCalendarEvent(event); err == nil {
+				s.saveSnapshot(event)
+			}
 		}
 	}
 
 	return event, nil
 }
 
-// UpdateEvent updates an event locally and syncs to Apple
+// eventCalendar returns the linked calendar of a synced event, nil for local events.
+// Fails if the calendar is read-only or the event is an occurrence of a recurring one:
+// the change would be undone by the next sync.
+func (s *CalendarService) eventCalendar(event *domain.CalendarEvent) (*domain.Calendar, *caldav.Client, error) {
+	if event.CalendarID == nil || event.CalDAVUID == "" {
+		return nil, nil, nil
+	}
+	cal, err := s.storage.GetCalendar(*event.CalendarID)
+	if err != nil {
+		return nil, nil, fmt.Errorf("get calendar: %w", err)
+	}
+	if cal == nil {
+		return nil, nil, nil
+	}
+	if !cal.Writable() {
+		return nil, nil, fmt.Errorf("календарь «%s» подключён только для чтения", cal.Name)
+	}
+	if event.IsOccurrence() {
+		return nil, nil, errOccurrence
+	}
+	client, err := s.clientFor(cal.AccountID)
+	if err != nil {
+		return nil, nil, err
+	}
+	return cal, client, nil
+}
+
+// UpdateEvent updates an event locally and in its calendar. The calendar's object is
+// overwritten only if it didn't change since the last sync; otherwise the edit stays
+// pending and the next sync merges it with the calendar's changes.
 func (s *CalendarService) UpdateEvent(event *domain.CalendarEvent) error {
+	cal, client, err := s.eventCalendar(event)
+	if err != nil {
+		return err
+	}
+	if cal != nil {
+		if open, err := s.hasConflict(event); err != nil {
+			return err
+		} else if open {
+			return errConflictOpen
+		}
+		event.Pending = true
+	}
+
 	// Update locally first
 	if err := s.storage.UpdateCalendarEvent(event); err != nil {
 		return fmt.Errorf("update local event: %w", err)
 	}
 
-	// Sync to Apple if configured and event has CalDAV UID
-	if s.IsConfigured() && s.calendarPath != "" && event.CalDAVUID != "" {
-		appleEvent := &caldav.Event{
-			UID:         event.CalDAVUID,
-			Summary:     event.Title,
-			Description: event.Description,
-			Location:    event.Location,
-			StartTime:   event.StartTime,
-			EndTime:     event.EndTime,
-			AllDay:      event.AllDay,
-		}
-
-		if err := s.caldavClient.UpdateEvent(s.calendarPath, appleEvent); err != nil {
-			// Log error but don't fail - local event is updated
-			fmt.Printf("Warning: failed to sync event update to Apple: %v\n", err)
-		} else {
-			// Update sync time
-			now := time.Now()
-			event.SyncedAt = &now
-			_ = s.storage.UpdateCalendarEvent(event)
+	if cal != nil {
+		if err := s.pushEvent(cal, client, event); err != nil {
+			// Log error but don't fail - local event is updated and will be merged on sync
+			log.Printf("calendar: event %d stays pending in calendar %d: %v", event.ID, cal.ID, err)
 		}
 	}
 
 	return nil
 }
 
-// DeleteEvent deletes an event locally and from Apple
+// DeleteEvent deletes an event locally and from its calendar
 func (s *CalendarService) DeleteEvent(eventID int64, userID int64) error {
 	event, err := s.storage.GetCalendarEvent(eventID)
 	if err != nil {
@@ -269,25 +196,57 @@ func (s *CalendarService) DeleteEvent(eventID int64, userID int64) error {
 		return fmt.Errorf("event not found")
 	}
 
-	// Delete from Apple if synced
-	if s.IsConfigured() && event.CalDAVUID != "" && s.calendarPath != "" {
-		if err := s.caldavClient.DeleteEvent(s.calendarPath, event.CalDAVUID); err != nil {
-			// Log but continue with local delete
-			fmt.Printf("Warning: failed to delete from Apple: %v\n", err)
+	cal, client, err := s.eventCalendar(event)
+	if err != nil {
+		return err
+	}
+	if cal != nil {
+		if open, err := s.hasConflict(event); err != nil {
+			return err
+		} else if open {
+			return errConflictOpen
+		}
+		// Удаляем, только если в календаре событие не меняли после синхронизации,
+		// иначе его правка пропала бы молча
+		err := client.DeleteObjectIfMatch(objectPath(cal, event), event.ETag)
+		if errors.Is(err, caldav.ErrPreconditionFailed) {
+			conflict, err := s.localDeleteConflict(cal, client, event)
+			if err != nil {
+				return fmt.Errorf("get changed event: %w", err)
+			}
+			if conflict != nil {
+				return &ConflictError{Conflict: conflict}
+			}
+		} else if err != nil {
+			// Локально не удаляем: следующая синхронизация вернула бы событие
+			return fmt.Errorf("delete from calendar: %w", err)
 		}
 	}
 
 	return s.storage.DeleteCalendarEvent(eventID)
 }
 
+// GetVisibleEvent returns an event of the user or a shared one of the household, nil otherwise
+func (s *CalendarService) GetVisibleEvent(eventID int64, userID int64) (*domain.CalendarEvent, error) {
+	event, err := s.storage.GetCalendarEvent(eventID)
+	if err != nil || event == nil {
+		return nil, err
+	}
+	ok, err := visibleTo(s.storage, event.UserID, userID, event.IsShared)
+	if err != nil || !ok {
+		return nil, err
+	}
+	return event, nil
+}
+
 // ListToday returns today's events
 func (s *CalendarService) ListToday(userID int64) ([]*domain.CalendarEvent, error) {
-	return s.storage.ListCalendarEventsToday(userID, true)
+	return s.storage.ListCalendarEventsToday(userID, true, s.clock.Now())
 }
 
 // ListWeek returns this week's events
 func (s *CalendarService) ListWeek(userID int64) ([]*domain.CalendarEvent, error) {
-	return s.storage.ListCalendarEventsWeek(userID, true)
+	return s.storage.ListCalendarEventsWeek(userID, true, s.clock.Now())
 }
 
 // ListRange returns events in a date range
@@ -297,7 +256,7 @@ func (s *CalendarService) ListRange(userID int64, from, to time.Time) ([]*domain
 
 // GetUpcomingForReminder returns events starting within the next N minutes
 func (s *CalendarService) GetUpcomingForReminder(minutes int) ([]*domain.CalendarEvent, error) {
-	return s.storage.ListUpcomingCalendarEventsForReminder(minutes)
+	return s.storage.ListUpcomingCalendarEventsForReminder(minutes, s.clock.Now())
 }
 
 // FormatEventList formats events for display
@@ -308,6 +267,7 @@ func (s *CalendarService) FormatEventList(events []*domain.CalendarEvent) string
 
 	var sb strings.Builder
 	var currentDate string
+	markers := s.calendarMarkers(events)
 
 	for _, e := range events {
 		eventDate := e.StartTime.Format("02.01")
@@ -323,11 +283,12 @@ func (s *CalendarService) FormatEventList(events []*domain.CalendarEvent) string
 		}
 
 		// Format event line
+		title := markers[e.ID] + e.Title
 		var line string
 		if e.AllDay {
-			line = fmt.Sprintf("  🗓 %s", e.Title)
+			line = fmt.Sprintf("  🗓 %s", title)
 		} else {
-			line = fmt.Sprintf("  %s — %s", e.FormatTime(), e.Title)
+			line = fmt.Sprintf("  %s — %s", e.FormatTime(), title)
 		}
 
 		if e.Location != "" {
@@ -348,13 +309,15 @@ func (s *CalendarService) FormatTodayBriefing(events []*domain.CalendarEvent) st
 
 	var sb strings.Builder
 	sb.WriteString("📅 События сегодня:\n")
+	markers := s.calendarMarkers(events)
 
 	for _, e := range events {
+		title := markers[e.ID] + e.Title
 		var line string
 		if e.AllDay {
-			line = fmt.Sprintf("• %s (весь день)", e.Title)
+			line = fmt.Sprintf("• %s (весь день)", title)
 		} else {
-			line = fmt.Sprintf("• %s — %s", e.StartTime.Format("15:04"), e.Title)
+			line = fmt.Sprintf("• %s — %s", e.StartTime.Format("15:04"), title)
 		}
 
 		if e.Location != "" {
@@ -367,6 +330,35 @@ func (s *CalendarService) FormatTodayBriefing(events []*domain.CalendarEvent) st
 	return sb.String()
 }
 
+// calendarMarkers returns the colour marker of the source calendar by event ID.
+// Without events from two or more calendars the markers would be noise, so they are empty then.
+func (s *CalendarService) calendarMarkers(events []*domain.CalendarEvent) map[int64]string {
+	calendars := make(map[int64]*domain.Calendar)
+	for _, e := range events {
+		if e.CalendarID == nil {
+			continue
+		}
+		if _, ok := calendars[*e.CalendarID]; !ok {
+			cal, _ := s.storage.GetCalendar(*e.CalendarID)
+			calendars[*e.CalendarID] = cal
+		}
+	}
+
+	markers := make(map[int64]string)
+	if len(calendars) < 2 {
+		return markers
+	}
+	for _, e := range events {
+		if e.CalendarID == nil {
+			continue
+		}
+		if cal := calendars[*e.CalendarID]; cal != nil {
+			markers[e.ID] = cal.Color.Emoji() + " "
+		}
+	}
+	return markers
+}
+
 // TaskToEvent converts a task with due date to a calendar event
 func (s *CalendarService) TaskToEvent(task *domain.Task) *domain.CalendarEvent {
 	if task.DueDate == nil {
@@ -396,8 +388,9 @@ func (s *CalendarService) SyncTaskToCalendar(task *domain.Task) error {
 		return nil // Nothing to sync
 	}
 
-	if !s.IsConfigured() || s.calendarPath == "" {
-		return nil // CalDAV not configured
+	cal, client := s.writeCalendar(task.UserID)
+	if cal == nil {
+		return nil // No two-way calendar
 	}
 
 	// Create calendar event from task
@@ -408,10 +401,11 @@ func (s *CalendarService) SyncTaskToCalendar(task *domain.Task) error {
 		StartTime:   *task.DueDate,
 		EndTime:     task.DueDate.Add(time.Hour),
 		AllDay:      true,
+		RRule:       task.RecurrenceRule(),
 	}
 
-	if err := s.caldavClient.CreateEvent(s.calendarPath, appleEvent); err != nil {
-		return fmt.Errorf("sync task to Apple Calendar: %w", err)
+	if err := client.CreateEvent(cal.Path, appleEvent); err != nil {
+		return fmt.Errorf("sync task to calendar: %w", err)
 	}
 
 	return nil
@@ -419,21 +413,36 @@ func (s *CalendarService) SyncTaskToCalendar(task *domain.Task) error {
 
 // DeleteTaskFromCalendar removes calendar event for a completed/deleted task
 func (s *CalendarService) DeleteTaskFromCalendar(taskID int64) error {
-	if !s.IsConfigured() || s.calendarPath == "" {
-		return nil // CalDAV not configured
+	// Use the same UID format as SyncTaskToCalendar
+	if err := s.deleteFromWritable(fmt.Sprintf("task-%d@familybot", taskID)); err != nil {
+		return fmt.Errorf("delete task from calendar: %w", err)
 	}
+	return nil
+}
 
-	// Use the same UID format as SyncTaskToCalendar
-	uid := fmt.Sprintf("task-%d@familybot", taskID)
+// deleteFromWritable removes the object with the UID from every two-way calendar:
+// the owner of a task or a schedule event may have changed since it was written
+func (s *CalendarService) deleteFromWritable(uid string) error {
+	calendars, err := s.storage.ListAllCalendars()
+	if err != nil {
+		return fmt.Errorf("list calendars: %w", err)
+	}
 
-	if err := s.caldavClient.DeleteEvent(s.calendarPath, uid); err != nil {
+	var firstErr error
+	for _, cal := range calendars {
+		if !cal.Writable() {
+			continue
+		}
+		client, err := s.clientFor(cal.AccountID)
+		if err == nil {
+			err = client.DeleteEvent(cal.Path, uid)
+		}
 		// Don't fail if event doesn't exist
-		if !strings.Contains(err.Error(), "404") && !strings.Contains(err.Error(), "not found") {
-			return fmt.Errorf("delete task from Apple Calendar: %w", err)
+		if err != nil && !strings.Contains(err.Error(), "404") && !strings.Contains(err.Error(), "not found") && firstErr == nil {
+			firstErr = err
 		}
 	}
-
-	return nil
+	return firstErr
 }
 
 // weekdayToRRULE converts Go weekday to RRULE BYDAY format
@@ -445,10 +454,18 @@ func weekdayToRRULE(wd int) string {
 	return days[wd]
 }
 
-// SyncWeeklyEventToCalendar creates or updates a recurring event in Apple Calendar
+// SyncWeeklyEventToCalendar creates or updates a recurring event in the two-way calendar of the event's owner
 func (s *CalendarService) SyncWeeklyEventToCalendar(eventID int64, dayOfWeek int, timeStart, timeEnd, title string, isFloating bool, floatingDays []int) error {
-	if !s.IsConfigured() || s.calendarPath == "" {
-		return nil // CalDAV not configured
+	weekly, err := s.storage.GetWeeklyEvent(eventID)
+	if err != nil {
+		return fmt.Errorf("get weekly event: %w", err)
+	}
+	if weekly == nil {
+		return nil
+	}
+	cal, client := s.writeCalendar(weekly.UserID)
+	if cal == nil {
+		return nil // No two-way calendar
 	}
 
 	// Use configured timezone
@@ -458,7 +475,7 @@ func (s *CalendarService) SyncWeeklyEventToCalendar(eventID int64, dayOfWeek int
 	}
 
 	// Parse start time
-	now := time.Now().In(tz)
+	now := s.clock.Now().In(tz)
 	startHour, startMin := 0, 0
 	if timeStart != "" {
 		fmt.Sscanf(timeStart, "%d:%d", &startHour, &startMin)
@@ -506,27 +523,17 @@ func (s *CalendarService) SyncWeeklyEventToCalendar(eventID int64, dayOfWeek int
 		RRule:       rrule,
 	}
 
-	if err := s.caldavClient.CreateEvent(s.calendarPath, appleEvent); err != nil {
-		return fmt.Errorf("sync weekly event to Apple Calendar: %w", err)
+	if err := client.CreateEvent(cal.Path, appleEvent); err != nil {
+		return fmt.Errorf("sync weekly event to calendar: %w", err)
 	}
 
 	return nil
 }
 
-// DeleteWeeklyEventFromCalendar removes a recurring event from Apple Calendar
+// DeleteWeeklyEventFromCalendar removes a recurring event from the two-way calendars
 func (s *CalendarService) DeleteWeeklyEventFromCalendar(eventID int64) error {
-	if !s.IsConfigured() || s.calendarPath == "" {
-		return nil // CalDAV not configured
+	if err := s.deleteFromWritable(fmt.Sprintf("schedule-%d@familybot", eventID)); err != nil {
+		return fmt.Errorf("delete weekly event from calendar: %w", err)
 	}
-
-	uid := fmt.Sprintf("schedule-%d@familybot", eventID)
-
-	if err := s.caldavClient.DeleteEvent(s.calendarPath, uid); err != nil {
-		// Don't fail if event doesn't exist
-		if !strings.Contains(err.Error(), "404") && !strings.Contains(err.Error(), "not found") {
-			return fmt.Errorf("delete weekly event from Apple Calendar: %w", err)
-		}
-	}
-
 	return nil
 }
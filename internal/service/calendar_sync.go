@@ -0,0 +1,360 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"maps"
+	"slices"
+
+	"github.com/tazhate/familybot/internal/clients/caldav"
+	"github.com/tazhate/familybot/internal/domain"
+)
+
+// SyncAll syncs every linked calendar of every user
+func (s *CalendarService) SyncAll() (*SyncResult, error) {
+	calendars, err := s.storage.ListAllCalendars()
+	if err != nil {
+		return nil, fmt.Errorf("list calendars: %w", err)
+	}
+	return s.syncCalendars(calendars), nil
+}
+
+// SyncUser syncs the user's linked calendars
+func (s *CalendarService) SyncUser(userID int64) (*SyncResult, error) {
+	calendars, err := s.storage.ListCalendars(userID)
+	if err != nil {
+		return nil, fmt.Errorf("list calendars: %w", err)
+	}
+	return s.syncCalendars(calendars), nil
+}
+
+// syncCalendars syncs the calendars one by one; a failed calendar doesn't stop the others
+func (s *CalendarService) syncCalendars(calendars []*domain.Calendar) *SyncResult {
+	total := &SyncResult{}
+	for _, cal := range calendars {
+		result, err := s.SyncCalendar(cal)
+		if err != nil {
+			total.Errors = append(total.Errors, fmt.Sprintf("%s: %v", cal.Name, err))
+			continue
+		}
+		total.Added += result.Added
+		total.Updated += result.Updated
+		total.Deleted += result.Deleted
+		total.Conflicts = append(total.Conflicts, result.Conflicts...)
+		for _, e := range result.Errors {
+			total.Errors = append(total.Errors, cal.Name+": "+e)
+		}
+	}
+	return total
+}
+
+// SyncCalendar syncs events from the linked calendar to local storage. Only changed objects
+// are downloaded: by the stored sync-token (RFC 6578 sync-collection) or, if the server
+// doesn't support it, by the ctag of the calendar and the etags of its objects.
+// Events changed in the bot are merged with the calendar's changes and written back.
+func (s *CalendarService) SyncCalendar(cal *domain.Calendar) (*SyncResult, error) {
+	client, err := s.clientFor(cal.AccountID)
+	if err != nil {
+		return nil, err
+	}
+
+	localEvents, err := s.storage.ListCalendarEventsByCalendar(cal.ID)
+	if err != nil {
+		return nil, fmt.Errorf("get local events: %w", err)
+	}
+	conflicts, err := s.conflictsByEvent(cal.UserID)
+	if err != nil {
+		return nil, err
+	}
+	// Экземпляры повторяющегося события — строки с общим путём объекта
+	localByPath := make(map[string][]*domain.CalendarEvent)
+	localByKey := make(map[string]*domain.CalendarEvent)
+	for _, e := range localEvents {
+		if e.CalDAVPath != "" {
+			localByPath[e.CalDAVPath] = append(localByPath[e.CalDAVPath], e)
+		}
+		if e.CalDAVUID != "" {
+			localByKey[occurrenceKey(e.CalDAVUID, e.RecurrenceID)] = e
+		}
+	}
+
+	// remote — объекты, о которых сообщил сервер; full — это полный список календаря,
+	// и синхронизированные события, которых в нём нет, удалены на сервере
+	prevSyncedAt := cal.SyncedAt
+	remote, deleted, full, err := s.remoteChanges(client, cal)
+	if err != nil {
+		return nil, err
+	}
+	result := &SyncResult{}
+
+	// Окно повторяющихся событий сдвинулось — серии разворачиваются заново
+	now := s.clock.Now()
+	from, to := s.recurrenceWindow(now)
+	var expand []string
+	if prevSyncedAt != nil && !full {
+		if _, prevTo := s.recurrenceWindow(*prevSyncedAt); to.After(prevTo) {
+			expand = s.seriesToExpand(client, cal, localByPath, prevTo, to)
+		}
+	}
+
+	if remote == nil && !full && len(expand) == 0 {
+		s.pushPending(cal, client, pendingEvents(cal, localEvents, conflicts, nil))
+		return result, s.saveSyncState(cal)
+	}
+
+	changed := make(map[string]bool)
+	for path, etag := range remote {
+		if locals := localByPath[path]; len(locals) == 0 || locals[0].ETag == "" || locals[0].ETag != etag {
+			changed[path] = true
+		}
+	}
+	for _, path := range expand {
+		if !slices.Contains(deleted, path) {
+			changed[path] = true
+		}
+	}
+
+	appleEvents, err := client.GetEventsByPath(cal.Path, slices.Sorted(maps.Keys(changed)), from, to)
+	if err != nil {
+		return nil, fmt.Errorf("get changed events: %w", err)
+	}
+
+	seen := make(map[string]bool)
+	merged := make(map[int64]bool)
+	for i := range appleEvents {
+		ae := &appleEvents[i]
+		key := occurrenceKey(ae.UID, ae.RecurrenceID)
+		seen[key] = true
+		if ae.ETag == "" {
+			ae.ETag = remote[ae.Path]
+		}
+
+		local := localByKey[key]
+		if locals := localByPath[ae.Path]; local == nil && ae.RecurrenceID == "" && len(locals) == 1 && !locals[0].IsOccurrence() {
+			// Объект тот же, UID сменился
+			local = locals[0]
+		}
+		if local == nil {
+			event := &domain.CalendarEvent{
+				UserID:       cal.UserID,
+				CalendarID:   &cal.ID,
+				CalDAVUID:    ae.UID,
+				CalDAVPath:   ae.Path,
+				RecurrenceID: ae.RecurrenceID,
+				ETag:         ae.ETag,
+				Title:        ae.Summary,
+				Description:  ae.Description,
+				Location:     ae.Location,
+				StartTime:    ae.StartTime,
+				EndTime:      ae.EndTime,
+				AllDay:       ae.AllDay,
+				IsShared:     cal.IsShared,
+				Sequence:     ae.Sequence,
+				SyncedAt:     &now,
+			}
+			if err := s.storage.CreateCalendarEvent(event); err != nil {
+				result.Errors = append(result.Errors, fmt.Sprintf("create %s: %v", ae.UID, err))
+			} else {
+				s.saveSnapshot(event)
+				result.Added++
+			}
+			continue
+		}
+
+		// Событие изменено и в боте: сливаем, а не перезаписываем
+		if conflict := conflicts[local.ID]; conflict != nil || (local.Pending && cal.Writable()) {
+			merged[local.ID] = true
+			var err error
+			if conflict != nil {
+				conflict, err = s.refreshConflict(cal, client, conflict, local, ae)
+			} else {
+				conflict, err = s.mergeRemote(cal, client, local, ae)
+			}
+			s.collectMerge(cal, result, local, conflict, err)
+			continue
+		}
+
+		contentChanged := s.eventChanged(local, ae)
+		if !contentChanged && local.ETag == ae.ETag && local.CalDAVPath == ae.Path && local.CalDAVUID == ae.UID {
+			continue
+		}
+		local.CalDAVUID = ae.UID
+		local.CalDAVPath = ae.Path
+		local.RecurrenceID = ae.RecurrenceID
+		local.ETag = ae.ETag
+		local.Title = ae.Summary
+		local.Description = ae.Description
+		local.Location = ae.Location
+		local.StartTime = ae.StartTime
+		local.EndTime = ae.EndTime
+		local.AllDay = ae.AllDay
+		local.Sequence = ae.Sequence
+		local.Pending = false
+		local.SyncedAt = &now
+		if err := s.storage.UpdateCalendarEvent(local); err != nil {
+			result.Errors = append(result.Errors, fmt.Sprintf("update %s: %v", ae.UID, err))
+			continue
+		}
+		s.saveSnapshot(local)
+		if contentChanged {
+			result.Updated++
+		}
+	}
+
+	// Удаляем только события, пришедшие с сервера (есть SyncedAt)
+	var removed []*domain.CalendarEvent
+	for _, path := range deleted {
+		for _, local := range localByPath[path] {
+			if local.SyncedAt != nil {
+				removed = append(removed, local)
+			}
+		}
+	}
+	for _, local := range localEvents {
+		if local.SyncedAt == nil || seen[occurrenceKey(local.CalDAVUID, local.RecurrenceID)] {
+			continue
+		}
+		switch {
+		case changed[local.CalDAVPath] && local.IsOccurrence() && local.StartTime.Before(from):
+			// Экземпляры до начала окна не разворачиваются — это прошлое, а не удаление
+			continue
+		case changed[local.CalDAVPath]:
+			// Объект перечитан, а экземпляра в нём нет: исключён из серии
+			removed = append(removed, local)
+		case full:
+			if _, ok := remote[local.CalDAVPath]; ok && local.CalDAVPath != "" {
+				continue
+			}
+			removed = append(removed, local)
+		}
+	}
+	for _, local := range removed {
+		// Удалённое в календаре, но изменённое в боте событие ждёт решения владельца
+		if conflict := conflicts[local.ID]; conflict != nil || (local.Pending && cal.Writable()) {
+			merged[local.ID] = true
+			var err error
+			if conflict != nil {
+				conflict, err = s.refreshConflict(cal, client, conflict, local, nil)
+			} else {
+				conflict, err = s.remoteDeleteConflict(local)
+			}
+			s.collectMerge(cal, result, local, conflict, err)
+			continue
+		}
+		if err := s.storage.DeleteCalendarEvent(local.ID); err != nil {
+			result.Errors = append(result.Errors, fmt.Sprintf("delete %s: %v", local.CalDAVUID, err))
+		} else {
+			result.Deleted++
+		}
+	}
+
+	s.pushPending(cal, client, pendingEvents(cal, localEvents, conflicts, merged))
+
+	// С ошибками состояние не сохраняем: в следующий раз изменения запросятся снова
+	if len(result.Errors) > 0 {
+		return result, nil
+	}
+	return result, s.saveSyncState(cal)
+}
+
+// remoteChanges asks the server what changed since the stored state and updates the state.
+// Returns the changed objects with their etags and the deleted paths; full means the
+// objects are the complete contents of the calendar. Nil objects and !full — nothing changed.
+func (s *CalendarService) remoteChanges(client *caldav.Client, cal *domain.Calendar) (objects map[string]string, deleted []string, full bool, err error) {
+	token := cal.SyncToken
+	changes, err := client.SyncCollection(cal.Path, token)
+	if err != nil && token != "" {
+		// Токен мог устареть — начинаем синхронизацию заново
+		log.Printf("calendar sync: %s: sync-token rejected, starting over: %v", cal.Name, err)
+		token = ""
+		changes, err = client.SyncCollection(cal.Path, "")
+	}
+	if err == nil {
+		objects = refsToMap(changes.Updated)
+		cal.SyncToken = changes.SyncToken
+		cal.CTag = ""
+		return objects, changes.Deleted, token == "", nil
+	}
+
+	// Сервер без sync-collection: сначала ctag всего календаря, потом etag каждого объекта
+	cal.SyncToken = ""
+	ctag, err := client.GetCTag(cal.Path)
+	if err != nil {
+		log.Printf("calendar sync: %s: no ctag: %v", cal.Name, err)
+	}
+	if ctag != "" && ctag == cal.CTag {
+		return nil, nil, false, nil
+	}
+
+	refs, err := client.ListObjects(cal.Path)
+	if err != nil {
+		return nil, nil, false, fmt.Errorf("list calendar objects: %w", err)
+	}
+	cal.CTag = ctag
+	return refsToMap(refs), nil, true, nil
+}
+
+func (s *CalendarService) saveSyncState(cal *domain.Calendar) error {
+	now := s.clock.Now()
+	cal.SyncedAt = &now
+	if err := s.storage.UpdateCalendar(cal); err != nil {
+		return fmt.Errorf("save sync state: %w", err)
+	}
+	return nil
+}
+
+// ResetSync forgets the sync-tokens and ctags of the user's calendars,
+// so the next sync downloads them whole
+func (s *CalendarService) ResetSync(userID int64) error {
+	calendars, err := s.storage.ListCalendars(userID)
+	if err != nil {
+		return fmt.Errorf("list calendars: %w", err)
+	}
+	for _, cal := range calendars {
+		cal.SyncToken = ""
+		cal.CTag = ""
+		if err := s.storage.UpdateCalendar(cal); err != nil {
+			return fmt.Errorf("reset calendar %d: %w", cal.ID, err)
+		}
+	}
+	return nil
+}
+
+// collectMerge records the outcome of merging an event changed on both sides
+func (s *CalendarService) collectMerge(cal *domain.Calendar, result *SyncResult, local *domain.CalendarEvent, conflict *domain.CalendarConflict, err error) {
+	switch {
+	case errors.Is(err, caldav.ErrPreconditionFailed):
+		// Календарь изменил объект прямо во время синхронизации — сольём в следующий раз
+		log.Printf("calendar sync: %s: event %d changed meanwhile, merging next time", cal.Name, local.ID)
+	case err != nil:
+		result.Errors = append(result.Errors, fmt.Sprintf("merge %s: %v", local.CalDAVUID, err))
+	case conflict != nil:
+		result.Conflicts = append(result.Conflicts, conflict)
+	default:
+		result.Updated++
+	}
+}
+
+// pendingEvents returns the events changed in the bot that still have to be written
+// to the calendar: without an open conflict and not merged in this sync
+func pendingEvents(cal *domain.Calendar, events []*domain.CalendarEvent, conflicts map[int64]*domain.CalendarConflict, merged map[int64]bool) []*domain.CalendarEvent {
+	if !cal.Writable() {
+		return nil
+	}
+	var pending []*domain.CalendarEvent
+	for _, e := range events {
+		if e.Pending && conflicts[e.ID] == nil && !merged[e.ID] {
+			pending = append(pending, e)
+		}
+	}
+	return pending
+}
+
+func refsToMap(refs []caldav.ObjectRef) map[string]string {
+	m := make(map[string]string, len(refs))
+	for _, r := range refs {
+		m[r.Path] = r.ETag
+	}
+	return m
+}
@@ -0,0 +1,277 @@
+package service_test
+
+import (
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/tazhate/familybot/internal/clients/caldav"
+	"github.com/tazhate/familybot/internal/clients/caldav/caldavtest"
+	"github.com/tazhate/familybot/internal/clock"
+	"github.com/tazhate/familybot/internal/domain"
+	"github.com/tazhate/familybot/internal/service"
+	"github.com/tazhate/familybot/internal/storage"
+	"github.com/tazhate/familybot/internal/storage/storagetest"
+)
+
+var moscow = mustLoadLocation("Europe/Moscow")
+
+func mustLoadLocation(name string) *time.Location {
+	loc, err := time.LoadLocation(name)
+	if err != nil {
+		panic(err)
+	}
+	return loc
+}
+
+// calendarFixture links a two-way calendar on an in-memory CalDAV server to a user
+type calendarFixture struct {
+	t         *testing.T
+	store     *storage.Storage
+	server    *caldavtest.Server
+	client    *caldav.Client
+	clock     *clock.Fake
+	calendars *service.CalendarService
+	user      *domain.User
+	cal       *domain.Calendar
+}
+
+func newCalendarFixture(t *testing.T) *calendarFixture {
+	t.Helper()
+	store := storagetest.SQLite(t)
+	server := caldavtest.NewServer()
+	t.Cleanup(server.Close)
+	clk := clock.NewFake(time.Date(2030, time.March, 10, 12, 0, 0, 0, moscow))
+
+	user := &domain.User{TelegramID: 100, Name: "Алекс", Role: domain.RoleOwner}
+	if err := store.CreateUser(user); err != nil {
+		t.Fatal(err)
+	}
+	calendars := service.NewCalendarService(store, moscow)
+	calendars.SetClock(clk)
+	if err := calendars.LinkFromConfig(user.ID, server.URL, "family", "secret", server.CalendarPath()); err != nil {
+		t.Fatal(err)
+	}
+	linked, err := store.ListCalendars(user.ID)
+	if err != nil || len(linked) != 1 {
+		t.Fatalf("linked calendars %v, %v", linked, err)
+	}
+
+	client := caldav.NewClient(server.URL, "family", "secret")
+	client.SetLocation(moscow)
+	return &calendarFixture{t: t, store: store, server: server, client: client, clock: clk, calendars: calendars, user: user, cal: linked[0]}
+}
+
+// putEvent stores the event on the server; props are extra iCalendar lines, e.g. SEQUENCE
+func (f *calendarFixture) putEvent(uid string, fields domain.EventFields, props ...string) {
+	f.t.Helper()
+	lines := []string{
+		"BEGIN:VCALENDAR",
+		"VERSION:2.0",
+		"PRODID:-//FamilyBot//test//EN",
+		"BEGIN:VEVENT",
+		"UID:" + uid,
+		"DTSTAMP:20300301T000000Z",
+		"DTSTART:" + fields.StartTime.UTC().Format("20060102T150405Z"),
+		"DTEND:" + fields.EndTime.UTC().Format("20060102T150405Z"),
+		"SUMMARY:" + fields.Title,
+	}
+	if fields.Location != "" {
+		lines = append(lines, "LOCATION:"+fields.Location)
+	}
+	if fields.Description != "" {
+		lines = append(lines, "DESCRIPTION:"+fields.Description)
+	}
+	lines = append(lines, props...)
+	lines = append(lines, "END:VEVENT", "END:VCALENDAR", "")
+	if _, err := f.server.PutObject(uid, strings.Join(lines, "\r\n")); err != nil {
+		f.t.Fatal(err)
+	}
+}
+
+// remote returns the event as it is on the server, nil if it is not there
+func (f *calendarFixture) remote(uid string) *caldav.Event {
+	f.t.Helper()
+	ae, err := f.client.GetEvent(f.server.CalendarPath() + uid + ".ics")
+	if err != nil {
+		f.t.Fatal(err)
+	}
+	return ae
+}
+
+func (f *calendarFixture) event(uid string) *domain.CalendarEvent {
+	f.t.Helper()
+	event, err := f.store.GetCalendarEventByCalDAVUID(uid)
+	if err != nil {
+		f.t.Fatal(err)
+	}
+	return event
+}
+
+func (f *calendarFixture) sync() *service.SyncResult {
+	f.t.Helper()
+	result, err := f.calendars.SyncCalendar(f.cal)
+	if err != nil {
+		f.t.Fatal(err)
+	}
+	if len(result.Errors) > 0 {
+		f.t.Fatalf("sync errors: %v", result.Errors)
+	}
+	return result
+}
+
+func (f *calendarFixture) titles() []string {
+	f.t.Helper()
+	events, err := f.store.ListCalendarEventsByCalendar(f.cal.ID)
+	if err != nil {
+		f.t.Fatal(err)
+	}
+	var titles []string
+	for _, e := range events {
+		titles = append(titles, e.Title)
+	}
+	slices.Sort(titles)
+	return titles
+}
+
+func (f *calendarFixture) expectTitles(want ...string) {
+	f.t.Helper()
+	if got := f.titles(); !slices.Equal(got, want) {
+		f.t.Errorf("bot has %q, want %q", got, want)
+	}
+}
+
+func (f *calendarFixture) expectFetched(want int) {
+	f.t.Helper()
+	if got := f.server.Fetched(); got != want {
+		f.t.Errorf("downloaded %d objects, want %d", got, want)
+	}
+}
+
+func expectResult(t *testing.T, r *service.SyncResult, added, updated, deleted int) {
+	t.Helper()
+	if r.Added != added || r.Updated != updated || r.Deleted != deleted {
+		t.Errorf("added %d, updated %d, deleted %d; want %d, %d, %d", r.Added, r.Updated, r.Deleted, added, updated, deleted)
+	}
+}
+
+// at returns the event fields for an hour-long event on the day of the fixture clock
+func at(title string, days int) domain.EventFields {
+	start := time.Date(2030, time.March, 10, 19, 0, 0, 0, moscow).AddDate(0, 0, days)
+	return domain.EventFields{Title: title, StartTime: start, EndTime: start.Add(time.Hour)}
+}
+
+func TestSyncCalendarFull(t *testing.T) {
+	f := newCalendarFixture(t)
+	f.putEvent("dinner", at("Ужин", 2))
+	f.putEvent("dentist", at("Стоматолог", 5))
+
+	expectResult(t, f.sync(), 2, 0, 0)
+	f.expectTitles("Стоматолог", "Ужин")
+	f.expectFetched(2)
+	if f.cal.SyncToken == "" {
+		t.Error("sync-token not stored")
+	}
+	if event := f.event("dinner"); event.ETag == "" || event.CalDAVPath == "" || event.SyncedAt == nil {
+		t.Errorf("event stored without sync state: etag %q, path %q, synced %v", event.ETag, event.CalDAVPath, event.SyncedAt)
+	}
+
+	// Ничего не изменилось — ничего не скачивается
+	expectResult(t, f.sync(), 0, 0, 0)
+	f.expectFetched(0)
+}
+
+func TestSyncCalendarIncremental(t *testing.T) {
+	f := newCalendarFixture(t)
+	f.putEvent("dinner", at("Ужин", 2))
+	f.putEvent("dentist", at("Стоматолог", 5))
+	f.putEvent("school", at("Собрание", 7))
+	f.sync()
+	f.server.Fetched()
+	token := f.cal.SyncToken
+
+	f.putEvent("dinner", at("Ужин с мамой", 2))
+	f.server.DeleteEvent("dentist")
+	f.putEvent("gym", at("Бассейн", 3))
+
+	expectResult(t, f.sync(), 1, 1, 1)
+	f.expectTitles("Бассейн", "Собрание", "Ужин с мамой")
+	f.expectFetched(2)
+	if f.cal.SyncToken == token {
+		t.Error("sync-token not advanced")
+	}
+}
+
+func TestSyncCalendarRejectedToken(t *testing.T) {
+	f := newCalendarFixture(t)
+	f.putEvent("dinner", at("Ужин", 2))
+	f.putEvent("dentist", at("Стоматолог", 5))
+	f.sync()
+
+	// Сервер забыл токен, а событие тем временем удалили: полная синхронизация это заметит
+	f.server.DeleteEvent("dentist")
+	f.putEvent("gym", at("Бассейн", 3))
+	f.cal.SyncToken = "familybot-sync-999"
+
+	expectResult(t, f.sync(), 1, 0, 1)
+	f.expectTitles("Бассейн", "Ужин")
+	if f.cal.SyncToken == "familybot-sync-999" || f.cal.SyncToken == "" {
+		t.Errorf("sync-token %q not replaced", f.cal.SyncToken)
+	}
+}
+
+func TestSyncCalendarWithoutSyncCollection(t *testing.T) {
+	for _, ctag := range []bool{true, false} {
+		name := "etag only"
+		if ctag {
+			name = "ctag"
+		}
+		t.Run(name, func(t *testing.T) {
+			f := newCalendarFixture(t)
+			f.server.SetSyncCollection(false)
+			f.server.SetCTag(ctag)
+			f.putEvent("dinner", at("Ужин", 2))
+			f.putEvent("dentist", at("Стоматолог", 5))
+
+			expectResult(t, f.sync(), 2, 0, 0)
+			f.expectFetched(2)
+			if f.cal.SyncToken != "" || (f.cal.CTag != "") != ctag {
+				t.Errorf("sync-token %q, ctag %q", f.cal.SyncToken, f.cal.CTag)
+			}
+
+			expectResult(t, f.sync(), 0, 0, 0)
+			f.expectFetched(0)
+
+			f.putEvent("dinner", at("Ужин с мамой", 2))
+			f.server.DeleteEvent("dentist")
+			expectResult(t, f.sync(), 0, 1, 1)
+			f.expectTitles("Ужин с мамой")
+			f.expectFetched(1)
+		})
+	}
+}
+
+// TestSyncCalendarKeepsPastEvents moves the clock so that events leave the sync window:
+// they are history, not deletions in the calendar
+func TestSyncCalendarKeepsPastEvents(t *testing.T) {
+	f := newCalendarFixture(t)
+	f.putEvent("dinner", at("Ужин", -20))
+	f.putEvent("dentist", at("Стоматолог", 5))
+	f.putEvent("swim", at("Бассейн", -26), "RRULE:FREQ=WEEKLY;COUNT=5")
+	f.sync()
+	swims := []string{"Бассейн", "Бассейн", "Бассейн", "Бассейн", "Бассейн"}
+	f.expectTitles(append(slices.Clone(swims), "Стоматолог", "Ужин")...)
+
+	f.clock.Advance(60 * 24 * time.Hour)
+	expectResult(t, f.sync(), 0, 0, 0)
+	f.expectTitles(append(slices.Clone(swims), "Стоматолог", "Ужин")...)
+
+	// И после полной синхронизации
+	if err := f.calendars.ResetSync(f.user.ID); err != nil {
+		t.Fatal(err)
+	}
+	f.cal.SyncToken = ""
+	expectResult(t, f.sync(), 0, 0, 0)
+	f.expectTitles(append(slices.Clone(swims), "Стоматолог", "Ужин")...)
+}
@@ -3,17 +3,32 @@ package service
 import (
 	"fmt"
 	"strings"
+	"sync"
+	"time"
 
+	"github.com/tazhate/familybot/internal/clock"
 	"github.com/tazhate/familybot/internal/domain"
 	"github.com/tazhate/familybot/internal/storage"
 )
 
+// historyRuns — сколько последних прогонов учитывается в /checklist history
+const historyRuns = 20
+
 type ChecklistService struct {
-	storage *storage.Storage
+	storage  storage.Store
+	timezone *time.Location
+	clock    clock.Clock
+	// Прогон отмечают одновременно двое: чтение и запись пунктов не должны перемешиваться
+	runMu sync.Mutex
+}
+
+func NewChecklistService(s storage.Store, tz *time.Location) *ChecklistService {
+	return &ChecklistService{storage: s, timezone: tz, clock: clock.Real()}
 }
 
-func NewChecklistService(s *storage.Storage) *ChecklistService {
-	return &ChecklistService{storage: s}
+// SetClock replaces the system clock, e.g. to simulate days in tests
+func (s *ChecklistService) SetClock(c clock.Clock) {
+	s.clock = c
 }
 
 func (s *ChecklistService) Create(userID int64, title string, items []string) (*domain.Checklist, error) {
@@ -24,10 +39,7 @@ func (s *ChecklistService) Create(userID int64, title string, items []string) (*
 
 	checklistItems := make([]domain.ChecklistItem, len(items))
 	for i, item := range items {
-		checklistItems[i] = domain.ChecklistItem{
-			Text:    strings.TrimSpace(item),
-			Checked: false,
-		}
+		checklistItems[i] = domain.ChecklistItem{Text: strings.TrimSpace(item)}
 	}
 
 	c := &domain.Checklist{
@@ -55,39 +67,163 @@ func (s *ChecklistService) List(userID int64) ([]*domain.Checklist, error) {
 	return s.storage.ListChecklistsByUser(userID)
 }
 
-func (s *ChecklistService) CheckItem(checklistID int64, userID int64, itemIndex int) error {
-	c, err := s.storage.GetChecklist(checklistID)
+// StartRun starts a new run of the checklist; an unfinished previous run stays in the history
+func (s *ChecklistService) StartRun(checklistID int64, userID int64) (*domain.ChecklistRun, error) {
+	c, err := s.owned(checklistID, userID)
 	if err != nil {
-		return fmt.Errorf("get checklist: %w", err)
+		return nil, err
+	}
+
+	r := domain.NewChecklistRun(c, userID, s.clock.Now())
+	if err := s.storage.CreateChecklistRun(r); err != nil {
+		return nil, fmt.Errorf("create checklist run: %w", err)
+	}
+	return r, nil
+}
+
+// CurrentRun returns the unfinished run of the checklist or starts a new one
+func (s *ChecklistService) CurrentRun(checklistID int64, userID int64) (*domain.ChecklistRun, error) {
+	if _, err := s.owned(checklistID, userID); err != nil {
+		return nil, err
+	}
+
+	r, err := s.storage.GetOpenChecklistRun(checklistID)
+	if err != nil {
+		return nil, fmt.Errorf("get open run: %w", err)
+	}
+	if r != nil {
+		return r, nil
+	}
+	return s.StartRun(checklistID, userID)
+}
+
+// OpenRun returns the unfinished run of the checklist or nil
+func (s *ChecklistService) OpenRun(checklistID int64) (*domain.ChecklistRun, error) {
+	return s.storage.GetOpenChecklistRun(checklistID)
+}
+
+// StartEventRun starts a run for the reminder of a weekly event with a linked checklist.
+// Repeated calls for the same occurrence (since — start of the event's day) return the same run.
+func (s *ChecklistService) StartEventRun(e *domain.WeeklyEvent, since time.Time) (*domain.ChecklistRun, error) {
+	if e.ChecklistID == nil {
+		return nil, nil
+	}
+
+	r, err := s.storage.GetEventChecklistRun(e.ID, since)
+	if err != nil {
+		return nil, fmt.Errorf("get event run: %w", err)
+	}
+	if r != nil {
+		return r, nil
+	}
+
+	c, err := s.storage.GetChecklist(*e.ChecklistID)
+	if err != nil {
+		return nil, fmt.Errorf("get checklist: %w", err)
 	}
 	if c == nil {
-		return fmt.Errorf("чек-лист не найден")
+		return nil, nil
 	}
-	if c.UserID != userID {
-		return fmt.Errorf("нет доступа")
+
+	r = domain.NewChecklistRun(c, e.UserID, s.clock.Now())
+	r.WeeklyEventID = &e.ID
+	r.IsShared = e.IsShared
+	if err := s.storage.CreateChecklistRun(r); err != nil {
+		return nil, fmt.Errorf("create checklist run: %w", err)
+	}
+	return r, nil
+}
+
+// GetVisibleRun returns a run of the user or a shared one of the household, nil otherwise
+func (s *ChecklistService) GetVisibleRun(id int64, userID int64) (*domain.ChecklistRun, error) {
+	r, err := s.storage.GetChecklistRun(id)
+	if err != nil || r == nil {
+		return nil, err
+	}
+	ok, err := visibleTo(s.storage, r.UserID, userID, r.IsShared)
+	if err != nil || !ok {
+		return nil, err
+	}
+	return r, nil
+}
+
+// ToggleRunItem checks or unchecks an item of the run on behalf of the user
+func (s *ChecklistService) ToggleRunItem(runID int64, userID int64, itemIndex int) (*domain.ChecklistRun, error) {
+	s.runMu.Lock()
+	defer s.runMu.Unlock()
+
+	r, err := s.accessibleRun(runID, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	if !r.ToggleItem(itemIndex, userID, s.clock.Now()) {
+		return nil, fmt.Errorf("неверный номер пункта")
+	}
+	if err := s.storage.UpdateChecklistRun(r); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// ShareRun lets the whole family check items of the run
+func (s *ChecklistService) ShareRun(runID int64, userID int64) (*domain.ChecklistRun, error) {
+	s.runMu.Lock()
+	defer s.runMu.Unlock()
+
+	r, err := s.accessibleRun(runID, userID)
+	if err != nil {
+		return nil, err
+	}
+	if r.IsShared {
+		return r, nil
+	}
+
+	r.IsShared = true
+	if err := s.storage.UpdateChecklistRun(r); err != nil {
+		return nil, err
 	}
+	return r, nil
+}
 
-	if !c.CheckItem(itemIndex) {
-		return fmt.Errorf("неверный номер пункта")
+// History returns the latest runs of the checklist, newest first
+func (s *ChecklistService) History(checklistID int64, userID int64) ([]*domain.ChecklistRun, error) {
+	if _, err := s.owned(checklistID, userID); err != nil {
+		return nil, err
 	}
+	return s.storage.ListChecklistRuns(checklistID, historyRuns)
+}
 
-	return s.storage.UpdateChecklistItems(checklistID, c.Items)
+func (s *ChecklistService) accessibleRun(runID int64, userID int64) (*domain.ChecklistRun, error) {
+	r, err := s.storage.GetChecklistRun(runID)
+	if err != nil {
+		return nil, fmt.Errorf("get checklist run: %w", err)
+	}
+	if r == nil {
+		return nil, fmt.Errorf("прогон не найден")
+	}
+	ok, err := visibleTo(s.storage, r.UserID, userID, r.IsShared)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return nil, fmt.Errorf("нет доступа")
+	}
+	return r, nil
 }
 
-func (s *ChecklistService) Reset(checklistID int64, userID int64) error {
+func (s *ChecklistService) owned(checklistID int64, userID int64) (*domain.Checklist, error) {
 	c, err := s.storage.GetChecklist(checklistID)
 	if err != nil {
-		return fmt.Errorf("get checklist: %w", err)
+		return nil, fmt.Errorf("get checklist: %w", err)
 	}
 	if c == nil {
-		return fmt.Errorf("чек-лист не найден")
+		return nil, fmt.Errorf("чек-лист не найден")
 	}
 	if c.UserID != userID {
-		return fmt.Errorf("нет доступа")
+		return nil, fmt.Errorf("нет доступа")
 	}
-
-	c.ResetChecks()
-	return s.storage.UpdateChecklistItems(checklistID, c.Items)
+	return c, nil
 }
 
 func (s *ChecklistService) Delete(checklistID int64, userID int64) error {
@@ -105,27 +241,128 @@ func (s *ChecklistService) Delete(checklistID int64, userID int64) error {
 	return s.storage.DeleteChecklist(checklistID)
 }
 
+// FormatChecklist formats the template: just the items, without checks
 func (s *ChecklistService) FormatChecklist(c *domain.Checklist) string {
 	var sb strings.Builder
 	sb.WriteString(fmt.Sprintf("📋 <b>%s</b>\n\n", c.Title))
 
 	for i, item := range c.Items {
+		sb.WriteString(fmt.Sprintf("▫️ %d. %s\n", i+1, item.Text))
+	}
+
+	return sb.String()
+}
+
+// FormatRun formats a run; in a shared run every check is signed with the name of who made it
+func (s *ChecklistService) FormatRun(r *domain.ChecklistRun) string {
+	var sb strings.Builder
+	sb.WriteString(fmt.Sprintf("📋 <b>%s</b> — %s", r.Title, s.formatDay(r.StartedAt)))
+	if r.IsShared {
+		sb.WriteString(" 👥")
+	}
+	sb.WriteString("\n\n")
+
+	names := make(map[int64]string)
+	for i, item := range r.Items {
 		status := "⬜"
 		if item.Checked {
 			status = "✅"
 		}
-		sb.WriteString(fmt.Sprintf("%s %d. %s\n", status, i+1, item.Text))
+		sb.WriteString(fmt.Sprintf("%s %d. %s", status, i+1, item.Text))
+		if item.Checked && r.IsShared && item.CheckedBy != nil && item.CheckedAt != nil {
+			sb.WriteString(fmt.Sprintf(" <i>— %s, %s</i>", s.userName(names, *item.CheckedBy), item.CheckedAt.In(s.timezone).Format("15:04")))
+		}
+		sb.WriteString("\n")
 	}
 
-	if c.AllChecked() {
+	if r.AllChecked() {
 		sb.WriteString("\n🎉 Все пункты выполнены!")
 	} else {
-		sb.WriteString(fmt.Sprintf("\n%d/%d выполнено", c.CheckedCount(), len(c.Items)))
+		sb.WriteString(fmt.Sprintf("\n%d/%d выполнено", r.CheckedCount(), len(r.Items)))
 	}
 
 	return sb.String()
 }
 
+// FormatHistory formats completion rates of the runs: overall and per item of the template
+func (s *ChecklistService) FormatHistory(c *domain.Checklist, runs []*domain.ChecklistRun) string {
+	var sb strings.Builder
+	sb.WriteString(fmt.Sprintf("📊 <b>%s</b> — история\n\n", c.Title))
+
+	if len(runs) == 0 {
+		sb.WriteString("Прогонов ещё не было.")
+		return sb.String()
+	}
+
+	completed := 0
+	for _, r := range runs {
+		if r.IsCompleted() {
+			completed++
+		}
+	}
+	sb.WriteString(fmt.Sprintf("Прогонов: %d, завершено: %d (%d%%)\n", len(runs), completed, percent(completed, len(runs))))
+
+	sb.WriteString("\n<b>По пунктам:</b>\n")
+	for _, item := range c.Items {
+		total, checked := 0, 0
+		for _, r := range runs {
+			for _, ri := range r.Items {
+				if ri.Text != item.Text {
+					continue
+				}
+				total++
+				if ri.Checked {
+					checked++
+				}
+				break
+			}
+		}
+		if total == 0 {
+			sb.WriteString(fmt.Sprintf("• %s — новый пункт\n", item.Text))
+			continue
+		}
+		sb.WriteString(fmt.Sprintf("• %s — %d/%d (%d%%)\n", item.Text, checked, total, percent(checked, total)))
+	}
+
+	sb.WriteString("\n<b>Последние:</b>\n")
+	for i, r := range runs {
+		if i == 10 {
+			break
+		}
+		status := "⬜"
+		if r.IsCompleted() {
+			status = "✅"
+		}
+		sb.WriteString(fmt.Sprintf("%s %s — %d/%d\n", status, s.formatDay(r.StartedAt), r.CheckedCount(), len(r.Items)))
+	}
+
+	return sb.String()
+}
+
+func (s *ChecklistService) formatDay(t time.Time) string {
+	local := t.In(s.timezone)
+	return fmt.Sprintf("%s %s", strings.ToLower(domain.WeekdayNameShort(domain.Weekday(local.Weekday()))), local.Format("02.01"))
+}
+
+func (s *ChecklistService) userName(cache map[int64]string, userID int64) string {
+	if name, ok := cache[userID]; ok {
+		return name
+	}
+	name := "?"
+	if u, err := s.storage.GetUserByID(userID); err == nil && u != nil {
+		name = u.Name
+	}
+	cache[userID] = name
+	return name
+}
+
+func percent(part, total int) int {
+	if total == 0 {
+		return 0
+	}
+	return part * 100 / total
+}
+
 func (s *ChecklistService) FormatChecklistList(checklists []*domain.Checklist) string {
 	if len(checklists) == 0 {
 		return "Нет чек-листов"
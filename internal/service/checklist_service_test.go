@@ -0,0 +1,52 @@
+package service_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/tazhate/familybot/internal/service"
+)
+
+// TestSharedRunStaysInHousehold: общий прогон видят и отмечают только в семье владельца
+func TestSharedRunStaysInHousehold(t *testing.T) {
+	f := newFamily(t)
+	checklists := service.NewChecklistService(f.store, time.UTC)
+	c, err := checklists.Create(f.owner.ID, "В поход", []string{"Палатка", "Спальник"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	run, err := checklists.StartRun(c.ID, f.owner.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	check := func(shared bool) {
+		t.Helper()
+		for _, tt := range []struct {
+			name    string
+			userID  int64
+			allowed bool
+		}{
+			{"owner", f.owner.ID, true},
+			{"partner", f.partner.ID, shared},
+			{"stranger", f.stranger.ID, false},
+		} {
+			got, err := checklists.GetVisibleRun(run.ID, tt.userID)
+			if err != nil || (got != nil) != tt.allowed {
+				t.Errorf("shared %v: %s sees run %v, %v; want %v", shared, tt.name, got, err, tt.allowed)
+			}
+			_, err = checklists.ToggleRunItem(run.ID, tt.userID, 0)
+			if (err == nil) != tt.allowed {
+				t.Errorf("shared %v: %s toggles an item: %v; want allowed %v", shared, tt.name, err, tt.allowed)
+			}
+		}
+	}
+	check(false)
+	if _, err := checklists.ShareRun(run.ID, f.stranger.ID); err == nil {
+		t.Error("stranger shared the run")
+	}
+	if _, err := checklists.ShareRun(run.ID, f.owner.ID); err != nil {
+		t.Fatal(err)
+	}
+	check(true)
+}
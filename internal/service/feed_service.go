@@ -0,0 +1,372 @@
+package service
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/emersion/go-ical"
+	"github.com/tazhate/familybot/internal/clients/caldav"
+	"github.com/tazhate/familybot/internal/clock"
+	"github.com/tazhate/familybot/internal/domain"
+	"github.com/tazhate/familybot/internal/storage"
+)
+
+// Подписка на календарь семьи: недельное расписание (RRULE), подтверждённые плавающие
+// события, задачи со сроком, дни рождения и сроки по машинам. Одни и те же события
+// отдаются файлом .ics и через CalDAV только для чтения.
+
+// feedProductID — PRODID календаря подписки
+const feedProductID = "-//FamilyBot//Feed//RU"
+
+// Часовой пояс в VTIMEZONE описывается на эти годы вокруг текущего: границы — начала
+// лет, чтобы объекты и их ETag не менялись от запроса к запросу
+const (
+	feedTimezoneYearsPast  = 1
+	feedTimezoneYearsAhead = 2
+)
+
+// ErrFeedDisabled — у пользователя нет ссылки на подписку
+var ErrFeedDisabled = errors.New("подписка на календарь выключена")
+
+type FeedService struct {
+	storage  storage.Store
+	timezone *time.Location
+	clock    clock.Clock
+}
+
+func NewFeedService(s storage.Store, tz *time.Location) *FeedService {
+	return &FeedService{storage: s, timezone: tz, clock: clock.Real()}
+}
+
+// SetClock replaces the system clock, e.g. to simulate days in tests
+func (s *FeedService) SetClock(c clock.Clock) {
+	s.clock = c
+}
+
+// Feed returns the user's feed, creating it on first use
+func (s *FeedService) Feed(userID int64) (*domain.CalendarFeed, error) {
+	feed, err := s.storage.GetCalendarFeed(userID)
+	if err != nil {
+		return nil, fmt.Errorf("get feed: %w", err)
+	}
+	if feed != nil {
+		return feed, nil
+	}
+	return s.Rotate(userID)
+}
+
+// Rotate gives the user a new token; the old link stops working
+func (s *FeedService) Rotate(userID int64) (*domain.CalendarFeed, error) {
+	token, err := newFeedToken()
+	if err != nil {
+		return nil, err
+	}
+	feed := &domain.CalendarFeed{UserID: userID, Token: token}
+	if err := s.storage.SaveCalendarFeed(feed); err != nil {
+		return nil, fmt.Errorf("save feed: %w", err)
+	}
+	return feed, nil
+}
+
+// Disable removes the user's feed; the link stops working
+func (s *FeedService) Disable(userID int64) error {
+	return s.storage.DeleteCalendarFeed(userID)
+}
+
+// UserByToken returns the ID of the user the token belongs to
+func (s *FeedService) UserByToken(token string) (int64, error) {
+	if token == "" {
+		return 0, ErrFeedDisabled
+	}
+	feed, err := s.storage.GetCalendarFeedByToken(token)
+	if err != nil {
+		return 0, fmt.Errorf("get feed: %w", err)
+	}
+	if feed == nil {
+		return 0, ErrFeedDisabled
+	}
+	return feed.UserID, nil
+}
+
+func newFeedToken() (string, error) {
+	buf := make([]byte, 20)
+	if _, err := rand.Read(buf); err != nil {
+		return "", fmt.Errorf("generate feed token: %w", err)
+	}
+	return hex.EncodeToString(buf), nil
+}
+
+// Events returns the VEVENTs of the user's feed sorted by UID. Times are in the bot's
+// time zone: in it the schedule is reminded and dates are entered.
+func (s *FeedService) Events(userID int64) ([]*ical.Event, error) {
+	loc := s.timezone
+	now := s.clock.Now().In(loc)
+	var events []*ical.Event
+
+	weekly, err := s.storage.ListWeeklyEventsByUser(userID, true)
+	if err != nil {
+		return nil, fmt.Errorf("list schedule: %w", err)
+	}
+	for _, e := range weekly {
+		if event := weeklyFeedEvent(e, loc, now); event != nil {
+			events = append(events, event)
+		}
+	}
+
+	tasks, err := s.storage.ListTasksByUser(userID, true, false)
+	if err != nil {
+		return nil, fmt.Errorf("list tasks: %w", err)
+	}
+	for _, t := range tasks {
+		if t.DueDate != nil && !t.IsDone() {
+			events = append(events, taskFeedEvent(t, loc))
+		}
+	}
+
+	persons, err := s.storage.ListPersonsWithBirthday(userID)
+	if err != nil {
+		return nil, fmt.Errorf("list birthdays: %w", err)
+	}
+	for _, p := range persons {
+		events = append(events, birthdayFeedEvent(p))
+	}
+
+	autoEvents, err := s.autoEvents(userID)
+	if err != nil {
+		return nil, err
+	}
+	events = append(events, autoEvents...)
+
+	sort.Slice(events, func(i, j int) bool {
+		return feedEventUID(events[i]) < feedEventUID(events[j])
+	})
+	return events, nil
+}
+
+// autoEvents returns the insurance and maintenance dates, documents and next services by the rules
+func (s *FeedService) autoEvents(userID int64) ([]*ical.Event, error) {
+	loc := s.timezone
+	autos, err := s.storage.ListAutosByUser(userID)
+	if err != nil {
+		return nil, fmt.Errorf("list autos: %w", err)
+	}
+	names := make(map[int64]string)
+	var events []*ical.Event
+	for _, a := range autos {
+		names[a.ID] = a.Name
+		if a.InsuranceUntil != nil {
+			events = append(events, dateFeedEvent(fmt.Sprintf("auto-%d-insurance", a.ID), "🛡 Страховка: "+a.Name, *a.InsuranceUntil, loc, a.CreatedAt))
+		}
+		if a.MaintenanceUntil != nil {
+			events = append(events, dateFeedEvent(fmt.Sprintf("auto-%d-maintenance", a.ID), "🔧 ТО: "+a.Name, *a.MaintenanceUntil, loc, a.CreatedAt))
+		}
+
+		rules, err := s.storage.ListMaintenanceRules(a.ID)
+		if err != nil {
+			return nil, fmt.Errorf("list rules: %w", err)
+		}
+		for _, r := range rules {
+			if next := r.NextDate(); next != nil {
+				events = append(events, dateFeedEvent(fmt.Sprintf("autorule-%d", r.ID), fmt.Sprintf("🔧 %s: %s", r.Title, a.Name), *next, loc, r.CreatedAt))
+			}
+		}
+	}
+
+	docs, err := s.storage.ListAutoDocuments(userID)
+	if err != nil {
+		return nil, fmt.Errorf("list documents: %w", err)
+	}
+	for _, d := range docs {
+		title := d.Emoji() + " " + d.Title
+		if d.AutoID != nil && names[*d.AutoID] != "" {
+			title += ": " + names[*d.AutoID]
+		}
+		events = append(events, dateFeedEvent(fmt.Sprintf("autodoc-%d", d.ID), title, d.ExpiresAt, loc, d.CreatedAt))
+	}
+	return events, nil
+}
+
+// Calendar wraps the events into a calendar with the time zone they use
+func (s *FeedService) Calendar(events []*ical.Event) *ical.Calendar {
+	loc := s.timezone
+	cal := ical.NewCalendar()
+	cal.Props.SetText(ical.PropVersion, "2.0")
+	cal.Props.SetText(ical.PropProductID, feedProductID)
+	cal.Props.SetText(ical.PropCalendarScale, "GREGORIAN")
+
+	year := s.clock.Now().In(loc).Year()
+	for _, e := range events {
+		if usesTimezone(e) {
+			from := time.Date(year-feedTimezoneYearsPast, time.January, 1, 0, 0, 0, 0, loc)
+			to := time.Date(year+feedTimezoneYearsAhead+1, time.January, 1, 0, 0, 0, 0, loc)
+			cal.Children = append(cal.Children, caldav.VTimezone(loc, from, to))
+			break
+		}
+	}
+	for _, e := range events {
+		cal.Children = append(cal.Children, e.Component)
+	}
+	return cal
+}
+
+// FeedCalendar returns the whole feed of the user as one calendar
+func (s *FeedService) FeedCalendar(userID int64) (*ical.Calendar, error) {
+	events, err := s.Events(userID)
+	if err != nil {
+		return nil, err
+	}
+	cal := s.Calendar(events)
+	setRawProp(cal.Props, "X-WR-CALNAME", "FamilyBot")
+	setRawProp(cal.Props, "X-WR-TIMEZONE", s.timezone.String())
+	// Как часто приложению календаря обновлять подписку
+	setRawProp(cal.Props, "X-PUBLISHED-TTL", "PT1H")
+	setRawProp(cal.Props, ical.PropRefreshInterval, "PT1H")
+	return cal, nil
+}
+
+// setRawProp sets a property value as is: SetText would escape the ";" of RRULE
+func setRawProp(props ical.Props, name, value string) {
+	prop := ical.NewProp(name)
+	prop.Value = value
+	props.Set(prop)
+}
+
+// weeklyFeedEvent returns a schedule event as a weekly series; a floating one only on
+// the day confirmed for the current week
+func weeklyFeedEvent(e *domain.WeeklyEvent, loc *time.Location, now time.Time) *ical.Event {
+	if e.IsFloating {
+		if !e.IsConfirmedThisWeek(now) {
+			return nil
+		}
+		// Понедельник текущей недели ISO и подтверждённый день от него
+		monday := now.AddDate(0, 0, -(int(now.Weekday())+6)%7)
+		day := monday.AddDate(0, 0, (*e.ConfirmedDay+6)%7)
+		year, week := now.ISOWeek()
+		event := newFeedEvent(fmt.Sprintf("floating-%d-%dW%02d", e.ID, year, week), "🗓 "+e.Title, e.CreatedAt)
+		setFeedTime(event, day, e.TimeStart, e.TimeEnd, loc)
+		return event
+	}
+
+	// Серия начинается с первого своего дня после создания события
+	created := e.CreatedAt.In(loc)
+	if e.CreatedAt.IsZero() {
+		created = now
+	}
+	first := created.AddDate(0, 0, (int(e.DayOfWeek)-int(created.Weekday())+7)%7)
+	event := newFeedEvent(fmt.Sprintf("schedule-%d", e.ID), "🗓 "+e.Title, e.CreatedAt)
+	setFeedTime(event, first, e.TimeStart, e.TimeEnd, loc)
+	setRawProp(event.Props, ical.PropRecurrenceRule, "FREQ=WEEKLY;BYDAY="+weekdayToRRULE(int(e.DayOfWeek)))
+	return event
+}
+
+// setFeedTime sets the start and end of an event on the day: "HH:MM" in loc or the whole day
+// if there is no time. Without the end time the event lasts an hour, as in the two-way calendar.
+func setFeedTime(event *ical.Event, day time.Time, timeStart, timeEnd string, loc *time.Location) {
+	day = day.In(loc)
+	var sh, sm int
+	if _, err := fmt.Sscanf(timeStart, "%d:%d", &sh, &sm); err != nil {
+		event.Props.SetDate(ical.PropDateTimeStart, day)
+		event.Props.SetDate(ical.PropDateTimeEnd, day.AddDate(0, 0, 1))
+		return
+	}
+	start := time.Date(day.Year(), day.Month(), day.Day(), sh, sm, 0, 0, loc)
+	end := start.Add(time.Hour)
+	var eh, em int
+	if _, err := fmt.Sscanf(timeEnd, "%d:%d", &eh, &em); err == nil {
+		end = time.Date(day.Year(), day.Month(), day.Day(), eh, em, 0, 0, loc)
+		if !end.After(start) {
+			end = end.AddDate(0, 0, 1) // через полночь
+		}
+	}
+	event.Props.SetDateTime(ical.PropDateTimeStart, start)
+	event.Props.SetDateTime(ical.PropDateTimeEnd, end)
+}
+
+// taskFeedEvent returns a task on its due date: at its time if it has one, otherwise the whole day
+func taskFeedEvent(t *domain.Task, loc *time.Location) *ical.Event {
+	event := newFeedEvent(fmt.Sprintf("task-%d", t.ID), "📋 "+t.Title, t.CreatedAt)
+	if t.Description != "" {
+		event.Props.SetText(ical.PropDescription, t.Description)
+	}
+	due := t.DueDate.In(loc)
+	if due.Hour() == 0 && due.Minute() == 0 {
+		event.Props.SetDate(ical.PropDateTimeStart, due)
+		event.Props.SetDate(ical.PropDateTimeEnd, due.AddDate(0, 0, 1))
+	} else {
+		event.Props.SetDateTime(ical.PropDateTimeStart, due)
+		event.Props.SetDateTime(ical.PropDateTimeEnd, due.Add(time.Hour))
+	}
+	if rule := t.RecurrenceRule(); rule != "" {
+		setRawProp(event.Props, ical.PropRecurrenceRule, rule)
+	}
+	return event
+}
+
+// birthdayFeedEvent returns a yearly all-day event; a birthday without a year starts in 2000
+func birthdayFeedEvent(p *domain.Person) *ical.Event {
+	event := newFeedEvent(fmt.Sprintf("birthday-%d", p.ID), "🎂 "+p.Name, p.CreatedAt)
+	b := *p.Birthday
+	year := b.Year()
+	if year <= 1 {
+		year = 2000 // високосный: 29 февраля тоже годится
+	} else {
+		event.Props.SetText(ical.PropDescription, fmt.Sprintf("Год рождения: %d", year))
+	}
+	start := time.Date(year, b.Month(), b.Day(), 0, 0, 0, 0, time.UTC)
+	event.Props.SetDate(ical.PropDateTimeStart, start)
+	event.Props.SetDate(ical.PropDateTimeEnd, start.AddDate(0, 0, 1))
+
+	rule := "FREQ=YEARLY"
+	if b.Month() == time.February && b.Day() == 29 {
+		rule += ";BYMONTH=2;BYMONTHDAY=-1" // в невисокосный год — 28 февраля
+	}
+	setRawProp(event.Props, ical.PropRecurrenceRule, rule)
+	return event
+}
+
+// dateFeedEvent returns an all-day event on the date in loc
+func dateFeedEvent(uid, title string, date time.Time, loc *time.Location, created time.Time) *ical.Event {
+	event := newFeedEvent(uid, title, created)
+	day := date.In(loc)
+	event.Props.SetDate(ical.PropDateTimeStart, day)
+	event.Props.SetDate(ical.PropDateTimeEnd, day.AddDate(0, 0, 1))
+	return event
+}
+
+// newFeedEvent creates a VEVENT. DTSTAMP is the creation time of the source, so the
+// object and its ETag stay the same until the source changes.
+func newFeedEvent(name, summary string, created time.Time) *ical.Event {
+	event := ical.NewEvent()
+	event.Props.SetText(ical.PropUID, name+"@feed.familybot")
+	event.Props.SetText(ical.PropSummary, summary)
+	if created.IsZero() {
+		created = time.Unix(0, 0)
+	}
+	event.Props.SetDateTime(ical.PropDateTimeStamp, created.UTC().Truncate(time.Second))
+	return event
+}
+
+func feedEventUID(e *ical.Event) string {
+	uid, _ := e.Props.Text(ical.PropUID)
+	return uid
+}
+
+// usesTimezone reports whether the event has times with TZID
+func usesTimezone(e *ical.Event) bool {
+	for _, name := range []string{ical.PropDateTimeStart, ical.PropDateTimeEnd} {
+		if prop := e.Props.Get(name); prop != nil && prop.Params.Get(ical.PropTimezoneID) != "" {
+			return true
+		}
+	}
+	return false
+}
+
+// FeedObjectName returns the file name of the event in the CalDAV collection
+func FeedObjectName(e *ical.Event) string {
+	return strings.TrimSuffix(feedEventUID(e), "@feed.familybot") + ".ics"
+}
@@ -0,0 +1,152 @@
+package service_test
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/emersion/go-ical"
+	"github.com/tazhate/familybot/internal/clock"
+	"github.com/tazhate/familybot/internal/domain"
+	"github.com/tazhate/familybot/internal/service"
+)
+
+func TestFeedTokens(t *testing.T) {
+	f := newFamily(t)
+	feeds := service.NewFeedService(f.store, moscow)
+
+	feed, err := feeds.Feed(f.owner.ID)
+	if err != nil || len(feed.Token) != 40 {
+		t.Fatalf("feed %+v, %v", feed, err)
+	}
+	if again, err := feeds.Feed(f.owner.ID); err != nil || again.Token != feed.Token {
+		t.Errorf("second call gave %+v, %v; want the same token", again, err)
+	}
+	if userID, err := feeds.UserByToken(feed.Token); err != nil || userID != f.owner.ID {
+		t.Errorf("token of user %d, %v", userID, err)
+	}
+	partnerFeed, err := feeds.Feed(f.partner.ID)
+	if err != nil || partnerFeed.Token == feed.Token {
+		t.Fatalf("partner's feed %+v, %v", partnerFeed, err)
+	}
+
+	// Новая ссылка отменяет прежнюю, выключение — любую
+	rotated, err := feeds.Rotate(f.owner.ID)
+	if err != nil || rotated.Token == feed.Token {
+		t.Fatalf("rotated %+v, %v", rotated, err)
+	}
+	if _, err := feeds.UserByToken(feed.Token); !errors.Is(err, service.ErrFeedDisabled) {
+		t.Errorf("old token: %v", err)
+	}
+	if err := feeds.Disable(f.owner.ID); err != nil {
+		t.Fatal(err)
+	}
+	for _, token := range []string{rotated.Token, ""} {
+		if _, err := feeds.UserByToken(token); !errors.Is(err, service.ErrFeedDisabled) {
+			t.Errorf("token %q: %v", token, err)
+		}
+	}
+	if userID, err := feeds.UserByToken(partnerFeed.Token); err != nil || userID != f.partner.ID {
+		t.Errorf("partner's token of user %d, %v", userID, err)
+	}
+}
+
+// TestFeedEvents checks what gets into the feed: the schedule as a weekly series, a floating
+// event on the day confirmed this week, tasks with a due date, birthdays, car deadlines
+func TestFeedEvents(t *testing.T) {
+	f := newFamily(t)
+	feeds := service.NewFeedService(f.store, moscow)
+	now := time.Date(2030, time.June, 5, 12, 0, 0, 0, moscow) // среда, 23-я неделя
+	feeds.SetClock(clock.NewFake(now))
+	_, week := now.ISOWeek()
+
+	saturday := 6
+	weekly := []*domain.WeeklyEvent{
+		{UserID: f.owner.ID, DayOfWeek: domain.WeekdayMonday, TimeStart: "19:00", TimeEnd: "20:30", Title: "Футбол"},
+		{UserID: f.owner.ID, TimeStart: "10:00", Title: "Бассейн", IsFloating: true, FloatingDays: "6,0", ConfirmedDay: &saturday, ConfirmedWeek: week},
+		{UserID: f.owner.ID, TimeStart: "11:00", Title: "Рынок", IsFloating: true, FloatingDays: "6,0", ConfirmedDay: &saturday, ConfirmedWeek: week - 1},
+		{UserID: f.partner.ID, DayOfWeek: domain.WeekdayFriday, TimeStart: "18:00", Title: "Кино", IsShared: true},
+		{UserID: f.stranger.ID, DayOfWeek: domain.WeekdayFriday, TimeStart: "18:00", Title: "Соседский сбор", IsShared: true},
+	}
+	for _, e := range weekly {
+		if err := f.store.CreateWeeklyEvent(e); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	due := func(day, hour int) *time.Time {
+		d := time.Date(2030, time.June, day, hour, 0, 0, 0, moscow)
+		return &d
+	}
+	tasks := []*domain.Task{
+		{UserID: f.owner.ID, Title: "Оплатить садик", Priority: domain.PriorityWeek, DueDate: due(10, 0)},
+		{UserID: f.owner.ID, Title: "Позвонить врачу", Priority: domain.PriorityUrgent, DueDate: due(6, 15)},
+		{UserID: f.owner.ID, Title: "Когда-нибудь", Priority: domain.PrioritySomeday},
+		{UserID: f.partner.ID, Title: "Купить подарок", Priority: domain.PriorityWeek, DueDate: due(12, 0), IsShared: true},
+		{UserID: f.partner.ID, Title: "Сюрприз", Priority: domain.PriorityWeek, DueDate: due(12, 0)},
+		{UserID: f.owner.ID, Title: "Уже сделано", Priority: domain.PriorityWeek, DueDate: due(4, 0)},
+	}
+	for _, task := range tasks {
+		if err := f.store.CreateTask(task); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := f.store.MarkTaskDone(tasks[len(tasks)-1].ID); err != nil {
+		t.Fatal(err)
+	}
+
+	born := time.Date(1992, time.February, 29, 0, 0, 0, 0, time.UTC)
+	person := &domain.Person{UserID: f.owner.ID, Name: "Маша", Role: domain.RoleChild, Birthday: &born}
+	if err := f.store.CreatePerson(person); err != nil {
+		t.Fatal(err)
+	}
+	insurance := time.Date(2030, time.July, 1, 0, 0, 0, 0, moscow)
+	auto := &domain.Auto{UserID: f.owner.ID, Name: "Ford", InsuranceUntil: &insurance}
+	if err := f.store.CreateAuto(auto); err != nil {
+		t.Fatal(err)
+	}
+
+	events, err := feeds.Events(f.owner.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := make(map[string]string)
+	for _, e := range events {
+		uid, _ := e.Props.Text(ical.PropUID)
+		summary, _ := e.Props.Text(ical.PropSummary)
+		line := summary + " " + e.Props.Get(ical.PropDateTimeStart).Value + "–" + e.Props.Get(ical.PropDateTimeEnd).Value
+		if rule := e.Props.Get(ical.PropRecurrenceRule); rule != nil {
+			line += " " + rule.Value
+		}
+		got[strings.TrimSuffix(uid, "@feed.familybot")] = line
+	}
+
+	// Серия начинается в ближайший понедельник после создания, поэтому дата не проверяется
+	football := got[fmt.Sprintf("schedule-%d", weekly[0].ID)]
+	if !strings.HasPrefix(football, "🗓 Футбол ") || !strings.Contains(football, "T190000–") || !strings.HasSuffix(football, "T203000 FREQ=WEEKLY;BYDAY=MO") {
+		t.Errorf("weekly event %q", football)
+	}
+	movies := got[fmt.Sprintf("schedule-%d", weekly[3].ID)]
+	if !strings.HasSuffix(movies, "T190000 FREQ=WEEKLY;BYDAY=FR") {
+		t.Errorf("partner's shared event %q, want 18:00–19:00 on Fridays", movies)
+	}
+	want := map[string]string{
+		fmt.Sprintf("floating-%d-2030W23", weekly[1].ID): "🗓 Бассейн 20300608T100000–20300608T110000",
+		fmt.Sprintf("task-%d", tasks[0].ID):              "📋 Оплатить садик 20300610–20300611",
+		fmt.Sprintf("task-%d", tasks[1].ID):              "📋 Позвонить врачу 20300606T150000–20300606T160000",
+		fmt.Sprintf("task-%d", tasks[3].ID):              "📋 Купить подарок 20300612–20300613",
+		fmt.Sprintf("birthday-%d", person.ID):            "🎂 Маша 19920229–19920301 FREQ=YEARLY;BYMONTH=2;BYMONTHDAY=-1",
+		fmt.Sprintf("auto-%d-insurance", auto.ID):        "🛡 Страховка: Ford 20300701–20300702",
+	}
+	for uid, line := range want {
+		if got[uid] != line {
+			t.Errorf("%s: %q, want %q", uid, got[uid], line)
+		}
+	}
+	// Неподтверждённое на этой неделе, без срока, выполненное, личное партнёра и чужое — не в подписке
+	if len(got) != len(want)+2 {
+		t.Errorf("feed has %d events: %q", len(got), got)
+	}
+}
@@ -0,0 +1,364 @@
+package service
+
+import (
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/tazhate/familybot/internal/clock"
+	"github.com/tazhate/familybot/internal/domain"
+	"github.com/tazhate/familybot/internal/storage"
+)
+
+// Время жизни кода приглашения
+const inviteTTL = 7 * 24 * time.Hour
+
+// Алфавит кода приглашения: без 0/O и 1/I/L, чтобы код можно было продиктовать
+const inviteAlphabet = "ABCDEFGHJKMNPQRSTUVWXYZ23456789"
+
+var (
+	ErrNoHousehold       = errors.New("вы не состоите в семье")
+	ErrNotHouseholdAdmin = errors.New("это может сделать только админ семьи")
+)
+
+type HouseholdService struct {
+	storage storage.Store
+	clock   clock.Clock
+}
+
+func NewHouseholdService(s storage.Store) *HouseholdService {
+	return &HouseholdService{storage: s, clock: clock.Real()}
+}
+
+// SetClock replaces the system clock, e.g. to simulate days in tests
+func (s *HouseholdService) SetClock(c clock.Clock) {
+	s.clock = c
+}
+
+// Bootstrap puts OWNER (admin) and PARTNER (adult) from the config into one household.
+// Idempotent: users that already belong to a household are left as is.
+func (s *HouseholdService) Bootstrap(ownerTelegramID, partnerTelegramID int64) error {
+	owner, err := s.userByTelegramID(ownerTelegramID)
+	if err != nil {
+		return err
+	}
+	partner, err := s.userByTelegramID(partnerTelegramID)
+	if err != nil {
+		return err
+	}
+
+	var householdID int64
+	for _, u := range []*domain.User{owner, partner} {
+		if u == nil {
+			continue
+		}
+		m, err := s.storage.GetHouseholdMember(u.ID)
+		if err != nil {
+			return err
+		}
+		if m != nil {
+			householdID = m.HouseholdID
+			break
+		}
+	}
+
+	add := func(u *domain.User, role domain.HouseholdRole) error {
+		if u == nil {
+			return nil
+		}
+		m, err := s.storage.GetHouseholdMember(u.ID)
+		if err != nil || m != nil {
+			return err
+		}
+		if householdID == 0 {
+			h := &domain.Household{Name: "Семья"}
+			if err := s.storage.CreateHousehold(h); err != nil {
+				return fmt.Errorf("create household: %w", err)
+			}
+			householdID = h.ID
+		}
+		return s.storage.AddHouseholdMember(&domain.HouseholdMember{
+			HouseholdID: householdID,
+			UserID:      u.ID,
+			Role:        role,
+		})
+	}
+
+	if err := add(owner, domain.HouseholdAdmin); err != nil {
+		return err
+	}
+	return add(partner, domain.HouseholdAdult)
+}
+
+func (s *HouseholdService) userByTelegramID(telegramID int64) (*domain.User, error) {
+	if telegramID == 0 {
+		return nil, nil
+	}
+	return s.storage.GetUserByTelegramID(telegramID)
+}
+
+// Membership returns the user's membership or nil if the user has no household
+func (s *HouseholdService) Membership(userID int64) (*domain.HouseholdMember, error) {
+	return s.storage.GetHouseholdMember(userID)
+}
+
+// Get returns the user's household with its members
+func (s *HouseholdService) Get(userID int64) (*domain.Household, []*domain.HouseholdMember, error) {
+	m, err := s.storage.GetHouseholdMember(userID)
+	if err != nil {
+		return nil, nil, err
+	}
+	if m == nil {
+		return nil, nil, ErrNoHousehold
+	}
+	h, err := s.storage.GetHousehold(m.HouseholdID)
+	if err != nil {
+		return nil, nil, err
+	}
+	if h == nil {
+		return nil, nil, ErrNoHousehold
+	}
+	members, err := s.storage.ListHouseholdMembers(m.HouseholdID)
+	if err != nil {
+		return nil, nil, err
+	}
+	return h, members, nil
+}
+
+// Mates returns users of the same household, including the user
+func (s *HouseholdService) Mates(userID int64) ([]*domain.User, error) {
+	_, members, err := s.Get(userID)
+	if err != nil {
+		return nil, err
+	}
+	users := make([]*domain.User, 0, len(members))
+	for _, m := range members {
+		users = append(users, m.User)
+	}
+	return users, nil
+}
+
+// Adults returns admins and adults of the user's household, those who see
+// each other's tasks and finances. Without a household the list is empty.
+func (s *HouseholdService) Adults(userID int64) ([]*domain.User, error) {
+	m, err := s.storage.GetHouseholdMember(userID)
+	if err != nil || m == nil {
+		return nil, err
+	}
+	members, err := s.storage.ListHouseholdMembers(m.HouseholdID)
+	if err != nil {
+		return nil, err
+	}
+	var users []*domain.User
+	for _, mm := range members {
+		if mm.Role.CanSeeFinance() {
+			users = append(users, mm.User)
+		}
+	}
+	return users, nil
+}
+
+// IsAdult reports whether the user is an admin or adult of some household
+func (s *HouseholdService) IsAdult(userID int64) (bool, error) {
+	m, err := s.storage.GetHouseholdMember(userID)
+	if err != nil {
+		return false, err
+	}
+	return m != nil && m.Role.CanSeeFinance(), nil
+}
+
+// CreateInvite creates a one-time invite code into the admin's household
+func (s *HouseholdService) CreateInvite(userID int64, role domain.HouseholdRole) (*domain.HouseholdInvite, error) {
+	m, err := s.admin(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	code, err := newInviteCode()
+	if err != nil {
+		return nil, err
+	}
+	inv := &domain.HouseholdInvite{
+		Code:        code,
+		HouseholdID: m.HouseholdID,
+		Role:        role,
+		CreatedBy:   userID,
+		ExpiresAt:   s.clock.Now().Add(inviteTTL),
+	}
+	if err := s.storage.CreateHouseholdInvite(inv); err != nil {
+		return nil, err
+	}
+	return inv, nil
+}
+
+// CheckInvite returns the invite if it exists, is unused and not expired
+func (s *HouseholdService) CheckInvite(code string) (*domain.HouseholdInvite, error) {
+	inv, err := s.storage.GetHouseholdInvite(strings.ToUpper(strings.TrimSpace(code)))
+	if err != nil {
+		return nil, err
+	}
+	if inv == nil || !inv.IsValid(s.clock.Now()) {
+		return nil, errors.New("приглашение не найдено или истекло")
+	}
+	return inv, nil
+}
+
+// Join adds the user to the household of the invite
+func (s *HouseholdService) Join(user *domain.User, code string) (*domain.Household, domain.HouseholdRole, error) {
+	inv, err := s.CheckInvite(code)
+	if err != nil {
+		return nil, "", err
+	}
+
+	existing, err := s.storage.GetHouseholdMember(user.ID)
+	if err != nil {
+		return nil, "", err
+	}
+	if existing != nil {
+		return nil, "", errors.New("вы уже состоите в семье")
+	}
+
+	err = s.storage.AcceptHouseholdInvite(inv.Code, &domain.HouseholdMember{
+		HouseholdID: inv.HouseholdID,
+		UserID:      user.ID,
+		Role:        inv.Role,
+	}, s.clock.Now())
+	if errors.Is(err, storage.ErrInviteUsed) {
+		return nil, "", errors.New("приглашение уже использовано")
+	}
+	if err != nil {
+		return nil, "", err
+	}
+
+	h, err := s.storage.GetHousehold(inv.HouseholdID)
+	if err != nil {
+		return nil, "", err
+	}
+	return h, inv.Role, nil
+}
+
+// SetRole changes a member's role; the last admin cannot be demoted
+func (s *HouseholdService) SetRole(adminID, targetID int64, role domain.HouseholdRole) error {
+	target, err := s.mateOf(adminID, targetID)
+	if err != nil {
+		return err
+	}
+	if target.Role == domain.HouseholdAdmin && role != domain.HouseholdAdmin {
+		if err := s.checkNotLastAdmin(target.HouseholdID); err != nil {
+			return err
+		}
+	}
+	return s.storage.UpdateHouseholdMemberRole(targetID, role)
+}
+
+// Remove removes a member from the household; the last admin cannot be removed
+func (s *HouseholdService) Remove(adminID, targetID int64) error {
+	target, err := s.mateOf(adminID, targetID)
+	if err != nil {
+		return err
+	}
+	if target.Role == domain.HouseholdAdmin {
+		if err := s.checkNotLastAdmin(target.HouseholdID); err != nil {
+			return err
+		}
+	}
+	return s.storage.RemoveHouseholdMember(targetID)
+}
+
+// Rename renames the admin's household
+func (s *HouseholdService) Rename(adminID int64, name string) error {
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return errors.New("название не может быть пустым")
+	}
+	m, err := s.admin(adminID)
+	if err != nil {
+		return err
+	}
+	return s.storage.UpdateHouseholdName(m.HouseholdID, name)
+}
+
+func (s *HouseholdService) admin(userID int64) (*domain.HouseholdMember, error) {
+	m, err := s.storage.GetHouseholdMember(userID)
+	if err != nil {
+		return nil, err
+	}
+	if m == nil {
+		return nil, ErrNoHousehold
+	}
+	if !m.Role.CanManage() {
+		return nil, ErrNotHouseholdAdmin
+	}
+	return m, nil
+}
+
+// mateOf checks that adminID manages the household targetID belongs to
+func (s *HouseholdService) mateOf(adminID, targetID int64) (*domain.HouseholdMember, error) {
+	admin, err := s.admin(adminID)
+	if err != nil {
+		return nil, err
+	}
+	target, err := s.storage.GetHouseholdMember(targetID)
+	if err != nil {
+		return nil, err
+	}
+	if target == nil || target.HouseholdID != admin.HouseholdID {
+		return nil, errors.New("участник не найден")
+	}
+	return target, nil
+}
+
+func (s *HouseholdService) checkNotLastAdmin(householdID int64) error {
+	members, err := s.storage.ListHouseholdMembers(householdID)
+	if err != nil {
+		return err
+	}
+	admins := 0
+	for _, m := range members {
+		if m.Role == domain.HouseholdAdmin {
+			admins++
+		}
+	}
+	if admins <= 1 {
+		return errors.New("в семье должен остаться хотя бы один админ")
+	}
+	return nil
+}
+
+// sameHousehold reports whether both users are members of the same household
+func sameHousehold(store storage.Store, a, b int64) (bool, error) {
+	ma, err := store.GetHouseholdMember(a)
+	if err != nil {
+		return false, fmt.Errorf("get household member: %w", err)
+	}
+	mb, err := store.GetHouseholdMember(b)
+	if err != nil {
+		return false, fmt.Errorf("get household member: %w", err)
+	}
+	return ma != nil && mb != nil && ma.HouseholdID == mb.HouseholdID, nil
+}
+
+// visibleTo reports whether a row of ownerID may be shown to userID:
+// own rows, and shared rows of the same household, as in the lists
+func visibleTo(store storage.Store, ownerID, userID int64, isShared bool) (bool, error) {
+	if ownerID == userID {
+		return true, nil
+	}
+	if !isShared {
+		return false, nil
+	}
+	return sameHousehold(store, ownerID, userID)
+}
+
+func newInviteCode() (string, error) {
+	buf := make([]byte, 8)
+	if _, err := rand.Read(buf); err != nil {
+		return "", fmt.Errorf("generate invite code: %w", err)
+	}
+	for i, b := range buf {
+		buf[i] = inviteAlphabet[int(b)%len(inviteAlphabet)]
+	}
+	return string(buf), nil
+}
@@ -0,0 +1,215 @@
+package service_test
+
+import (
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/tazhate/familybot/internal/clock"
+	"github.com/tazhate/familybot/internal/domain"
+	"github.com/tazhate/familybot/internal/service"
+	"github.com/tazhate/familybot/internal/storage"
+	"github.com/tazhate/familybot/internal/storage/storagetest"
+)
+
+// family — owner и partner в одной семье, stranger в другой
+type family struct {
+	store                    *storage.Storage
+	owner, partner, stranger *domain.User
+}
+
+func newFamily(t *testing.T) *family {
+	t.Helper()
+	store := storagetest.SQLite(t)
+	f := &family{store: store}
+	for i, u := range []**domain.User{&f.owner, &f.partner, &f.stranger} {
+		*u = &domain.User{TelegramID: int64(100 * (i + 1)), Name: []string{"owner", "partner", "stranger"}[i], Role: domain.RoleOwner}
+		if err := store.CreateUser(*u); err != nil {
+			t.Fatal(err)
+		}
+	}
+	home, other := &domain.Household{Name: "Дом"}, &domain.Household{Name: "Соседи"}
+	for _, h := range []*domain.Household{home, other} {
+		if err := store.CreateHousehold(h); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, m := range []*domain.HouseholdMember{
+		{HouseholdID: home.ID, UserID: f.owner.ID, Role: domain.HouseholdAdmin},
+		{HouseholdID: home.ID, UserID: f.partner.ID, Role: domain.HouseholdAdult},
+		{HouseholdID: other.ID, UserID: f.stranger.ID, Role: domain.HouseholdAdmin},
+	} {
+		if err := store.AddHouseholdMember(m); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return f
+}
+
+func TestGetVisible(t *testing.T) {
+	f := newFamily(t)
+	owner := f.owner
+
+	shared := &domain.Task{UserID: owner.ID, ChatID: owner.TelegramID, Title: "Общая", Priority: domain.PriorityWeek, IsShared: true}
+	private := &domain.Task{UserID: owner.ID, ChatID: owner.TelegramID, Title: "Личная", Priority: domain.PriorityWeek}
+	for _, task := range []*domain.Task{shared, private} {
+		if err := f.store.CreateTask(task); err != nil {
+			t.Fatal(err)
+		}
+	}
+	sharedWeekly := &domain.WeeklyEvent{UserID: owner.ID, DayOfWeek: domain.WeekdayMonday, TimeStart: "10:00", Title: "Бассейн", IsShared: true}
+	privateWeekly := &domain.WeeklyEvent{UserID: owner.ID, DayOfWeek: domain.WeekdayMonday, TimeStart: "11:00", Title: "Зал"}
+	for _, e := range []*domain.WeeklyEvent{sharedWeekly, privateWeekly} {
+		if err := f.store.CreateWeeklyEvent(e); err != nil {
+			t.Fatal(err)
+		}
+	}
+	start := time.Date(2030, time.March, 10, 19, 0, 0, 0, time.UTC)
+	sharedEvent := &domain.CalendarEvent{UserID: owner.ID, Title: "Ужин", StartTime: start, EndTime: start.Add(time.Hour), IsShared: true}
+	privateEvent := &domain.CalendarEvent{UserID: owner.ID, Title: "Врач", StartTime: start, EndTime: start.Add(time.Hour)}
+	for _, e := range []*domain.CalendarEvent{sharedEvent, privateEvent} {
+		if err := f.store.CreateCalendarEvent(e); err != nil {
+			t.Fatal(err)
+		}
+	}
+	person := &domain.Person{UserID: owner.ID, Name: "Лука", Role: domain.RoleChild}
+	if err := f.store.CreatePerson(person); err != nil {
+		t.Fatal(err)
+	}
+
+	tasks := service.NewTaskService(f.store)
+	schedule := service.NewScheduleService(f.store)
+	calendars := service.NewCalendarService(f.store, time.UTC)
+	persons := service.NewPersonService(f.store)
+	tests := []struct {
+		user    *domain.User
+		shared  bool
+		allowed bool
+	}{
+		{owner, false, true},
+		{f.partner, true, true},
+		{f.partner, false, false},
+		{f.stranger, true, false},
+		{f.stranger, false, false},
+	}
+	for _, tt := range tests {
+		task, event, weekly := private, privateEvent, privateWeekly
+		if tt.shared {
+			task, event, weekly = shared, sharedEvent, sharedWeekly
+		}
+		// Чат партнёра свой, доступ только через семью
+		gotTask, err := tasks.GetVisible(task.ID, tt.user.ID, tt.user.TelegramID)
+		if err != nil || (gotTask != nil) != tt.allowed {
+			t.Errorf("%s → task «%s»: %v, %v; want allowed %v", tt.user.Name, task.Title, gotTask, err, tt.allowed)
+		}
+		gotWeekly, err := schedule.GetVisible(weekly.ID, tt.user.ID)
+		if err != nil || (gotWeekly != nil) != tt.allowed {
+			t.Errorf("%s → weekly «%s»: %v, %v; want allowed %v", tt.user.Name, weekly.Title, gotWeekly, err, tt.allowed)
+		}
+		gotEvent, err := calendars.GetVisibleEvent(event.ID, tt.user.ID)
+		if err != nil || (gotEvent != nil) != tt.allowed {
+			t.Errorf("%s → event «%s»: %v, %v; want allowed %v", tt.user.Name, event.Title, gotEvent, err, tt.allowed)
+		}
+	}
+
+	// Люди не бывают общими
+	for _, u := range []*domain.User{f.partner, f.stranger} {
+		if p, err := persons.GetOwned(person.ID, u.ID); err != nil || p != nil {
+			t.Errorf("%s sees person %v, %v", u.Name, p, err)
+		}
+	}
+	if p, err := persons.GetOwned(person.ID, owner.ID); err != nil || p == nil {
+		t.Errorf("owner does not see own person: %v", err)
+	}
+	if task, err := tasks.GetVisible(999, owner.ID, owner.TelegramID); err != nil || task != nil {
+		t.Errorf("missing task: %v, %v", task, err)
+	}
+}
+
+func TestAdults(t *testing.T) {
+	f := newFamily(t)
+	kid := &domain.User{TelegramID: 400, Name: "kid", Role: domain.RoleOwner}
+	if err := f.store.CreateUser(kid); err != nil {
+		t.Fatal(err)
+	}
+	owner, err := f.store.GetHouseholdMember(f.owner.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := f.store.AddHouseholdMember(&domain.HouseholdMember{HouseholdID: owner.HouseholdID, UserID: kid.ID, Role: domain.HouseholdChild}); err != nil {
+		t.Fatal(err)
+	}
+
+	households := service.NewHouseholdService(f.store)
+	adults, err := households.Adults(kid.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, u := range adults {
+		names = append(names, u.Name)
+	}
+	if !slices.Equal(names, []string{"owner", "partner"}) {
+		t.Errorf("adults %q, want [owner partner]", names)
+	}
+
+	for _, tt := range []struct {
+		user  *domain.User
+		adult bool
+	}{{f.owner, true}, {f.partner, true}, {f.stranger, true}, {kid, false}} {
+		if ok, err := households.IsAdult(tt.user.ID); err != nil || ok != tt.adult {
+			t.Errorf("IsAdult(%s) = %v, %v; want %v", tt.user.Name, ok, err, tt.adult)
+		}
+	}
+}
+
+func TestJoin(t *testing.T) {
+	f := newFamily(t)
+	clk := clock.NewFake(time.Date(2030, time.March, 10, 12, 0, 0, 0, time.UTC))
+	households := service.NewHouseholdService(f.store)
+	households.SetClock(clk)
+	newcomer := func(telegramID int64) *domain.User {
+		u := &domain.User{TelegramID: telegramID, Name: "Ира", Role: domain.RoleMember}
+		if err := f.store.CreateUser(u); err != nil {
+			t.Fatal(err)
+		}
+		return u
+	}
+
+	inv, err := households.CreateInvite(f.owner.ID, domain.HouseholdAdult)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := households.CheckInvite("NOSUCHCODE"); err == nil {
+		t.Error("unknown code accepted")
+	}
+	// Код можно ввести строчными и с пробелами
+	if _, err := households.CheckInvite(" " + strings.ToLower(inv.Code) + " "); err != nil {
+		t.Errorf("check invite: %v", err)
+	}
+	if _, _, err := households.Join(f.stranger, inv.Code); err == nil {
+		t.Error("member of another household joined")
+	}
+
+	ira := newcomer(400)
+	h, role, err := households.Join(ira, inv.Code)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if h.Name != "Дом" || role != domain.HouseholdAdult {
+		t.Errorf("joined %q as %s", h.Name, role)
+	}
+	if _, _, err := households.Join(newcomer(500), inv.Code); err == nil {
+		t.Error("invite used twice")
+	}
+
+	expired, err := households.CreateInvite(f.owner.ID, domain.HouseholdChild)
+	if err != nil {
+		t.Fatal(err)
+	}
+	clk.Advance(8 * 24 * time.Hour)
+	if _, err := households.CheckInvite(expired.Code); err == nil {
+		t.Error("expired invite accepted")
+	}
+}
@@ -7,17 +7,24 @@ import (
 	"strings"
 	"time"
 
+	"github.com/tazhate/familybot/internal/clock"
 	"github.com/tazhate/familybot/internal/domain"
 	"github.com/tazhate/familybot/internal/storage"
 )
 
 type PersonService struct {
-	storage         *storage.Storage
+	storage         storage.Store
 	reminderService *ReminderService
+	clock           clock.Clock
 }
 
-func NewPersonService(s *storage.Storage) *PersonService {
-	return &PersonService{storage: s}
+func NewPersonService(s storage.Store) *PersonService {
+	return &PersonService{storage: s, clock: clock.Real()}
+}
+
+// SetClock replaces the system clock, e.g. to simulate days in tests
+func (s *PersonService) SetClock(c clock.Clock) {
+	s.clock = c
 }
 
 // SetReminderService sets the reminder service for auto-creating birthday reminders
@@ -100,7 +107,7 @@ func (s *PersonService) createBirthdayReminders(userID int64, person *domain.Per
 		title := fmt.Sprintf(r.titleFmt, person.Name)
 		if person.Birthday.Year() > 1 {
 			// Calculate age they will turn
-			age := person.Age()
+			age := person.Age(s.clock.Now())
 			if r.daysBefore > 0 {
 				age++ // They haven't had their birthday yet
 			}
@@ -134,6 +141,15 @@ func (s *PersonService) Get(id int64) (*domain.Person, error) {
 	return s.storage.GetPerson(id)
 }
 
+// GetOwned returns the user's person by ID, nil if it belongs to someone else
+func (s *PersonService) GetOwned(id int64, userID int64) (*domain.Person, error) {
+	person, err := s.storage.GetPerson(id)
+	if err != nil || person == nil || person.UserID != userID {
+		return nil, err
+	}
+	return person, nil
+}
+
 // GetByName returns a person by name (case-insensitive)
 func (s *PersonService) GetByName(userID int64, name string) (*domain.Person, error) {
 	return s.storage.GetPersonByName(userID, name)
@@ -164,7 +180,7 @@ func (s *PersonService) ListBirthdays(userID int64) ([]*domain.Person, error) {
 
 // ListUpcomingBirthdays returns persons with birthdays in the next N days
 func (s *PersonService) ListUpcomingBirthdays(userID int64, days int) ([]*domain.Person, error) {
-	return s.storage.ListUpcomingBirthdays(userID, days)
+	return s.storage.ListUpcomingBirthdays(userID, days, s.clock.Now())
 }
 
 // Update updates a person
@@ -209,21 +225,11 @@ func (s *PersonService) ParseAddPersonArgs(args string) (name string, role domai
 	role = domain.RoleContact // default
 
 	if len(parts) >= 2 {
-		// Try to parse role
-		switch strings.ToLower(parts[1]) {
-		case "ребёнок", "ребенок", "child":
-			role = domain.RoleChild
-		case "семья", "family":
-			role = domain.RoleFamily
-		case "контакт", "contact":
-			role = domain.RoleContact
-		case "партнёр_ребёнок", "partner_child":
-			role = domain.RolePartnerChild
-		default:
-			// Maybe it's a date?
-			if bd := s.parseDate(parts[1]); bd != nil {
-				birthday = bd
-			}
+		// Try to parse role, maybe it's a date
+		if r, ok := domain.ParsePersonRole(parts[1]); ok {
+			role = r
+		} else if bd := s.parseDate(parts[1]); bd != nil {
+			birthday = bd
 		}
 	}
 
@@ -235,6 +241,15 @@ func (s *PersonService) ParseAddPersonArgs(args string) (name string, role domai
 	return
 }
 
+// ParseBirthday parses a birthday: 12.06.2017 or 17.12 (year unknown)
+func (s *PersonService) ParseBirthday(str string) (*time.Time, error) {
+	birthday := s.parseDate(strings.TrimSpace(str))
+	if birthday == nil {
+		return nil, errors.New("формат даты: ДД.ММ.ГГГГ или ДД.ММ")
+	}
+	return birthday, nil
+}
+
 // parseDate parses date in formats: DD.MM.YYYY, DD.MM, DD/MM/YYYY, DD/MM
 func (s *PersonService) parseDate(str string) *time.Time {
 	formats := []string{
@@ -281,10 +296,10 @@ func (s *PersonService) FormatPersonList(persons []*domain.Person) string {
 		sb.WriteString(fmt.Sprintf("%s <b>%s</b>", p.RoleEmoji(), p.Name))
 		if p.HasBirthday() {
 			if p.Birthday.Year() > 1 {
-				sb.WriteString(fmt.Sprintf(" (%d лет)", p.Age()))
+				sb.WriteString(fmt.Sprintf(" (%d лет)", p.Age(s.clock.Now())))
 			}
 			sb.WriteString(fmt.Sprintf(" 🎂 %s", p.Birthday.Format("02.01")))
-			days := p.DaysUntilBirthday()
+			days := p.DaysUntilBirthday(s.clock.Now())
 			if days == 0 {
 				sb.WriteString(" <b>СЕГОДНЯ!</b>")
 			} else if days <= 7 {
@@ -304,11 +319,11 @@ func (s *PersonService) FormatBirthdaysList(persons []*domain.Person) string {
 
 	var sb strings.Builder
 	for _, p := range persons {
-		days := p.DaysUntilBirthday()
+		days := p.DaysUntilBirthday(s.clock.Now())
 		sb.WriteString(fmt.Sprintf("%s <b>%s</b>", p.RoleEmoji(), p.Name))
 
 		if p.Birthday.Year() > 1 {
-			nextAge := p.Age()
+			nextAge := p.Age(s.clock.Now())
 			if days > 0 {
 				nextAge++
 			}
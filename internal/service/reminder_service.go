@@ -7,36 +7,44 @@ import (
 	"time"
 
 	"github.com/robfig/cron/v3"
+	"github.com/tazhate/familybot/internal/clock"
 	"github.com/tazhate/familybot/internal/domain"
 	"github.com/tazhate/familybot/internal/storage"
 )
 
 type ReminderService struct {
-	storage  *storage.Storage
+	storage  storage.Store
 	timezone *time.Location
+	clock    clock.Clock
 }
 
-func NewReminderService(s *storage.Storage, tz *time.Location) *ReminderService {
+func NewReminderService(s storage.Store, tz *time.Location) *ReminderService {
 	return &ReminderService{
 		storage:  s,
 		timezone: tz,
+		clock:    clock.Real(),
 	}
 }
 
+// SetClock replaces the system clock, e.g. to simulate days in tests
+func (s *ReminderService) SetClock(c clock.Clock) {
+	s.clock = c
+}
+
 func (s *ReminderService) Create(userID int64, title string, reminderType domain.ReminderType, params domain.ReminderParams) (*domain.Reminder, error) {
 	title = strings.TrimSpace(title)
 	if title == "" {
 		return nil, fmt.Errorf("reminder title cannot be empty")
 	}
 
-	schedule, err := s.buildCronSchedule(reminderType, params)
+	schedule, err := params.RRule(reminderType)
 	if err != nil {
 		return nil, fmt.Errorf("build schedule: %w", err)
 	}
 
 	paramsJSON, _ := json.Marshal(params)
 
-	nextRun, err := s.calculateNextRun(schedule)
+	nextRun, err := domain.NextReminderRun(reminderType, params, s.clock.Now().In(s.timezone))
 	if err != nil {
 		return nil, fmt.Errorf("calculate next run: %w", err)
 	}
@@ -58,58 +66,63 @@ func (s *ReminderService) Create(userID int64, title string, reminderType domain
 	return reminder, nil
 }
 
-func (s *ReminderService) buildCronSchedule(reminderType domain.ReminderType, params domain.ReminderParams) (string, error) {
-	timeStr := params.Time
-	if timeStr == "" {
-		timeStr = "11:00"
-	}
-	parts := strings.Split(timeStr, ":")
-	if len(parts) != 2 {
-		return "", fmt.Errorf("invalid time format: %s", timeStr)
+// nextRun считает следующий запуск по типу и параметрам напоминания.
+// Если параметры не читаются (очень старые записи), используем cron из Schedule.
+func (s *ReminderService) nextRun(r *domain.Reminder, after time.Time) (time.Time, error) {
+	var params domain.ReminderParams
+	if err := json.Unmarshal([]byte(r.Params), &params); err == nil {
+		if next, err := domain.NextReminderRun(r.Type, params, after.In(s.timezone)); err == nil {
+			return next, nil
+		}
 	}
-	hour, minute := parts[0], parts[1]
-
-	switch reminderType {
-	case domain.ReminderDaily:
-		// Каждый день в указанное время
-		return fmt.Sprintf("%s %s * * *", minute, hour), nil
-
-	case domain.ReminderWeekly:
-		// Еженедельно в указанный день
-		return fmt.Sprintf("%s %s * * %d", minute, hour, params.DayOfWeek), nil
-
-	case domain.ReminderMonthly:
-		// Ежемесячно в указанный день
-		return fmt.Sprintf("%s %s %d * *", minute, hour, params.DayOfMonth), nil
-
-	case domain.ReminderYearly:
-		// Ежегодно
-		return fmt.Sprintf("%s %s %d %d *", minute, hour, params.Day, params.Month), nil
-
-	case domain.ReminderMonthWeek:
-		// N-я неделя месяца, определённый день
-		// Cron не поддерживает это напрямую, используем ближайшее приближение
-		// Будем проверять программно при выполнении
-		return fmt.Sprintf("%s %s * * %d", minute, hour, params.DayOfWeek), nil
 
-	case domain.ReminderFloating:
-		// Плавающие — без автоматического расписания
-		return fmt.Sprintf("%s %s * * *", minute, hour), nil
-
-	default:
-		return "", fmt.Errorf("unknown reminder type: %s", reminderType)
+	parser := cron.NewParser(cron.Minute | cron.Hour | cron.Dom | cron.Month | cron.Dow)
+	sched, err := parser.Parse(r.Schedule)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("parse schedule: %w", err)
 	}
+	return sched.Next(after.In(s.timezone)), nil
 }
 
-func (s *ReminderService) calculateNextRun(schedule string) (time.Time, error) {
-	parser := cron.NewParser(cron.Minute | cron.Hour | cron.Dom | cron.Month | cron.Dow)
-	sched, err := parser.Parse(schedule)
+// RecalculateNextRuns пересчитывает next_run всех активных напоминаний, например
+// после исправления расписаний month_week/floating, которые раньше считались по cron.
+func (s *ReminderService) RecalculateNextRuns() error {
+	users, err := s.storage.ListUsers()
 	if err != nil {
-		return time.Time{}, fmt.Errorf("parse schedule: %w", err)
+		return fmt.Errorf("list users: %w", err)
 	}
 
-	now := time.Now().In(s.timezone)
-	return sched.Next(now), nil
+	now := s.clock.Now().In(s.timezone)
+	for _, u := range users {
+		reminders, err := s.storage.ListRemindersByUser(u.ID)
+		if err != nil {
+			return fmt.Errorf("list reminders: %w", err)
+		}
+		for _, r := range reminders {
+			// Просроченные не трогаем — планировщик отправит их и посчитает дальше
+			if !r.IsActive || (r.NextRun != nil && !r.NextRun.After(now)) {
+				continue
+			}
+			next, err := s.nextRun(r, now)
+			if err != nil {
+				continue
+			}
+			if r.NextRun != nil && r.NextRun.Equal(next) {
+				continue
+			}
+			var params domain.ReminderParams
+			if json.Unmarshal([]byte(r.Params), &params) == nil {
+				if rule, err := params.RRule(r.Type); err == nil {
+					r.Schedule = rule
+				}
+			}
+			r.NextRun = &next
+			if err := s.storage.UpdateReminder(r); err != nil {
+				return fmt.Errorf("update reminder %d: %w", r.ID, err)
+			}
+		}
+	}
+	return nil
 }
 
 func (s *ReminderService) List(userID int64) ([]*domain.Reminder, error) {
@@ -121,7 +134,7 @@ func (s *ReminderService) Get(reminderID int64) (*domain.Reminder, error) {
 }
 
 func (s *ReminderService) GetDueReminders() ([]*domain.Reminder, error) {
-	now := time.Now().In(s.timezone)
+	now := s.clock.Now().In(s.timezone)
 	return s.storage.ListDueReminders(now)
 }
 
@@ -134,12 +147,12 @@ func (s *ReminderService) MarkSent(reminderID int64) error {
 		return fmt.Errorf("reminder not found")
 	}
 
-	nextRun, err := s.calculateNextRun(reminder.Schedule)
+	now := s.clock.Now().In(s.timezone)
+	nextRun, err := s.nextRun(reminder, now)
 	if err != nil {
 		return fmt.Errorf("calculate next run: %w", err)
 	}
 
-	now := time.Now().In(s.timezone)
 	return s.storage.UpdateReminderNextRun(reminderID, now, nextRun)
 }
 
@@ -6,18 +6,24 @@ import (
 	"regexp"
 	"strconv"
 	"strings"
-	"time"
 
+	"github.com/tazhate/familybot/internal/clock"
 	"github.com/tazhate/familybot/internal/domain"
 	"github.com/tazhate/familybot/internal/storage"
 )
 
 type ScheduleService struct {
-	storage *storage.Storage
+	storage storage.Store
+	clock   clock.Clock
 }
 
-func NewScheduleService(s *storage.Storage) *ScheduleService {
-	return &ScheduleService{storage: s}
+func NewScheduleService(s storage.Store) *ScheduleService {
+	return &ScheduleService{storage: s, clock: clock.Real()}
+}
+
+// SetClock replaces the system clock, e.g. to simulate days in tests
+func (s *ScheduleService) SetClock(c clock.Clock) {
+	s.clock = c
 }
 
 // Create creates a new weekly event
@@ -54,7 +60,7 @@ func (s *ScheduleService) ListForDay(userID int64, day domain.Weekday, includeSh
 
 // ListForToday returns events for today (including shared)
 func (s *ScheduleService) ListForToday(userID int64, includeShared bool) ([]*domain.WeeklyEvent, error) {
-	today := domain.Weekday(time.Now().Weekday())
+	today := domain.Weekday(s.clock.Now().Weekday())
 	return s.ListForDay(userID, today, includeShared)
 }
 
@@ -144,7 +150,7 @@ func (s *ScheduleService) ConfirmFloatingDay(eventID int64, userID int64, day do
 		return errors.New("недопустимый день для этого события")
 	}
 
-	_, week := time.Now().ISOWeek()
+	_, week := s.clock.Now().ISOWeek()
 	dayInt := int(day)
 	return s.storage.UpdateWeeklyEventConfirmedDay(eventID, &dayInt, week)
 }
@@ -159,6 +165,19 @@ func (s *ScheduleService) Get(id int64) (*domain.WeeklyEvent, error) {
 	return s.storage.GetWeeklyEvent(id)
 }
 
+// GetVisible returns an event of the user or a shared one of the household, nil otherwise
+func (s *ScheduleService) GetVisible(id int64, userID int64) (*domain.WeeklyEvent, error) {
+	event, err := s.storage.GetWeeklyEvent(id)
+	if err != nil || event == nil {
+		return nil, err
+	}
+	ok, err := visibleTo(s.storage, event.UserID, userID, event.IsShared)
+	if err != nil || !ok {
+		return nil, err
+	}
+	return event, nil
+}
+
 // ParseFloatingArgs parses "/addfloating Сб,Вс 10:00 Лука" format
 func (s *ScheduleService) ParseFloatingArgs(args string) (days []domain.Weekday, timeStart, timeEnd, title string, err error) {
 	parts := strings.Fields(args)
@@ -184,21 +203,8 @@ func (s *ScheduleService) ParseFloatingArgs(args string) (days []domain.Weekday,
 	}
 
 	// Parse time
-	timeStr := parts[1]
-	if strings.Contains(timeStr, "-") {
-		timeParts := strings.Split(timeStr, "-")
-		timeStart = timeParts[0]
-		if len(timeParts) > 1 {
-			timeEnd = timeParts[1]
-		}
-	} else {
-		timeStart = timeStr
-	}
-
-	// Validate time format
-	timeRe := regexp.MustCompile(`^\d{1,2}:\d{2}$`)
-	if !timeRe.MatchString(timeStart) {
-		err = errors.New("неверный формат времени (ЧЧ:ММ)")
+	timeStart, timeEnd, err = ParseTimeRange(parts[1])
+	if err != nil {
 		return
 	}
 
@@ -207,6 +213,17 @@ func (s *ScheduleService) ParseFloatingArgs(args string) (days []domain.Weekday,
 	return
 }
 
+var timeRe = regexp.MustCompile(`^\d{1,2}:\d{2}$`)
+
+// ParseTimeRange parses "17:30" or "16:00-20:00"
+func ParseTimeRange(s string) (timeStart, timeEnd string, err error) {
+	timeStart, timeEnd, _ = strings.Cut(strings.TrimSpace(s), "-")
+	if !timeRe.MatchString(timeStart) {
+		return "", "", errors.New("неверный формат времени (ЧЧ:ММ)")
+	}
+	return timeStart, timeEnd, nil
+}
+
 // ParseAddArgs parses "/addweekly Пн 17:30 Федя спорт" or "/addweekly Пн 17:30 !15 Федя спорт" format
 func (s *ScheduleService) ParseAddArgs(args string) (dayOfWeek domain.Weekday, timeStart, timeEnd, title string, reminderBefore int, err error) {
 	parts := strings.Fields(args)
@@ -224,21 +241,8 @@ func (s *ScheduleService) ParseAddArgs(args string) (dayOfWeek domain.Weekday, t
 	dayOfWeek = day
 
 	// Parse time (could be "17:30" or "16:00-20:00")
-	timeStr := parts[1]
-	if strings.Contains(timeStr, "-") {
-		timeParts := strings.Split(timeStr, "-")
-		timeStart = timeParts[0]
-		if len(timeParts) > 1 {
-			timeEnd = timeParts[1]
-		}
-	} else {
-		timeStart = timeStr
-	}
-
-	// Validate time format
-	timeRe := regexp.MustCompile(`^\d{1,2}:\d{2}$`)
-	if !timeRe.MatchString(timeStart) {
-		err = errors.New("неверный формат времени (ЧЧ:ММ)")
+	timeStart, timeEnd, err = ParseTimeRange(parts[1])
+	if err != nil {
 		return
 	}
 
@@ -283,7 +287,7 @@ func (s *ScheduleService) formatWeekScheduleInternal(events []*domain.WeeklyEven
 		if e.IsFloating {
 			floatingEvents = append(floatingEvents, e)
 			// If confirmed this week, also show in the confirmed day
-			if e.IsConfirmedThisWeek() && e.ConfirmedDay != nil {
+			if e.IsConfirmedThisWeek(s.clock.Now()) && e.ConfirmedDay != nil {
 				byDay[domain.Weekday(*e.ConfirmedDay)] = append(byDay[domain.Weekday(*e.ConfirmedDay)], e)
 			}
 		} else {
@@ -296,7 +300,7 @@ func (s *ScheduleService) formatWeekScheduleInternal(events []*domain.WeeklyEven
 	// Show floating events that need confirmation
 	unconfirmedFloating := false
 	for _, e := range floatingEvents {
-		if !e.IsConfirmedThisWeek() {
+		if !e.IsConfirmedThisWeek(s.clock.Now()) {
 			unconfirmedFloating = true
 			break
 		}
@@ -305,7 +309,7 @@ func (s *ScheduleService) formatWeekScheduleInternal(events []*domain.WeeklyEven
 	if unconfirmedFloating {
 		sb.WriteString("<b>⚡️ Плавающие (выбери день):</b>\n")
 		for _, e := range floatingEvents {
-			if !e.IsConfirmedThisWeek() {
+			if !e.IsConfirmedThisWeek(s.clock.Now()) {
 				days := e.GetFloatingDays()
 				var dayNames []string
 				for _, d := range days {
@@ -323,7 +327,7 @@ func (s *ScheduleService) formatWeekScheduleInternal(events []*domain.WeeklyEven
 		domain.WeekdayThursday, domain.WeekdayFriday, domain.WeekdaySaturday, domain.WeekdaySunday,
 	}
 
-	today := domain.Weekday(time.Now().Weekday())
+	today := domain.Weekday(s.clock.Now().Weekday())
 
 	for _, day := range daysOrder {
 		dayEvents := byDay[day]
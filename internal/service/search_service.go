@@ -0,0 +1,60 @@
+package service
+
+import (
+	"fmt"
+	"html"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/tazhate/familybot/internal/domain"
+	"github.com/tazhate/familybot/internal/storage"
+)
+
+const searchLimit = 10
+
+type SearchService struct {
+	storage storage.Store
+}
+
+func NewSearchService(s storage.Store) *SearchService {
+	return &SearchService{storage: s}
+}
+
+// Search ищет по задачам, людям, чек-листам, расписанию и календарю.
+func (s *SearchService) Search(userID int64, query string) ([]*domain.SearchResult, error) {
+	query = strings.TrimSpace(query)
+	if query == "" {
+		return nil, fmt.Errorf("пустой запрос")
+	}
+
+	results, err := s.storage.Search(userID, query, searchLimit)
+	if err != nil {
+		return nil, fmt.Errorf("search: %w", err)
+	}
+	return results, nil
+}
+
+func (s *SearchService) FormatResults(query string, results []*domain.SearchResult) string {
+	var sb strings.Builder
+	sb.WriteString(fmt.Sprintf("<b>🔎 Поиск:</b> %s\n\n", html.EscapeString(query)))
+
+	if len(results) == 0 {
+		sb.WriteString("Ничего не найдено")
+		return sb.String()
+	}
+
+	for i, r := range results {
+		sb.WriteString(fmt.Sprintf("%d. %s <b>%s</b> <i>(%s)</i>\n", i+1, r.KindEmoji(), html.EscapeString(r.Title), r.KindName()))
+		if body := strings.TrimSpace(r.Body); body != "" {
+			sb.WriteString(fmt.Sprintf("    %s\n", html.EscapeString(truncate(body, 60))))
+		}
+	}
+	return sb.String()
+}
+
+func truncate(s string, n int) string {
+	if utf8.RuneCountInString(s) <= n {
+		return s
+	}
+	return string([]rune(s)[:n]) + "…"
+}
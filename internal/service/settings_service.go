@@ -0,0 +1,231 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/tazhate/familybot/internal/clock"
+	"github.com/tazhate/familybot/internal/domain"
+	"github.com/tazhate/familybot/internal/storage"
+)
+
+// SettingsService — личные настройки; пока пользователь ничего не менял,
+// действуют значения из конфига (TIMEZONE, MORNING_TIME, EVENING_TIME).
+type SettingsService struct {
+	storage     storage.Store
+	timezone    *time.Location
+	morningTime string
+	eveningTime string
+	clock       clock.Clock
+}
+
+func NewSettingsService(s storage.Store, tz *time.Location, morningTime, eveningTime string) *SettingsService {
+	return &SettingsService{
+		storage:     s,
+		timezone:    tz,
+		morningTime: morningTime,
+		eveningTime: eveningTime,
+		clock:       clock.Real(),
+	}
+}
+
+// SetClock replaces the system clock, e.g. to simulate days in tests
+func (s *SettingsService) SetClock(c clock.Clock) {
+	s.clock = c
+}
+
+// Get returns the user's settings or defaults from the config
+func (s *SettingsService) Get(userID int64) *domain.UserSettings {
+	st, err := s.storage.GetUserSettings(userID)
+	if err != nil {
+		log.Printf("SettingsService.Get: user %d: %v", userID, err)
+	}
+	if st == nil {
+		st = &domain.UserSettings{
+			UserID:           userID,
+			Timezone:         s.timezone.String(),
+			MorningTime:      s.morningTime,
+			EveningTime:      s.eveningTime,
+			BriefingSections: domain.DefaultBriefingSections,
+		}
+	}
+	return st
+}
+
+// Location returns the user's timezone
+func (s *SettingsService) Location(userID int64) *time.Location {
+	return s.Get(userID).Location(s.timezone)
+}
+
+// Now returns the current time in the user's timezone
+func (s *SettingsService) Now(userID int64) time.Time {
+	return s.clock.Now().In(s.Location(userID))
+}
+
+// InQuietHours reports whether it is quiet hours for the user at the moment t
+func (s *SettingsService) InQuietHours(userID int64, t time.Time) bool {
+	st := s.Get(userID)
+	return st.InQuietHours(t.In(st.Location(s.timezone)))
+}
+
+// QuietUntil returns the end of the user's quiet hours if the moment t falls into them
+func (s *SettingsService) QuietUntil(userID int64, t time.Time) (time.Time, bool) {
+	st := s.Get(userID)
+	return st.QuietUntil(t.In(st.Location(s.timezone)))
+}
+
+// SetTimezone sets the timezone: "Europe/Berlin", "UTC+3", "+5"
+func (s *SettingsService) SetTimezone(userID int64, name string) (*domain.UserSettings, error) {
+	loc, err := ParseTimezone(name)
+	if err != nil {
+		return nil, err
+	}
+	return s.update(userID, func(st *domain.UserSettings) { st.Timezone = loc.String() })
+}
+
+// SetMorningTime sets the morning briefing time; "" disables the briefing
+func (s *SettingsService) SetMorningTime(userID int64, value string) (*domain.UserSettings, error) {
+	clock, err := parseOptionalClock(value)
+	if err != nil {
+		return nil, err
+	}
+	return s.update(userID, func(st *domain.UserSettings) { st.MorningTime = clock })
+}
+
+// SetEveningTime sets the evening check-in time; "" disables the check-in
+func (s *SettingsService) SetEveningTime(userID int64, value string) (*domain.UserSettings, error) {
+	clock, err := parseOptionalClock(value)
+	if err != nil {
+		return nil, err
+	}
+	return s.update(userID, func(st *domain.UserSettings) { st.EveningTime = clock })
+}
+
+// SetQuietHours sets quiet hours from "23:00-08:00"; "" disables them
+func (s *SettingsService) SetQuietHours(userID int64, value string) (*domain.UserSettings, error) {
+	var start, end string
+	if !isOff(value) {
+		from, to, ok := strings.Cut(strings.ReplaceAll(strings.ReplaceAll(value, "–", "-"), " ", ""), "-")
+		if !ok {
+			return nil, errors.New("формат: 23:00-08:00")
+		}
+		var okFrom, okTo bool
+		start, okFrom = domain.NormalizeClock(from)
+		end, okTo = domain.NormalizeClock(to)
+		if !okFrom || !okTo || start == end {
+			return nil, errors.New("формат: 23:00-08:00")
+		}
+	}
+	return s.update(userID, func(st *domain.UserSettings) {
+		st.QuietStart = start
+		st.QuietEnd = end
+	})
+}
+
+// ToggleSection enables or disables a briefing section
+func (s *SettingsService) ToggleSection(userID int64, section domain.BriefingSection) (*domain.UserSettings, error) {
+	return s.update(userID, func(st *domain.UserSettings) { st.ToggleSection(section) })
+}
+
+func (s *SettingsService) update(userID int64, change func(st *domain.UserSettings)) (*domain.UserSettings, error) {
+	st := s.Get(userID)
+	change(st)
+	if err := s.storage.SaveUserSettings(st); err != nil {
+		return nil, err
+	}
+	return st, nil
+}
+
+// Format formats settings for display
+func (s *SettingsService) Format(st *domain.UserSettings) string {
+	var sb strings.Builder
+	sb.WriteString("⚙️ <b>Настройки</b>\n\n")
+
+	loc := st.Location(s.timezone)
+	sb.WriteString(fmt.Sprintf("🌍 Часовой пояс: <b>%s</b> (сейчас %s)\n", loc.String(), s.clock.Now().In(loc).Format("15:04")))
+	sb.WriteString(fmt.Sprintf("☀️ Утренний брифинг: <b>%s</b>\n", clockLabel(st.MorningTime)))
+	sb.WriteString(fmt.Sprintf("🌙 Вечерний чекин: <b>%s</b>\n", clockLabel(st.EveningTime)))
+	sb.WriteString(fmt.Sprintf("🔕 Тихие часы: <b>%s</b>\n", st.QuietHoursLabel()))
+
+	var names []string
+	for _, section := range st.GetSections() {
+		names = append(names, section.Name())
+	}
+	if len(names) == 0 {
+		names = append(names, "только приветствие")
+	}
+	sb.WriteString("📰 Брифинг: " + strings.Join(names, ", ") + "\n")
+
+	if st.HasQuietHours() {
+		sb.WriteString("\n<i>В тихие часы несрочные уведомления копятся и приходят после их окончания. Срочные задачи 🔴 приходят сразу.</i>")
+	}
+	return sb.String()
+}
+
+// ParseTimezone parses an IANA name or a UTC offset ("UTC+3", "+5", "GMT-4")
+func ParseTimezone(name string) (*time.Location, error) {
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return nil, errors.New("укажи часовой пояс, например Europe/Moscow или UTC+3")
+	}
+
+	offset := strings.TrimPrefix(strings.TrimPrefix(strings.ToUpper(name), "UTC"), "GMT")
+	if offset == "" {
+		return time.UTC, nil
+	}
+	if offset[0] == '+' || offset[0] == '-' {
+		hours, err := strconv.Atoi(offset[1:])
+		if err != nil || hours > 14 {
+			return nil, fmt.Errorf("неизвестный часовой пояс: %s", name)
+		}
+		if hours == 0 {
+			return time.UTC, nil
+		}
+		// В базе tz знаки Etc/GMT инвертированы: UTC+3 — это Etc/GMT-3
+		sign := "-"
+		if offset[0] == '-' {
+			sign = "+"
+		}
+		loc, err := time.LoadLocation("Etc/GMT" + sign + strconv.Itoa(hours))
+		if err != nil {
+			return nil, fmt.Errorf("неизвестный часовой пояс: %s", name)
+		}
+		return loc, nil
+	}
+
+	loc, err := time.LoadLocation(name)
+	if err != nil {
+		return nil, fmt.Errorf("неизвестный часовой пояс: %s", name)
+	}
+	return loc, nil
+}
+
+func parseOptionalClock(value string) (string, error) {
+	if isOff(value) {
+		return "", nil
+	}
+	clock, ok := domain.NormalizeClock(value)
+	if !ok {
+		return "", errors.New("формат времени: 07:30 (или «выкл»)")
+	}
+	return clock, nil
+}
+
+func isOff(value string) bool {
+	switch strings.ToLower(strings.TrimSpace(value)) {
+	case "", "-", "выкл", "нет", "off", "none":
+		return true
+	}
+	return false
+}
+
+func clockLabel(clock string) string {
+	if clock == "" {
+		return "выкл"
+	}
+	return clock
+}
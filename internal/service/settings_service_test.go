@@ -0,0 +1,93 @@
+package service_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/tazhate/familybot/internal/clock"
+	"github.com/tazhate/familybot/internal/domain"
+	"github.com/tazhate/familybot/internal/service"
+	"github.com/tazhate/familybot/internal/storage/storagetest"
+)
+
+func TestParseTimezone(t *testing.T) {
+	tests := []struct {
+		name string
+		want string // "" — ошибка
+	}{
+		{"Europe/Berlin", "Europe/Berlin"},
+		{"UTC+3", "Etc/GMT-3"},
+		{"+5", "Etc/GMT-5"},
+		{"gmt-4", "Etc/GMT+4"},
+		{"UTC", "UTC"},
+		{"UTC+0", "UTC"},
+		{"UTC+15", ""},
+		{"Марс/Олимп", ""},
+		{"  ", ""},
+	}
+	for _, tt := range tests {
+		loc, err := service.ParseTimezone(tt.name)
+		if tt.want == "" {
+			if err == nil {
+				t.Errorf("ParseTimezone(%q) = %s, want error", tt.name, loc)
+			}
+			continue
+		}
+		if err != nil || loc.String() != tt.want {
+			t.Errorf("ParseTimezone(%q) = %v, %v; want %s", tt.name, loc, err, tt.want)
+		}
+	}
+}
+
+func TestSettings(t *testing.T) {
+	store := storagetest.SQLite(t)
+	user := &domain.User{TelegramID: 100, Name: "Алекс", Role: domain.RoleOwner}
+	if err := store.CreateUser(user); err != nil {
+		t.Fatal(err)
+	}
+	settings := service.NewSettingsService(store, moscow, "09:00", "21:00")
+	// 23:30 по Москве — 22:30 в Берлине
+	settings.SetClock(clock.NewFake(time.Date(2030, time.June, 4, 23, 30, 0, 0, moscow)))
+
+	// Пока ничего не менялось — значения из конфига
+	if st := settings.Get(user.ID); st.MorningTime != "09:00" || st.EveningTime != "21:00" || st.Location(nil).String() != "Europe/Moscow" || st.HasQuietHours() {
+		t.Errorf("defaults %+v", st)
+	}
+
+	for _, value := range []string{"23:00", "23:00-23:00", "поздно-рано", "25:00-08:00"} {
+		if _, err := settings.SetQuietHours(user.ID, value); err == nil {
+			t.Errorf("quiet hours %q accepted", value)
+		}
+	}
+	if _, err := settings.SetMorningTime(user.ID, "рано"); err == nil {
+		t.Error("morning time «рано» accepted")
+	}
+	if _, err := settings.SetTimezone(user.ID, "Europe/Berlin"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := settings.SetQuietHours(user.ID, "22:00 – 7:00"); err != nil {
+		t.Fatal(err)
+	}
+	st, err := settings.SetMorningTime(user.ID, "выкл")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if st.MorningTime != "" || st.QuietStart != "22:00" || st.QuietEnd != "07:00" {
+		t.Errorf("saved %+v", st)
+	}
+
+	now := settings.Now(user.ID)
+	if now.Location().String() != "Europe/Berlin" || now.Hour() != 22 {
+		t.Errorf("now %s", now)
+	}
+	until, ok := settings.QuietUntil(user.ID, now)
+	if want := time.Date(2030, time.June, 5, 7, 0, 0, 0, now.Location()); !ok || !until.Equal(want) {
+		t.Errorf("quiet until %s, %v; want %s", until, ok, want)
+	}
+	if _, err := settings.SetQuietHours(user.ID, "выкл"); err != nil {
+		t.Fatal(err)
+	}
+	if settings.InQuietHours(user.ID, now) {
+		t.Error("quiet hours still on")
+	}
+}
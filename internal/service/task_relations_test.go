@@ -0,0 +1,252 @@
+package service_test
+
+import (
+	"slices"
+	"testing"
+
+	"github.com/tazhate/familybot/internal/domain"
+	"github.com/tazhate/familybot/internal/service"
+	"github.com/tazhate/familybot/internal/storage/storagetest"
+)
+
+// taskTree — задачи одного пользователя для проверок подзадач и блокировок
+type taskTree struct {
+	t     *testing.T
+	tasks *service.TaskService
+	user  *domain.User
+}
+
+func newTaskTree(t *testing.T) *taskTree {
+	t.Helper()
+	store := storagetest.SQLite(t)
+	user := &domain.User{TelegramID: 100, Name: "Алекс", Role: domain.RoleOwner}
+	if err := store.CreateUser(user); err != nil {
+		t.Fatal(err)
+	}
+	return &taskTree{t: t, tasks: service.NewTaskService(store), user: user}
+}
+
+func (tt *taskTree) create(title string) *domain.Task {
+	tt.t.Helper()
+	task, err := tt.tasks.Create(tt.user.ID, tt.user.TelegramID, title, domain.PriorityUrgent)
+	if err != nil {
+		tt.t.Fatal(err)
+	}
+	return task
+}
+
+func (tt *taskTree) subtask(parent *domain.Task, title string) *domain.Task {
+	tt.t.Helper()
+	task, err := tt.tasks.CreateSubtask(parent.ID, tt.user.ID, tt.user.TelegramID, title)
+	if err != nil {
+		tt.t.Fatal(err)
+	}
+	return task
+}
+
+func (tt *taskTree) done(task *domain.Task) {
+	tt.t.Helper()
+	if err := tt.tasks.MarkDone(task.ID, tt.user.ID, tt.user.TelegramID); err != nil {
+		tt.t.Fatal(err)
+	}
+}
+
+func (tt *taskTree) blockers(task *domain.Task) []int64 {
+	tt.t.Helper()
+	blockers, err := tt.tasks.Blockers(task.ID)
+	if err != nil {
+		tt.t.Fatal(err)
+	}
+	var ids []int64
+	for _, b := range blockers {
+		ids = append(ids, b.ID)
+	}
+	slices.Sort(ids)
+	return ids
+}
+
+func TestCreateSubtask(t *testing.T) {
+	tt := newTaskTree(t)
+	parent := tt.create("Ремонт")
+	child := tt.subtask(parent, "Купить краску")
+	if child.ParentID == nil || *child.ParentID != parent.ID || child.Priority != parent.Priority || child.ChatID != parent.ChatID {
+		t.Errorf("subtask %+v does not inherit from %+v", child, parent)
+	}
+
+	if _, err := tt.tasks.CreateSubtask(parent.ID, tt.user.ID, tt.user.TelegramID, "  "); err == nil {
+		t.Error("empty subtask title accepted")
+	}
+	if _, err := tt.tasks.CreateSubtask(parent.ID, 999, 999, "Чужая"); err == nil {
+		t.Error("subtask of someone else's task created")
+	}
+	tt.done(parent)
+	if _, err := tt.tasks.CreateSubtask(parent.ID, tt.user.ID, tt.user.TelegramID, "Поздно"); err == nil {
+		t.Error("subtask of a done task created")
+	}
+}
+
+func TestSetParentRejectsCycles(t *testing.T) {
+	tt := newTaskTree(t)
+	a := tt.create("Школа")
+	b := tt.subtask(a, "Форма")
+	c := tt.subtask(b, "Ботинки")
+	other := tt.create("Дача")
+
+	setParent := func(task *domain.Task, parent *domain.Task) error {
+		var parentID *int64
+		if parent != nil {
+			parentID = &parent.ID
+		}
+		return tt.tasks.SetParent(task.ID, tt.user.ID, tt.user.TelegramID, parentID)
+	}
+	if err := setParent(a, a); err == nil {
+		t.Error("task became its own parent")
+	}
+	if err := setParent(a, c); err == nil {
+		t.Error("task moved under its grandchild")
+	}
+	if err := setParent(c, other); err != nil {
+		t.Errorf("move to another tree: %v", err)
+	}
+	// c больше не потомок a, теперь можно
+	if err := setParent(a, c); err != nil {
+		t.Errorf("move under a former descendant: %v", err)
+	}
+	if err := setParent(a, nil); err != nil {
+		t.Errorf("move to top level: %v", err)
+	}
+	subtasks, err := tt.tasks.Subtasks(c.ID)
+	if err != nil || len(subtasks) != 0 {
+		t.Errorf("subtasks of #%d after moving back: %v, %v", c.ID, subtasks, err)
+	}
+}
+
+func TestBlockRejectsCycles(t *testing.T) {
+	tt := newTaskTree(t)
+	paint := tt.create("Покрасить стены")
+	buy := tt.create("Купить краску")
+	money := tt.create("Получить зарплату")
+	block := func(task, blocker *domain.Task) error {
+		return tt.tasks.Block(task.ID, blocker.ID, tt.user.ID, tt.user.TelegramID)
+	}
+
+	if err := block(paint, paint); err == nil {
+		t.Error("task blocks itself")
+	}
+	if err := block(paint, buy); err != nil {
+		t.Fatal(err)
+	}
+	if err := block(buy, money); err != nil {
+		t.Fatal(err)
+	}
+	if err := block(buy, paint); err == nil {
+		t.Error("direct cycle accepted")
+	}
+	if err := block(money, paint); err == nil {
+		t.Error("transitive cycle accepted")
+	}
+	// Повторная блокировка ничего не дублирует
+	if err := block(paint, buy); err != nil {
+		t.Error(err)
+	}
+	if got := tt.blockers(paint); !slices.Equal(got, []int64{buy.ID}) {
+		t.Errorf("blockers %v, want [%d]", got, buy.ID)
+	}
+
+	if err := tt.tasks.Unblock(buy.ID, money.ID, tt.user.ID, tt.user.TelegramID); err != nil {
+		t.Fatal(err)
+	}
+	if err := block(money, paint); err != nil {
+		t.Errorf("no cycle after unblock: %v", err)
+	}
+}
+
+func TestSetBlockers(t *testing.T) {
+	tt := newTaskTree(t)
+	task := tt.create("Переезд")
+	boxes, van, keys := tt.create("Коробки"), tt.create("Машина"), tt.create("Ключи")
+	set := func(blockers ...*domain.Task) error {
+		var ids []int64
+		for _, b := range blockers {
+			ids = append(ids, b.ID)
+		}
+		return tt.tasks.SetBlockers(task.ID, tt.user.ID, tt.user.TelegramID, ids)
+	}
+
+	if err := set(boxes, van); err != nil {
+		t.Fatal(err)
+	}
+	if err := set(van, keys); err != nil {
+		t.Fatal(err)
+	}
+	if got := tt.blockers(task); !slices.Equal(got, []int64{van.ID, keys.ID}) {
+		t.Errorf("blockers %v, want [%d %d]", got, van.ID, keys.ID)
+	}
+
+	if err := tt.tasks.Block(keys.ID, task.ID, tt.user.ID, tt.user.TelegramID); err == nil {
+		t.Error("cycle through SetBlockers accepted")
+	}
+	if err := set(); err != nil {
+		t.Fatal(err)
+	}
+	if got := tt.blockers(task); len(got) != 0 {
+		t.Errorf("blockers %v after clearing", got)
+	}
+}
+
+func TestBlockedTasksHiddenFromToday(t *testing.T) {
+	tt := newTaskTree(t)
+	paint := tt.create("Покрасить стены")
+	buy := tt.create("Купить краску")
+	if err := tt.tasks.Block(paint.ID, buy.ID, tt.user.ID, tt.user.TelegramID); err != nil {
+		t.Fatal(err)
+	}
+	today := func() []string {
+		tasks, err := tt.tasks.ListForToday(tt.user.ID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var titles []string
+		for _, task := range tasks {
+			titles = append(titles, task.Title)
+		}
+		return titles
+	}
+
+	if got := today(); !slices.Equal(got, []string{"Купить краску"}) {
+		t.Errorf("today %q while blocked", got)
+	}
+	tt.done(buy)
+	if got := today(); !slices.Equal(got, []string{"Покрасить стены"}) {
+		t.Errorf("today %q after the blocker is done", got)
+	}
+}
+
+func TestCompletableParent(t *testing.T) {
+	tt := newTaskTree(t)
+	parent := tt.create("Подготовка к школе")
+	form, books := tt.subtask(parent, "Форма"), tt.subtask(parent, "Учебники")
+	completable := func(task *domain.Task) *domain.Task {
+		p, err := tt.tasks.CompletableParent(task.ID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return p
+	}
+
+	if p := completable(parent); p != nil {
+		t.Errorf("top-level task has completable parent %+v", p)
+	}
+	tt.done(form)
+	if p := completable(form); p != nil {
+		t.Errorf("parent offered with «%s» still open", books.Title)
+	}
+	tt.done(books)
+	if p := completable(books); p == nil || p.ID != parent.ID {
+		t.Errorf("completable parent %+v, want #%d", p, parent.ID)
+	}
+	tt.done(parent)
+	if p := completable(books); p != nil {
+		t.Errorf("done parent offered again: %+v", p)
+	}
+}
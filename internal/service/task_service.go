@@ -6,16 +6,24 @@ import (
 	"strings"
 	"time"
 
+	"github.com/tazhate/familybot/internal/clock"
 	"github.com/tazhate/familybot/internal/domain"
+	"github.com/tazhate/familybot/internal/nlp/dates"
 	"github.com/tazhate/familybot/internal/storage"
 )
 
 type TaskService struct {
-	storage *storage.Storage
+	storage storage.Store
+	clock   clock.Clock
 }
 
-func NewTaskService(s *storage.Storage) *TaskService {
-	return &TaskService{storage: s}
+func NewTaskService(s storage.Store) *TaskService {
+	return &TaskService{storage: s, clock: clock.Real()}
+}
+
+// SetClock replaces the system clock, e.g. to simulate days in tests
+func (s *TaskService) SetClock(c clock.Clock) {
+	s.clock = c
 }
 
 func (s *TaskService) Create(userID int64, chatID int64, title string, priority domain.Priority) (*domain.Task, error) {
@@ -65,6 +73,72 @@ func (s *TaskService) CreateRepeatingWithWeekNum(userID int64, chatID int64, tit
 	return task, nil
 }
 
+// CreateWithRule creates a task repeating by an RRULE or a Russian phrase ("каждые 2 недели в пт").
+// The first due date is the first occurrence starting today.
+func (s *TaskService) CreateWithRule(userID int64, chatID int64, title string, priority domain.Priority, personID *int64, ruleText string, repeatTime string) (*domain.Task, error) {
+	rule, err := domain.ParseRecurrence(ruleText)
+	if err != nil {
+		return nil, err
+	}
+
+	title = strings.TrimSpace(title)
+	if title == "" {
+		return nil, fmt.Errorf("task title cannot be empty")
+	}
+	if priority == "" {
+		priority = domain.PrioritySomeday
+	}
+
+	// Серия начинается сегодня во время напоминания
+	start := domain.RuleStart(s.clock.Now(), repeatTime)
+	dueDate, err := domain.RuleOccurrenceAfter(rule, start, start)
+	if err != nil {
+		return nil, err
+	}
+	if dueDate == nil {
+		return nil, fmt.Errorf("правило %s не даёт ни одной даты", rule)
+	}
+
+	task := &domain.Task{
+		UserID:     userID,
+		ChatID:     chatID,
+		Title:      title,
+		Priority:   priority,
+		PersonID:   personID,
+		DueDate:    dueDate,
+		RepeatType: domain.RepeatRule,
+		RepeatTime: repeatTime,
+		RRule:      rule,
+		RRuleStart: &start,
+	}
+	if err := s.storage.CreateTask(task); err != nil {
+		return nil, fmt.Errorf("create task: %w", err)
+	}
+	return task, nil
+}
+
+// SetRecurrence sets an RRULE (raw or Russian phrase) on a task; empty text stops repeating
+func (s *TaskService) SetRecurrence(taskID int64, userID int64, chatID int64, ruleText string) error {
+	task, err := s.getAccessible(taskID, userID, chatID)
+	if err != nil {
+		return err
+	}
+	if strings.TrimSpace(ruleText) == "" {
+		return s.storage.UpdateTaskRecurrence(taskID, domain.RepeatNone, "", nil)
+	}
+	rule, err := domain.ParseRecurrence(ruleText)
+	if err != nil {
+		return err
+	}
+	// Серия начинается с текущего дедлайна, а без него — сегодня
+	day := s.clock.Now()
+	if task.DueDate != nil {
+		day = task.DueDate.In(day.Location())
+	}
+	start := domain.RuleStart(day, task.RepeatTime)
+	return s.storage.UpdateTaskRecurrence(taskID, domain.RepeatRule, rule, &start)
+}
+
 // ParseMentions extracts @name mentions from text and returns clean text + person names
 func (s *TaskService) ParseMentions(text string) (cleanText string, mentions []string) {
 	re := regexp.MustCompile(`@(\S+)`)
@@ -130,156 +204,14 @@ func (s *TaskService) ResolveMention(ownerUserID int64, mention string) (*Mentio
 	return nil, fmt.Errorf("не найдено: @%s", mention)
 }
 
-// ParseDate extracts date from Russian text like "завтра", "в понедельник", "20 января", "04.02"
-// Returns clean text and parsed date (or nil if no date found)
+// ParseDate extracts date and time from text like "завтра в 15:30", "в пятницу", "20 января", "04.02".
+// Returns clean text and parsed date (or nil if no date found). Parsing lives in internal/nlp/dates.
 func (s *TaskService) ParseDate(text string) (cleanText string, dueDate *time.Time) {
-	now := time.Now()
-	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
-
-	// Date patterns (order matters - more specific first)
-	patterns := []struct {
-		pattern string
-		days    int
-	}{
-		{`\bпослезавтра\b`, 2},
-		{`\bзавтра\b`, 1},
-		{`\bсегодня\b`, 0},
-		{`\bчерез\s+неделю\b`, 7},
-		{`\bчерез\s+2\s+недели?\b`, 14},
-		{`\bчерез\s+месяц\b`, 30},
-	}
-
-	// Weekday patterns
-	weekdays := map[string]time.Weekday{
-		"понедельник": time.Monday,
-		"вторник":     time.Tuesday,
-		"среду":       time.Wednesday,
-		"среда":       time.Wednesday,
-		"четверг":     time.Thursday,
-		"пятницу":     time.Friday,
-		"пятница":     time.Friday,
-		"субботу":     time.Saturday,
-		"суббота":     time.Saturday,
-		"воскресенье": time.Sunday,
-	}
-
-	// Russian month names
-	months := map[string]time.Month{
-		"января":   time.January,
-		"февраля":  time.February,
-		"марта":    time.March,
-		"апреля":   time.April,
-		"мая":      time.May,
-		"июня":     time.June,
-		"июля":     time.July,
-		"августа":  time.August,
-		"сентября": time.September,
-		"октября":  time.October,
-		"ноября":   time.November,
-		"декабря":  time.December,
-	}
-
-	cleanText = text
-
-	// Try simple patterns first
-	for _, p := range patterns {
-		re := regexp.MustCompile(`(?i)` + p.pattern)
-		if re.MatchString(cleanText) {
-			d := today.AddDate(0, 0, p.days)
-			dueDate = &d
-			cleanText = re.ReplaceAllString(cleanText, "")
-			break
-		}
-	}
-
-	// Try DD.MM.YYYY or DD.MM format
-	if dueDate == nil {
-		// DD.MM.YYYY
-		re := regexp.MustCompile(`\b(\d{1,2})\.(\d{1,2})\.(\d{4})\b`)
-		if matches := re.FindStringSubmatch(cleanText); matches != nil {
-			day := atoi(matches[1])
-			month := atoi(matches[2])
-			year := atoi(matches[3])
-			if day >= 1 && day <= 31 && month >= 1 && month <= 12 {
-				d := time.Date(int(year), time.Month(month), int(day), 0, 0, 0, 0, now.Location())
-				dueDate = &d
-				cleanText = re.ReplaceAllString(cleanText, "")
-			}
-		}
-	}
-
-	if dueDate == nil {
-		// DD.MM (without year - use current or next year)
-		re := regexp.MustCompile(`\b(\d{1,2})\.(\d{1,2})\b`)
-		if matches := re.FindStringSubmatch(cleanText); matches != nil {
-			day := atoi(matches[1])
-			month := atoi(matches[2])
-			if day >= 1 && day <= 31 && month >= 1 && month <= 12 {
-				year := now.Year()
-				d := time.Date(year, time.Month(month), int(day), 0, 0, 0, 0, now.Location())
-				// If date is in the past, use next year
-				if d.Before(today) {
-					d = d.AddDate(1, 0, 0)
-				}
-				dueDate = &d
-				cleanText = re.ReplaceAllString(cleanText, "")
-			}
-		}
-	}
-
-	// Try "20 января" format
-	if dueDate == nil {
-		for monthName, monthNum := range months {
-			re := regexp.MustCompile(`(?i)\b(\d{1,2})\s+` + monthName + `\b`)
-			if matches := re.FindStringSubmatch(cleanText); matches != nil {
-				day := atoi(matches[1])
-				if day >= 1 && day <= 31 {
-					year := now.Year()
-					d := time.Date(year, monthNum, int(day), 0, 0, 0, 0, now.Location())
-					// If date is in the past, use next year
-					if d.Before(today) {
-						d = d.AddDate(1, 0, 0)
-					}
-					dueDate = &d
-					cleanText = re.ReplaceAllString(cleanText, "")
-					break
-				}
-			}
-		}
-	}
-
-	// Try weekday patterns ("в понедельник", "в пятницу")
-	if dueDate == nil {
-		for name, wd := range weekdays {
-			re := regexp.MustCompile(`(?i)\bв\s+` + name + `\b`)
-			if re.MatchString(cleanText) {
-				// Find the next occurrence of this weekday
-				daysUntil := int(wd) - int(now.Weekday())
-				if daysUntil <= 0 {
-					daysUntil += 7
-				}
-				d := today.AddDate(0, 0, daysUntil)
-				dueDate = &d
-				cleanText = re.ReplaceAllString(cleanText, "")
-				break
-			}
-		}
+	cleanText, r := dates.Extract(text, s.clock.Now())
+	if r == nil {
+		return cleanText, nil
 	}
-
-	// Clean up multiple spaces
-	cleanText = regexp.MustCompile(`\s+`).ReplaceAllString(strings.TrimSpace(cleanText), " ")
-
-	return cleanText, dueDate
-}
-
-func atoi(s string) int64 {
-	var n int64
-	for _, c := range s {
-		if c >= '0' && c <= '9' {
-			n = n*10 + int64(c-'0')
-		}
-	}
-	return n
+	return cleanText, &r.Time
 }
 
 // ListByPerson returns tasks for a specific person
@@ -312,12 +244,12 @@ func (s *TaskService) ListByChat(chatID int64, includeDone bool) ([]*domain.Task
 }
 
 func (s *TaskService) ListForToday(userID int64) ([]*domain.Task, error) {
-	return s.storage.ListTasksForToday(userID)
+	return s.storage.ListTasksForToday(userID, s.clock.Now())
 }
 
 // ListForTodayByChat returns urgent tasks for a specific chat
 func (s *TaskService) ListForTodayByChat(chatID int64) ([]*domain.Task, error) {
-	return s.storage.ListTasksForTodayByChat(chatID)
+	return s.storage.ListTasksForTodayByChat(chatID, s.clock.Now())
 }
 
 func (s *TaskService) MarkDone(taskID int64, userID int64, chatID int64) error {
@@ -343,22 +275,26 @@ func (s *TaskService) MarkDone(taskID int64, userID int64, chatID int64) error {
 	}
 
 	// Если задача повторяющаяся — создаём новую на следующий раз
+	// (для RRULE с COUNT/UNTIL следующей даты может не быть)
 	if task.IsRepeating() {
-		nextDue := task.NextOccurrence(time.Now())
-		_, err := s.CreateRepeatingWithWeekNum(
-			task.UserID,
-			task.ChatID,
-			task.Title,
-			task.Priority,
-			task.PersonID,
-			nextDue,
-			task.RepeatType,
-			task.RepeatTime,
-			task.RepeatWeekNum,
-		)
-		if err != nil {
-			// Логируем но не возвращаем ошибку — основная задача выполнена
-			fmt.Printf("Error creating next occurrence: %v\n", err)
+		if nextDue := task.NextOccurrence(s.clock.Now()); nextDue != nil {
+			next := &domain.Task{
+				UserID:        task.UserID,
+				ChatID:        task.ChatID,
+				Title:         task.Title,
+				Priority:      task.Priority,
+				PersonID:      task.PersonID,
+				DueDate:       nextDue,
+				RepeatType:    task.RepeatType,
+				RepeatTime:    task.RepeatTime,
+				RepeatWeekNum: task.RepeatWeekNum,
+				RRule:         task.RRule,
+				RRuleStart:    task.RRuleStart,
+			}
+			if err := s.storage.CreateTask(next); err != nil {
+				// Логируем но не возвращаем ошибку — основная задача выполнена
+				fmt.Printf("Error creating next occurrence: %v\n", err)
+			}
 		}
 	}
 
@@ -382,6 +318,202 @@ func (s *TaskService) Delete(taskID int64, userID int64, chatID int64) error {
 	return s.storage.DeleteTask(taskID)
 }
 
+// CreateSubtask creates a child task. Chat, priority, person and sharing are inherited from the parent
+func (s *TaskService) CreateSubtask(parentID int64, userID int64, chatID int64, title string) (*domain.Task, error) {
+	parent, err := s.getAccessible(parentID, userID, chatID)
+	if err != nil {
+		return nil, err
+	}
+	if parent.IsDone() {
+		return nil, fmt.Errorf("parent task is already done")
+	}
+
+	title = strings.TrimSpace(title)
+	if title == "" {
+		return nil, fmt.Errorf("task title cannot be empty")
+	}
+
+	task := &domain.Task{
+		UserID:   userID,
+		ChatID:   parent.ChatID,
+		Title:    title,
+		Priority: parent.Priority,
+		PersonID: parent.PersonID,
+		IsShared: parent.IsShared,
+		ParentID: &parent.ID,
+	}
+	if err := s.storage.CreateTask(task); err != nil {
+		return nil, fmt.Errorf("create task: %w", err)
+	}
+	return task, nil
+}
+
+// SetParent moves a task under another one (nil — back to top level)
+func (s *TaskService) SetParent(taskID int64, userID int64, chatID int64, parentID *int64) error {
+	if _, err := s.getAccessible(taskID, userID, chatID); err != nil {
+		return err
+	}
+	if parentID != nil {
+		if *parentID == taskID {
+			return fmt.Errorf("task cannot be its own parent")
+		}
+		if _, err := s.getAccessible(*parentID, userID, chatID); err != nil {
+			return fmt.Errorf("parent #%d: %w", *parentID, err)
+		}
+		// Новый родитель не должен быть потомком задачи
+		for id := parentID; id != nil; {
+			if *id == taskID {
+				return fmt.Errorf("task #%d is a subtask of #%d", *parentID, taskID)
+			}
+			t, err := s.storage.GetTask(*id)
+			if err != nil {
+				return fmt.Errorf("get task: %w", err)
+			}
+			if t == nil {
+				break
+			}
+			id = t.ParentID
+		}
+	}
+	return s.storage.UpdateTaskParent(taskID, parentID)
+}
+
+// Subtasks returns direct children of a task
+func (s *TaskService) Subtasks(taskID int64) ([]*domain.Task, error) {
+	return s.storage.ListSubtasks(taskID)
+}
+
+// Blockers returns tasks that must be done before this one
+func (s *TaskService) Blockers(taskID int64) ([]*domain.Task, error) {
+	return s.storage.ListTaskBlockers(taskID)
+}
+
+// Block marks taskID as blocked by blockerID
+func (s *TaskService) Block(taskID int64, blockerID int64, userID int64, chatID int64) error {
+	if taskID == blockerID {
+		return fmt.Errorf("task cannot block itself")
+	}
+	if _, err := s.getAccessible(taskID, userID, chatID); err != nil {
+		return err
+	}
+	if _, err := s.getAccessible(blockerID, userID, chatID); err != nil {
+		return fmt.Errorf("blocker #%d: %w", blockerID, err)
+	}
+
+	// Защита от циклов: блокирующая задача не должна сама ждать taskID
+	blocked, err := s.dependsOn(blockerID, taskID, map[int64]bool{})
+	if err != nil {
+		return err
+	}
+	if blocked {
+		return fmt.Errorf("task #%d already waits for #%d", blockerID, taskID)
+	}
+
+	return s.storage.AddTaskBlocker(taskID, blockerID)
+}
+
+// Unblock removes the "blocked by" link
+func (s *TaskService) Unblock(taskID int64, blockerID int64, userID int64, chatID int64) error {
+	if _, err := s.getAccessible(taskID, userID, chatID); err != nil {
+		return err
+	}
+	return s.storage.RemoveTaskBlocker(taskID, blockerID)
+}
+
+// SetBlockers replaces the whole set of blockers
+func (s *TaskService) SetBlockers(taskID int64, userID int64, chatID int64, blockerIDs []int64) error {
+	current, err := s.storage.ListTaskBlockers(taskID)
+	if err != nil {
+		return fmt.Errorf("list blockers: %w", err)
+	}
+
+	want := make(map[int64]bool, len(blockerIDs))
+	for _, id := range blockerIDs {
+		want[id] = true
+	}
+	for _, b := range current {
+		if want[b.ID] {
+			delete(want, b.ID)
+			continue
+		}
+		if err := s.Unblock(taskID, b.ID, userID, chatID); err != nil {
+			return err
+		}
+	}
+	for _, id := range blockerIDs {
+		if !want[id] {
+			continue
+		}
+		if err := s.Block(taskID, id, userID, chatID); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// CompletableParent returns the parent of taskID if all its subtasks are done
+// and the parent itself is still open. Used to offer closing the parent.
+func (s *TaskService) CompletableParent(taskID int64) (*domain.Task, error) {
+	task, err := s.storage.GetTask(taskID)
+	if err != nil || task == nil || task.ParentID == nil {
+		return nil, err
+	}
+
+	parent, err := s.storage.GetTask(*task.ParentID)
+	if err != nil || parent == nil || parent.IsDone() {
+		return nil, err
+	}
+
+	children, err := s.storage.ListSubtasks(parent.ID)
+	if err != nil {
+		return nil, err
+	}
+	for _, c := range children {
+		if !c.IsDone() {
+			return nil, nil
+		}
+	}
+	return parent, nil
+}
+
+// dependsOn reports whether taskID (transitively) waits for targetID
+func (s *TaskService) dependsOn(taskID, targetID int64, seen map[int64]bool) (bool, error) {
+	if seen[taskID] {
+		return false, nil
+	}
+	seen[taskID] = true
+
+	blockers, err := s.storage.ListTaskBlockers(taskID)
+	if err != nil {
+		return false, fmt.Errorf("list blockers: %w", err)
+	}
+	for _, b := range blockers {
+		if b.ID == targetID {
+			return true, nil
+		}
+		found, err := s.dependsOn(b.ID, targetID, seen)
+		if err != nil || found {
+			return found, err
+		}
+	}
+	return false, nil
+}
+
+// getAccessible loads a task the user may change (same chat, creator or assignee)
+func (s *TaskService) getAccessible(taskID int64, userID int64, chatID int64) (*domain.Task, error) {
+	task, err := s.storage.GetTask(taskID)
+	if err != nil {
+		return nil, fmt.Errorf("get task: %w", err)
+	}
+	if task == nil {
+		return nil, fmt.Errorf("task not found")
+	}
+	if task.ChatID != chatID && task.UserID != userID && (task.AssignedTo == nil || *task.AssignedTo != userID) {
+		return nil, fmt.Errorf("access denied")
+	}
+	return task, nil
+}
+
 // Assign assigns a task to a user
 func (s *TaskService) Assign(taskID int64, assignToUserID int64, requestingUserID int64, chatID int64) error {
 	task, err := s.storage.GetTask(taskID)
@@ -418,9 +550,9 @@ func (s *TaskService) Unassign(taskID int64, requestingUserID int64, chatID int6
 	return s.storage.UpdateTaskAssignment(taskID, nil)
 }
 
-// ListShared returns all shared tasks
-func (s *TaskService) ListShared(includeDone bool) ([]*domain.Task, error) {
-	return s.storage.ListSharedTasks(includeDone)
+// ListShared returns shared tasks of the user's household
+func (s *TaskService) ListShared(userID int64, includeDone bool) ([]*domain.Task, error) {
+	return s.storage.ListSharedTasks(userID, includeDone)
 }
 
 // SetShared marks a task as shared or not
@@ -474,18 +606,18 @@ func (s *TaskService) Snooze(taskID int64, userID int64, chatID int64, duration
 		return fmt.Errorf("access denied")
 	}
 
-	until := time.Now().Add(duration)
+	until := s.clock.Now().Add(duration)
 	return s.storage.SnoozeTask(taskID, until)
 }
 
 // ListUrgentForReminder возвращает urgent задачи, о которых нужно напомнить
 func (s *TaskService) ListUrgentForReminder() ([]*domain.Task, error) {
-	return s.storage.ListUrgentTasksForReminder()
+	return s.storage.ListUrgentTasksForReminder(s.clock.Now())
 }
 
 // MarkReminded отмечает, что о задаче напомнили
 func (s *TaskService) MarkReminded(taskID int64) error {
-	return s.storage.UpdateTaskReminder(taskID)
+	return s.storage.UpdateTaskReminder(taskID, s.clock.Now())
 }
 
 // Get returns a task by ID
@@ -493,6 +625,23 @@ func (s *TaskService) Get(taskID int64) (*domain.Task, error) {
 	return s.storage.GetTask(taskID)
 }
 
+// GetVisible returns a task the user may look at: besides getAccessible,
+// a shared task of the same household. Nil if there is no such task.
+func (s *TaskService) GetVisible(taskID int64, userID int64, chatID int64) (*domain.Task, error) {
+	task, err := s.storage.GetTask(taskID)
+	if err != nil || task == nil {
+		return nil, err
+	}
+	if task.ChatID == chatID || (task.AssignedTo != nil && *task.AssignedTo == userID) {
+		return task, nil
+	}
+	ok, err := visibleTo(s.storage, task.UserID, userID, task.IsShared)
+	if err != nil || !ok {
+		return nil, err
+	}
+	return task, nil
+}
+
 // UpdateTitle updates task title
 func (s *TaskService) UpdateTitle(taskID int64, userID int64, chatID int64, title string) error {
 	task, err := s.storage.GetTask(taskID)
@@ -551,9 +700,27 @@ func (s *TaskService) FormatTaskListWithPersons(tasks []*domain.Task, personName
 		return "Нет задач"
 	}
 
+	// Build the tree: subtasks are rendered under their parent if it's in the list
+	inList := make(map[int64]bool, len(tasks))
+	for _, t := range tasks {
+		inList[t.ID] = true
+	}
+	children := make(map[int64][]*domain.Task)
+	var roots []*domain.Task
+	for _, t := range tasks {
+		if t.ParentID != nil && inList[*t.ParentID] {
+			children[*t.ParentID] = append(children[*t.ParentID], t)
+		} else {
+			roots = append(roots, t)
+		}
+	}
+
+	// Ошибку игнорируем: список важнее пометок ⛔
+	blocked, _ := s.storage.ListBlockedTaskIDs()
+
 	// Split into recurring and one-time
 	var recurring, oneTime []*domain.Task
-	for _, t := range tasks {
+	for _, t := range roots {
 		if t.IsRepeating() {
 			recurring = append(recurring, t)
 		} else {
@@ -562,22 +729,35 @@ func (s *TaskService) FormatTaskListWithPersons(tasks []*domain.Task, personName
 	}
 
 	var sb strings.Builder
+	writeTree := func(list []*domain.Task) {
+		var walk func(t *domain.Task, depth int)
+		walk = func(t *domain.Task, depth int) {
+			if depth > 0 {
+				sb.WriteString(strings.Repeat("    ", depth) + "↳ ")
+			}
+			line := s.formatTaskLine(t, personNames)
+			if blocked[t.ID] {
+				line = "⛔ " + line
+			}
+			sb.WriteString(line)
+			for _, c := range children[t.ID] {
+				walk(c, depth+1)
+			}
+		}
+		for _, t := range list {
+			walk(t, 0)
+		}
+	}
 
 	// If there are both types, show sections
 	if len(recurring) > 0 && len(oneTime) > 0 {
 		sb.WriteString("<b>📌 Разовые:</b>\n")
-		for _, t := range oneTime {
-			sb.WriteString(s.formatTaskLine(t, personNames))
-		}
+		writeTree(oneTime)
 		sb.WriteString("\n<b>🔁 Регулярные:</b>\n")
-		for _, t := range recurring {
-			sb.WriteString(s.formatTaskLine(t, personNames))
-		}
+		writeTree(recurring)
 	} else {
 		// Only one type — show flat list
-		for _, t := range tasks {
-			sb.WriteString(s.formatTaskLine(t, personNames))
-		}
+		writeTree(roots)
 	}
 
 	return sb.String()
@@ -0,0 +1,52 @@
+package service_test
+
+import (
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/tazhate/familybot/internal/clock"
+	"github.com/tazhate/familybot/internal/domain"
+	"github.com/tazhate/familybot/internal/service"
+	"github.com/tazhate/familybot/internal/storage/storagetest"
+)
+
+// TestRuleSeriesEndsAfterCount completes a COUNT=3 series: the third occurrence
+// must not create a fourth, even though every occurrence is a new task
+func TestRuleSeriesEndsAfterCount(t *testing.T) {
+	store := storagetest.SQLite(t)
+	user := &domain.User{TelegramID: 100, Name: "Алекс", Role: domain.RoleOwner}
+	if err := store.CreateUser(user); err != nil {
+		t.Fatal(err)
+	}
+	// Понедельник
+	clk := clock.NewFake(time.Date(2030, time.January, 7, 8, 0, 0, 0, moscow))
+	tasks := service.NewTaskService(store)
+	tasks.SetClock(clk)
+
+	task, err := tasks.CreateWithRule(user.ID, user.TelegramID, "Английский", domain.PriorityWeek, nil, "FREQ=WEEKLY;BYDAY=MO;COUNT=3", "09:00")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var dates []string
+	for range 5 {
+		dates = append(dates, task.DueDate.In(moscow).Format("2006-01-02 15:04"))
+		clk.Set(task.DueDate.In(moscow))
+		if err := tasks.MarkDone(task.ID, user.ID, user.TelegramID); err != nil {
+			t.Fatal(err)
+		}
+		open, err := store.ListTasksByUser(user.ID, false, false)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(open) == 0 {
+			break
+		}
+		task = open[0]
+	}
+
+	want := []string{"2030-01-07 09:00", "2030-01-14 09:00", "2030-01-21 09:00"}
+	if !slices.Equal(dates, want) {
+		t.Errorf("occurrences %v, want %v", dates, want)
+	}
+}
@@ -7,28 +7,36 @@ import (
 	"time"
 
 	"github.com/tazhate/familybot/internal/clients/todoist"
+	"github.com/tazhate/familybot/internal/clock"
 	"github.com/tazhate/familybot/internal/domain"
 	"github.com/tazhate/familybot/internal/storage"
 )
 
 // TodoistService handles Todoist integration
 type TodoistService struct {
-	storage       *storage.Storage
+	storage       storage.Store
 	client        *todoist.Client
 	ownerUserID   int64
 	partnerUserID int64
+	clock         clock.Clock
 }
 
 // NewTodoistService creates a new Todoist service
-func NewTodoistService(s *storage.Storage, client *todoist.Client, ownerUserID int64, partnerUserID int64) *TodoistService {
+func NewTodoistService(s storage.Store, client *todoist.Client, ownerUserID int64, partnerUserID int64) *TodoistService {
 	return &TodoistService{
 		storage:       s,
 		client:        client,
 		ownerUserID:   ownerUserID,
 		partnerUserID: partnerUserID,
+		clock:         clock.Real(),
 	}
 }
 
+// SetClock replaces the system clock, e.g. to simulate days in tests
+func (s *TodoistService) SetClock(c clock.Clock) {
+	s.clock = c
+}
+
 // IsConfigured returns true if Todoist client is configured
 func (s *TodoistService) IsConfigured() bool {
 	return s.client != nil && s.client.IsConfigured()
@@ -168,7 +176,7 @@ func (s *TodoistService) syncUserTasks(userID int64, sectionID string, result *T
 		if _, exists := todoistByID[todoistID]; !exists {
 			// Task was completed or deleted in Todoist
 			if local.DoneAt == nil {
-				now := time.Now()
+				now := s.clock.Now()
 				local.DoneAt = &now
 				if err := s.storage.UpdateTask(local); err != nil {
 					result.Errors = append(result.Errors, fmt.Sprintf("mark done %d: %v", local.ID, err))
@@ -248,7 +256,7 @@ func (s *TodoistService) todoistToLocalForUser(tt *todoist.Task, userID int64) *
 		Priority:  s.priorityFromTodoist(tt.Priority),
 		IsShared:  false, // Don't auto-share Todoist tasks to avoid duplication
 		TodoistID: tt.ID,
-		CreatedAt: time.Now(),
+		CreatedAt: s.clock.Now(),
 	}
 
 	if tt.Description != "" {
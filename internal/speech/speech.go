@@ -0,0 +1,9 @@
+// Package speech — распознавание речи для голосовых сообщений.
+package speech
+
+import "context"
+
+// Transcriber turns an audio file into text
+type Transcriber interface {
+	Transcribe(ctx context.Context, audioPath string) (string, error)
+}
@@ -0,0 +1,54 @@
+// Package speechtest — распознаватель речи для тестов: возвращает заданный текст.
+package speechtest
+
+import (
+	"context"
+	"sync"
+
+	"github.com/tazhate/familybot/internal/speech"
+)
+
+var _ speech.Transcriber = (*Transcriber)(nil)
+
+// Transcriber returns a fixed transcript and records the files it was given
+type Transcriber struct {
+	mu    sync.Mutex
+	text  string
+	err   error
+	files []string
+}
+
+func NewTranscriber(text string) *Transcriber {
+	return &Transcriber{text: text}
+}
+
+func (t *Transcriber) Transcribe(_ context.Context, audioPath string) (string, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.files = append(t.files, audioPath)
+	if t.err != nil {
+		return "", t.err
+	}
+	return t.text, nil
+}
+
+// SetText changes the transcript returned from now on
+func (t *Transcriber) SetText(text string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.text = text
+}
+
+// SetError makes every call fail with err (nil restores the transcript)
+func (t *Transcriber) SetError(err error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.err = err
+}
+
+// Files returns the audio files passed to Transcribe, in order
+func (t *Transcriber) Files() []string {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return append([]string(nil), t.files...)
+}
@@ -0,0 +1,82 @@
+package speech
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// WhisperCPP transcribes audio with a local whisper.cpp-compatible binary (whisper-cli).
+// Telegram voice notes are ogg/opus, so the file is converted to 16 kHz mono wav with ffmpeg first.
+type WhisperCPP struct {
+	binary   string
+	model    string
+	language string
+	ffmpeg   string
+}
+
+func NewWhisperCPP(binary, model, language, ffmpeg string) *WhisperCPP {
+	return &WhisperCPP{
+		binary:   binary,
+		model:    model,
+		language: language,
+		ffmpeg:   ffmpeg,
+	}
+}
+
+func (w *WhisperCPP) Transcribe(ctx context.Context, audioPath string) (string, error) {
+	dir, err := os.MkdirTemp("", "familybot-voice-")
+	if err != nil {
+		return "", fmt.Errorf("temp dir: %w", err)
+	}
+	defer os.RemoveAll(dir)
+
+	wav := filepath.Join(dir, "voice.wav")
+	if _, err := run(ctx, w.ffmpeg, "-nostdin", "-loglevel", "error", "-i", audioPath, "-ar", "16000", "-ac", "1", "-c:a", "pcm_s16le", wav); err != nil {
+		return "", fmt.Errorf("ffmpeg: %w", err)
+	}
+
+	out, err := run(ctx, w.binary, "-m", w.model, "-f", wav, "-l", w.language, "--no-timestamps", "--no-prints")
+	if err != nil {
+		return "", fmt.Errorf("whisper: %w", err)
+	}
+	return cleanTranscript(out), nil
+}
+
+func run(ctx context.Context, name string, args ...string) (string, error) {
+	var stdout, stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, name, args...)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return "", fmt.Errorf("%w: %s", err, lastLine(msg))
+		}
+		return "", err
+	}
+	return stdout.String(), nil
+}
+
+// cleanTranscript joins the output lines and drops whisper's markers like [BLANK_AUDIO]
+func cleanTranscript(out string) string {
+	var parts []string
+	for _, line := range strings.Split(out, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || (strings.HasPrefix(line, "[") && strings.HasSuffix(line, "]")) {
+			continue
+		}
+		parts = append(parts, line)
+	}
+	return strings.Join(parts, " ")
+}
+
+func lastLine(s string) string {
+	if i := strings.LastIndex(s, "\n"); i >= 0 {
+		return s[i+1:]
+	}
+	return s
+}
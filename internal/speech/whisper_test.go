@@ -0,0 +1,23 @@
+package speech
+
+import "testing"
+
+func TestCleanTranscript(t *testing.T) {
+	tests := []struct {
+		out  string
+		want string
+	}{
+		{"", ""},
+		{"[BLANK_AUDIO]\n", ""},
+		{" Купить молоко \n", "Купить молоко"},
+		{"Купить молоко\n и хлеб\n\n", "Купить молоко и хлеб"},
+		{"[музыка]\nПозвонить маме\n[BLANK_AUDIO]", "Позвонить маме"},
+		// Квадратные скобки внутри фразы — это слова, а не метка
+		{"Купить [что-то] к чаю", "Купить [что-то] к чаю"},
+	}
+	for _, tt := range tests {
+		if got := cleanTranscript(tt.out); got != tt.want {
+			t.Errorf("cleanTranscript(%q) = %q, want %q", tt.out, got, tt.want)
+		}
+	}
+}
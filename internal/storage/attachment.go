@@ -0,0 +1,83 @@
+package storage
+
+import (
+	"database/sql"
+	"time"
+
+	"github.com/tazhate/familybot/internal/domain"
+)
+
+// === Attachments ===
+
+const attachmentColumns = `id, user_id, owner_type, owner_id, kind, file_id, file_unique_id, file_name, mime_type, file_size, caption, local_path, created_at`
+
+func (s *Storage) CreateAttachment(a *domain.Attachment) error {
+	if a.CreatedAt.IsZero() {
+		a.CreatedAt = time.Now()
+	}
+	id, err := s.insert(
+		`INSERT INTO attachments (user_id, owner_type, owner_id, kind, file_id, file_unique_id, file_name, mime_type, file_size, caption, local_path, created_at)
+		 VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		a.UserID, a.OwnerType, a.OwnerID, a.Kind, a.FileID, a.FileUniqueID, a.FileName, a.MimeType, a.FileSize, a.Caption, a.LocalPath, a.CreatedAt.UTC(),
+	)
+	if err != nil {
+		return err
+	}
+	a.ID = id
+	return nil
+}
+
+func (s *Storage) GetAttachment(id int64) (*domain.Attachment, error) {
+	a, err := scanAttachment(s.queryRow(`SELECT `+attachmentColumns+` FROM attachments WHERE id = ?`, id))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return a, nil
+}
+
+// ListAttachments returns the attachments of a task, person or auto, oldest first
+func (s *Storage) ListAttachments(ownerType domain.AttachmentOwner, ownerID int64) ([]*domain.Attachment, error) {
+	rows, err := s.query(
+		`SELECT `+attachmentColumns+` FROM attachments
+		 WHERE owner_type = ? AND owner_id = ?
+		 ORDER BY created_at, id`,
+		ownerType, ownerID,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var attachments []*domain.Attachment
+	for rows.Next() {
+		a, err := scanAttachment(rows)
+		if err != nil {
+			return nil, err
+		}
+		attachments = append(attachments, a)
+	}
+	return attachments, rows.Err()
+}
+
+func (s *Storage) DeleteAttachment(id int64) error {
+	_, err := s.exec(`DELETE FROM attachments WHERE id = ?`, id)
+	return err
+}
+
+// deleteAttachments removes the attachments of a deleted task, person or auto
+func (s *Storage) deleteAttachments(ownerType domain.AttachmentOwner, ownerID int64) error {
+	_, err := s.exec(`DELETE FROM attachments WHERE owner_type = ? AND owner_id = ?`, ownerType, ownerID)
+	return err
+}
+
+func scanAttachment(row interface{ Scan(dest ...any) error }) (*domain.Attachment, error) {
+	a := &domain.Attachment{}
+	if err := row.Scan(&a.ID, &a.UserID, &a.OwnerType, &a.OwnerID, &a.Kind, &a.FileID, &a.FileUniqueID,
+		&a.FileName, &a.MimeType, &a.FileSize, &a.Caption, &a.LocalPath, &a.CreatedAt); err != nil {
+		return nil, err
+	}
+	return a, nil
+}
@@ -0,0 +1,275 @@
+package storage
+
+import (
+	"database/sql"
+	"time"
+
+	"github.com/tazhate/familybot/internal/domain"
+)
+
+// === Auto: odometer ===
+
+func (s *Storage) AddOdometerReading(r *domain.OdometerReading) error {
+	if r.RecordedAt.IsZero() {
+		r.RecordedAt = time.Now()
+	}
+	id, err := s.insert(
+		`INSERT INTO auto_odometer (auto_id, user_id, km, recorded_at) VALUES (?, ?, ?, ?)`,
+		r.AutoID, r.UserID, r.Km, r.RecordedAt.UTC(),
+	)
+	if err != nil {
+		return err
+	}
+	r.ID = id
+	return nil
+}
+
+// GetLatestOdometerReading returns the last recorded mileage of the auto
+func (s *Storage) GetLatestOdometerReading(autoID int64) (*domain.OdometerReading, error) {
+	r := &domain.OdometerReading{}
+	err := s.queryRow(
+		`SELECT id, auto_id, user_id, km, recorded_at FROM auto_odometer
+		 WHERE auto_id = ? ORDER BY recorded_at DESC, id DESC LIMIT 1`,
+		autoID,
+	).Scan(&r.ID, &r.AutoID, &r.UserID, &r.Km, &r.RecordedAt)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// ListOdometerReadings returns the latest readings, newest first
+func (s *Storage) ListOdometerReadings(autoID int64, limit int) ([]*domain.OdometerReading, error) {
+	rows, err := s.query(
+		`SELECT id, auto_id, user_id, km, recorded_at FROM auto_odometer
+		 WHERE auto_id = ? ORDER BY recorded_at DESC, id DESC LIMIT ?`,
+		autoID, limit,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var readings []*domain.OdometerReading
+	for rows.Next() {
+		r := &domain.OdometerReading{}
+		if err := rows.Scan(&r.ID, &r.AutoID, &r.UserID, &r.Km, &r.RecordedAt); err != nil {
+			return nil, err
+		}
+		readings = append(readings, r)
+	}
+	return readings, rows.Err()
+}
+
+// === Auto: service records ===
+
+func (s *Storage) CreateServiceRecord(r *domain.ServiceRecord) error {
+	if r.PerformedAt.IsZero() {
+		r.PerformedAt = time.Now()
+	}
+	id, err := s.insert(
+		`INSERT INTO auto_services (auto_id, user_id, rule_id, title, km, cost, notes, performed_at)
+		 VALUES (?, ?, ?, ?, ?, ?, ?, ?)`,
+		r.AutoID, r.UserID, r.RuleID, r.Title, r.Km, r.Cost, r.Notes, r.PerformedAt.UTC(),
+	)
+	if err != nil {
+		return err
+	}
+	r.ID = id
+	return nil
+}
+
+// ListServiceRecords returns the latest service records, newest first
+func (s *Storage) ListServiceRecords(autoID int64, limit int) ([]*domain.ServiceRecord, error) {
+	rows, err := s.query(
+		`SELECT id, auto_id, user_id, rule_id, title, km, cost, notes, performed_at FROM auto_services
+		 WHERE auto_id = ? ORDER BY performed_at DESC, id DESC LIMIT ?`,
+		autoID, limit,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var records []*domain.ServiceRecord
+	for rows.Next() {
+		r := &domain.ServiceRecord{}
+		if err := rows.Scan(&r.ID, &r.AutoID, &r.UserID, &r.RuleID, &r.Title, &r.Km, &r.Cost, &r.Notes, &r.PerformedAt); err != nil {
+			return nil, err
+		}
+		records = append(records, r)
+	}
+	return records, rows.Err()
+}
+
+// ServiceCostTotal returns the number of service records and their total cost
+func (s *Storage) ServiceCostTotal(autoID int64) (int, float64, error) {
+	var count int
+	var total float64
+	err := s.queryRow(
+		`SELECT COUNT(*), COALESCE(SUM(cost), 0) FROM auto_services WHERE auto_id = ?`,
+		autoID,
+	).Scan(&count, &total)
+	return count, total, err
+}
+
+// === Auto: maintenance rules ===
+
+const maintenanceRuleColumns = `id, auto_id, title, interval_km, interval_months, last_km, last_done_at, created_at`
+
+func (s *Storage) CreateMaintenanceRule(r *domain.MaintenanceRule) error {
+	if r.CreatedAt.IsZero() {
+		r.CreatedAt = time.Now()
+	}
+	id, err := s.insert(
+		`INSERT INTO auto_rules (auto_id, title, interval_km, interval_months, last_km, last_done_at, created_at)
+		 VALUES (?, ?, ?, ?, ?, ?, ?)`,
+		r.AutoID, r.Title, r.IntervalKm, r.IntervalMonths, r.LastKm, utcOrNil(r.LastDoneAt), r.CreatedAt.UTC(),
+	)
+	if err != nil {
+		return err
+	}
+	r.ID = id
+	return nil
+}
+
+func (s *Storage) GetMaintenanceRule(id int64) (*domain.MaintenanceRule, error) {
+	r, err := scanMaintenanceRule(s.queryRow(`SELECT `+maintenanceRuleColumns+` FROM auto_rules WHERE id = ?`, id))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// ListMaintenanceRules returns the rules of the auto; autoID 0 returns the rules of all autos
+func (s *Storage) ListMaintenanceRules(autoID int64) ([]*domain.MaintenanceRule, error) {
+	query := `SELECT ` + maintenanceRuleColumns + ` FROM auto_rules`
+	var args []any
+	if autoID != 0 {
+		query += ` WHERE auto_id = ?`
+		args = append(args, autoID)
+	}
+	rows, err := s.query(query+` ORDER BY auto_id, id`, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var rules []*domain.MaintenanceRule
+	for rows.Next() {
+		r, err := scanMaintenanceRule(rows)
+		if err != nil {
+			return nil, err
+		}
+		rules = append(rules, r)
+	}
+	return rules, rows.Err()
+}
+
+// UpdateMaintenanceRuleDone moves the countdown of the rule to the last service
+func (s *Storage) UpdateMaintenanceRuleDone(id int64, km int, doneAt time.Time) error {
+	_, err := s.exec(`UPDATE auto_rules SET last_km = ?, last_done_at = ? WHERE id = ?`, km, doneAt.UTC(), id)
+	return err
+}
+
+func (s *Storage) DeleteMaintenanceRule(id int64) error {
+	_, err := s.exec(`DELETE FROM auto_rules WHERE id = ?`, id)
+	return err
+}
+
+func scanMaintenanceRule(row interface{ Scan(dest ...any) error }) (*domain.MaintenanceRule, error) {
+	r := &domain.MaintenanceRule{}
+	if err := row.Scan(&r.ID, &r.AutoID, &r.Title, &r.IntervalKm, &r.IntervalMonths, &r.LastKm, &r.LastDoneAt, &r.CreatedAt); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// === Auto: documents ===
+
+const autoDocumentColumns = `id, user_id, auto_id, kind, title, expires_at, notes, created_at`
+
+func (s *Storage) CreateAutoDocument(d *domain.AutoDocument) error {
+	if d.CreatedAt.IsZero() {
+		d.CreatedAt = time.Now()
+	}
+	id, err := s.insert(
+		`INSERT INTO auto_documents (user_id, auto_id, kind, title, expires_at, notes, created_at)
+		 VALUES (?, ?, ?, ?, ?, ?, ?)`,
+		d.UserID, d.AutoID, d.Kind, d.Title, d.ExpiresAt.UTC(), d.Notes, d.CreatedAt.UTC(),
+	)
+	if err != nil {
+		return err
+	}
+	d.ID = id
+	return nil
+}
+
+func (s *Storage) GetAutoDocument(id int64) (*domain.AutoDocument, error) {
+	d, err := scanAutoDocument(s.queryRow(`SELECT `+autoDocumentColumns+` FROM auto_documents WHERE id = ?`, id))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return d, nil
+}
+
+// ListAutoDocuments returns the user's documents, the nearest expiry first
+func (s *Storage) ListAutoDocuments(userID int64) ([]*domain.AutoDocument, error) {
+	return s.listAutoDocuments(`WHERE user_id = ?`, userID)
+}
+
+// ListAutoDocumentsByAuto returns the documents of one auto
+func (s *Storage) ListAutoDocumentsByAuto(autoID int64) ([]*domain.AutoDocument, error) {
+	return s.listAutoDocuments(`WHERE auto_id = ?`, autoID)
+}
+
+// ListAutoDocumentsExpiringBefore returns documents of all users expiring before the moment
+func (s *Storage) ListAutoDocumentsExpiringBefore(before time.Time) ([]*domain.AutoDocument, error) {
+	return s.listAutoDocuments(`WHERE expires_at < ?`, before.UTC())
+}
+
+// UpdateAutoDocumentExpiry sets the new expiry date of a renewed document
+func (s *Storage) UpdateAutoDocumentExpiry(id int64, expiresAt time.Time) error {
+	_, err := s.exec(`UPDATE auto_documents SET expires_at = ? WHERE id = ?`, expiresAt.UTC(), id)
+	return err
+}
+
+func (s *Storage) DeleteAutoDocument(id int64) error {
+	_, err := s.exec(`DELETE FROM auto_documents WHERE id = ?`, id)
+	return err
+}
+
+func (s *Storage) listAutoDocuments(where string, args ...any) ([]*domain.AutoDocument, error) {
+	rows, err := s.query(`SELECT `+autoDocumentColumns+` FROM auto_documents `+where+` ORDER BY expires_at, id`, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var docs []*domain.AutoDocument
+	for rows.Next() {
+		d, err := scanAutoDocument(rows)
+		if err != nil {
+			return nil, err
+		}
+		docs = append(docs, d)
+	}
+	return docs, rows.Err()
+}
+
+func scanAutoDocument(row interface{ Scan(dest ...any) error }) (*domain.AutoDocument, error) {
+	d := &domain.AutoDocument{}
+	if err := row.Scan(&d.ID, &d.UserID, &d.AutoID, &d.Kind, &d.Title, &d.ExpiresAt, &d.Notes, &d.CreatedAt); err != nil {
+		return nil, err
+	}
+	return d, nil
+}
@@ -0,0 +1,24 @@
+package storage
+
+import "database/sql"
+
+// === Bot state ===
+
+// GetBotState returns the stored value or "" if the key is not set
+func (s *Storage) GetBotState(key string) (string, error) {
+	var value string
+	err := s.queryRow(`SELECT value FROM bot_state WHERE key = ?`, key).Scan(&value)
+	if err == sql.ErrNoRows {
+		return "", nil
+	}
+	return value, err
+}
+
+func (s *Storage) SetBotState(key, value string) error {
+	_, err := s.exec(
+		`INSERT INTO bot_state (key, value, updated_at) VALUES (?, ?, CURRENT_TIMESTAMP)
+		 ON CONFLICT (key) DO UPDATE SET value = excluded.value, updated_at = excluded.updated_at`,
+		key, value,
+	)
+	return err
+}
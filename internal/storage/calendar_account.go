@@ -0,0 +1,206 @@
+package storage
+
+import (
+	"database/sql"
+	"time"
+
+	"github.com/tazhate/familybot/internal/domain"
+)
+
+// === Calendar accounts ===
+
+const calendarAccountColumns = `id, user_id, provider, url, username, password, created_at`
+
+func (s *Storage) CreateCalendarAccount(a *domain.CalendarAccount) error {
+	a.CreatedAt = time.Now()
+	id, err := s.insert(
+		`INSERT INTO calendar_accounts (user_id, provider, url, username, password, created_at) VALUES (?, ?, ?, ?, ?, ?)`,
+		a.UserID, a.Provider, a.URL, a.Username, a.Password, a.CreatedAt.UTC(),
+	)
+	if err != nil {
+		return err
+	}
+	a.ID = id
+	return nil
+}
+
+// GetCalendarAccount returns the account or nil if it doesn't exist
+func (s *Storage) GetCalendarAccount(id int64) (*domain.CalendarAccount, error) {
+	return s.getCalendarAccount(`SELECT `+calendarAccountColumns+` FROM calendar_accounts WHERE id = ?`, id)
+}
+
+// GetCalendarAccountByLogin finds the user's account on the server by login
+func (s *Storage) GetCalendarAccountByLogin(userID int64, url, username string) (*domain.CalendarAccount, error) {
+	return s.getCalendarAccount(
+		`SELECT `+calendarAccountColumns+` FROM calendar_accounts WHERE user_id = ? AND url = ? AND username = ?`,
+		userID, url, username,
+	)
+}
+
+func (s *Storage) ListCalendarAccounts(userID int64) ([]*domain.CalendarAccount, error) {
+	rows, err := s.query(`SELECT `+calendarAccountColumns+` FROM calendar_accounts WHERE user_id = ? ORDER BY id`, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var accounts []*domain.CalendarAccount
+	for rows.Next() {
+		a, err := scanCalendarAccount(rows)
+		if err != nil {
+			return nil, err
+		}
+		accounts = append(accounts, a)
+	}
+	return accounts, rows.Err()
+}
+
+func (s *Storage) UpdateCalendarAccount(a *domain.CalendarAccount) error {
+	_, err := s.exec(
+		`UPDATE calendar_accounts SET provider = ?, url = ?, username = ?, password = ? WHERE id = ?`,
+		a.Provider, a.URL, a.Username, a.Password, a.ID,
+	)
+	return err
+}
+
+// DeleteCalendarAccount removes the account with its calendars and their events
+func (s *Storage) DeleteCalendarAccount(id int64) error {
+	if _, err := s.exec(
+		`DELETE FROM calendar_events WHERE calendar_id IN (SELECT id FROM calendars WHERE account_id = ?)`, id,
+	); err != nil {
+		return err
+	}
+	_, err := s.exec(`DELETE FROM calendar_accounts WHERE id = ?`, id)
+	return err
+}
+
+func (s *Storage) getCalendarAccount(query string, args ...any) (*domain.CalendarAccount, error) {
+	a, err := scanCalendarAccount(s.queryRow(query, args...))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return a, nil
+}
+
+func scanCalendarAccount(row interface{ Scan(dest ...any) error }) (*domain.CalendarAccount, error) {
+	a := &domain.CalendarAccount{}
+	if err := row.Scan(&a.ID, &a.UserID, &a.Provider, &a.URL, &a.Username, &a.Password, &a.CreatedAt); err != nil {
+		return nil, err
+	}
+	return a, nil
+}
+
+// === Linked calendars ===
+
+const calendarColumns = `id, account_id, user_id, path, name, color, mode, is_shared, sync_token, ctag, synced_at, created_at`
+
+func (s *Storage) CreateCalendar(c *domain.Calendar) error {
+	c.CreatedAt = time.Now()
+	id, err := s.insert(
+		`INSERT INTO calendars (account_id, user_id, path, name, color, mode, is_shared, sync_token, ctag, synced_at, created_at)
+		 VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		c.AccountID, c.UserID, c.Path, c.Name, c.Color, c.Mode, c.IsShared, c.SyncToken, c.CTag, c.SyncedAt, c.CreatedAt.UTC(),
+	)
+	if err != nil {
+		return err
+	}
+	c.ID = id
+	return nil
+}
+
+// GetCalendar returns the linked calendar or nil if it doesn't exist
+func (s *Storage) GetCalendar(id int64) (*domain.Calendar, error) {
+	return s.getCalendar(`SELECT `+calendarColumns+` FROM calendars WHERE id = ?`, id)
+}
+
+// GetCalendarByPath finds the linked calendar of the account by its collection path
+func (s *Storage) GetCalendarByPath(accountID int64, path string) (*domain.Calendar, error) {
+	return s.getCalendar(`SELECT `+calendarColumns+` FROM calendars WHERE account_id = ? AND path = ?`, accountID, path)
+}
+
+// ListCalendars returns the user's linked calendars in the order they were linked
+func (s *Storage) ListCalendars(userID int64) ([]*domain.Calendar, error) {
+	return s.listCalendars(`SELECT `+calendarColumns+` FROM calendars WHERE user_id = ? ORDER BY id`, userID)
+}
+
+// ListAllCalendars returns the linked calendars of all users (for sync)
+func (s *Storage) ListAllCalendars() ([]*domain.Calendar, error) {
+	return s.listCalendars(`SELECT ` + calendarColumns + ` FROM calendars ORDER BY id`)
+}
+
+func (s *Storage) UpdateCalendar(c *domain.Calendar) error {
+	_, err := s.exec(
+		`UPDATE calendars SET name = ?, color = ?, mode = ?, is_shared = ?, sync_token = ?, ctag = ?, synced_at = ? WHERE id = ?`,
+		c.Name, c.Color, c.Mode, c.IsShared, c.SyncToken, c.CTag, c.SyncedAt, c.ID,
+	)
+	return err
+}
+
+// DeleteCalendar unlinks the calendar and removes its events
+func (s *Storage) DeleteCalendar(id int64) error {
+	if _, err := s.exec(`DELETE FROM calendar_events WHERE calendar_id = ?`, id); err != nil {
+		return err
+	}
+	_, err := s.exec(`DELETE FROM calendars WHERE id = ?`, id)
+	return err
+}
+
+// SetCalendarEventsShared applies the calendar's sharing flag to its events
+func (s *Storage) SetCalendarEventsShared(calendarID int64, shared bool) error {
+	_, err := s.exec(`UPDATE calendar_events SET is_shared = ? WHERE calendar_id = ?`, shared, calendarID)
+	return err
+}
+
+// AdoptCalendarEvents attaches the synced events without a calendar (imported before
+// calendars could be linked) to the calendar. Returns the number of events.
+func (s *Storage) AdoptCalendarEvents(calendarID, userID int64) (int64, error) {
+	res, err := s.exec(
+		`UPDATE calendar_events SET calendar_id = ? WHERE calendar_id IS NULL AND user_id = ? AND caldav_uid != ''`,
+		calendarID, userID,
+	)
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
+
+func (s *Storage) getCalendar(query string, args ...any) (*domain.Calendar, error) {
+	c, err := scanCalendar(s.queryRow(query, args...))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+func (s *Storage) listCalendars(query string, args ...any) ([]*domain.Calendar, error) {
+	rows, err := s.query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var calendars []*domain.Calendar
+	for rows.Next() {
+		c, err := scanCalendar(rows)
+		if err != nil {
+			return nil, err
+		}
+		calendars = append(calendars, c)
+	}
+	return calendars, rows.Err()
+}
+
+func scanCalendar(row interface{ Scan(dest ...any) error }) (*domain.Calendar, error) {
+	c := &domain.Calendar{}
+	if err := row.Scan(&c.ID, &c.AccountID, &c.UserID, &c.Path, &c.Name, &c.Color, &c.Mode, &c.IsShared,
+		&c.SyncToken, &c.CTag, &c.SyncedAt, &c.CreatedAt); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
@@ -0,0 +1,137 @@
+package storage
+
+import (
+	"database/sql"
+	"strings"
+	"time"
+
+	"github.com/tazhate/familybot/internal/domain"
+)
+
+// === Calendar event snapshots ===
+
+// SaveCalendarEventSnapshot stores the version of the event seen at the last sync
+func (s *Storage) SaveCalendarEventSnapshot(snap *domain.CalendarEventSnapshot) error {
+	f := snap.Fields
+	_, err := s.exec(
+		`INSERT INTO calendar_event_snapshots (event_id, title, description, location, start_time, end_time, all_day, sequence)
+		 VALUES (?, ?, ?, ?, ?, ?, ?, ?)
+		 ON CONFLICT (event_id) DO UPDATE SET title = excluded.title, description = excluded.description,
+			location = excluded.location, start_time = excluded.start_time, end_time = excluded.end_time,
+			all_day = excluded.all_day, sequence = excluded.sequence`,
+		snap.EventID, f.Title, f.Description, f.Location, f.StartTime, f.EndTime, f.AllDay, snap.Sequence,
+	)
+	return err
+}
+
+// GetCalendarEventSnapshot returns the snapshot of the event or nil if it was never synced
+func (s *Storage) GetCalendarEventSnapshot(eventID int64) (*domain.CalendarEventSnapshot, error) {
+	snap := &domain.CalendarEventSnapshot{EventID: eventID}
+	f := &snap.Fields
+	err := s.queryRow(
+		`SELECT title, description, location, start_time, end_time, all_day, sequence
+		 FROM calendar_event_snapshots WHERE event_id = ?`, eventID,
+	).Scan(&f.Title, &f.Description, &f.Location, &f.StartTime, &f.EndTime, &f.AllDay, &snap.Sequence)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return snap, nil
+}
+
+// === Calendar conflicts ===
+
+const calendarConflictColumns = `id, event_id, user_id, title, description, location, start_time, end_time, all_day,
+	remote_etag, remote_sequence, remote_deleted, local_deleted, fields, created_at`
+
+func (s *Storage) CreateCalendarConflict(c *domain.CalendarConflict) error {
+	c.CreatedAt = time.Now()
+	r := c.Remote
+	id, err := s.insert(
+		`INSERT INTO calendar_conflicts (event_id, user_id, title, description, location, start_time, end_time, all_day,
+			remote_etag, remote_sequence, remote_deleted, local_deleted, fields, created_at)
+		 VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		c.EventID, c.UserID, r.Title, r.Description, r.Location, r.StartTime, r.EndTime, r.AllDay,
+		c.RemoteETag, c.RemoteSequence, c.RemoteDeleted, c.LocalDeleted, joinEventFields(c.Fields), c.CreatedAt.UTC(),
+	)
+	if err != nil {
+		return err
+	}
+	c.ID = id
+	return nil
+}
+
+// GetCalendarConflict returns the conflict or nil if it was resolved
+func (s *Storage) GetCalendarConflict(id int64) (*domain.CalendarConflict, error) {
+	c, err := scanCalendarConflict(s.queryRow(`SELECT `+calendarConflictColumns+` FROM calendar_conflicts WHERE id = ?`, id))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// UpdateCalendarConflict replaces the calendar's version when it changes again before the owner decides
+func (s *Storage) UpdateCalendarConflict(c *domain.CalendarConflict) error {
+	r := c.Remote
+	_, err := s.exec(
+		`UPDATE calendar_conflicts SET title = ?, description = ?, location = ?, start_time = ?, end_time = ?, all_day = ?,
+			remote_etag = ?, remote_sequence = ?, remote_deleted = ?, local_deleted = ?, fields = ?
+		 WHERE id = ?`,
+		r.Title, r.Description, r.Location, r.StartTime, r.EndTime, r.AllDay,
+		c.RemoteETag, c.RemoteSequence, c.RemoteDeleted, c.LocalDeleted, joinEventFields(c.Fields), c.ID,
+	)
+	return err
+}
+
+// ListCalendarConflicts returns the user's open conflicts, oldest first
+func (s *Storage) ListCalendarConflicts(userID int64) ([]*domain.CalendarConflict, error) {
+	rows, err := s.query(`SELECT `+calendarConflictColumns+` FROM calendar_conflicts WHERE user_id = ? ORDER BY id`, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var conflicts []*domain.CalendarConflict
+	for rows.Next() {
+		c, err := scanCalendarConflict(rows)
+		if err != nil {
+			return nil, err
+		}
+		conflicts = append(conflicts, c)
+	}
+	return conflicts, rows.Err()
+}
+
+func (s *Storage) DeleteCalendarConflict(id int64) error {
+	_, err := s.exec(`DELETE FROM calendar_conflicts WHERE id = ?`, id)
+	return err
+}
+
+func scanCalendarConflict(row interface{ Scan(dest ...any) error }) (*domain.CalendarConflict, error) {
+	c := &domain.CalendarConflict{}
+	r := &c.Remote
+	var fields string
+	if err := row.Scan(&c.ID, &c.EventID, &c.UserID, &r.Title, &r.Description, &r.Location, &r.StartTime, &r.EndTime, &r.AllDay,
+		&c.RemoteETag, &c.RemoteSequence, &c.RemoteDeleted, &c.LocalDeleted, &fields, &c.CreatedAt); err != nil {
+		return nil, err
+	}
+	for _, f := range strings.Split(fields, ",") {
+		if f != "" {
+			c.Fields = append(c.Fields, domain.EventField(f))
+		}
+	}
+	return c, nil
+}
+
+func joinEventFields(fields []domain.EventField) string {
+	names := make([]string, len(fields))
+	for i, f := range fields {
+		names[i] = string(f)
+	}
+	return strings.Join(names, ",")
+}
@@ -0,0 +1,46 @@
+package storage
+
+import (
+	"database/sql"
+	"time"
+
+	"github.com/tazhate/familybot/internal/domain"
+)
+
+// SaveCalendarFeed creates the user's feed or replaces its token
+func (s *Storage) SaveCalendarFeed(f *domain.CalendarFeed) error {
+	f.CreatedAt = time.Now()
+	_, err := s.exec(
+		`INSERT INTO calendar_feeds (user_id, token, created_at) VALUES (?, ?, ?)
+		 ON CONFLICT (user_id) DO UPDATE SET token = excluded.token, created_at = excluded.created_at`,
+		f.UserID, f.Token, f.CreatedAt.UTC(),
+	)
+	return err
+}
+
+// GetCalendarFeed returns the user's feed or nil if there is none
+func (s *Storage) GetCalendarFeed(userID int64) (*domain.CalendarFeed, error) {
+	return scanCalendarFeed(s.queryRow(`SELECT user_id, token, created_at FROM calendar_feeds WHERE user_id = ?`, userID))
+}
+
+// GetCalendarFeedByToken returns the feed with the token or nil if the token is unknown
+func (s *Storage) GetCalendarFeedByToken(token string) (*domain.CalendarFeed, error) {
+	return scanCalendarFeed(s.queryRow(`SELECT user_id, token, created_at FROM calendar_feeds WHERE token = ?`, token))
+}
+
+func (s *Storage) DeleteCalendarFeed(userID int64) error {
+	_, err := s.exec(`DELETE FROM calendar_feeds WHERE user_id = ?`, userID)
+	return err
+}
+
+func scanCalendarFeed(row *sql.Row) (*domain.CalendarFeed, error) {
+	f := &domain.CalendarFeed{}
+	err := row.Scan(&f.UserID, &f.Token, &f.CreatedAt)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return f, nil
+}
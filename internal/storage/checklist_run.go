@@ -0,0 +1,117 @@
+package storage
+
+import (
+	"database/sql"
+	"time"
+
+	"github.com/tazhate/familybot/internal/domain"
+)
+
+// === Checklist runs ===
+
+const checklistRunColumns = `id, checklist_id, user_id, weekly_event_id, title, items, is_shared, started_at, completed_at`
+
+func (s *Storage) CreateChecklistRun(r *domain.ChecklistRun) error {
+	if r.StartedAt.IsZero() {
+		r.StartedAt = time.Now()
+	}
+	id, err := s.insert(
+		`INSERT INTO checklist_runs (checklist_id, user_id, weekly_event_id, title, items, is_shared, started_at, completed_at)
+		 VALUES (?, ?, ?, ?, ?, ?, ?, ?)`,
+		r.ChecklistID, r.UserID, r.WeeklyEventID, r.Title, r.ItemsJSON(), r.IsShared, r.StartedAt.UTC(), utcOrNil(r.CompletedAt),
+	)
+	if err != nil {
+		return err
+	}
+	r.ID = id
+	return nil
+}
+
+func (s *Storage) GetChecklistRun(id int64) (*domain.ChecklistRun, error) {
+	return s.getChecklistRun(`SELECT `+checklistRunColumns+` FROM checklist_runs WHERE id = ?`, id)
+}
+
+// GetOpenChecklistRun returns the latest unfinished run of the checklist
+func (s *Storage) GetOpenChecklistRun(checklistID int64) (*domain.ChecklistRun, error) {
+	return s.getChecklistRun(
+		`SELECT `+checklistRunColumns+` FROM checklist_runs
+		 WHERE checklist_id = ? AND completed_at IS NULL
+		 ORDER BY started_at DESC, id DESC LIMIT 1`,
+		checklistID,
+	)
+}
+
+// GetEventChecklistRun returns the latest run started by the weekly event since the moment since
+func (s *Storage) GetEventChecklistRun(weeklyEventID int64, since time.Time) (*domain.ChecklistRun, error) {
+	return s.getChecklistRun(
+		`SELECT `+checklistRunColumns+` FROM checklist_runs
+		 WHERE weekly_event_id = ? AND started_at >= ?
+		 ORDER BY started_at DESC, id DESC LIMIT 1`,
+		weeklyEventID, since.UTC(),
+	)
+}
+
+// ListChecklistRuns returns the latest runs of the checklist, newest first
+func (s *Storage) ListChecklistRuns(checklistID int64, limit int) ([]*domain.ChecklistRun, error) {
+	rows, err := s.query(
+		`SELECT `+checklistRunColumns+` FROM checklist_runs
+		 WHERE checklist_id = ?
+		 ORDER BY started_at DESC, id DESC LIMIT ?`,
+		checklistID, limit,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var runs []*domain.ChecklistRun
+	for rows.Next() {
+		r, err := scanChecklistRun(rows)
+		if err != nil {
+			return nil, err
+		}
+		runs = append(runs, r)
+	}
+	return runs, rows.Err()
+}
+
+// UpdateChecklistRun saves the items, sharing and completion of the run
+func (s *Storage) UpdateChecklistRun(r *domain.ChecklistRun) error {
+	_, err := s.exec(
+		`UPDATE checklist_runs SET items = ?, is_shared = ?, completed_at = ? WHERE id = ?`,
+		r.ItemsJSON(), r.IsShared, utcOrNil(r.CompletedAt), r.ID,
+	)
+	return err
+}
+
+func (s *Storage) getChecklistRun(query string, args ...any) (*domain.ChecklistRun, error) {
+	r, err := scanChecklistRun(s.queryRow(query, args...))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+func scanChecklistRun(row interface{ Scan(dest ...any) error }) (*domain.ChecklistRun, error) {
+	r := &domain.ChecklistRun{}
+	var itemsJSON string
+	if err := row.Scan(&r.ID, &r.ChecklistID, &r.UserID, &r.WeeklyEventID, &r.Title, &itemsJSON,
+		&r.IsShared, &r.StartedAt, &r.CompletedAt); err != nil {
+		return nil, err
+	}
+	if err := r.ParseItemsJSON(itemsJSON); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+func utcOrNil(t *time.Time) *time.Time {
+	if t == nil {
+		return nil
+	}
+	utc := t.UTC()
+	return &utc
+}
@@ -0,0 +1,360 @@
+package storage_test
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/tazhate/familybot/internal/domain"
+	"github.com/tazhate/familybot/internal/storage"
+	"github.com/tazhate/familybot/internal/storage/storagetest"
+)
+
+// Один и тот же набор проверок на SQLite и PostgreSQL: места, где диалекты расходятся
+// (id новой строки, ON CONFLICT, булевы колонки, полнотекстовый поиск).
+
+// family creates two users of one household and a user of another one
+type family struct {
+	owner, partner, stranger *domain.User
+}
+
+func newFamily(t *testing.T, s *storage.Storage) family {
+	t.Helper()
+	f := family{
+		owner:    &domain.User{TelegramID: 100, Name: "Алекс", Role: domain.RoleOwner},
+		partner:  &domain.User{TelegramID: 200, Name: "Саша", Role: domain.RolePartner},
+		stranger: &domain.User{TelegramID: 300, Name: "Женя", Role: domain.RoleOwner},
+	}
+	for _, u := range []*domain.User{f.owner, f.partner, f.stranger} {
+		if err := s.CreateUser(u); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	home, other := &domain.Household{Name: "Дом"}, &domain.Household{Name: "Соседи"}
+	for _, h := range []*domain.Household{home, other} {
+		if err := s.CreateHousehold(h); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, m := range []*domain.HouseholdMember{
+		{HouseholdID: home.ID, UserID: f.owner.ID, Role: domain.HouseholdAdmin},
+		{HouseholdID: home.ID, UserID: f.partner.ID, Role: domain.HouseholdAdult},
+		{HouseholdID: other.ID, UserID: f.stranger.ID, Role: domain.HouseholdAdmin},
+	} {
+		if err := s.AddHouseholdMember(m); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return f
+}
+
+func createTask(t *testing.T, s *storage.Storage, user *domain.User, title string, shared bool) *domain.Task {
+	t.Helper()
+	task := &domain.Task{UserID: user.ID, ChatID: user.TelegramID, Title: title, Priority: domain.PriorityWeek, IsShared: shared}
+	if err := s.CreateTask(task); err != nil {
+		t.Fatal(err)
+	}
+	return task
+}
+
+func taskTitles(tasks []*domain.Task) map[string]bool {
+	titles := make(map[string]bool)
+	for _, task := range tasks {
+		titles[task.Title] = true
+	}
+	return titles
+}
+
+func TestInsertReturnsID(t *testing.T) {
+	storagetest.Each(t, func(t *testing.T, s *storage.Storage) {
+		f := newFamily(t, s)
+		if f.owner.ID == 0 || f.partner.ID == 0 || f.owner.ID == f.partner.ID {
+			t.Fatalf("user ids %d, %d", f.owner.ID, f.partner.ID)
+		}
+		if u, err := s.GetUserByID(f.partner.ID); err != nil || u == nil || u.Name != "Саша" {
+			t.Errorf("GetUserByID(%d) = %+v, %v", f.partner.ID, u, err)
+		}
+
+		first := createTask(t, s, f.owner, "Купить молоко", false)
+		second := createTask(t, s, f.owner, "Оплатить интернет", false)
+		if second.ID <= first.ID {
+			t.Errorf("task ids %d, %d", first.ID, second.ID)
+		}
+		if task, err := s.GetTask(second.ID); err != nil || task == nil || task.Title != "Оплатить интернет" {
+			t.Errorf("GetTask(%d) = %+v, %v", second.ID, task, err)
+		}
+	})
+}
+
+func TestUpserts(t *testing.T) {
+	storagetest.Each(t, func(t *testing.T, s *storage.Storage) {
+		f := newFamily(t, s)
+
+		t.Run("bot state", func(t *testing.T) {
+			for _, v := range []string{"1", "2"} {
+				if err := s.SetBotState("telegram_offset", v); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if v, err := s.GetBotState("telegram_offset"); err != nil || v != "2" {
+				t.Errorf("bot state %q, %v; want 2", v, err)
+			}
+		})
+
+		t.Run("job watermark", func(t *testing.T) {
+			first := time.Date(2030, time.June, 4, 10, 0, 0, 0, time.UTC)
+			for _, at := range []time.Time{first, first.Add(time.Minute)} {
+				if err := s.SetJobWatermark("task_reminders", at); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if at, err := s.GetJobWatermark("task_reminders"); err != nil || at == nil || !at.Equal(first.Add(time.Minute)) {
+				t.Errorf("watermark %v, %v; want %s", at, err, first.Add(time.Minute))
+			}
+		})
+
+		t.Run("user settings", func(t *testing.T) {
+			for _, morning := range []string{"08:00", "07:30"} {
+				if err := s.SaveUserSettings(&domain.UserSettings{UserID: f.owner.ID, Timezone: "Europe/Moscow", MorningTime: morning}); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if st, err := s.GetUserSettings(f.owner.ID); err != nil || st == nil || st.MorningTime != "07:30" {
+				t.Errorf("settings %+v, %v; want morning 07:30", st, err)
+			}
+		})
+
+		t.Run("notification do nothing", func(t *testing.T) {
+			now := time.Date(2030, time.June, 4, 10, 0, 0, 0, time.UTC)
+			first := &domain.Notification{Key: "briefing:1", UserID: &f.owner.ID, ChatID: f.owner.TelegramID, Text: "первое"}
+			again := &domain.Notification{Key: "briefing:1", UserID: &f.owner.ID, ChatID: f.owner.TelegramID, Text: "второе"}
+			if added, err := s.EnqueueNotification(first, now); err != nil || !added {
+				t.Fatalf("first enqueue: %v, %v", added, err)
+			}
+			if added, err := s.EnqueueNotification(again, now.Add(time.Minute)); err != nil || added {
+				t.Fatalf("second enqueue: %v, %v", added, err)
+			}
+			if again.ID != first.ID {
+				t.Errorf("duplicate got id %d, want %d", again.ID, first.ID)
+			}
+			pending, err := s.ListNotifications(domain.NotificationPending, 10)
+			if err != nil || len(pending) != 1 || pending[0].Text != "первое" {
+				t.Fatalf("outbox %+v, %v; want only the first", pending, err)
+			}
+			if !pending[0].CreatedAt.Equal(now) || !pending[0].NextAttemptAt.Equal(now) {
+				t.Errorf("created %s, next attempt %s; want %s", pending[0].CreatedAt, pending[0].NextAttemptAt, now)
+			}
+		})
+
+		t.Run("task blocker do nothing", func(t *testing.T) {
+			task := createTask(t, s, f.owner, "Повесить полку", false)
+			blocker := createTask(t, s, f.owner, "Купить дюбели", false)
+			for range 2 {
+				if err := s.AddTaskBlocker(task.ID, blocker.ID); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if blockers, err := s.ListTaskBlockers(task.ID); err != nil || len(blockers) != 1 {
+				t.Errorf("blockers %d, %v; want 1", len(blockers), err)
+			}
+		})
+
+		t.Run("calendar snapshot", func(t *testing.T) {
+			start := time.Date(2030, time.June, 4, 19, 0, 0, 0, time.UTC)
+			event := &domain.CalendarEvent{UserID: f.owner.ID, Title: "Ужин", StartTime: start, EndTime: start.Add(time.Hour)}
+			if err := s.CreateCalendarEvent(event); err != nil {
+				t.Fatal(err)
+			}
+			for seq, title := range []string{"Ужин", "Ужин с мамой"} {
+				snap := &domain.CalendarEventSnapshot{EventID: event.ID, Fields: event.Fields(), Sequence: seq}
+				snap.Fields.Title = title
+				if err := s.SaveCalendarEventSnapshot(snap); err != nil {
+					t.Fatal(err)
+				}
+			}
+			snap, err := s.GetCalendarEventSnapshot(event.ID)
+			if err != nil || snap == nil || snap.Fields.Title != "Ужин с мамой" || snap.Sequence != 1 {
+				t.Errorf("snapshot %+v, %v", snap, err)
+			}
+		})
+	})
+}
+
+func TestBooleanColumns(t *testing.T) {
+	storagetest.Each(t, func(t *testing.T, s *storage.Storage) {
+		f := newFamily(t, s)
+		now := time.Date(2030, time.June, 4, 10, 0, 0, 0, time.UTC)
+
+		t.Run("shared tasks", func(t *testing.T) {
+			createTask(t, s, f.owner, "Общая", true)
+			createTask(t, s, f.owner, "Личная", false)
+			createTask(t, s, f.stranger, "Чужая общая", true)
+
+			shared, err := s.ListSharedTasks(f.partner.ID, false)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if titles := taskTitles(shared); len(titles) != 1 || !titles["Общая"] {
+				t.Errorf("partner sees shared %v, want only «Общая»", titles)
+			}
+			own, err := s.ListTasksByUser(f.partner.ID, true, false)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if titles := taskTitles(own); len(titles) != 1 || !titles["Общая"] {
+				t.Errorf("partner sees %v, want only «Общая»", titles)
+			}
+		})
+
+		t.Run("active reminders", func(t *testing.T) {
+			due := now.Add(-time.Minute)
+			for _, r := range []*domain.Reminder{
+				{UserID: f.owner.ID, Title: "Включено", Type: domain.ReminderDaily, IsActive: true, NextRun: &due},
+				{UserID: f.owner.ID, Title: "Выключено", Type: domain.ReminderDaily, IsActive: false, NextRun: &due},
+			} {
+				if err := s.CreateReminder(r); err != nil {
+					t.Fatal(err)
+				}
+			}
+			reminders, err := s.ListDueReminders(now)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(reminders) != 1 || reminders[0].Title != "Включено" || !reminders[0].IsActive {
+				t.Errorf("due reminders %+v, want only the active one", reminders)
+			}
+		})
+
+		t.Run("floating events", func(t *testing.T) {
+			for _, e := range []*domain.WeeklyEvent{
+				{UserID: f.owner.ID, DayOfWeek: domain.WeekdaySaturday, TimeStart: "10:00", Title: "Бассейн", IsFloating: true, FloatingDays: "6,0"},
+				{UserID: f.owner.ID, DayOfWeek: domain.WeekdayMonday, TimeStart: "18:00", Title: "Английский"},
+			} {
+				if err := s.CreateWeeklyEvent(e); err != nil {
+					t.Fatal(err)
+				}
+			}
+			floating, err := s.ListFloatingEvents(f.owner.ID)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(floating) != 1 || floating[0].Title != "Бассейн" {
+				t.Errorf("floating events %+v, want only «Бассейн»", floating)
+			}
+		})
+
+		t.Run("all-day events", func(t *testing.T) {
+			start := now.Add(10 * time.Minute)
+			for _, e := range []*domain.CalendarEvent{
+				{UserID: f.owner.ID, CalDAVUID: "call", Title: "Созвон", StartTime: start, EndTime: start.Add(time.Hour)},
+				{UserID: f.owner.ID, CalDAVUID: "vacation", Title: "Отпуск", StartTime: start, EndTime: start.Add(24 * time.Hour), AllDay: true},
+			} {
+				if err := s.CreateCalendarEvent(e); err != nil {
+					t.Fatal(err)
+				}
+			}
+			events, err := s.ListUpcomingCalendarEventsForReminder(15, now)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(events) != 1 || events[0].Title != "Созвон" || events[0].AllDay {
+				t.Errorf("upcoming events %+v, want only «Созвон»", events)
+			}
+		})
+	})
+}
+
+func TestSearch(t *testing.T) {
+	storagetest.Each(t, func(t *testing.T, s *storage.Storage) {
+		f := newFamily(t, s)
+		createTask(t, s, f.owner, "Купить молоко", true)
+		createTask(t, s, f.owner, "Молоко для блинов", false)
+		createTask(t, s, f.stranger, "Молоко соседям", true)
+
+		search := func(user *domain.User, query string) map[string]bool {
+			t.Helper()
+			results, err := s.Search(user.ID, query, 10)
+			if err != nil {
+				t.Fatalf("search %q: %v", query, err)
+			}
+			titles := make(map[string]bool)
+			for _, r := range results {
+				if r.Kind != "task" || r.RefID == 0 {
+					t.Errorf("result %+v", r)
+				}
+				titles[r.Title] = true
+			}
+			return titles
+		}
+
+		// Префикс «молок» находит «молоко»; общие задачи видны только своей семье
+		if got := search(f.owner, "молок"); len(got) != 2 || !got["Купить молоко"] || !got["Молоко для блинов"] {
+			t.Errorf("owner found %v", got)
+		}
+		if got := search(f.partner, "молок"); len(got) != 1 || !got["Купить молоко"] {
+			t.Errorf("partner found %v, want only the shared task", got)
+		}
+		if got := search(f.owner, "купить молоко"); len(got) != 1 || !got["Купить молоко"] {
+			t.Errorf("all terms must match, found %v", got)
+		}
+		if got := search(f.owner, "хлеб"); len(got) != 0 {
+			t.Errorf("found %v for a missing word", got)
+		}
+		// Синтаксис FTS в запросе — просто слова
+		if got := search(f.owner, `"молок*) -`); len(got) != 2 {
+			t.Errorf("query with FTS syntax found %v", got)
+		}
+	})
+}
+
+func TestAcceptHouseholdInvite(t *testing.T) {
+	storagetest.Each(t, func(t *testing.T, s *storage.Storage) {
+		f := newFamily(t, s)
+		home, err := s.GetHouseholdMember(f.owner.ID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		now := time.Date(2030, time.March, 10, 12, 0, 0, 0, time.UTC)
+		inv := &domain.HouseholdInvite{Code: "ABCD2345", HouseholdID: home.HouseholdID, Role: domain.HouseholdAdult, CreatedBy: f.owner.ID, ExpiresAt: now.Add(time.Hour)}
+		if err := s.CreateHouseholdInvite(inv); err != nil {
+			t.Fatal(err)
+		}
+		unused := func() bool {
+			t.Helper()
+			got, err := s.GetHouseholdInvite(inv.Code)
+			if err != nil {
+				t.Fatal(err)
+			}
+			return got.UsedBy == nil
+		}
+
+		// Участник другой семьи: вставка падает, и приглашение остаётся свободным
+		err = s.AcceptHouseholdInvite(inv.Code, &domain.HouseholdMember{HouseholdID: home.HouseholdID, UserID: f.stranger.ID, Role: inv.Role}, now)
+		if err == nil {
+			t.Fatal("second household membership accepted")
+		}
+		if !unused() {
+			t.Error("invite used by a failed join")
+		}
+
+		newcomer := &domain.User{TelegramID: 400, Name: "Ира", Role: domain.RoleMember}
+		if err := s.CreateUser(newcomer); err != nil {
+			t.Fatal(err)
+		}
+		m := &domain.HouseholdMember{HouseholdID: home.HouseholdID, UserID: newcomer.ID, Role: inv.Role}
+		if err := s.AcceptHouseholdInvite(inv.Code, m, now); err != nil {
+			t.Fatal(err)
+		}
+		if unused() {
+			t.Error("invite not marked as used")
+		}
+		if got, err := s.GetHouseholdMember(newcomer.ID); err != nil || got == nil || got.HouseholdID != home.HouseholdID {
+			t.Errorf("membership %+v, %v", got, err)
+		}
+		err = s.AcceptHouseholdInvite(inv.Code, &domain.HouseholdMember{HouseholdID: home.HouseholdID, UserID: f.stranger.ID, Role: inv.Role}, now)
+		if !errors.Is(err, storage.ErrInviteUsed) {
+			t.Errorf("reused invite: %v, want ErrInviteUsed", err)
+		}
+	})
+}
@@ -0,0 +1,68 @@
+package storage
+
+import (
+	"database/sql"
+	"encoding/json"
+	"time"
+
+	"github.com/tazhate/familybot/internal/domain"
+)
+
+// === Conversations (wizards) ===
+
+func (s *Storage) GetConversation(chatID, userID int64) (*domain.Conversation, error) {
+	c := &domain.Conversation{}
+	var data, history string
+	err := s.queryRow(
+		`SELECT chat_id, user_id, flow, step, data, history, expires_at, updated_at
+		 FROM conversations WHERE chat_id = ? AND user_id = ?`,
+		chatID, userID,
+	).Scan(&c.ChatID, &c.UserID, &c.Flow, &c.Step, &data, &history, &c.ExpiresAt, &c.UpdatedAt)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal([]byte(data), &c.Data); err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal([]byte(history), &c.History); err != nil {
+		return nil, err
+	}
+	if c.Data == nil {
+		c.Data = make(map[string]string)
+	}
+	return c, nil
+}
+
+// SaveConversation inserts or replaces the conversation of the chat and user
+func (s *Storage) SaveConversation(c *domain.Conversation) error {
+	data, err := json.Marshal(c.Data)
+	if err != nil {
+		return err
+	}
+	history, err := json.Marshal(c.History)
+	if err != nil {
+		return err
+	}
+	c.UpdatedAt = time.Now()
+	_, err = s.exec(
+		`INSERT INTO conversations (chat_id, user_id, flow, step, data, history, expires_at, updated_at)
+		 VALUES (?, ?, ?, ?, ?, ?, ?, ?)
+		 ON CONFLICT (chat_id, user_id) DO UPDATE SET
+		   flow = excluded.flow,
+		   step = excluded.step,
+		   data = excluded.data,
+		   history = excluded.history,
+		   expires_at = excluded.expires_at,
+		   updated_at = excluded.updated_at`,
+		c.ChatID, c.UserID, c.Flow, c.Step, string(data), string(history), c.ExpiresAt.UTC(), c.UpdatedAt.UTC(),
+	)
+	return err
+}
+
+func (s *Storage) DeleteConversation(chatID, userID int64) error {
+	_, err := s.exec(`DELETE FROM conversations WHERE chat_id = ? AND user_id = ?`, chatID, userID)
+	return err
+}
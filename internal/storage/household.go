@@ -0,0 +1,198 @@
+package storage
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/tazhate/familybot/internal/domain"
+)
+
+// === Households: семьи, участники, приглашения ===
+
+// ErrInviteUsed — приглашение уже использовал кто-то другой
+var ErrInviteUsed = errors.New("invite already used")
+
+// Общие (is_shared) задачи и события видны только внутри семьи их автора.
+// householdOfUser — владелец строки в одной семье с пользователем ?,
+// householdOfChat — с пользователем, чей Telegram ID (личный чат) равен ?.
+const (
+	householdOfUser = `user_id IN (SELECT m.user_id FROM household_members m
+		JOIN household_members me ON me.household_id = m.household_id
+		WHERE me.user_id = ?)`
+	householdOfChat = `user_id IN (SELECT m.user_id FROM household_members m
+		JOIN household_members me ON me.household_id = m.household_id
+		JOIN users u ON u.id = me.user_id
+		WHERE u.telegram_id = ?)`
+)
+
+func (s *Storage) CreateHousehold(h *domain.Household) error {
+	id, err := s.insert(`INSERT INTO households (name) VALUES (?)`, h.Name)
+	if err != nil {
+		return err
+	}
+	h.ID = id
+	h.CreatedAt = time.Now()
+	return nil
+}
+
+func (s *Storage) GetHousehold(id int64) (*domain.Household, error) {
+	h := &domain.Household{}
+	err := s.queryRow(`SELECT id, name, created_at FROM households WHERE id = ?`, id).
+		Scan(&h.ID, &h.Name, &h.CreatedAt)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	return h, err
+}
+
+func (s *Storage) UpdateHouseholdName(id int64, name string) error {
+	_, err := s.exec(`UPDATE households SET name = ? WHERE id = ?`, name, id)
+	return err
+}
+
+func (s *Storage) AddHouseholdMember(m *domain.HouseholdMember) error {
+	_, err := s.exec(
+		`INSERT INTO household_members (household_id, user_id, role) VALUES (?, ?, ?)`,
+		m.HouseholdID, m.UserID, m.Role,
+	)
+	if err != nil {
+		return err
+	}
+	m.JoinedAt = time.Now()
+	return nil
+}
+
+// GetHouseholdMember returns the user's membership or nil if the user has no household
+func (s *Storage) GetHouseholdMember(userID int64) (*domain.HouseholdMember, error) {
+	m := &domain.HouseholdMember{}
+	err := s.queryRow(
+		`SELECT household_id, user_id, role, joined_at FROM household_members WHERE user_id = ?`,
+		userID,
+	).Scan(&m.HouseholdID, &m.UserID, &m.Role, &m.JoinedAt)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	return m, err
+}
+
+func (s *Storage) UpdateHouseholdMemberRole(userID int64, role domain.HouseholdRole) error {
+	_, err := s.exec(`UPDATE household_members SET role = ? WHERE user_id = ?`, role, userID)
+	return err
+}
+
+func (s *Storage) RemoveHouseholdMember(userID int64) error {
+	_, err := s.exec(`DELETE FROM household_members WHERE user_id = ?`, userID)
+	return err
+}
+
+// ListHouseholdMembers returns members with their users, admins first
+func (s *Storage) ListHouseholdMembers(householdID int64) ([]*domain.HouseholdMember, error) {
+	rows, err := s.query(
+		`SELECT m.household_id, m.user_id, m.role, m.joined_at,
+		        u.id, u.telegram_id, u.name, u.role, u.created_at
+		 FROM household_members m
+		 JOIN users u ON u.id = m.user_id
+		 WHERE m.household_id = ?
+		 ORDER BY CASE m.role WHEN 'admin' THEN 1 WHEN 'adult' THEN 2 WHEN 'child' THEN 3 ELSE 4 END, m.joined_at, u.id`,
+		householdID,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var members []*domain.HouseholdMember
+	for rows.Next() {
+		m := &domain.HouseholdMember{User: &domain.User{}}
+		if err := rows.Scan(&m.HouseholdID, &m.UserID, &m.Role, &m.JoinedAt,
+			&m.User.ID, &m.User.TelegramID, &m.User.Name, &m.User.Role, &m.User.CreatedAt); err != nil {
+			return nil, err
+		}
+		members = append(members, m)
+	}
+	return members, rows.Err()
+}
+
+// ListHouseholdUsers returns every user that belongs to some household
+func (s *Storage) ListHouseholdUsers() ([]*domain.User, error) {
+	rows, err := s.query(
+		`SELECT u.id, u.telegram_id, u.name, u.role, u.created_at
+		 FROM users u
+		 JOIN household_members m ON m.user_id = u.id
+		 ORDER BY m.household_id, u.id`,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var users []*domain.User
+	for rows.Next() {
+		u := &domain.User{}
+		if err := rows.Scan(&u.ID, &u.TelegramID, &u.Name, &u.Role, &u.CreatedAt); err != nil {
+			return nil, err
+		}
+		users = append(users, u)
+	}
+	return users, rows.Err()
+}
+
+func (s *Storage) CreateHouseholdInvite(inv *domain.HouseholdInvite) error {
+	_, err := s.exec(
+		`INSERT INTO household_invites (code, household_id, role, created_by, expires_at) VALUES (?, ?, ?, ?, ?)`,
+		inv.Code, inv.HouseholdID, inv.Role, inv.CreatedBy, inv.ExpiresAt,
+	)
+	if err != nil {
+		return err
+	}
+	inv.CreatedAt = time.Now()
+	return nil
+}
+
+func (s *Storage) GetHouseholdInvite(code string) (*domain.HouseholdInvite, error) {
+	inv := &domain.HouseholdInvite{}
+	err := s.queryRow(
+		`SELECT code, household_id, role, created_by, expires_at, used_by, used_at, created_at
+		 FROM household_invites WHERE code = ?`,
+		code,
+	).Scan(&inv.Code, &inv.HouseholdID, &inv.Role, &inv.CreatedBy, &inv.ExpiresAt, &inv.UsedBy, &inv.UsedAt, &inv.CreatedAt)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	return inv, err
+}
+
+// AcceptHouseholdInvite marks the invite as used by the member and adds the member
+// to the invite's household in one transaction: a failed insert leaves the invite unused.
+// Returns ErrInviteUsed if someone used the invite first.
+func (s *Storage) AcceptHouseholdInvite(code string, m *domain.HouseholdMember, at time.Time) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return fmt.Errorf("begin: %w", err)
+	}
+	defer tx.Rollback()
+
+	res, err := tx.Exec(s.dialect.rebind(
+		`UPDATE household_invites SET used_by = ?, used_at = ? WHERE code = ? AND used_by IS NULL`),
+		m.UserID, at, code,
+	)
+	if err != nil {
+		return err
+	}
+	if n, err := res.RowsAffected(); err == nil && n == 0 {
+		return ErrInviteUsed
+	}
+	if _, err := tx.Exec(s.dialect.rebind(
+		`INSERT INTO household_members (household_id, user_id, role, joined_at) VALUES (?, ?, ?, ?)`),
+		m.HouseholdID, m.UserID, m.Role, at,
+	); err != nil {
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+	m.JoinedAt = at
+	return nil
+}
@@ -0,0 +1,63 @@
+package storage
+
+import (
+	"time"
+
+	"github.com/tazhate/familybot/internal/domain"
+)
+
+// === Live messages ===
+
+// TrackMessage remembers what the message shows; a message shows one thing at a time
+func (s *Storage) TrackMessage(m *domain.LiveMessage) error {
+	if m.UpdatedAt.IsZero() {
+		m.UpdatedAt = time.Now()
+	}
+	_, err := s.exec(
+		`INSERT INTO live_messages (chat_id, message_id, user_id, kind, ref_id, updated_at)
+		 VALUES (?, ?, ?, ?, ?, ?)
+		 ON CONFLICT (chat_id, message_id) DO UPDATE SET
+		   user_id = excluded.user_id,
+		   kind = excluded.kind,
+		   ref_id = excluded.ref_id,
+		   updated_at = excluded.updated_at`,
+		m.ChatID, m.MessageID, m.UserID, m.Kind, m.RefID, m.UpdatedAt.UTC(),
+	)
+	return err
+}
+
+func (s *Storage) UntrackMessage(chatID int64, messageID int) error {
+	_, err := s.exec(`DELETE FROM live_messages WHERE chat_id = ? AND message_id = ?`, chatID, messageID)
+	return err
+}
+
+func (s *Storage) ListLiveMessages(kind domain.LiveMessageKind, refID int64) ([]*domain.LiveMessage, error) {
+	rows, err := s.query(
+		`SELECT chat_id, message_id, user_id, kind, ref_id, updated_at FROM live_messages
+		 WHERE kind = ? AND ref_id = ? ORDER BY updated_at`,
+		kind, refID,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var messages []*domain.LiveMessage
+	for rows.Next() {
+		m := &domain.LiveMessage{}
+		if err := rows.Scan(&m.ChatID, &m.MessageID, &m.UserID, &m.Kind, &m.RefID, &m.UpdatedAt); err != nil {
+			return nil, err
+		}
+		messages = append(messages, m)
+	}
+	return messages, rows.Err()
+}
+
+// DeleteLiveMessagesBefore forgets messages that haven't been shown or updated since before
+func (s *Storage) DeleteLiveMessagesBefore(before time.Time) (int64, error) {
+	res, err := s.exec(`DELETE FROM live_messages WHERE updated_at < ?`, before.UTC())
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
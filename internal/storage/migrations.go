@@ -0,0 +1,1635 @@
+package storage
+
+import (
+	"fmt"
+	"log"
+	"strings"
+	"time"
+)
+
+// migration — один шаг схемы БД. Каждый шаг применяется (и откатывается)
+// в своей транзакции, номер версии записывается в schema_migrations.
+type migration struct {
+	Version int
+	Name    string
+	Up      []string // SQLite
+	Down    []string
+	// PostgresUp/PostgresDown — те же шаги для PostgreSQL.
+	PostgresUp   []string
+	PostgresDown []string
+	// NoFTS5Up заменяет Up, если SQLite собран без FTS5.
+	NoFTS5Up []string
+	// Legacy — шаг мог быть частично применён старым migrate() без версий,
+	// поэтому ошибки "duplicate column" в нём игнорируются.
+	Legacy bool
+}
+
+// MigrationStatus — состояние одного шага для `familybot migrate status`.
+type MigrationStatus struct {
+	Version   int
+	Name      string
+	AppliedAt *time.Time
+}
+
+// migrations — история схемы. Новые шаги добавляются только в конец,
+// уже выпущенные шаги не редактируются.
+var migrations = []migration{
+	{
+		Version: 1,
+		Name:    "baseline",
+		Legacy:  true,
+		Up: []string{
+			`CREATE TABLE IF NOT EXISTS users (
+				id INTEGER PRIMARY KEY AUTOINCREMENT,
+				telegram_id INTEGER UNIQUE NOT NULL,
+				name TEXT NOT NULL,
+				role TEXT NOT NULL DEFAULT 'owner',
+				created_at DATETIME DEFAULT CURRENT_TIMESTAMP
+			)`,
+			`CREATE TABLE IF NOT EXISTS tasks (
+				id INTEGER PRIMARY KEY AUTOINCREMENT,
+				user_id INTEGER NOT NULL,
+				assigned_to INTEGER,
+				title TEXT NOT NULL,
+				description TEXT DEFAULT '',
+				priority TEXT DEFAULT 'someday',
+				is_shared INTEGER DEFAULT 0,
+				due_date DATETIME,
+				done_at DATETIME,
+				created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+				FOREIGN KEY (user_id) REFERENCES users(id),
+				FOREIGN KEY (assigned_to) REFERENCES users(id)
+			)`,
+			`CREATE TABLE IF NOT EXISTS reminders (
+				id INTEGER PRIMARY KEY AUTOINCREMENT,
+				user_id INTEGER NOT NULL,
+				title TEXT NOT NULL,
+				type TEXT NOT NULL,
+				schedule TEXT NOT NULL,
+				params TEXT DEFAULT '{}',
+				is_active INTEGER DEFAULT 1,
+				last_sent DATETIME,
+				next_run DATETIME,
+				created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+				FOREIGN KEY (user_id) REFERENCES users(id)
+			)`,
+			`CREATE INDEX IF NOT EXISTS idx_tasks_user_id ON tasks(user_id)`,
+			`CREATE INDEX IF NOT EXISTS idx_tasks_done_at ON tasks(done_at)`,
+			`CREATE INDEX IF NOT EXISTS idx_reminders_user_id ON reminders(user_id)`,
+			`CREATE INDEX IF NOT EXISTS idx_reminders_next_run ON reminders(next_run)`,
+			// Persons table
+			`CREATE TABLE IF NOT EXISTS persons (
+				id INTEGER PRIMARY KEY AUTOINCREMENT,
+				user_id INTEGER NOT NULL,
+				name TEXT NOT NULL,
+				role TEXT NOT NULL DEFAULT 'contact',
+				birthday DATE,
+				notes TEXT DEFAULT '',
+				created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+				FOREIGN KEY (user_id) REFERENCES users(id)
+			)`,
+			`CREATE INDEX IF NOT EXISTS idx_persons_user_id ON persons(user_id)`,
+			`CREATE INDEX IF NOT EXISTS idx_persons_birthday ON persons(birthday)`,
+			// Add person_id to tasks
+			`ALTER TABLE tasks ADD COLUMN person_id INTEGER REFERENCES persons(id)`,
+			// Weekly schedule table
+			`CREATE TABLE IF NOT EXISTS weekly_events (
+				id INTEGER PRIMARY KEY AUTOINCREMENT,
+				user_id INTEGER NOT NULL,
+				day_of_week INTEGER NOT NULL,
+				time_start TEXT NOT NULL,
+				time_end TEXT DEFAULT '',
+				title TEXT NOT NULL,
+				person_id INTEGER,
+				reminder_before INTEGER DEFAULT 0,
+				is_floating INTEGER DEFAULT 0,
+				created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+				FOREIGN KEY (user_id) REFERENCES users(id),
+				FOREIGN KEY (person_id) REFERENCES persons(id)
+			)`,
+			`CREATE INDEX IF NOT EXISTS idx_weekly_events_user_id ON weekly_events(user_id)`,
+			`CREATE INDEX IF NOT EXISTS idx_weekly_events_day ON weekly_events(day_of_week)`,
+			// Floating events support
+			`ALTER TABLE weekly_events ADD COLUMN floating_days TEXT DEFAULT ''`,
+			`ALTER TABLE weekly_events ADD COLUMN confirmed_day INTEGER`,
+			`ALTER TABLE weekly_events ADD COLUMN confirmed_week INTEGER DEFAULT 0`,
+			// Multi-chat support for tasks
+			`ALTER TABLE tasks ADD COLUMN chat_id INTEGER NOT NULL DEFAULT 0`,
+			`CREATE INDEX IF NOT EXISTS idx_tasks_chat_id ON tasks(chat_id)`,
+			// Migrate existing tasks to personal chat (chat_id = user's telegram_id)
+			`UPDATE tasks SET chat_id = (SELECT telegram_id FROM users WHERE users.id = tasks.user_id) WHERE chat_id = 0`,
+			// Set default reminder_before for floating events that don't have one
+			`UPDATE weekly_events SET reminder_before = 30 WHERE is_floating = 1 AND reminder_before = 0`,
+			// Reminder tracking for urgent tasks
+			`ALTER TABLE tasks ADD COLUMN reminder_count INTEGER DEFAULT 0`,
+			`ALTER TABLE tasks ADD COLUMN last_reminded_at DATETIME`,
+			`ALTER TABLE tasks ADD COLUMN snooze_until DATETIME`,
+			// Autos table
+			`CREATE TABLE IF NOT EXISTS autos (
+				id INTEGER PRIMARY KEY AUTOINCREMENT,
+				user_id INTEGER NOT NULL,
+				name TEXT NOT NULL,
+				year INTEGER DEFAULT 0,
+				insurance_until DATE,
+				maintenance_until DATE,
+				notes TEXT DEFAULT '',
+				created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+				FOREIGN KEY (user_id) REFERENCES users(id)
+			)`,
+			`CREATE INDEX IF NOT EXISTS idx_autos_user_id ON autos(user_id)`,
+			// Repeating tasks
+			`ALTER TABLE tasks ADD COLUMN repeat_type TEXT DEFAULT ''`,
+			`ALTER TABLE tasks ADD COLUMN repeat_time TEXT DEFAULT ''`,
+			`ALTER TABLE tasks ADD COLUMN repeat_week_num INTEGER DEFAULT 0`,
+			// Checklists
+			`CREATE TABLE IF NOT EXISTS checklists (
+				id INTEGER PRIMARY KEY AUTOINCREMENT,
+				user_id INTEGER NOT NULL,
+				title TEXT NOT NULL,
+				items TEXT DEFAULT '[]',
+				person_id INTEGER,
+				created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+				FOREIGN KEY (user_id) REFERENCES users(id),
+				FOREIGN KEY (person_id) REFERENCES persons(id)
+			)`,
+			`CREATE INDEX IF NOT EXISTS idx_checklists_user_id ON checklists(user_id)`,
+			`CREATE INDEX IF NOT EXISTS idx_checklists_title ON checklists(title)`,
+			// Person telegram link
+			`ALTER TABLE persons ADD COLUMN telegram_id INTEGER`,
+			// Task reminders (напоминания привязанные к задачам)
+			`CREATE TABLE IF NOT EXISTS task_reminders (
+				id INTEGER PRIMARY KEY AUTOINCREMENT,
+				task_id INTEGER NOT NULL,
+				remind_before INTEGER NOT NULL,
+				sent_at DATETIME,
+				FOREIGN KEY (task_id) REFERENCES tasks(id) ON DELETE CASCADE
+			)`,
+			`CREATE INDEX IF NOT EXISTS idx_task_reminders_task_id ON task_reminders(task_id)`,
+			`CREATE INDEX IF NOT EXISTS idx_task_reminders_sent ON task_reminders(sent_at)`,
+			// Shared weekly events
+			`ALTER TABLE weekly_events ADD COLUMN is_shared INTEGER DEFAULT 0`,
+			// Link checklist to weekly event
+			`ALTER TABLE weekly_events ADD COLUMN checklist_id INTEGER REFERENCES checklists(id)`,
+			// Trackable weekly events (create tasks that can be marked done)
+			`ALTER TABLE weekly_events ADD COLUMN is_trackable INTEGER DEFAULT 0`,
+			// Calendar events (synced from Apple Calendar)
+			`CREATE TABLE IF NOT EXISTS calendar_events (
+				id INTEGER PRIMARY KEY AUTOINCREMENT,
+				user_id INTEGER NOT NULL,
+				caldav_uid TEXT UNIQUE,
+				title TEXT NOT NULL,
+				description TEXT DEFAULT '',
+				location TEXT DEFAULT '',
+				start_time DATETIME NOT NULL,
+				end_time DATETIME,
+				all_day INTEGER DEFAULT 0,
+				is_shared INTEGER DEFAULT 1,
+				synced_at DATETIME,
+				created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+				updated_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+				FOREIGN KEY (user_id) REFERENCES users(id)
+			)`,
+			`CREATE INDEX IF NOT EXISTS idx_calendar_events_start ON calendar_events(start_time)`,
+			`CREATE INDEX IF NOT EXISTS idx_calendar_events_caldav ON calendar_events(caldav_uid)`,
+			`CREATE INDEX IF NOT EXISTS idx_calendar_events_user ON calendar_events(user_id)`,
+			// Todoist sync
+			`ALTER TABLE tasks ADD COLUMN todoist_id TEXT DEFAULT ''`,
+			`CREATE INDEX IF NOT EXISTS idx_tasks_todoist ON tasks(todoist_id)`,
+		},
+		Down: []string{
+			`DROP TABLE IF EXISTS calendar_events`,
+			`DROP TABLE IF EXISTS task_reminders`,
+			`DROP TABLE IF EXISTS weekly_events`,
+			`DROP TABLE IF EXISTS checklists`,
+			`DROP TABLE IF EXISTS autos`,
+			`DROP TABLE IF EXISTS tasks`,
+			`DROP TABLE IF EXISTS persons`,
+			`DROP TABLE IF EXISTS reminders`,
+			`DROP TABLE IF EXISTS users`,
+		},
+		PostgresUp: []string{
+			`CREATE TABLE IF NOT EXISTS users (
+				id BIGSERIAL PRIMARY KEY,
+				telegram_id BIGINT UNIQUE NOT NULL,
+				name TEXT NOT NULL,
+				role TEXT NOT NULL DEFAULT 'owner',
+				created_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP
+			)`,
+			`CREATE TABLE IF NOT EXISTS persons (
+				id BIGSERIAL PRIMARY KEY,
+				user_id BIGINT NOT NULL REFERENCES users(id),
+				telegram_id BIGINT,
+				name TEXT NOT NULL,
+				role TEXT NOT NULL DEFAULT 'contact',
+				birthday DATE,
+				notes TEXT DEFAULT '',
+				created_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP
+			)`,
+			`CREATE TABLE IF NOT EXISTS tasks (
+				id BIGSERIAL PRIMARY KEY,
+				user_id BIGINT NOT NULL REFERENCES users(id),
+				chat_id BIGINT NOT NULL DEFAULT 0,
+				assigned_to BIGINT REFERENCES users(id),
+				person_id BIGINT REFERENCES persons(id),
+				title TEXT NOT NULL,
+				description TEXT DEFAULT '',
+				priority TEXT DEFAULT 'someday',
+				is_shared BOOLEAN DEFAULT FALSE,
+				due_date TIMESTAMPTZ,
+				done_at TIMESTAMPTZ,
+				created_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP,
+				reminder_count INTEGER DEFAULT 0,
+				last_reminded_at TIMESTAMPTZ,
+				snooze_until TIMESTAMPTZ,
+				repeat_type TEXT DEFAULT '',
+				repeat_time TEXT DEFAULT '',
+				repeat_week_num INTEGER DEFAULT 0,
+				todoist_id TEXT DEFAULT ''
+			)`,
+			`CREATE INDEX IF NOT EXISTS idx_tasks_user_id ON tasks(user_id)`,
+			`CREATE INDEX IF NOT EXISTS idx_tasks_done_at ON tasks(done_at)`,
+			`CREATE INDEX IF NOT EXISTS idx_tasks_chat_id ON tasks(chat_id)`,
+			`CREATE INDEX IF NOT EXISTS idx_tasks_todoist ON tasks(todoist_id)`,
+			`CREATE TABLE IF NOT EXISTS reminders (
+				id BIGSERIAL PRIMARY KEY,
+				user_id BIGINT NOT NULL REFERENCES users(id),
+				title TEXT NOT NULL,
+				type TEXT NOT NULL,
+				schedule TEXT NOT NULL,
+				params TEXT DEFAULT '{}',
+				is_active BOOLEAN DEFAULT TRUE,
+				last_sent TIMESTAMPTZ,
+				next_run TIMESTAMPTZ,
+				created_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP
+			)`,
+			`CREATE INDEX IF NOT EXISTS idx_reminders_user_id ON reminders(user_id)`,
+			`CREATE INDEX IF NOT EXISTS idx_reminders_next_run ON reminders(next_run)`,
+			`CREATE INDEX IF NOT EXISTS idx_persons_user_id ON persons(user_id)`,
+			`CREATE INDEX IF NOT EXISTS idx_persons_birthday ON persons(birthday)`,
+			`CREATE TABLE IF NOT EXISTS checklists (
+				id BIGSERIAL PRIMARY KEY,
+				user_id BIGINT NOT NULL REFERENCES users(id),
+				title TEXT NOT NULL,
+				items TEXT DEFAULT '[]',
+				person_id BIGINT REFERENCES persons(id),
+				created_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP
+			)`,
+			`CREATE INDEX IF NOT EXISTS idx_checklists_user_id ON checklists(user_id)`,
+			`CREATE INDEX IF NOT EXISTS idx_checklists_title ON checklists(title)`,
+			`CREATE TABLE IF NOT EXISTS weekly_events (
+				id BIGSERIAL PRIMARY KEY,
+				user_id BIGINT NOT NULL REFERENCES users(id),
+				day_of_week INTEGER NOT NULL,
+				time_start TEXT NOT NULL,
+				time_end TEXT DEFAULT '',
+				title TEXT NOT NULL,
+				person_id BIGINT REFERENCES persons(id),
+				reminder_before INTEGER DEFAULT 0,
+				is_floating BOOLEAN DEFAULT FALSE,
+				floating_days TEXT DEFAULT '',
+				confirmed_day INTEGER,
+				confirmed_week INTEGER DEFAULT 0,
+				is_shared BOOLEAN DEFAULT FALSE,
+				checklist_id BIGINT REFERENCES checklists(id),
+				is_trackable BOOLEAN DEFAULT FALSE,
+				created_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP
+			)`,
+			`CREATE INDEX IF NOT EXISTS idx_weekly_events_user_id ON weekly_events(user_id)`,
+			`CREATE INDEX IF NOT EXISTS idx_weekly_events_day ON weekly_events(day_of_week)`,
+			`CREATE TABLE IF NOT EXISTS autos (
+				id BIGSERIAL PRIMARY KEY,
+				user_id BIGINT NOT NULL REFERENCES users(id),
+				name TEXT NOT NULL,
+				year INTEGER DEFAULT 0,
+				insurance_until DATE,
+				maintenance_until DATE,
+				notes TEXT DEFAULT '',
+				created_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP
+			)`,
+			`CREATE INDEX IF NOT EXISTS idx_autos_user_id ON autos(user_id)`,
+			`CREATE TABLE IF NOT EXISTS task_reminders (
+				id BIGSERIAL PRIMARY KEY,
+				task_id BIGINT NOT NULL REFERENCES tasks(id) ON DELETE CASCADE,
+				remind_before INTEGER NOT NULL,
+				sent_at TIMESTAMPTZ
+			)`,
+			`CREATE INDEX IF NOT EXISTS idx_task_reminders_task_id ON task_reminders(task_id)`,
+			`CREATE INDEX IF NOT EXISTS idx_task_reminders_sent ON task_reminders(sent_at)`,
+			`CREATE TABLE IF NOT EXISTS calendar_events (
+				id BIGSERIAL PRIMARY KEY,
+				user_id BIGINT NOT NULL REFERENCES users(id),
+				caldav_uid TEXT UNIQUE,
+				title TEXT NOT NULL,
+				description TEXT DEFAULT '',
+				location TEXT DEFAULT '',
+				start_time TIMESTAMPTZ NOT NULL,
+				end_time TIMESTAMPTZ,
+				all_day BOOLEAN DEFAULT FALSE,
+				is_shared BOOLEAN DEFAULT TRUE,
+				synced_at TIMESTAMPTZ,
+				created_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP,
+				updated_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP
+			)`,
+			`CREATE INDEX IF NOT EXISTS idx_calendar_events_start ON calendar_events(start_time)`,
+			`CREATE INDEX IF NOT EXISTS idx_calendar_events_user ON calendar_events(user_id)`,
+		},
+		PostgresDown: []string{
+			`DROP TABLE IF EXISTS calendar_events`,
+			`DROP TABLE IF EXISTS task_reminders`,
+			`DROP TABLE IF EXISTS weekly_events`,
+			`DROP TABLE IF EXISTS checklists`,
+			`DROP TABLE IF EXISTS autos`,
+			`DROP TABLE IF EXISTS tasks`,
+			`DROP TABLE IF EXISTS persons`,
+			`DROP TABLE IF EXISTS reminders`,
+			`DROP TABLE IF EXISTS users`,
+		},
+	},
+	{
+		Version: 2,
+		Name:    "search_index",
+		// Полнотекстовый индекс (FTS5) по задачам, людям, чек-листам,
+		// недельному расписанию и событиям календаря. Поддерживается триггерами.
+		// Без FTS5 (сборка без -tags sqlite_fts5) индекс — обычная таблица,
+		// а Search ищет по ней подстроки.
+		Up:       append([]string{searchIndexFTS5}, searchIndexSteps...),
+		NoFTS5Up: append([]string{searchIndexPlain}, searchIndexSteps...),
+		Down: []string{
+			`DROP TRIGGER IF EXISTS search_tasks_ai`,
+			`DROP TRIGGER IF EXISTS search_tasks_au`,
+			`DROP TRIGGER IF EXISTS search_tasks_ad`,
+			`DROP TRIGGER IF EXISTS search_persons_ai`,
+			`DROP TRIGGER IF EXISTS search_persons_au`,
+			`DROP TRIGGER IF EXISTS search_persons_ad`,
+			`DROP TRIGGER IF EXISTS search_checklists_ai`,
+			`DROP TRIGGER IF EXISTS search_checklists_au`,
+			`DROP TRIGGER IF EXISTS search_checklists_ad`,
+			`DROP TRIGGER IF EXISTS search_weekly_events_ai`,
+			`DROP TRIGGER IF EXISTS search_weekly_events_au`,
+			`DROP TRIGGER IF EXISTS search_weekly_events_ad`,
+			`DROP TRIGGER IF EXISTS search_calendar_events_ai`,
+			`DROP TRIGGER IF EXISTS search_calendar_events_au`,
+			`DROP TRIGGER IF EXISTS search_calendar_events_ad`,
+			`DROP TABLE IF EXISTS search_index`,
+		},
+		// В PostgreSQL search_index — представление, а индексы — GIN по выражениям
+		// в каждой таблице. Выражение индекса должно совпадать с тем, во что
+		// раскрывается to_tsvector('simple', title || ' ' || body) из Search,
+		// вплоть до скобок, иначе планировщик его не использует.
+		PostgresUp: []string{
+			`CREATE FUNCTION checklist_search_text(items TEXT) RETURNS TEXT
+				LANGUAGE SQL IMMUTABLE
+				AS $$ SELECT COALESCE((SELECT string_agg(item->>'text', ' ') FROM jsonb_array_elements(items::jsonb) AS item), '') $$`,
+			`CREATE VIEW search_index AS
+				SELECT title, COALESCE(description, '') AS body, 'task' AS kind, id AS ref_id, user_id, is_shared FROM tasks WHERE done_at IS NULL
+				UNION ALL
+				SELECT name, COALESCE(notes, ''), 'person', id, user_id, FALSE FROM persons
+				UNION ALL
+				SELECT title, checklist_search_text(items), 'checklist', id, user_id, FALSE FROM checklists
+				UNION ALL
+				SELECT title, '', 'weekly', id, user_id, is_shared FROM weekly_events
+				UNION ALL
+				SELECT title, COALESCE(description, '') || ' ' || COALESCE(location, ''), 'event', id, user_id, is_shared FROM calendar_events`,
+			`CREATE INDEX idx_tasks_search ON tasks
+				USING GIN (to_tsvector('simple', title || ' ' || COALESCE(description, ''))) WHERE done_at IS NULL`,
+			`CREATE INDEX idx_persons_search ON persons
+				USING GIN (to_tsvector('simple', name || ' ' || COALESCE(notes, '')))`,
+			`CREATE INDEX idx_checklists_search ON checklists
+				USING GIN (to_tsvector('simple', title || ' ' || checklist_search_text(items)))`,
+			`CREATE INDEX idx_weekly_events_search ON weekly_events
+				USING GIN (to_tsvector('simple', title || ' ' || ''))`,
+			`CREATE INDEX idx_calendar_events_search ON calendar_events
+				USING GIN (to_tsvector('simple', title || ' ' || (COALESCE(description, '') || ' ' || COALESCE(location, ''))))`,
+		},
+		PostgresDown: []string{
+			`DROP INDEX IF EXISTS idx_tasks_search`,
+			`DROP INDEX IF EXISTS idx_persons_search`,
+			`DROP INDEX IF EXISTS idx_checklists_search`,
+			`DROP INDEX IF EXISTS idx_weekly_events_search`,
+			`DROP INDEX IF EXISTS idx_calendar_events_search`,
+			`DROP VIEW IF EXISTS search_index`,
+			`DROP FUNCTION IF EXISTS checklist_search_text(TEXT)`,
+		},
+	},
+	{
+		Version: 3,
+		Name:    "task_relations",
+		// Подзадачи (parent_id) и блокировки «задача ждёт другую задачу».
+		Up: []string{
+			`ALTER TABLE tasks ADD COLUMN parent_id INTEGER`,
+			`CREATE INDEX idx_tasks_parent ON tasks(parent_id)`,
+			`CREATE TABLE task_blockers (
+				task_id INTEGER NOT NULL,
+				blocker_id INTEGER NOT NULL,
+				created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+				PRIMARY KEY (task_id, blocker_id),
+				FOREIGN KEY (task_id) REFERENCES tasks(id) ON DELETE CASCADE,
+				FOREIGN KEY (blocker_id) REFERENCES tasks(id) ON DELETE CASCADE
+			)`,
+			`CREATE INDEX idx_task_blockers_blocker ON task_blockers(blocker_id)`,
+		},
+		Down: []string{
+			`DROP TABLE IF EXISTS task_blockers`,
+			`DROP INDEX IF EXISTS idx_tasks_parent`,
+			`ALTER TABLE tasks DROP COLUMN parent_id`,
+		},
+		PostgresUp: []string{
+			`ALTER TABLE tasks ADD COLUMN parent_id BIGINT`,
+			`CREATE INDEX idx_tasks_parent ON tasks(parent_id)`,
+			`CREATE TABLE task_blockers (
+				task_id BIGINT NOT NULL REFERENCES tasks(id) ON DELETE CASCADE,
+				blocker_id BIGINT NOT NULL REFERENCES tasks(id) ON DELETE CASCADE,
+				created_at TIMESTAMPTZ DEFAULT NOW(),
+				PRIMARY KEY (task_id, blocker_id)
+			)`,
+			`CREATE INDEX idx_task_blockers_blocker ON task_blockers(blocker_id)`,
+		},
+		PostgresDown: []string{
+			`DROP TABLE IF EXISTS task_blockers`,
+			`DROP INDEX IF EXISTS idx_tasks_parent`,
+			`ALTER TABLE tasks DROP COLUMN parent_id`,
+		},
+	},
+	{
+		Version: 4,
+		Name:    "task_rrule",
+		// Произвольные правила повторения RFC 5545 (repeat_type = 'rrule').
+		Up: []string{
+			`ALTER TABLE tasks ADD COLUMN rrule TEXT DEFAULT ''`,
+		},
+		Down: []string{
+			`UPDATE tasks SET repeat_type = '' WHERE repeat_type = 'rrule'`,
+			`ALTER TABLE tasks DROP COLUMN rrule`,
+		},
+		PostgresUp: []string{
+			`ALTER TABLE tasks ADD COLUMN rrule TEXT DEFAULT ''`,
+		},
+		PostgresDown: []string{
+			`UPDATE tasks SET repeat_type = '' WHERE repeat_type = 'rrule'`,
+			`ALTER TABLE tasks DROP COLUMN rrule`,
+		},
+	},
+	{
+		Version: 5,
+		Name:    "households",
+		// Семьи, участники с ролями и приглашения. Уже зарегистрированные
+		// пользователи становятся одной семьёй: owner — admin, остальные — adult.
+		Up: []string{
+			`CREATE TABLE households (
+				id INTEGER PRIMARY KEY AUTOINCREMENT,
+				name TEXT NOT NULL,
+				created_at DATETIME DEFAULT CURRENT_TIMESTAMP
+			)`,
+			`CREATE TABLE household_members (
+				household_id INTEGER NOT NULL,
+				user_id INTEGER NOT NULL UNIQUE,
+				role TEXT NOT NULL DEFAULT 'adult',
+				joined_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+				PRIMARY KEY (household_id, user_id),
+				FOREIGN KEY (household_id) REFERENCES households(id) ON DELETE CASCADE,
+				FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+			)`,
+			`CREATE TABLE household_invites (
+				code TEXT PRIMARY KEY,
+				household_id INTEGER NOT NULL,
+				role TEXT NOT NULL,
+				created_by INTEGER NOT NULL,
+				expires_at DATETIME NOT NULL,
+				used_by INTEGER,
+				used_at DATETIME,
+				created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+				FOREIGN KEY (household_id) REFERENCES households(id) ON DELETE CASCADE
+			)`,
+			`INSERT INTO households (name) SELECT 'Семья' WHERE EXISTS (SELECT 1 FROM users)`,
+			`INSERT INTO household_members (household_id, user_id, role)
+				SELECT (SELECT MIN(id) FROM households), id, CASE WHEN role = 'owner' THEN 'admin' ELSE 'adult' END
+				FROM users`,
+		},
+		Down: []string{
+			`DROP TABLE IF EXISTS household_invites`,
+			`DROP TABLE IF EXISTS household_members`,
+			`DROP TABLE IF EXISTS households`,
+		},
+		PostgresUp: []string{
+			`CREATE TABLE households (
+				id BIGSERIAL PRIMARY KEY,
+				name TEXT NOT NULL,
+				created_at TIMESTAMPTZ DEFAULT NOW()
+			)`,
+			`CREATE TABLE household_members (
+				household_id BIGINT NOT NULL REFERENCES households(id) ON DELETE CASCADE,
+				user_id BIGINT NOT NULL UNIQUE REFERENCES users(id) ON DELETE CASCADE,
+				role TEXT NOT NULL DEFAULT 'adult',
+				joined_at TIMESTAMPTZ DEFAULT NOW(),
+				PRIMARY KEY (household_id, user_id)
+			)`,
+			`CREATE TABLE household_invites (
+				code TEXT PRIMARY KEY,
+				household_id BIGINT NOT NULL REFERENCES households(id) ON DELETE CASCADE,
+				role TEXT NOT NULL,
+				created_by BIGINT NOT NULL,
+				expires_at TIMESTAMPTZ NOT NULL,
+				used_by BIGINT,
+				used_at TIMESTAMPTZ,
+				created_at TIMESTAMPTZ DEFAULT NOW()
+			)`,
+			`INSERT INTO households (name) SELECT 'Семья' WHERE EXISTS (SELECT 1 FROM users)`,
+			`INSERT INTO household_members (household_id, user_id, role)
+				SELECT (SELECT MIN(id) FROM households), id, CASE WHEN role = 'owner' THEN 'admin' ELSE 'adult' END
+				FROM users`,
+		},
+		PostgresDown: []string{
+			`DROP TABLE IF EXISTS household_invites`,
+			`DROP TABLE IF EXISTS household_members`,
+			`DROP TABLE IF EXISTS households`,
+		},
+	},
+	{
+		Version: 6,
+		Name:    "user_settings",
+		// Личные настройки (часовой пояс, время брифингов, тихие часы)
+		// и очередь несрочных сообщений, отложенных до конца тихих часов.
+		Up: []string{
+			`CREATE TABLE user_settings (
+				user_id INTEGER PRIMARY KEY,
+				timezone TEXT NOT NULL DEFAULT '',
+				morning_time TEXT NOT NULL DEFAULT '',
+				evening_time TEXT NOT NULL DEFAULT '',
+				quiet_start TEXT NOT NULL DEFAULT '',
+				quiet_end TEXT NOT NULL DEFAULT '',
+				briefing_sections TEXT NOT NULL DEFAULT '',
+				updated_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+				FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+			)`,
+			`CREATE TABLE queued_messages (
+				id INTEGER PRIMARY KEY AUTOINCREMENT,
+				user_id INTEGER NOT NULL,
+				chat_id INTEGER NOT NULL,
+				text TEXT NOT NULL,
+				task_id INTEGER,
+				created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+				FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+			)`,
+		},
+		Down: []string{
+			`DROP TABLE IF EXISTS queued_messages`,
+			`DROP TABLE IF EXISTS user_settings`,
+		},
+		PostgresUp: []string{
+			`CREATE TABLE user_settings (
+				user_id BIGINT PRIMARY KEY REFERENCES users(id) ON DELETE CASCADE,
+				timezone TEXT NOT NULL DEFAULT '',
+				morning_time TEXT NOT NULL DEFAULT '',
+				evening_time TEXT NOT NULL DEFAULT '',
+				quiet_start TEXT NOT NULL DEFAULT '',
+				quiet_end TEXT NOT NULL DEFAULT '',
+				briefing_sections TEXT NOT NULL DEFAULT '',
+				updated_at TIMESTAMPTZ DEFAULT NOW()
+			)`,
+			`CREATE TABLE queued_messages (
+				id BIGSERIAL PRIMARY KEY,
+				user_id BIGINT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+				chat_id BIGINT NOT NULL,
+				text TEXT NOT NULL,
+				task_id BIGINT,
+				created_at TIMESTAMPTZ DEFAULT NOW()
+			)`,
+		},
+		PostgresDown: []string{
+			`DROP TABLE IF EXISTS queued_messages`,
+			`DROP TABLE IF EXISTS user_settings`,
+		},
+	},
+	{
+		Version: 7,
+		Name:    "notifications",
+		// Исходящие уведомления планировщика: сначала пишутся в таблицу,
+		// затем отправляются диспетчером с повторами. Заменяет queued_messages —
+		// тихие часы теперь просто откладывают next_attempt_at.
+		Up: []string{
+			`CREATE TABLE notifications (
+				id INTEGER PRIMARY KEY AUTOINCREMENT,
+				idempotency_key TEXT NOT NULL UNIQUE,
+				user_id INTEGER,
+				chat_id INTEGER NOT NULL,
+				text TEXT NOT NULL,
+				task_id INTEGER,
+				urgent BOOLEAN NOT NULL DEFAULT FALSE,
+				status TEXT NOT NULL DEFAULT 'pending',
+				attempts INTEGER NOT NULL DEFAULT 0,
+				last_error TEXT NOT NULL DEFAULT '',
+				next_attempt_at DATETIME NOT NULL,
+				created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+				sent_at DATETIME,
+				FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+			)`,
+			`CREATE INDEX IF NOT EXISTS idx_notifications_due ON notifications(status, next_attempt_at)`,
+			`INSERT INTO notifications (idempotency_key, user_id, chat_id, text, task_id, next_attempt_at, created_at)
+				SELECT 'queued:' || id, user_id, chat_id, text, task_id, COALESCE(created_at, CURRENT_TIMESTAMP), created_at FROM queued_messages`,
+			`DROP TABLE IF EXISTS queued_messages`,
+		},
+		Down: []string{
+			`CREATE TABLE queued_messages (
+				id INTEGER PRIMARY KEY AUTOINCREMENT,
+				user_id INTEGER NOT NULL,
+				chat_id INTEGER NOT NULL,
+				text TEXT NOT NULL,
+				task_id INTEGER,
+				created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+				FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+			)`,
+			`INSERT INTO queued_messages (user_id, chat_id, text, task_id, created_at)
+				SELECT user_id, chat_id, text, task_id, created_at FROM notifications
+				WHERE status = 'pending' AND user_id IS NOT NULL`,
+			`DROP TABLE IF EXISTS notifications`,
+		},
+		PostgresUp: []string{
+			`CREATE TABLE notifications (
+				id BIGSERIAL PRIMARY KEY,
+				idempotency_key TEXT NOT NULL UNIQUE,
+				user_id BIGINT REFERENCES users(id) ON DELETE CASCADE,
+				chat_id BIGINT NOT NULL,
+				text TEXT NOT NULL,
+				task_id BIGINT,
+				urgent BOOLEAN NOT NULL DEFAULT FALSE,
+				status TEXT NOT NULL DEFAULT 'pending',
+				attempts INTEGER NOT NULL DEFAULT 0,
+				last_error TEXT NOT NULL DEFAULT '',
+				next_attempt_at TIMESTAMPTZ NOT NULL,
+				created_at TIMESTAMPTZ DEFAULT NOW(),
+				sent_at TIMESTAMPTZ
+			)`,
+			`CREATE INDEX IF NOT EXISTS idx_notifications_due ON notifications(status, next_attempt_at)`,
+			`INSERT INTO notifications (idempotency_key, user_id, chat_id, text, task_id, next_attempt_at, created_at)
+				SELECT 'queued:' || id, user_id, chat_id, text, task_id, COALESCE(created_at, CURRENT_TIMESTAMP), created_at FROM queued_messages`,
+			`DROP TABLE IF EXISTS queued_messages`,
+		},
+		PostgresDown: []string{
+			`CREATE TABLE queued_messages (
+				id BIGSERIAL PRIMARY KEY,
+				user_id BIGINT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+				chat_id BIGINT NOT NULL,
+				text TEXT NOT NULL,
+				task_id BIGINT,
+				created_at TIMESTAMPTZ DEFAULT NOW()
+			)`,
+			`INSERT INTO queued_messages (user_id, chat_id, text, task_id, created_at)
+				SELECT user_id, chat_id, text, task_id, created_at FROM notifications
+				WHERE status = 'pending' AND user_id IS NOT NULL`,
+			`DROP TABLE IF EXISTS notifications`,
+		},
+	},
+	{
+		Version: 8,
+		Name:    "job_watermarks",
+		// До какого момента каждая задача планировщика обработала срабатывания:
+		// после рестарта пропущенные минуты досылаются (или попадают в дайджест).
+		Up: []string{
+			`CREATE TABLE job_watermarks (
+				job TEXT PRIMARY KEY,
+				processed_at DATETIME NOT NULL
+			)`,
+		},
+		Down: []string{
+			`DROP TABLE IF EXISTS job_watermarks`,
+		},
+		PostgresUp: []string{
+			`CREATE TABLE job_watermarks (
+				job TEXT PRIMARY KEY,
+				processed_at TIMESTAMPTZ NOT NULL
+			)`,
+		},
+		PostgresDown: []string{
+			`DROP TABLE IF EXISTS job_watermarks`,
+		},
+	},
+	{
+		Version: 9,
+		Name:    "bot_state",
+		// Служебное состояние бота, например offset getUpdates в режиме polling,
+		// чтобы после рестарта не обрабатывать апдейты повторно.
+		Up: []string{
+			`CREATE TABLE bot_state (
+				key TEXT PRIMARY KEY,
+				value TEXT NOT NULL,
+				updated_at DATETIME DEFAULT CURRENT_TIMESTAMP
+			)`,
+		},
+		Down: []string{
+			`DROP TABLE IF EXISTS bot_state`,
+		},
+		PostgresUp: []string{
+			`CREATE TABLE bot_state (
+				key TEXT PRIMARY KEY,
+				value TEXT NOT NULL,
+				updated_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP
+			)`,
+		},
+		PostgresDown: []string{
+			`DROP TABLE IF EXISTS bot_state`,
+		},
+	},
+	{
+		Version: 10,
+		Name:    "conversations",
+		// Пошаговые диалоги (мастера): текущий шаг и ответы по паре чат + пользователь.
+		Up: []string{
+			`CREATE TABLE conversations (
+				chat_id INTEGER NOT NULL,
+				user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+				flow TEXT NOT NULL,
+				step TEXT NOT NULL,
+				data TEXT NOT NULL DEFAULT '{}',
+				history TEXT NOT NULL DEFAULT '[]',
+				expires_at DATETIME NOT NULL,
+				updated_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+				PRIMARY KEY (chat_id, user_id)
+			)`,
+		},
+		Down: []string{
+			`DROP TABLE IF EXISTS conversations`,
+		},
+		PostgresUp: []string{
+			`CREATE TABLE conversations (
+				chat_id BIGINT NOT NULL,
+				user_id BIGINT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+				flow TEXT NOT NULL,
+				step TEXT NOT NULL,
+				data TEXT NOT NULL DEFAULT '{}',
+				history TEXT NOT NULL DEFAULT '[]',
+				expires_at TIMESTAMPTZ NOT NULL,
+				updated_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP,
+				PRIMARY KEY (chat_id, user_id)
+			)`,
+		},
+		PostgresDown: []string{
+			`DROP TABLE IF EXISTS conversations`,
+		},
+	},
+	{
+		Version: 11,
+		Name:    "checklist_runs",
+		// Прогоны чек-листов: шаблон больше не хранит отметки, каждый прогон
+		// помнит, кто и когда отметил пункт. Начатые отметки шаблонов
+		// переносятся в первый прогон.
+		Up: []string{
+			`CREATE TABLE checklist_runs (
+				id INTEGER PRIMARY KEY AUTOINCREMENT,
+				checklist_id INTEGER NOT NULL REFERENCES checklists(id) ON DELETE CASCADE,
+				user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+				weekly_event_id INTEGER REFERENCES weekly_events(id) ON DELETE SET NULL,
+				title TEXT NOT NULL,
+				items TEXT NOT NULL DEFAULT '[]',
+				is_shared BOOLEAN NOT NULL DEFAULT FALSE,
+				started_at DATETIME NOT NULL,
+				completed_at DATETIME
+			)`,
+			`CREATE INDEX IF NOT EXISTS idx_checklist_runs_checklist ON checklist_runs(checklist_id, started_at)`,
+			`INSERT INTO checklist_runs (checklist_id, user_id, title, items, started_at, completed_at)
+				SELECT id, user_id, title, items, CURRENT_TIMESTAMP,
+					CASE WHEN items NOT LIKE '%"checked":false%' THEN CURRENT_TIMESTAMP END
+				FROM checklists WHERE items LIKE '%"checked":true%'`,
+		},
+		Down: []string{
+			`DROP TABLE IF EXISTS checklist_runs`,
+		},
+		PostgresUp: []string{
+			`CREATE TABLE checklist_runs (
+				id BIGSERIAL PRIMARY KEY,
+				checklist_id BIGINT NOT NULL REFERENCES checklists(id) ON DELETE CASCADE,
+				user_id BIGINT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+				weekly_event_id BIGINT REFERENCES weekly_events(id) ON DELETE SET NULL,
+				title TEXT NOT NULL,
+				items TEXT NOT NULL DEFAULT '[]',
+				is_shared BOOLEAN NOT NULL DEFAULT FALSE,
+				started_at TIMESTAMPTZ NOT NULL,
+				completed_at TIMESTAMPTZ
+			)`,
+			`CREATE INDEX IF NOT EXISTS idx_checklist_runs_checklist ON checklist_runs(checklist_id, started_at)`,
+			`INSERT INTO checklist_runs (checklist_id, user_id, title, items, started_at, completed_at)
+				SELECT id, user_id, title, items, CURRENT_TIMESTAMP,
+					CASE WHEN items NOT LIKE '%"checked":false%' THEN CURRENT_TIMESTAMP END
+				FROM checklists WHERE items LIKE '%"checked":true%'`,
+		},
+		PostgresDown: []string{
+			`DROP TABLE IF EXISTS checklist_runs`,
+		},
+	},
+	{
+		Version: 12,
+		Name:    "live_messages",
+		// Сообщения с чек-листами и общими задачами, которые бот редактирует
+		// у всех получателей при изменении состояния.
+		Up: []string{
+			`CREATE TABLE live_messages (
+				chat_id INTEGER NOT NULL,
+				message_id INTEGER NOT NULL,
+				user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+				kind TEXT NOT NULL,
+				ref_id INTEGER NOT NULL DEFAULT 0,
+				updated_at DATETIME NOT NULL,
+				PRIMARY KEY (chat_id, message_id)
+			)`,
+			`CREATE INDEX IF NOT EXISTS idx_live_messages_ref ON live_messages(kind, ref_id)`,
+		},
+		Down: []string{
+			`DROP TABLE IF EXISTS live_messages`,
+		},
+		PostgresUp: []string{
+			`CREATE TABLE live_messages (
+				chat_id BIGINT NOT NULL,
+				message_id BIGINT NOT NULL,
+				user_id BIGINT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+				kind TEXT NOT NULL,
+				ref_id BIGINT NOT NULL DEFAULT 0,
+				updated_at TIMESTAMPTZ NOT NULL,
+				PRIMARY KEY (chat_id, message_id)
+			)`,
+			`CREATE INDEX IF NOT EXISTS idx_live_messages_ref ON live_messages(kind, ref_id)`,
+		},
+		PostgresDown: []string{
+			`DROP TABLE IF EXISTS live_messages`,
+		},
+	},
+	{
+		Version: 13,
+		Name:    "attachments",
+		// Фото и документы, прикреплённые к задачам, людям и машинам.
+		// Владелец полиморфный (owner_type + owner_id), поэтому без внешнего ключа:
+		// вложения удаляются вместе с владельцем в DeleteTask/DeletePerson/DeleteAuto.
+		Up: []string{
+			`CREATE TABLE attachments (
+				id INTEGER PRIMARY KEY AUTOINCREMENT,
+				user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+				owner_type TEXT NOT NULL,
+				owner_id INTEGER NOT NULL,
+				kind TEXT NOT NULL,
+				file_id TEXT NOT NULL,
+				file_unique_id TEXT NOT NULL DEFAULT '',
+				file_name TEXT NOT NULL DEFAULT '',
+				mime_type TEXT NOT NULL DEFAULT '',
+				file_size INTEGER NOT NULL DEFAULT 0,
+				caption TEXT NOT NULL DEFAULT '',
+				local_path TEXT NOT NULL DEFAULT '',
+				created_at DATETIME NOT NULL
+			)`,
+			`CREATE INDEX IF NOT EXISTS idx_attachments_owner ON attachments(owner_type, owner_id)`,
+		},
+		Down: []string{
+			`DROP TABLE IF EXISTS attachments`,
+		},
+		PostgresUp: []string{
+			`CREATE TABLE attachments (
+				id BIGSERIAL PRIMARY KEY,
+				user_id BIGINT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+				owner_type TEXT NOT NULL,
+				owner_id BIGINT NOT NULL,
+				kind TEXT NOT NULL,
+				file_id TEXT NOT NULL,
+				file_unique_id TEXT NOT NULL DEFAULT '',
+				file_name TEXT NOT NULL DEFAULT '',
+				mime_type TEXT NOT NULL DEFAULT '',
+				file_size BIGINT NOT NULL DEFAULT 0,
+				caption TEXT NOT NULL DEFAULT '',
+				local_path TEXT NOT NULL DEFAULT '',
+				created_at TIMESTAMPTZ NOT NULL
+			)`,
+			`CREATE INDEX IF NOT EXISTS idx_attachments_owner ON attachments(owner_type, owner_id)`,
+		},
+		PostgresDown: []string{
+			`DROP TABLE IF EXISTS attachments`,
+		},
+	},
+	{
+		Version: 14,
+		Name:    "auto_logbook",
+		// Машины: журнал пробега, сервисная книжка, регламент ТО по км/месяцам
+		// и документы со сроком (техосмотр, ОСАГО/КАСКО, права).
+		Up: []string{
+			`CREATE TABLE auto_odometer (
+				id INTEGER PRIMARY KEY AUTOINCREMENT,
+				auto_id INTEGER NOT NULL REFERENCES autos(id) ON DELETE CASCADE,
+				user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+				km INTEGER NOT NULL,
+				recorded_at DATETIME NOT NULL
+			)`,
+			`CREATE INDEX IF NOT EXISTS idx_auto_odometer_auto ON auto_odometer(auto_id, recorded_at)`,
+			`CREATE TABLE auto_rules (
+				id INTEGER PRIMARY KEY AUTOINCREMENT,
+				auto_id INTEGER NOT NULL REFERENCES autos(id) ON DELETE CASCADE,
+				title TEXT NOT NULL,
+				interval_km INTEGER NOT NULL DEFAULT 0,
+				interval_months INTEGER NOT NULL DEFAULT 0,
+				last_km INTEGER NOT NULL DEFAULT 0,
+				last_done_at DATETIME,
+				created_at DATETIME NOT NULL
+			)`,
+			`CREATE INDEX IF NOT EXISTS idx_auto_rules_auto ON auto_rules(auto_id)`,
+			`CREATE TABLE auto_services (
+				id INTEGER PRIMARY KEY AUTOINCREMENT,
+				auto_id INTEGER NOT NULL REFERENCES autos(id) ON DELETE CASCADE,
+				user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+				rule_id INTEGER REFERENCES auto_rules(id) ON DELETE SET NULL,
+				title TEXT NOT NULL,
+				km INTEGER NOT NULL DEFAULT 0,
+				cost REAL NOT NULL DEFAULT 0,
+				notes TEXT NOT NULL DEFAULT '',
+				performed_at DATETIME NOT NULL
+			)`,
+			`CREATE INDEX IF NOT EXISTS idx_auto_services_auto ON auto_services(auto_id, performed_at)`,
+			`CREATE TABLE auto_documents (
+				id INTEGER PRIMARY KEY AUTOINCREMENT,
+				user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+				auto_id INTEGER REFERENCES autos(id) ON DELETE CASCADE,
+				kind TEXT NOT NULL,
+				title TEXT NOT NULL,
+				expires_at DATETIME NOT NULL,
+				notes TEXT NOT NULL DEFAULT '',
+				created_at DATETIME NOT NULL
+			)`,
+			`CREATE INDEX IF NOT EXISTS idx_auto_documents_user ON auto_documents(user_id)`,
+			`CREATE INDEX IF NOT EXISTS idx_auto_documents_expires ON auto_documents(expires_at)`,
+		},
+		Down: []string{
+			`DROP TABLE IF EXISTS auto_documents`,
+			`DROP TABLE IF EXISTS auto_services`,
+			`DROP TABLE IF EXISTS auto_rules`,
+			`DROP TABLE IF EXISTS auto_odometer`,
+		},
+		PostgresUp: []string{
+			`CREATE TABLE auto_odometer (
+				id BIGSERIAL PRIMARY KEY,
+				auto_id BIGINT NOT NULL REFERENCES autos(id) ON DELETE CASCADE,
+				user_id BIGINT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+				km INTEGER NOT NULL,
+				recorded_at TIMESTAMPTZ NOT NULL
+			)`,
+			`CREATE INDEX IF NOT EXISTS idx_auto_odometer_auto ON auto_odometer(auto_id, recorded_at)`,
+			`CREATE TABLE auto_rules (
+				id BIGSERIAL PRIMARY KEY,
+				auto_id BIGINT NOT NULL REFERENCES autos(id) ON DELETE CASCADE,
+				title TEXT NOT NULL,
+				interval_km INTEGER NOT NULL DEFAULT 0,
+				interval_months INTEGER NOT NULL DEFAULT 0,
+				last_km INTEGER NOT NULL DEFAULT 0,
+				last_done_at TIMESTAMPTZ,
+				created_at TIMESTAMPTZ NOT NULL
+			)`,
+			`CREATE INDEX IF NOT EXISTS idx_auto_rules_auto ON auto_rules(auto_id)`,
+			`CREATE TABLE auto_services (
+				id BIGSERIAL PRIMARY KEY,
+				auto_id BIGINT NOT NULL REFERENCES autos(id) ON DELETE CASCADE,
+				user_id BIGINT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+				rule_id BIGINT REFERENCES auto_rules(id) ON DELETE SET NULL,
+				title TEXT NOT NULL,
+				km INTEGER NOT NULL DEFAULT 0,
+				cost DOUBLE PRECISION NOT NULL DEFAULT 0,
+				notes TEXT NOT NULL DEFAULT '',
+				performed_at TIMESTAMPTZ NOT NULL
+			)`,
+			`CREATE INDEX IF NOT EXISTS idx_auto_services_auto ON auto_services(auto_id, performed_at)`,
+			`CREATE TABLE auto_documents (
+				id BIGSERIAL PRIMARY KEY,
+				user_id BIGINT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+				auto_id BIGINT REFERENCES autos(id) ON DELETE CASCADE,
+				kind TEXT NOT NULL,
+				title TEXT NOT NULL,
+				expires_at TIMESTAMPTZ NOT NULL,
+				notes TEXT NOT NULL DEFAULT '',
+				created_at TIMESTAMPTZ NOT NULL
+			)`,
+			`CREATE INDEX IF NOT EXISTS idx_auto_documents_user ON auto_documents(user_id)`,
+			`CREATE INDEX IF NOT EXISTS idx_auto_documents_expires ON auto_documents(expires_at)`,
+		},
+		PostgresDown: []string{
+			`DROP TABLE IF EXISTS auto_documents`,
+			`DROP TABLE IF EXISTS auto_services`,
+			`DROP TABLE IF EXISTS auto_rules`,
+			`DROP TABLE IF EXISTS auto_odometer`,
+		},
+	},
+	{
+		Version: 15,
+		Name:    "calendar_sync_state",
+		// Инкрементальная синхронизация CalDAV: путь и etag каждого объекта,
+		// sync-token (RFC 6578) и ctag каждого календаря.
+		Up: []string{
+			`ALTER TABLE calendar_events ADD COLUMN caldav_path TEXT NOT NULL DEFAULT ''`,
+			`ALTER TABLE calendar_events ADD COLUMN etag TEXT NOT NULL DEFAULT ''`,
+			`CREATE INDEX IF NOT EXISTS idx_calendar_events_path ON calendar_events(caldav_path)`,
+			`CREATE TABLE calendar_sync_state (
+				calendar_path TEXT PRIMARY KEY,
+				sync_token TEXT NOT NULL DEFAULT '',
+				ctag TEXT NOT NULL DEFAULT '',
+				synced_at DATETIME NOT NULL
+			)`,
+		},
+		Down: []string{
+			`DROP TABLE IF EXISTS calendar_sync_state`,
+			`DROP INDEX IF EXISTS idx_calendar_events_path`,
+			`ALTER TABLE calendar_events DROP COLUMN etag`,
+			`ALTER TABLE calendar_events DROP COLUMN caldav_path`,
+		},
+		PostgresUp: []string{
+			`ALTER TABLE calendar_events ADD COLUMN caldav_path TEXT NOT NULL DEFAULT ''`,
+			`ALTER TABLE calendar_events ADD COLUMN etag TEXT NOT NULL DEFAULT ''`,
+			`CREATE INDEX IF NOT EXISTS idx_calendar_events_path ON calendar_events(caldav_path)`,
+			`CREATE TABLE calendar_sync_state (
+				calendar_path TEXT PRIMARY KEY,
+				sync_token TEXT NOT NULL DEFAULT '',
+				ctag TEXT NOT NULL DEFAULT '',
+				synced_at TIMESTAMPTZ NOT NULL
+			)`,
+		},
+		PostgresDown: []string{
+			`DROP TABLE IF EXISTS calendar_sync_state`,
+			`DROP INDEX IF EXISTS idx_calendar_events_path`,
+			`ALTER TABLE calendar_events DROP COLUMN etag`,
+			`ALTER TABLE calendar_events DROP COLUMN caldav_path`,
+		},
+	},
+	{
+		Version: 16,
+		Name:    "calendar_accounts",
+		// Несколько CalDAV-аккаунтов и календарей на пользователя: цвет, направление
+		// синхронизации и «общий для семьи» у каждого календаря. Sync-token и ctag
+		// переезжают из calendar_sync_state в calendars: пути разных серверов совпадают.
+		// caldav_uid больше не уникален: приглашение лежит в календарях обоих супругов
+		// с одним UID. SQLite не умеет снимать UNIQUE, поэтому таблица пересоздаётся;
+		// откат UNIQUE не возвращает.
+		Up: []string{
+			`CREATE TABLE calendar_accounts (
+				id INTEGER PRIMARY KEY AUTOINCREMENT,
+				user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+				provider TEXT NOT NULL,
+				url TEXT NOT NULL,
+				username TEXT NOT NULL,
+				password TEXT NOT NULL,
+				created_at DATETIME NOT NULL
+			)`,
+			`CREATE INDEX IF NOT EXISTS idx_calendar_accounts_user ON calendar_accounts(user_id)`,
+			`CREATE TABLE calendars (
+				id INTEGER PRIMARY KEY AUTOINCREMENT,
+				account_id INTEGER NOT NULL REFERENCES calendar_accounts(id) ON DELETE CASCADE,
+				user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+				path TEXT NOT NULL,
+				name TEXT NOT NULL,
+				color TEXT NOT NULL,
+				mode TEXT NOT NULL DEFAULT 'read',
+				is_shared BOOLEAN NOT NULL DEFAULT FALSE,
+				sync_token TEXT NOT NULL DEFAULT '',
+				ctag TEXT NOT NULL DEFAULT '',
+				synced_at DATETIME,
+				created_at DATETIME NOT NULL,
+				UNIQUE (account_id, path)
+			)`,
+			`CREATE INDEX IF NOT EXISTS idx_calendars_user ON calendars(user_id)`,
+			`CREATE TABLE calendar_events_new (
+				id INTEGER PRIMARY KEY AUTOINCREMENT,
+				user_id INTEGER NOT NULL,
+				calendar_id INTEGER,
+				caldav_uid TEXT,
+				caldav_path TEXT NOT NULL DEFAULT '',
+				etag TEXT NOT NULL DEFAULT '',
+				title TEXT NOT NULL,
+				description TEXT DEFAULT '',
+				location TEXT DEFAULT '',
+				start_time DATETIME NOT NULL,
+				end_time DATETIME,
+				all_day INTEGER DEFAULT 0,
+				is_shared INTEGER DEFAULT 1,
+				synced_at DATETIME,
+				created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+				updated_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+				FOREIGN KEY (user_id) REFERENCES users(id)
+			)`,
+			`INSERT INTO calendar_events_new (id, user_id, caldav_uid, caldav_path, etag, title, description, location,
+				start_time, end_time, all_day, is_shared, synced_at, created_at, updated_at)
+			 SELECT id, user_id, caldav_uid, caldav_path, etag, title, description, location,
+				start_time, end_time, all_day, is_shared, synced_at, created_at, updated_at FROM calendar_events`,
+			// DROP TABLE не вызывает триггеры, записи search_index остаются с теми же ID
+			`DROP TABLE calendar_events`,
+			`ALTER TABLE calendar_events_new RENAME TO calendar_events`,
+			`CREATE INDEX IF NOT EXISTS idx_calendar_events_start ON calendar_events(start_time)`,
+			`CREATE INDEX IF NOT EXISTS idx_calendar_events_caldav ON calendar_events(caldav_uid)`,
+			`CREATE INDEX IF NOT EXISTS idx_calendar_events_user ON calendar_events(user_id)`,
+			`CREATE INDEX IF NOT EXISTS idx_calendar_events_path ON calendar_events(caldav_path)`,
+			`CREATE INDEX IF NOT EXISTS idx_calendar_events_calendar ON calendar_events(calendar_id)`,
+			`CREATE TRIGGER search_calendar_events_ai AFTER INSERT ON calendar_events BEGIN
+				INSERT INTO search_index (title, body, kind, ref_id, user_id, is_shared)
+				VALUES (NEW.title, COALESCE(NEW.description, '') || ' ' || COALESCE(NEW.location, ''), 'event', NEW.id, NEW.user_id, NEW.is_shared);
+			END`,
+			`CREATE TRIGGER search_calendar_events_au AFTER UPDATE ON calendar_events BEGIN
+				DELETE FROM search_index WHERE kind = 'event' AND ref_id = OLD.id;
+				INSERT INTO search_index (title, body, kind, ref_id, user_id, is_shared)
+				VALUES (NEW.title, COALESCE(NEW.description, '') || ' ' || COALESCE(NEW.location, ''), 'event', NEW.id, NEW.user_id, NEW.is_shared);
+			END`,
+			`CREATE TRIGGER search_calendar_events_ad AFTER DELETE ON calendar_events BEGIN
+				DELETE FROM search_index WHERE kind = 'event' AND ref_id = OLD.id;
+			END`,
+			`DROP TABLE IF EXISTS calendar_sync_state`,
+		},
+		Down: []string{
+			`CREATE TABLE calendar_sync_state (
+				calendar_path TEXT PRIMARY KEY,
+				sync_token TEXT NOT NULL DEFAULT '',
+				ctag TEXT NOT NULL DEFAULT '',
+				synced_at DATETIME NOT NULL
+			)`,
+			`DROP INDEX IF EXISTS idx_calendar_events_calendar`,
+			`ALTER TABLE calendar_events DROP COLUMN calendar_id`,
+			`DROP TABLE IF EXISTS calendars`,
+			`DROP TABLE IF EXISTS calendar_accounts`,
+		},
+		PostgresUp: []string{
+			`CREATE TABLE calendar_accounts (
+				id BIGSERIAL PRIMARY KEY,
+				user_id BIGINT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+				provider TEXT NOT NULL,
+				url TEXT NOT NULL,
+				username TEXT NOT NULL,
+				password TEXT NOT NULL,
+				created_at TIMESTAMPTZ NOT NULL
+			)`,
+			`CREATE INDEX IF NOT EXISTS idx_calendar_accounts_user ON calendar_accounts(user_id)`,
+			`CREATE TABLE calendars (
+				id BIGSERIAL PRIMARY KEY,
+				account_id BIGINT NOT NULL REFERENCES calendar_accounts(id) ON DELETE CASCADE,
+				user_id BIGINT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+				path TEXT NOT NULL,
+				name TEXT NOT NULL,
+				color TEXT NOT NULL,
+				mode TEXT NOT NULL DEFAULT 'read',
+				is_shared BOOLEAN NOT NULL DEFAULT FALSE,
+				sync_token TEXT NOT NULL DEFAULT '',
+				ctag TEXT NOT NULL DEFAULT '',
+				synced_at TIMESTAMPTZ,
+				created_at TIMESTAMPTZ NOT NULL,
+				UNIQUE (account_id, path)
+			)`,
+			`CREATE INDEX IF NOT EXISTS idx_calendars_user ON calendars(user_id)`,
+			`ALTER TABLE calendar_events ADD COLUMN calendar_id BIGINT`,
+			`CREATE INDEX IF NOT EXISTS idx_calendar_events_calendar ON calendar_events(calendar_id)`,
+			`ALTER TABLE calendar_events DROP CONSTRAINT IF EXISTS calendar_events_caldav_uid_key`,
+			`DROP TABLE IF EXISTS calendar_sync_state`,
+		},
+		PostgresDown: []string{
+			`CREATE TABLE calendar_sync_state (
+				calendar_path TEXT PRIMARY KEY,
+				sync_token TEXT NOT NULL DEFAULT '',
+				ctag TEXT NOT NULL DEFAULT '',
+				synced_at TIMESTAMPTZ NOT NULL
+			)`,
+			`DROP INDEX IF EXISTS idx_calendar_events_calendar`,
+			`ALTER TABLE calendar_events DROP COLUMN calendar_id`,
+			`DROP TABLE IF EXISTS calendars`,
+			`DROP TABLE IF EXISTS calendar_accounts`,
+		},
+	},
+	{
+		Version: 17,
+		Name:    "calendar_conflicts",
+		// Правки событий в боте уходят в календарь, а встречные правки сливаются
+		// по полям относительно снимка последней синхронизации. Снимки уже загруженных
+		// событий берутся из них самих: до этой версии бот событий не менял.
+		// Неразрешимые конфликты ждут решения владельца, уведомление несёт их ID для кнопок.
+		Up: []string{
+			`ALTER TABLE calendar_events ADD COLUMN sequence INTEGER NOT NULL DEFAULT 0`,
+			`ALTER TABLE calendar_events ADD COLUMN pending BOOLEAN NOT NULL DEFAULT FALSE`,
+			`CREATE TABLE calendar_event_snapshots (
+				event_id INTEGER PRIMARY KEY REFERENCES calendar_events(id) ON DELETE CASCADE,
+				title TEXT NOT NULL,
+				description TEXT NOT NULL DEFAULT '',
+				location TEXT NOT NULL DEFAULT '',
+				start_time DATETIME NOT NULL,
+				end_time DATETIME NOT NULL,
+				all_day BOOLEAN NOT NULL DEFAULT FALSE,
+				sequence INTEGER NOT NULL DEFAULT 0
+			)`,
+			`INSERT INTO calendar_event_snapshots (event_id, title, description, location, start_time, end_time, all_day)
+			 SELECT id, title, COALESCE(description, ''), COALESCE(location, ''), start_time, COALESCE(end_time, start_time), all_day
+			 FROM calendar_events WHERE synced_at IS NOT NULL AND caldav_uid != ''`,
+			`CREATE TABLE calendar_conflicts (
+				id INTEGER PRIMARY KEY AUTOINCREMENT,
+				event_id INTEGER NOT NULL UNIQUE REFERENCES calendar_events(id) ON DELETE CASCADE,
+				user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+				title TEXT NOT NULL DEFAULT '',
+				description TEXT NOT NULL DEFAULT '',
+				location TEXT NOT NULL DEFAULT '',
+				start_time DATETIME NOT NULL,
+				end_time DATETIME NOT NULL,
+				all_day BOOLEAN NOT NULL DEFAULT FALSE,
+				remote_etag TEXT NOT NULL DEFAULT '',
+				remote_sequence INTEGER NOT NULL DEFAULT 0,
+				remote_deleted BOOLEAN NOT NULL DEFAULT FALSE,
+				local_deleted BOOLEAN NOT NULL DEFAULT FALSE,
+				fields TEXT NOT NULL DEFAULT '',
+				created_at DATETIME NOT NULL
+			)`,
+			`CREATE INDEX IF NOT EXISTS idx_calendar_conflicts_user ON calendar_conflicts(user_id)`,
+			`ALTER TABLE notifications ADD COLUMN conflict_id INTEGER`,
+		},
+		Down: []string{
+			`ALTER TABLE notifications DROP COLUMN conflict_id`,
+			`DROP TABLE IF EXISTS calendar_conflicts`,
+			`DROP TABLE IF EXISTS calendar_event_snapshots`,
+			`ALTER TABLE calendar_events DROP COLUMN pending`,
+			`ALTER TABLE calendar_events DROP COLUMN sequence`,
+		},
+		PostgresUp: []string{
+			`ALTER TABLE calendar_events ADD COLUMN sequence INTEGER NOT NULL DEFAULT 0`,
+			`ALTER TABLE calendar_events ADD COLUMN pending BOOLEAN NOT NULL DEFAULT FALSE`,
+			`CREATE TABLE calendar_event_snapshots (
+				event_id BIGINT PRIMARY KEY REFERENCES calendar_events(id) ON DELETE CASCADE,
+				title TEXT NOT NULL,
+				description TEXT NOT NULL DEFAULT '',
+				location TEXT NOT NULL DEFAULT '',
+				start_time TIMESTAMPTZ NOT NULL,
+				end_time TIMESTAMPTZ NOT NULL,
+				all_day BOOLEAN NOT NULL DEFAULT FALSE,
+				sequence INTEGER NOT NULL DEFAULT 0
+			)`,
+			`INSERT INTO calendar_event_snapshots (event_id, title, description, location, start_time, end_time, all_day)
+			 SELECT id, title, COALESCE(description, ''), COALESCE(location, ''), start_time, COALESCE(end_time, start_time), all_day
+			 FROM calendar_events WHERE synced_at IS NOT NULL AND caldav_uid != ''`,
+			`CREATE TABLE calendar_conflicts (
+				id BIGSERIAL PRIMARY KEY,
+				event_id BIGINT NOT NULL UNIQUE REFERENCES calendar_events(id) ON DELETE CASCADE,
+				user_id BIGINT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+				title TEXT NOT NULL DEFAULT '',
+				description TEXT NOT NULL DEFAULT '',
+				location TEXT NOT NULL DEFAULT '',
+				start_time TIMESTAMPTZ NOT NULL,
+				end_time TIMESTAMPTZ NOT NULL,
+				all_day BOOLEAN NOT NULL DEFAULT FALSE,
+				remote_etag TEXT NOT NULL DEFAULT '',
+				remote_sequence INTEGER NOT NULL DEFAULT 0,
+				remote_deleted BOOLEAN NOT NULL DEFAULT FALSE,
+				local_deleted BOOLEAN NOT NULL DEFAULT FALSE,
+				fields TEXT NOT NULL DEFAULT '',
+				created_at TIMESTAMPTZ NOT NULL
+			)`,
+			`CREATE INDEX IF NOT EXISTS idx_calendar_conflicts_user ON calendar_conflicts(user_id)`,
+			`ALTER TABLE notifications ADD COLUMN conflict_id BIGINT`,
+		},
+		PostgresDown: []string{
+			`ALTER TABLE notifications DROP COLUMN conflict_id`,
+			`DROP TABLE IF EXISTS calendar_conflicts`,
+			`DROP TABLE IF EXISTS calendar_event_snapshots`,
+			`ALTER TABLE calendar_events DROP COLUMN pending`,
+			`ALTER TABLE calendar_events DROP COLUMN sequence`,
+		},
+	},
+	{
+		Version: 18,
+		Name:    "calendar_event_recurrence",
+		// Повторяющиеся события календаря разворачиваются в экземпляры — строки с тем же
+		// caldav_uid и своим recurrence_id. Состояние синхронизации сбрасывается, чтобы
+		// уже загруженные серии скачались заново и развернулись. При откате экземпляры
+		// удаляются: прежняя схема хранит объект календаря одной строкой.
+		Up: []string{
+			`ALTER TABLE calendar_events ADD COLUMN recurrence_id TEXT NOT NULL DEFAULT ''`,
+			`UPDATE calendars SET sync_token = '', ctag = ''`,
+		},
+		Down: []string{
+			`DELETE FROM calendar_events WHERE recurrence_id != ''`,
+			`ALTER TABLE calendar_events DROP COLUMN recurrence_id`,
+			`UPDATE calendars SET sync_token = '', ctag = ''`,
+		},
+		PostgresUp: []string{
+			`ALTER TABLE calendar_events ADD COLUMN recurrence_id TEXT NOT NULL DEFAULT ''`,
+			`UPDATE calendars SET sync_token = '', ctag = ''`,
+		},
+		PostgresDown: []string{
+			`DELETE FROM calendar_events WHERE recurrence_id != ''`,
+			`ALTER TABLE calendar_events DROP COLUMN recurrence_id`,
+			`UPDATE calendars SET sync_token = '', ctag = ''`,
+		},
+	},
+	{
+		Version: 19,
+		Name:    "calendar_feeds",
+		// Личная ссылка на подписку: /ical/<token>.ics и CalDAV с токеном вместо пароля.
+		// У пользователя одна ссылка; новая заменяет старую, и та перестаёт работать.
+		Up: []string{
+			`CREATE TABLE calendar_feeds (
+				user_id INTEGER PRIMARY KEY REFERENCES users(id) ON DELETE CASCADE,
+				token TEXT NOT NULL UNIQUE,
+				created_at DATETIME NOT NULL
+			)`,
+		},
+		Down: []string{
+			`DROP TABLE IF EXISTS calendar_feeds`,
+		},
+		PostgresUp: []string{
+			`CREATE TABLE calendar_feeds (
+				user_id BIGINT PRIMARY KEY REFERENCES users(id) ON DELETE CASCADE,
+				token TEXT NOT NULL UNIQUE,
+				created_at TIMESTAMPTZ NOT NULL
+			)`,
+		},
+		PostgresDown: []string{
+			`DROP TABLE IF EXISTS calendar_feeds`,
+		},
+	},
+	{
+		Version: 20,
+		Name:    "task_rrule_start",
+		// DTSTART серии RRULE. Следующее вхождение — новая задача, и без исходного
+		// начала COUNT отсчитывался бы заново от каждого дедлайна.
+		Up: []string{
+			`ALTER TABLE tasks ADD COLUMN rrule_start DATETIME`,
+		},
+		Down: []string{
+			`ALTER TABLE tasks DROP COLUMN rrule_start`,
+		},
+		PostgresUp: []string{
+			`ALTER TABLE tasks ADD COLUMN rrule_start TIMESTAMPTZ`,
+		},
+		PostgresDown: []string{
+			`ALTER TABLE tasks DROP COLUMN rrule_start`,
+		},
+	},
+}
+
+const (
+	searchIndexFTS5 = `CREATE VIRTUAL TABLE search_index USING fts5(
+		title, body,
+		kind UNINDEXED, ref_id UNINDEXED, user_id UNINDEXED, is_shared UNINDEXED,
+		tokenize = 'unicode61 remove_diacritics 2'
+	)`
+	searchIndexPlain = `CREATE TABLE search_index (
+		title TEXT, body TEXT,
+		kind TEXT, ref_id INTEGER, user_id INTEGER, is_shared INTEGER
+	)`
+)
+
+// searchIndexSteps — триггеры и начальное заполнение search_index (миграция 2).
+var searchIndexSteps = []string{
+	// Задачи (только невыполненные)
+	`CREATE TRIGGER search_tasks_ai AFTER INSERT ON tasks WHEN NEW.done_at IS NULL BEGIN
+			INSERT INTO search_index (title, body, kind, ref_id, user_id, is_shared)
+			VALUES (NEW.title, COALESCE(NEW.description, ''), 'task', NEW.id, NEW.user_id, NEW.is_shared);
+		END`,
+	`CREATE TRIGGER search_tasks_au AFTER UPDATE ON tasks BEGIN
+			DELETE FROM search_index WHERE kind = 'task' AND ref_id = OLD.id;
+			INSERT INTO search_index (title, body, kind, ref_id, user_id, is_shared)
+			SELECT NEW.title, COALESCE(NEW.description, ''), 'task', NEW.id, NEW.user_id, NEW.is_shared
+			WHERE NEW.done_at IS NULL;
+		END`,
+	`CREATE TRIGGER search_tasks_ad AFTER DELETE ON tasks BEGIN
+			DELETE FROM search_index WHERE kind = 'task' AND ref_id = OLD.id;
+		END`,
+
+	// Люди
+	`CREATE TRIGGER search_persons_ai AFTER INSERT ON persons BEGIN
+			INSERT INTO search_index (title, body, kind, ref_id, user_id, is_shared)
+			VALUES (NEW.name, COALESCE(NEW.notes, ''), 'person', NEW.id, NEW.user_id, 0);
+		END`,
+	`CREATE TRIGGER search_persons_au AFTER UPDATE ON persons BEGIN
+			DELETE FROM search_index WHERE kind = 'person' AND ref_id = OLD.id;
+			INSERT INTO search_index (title, body, kind, ref_id, user_id, is_shared)
+			VALUES (NEW.name, COALESCE(NEW.notes, ''), 'person', NEW.id, NEW.user_id, 0);
+		END`,
+	`CREATE TRIGGER search_persons_ad AFTER DELETE ON persons BEGIN
+			DELETE FROM search_index WHERE kind = 'person' AND ref_id = OLD.id;
+		END`,
+
+	// Чек-листы: индексируем текст пунктов из JSON
+	`CREATE TRIGGER search_checklists_ai AFTER INSERT ON checklists BEGIN
+			INSERT INTO search_index (title, body, kind, ref_id, user_id, is_shared)
+			VALUES (NEW.title, COALESCE((SELECT group_concat(json_extract(value, '$.text'), ' ') FROM json_each(NEW.items)), ''), 'checklist', NEW.id, NEW.user_id, 0);
+		END`,
+	`CREATE TRIGGER search_checklists_au AFTER UPDATE ON checklists BEGIN
+			DELETE FROM search_index WHERE kind = 'checklist' AND ref_id = OLD.id;
+			INSERT INTO search_index (title, body, kind, ref_id, user_id, is_shared)
+			VALUES (NEW.title, COALESCE((SELECT group_concat(json_extract(value, '$.text'), ' ') FROM json_each(NEW.items)), ''), 'checklist', NEW.id, NEW.user_id, 0);
+		END`,
+	`CREATE TRIGGER search_checklists_ad AFTER DELETE ON checklists BEGIN
+			DELETE FROM search_index WHERE kind = 'checklist' AND ref_id = OLD.id;
+		END`,
+
+	// Недельное расписание
+	`CREATE TRIGGER search_weekly_events_ai AFTER INSERT ON weekly_events BEGIN
+			INSERT INTO search_index (title, body, kind, ref_id, user_id, is_shared)
+			VALUES (NEW.title, '', 'weekly', NEW.id, NEW.user_id, NEW.is_shared);
+		END`,
+	`CREATE TRIGGER search_weekly_events_au AFTER UPDATE ON weekly_events BEGIN
+			DELETE FROM search_index WHERE kind = 'weekly' AND ref_id = OLD.id;
+			INSERT INTO search_index (title, body, kind, ref_id, user_id, is_shared)
+			VALUES (NEW.title, '', 'weekly', NEW.id, NEW.user_id, NEW.is_shared);
+		END`,
+	`CREATE TRIGGER search_weekly_events_ad AFTER DELETE ON weekly_events BEGIN
+			DELETE FROM search_index WHERE kind = 'weekly' AND ref_id = OLD.id;
+		END`,
+
+	// События календаря
+	`CREATE TRIGGER search_calendar_events_ai AFTER INSERT ON calendar_events BEGIN
+			INSERT INTO search_index (title, body, kind, ref_id, user_id, is_shared)
+			VALUES (NEW.title, COALESCE(NEW.description, '') || ' ' || COALESCE(NEW.location, ''), 'event', NEW.id, NEW.user_id, NEW.is_shared);
+		END`,
+	`CREATE TRIGGER search_calendar_events_au AFTER UPDATE ON calendar_events BEGIN
+			DELETE FROM search_index WHERE kind = 'event' AND ref_id = OLD.id;
+			INSERT INTO search_index (title, body, kind, ref_id, user_id, is_shared)
+			VALUES (NEW.title, COALESCE(NEW.description, '') || ' ' || COALESCE(NEW.location, ''), 'event', NEW.id, NEW.user_id, NEW.is_shared);
+		END`,
+	`CREATE TRIGGER search_calendar_events_ad AFTER DELETE ON calendar_events BEGIN
+			DELETE FROM search_index WHERE kind = 'event' AND ref_id = OLD.id;
+		END`,
+
+	// Индексируем уже существующие данные
+	`INSERT INTO search_index (title, body, kind, ref_id, user_id, is_shared)
+		 SELECT title, COALESCE(description, ''), 'task', id, user_id, is_shared FROM tasks WHERE done_at IS NULL`,
+	`INSERT INTO search_index (title, body, kind, ref_id, user_id, is_shared)
+		 SELECT name, COALESCE(notes, ''), 'person', id, user_id, 0 FROM persons`,
+	`INSERT INTO search_index (title, body, kind, ref_id, user_id, is_shared)
+		 SELECT title, COALESCE((SELECT group_concat(json_extract(value, '$.text'), ' ') FROM json_each(checklists.items)), ''), 'checklist', id, user_id, 0 FROM checklists`,
+	`INSERT INTO search_index (title, body, kind, ref_id, user_id, is_shared)
+		 SELECT title, '', 'weekly', id, user_id, is_shared FROM weekly_events`,
+	`INSERT INTO search_index (title, body, kind, ref_id, user_id, is_shared)
+		 SELECT title, COALESCE(description, '') || ' ' || COALESCE(location, ''), 'event', id, user_id, is_shared FROM calendar_events`,
+}
+
+// steps возвращает up- или down-шаги миграции для диалекта.
+func (m migration) steps(d dialect, up bool) []string {
+	switch {
+	case d == dialectPostgres && up:
+		return m.PostgresUp
+	case d == dialectPostgres:
+		return m.PostgresDown
+	case up:
+		return m.Up
+	default:
+		return m.Down
+	}
+}
+
+// LatestSchemaVersion возвращает версию схемы, которую знает этот бинарник.
+func LatestSchemaVersion() int {
+	return migrations[len(migrations)-1].Version
+}
+
+func (s *Storage) ensureMigrationsTable() error {
+	appliedAtType := "DATETIME"
+	if s.dialect == dialectPostgres {
+		appliedAtType = "TIMESTAMPTZ"
+	}
+	_, err := s.db.Exec(`CREATE TABLE IF NOT EXISTS schema_migrations (
+		version INTEGER PRIMARY KEY,
+		name TEXT NOT NULL,
+		applied_at ` + appliedAtType + ` DEFAULT CURRENT_TIMESTAMP
+	)`)
+	if err != nil {
+		return fmt.Errorf("create schema_migrations: %w", err)
+	}
+	return nil
+}
+
+// SchemaVersion возвращает текущую версию схемы (0 — пустая БД).
+func (s *Storage) SchemaVersion() (int, error) {
+	if err := s.ensureMigrationsTable(); err != nil {
+		return 0, err
+	}
+	var version int
+	err := s.db.QueryRow(`SELECT COALESCE(MAX(version), 0) FROM schema_migrations`).Scan(&version)
+	if err != nil {
+		return 0, fmt.Errorf("get schema version: %w", err)
+	}
+	return version, nil
+}
+
+// MigrationStatus возвращает все известные шаги и время их применения.
+// Шаги из БД, которых нет в бинарнике (БД новее), тоже попадают в список.
+func (s *Storage) MigrationStatus() ([]MigrationStatus, error) {
+	if err := s.ensureMigrationsTable(); err != nil {
+		return nil, err
+	}
+	rows, err := s.db.Query(`SELECT version, name, applied_at FROM schema_migrations ORDER BY version`)
+	if err != nil {
+		return nil, fmt.Errorf("list schema_migrations: %w", err)
+	}
+	defer rows.Close()
+
+	applied := make(map[int]MigrationStatus)
+	for rows.Next() {
+		var st MigrationStatus
+		var appliedAt time.Time
+		if err := rows.Scan(&st.Version, &st.Name, &appliedAt); err != nil {
+			return nil, err
+		}
+		st.AppliedAt = &appliedAt
+		applied[st.Version] = st
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	var result []MigrationStatus
+	for _, m := range migrations {
+		st := MigrationStatus{Version: m.Version, Name: m.Name}
+		if a, ok := applied[m.Version]; ok {
+			st.AppliedAt = a.AppliedAt
+			delete(applied, m.Version)
+		}
+		result = append(result, st)
+	}
+	for _, a := range applied {
+		result = append(result, a)
+	}
+	return result, nil
+}
+
+// checkSchemaVersion не даёт старому бинарнику работать с БД,
+// которую уже обновил более новый (например, после отката деплоя).
+func (s *Storage) checkSchemaVersion() error {
+	current, err := s.SchemaVersion()
+	if err != nil {
+		return err
+	}
+	if latest := LatestSchemaVersion(); current > latest {
+		return fmt.Errorf("database schema version %d is newer than supported %d: run `familybot migrate down %d` with the newer binary first", current, latest, latest)
+	}
+	return nil
+}
+
+// MigrateUp применяет все шаги до версии target включительно (0 — до последней).
+func (s *Storage) MigrateUp(target int) error {
+	if target == 0 {
+		target = LatestSchemaVersion()
+	}
+	if err := s.checkSchemaVersion(); err != nil {
+		return err
+	}
+	current, err := s.SchemaVersion()
+	if err != nil {
+		return err
+	}
+
+	for _, m := range migrations {
+		if m.Version <= current || m.Version > target {
+			continue
+		}
+		if err := s.applyMigration(m, true); err != nil {
+			return fmt.Errorf("migration %d (%s) up: %w", m.Version, m.Name, err)
+		}
+		log.Printf("storage: applied migration %d (%s)", m.Version, m.Name)
+	}
+	return nil
+}
+
+// MigrateDown откатывает шаги, пока версия схемы не станет равна target.
+func (s *Storage) MigrateDown(target int) error {
+	if target < 0 {
+		return fmt.Errorf("invalid target version %d", target)
+	}
+	if err := s.checkSchemaVersion(); err != nil {
+		return err
+	}
+	current, err := s.SchemaVersion()
+	if err != nil {
+		return err
+	}
+
+	for i := len(migrations) - 1; i >= 0; i-- {
+		m := migrations[i]
+		if m.Version > current || m.Version <= target {
+			continue
+		}
+		if err := s.applyMigration(m, false); err != nil {
+			return fmt.Errorf("migration %d (%s) down: %w", m.Version, m.Name, err)
+		}
+		log.Printf("storage: rolled back migration %d (%s)", m.Version, m.Name)
+	}
+	return nil
+}
+
+func (s *Storage) applyMigration(m migration, up bool) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return fmt.Errorf("begin: %w", err)
+	}
+	defer tx.Rollback()
+
+	steps := m.steps(s.dialect, up)
+	if up && s.dialect == dialectSQLite && !s.fts5 && m.NoFTS5Up != nil {
+		steps = m.NoFTS5Up
+	}
+	for _, stmt := range steps {
+		if _, err := tx.Exec(stmt); err != nil {
+			if up && m.Legacy && strings.Contains(err.Error(), "duplicate column") {
+				continue
+			}
+			return err
+		}
+	}
+
+	if up {
+		_, err = tx.Exec(s.dialect.rebind(`INSERT INTO schema_migrations (version, name) VALUES (?, ?)`), m.Version, m.Name)
+	} else {
+		_, err = tx.Exec(s.dialect.rebind(`DELETE FROM schema_migrations WHERE version = ?`), m.Version)
+	}
+	if err != nil {
+		return fmt.Errorf("record version: %w", err)
+	}
+	return tx.Commit()
+}
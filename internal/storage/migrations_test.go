@@ -0,0 +1,118 @@
+package storage_test
+
+import (
+	"database/sql"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/tazhate/familybot/internal/domain"
+	"github.com/tazhate/familybot/internal/storage"
+)
+
+func expectVersion(t *testing.T, s *storage.Storage, want int) {
+	t.Helper()
+	if v, err := s.SchemaVersion(); err != nil || v != want {
+		t.Fatalf("schema version %d, %v; want %d", v, err, want)
+	}
+}
+
+// TestMigrateRoundTrip applies every step, rolls them all back and applies them again:
+// each Down must leave the schema the matching Up can be applied to
+func TestMigrateRoundTrip(t *testing.T) {
+	s, err := storage.OpenSQLite(filepath.Join(t.TempDir(), "familybot.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+	latest := storage.LatestSchemaVersion()
+
+	expectVersion(t, s, 0)
+	if err := s.MigrateUp(0); err != nil {
+		t.Fatal(err)
+	}
+	expectVersion(t, s, latest)
+	if err := s.CreateUser(&domain.User{TelegramID: 100, Name: "Алекс", Role: domain.RoleOwner}); err != nil {
+		t.Fatal(err)
+	}
+
+	// По одному шагу вниз до пустой БД
+	for v := latest - 1; v >= 0; v-- {
+		if err := s.MigrateDown(v); err != nil {
+			t.Fatal(err)
+		}
+		expectVersion(t, s, v)
+	}
+	statuses, err := s.MigrationStatus()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(statuses) != latest {
+		t.Errorf("%d statuses, want %d", len(statuses), latest)
+	}
+	for _, st := range statuses {
+		if st.AppliedAt != nil {
+			t.Errorf("migration %d (%s) still applied", st.Version, st.Name)
+		}
+	}
+
+	if err := s.MigrateUp(2); err != nil {
+		t.Fatal(err)
+	}
+	expectVersion(t, s, 2)
+	if err := s.MigrateUp(0); err != nil {
+		t.Fatal(err)
+	}
+	expectVersion(t, s, latest)
+	user := &domain.User{TelegramID: 100, Name: "Алекс", Role: domain.RoleOwner}
+	if err := s.CreateUser(user); err != nil {
+		t.Fatalf("create user after re-migration: %v", err)
+	}
+	task := &domain.Task{UserID: user.ID, ChatID: 100, Title: "Купить молоко", Priority: domain.PriorityWeek}
+	if err := s.CreateTask(task); err != nil {
+		t.Fatal(err)
+	}
+	if results, err := s.Search(user.ID, "молоко", 10); err != nil || len(results) != 1 {
+		t.Errorf("search after re-migration: %+v, %v", results, err)
+	}
+}
+
+// TestMigrateRefusesNewerSchema is the rollback of a deploy: the old binary must not touch
+// a database a newer one has already migrated
+func TestMigrateRefusesNewerSchema(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "familybot.db")
+	s, err := storage.New("", path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.Close()
+
+	db, err := sql.Open("sqlite3", path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	future := storage.LatestSchemaVersion() + 1
+	if _, err := db.Exec(`INSERT INTO schema_migrations (version, name) VALUES (?, 'from_the_future')`, future); err != nil {
+		t.Fatal(err)
+	}
+	db.Close()
+
+	if _, err := storage.New("", path); err == nil || !strings.Contains(err.Error(), "newer than supported") {
+		t.Fatalf("opened a newer schema: %v", err)
+	}
+	s, err = storage.OpenSQLite(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+	if err := s.MigrateDown(0); err == nil {
+		t.Error("rolled back a newer schema")
+	}
+	statuses, err := s.MigrationStatus()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if last := statuses[len(statuses)-1]; last.Version != future || last.AppliedAt == nil {
+		t.Errorf("unknown migration reported as %+v", last)
+	}
+}
@@ -0,0 +1,133 @@
+package storage
+
+import (
+	"database/sql"
+	"time"
+
+	"github.com/tazhate/familybot/internal/domain"
+)
+
+// === Notifications (outbox) ===
+// Все времена пишутся в UTC, чтобы сравнение next_attempt_at в SQLite было корректным.
+
+const notificationColumns = `id, idempotency_key, user_id, chat_id, text, task_id, conflict_id, urgent, status, attempts, last_error, next_attempt_at, created_at, sent_at`
+
+// EnqueueNotification adds the notification to the outbox at the moment now.
+// Returns false if a notification with the same key already exists; n.ID is set in both cases.
+func (s *Storage) EnqueueNotification(n *domain.Notification, now time.Time) (bool, error) {
+	now = now.UTC()
+	if n.NextAttemptAt.IsZero() {
+		n.NextAttemptAt = now
+	}
+	n.Status = domain.NotificationPending
+	n.CreatedAt = now
+
+	res, err := s.exec(
+		`INSERT INTO notifications (idempotency_key, user_id, chat_id, text, task_id, conflict_id, urgent, status, next_attempt_at, created_at)
+		 VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+		 ON CONFLICT (idempotency_key) DO NOTHING`,
+		n.Key, n.UserID, n.ChatID, n.Text, n.TaskID, n.ConflictID, n.Urgent, n.Status, n.NextAttemptAt.UTC(), n.CreatedAt,
+	)
+	if err != nil {
+		return false, err
+	}
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+
+	if err := s.queryRow(`SELECT id FROM notifications WHERE idempotency_key = ?`, n.Key).Scan(&n.ID); err != nil {
+		return false, err
+	}
+	return affected > 0, nil
+}
+
+// ListDueNotifications returns pending notifications whose next attempt is due, oldest first
+func (s *Storage) ListDueNotifications(now time.Time, limit int) ([]*domain.Notification, error) {
+	rows, err := s.query(
+		`SELECT `+notificationColumns+` FROM notifications
+		 WHERE status = ? AND next_attempt_at <= ?
+		 ORDER BY next_attempt_at, id LIMIT ?`,
+		domain.NotificationPending, now.UTC(), limit,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return scanNotifications(rows)
+}
+
+func (s *Storage) MarkNotificationSent(id int64, sentAt time.Time) error {
+	_, err := s.exec(
+		`UPDATE notifications SET status = ?, attempts = attempts + 1, last_error = '', sent_at = ? WHERE id = ?`,
+		domain.NotificationSent, sentAt.UTC(), id,
+	)
+	return err
+}
+
+// RetryNotification records a failed attempt and schedules the next one
+func (s *Storage) RetryNotification(id int64, attempts int, nextAttemptAt time.Time, lastError string) error {
+	_, err := s.exec(
+		`UPDATE notifications SET attempts = ?, next_attempt_at = ?, last_error = ? WHERE id = ?`,
+		attempts, nextAttemptAt.UTC(), lastError, id,
+	)
+	return err
+}
+
+// FailNotification records the last failed attempt and gives up
+func (s *Storage) FailNotification(id int64, attempts int, lastError string) error {
+	_, err := s.exec(
+		`UPDATE notifications SET status = ?, attempts = ?, last_error = ? WHERE id = ?`,
+		domain.NotificationFailed, attempts, lastError, id,
+	)
+	return err
+}
+
+// PostponeNotification moves the next attempt without counting it (quiet hours)
+func (s *Storage) PostponeNotification(id int64, until time.Time) error {
+	_, err := s.exec(`UPDATE notifications SET next_attempt_at = ? WHERE id = ?`, until.UTC(), id)
+	return err
+}
+
+// ListNotifications returns the delivery log, newest first; empty status means all
+func (s *Storage) ListNotifications(status domain.NotificationStatus, limit int) ([]*domain.Notification, error) {
+	query := `SELECT ` + notificationColumns + ` FROM notifications`
+	var args []any
+	if status != "" {
+		query += ` WHERE status = ?`
+		args = append(args, status)
+	}
+	query += ` ORDER BY id DESC LIMIT ?`
+	args = append(args, limit)
+
+	rows, err := s.query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	return scanNotifications(rows)
+}
+
+// DeleteSentNotificationsBefore removes old delivered notifications from the log
+func (s *Storage) DeleteSentNotificationsBefore(before time.Time) (int64, error) {
+	res, err := s.exec(`DELETE FROM notifications WHERE status = ? AND sent_at < ?`, domain.NotificationSent, before.UTC())
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
+
+func scanNotifications(rows *sql.Rows) ([]*domain.Notification, error) {
+	defer rows.Close()
+
+	var notifications []*domain.Notification
+	for rows.Next() {
+		n := &domain.Notification{}
+		var createdAt sql.NullTime
+		if err := rows.Scan(&n.ID, &n.Key, &n.UserID, &n.ChatID, &n.Text, &n.TaskID, &n.ConflictID, &n.Urgent, &n.Status,
+			&n.Attempts, &n.LastError, &n.NextAttemptAt, &createdAt, &n.SentAt); err != nil {
+			return nil, err
+		}
+		n.CreatedAt = createdAt.Time
+		notifications = append(notifications, n)
+	}
+	return notifications, rows.Err()
+}
@@ -0,0 +1,23 @@
+package storage
+
+import (
+	"database/sql"
+	"fmt"
+
+	_ "github.com/jackc/pgx/v5/stdlib"
+)
+
+// OpenPostgres подключается к PostgreSQL по DATABASE_URL без применения миграций.
+func OpenPostgres(databaseURL string) (*Storage, error) {
+	db, err := sql.Open("pgx", databaseURL)
+	if err != nil {
+		return nil, fmt.Errorf("open db: %w", err)
+	}
+
+	if err := db.Ping(); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("ping db: %w", err)
+	}
+
+	return &Storage{db: db, dialect: dialectPostgres}, nil
+}
@@ -0,0 +1,327 @@
+package storage
+
+import (
+	"time"
+
+	"github.com/tazhate/familybot/internal/domain"
+)
+
+// UserRepository — пользователи бота.
+type UserRepository interface {
+	CreateUser(u *domain.User) error
+	GetUserByTelegramID(telegramID int64) (*domain.User, error)
+	GetUserByID(id int64) (*domain.User, error)
+	ListUsers() ([]*domain.User, error)
+	GetUser(id int64) (*domain.User, error)
+	GetUserByName(name string) (*domain.User, error)
+}
+
+// TaskRepository — задачи, подзадачи и блокировки, статистика и напоминания к задачам.
+type TaskRepository interface {
+	CreateTask(t *domain.Task) error
+	TaskExistsForEventToday(userID int64, title string, todayStart time.Time) (bool, error)
+	GetTask(id int64) (*domain.Task, error)
+	GetTaskByTodoistID(todoistID string) (*domain.Task, error)
+	UpdateTaskTodoistID(taskID int64, todoistID string) error
+	ListTasksByUser(userID int64, includeShared bool, includeDone bool) ([]*domain.Task, error)
+	ListTasksByChat(chatID int64, includeDone bool) ([]*domain.Task, error)
+	ListSharedTasks(userID int64, includeDone bool) ([]*domain.Task, error)
+	UpdateTaskShared(taskID int64, isShared bool) error
+	ListTasksForToday(userID int64, now time.Time) ([]*domain.Task, error)
+	ListTasksForTodayByChat(chatID int64, now time.Time) ([]*domain.Task, error)
+	ListTasksByPerson(personID int64, includeDone bool) ([]*domain.Task, error)
+	UpdateTaskAssignment(taskID int64, assignedTo *int64) error
+	UpdateTaskPerson(taskID int64, personID *int64) error
+	MarkTaskDone(id int64) error
+	DeleteTask(id int64) error
+	UpdateTask(t *domain.Task) error
+	UpdateTaskTitle(taskID int64, title string) error
+	UpdateTaskPriority(taskID int64, priority domain.Priority) error
+	UpdateTaskDueDate(taskID int64, dueDate *time.Time) error
+	UpdateTaskRepeatType(taskID int64, repeatType domain.RepeatType) error
+	UpdateTaskRecurrence(taskID int64, repeatType domain.RepeatType, rrule string, start *time.Time) error
+	ListUrgentTasksForReminder(now time.Time) ([]*domain.Task, error)
+	UpdateTaskReminder(taskID int64, at time.Time) error
+	SnoozeTask(taskID int64, until time.Time) error
+	ListRepeatingTasksByTime(repeatTime string, now time.Time) ([]*domain.Task, error)
+
+	UpdateTaskParent(taskID int64, parentID *int64) error
+	ListSubtasks(parentID int64) ([]*domain.Task, error)
+	AddTaskBlocker(taskID, blockerID int64) error
+	RemoveTaskBlocker(taskID, blockerID int64) error
+	ListTaskBlockers(taskID int64) ([]*domain.Task, error)
+	ListBlockedTaskIDs() (map[int64]bool, error)
+
+	ListCompletedTasks(userID int64, limit int) ([]*domain.Task, error)
+	GetTaskStats(userID int64, since time.Time) (completed int, created int, err error)
+	GetPendingTaskCount(userID int64) (int, error)
+
+	CreateTaskReminder(tr *domain.TaskReminder) error
+	GetTaskReminders(taskID int64) ([]*domain.TaskReminder, error)
+	DeleteTaskReminder(id int64) error
+	DeleteTaskRemindersByTask(taskID int64) error
+	MarkTaskReminderSent(id int64) error
+	GetPendingTaskReminders(now time.Time) ([]*domain.TaskReminder, []*domain.Task, error)
+}
+
+// ReminderRepository — регулярные напоминания.
+type ReminderRepository interface {
+	CreateReminder(r *domain.Reminder) error
+	GetReminder(id int64) (*domain.Reminder, error)
+	ListRemindersByUser(userID int64) ([]*domain.Reminder, error)
+	ListDueReminders(now time.Time) ([]*domain.Reminder, error)
+	UpdateReminderNextRun(id int64, lastSent, nextRun time.Time) error
+	DeleteReminder(id int64) error
+	UpdateReminder(r *domain.Reminder) error
+	UpdateReminderTitle(id int64, title string) error
+}
+
+// PersonRepository — люди (дети, семья, контакты).
+type PersonRepository interface {
+	CreatePerson(p *domain.Person) error
+	GetPerson(id int64) (*domain.Person, error)
+	GetPersonByName(userID int64, name string) (*domain.Person, error)
+	ListPersonsByUser(userID int64) ([]*domain.Person, error)
+	ListPersonsWithBirthday(userID int64) ([]*domain.Person, error)
+	ListUpcomingBirthdays(userID int64, days int, now time.Time) ([]*domain.Person, error)
+	UpdatePerson(p *domain.Person) error
+	UpdatePersonTelegramID(personID int64, telegramID *int64) error
+	GetPersonByTelegramID(userID int64, telegramID int64) (*domain.Person, error)
+	DeletePerson(id int64) error
+}
+
+// WeeklyEventRepository — недельное расписание.
+type WeeklyEventRepository interface {
+	CreateWeeklyEvent(e *domain.WeeklyEvent) error
+	GetWeeklyEvent(id int64) (*domain.WeeklyEvent, error)
+	ListWeeklyEventsByUser(userID int64, includeShared bool) ([]*domain.WeeklyEvent, error)
+	ListWeeklyEventsByDay(userID int64, dayOfWeek domain.Weekday, includeShared bool) ([]*domain.WeeklyEvent, error)
+	DeleteWeeklyEvent(id int64) error
+	UpdateWeeklyEvent(e *domain.WeeklyEvent) error
+	UpdateWeeklyEventConfirmedDay(id int64, confirmedDay *int, confirmedWeek int) error
+	ListFloatingEvents(userID int64) ([]*domain.WeeklyEvent, error)
+	ListEventsWithReminders() ([]*domain.WeeklyEvent, error)
+	UpdateWeeklyEventChecklist(eventID int64, checklistID *int64) error
+	UpdateWeeklyEventShared(eventID int64, isShared bool) error
+	UpdateWeeklyEventTrackable(eventID int64, isTrackable bool) error
+	UpdateWeeklyEventTitle(eventID int64, title string) error
+	UpdateWeeklyEventDay(eventID int64, day domain.Weekday) error
+	UpdateWeeklyEventTime(eventID int64, timeStart, timeEnd string) error
+}
+
+// AutoRepository — машины.
+type AutoRepository interface {
+	CreateAuto(a *domain.Auto) error
+	GetAuto(id int64) (*domain.Auto, error)
+	ListAutosByUser(userID int64) ([]*domain.Auto, error)
+	UpdateAutoInsurance(id int64, until time.Time) error
+	UpdateAutoMaintenance(id int64, until time.Time) error
+	DeleteAuto(id int64) error
+	ListAutosNeedingReminder(days int, now time.Time) ([]*domain.Auto, error)
+}
+
+// ChecklistRepository — чек-листы.
+type ChecklistRepository interface {
+	CreateChecklist(c *domain.Checklist) error
+	GetChecklist(id int64) (*domain.Checklist, error)
+	GetChecklistByTitle(userID int64, title string) (*domain.Checklist, error)
+	ListChecklistsByUser(userID int64) ([]*domain.Checklist, error)
+	UpdateChecklistItems(id int64, items []domain.ChecklistItem) error
+	DeleteChecklist(id int64) error
+}
+
+// ChecklistRunRepository — прогоны чек-листов с отметками.
+type ChecklistRunRepository interface {
+	CreateChecklistRun(r *domain.ChecklistRun) error
+	GetChecklistRun(id int64) (*domain.ChecklistRun, error)
+	GetOpenChecklistRun(checklistID int64) (*domain.ChecklistRun, error)
+	GetEventChecklistRun(weeklyEventID int64, since time.Time) (*domain.ChecklistRun, error)
+	ListChecklistRuns(checklistID int64, limit int) ([]*domain.ChecklistRun, error)
+	UpdateChecklistRun(r *domain.ChecklistRun) error
+}
+
+// CalendarEventRepository — события, синхронизированные с Apple Calendar.
+type CalendarEventRepository interface {
+	CreateCalendarEvent(e *domain.CalendarEvent) error
+	GetCalendarEvent(id int64) (*domain.CalendarEvent, error)
+	GetCalendarEventByCalDAVUID(uid string) (*domain.CalendarEvent, error)
+	GetCalendarEventByCalDAVPath(path string) (*domain.CalendarEvent, error)
+	UpdateCalendarEvent(e *domain.CalendarEvent) error
+	DeleteCalendarEvent(id int64) error
+	DeleteCalendarEventByCalDAVUID(uid string) error
+	ListCalendarEvents(userID int64, from, to time.Time, includeShared bool) ([]*domain.CalendarEvent, error)
+	ListCalendarEventsToday(userID int64, includeShared bool, now time.Time) ([]*domain.CalendarEvent, error)
+	ListCalendarEventsWeek(userID int64, includeShared bool, now time.Time) ([]*domain.CalendarEvent, error)
+	ListAllCalendarEvents() ([]*domain.CalendarEvent, error)
+	ListCalendarEventsByCalendar(calendarID int64) ([]*domain.CalendarEvent, error)
+	ListUpcomingCalendarEventsForReminder(minutes int, now time.Time) ([]*domain.CalendarEvent, error)
+}
+
+// CalendarAccountRepository — CalDAV-аккаунты пользователей и подключённые календари
+// с их sync-token и ctag.
+type CalendarAccountRepository interface {
+	CreateCalendarAccount(a *domain.CalendarAccount) error
+	GetCalendarAccount(id int64) (*domain.CalendarAccount, error)
+	GetCalendarAccountByLogin(userID int64, url, username string) (*domain.CalendarAccount, error)
+	ListCalendarAccounts(userID int64) ([]*domain.CalendarAccount, error)
+	UpdateCalendarAccount(a *domain.CalendarAccount) error
+	DeleteCalendarAccount(id int64) error
+	CreateCalendar(c *domain.Calendar) error
+	GetCalendar(id int64) (*domain.Calendar, error)
+	GetCalendarByPath(accountID int64, path string) (*domain.Calendar, error)
+	ListCalendars(userID int64) ([]*domain.Calendar, error)
+	ListAllCalendars() ([]*domain.Calendar, error)
+	UpdateCalendar(c *domain.Calendar) error
+	DeleteCalendar(id int64) error
+	SetCalendarEventsShared(calendarID int64, shared bool) error
+	AdoptCalendarEvents(calendarID, userID int64) (int64, error)
+}
+
+// CalendarConflictRepository — снимки последней синхронизации событий и конфликты правок
+type CalendarConflictRepository interface {
+	SaveCalendarEventSnapshot(snap *domain.CalendarEventSnapshot) error
+	GetCalendarEventSnapshot(eventID int64) (*domain.CalendarEventSnapshot, error)
+	CreateCalendarConflict(c *domain.CalendarConflict) error
+	GetCalendarConflict(id int64) (*domain.CalendarConflict, error)
+	UpdateCalendarConflict(c *domain.CalendarConflict) error
+	ListCalendarConflicts(userID int64) ([]*domain.CalendarConflict, error)
+	DeleteCalendarConflict(id int64) error
+}
+
+// CalendarFeedRepository — личные ссылки на подписку на календарь семьи
+type CalendarFeedRepository interface {
+	SaveCalendarFeed(f *domain.CalendarFeed) error
+	GetCalendarFeed(userID int64) (*domain.CalendarFeed, error)
+	GetCalendarFeedByToken(token string) (*domain.CalendarFeed, error)
+	DeleteCalendarFeed(userID int64) error
+}
+
+// SearchRepository — полнотекстовый поиск по всем сущностям.
+type SearchRepository interface {
+	Search(userID int64, query string, limit int) ([]*domain.SearchResult, error)
+}
+
+// HouseholdRepository — семьи, их участники и приглашения.
+type HouseholdRepository interface {
+	CreateHousehold(h *domain.Household) error
+	GetHousehold(id int64) (*domain.Household, error)
+	UpdateHouseholdName(id int64, name string) error
+	AddHouseholdMember(m *domain.HouseholdMember) error
+	GetHouseholdMember(userID int64) (*domain.HouseholdMember, error)
+	UpdateHouseholdMemberRole(userID int64, role domain.HouseholdRole) error
+	RemoveHouseholdMember(userID int64) error
+	ListHouseholdMembers(householdID int64) ([]*domain.HouseholdMember, error)
+	ListHouseholdUsers() ([]*domain.User, error)
+	CreateHouseholdInvite(inv *domain.HouseholdInvite) error
+	GetHouseholdInvite(code string) (*domain.HouseholdInvite, error)
+	AcceptHouseholdInvite(code string, m *domain.HouseholdMember, at time.Time) error
+}
+
+// SettingsRepository — личные настройки.
+type SettingsRepository interface {
+	GetUserSettings(userID int64) (*domain.UserSettings, error)
+	SaveUserSettings(st *domain.UserSettings) error
+}
+
+// NotificationRepository — исходящие уведомления (outbox) и журнал доставки.
+type NotificationRepository interface {
+	EnqueueNotification(n *domain.Notification, now time.Time) (bool, error)
+	ListDueNotifications(now time.Time, limit int) ([]*domain.Notification, error)
+	MarkNotificationSent(id int64, sentAt time.Time) error
+	RetryNotification(id int64, attempts int, nextAttemptAt time.Time, lastError string) error
+	FailNotification(id int64, attempts int, lastError string) error
+	PostponeNotification(id int64, until time.Time) error
+	ListNotifications(status domain.NotificationStatus, limit int) ([]*domain.Notification, error)
+	DeleteSentNotificationsBefore(before time.Time) (int64, error)
+}
+
+// SchedulerRepository — отметки планировщика «обработано до» по каждой задаче.
+type SchedulerRepository interface {
+	GetJobWatermark(job string) (*time.Time, error)
+	SetJobWatermark(job string, at time.Time) error
+}
+
+// BotStateRepository — служебные значения бота (offset getUpdates и т.п.).
+type BotStateRepository interface {
+	GetBotState(key string) (string, error)
+	SetBotState(key, value string) error
+}
+
+// ConversationRepository — состояние пошаговых диалогов бота.
+type ConversationRepository interface {
+	GetConversation(chatID, userID int64) (*domain.Conversation, error)
+	SaveConversation(c *domain.Conversation) error
+	DeleteConversation(chatID, userID int64) error
+}
+
+// LiveMessageRepository — сообщения, которые бот обновляет при изменениях.
+type LiveMessageRepository interface {
+	TrackMessage(m *domain.LiveMessage) error
+	UntrackMessage(chatID int64, messageID int) error
+	ListLiveMessages(kind domain.LiveMessageKind, refID int64) ([]*domain.LiveMessage, error)
+	DeleteLiveMessagesBefore(before time.Time) (int64, error)
+}
+
+// AutoLogRepository — пробег, сервисная книжка, регламент ТО и документы машин.
+type AutoLogRepository interface {
+	AddOdometerReading(r *domain.OdometerReading) error
+	GetLatestOdometerReading(autoID int64) (*domain.OdometerReading, error)
+	ListOdometerReadings(autoID int64, limit int) ([]*domain.OdometerReading, error)
+
+	CreateServiceRecord(r *domain.ServiceRecord) error
+	ListServiceRecords(autoID int64, limit int) ([]*domain.ServiceRecord, error)
+	ServiceCostTotal(autoID int64) (int, float64, error)
+
+	CreateMaintenanceRule(r *domain.MaintenanceRule) error
+	GetMaintenanceRule(id int64) (*domain.MaintenanceRule, error)
+	ListMaintenanceRules(autoID int64) ([]*domain.MaintenanceRule, error)
+	UpdateMaintenanceRuleDone(id int64, km int, doneAt time.Time) error
+	DeleteMaintenanceRule(id int64) error
+
+	CreateAutoDocument(d *domain.AutoDocument) error
+	GetAutoDocument(id int64) (*domain.AutoDocument, error)
+	ListAutoDocuments(userID int64) ([]*domain.AutoDocument, error)
+	ListAutoDocumentsByAuto(autoID int64) ([]*domain.AutoDocument, error)
+	ListAutoDocumentsExpiringBefore(before time.Time) ([]*domain.AutoDocument, error)
+	UpdateAutoDocumentExpiry(id int64, expiresAt time.Time) error
+	DeleteAutoDocument(id int64) error
+}
+
+// AttachmentRepository — файлы, прикреплённые к задачам, людям и машинам.
+type AttachmentRepository interface {
+	CreateAttachment(a *domain.Attachment) error
+	GetAttachment(id int64) (*domain.Attachment, error)
+	ListAttachments(ownerType domain.AttachmentOwner, ownerID int64) ([]*domain.Attachment, error)
+	DeleteAttachment(id int64) error
+}
+
+// Store объединяет все репозитории. Сервисы, бот и планировщик зависят от Store,
+// а не от конкретной БД: реализация — Storage поверх SQLite или PostgreSQL.
+type Store interface {
+	UserRepository
+	TaskRepository
+	ReminderRepository
+	PersonRepository
+	WeeklyEventRepository
+	AutoRepository
+	ChecklistRepository
+	ChecklistRunRepository
+	CalendarEventRepository
+	CalendarAccountRepository
+	CalendarConflictRepository
+	CalendarFeedRepository
+	SearchRepository
+	HouseholdRepository
+	SettingsRepository
+	NotificationRepository
+	SchedulerRepository
+	BotStateRepository
+	ConversationRepository
+	LiveMessageRepository
+	AttachmentRepository
+	AutoLogRepository
+
+	Close() error
+}
+
+var _ Store = (*Storage)(nil)
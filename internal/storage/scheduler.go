@@ -0,0 +1,30 @@
+package storage
+
+import (
+	"database/sql"
+	"time"
+)
+
+// === Scheduler watermarks ===
+
+// GetJobWatermark returns the moment up to which the job has processed occurrences
+func (s *Storage) GetJobWatermark(job string) (*time.Time, error) {
+	var at time.Time
+	err := s.queryRow(`SELECT processed_at FROM job_watermarks WHERE job = ?`, job).Scan(&at)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &at, nil
+}
+
+func (s *Storage) SetJobWatermark(job string, at time.Time) error {
+	_, err := s.exec(
+		`INSERT INTO job_watermarks (job, processed_at) VALUES (?, ?)
+		 ON CONFLICT (job) DO UPDATE SET processed_at = excluded.processed_at`,
+		job, at.UTC(),
+	)
+	return err
+}
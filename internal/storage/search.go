@@ -0,0 +1,137 @@
+package storage
+
+import (
+	"sort"
+	"strings"
+	"unicode"
+
+	"github.com/tazhate/familybot/internal/domain"
+)
+
+// === Search ===
+
+// Search ищет по задачам, людям, чек-листам, расписанию и событиям календаря.
+// Возвращает результаты, отсортированные по релевантности.
+func (s *Storage) Search(userID int64, query string, limit int) ([]*domain.SearchResult, error) {
+	terms := searchTerms(query)
+	if len(terms) == 0 {
+		return nil, nil
+	}
+
+	var sqlQuery string
+	var match string
+	if s.dialect == dialectSQLite {
+		fts, err := s.searchIndexIsFTS()
+		if err != nil {
+			return nil, err
+		}
+		if !fts {
+			return s.searchSubstring(userID, terms, limit)
+		}
+	}
+	if s.dialect == dialectPostgres {
+		// Префиксный поиск: молок → молоко, молока...
+		for i, t := range terms {
+			terms[i] = t + ":*"
+		}
+		match = strings.Join(terms, " & ")
+		// Запрос стоит прямо в WHERE, чтобы условие ушло в GIN-индексы таблиц (миграция 2)
+		sqlQuery = `SELECT kind, ref_id, title, body, ts_rank(to_tsvector('simple', title || ' ' || body), to_tsquery('simple', ?)) AS rank
+			FROM search_index
+			WHERE to_tsvector('simple', title || ' ' || body) @@ to_tsquery('simple', ?) AND (user_id = ? OR (is_shared AND ` + householdOfUser + `))
+			ORDER BY rank DESC
+			LIMIT ?`
+	} else {
+		for i, t := range terms {
+			terms[i] = `"` + t + `"*`
+		}
+		match = strings.Join(terms, " ")
+		// bm25 возвращает отрицательные значения: меньше — релевантнее
+		sqlQuery = `SELECT kind, ref_id, title, body, -bm25(search_index, 10.0, 1.0) AS rank
+			FROM search_index
+			WHERE search_index MATCH ? AND (user_id = ? OR (is_shared = 1 AND ` + householdOfUser + `))
+			ORDER BY bm25(search_index, 10.0, 1.0)
+			LIMIT ?`
+	}
+
+	args := []any{match, userID, userID, limit}
+	if s.dialect == dialectPostgres {
+		args = append([]any{match}, args...)
+	}
+	rows, err := s.query(sqlQuery, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var results []*domain.SearchResult
+	for rows.Next() {
+		r := &domain.SearchResult{}
+		if err := rows.Scan(&r.Kind, &r.RefID, &r.Title, &r.Body, &r.Rank); err != nil {
+			return nil, err
+		}
+		results = append(results, r)
+	}
+	return results, rows.Err()
+}
+
+// searchIndexIsFTS сообщает, создан ли search_index через FTS5 (см. миграцию 2).
+func (s *Storage) searchIndexIsFTS() (bool, error) {
+	var ddl string
+	if err := s.queryRow(`SELECT sql FROM sqlite_master WHERE name = 'search_index'`).Scan(&ddl); err != nil {
+		return false, err
+	}
+	return strings.Contains(strings.ToLower(ddl), "using fts5"), nil
+}
+
+// searchSubstring — поиск без FTS5: все доступные записи индекса фильтруются здесь же.
+// LIKE в SQLite не различает регистр только для ASCII, а задачи в основном по-русски.
+// Совпадение в заголовке весит больше, как в bm25 выше.
+func (s *Storage) searchSubstring(userID int64, terms []string, limit int) ([]*domain.SearchResult, error) {
+	rows, err := s.query(`SELECT kind, ref_id, title, body FROM search_index
+		WHERE user_id = ? OR (is_shared = 1 AND `+householdOfUser+`)`, userID, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var results []*domain.SearchResult
+	for rows.Next() {
+		r := &domain.SearchResult{}
+		if err := rows.Scan(&r.Kind, &r.RefID, &r.Title, &r.Body); err != nil {
+			return nil, err
+		}
+		title, body := strings.ToLower(r.Title), strings.ToLower(r.Body)
+		for _, t := range terms {
+			inTitle, inBody := strings.Count(title, t), strings.Count(body, t)
+			if inTitle+inBody == 0 {
+				r = nil
+				break
+			}
+			r.Rank += float64(10*inTitle + inBody)
+		}
+		if r != nil {
+			results = append(results, r)
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	sort.SliceStable(results, func(i, j int) bool { return results[i].Rank > results[j].Rank })
+	if len(results) > limit {
+		results = results[:limit]
+	}
+	return results, nil
+}
+
+// searchTerms разбивает запрос на слова, выбрасывая символы синтаксиса FTS.
+func searchTerms(query string) []string {
+	words := strings.FieldsFunc(strings.ToLower(query), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+	if len(words) > 8 {
+		words = words[:8]
+	}
+	return words
+}
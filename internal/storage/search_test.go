@@ -0,0 +1,51 @@
+package storage
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/tazhate/familybot/internal/domain"
+)
+
+// TestSearchWithoutFTS5 builds the index as on SQLite without FTS5,
+// whatever tags the test binary was built with
+func TestSearchWithoutFTS5(t *testing.T) {
+	s, err := OpenSQLite(filepath.Join(t.TempDir(), "familybot.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+	s.fts5 = false
+	if err := s.MigrateUp(0); err != nil {
+		t.Fatal(err)
+	}
+	if fts, err := s.searchIndexIsFTS(); err != nil || fts {
+		t.Fatalf("search_index is fts5: %v, %v", fts, err)
+	}
+
+	user := &domain.User{TelegramID: 100, Name: "Алекс", Role: domain.RoleOwner}
+	if err := s.CreateUser(user); err != nil {
+		t.Fatal(err)
+	}
+	for _, task := range []*domain.Task{
+		{UserID: user.ID, ChatID: 100, Title: "Купить молоко", Priority: domain.PriorityWeek},
+		{UserID: user.ID, ChatID: 100, Title: "Блины", Description: "нужно молоко", Priority: domain.PriorityWeek},
+		{UserID: user.ID, ChatID: 100, Title: "Хлеб", Priority: domain.PriorityWeek},
+	} {
+		if err := s.CreateTask(task); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	results, err := s.Search(user.ID, "МОЛОК", 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Совпадение в заголовке выше, чем в описании
+	if len(results) != 2 || results[0].Title != "Купить молоко" || results[1].Title != "Блины" {
+		t.Errorf("found %+v", results)
+	}
+	if results, err := s.Search(user.ID, "купить хлеб", 10); err != nil || len(results) != 0 {
+		t.Errorf("all terms must match, found %+v, %v", results, err)
+	}
+}
@@ -0,0 +1,42 @@
+package storage
+
+import (
+	"database/sql"
+	"time"
+
+	"github.com/tazhate/familybot/internal/domain"
+)
+
+// === User settings ===
+
+func (s *Storage) GetUserSettings(userID int64) (*domain.UserSettings, error) {
+	st := &domain.UserSettings{}
+	err := s.queryRow(
+		`SELECT user_id, timezone, morning_time, evening_time, quiet_start, quiet_end, briefing_sections, updated_at
+		 FROM user_settings WHERE user_id = ?`,
+		userID,
+	).Scan(&st.UserID, &st.Timezone, &st.MorningTime, &st.EveningTime, &st.QuietStart, &st.QuietEnd, &st.BriefingSections, &st.UpdatedAt)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	return st, err
+}
+
+// SaveUserSettings inserts or replaces the user's settings
+func (s *Storage) SaveUserSettings(st *domain.UserSettings) error {
+	st.UpdatedAt = time.Now()
+	_, err := s.exec(
+		`INSERT INTO user_settings (user_id, timezone, morning_time, evening_time, quiet_start, quiet_end, briefing_sections, updated_at)
+		 VALUES (?, ?, ?, ?, ?, ?, ?, ?)
+		 ON CONFLICT (user_id) DO UPDATE SET
+		   timezone = excluded.timezone,
+		   morning_time = excluded.morning_time,
+		   evening_time = excluded.evening_time,
+		   quiet_start = excluded.quiet_start,
+		   quiet_end = excluded.quiet_end,
+		   briefing_sections = excluded.briefing_sections,
+		   updated_at = excluded.updated_at`,
+		st.UserID, st.Timezone, st.MorningTime, st.EveningTime, st.QuietStart, st.QuietEnd, st.BriefingSections, st.UpdatedAt,
+	)
+	return err
+}
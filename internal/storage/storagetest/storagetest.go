@@ -0,0 +1,54 @@
+// Package storagetest — базы данных для тестов: пустая SQLite во временном каталоге
+// и PostgreSQL из DATABASE_URL, обе со схемой последней версии.
+package storagetest
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/tazhate/familybot/internal/storage"
+)
+
+// SQLite opens a fresh migrated SQLite database that is removed after the test.
+// Without -tags sqlite_fts5 search runs on the substring fallback.
+func SQLite(t testing.TB) *storage.Storage {
+	t.Helper()
+	s, err := storage.New("", filepath.Join(t.TempDir(), "familybot.db"))
+	if err != nil {
+		t.Fatalf("open sqlite: %v", err)
+	}
+	t.Cleanup(func() { s.Close() })
+	return s
+}
+
+// Postgres opens the database from DATABASE_URL, skipping the test if it is not set.
+// All migrations are rolled back and applied again, so the database is WIPED:
+// point DATABASE_URL at a scratch database, never at the bot's one.
+func Postgres(t testing.TB) *storage.Storage {
+	t.Helper()
+	url := os.Getenv("DATABASE_URL")
+	if url == "" {
+		t.Skip("DATABASE_URL is not set")
+	}
+	s, err := storage.Open(url, "")
+	if err != nil {
+		t.Fatalf("open postgres: %v", err)
+	}
+	t.Cleanup(func() { s.Close() })
+	if err := s.MigrateDown(0); err != nil {
+		t.Fatalf("wipe postgres: %v", err)
+	}
+	if err := s.MigrateUp(0); err != nil {
+		t.Fatalf("migrate postgres: %v", err)
+	}
+	return s
+}
+
+// Each runs the test as a subtest on every backend: always on SQLite,
+// on PostgreSQL when DATABASE_URL is set
+func Each(t *testing.T, test func(t *testing.T, s *storage.Storage)) {
+	t.Helper()
+	t.Run("sqlite", func(t *testing.T) { test(t, SQLite(t)) })
+	t.Run("postgres", func(t *testing.T) { test(t, Postgres(t)) })
+}
@@ -0,0 +1,83 @@
+package storage
+
+import (
+	"github.com/tazhate/familybot/internal/domain"
+)
+
+// === Task relations: subtasks and blockers ===
+
+// UpdateTaskParent sets or clears (nil) the parent task
+func (s *Storage) UpdateTaskParent(taskID int64, parentID *int64) error {
+	_, err := s.exec(`UPDATE tasks SET parent_id = ? WHERE id = ?`, parentID, taskID)
+	return err
+}
+
+// ListSubtasks returns direct children of a task, done ones included
+func (s *Storage) ListSubtasks(parentID int64) ([]*domain.Task, error) {
+	return s.listTasksWhere(`parent_id = ?`, parentID)
+}
+
+// AddTaskBlocker marks taskID as waiting for blockerID
+func (s *Storage) AddTaskBlocker(taskID, blockerID int64) error {
+	_, err := s.exec(
+		`INSERT INTO task_blockers (task_id, blocker_id) VALUES (?, ?) ON CONFLICT DO NOTHING`,
+		taskID, blockerID,
+	)
+	return err
+}
+
+// RemoveTaskBlocker removes a single blocker link
+func (s *Storage) RemoveTaskBlocker(taskID, blockerID int64) error {
+	_, err := s.exec(`DELETE FROM task_blockers WHERE task_id = ? AND blocker_id = ?`, taskID, blockerID)
+	return err
+}
+
+// ListTaskBlockers returns tasks that block taskID, done ones included
+func (s *Storage) ListTaskBlockers(taskID int64) ([]*domain.Task, error) {
+	return s.listTasksWhere(`id IN (SELECT blocker_id FROM task_blockers WHERE task_id = ?)`, taskID)
+}
+
+// ListBlockedTaskIDs returns IDs of tasks that have at least one open blocker
+func (s *Storage) ListBlockedTaskIDs() (map[int64]bool, error) {
+	rows, err := s.query(
+		`SELECT DISTINCT b.task_id FROM task_blockers b
+		 JOIN tasks bt ON bt.id = b.blocker_id
+		 WHERE bt.done_at IS NULL`,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	blocked := make(map[int64]bool)
+	for rows.Next() {
+		var id int64
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		blocked[id] = true
+	}
+	return blocked, rows.Err()
+}
+
+func (s *Storage) listTasksWhere(where string, args ...any) ([]*domain.Task, error) {
+	rows, err := s.query(
+		`SELECT id, user_id, chat_id, assigned_to, person_id, title, description, priority, is_shared, due_date, done_at, created_at, reminder_count, last_reminded_at, snooze_until, repeat_type, repeat_time, repeat_week_num, COALESCE(todoist_id, ''), parent_id, COALESCE(rrule, ''), rrule_start
+		 FROM tasks WHERE `+where+` ORDER BY created_at ASC, id ASC`,
+		args...,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var tasks []*domain.Task
+	for rows.Next() {
+		t := &domain.Task{}
+		if err := rows.Scan(&t.ID, &t.UserID, &t.ChatID, &t.AssignedTo, &t.PersonID, &t.Title, &t.Description, &t.Priority, &t.IsShared, &t.DueDate, &t.DoneAt, &t.CreatedAt, &t.ReminderCount, &t.LastRemindedAt, &t.SnoozeUntil, &t.RepeatType, &t.RepeatTime, &t.RepeatWeekNum, &t.TodoistID, &t.ParentID, &t.RRule, &t.RRuleStart); err != nil {
+			return nil, err
+		}
+		tasks = append(tasks, t)
+	}
+	return tasks, rows.Err()
+}